@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/wagnerjt/go-mcp/client/pkg/mcpclient"
+)
+
+// runAuthCheck validates the authorization of the streamable HTTP endpoint of
+// the profile against the MCP authorization spec, without connecting.
+func runAuthCheck(ctx context.Context, p mcpclient.Profile, args []string) error {
+	fs := flag.NewFlagSet("auth check", flag.ExitOnError)
+	token := fs.String("token", "", "Valid access token, enables the checks of the accepted tokens")
+	fs.Parse(args)
+	endpoint := p.URL
+	if fs.NArg() > 0 {
+		endpoint = fs.Arg(0)
+	}
+
+	results := mcpclient.CheckAuthorization(ctx, endpoint, *token,
+		mcpclient.WithProxy(proxyURL),
+		mcpclient.WithCACert(caCert),
+		mcpclient.WithInsecure(insecure),
+	)
+	failed := 0
+	for _, result := range results {
+		if !result.OK {
+			failed++
+		}
+	}
+	if jsonEnvelope && failed > 0 {
+		// reported along with the error by exit
+		partialResult = results
+		return fmt.Errorf("%d of %d authorization checks failed", failed, len(results))
+	}
+	err := printResult(results, func() []string {
+		var lines []string
+		for _, result := range results {
+			status := "ok  "
+			if !result.OK {
+				status = "FAIL"
+			}
+			line := fmt.Sprintf("%s  %s", status, result.Name)
+			if result.Detail != "" {
+				line += ": " + result.Detail
+			}
+			lines = append(lines, line)
+		}
+		return lines
+	})
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d authorization checks failed", failed, len(results))
+	}
+	return nil
+}
@@ -0,0 +1,400 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/wagnerjt/go-mcp/client/pkg/mcpclient"
+)
+
+const (
+	formatText string = "text"
+	formatJSON string = "json"
+)
+
+// keyValueFlag collects k=v pairs given as comma separated lists or by
+// repeating the flag.
+type keyValueFlag map[string]string
+
+func (f keyValueFlag) String() string {
+	var pairs []string
+	for k, v := range f {
+		pairs = append(pairs, k+"="+v)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (f keyValueFlag) Set(value string) error {
+	for _, pair := range strings.Split(value, ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("expected key=value, got %q", pair)
+		}
+		f[strings.TrimSpace(k)] = v
+	}
+	return nil
+}
+
+// commandName is the command being run, reported in the -json envelope.
+var commandName string
+
+// pageFlags are the pagination flags shared by the list commands.
+type pageFlags struct {
+	cursor string
+	all    bool
+}
+
+func newListFlags(name string) (*flag.FlagSet, *pageFlags) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	page := &pageFlags{}
+	fs.StringVar(&page.cursor, "cursor", "", "Cursor of the page to fetch")
+	fs.BoolVar(&page.all, "all", false, "Follow nextCursor and fetch every page")
+	return fs, page
+}
+
+func runCommand(ctx context.Context, c *mcpclient.Client, args []string) error {
+	if len(args) < 2 {
+		flag.Usage()
+		return &usageError{"missing subcommand"}
+	}
+	// tool calls have their own timeouts, a call may wait on prompts
+	switch args[0] + " " + args[1] {
+	case "tools call":
+		return callTool(ctx, c, args[2:])
+	case "tools batch":
+		return callToolsBatch(ctx, c, args[2:])
+	}
+
+	ctx, cancel := operationContext(ctx)
+	defer cancel()
+	switch args[0] + " " + args[1] {
+	case "resources list":
+		return listResources(ctx, c, args[2:])
+	case "resources read":
+		return readResource(ctx, c, args[2:])
+	case "templates list":
+		return listResourceTemplates(ctx, c, args[2:])
+	case "prompts list":
+		return listPrompts(ctx, c, args[2:])
+	case "prompts get":
+		return getPrompt(ctx, c, args[2:])
+	default:
+		flag.Usage()
+		return &usageError{fmt.Sprintf("unknown command %q", strings.Join(args[:2], " "))}
+	}
+}
+
+// printResult writes v as JSON, or the text lines returned by text. In -json
+// mode v is wrapped in the envelope.
+func printResult(v any, text func() []string) error {
+	if jsonEnvelope {
+		return writeEnvelope(envelope{OK: true, Command: commandName, Result: v})
+	}
+	if outputFormat == formatJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	}
+	for _, line := range text() {
+		fmt.Println(line)
+	}
+	return nil
+}
+
+func nextCursorLine(cursor mcp.Cursor) []string {
+	if cursor == "" {
+		return nil
+	}
+	return []string{fmt.Sprintf("next cursor: %s", cursor)}
+}
+
+func listResources(ctx context.Context, c *mcpclient.Client, args []string) error {
+	fs, page := newListFlags("resources list")
+	fs.Parse(args)
+
+	request := mcp.ListResourcesRequest{}
+	request.Params.Cursor = mcp.Cursor(page.cursor)
+	var result *mcp.ListResourcesResult
+	var err error
+	if page.all {
+		result, err = c.ListResources(ctx, request)
+	} else {
+		result, err = c.ListResourcesByPage(ctx, request)
+	}
+	if err != nil {
+		return err
+	}
+
+	return printResult(result, func() []string {
+		var lines []string
+		for _, r := range result.Resources {
+			lines = append(lines, fmt.Sprintf("%s\t%s\t%s\t%s", r.URI, r.Name, r.MIMEType, r.Description))
+		}
+		return append(lines, nextCursorLine(result.NextCursor)...)
+	})
+}
+
+func readResource(ctx context.Context, c *mcpclient.Client, args []string) error {
+	if len(args) != 1 {
+		return &usageError{"usage: resources read <uri>"}
+	}
+
+	request := mcp.ReadResourceRequest{}
+	request.Params.URI = args[0]
+	result, err := c.ReadResource(ctx, request)
+	if err != nil {
+		return err
+	}
+
+	return printResult(result, func() []string {
+		var lines []string
+		for _, content := range result.Contents {
+			switch content := content.(type) {
+			case mcp.TextResourceContents:
+				lines = append(lines, content.Text)
+			case mcp.BlobResourceContents:
+				lines = append(lines, fmt.Sprintf("<%s blob, %d base64 bytes>", content.MIMEType, len(content.Blob)))
+			}
+		}
+		return lines
+	})
+}
+
+func listResourceTemplates(ctx context.Context, c *mcpclient.Client, args []string) error {
+	fs, page := newListFlags("templates list")
+	fs.Parse(args)
+
+	request := mcp.ListResourceTemplatesRequest{}
+	request.Params.Cursor = mcp.Cursor(page.cursor)
+	var result *mcp.ListResourceTemplatesResult
+	var err error
+	if page.all {
+		result, err = c.ListResourceTemplates(ctx, request)
+	} else {
+		result, err = c.ListResourceTemplatesByPage(ctx, request)
+	}
+	if err != nil {
+		return err
+	}
+
+	return printResult(result, func() []string {
+		var lines []string
+		for _, t := range result.ResourceTemplates {
+			var uriTemplate string
+			if t.URITemplate != nil {
+				uriTemplate = t.URITemplate.Raw()
+			}
+			lines = append(lines, fmt.Sprintf("%s\t%s\t%s", uriTemplate, t.Name, t.Description))
+		}
+		return append(lines, nextCursorLine(result.NextCursor)...)
+	})
+}
+
+func listPrompts(ctx context.Context, c *mcpclient.Client, args []string) error {
+	fs, page := newListFlags("prompts list")
+	fs.Parse(args)
+
+	request := mcp.ListPromptsRequest{}
+	request.Params.Cursor = mcp.Cursor(page.cursor)
+	var result *mcp.ListPromptsResult
+	var err error
+	if page.all {
+		result, err = c.ListPrompts(ctx, request)
+	} else {
+		result, err = c.ListPromptsByPage(ctx, request)
+	}
+	if err != nil {
+		return err
+	}
+
+	return printResult(result, func() []string {
+		var lines []string
+		for _, p := range result.Prompts {
+			var arguments []string
+			for _, arg := range p.Arguments {
+				if arg.Required {
+					arguments = append(arguments, arg.Name+"*")
+				} else {
+					arguments = append(arguments, arg.Name)
+				}
+			}
+			lines = append(lines, fmt.Sprintf("%s(%s)\t%s", p.Name, strings.Join(arguments, ", "), p.Description))
+		}
+		return append(lines, nextCursorLine(result.NextCursor)...)
+	})
+}
+
+func getPrompt(ctx context.Context, c *mcpclient.Client, args []string) error {
+	if len(args) < 1 {
+		return &usageError{"usage: prompts get <name> [-args k=v,...]"}
+	}
+	arguments := keyValueFlag{}
+	fs := flag.NewFlagSet("prompts get", flag.ExitOnError)
+	fs.Var(arguments, "args", "Prompt arguments as k=v pairs, comma separated or repeated")
+	fs.Parse(args[1:])
+
+	request := mcp.GetPromptRequest{}
+	request.Params.Name = args[0]
+	request.Params.Arguments = arguments
+	result, err := c.GetPrompt(ctx, request)
+	if err != nil {
+		return err
+	}
+
+	return printResult(result, func() []string {
+		lines := []string{result.Description}
+		for _, message := range result.Messages {
+			switch content := message.Content.(type) {
+			case mcp.TextContent:
+				lines = append(lines, fmt.Sprintf("[%s] %s", message.Role, content.Text))
+			default:
+				lines = append(lines, fmt.Sprintf("[%s] <%T>", message.Role, content))
+			}
+		}
+		return lines
+	})
+}
+
+func callTool(ctx context.Context, c *mcpclient.Client, args []string) error {
+	if len(args) < 1 {
+		return &usageError{"usage: tools call <name> [-args k=v,...] [-no-prompt]"}
+	}
+	arguments := keyValueFlag{}
+	fs := flag.NewFlagSet("tools call", flag.ExitOnError)
+	fs.Var(arguments, "args", "Tool arguments as k=v pairs, comma separated or repeated")
+	noPrompt := fs.Bool("no-prompt", false, "Don't prompt for missing required arguments")
+	fs.Parse(args[1:])
+
+	toolArgs := toolArguments(arguments)
+	if !*noPrompt && isTerminal() {
+		tool, err := findTool(ctx, c, args[0])
+		if err != nil {
+			return err
+		}
+		if err := newArgumentPrompter().prompt(tool, toolArgs); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := toolCallContext(ctx)
+	defer cancel()
+	result, err := mcpclient.Call[*mcp.CallToolResult](ctx, c, args[0], toolArgs)
+	if err != nil {
+		return err
+	}
+
+	return printResult(result, func() []string {
+		return []string{mcpclient.ResultText(result)}
+	})
+}
+
+// toolArguments converts k=v pairs into arguments, values that parse as JSON
+// numbers, booleans, arrays or objects are passed as such.
+func toolArguments(pairs keyValueFlag) map[string]any {
+	arguments := make(map[string]any, len(pairs))
+	for k, v := range pairs {
+		var value any
+		if err := json.Unmarshal([]byte(v), &value); err != nil {
+			value = v
+		}
+		arguments[k] = value
+	}
+	return arguments
+}
+
+// batchResult is the JSON output of one call of a batch.
+type batchResult struct {
+	Tool       string              `json:"tool"`
+	Arguments  any                 `json:"arguments,omitempty"`
+	Result     *mcp.CallToolResult `json:"result,omitempty"`
+	Error      string              `json:"error,omitempty"`
+	DurationMs int64               `json:"durationMs"`
+}
+
+// callToolsBatch calls the tools of a JSONL file, one
+// {"tool": "...", "arguments": {...}} object per line, over a pool of
+// -parallel workers.
+func callToolsBatch(ctx context.Context, c *mcpclient.Client, args []string) error {
+	fs := flag.NewFlagSet("tools batch", flag.ExitOnError)
+	parallel := fs.Int("parallel", 4, "Number of tool calls in flight")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return &usageError{"usage: tools batch [-parallel n] <file.jsonl|->"}
+	}
+
+	input := os.Stdin
+	if fs.Arg(0) != "-" {
+		file, err := os.Open(fs.Arg(0))
+		if err != nil {
+			return &usageError{fmt.Sprintf("failed to open batch: %v", err)}
+		}
+		defer file.Close()
+		input = file
+	}
+
+	var calls []mcpclient.ToolCall
+	decoder := json.NewDecoder(input)
+	for decoder.More() {
+		var call mcpclient.ToolCall
+		if err := decoder.Decode(&call); err != nil {
+			return &usageError{fmt.Sprintf("failed to parse batch: %v", err)}
+		}
+		call.Timeout = operationTimeout
+		calls = append(calls, call)
+	}
+
+	start := time.Now()
+	results := c.CallAll(ctx, calls, *parallel)
+	elapsed := time.Since(start)
+
+	var failed int
+	var firstErr error
+	output := make([]batchResult, len(results))
+	for i, r := range results {
+		output[i] = batchResult{
+			Tool:       r.Call.Tool,
+			Arguments:  r.Call.Arguments,
+			Result:     r.Result,
+			DurationMs: r.Duration.Milliseconds(),
+		}
+		if r.Err != nil {
+			output[i].Error = r.Err.Error()
+			failed++
+			if firstErr == nil {
+				firstErr = r.Err
+			}
+		}
+	}
+
+	if jsonEnvelope && firstErr != nil {
+		// reported along with the error by exit
+		partialResult = output
+		return fmt.Errorf("%d of %d calls failed, first: %w", failed, len(calls), firstErr)
+	}
+	err := printResult(output, func() []string {
+		var lines []string
+		for _, r := range output {
+			status, text := "ok", r.Error
+			if r.Error != "" {
+				status = "error"
+			} else {
+				text = mcpclient.ResultText(r.Result)
+			}
+			lines = append(lines, fmt.Sprintf("%s\t%s\t%dms\t%s", r.Tool, status, r.DurationMs, text))
+		}
+		return append(lines, fmt.Sprintf("%d calls, %d failed in %s with %d workers", len(calls), failed, elapsed.Round(time.Millisecond), *parallel))
+	})
+	if err != nil {
+		return err
+	}
+	if firstErr != nil {
+		return fmt.Errorf("%d of %d calls failed, first: %w", failed, len(calls), firstErr)
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/wagnerjt/go-mcp/client/pkg/mcpclient"
+)
+
+// catalog is what diff compares between two servers.
+type catalog struct {
+	ServerInfo   mcp.Implementation     `json:"serverInfo"`
+	Capabilities mcp.ServerCapabilities `json:"capabilities"`
+	Tools        map[string]mcp.Tool    `json:"tools"`
+}
+
+// change is a value that differs at a JSON path, A or B is nil when the path
+// exists on one server only.
+type change struct {
+	Path string `json:"path"`
+	A    any    `json:"a,omitempty"`
+	B    any    `json:"b,omitempty"`
+}
+
+type catalogDiff struct {
+	A            string              `json:"a"`
+	B            string              `json:"b"`
+	Capabilities []change            `json:"capabilities,omitempty"`
+	OnlyInA      []string            `json:"onlyInA,omitempty"`
+	OnlyInB      []string            `json:"onlyInB,omitempty"`
+	Changed      map[string][]change `json:"changed,omitempty"`
+}
+
+func (d catalogDiff) empty() bool {
+	return len(d.Capabilities) == 0 && len(d.OnlyInA) == 0 && len(d.OnlyInB) == 0 && len(d.Changed) == 0
+}
+
+// runDiff compares the tool catalogs, schemas and capabilities of two
+// servers. A server is a URL, using the headers and auth of base, or the
+// name of a profile of -profiles.
+func runDiff(ctx context.Context, base mcpclient.Profile, recorder *notificationRecorder, args []string) error {
+	if len(args) != 2 {
+		return &usageError{"usage: diff <serverA> <serverB>"}
+	}
+
+	catalogs := make([]catalog, 2)
+	for i, server := range args {
+		p, err := diffProfile(base, server)
+		if err != nil {
+			return err
+		}
+		c, err := connect(ctx, p, recorder)
+		if err != nil {
+			return fmt.Errorf("%s: %w", server, err)
+		}
+		catalogs[i], err = fetchCatalog(ctx, c)
+		c.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", server, err)
+		}
+	}
+
+	d := diffCatalogs(catalogs[0], catalogs[1])
+	d.A, d.B = args[0], args[1]
+	if d.empty() {
+		return printResult(d, func() []string {
+			return []string{"no differences"}
+		})
+	}
+	if jsonEnvelope {
+		partialResult = d
+		return fmt.Errorf("catalogs differ")
+	}
+	if err := printResult(d, d.lines); err != nil {
+		return err
+	}
+	return fmt.Errorf("catalogs differ")
+}
+
+// diffProfile resolves server into a profile, a URL replaces the URL of base
+// and picks the transport from its path.
+func diffProfile(base mcpclient.Profile, server string) (mcpclient.Profile, error) {
+	if !strings.Contains(server, "://") {
+		if profilesFile == "" {
+			return base, &usageError{fmt.Sprintf("%s is not a URL and no -profiles is given", server)}
+		}
+		p, err := mcpclient.LoadProfile(profilesFile, server)
+		if err != nil {
+			return p, &usageError{err.Error()}
+		}
+		return p, nil
+	}
+
+	p := base
+	p.URL = server
+	p.Transport = http
+	if strings.HasSuffix(strings.TrimSuffix(server, "/"), "/sse") {
+		p.Transport = sse
+	}
+	return p, nil
+}
+
+func fetchCatalog(ctx context.Context, c *mcpclient.Client) (catalog, error) {
+	ctx, cancel := operationContext(ctx)
+	defer cancel()
+
+	cat := catalog{
+		ServerInfo:   c.ServerInfo(),
+		Capabilities: c.GetServerCapabilities(),
+		Tools:        make(map[string]mcp.Tool),
+	}
+	if cat.Capabilities.Tools == nil {
+		return cat, nil
+	}
+	result, err := c.ListTools(ctx, mcp.ListToolsRequest{})
+	if err != nil {
+		return cat, fmt.Errorf("list tools failed: %w", err)
+	}
+	for _, tool := range result.Tools {
+		cat.Tools[tool.Name] = tool
+	}
+	return cat, nil
+}
+
+func diffCatalogs(a, b catalog) catalogDiff {
+	d := catalogDiff{Changed: make(map[string][]change)}
+	d.Capabilities = diffJSON("", toJSON(a.Capabilities), toJSON(b.Capabilities))
+
+	for name, toolA := range a.Tools {
+		toolB, ok := b.Tools[name]
+		if !ok {
+			d.OnlyInA = append(d.OnlyInA, name)
+			continue
+		}
+		if changes := diffJSON("", toJSON(toolA), toJSON(toolB)); len(changes) > 0 {
+			d.Changed[name] = changes
+		}
+	}
+	for name := range b.Tools {
+		if _, ok := a.Tools[name]; !ok {
+			d.OnlyInB = append(d.OnlyInB, name)
+		}
+	}
+	sort.Strings(d.OnlyInA)
+	sort.Strings(d.OnlyInB)
+	return d
+}
+
+// toJSON converts v into its generic JSON form, so that the diff follows the
+// JSON names and what is sent on the wire.
+func toJSON(v any) any {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var out any
+	json.Unmarshal(data, &out)
+	return out
+}
+
+// diffJSON lists the paths where a and b differ, descending into objects.
+func diffJSON(path string, a, b any) []change {
+	objectA, okA := a.(map[string]any)
+	objectB, okB := b.(map[string]any)
+	if !okA || !okB {
+		if reflect.DeepEqual(a, b) {
+			return nil
+		}
+		return []change{{Path: path, A: a, B: b}}
+	}
+
+	keys := make(map[string]bool, len(objectA)+len(objectB))
+	for k := range objectA {
+		keys[k] = true
+	}
+	for k := range objectB {
+		keys[k] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	var changes []change
+	for _, k := range sorted {
+		changes = append(changes, diffJSON(joinPath(path, k), objectA[k], objectB[k])...)
+	}
+	return changes
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func (d catalogDiff) lines() []string {
+	lines := []string{"--- " + d.A, "+++ " + d.B}
+	for _, c := range d.Capabilities {
+		lines = append(lines, c.line("capabilities"))
+	}
+	for _, name := range d.OnlyInA {
+		lines = append(lines, "- tool "+name)
+	}
+	for _, name := range d.OnlyInB {
+		lines = append(lines, "+ tool "+name)
+	}
+
+	names := make([]string, 0, len(d.Changed))
+	for name := range d.Changed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		lines = append(lines, "~ tool "+name)
+		for _, c := range d.Changed[name] {
+			lines = append(lines, "  "+c.line(""))
+		}
+	}
+	return lines
+}
+
+func (c change) line(prefix string) string {
+	path := joinPath(prefix, c.Path)
+	switch {
+	case c.A == nil:
+		return fmt.Sprintf("+ %s: %s", path, compact(c.B))
+	case c.B == nil:
+		return fmt.Sprintf("- %s: %s", path, compact(c.A))
+	default:
+		return fmt.Sprintf("~ %s: %s -> %s", path, compact(c.A), compact(c.B))
+	}
+}
+
+func compact(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+
+	"github.com/wagnerjt/go-mcp/client/pkg/mcpclient"
+)
+
+// Exit codes are stable so scripts can branch on them.
+const (
+	exitOK          int = 0
+	exitFailure     int = 1
+	exitUsage       int = 2
+	exitAuth        int = 3
+	exitTransport   int = 4
+	exitToolError   int = 5
+	exitTimeout     int = 6
+	exitInterrupted int = 130
+)
+
+var errorKinds = map[int]string{
+	exitFailure:     "failure",
+	exitUsage:       "usage",
+	exitAuth:        "auth",
+	exitTransport:   "transport",
+	exitToolError:   "tool_error",
+	exitTimeout:     "timeout",
+	exitInterrupted: "interrupted",
+}
+
+var jsonEnvelope bool
+
+// partialResult is reported in the error envelope of commands that failed
+// part way, i.e. a batch with failed calls.
+var partialResult any
+
+// envelope wraps every result and error printed in -json mode.
+type envelope struct {
+	OK      bool           `json:"ok"`
+	Command string         `json:"command"`
+	Result  any            `json:"result,omitempty"`
+	Error   *envelopeError `json:"error,omitempty"`
+}
+
+type envelopeError struct {
+	Kind     string `json:"kind"`
+	Message  string `json:"message"`
+	ExitCode int    `json:"exitCode"`
+}
+
+// usageError is returned for bad commands and arguments.
+type usageError struct {
+	message string
+}
+
+func (e *usageError) Error() string {
+	return e.message
+}
+
+// exitCode maps err to one of the exit codes.
+func exitCode(err error) int {
+	var usage *usageError
+	var tool *mcpclient.ToolError
+	var auth *mcpclient.AuthError
+	var transport *mcpclient.TransportError
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &usage):
+		return exitUsage
+	case errors.As(err, &tool):
+		return exitToolError
+	case errors.Is(err, context.DeadlineExceeded):
+		return exitTimeout
+	case errors.Is(err, context.Canceled):
+		return exitInterrupted
+	case errors.As(err, &auth):
+		return exitAuth
+	case errors.As(err, &transport):
+		return exitTransport
+	default:
+		return exitFailure
+	}
+}
+
+// exit reports the outcome of command and exits with its exit code.
+func exit(command string, err error) {
+	code := exitCode(err)
+	if err != nil {
+		if jsonEnvelope {
+			writeEnvelope(envelope{
+				Command: command,
+				Result:  partialResult,
+				Error: &envelopeError{
+					Kind:     errorKinds[code],
+					Message:  err.Error(),
+					ExitCode: code,
+				},
+			})
+		} else {
+			log.Printf("%s failed: %v", command, err)
+		}
+	}
+	os.Exit(code)
+}
+
+func writeEnvelope(e envelope) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(e)
+}
@@ -2,10 +2,16 @@ module github.com/wagnerjt/go-mcp/client
 
 go 1.24.1
 
-require github.com/mark3labs/mcp-go v0.32.0
+require (
+	github.com/mark3labs/mcp-go v0.32.0
+	github.com/wagnerjt/go-mcp/shared v0.0.0
+)
 
 require (
 	github.com/google/uuid v1.6.0 // indirect
 	github.com/spf13/cast v1.7.1 // indirect
 	github.com/yosida95/uritemplate/v3 v3.0.2 // indirect
 )
+
+// the build info is shared with the servers
+replace github.com/wagnerjt/go-mcp/shared => ../shared
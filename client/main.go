@@ -3,91 +3,204 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
-	"github.com/mark3labs/mcp-go/client"
-	"github.com/mark3labs/mcp-go/client/transport"
 	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/wagnerjt/go-mcp/client/pkg/mcpclient"
+	"github.com/wagnerjt/go-mcp/shared/pkg/buildinfo"
 )
 
 const mocked_key string = "sk-12345"
-const sse string = "sse"
-const http string = "http"
+const sse string = mcpclient.TransportSSE
+const http string = mcpclient.TransportHTTP
 
 var mcpUri string
 var mcpTransport string
+var outputFormat string
+var notificationMethods string
+var eventsLog string
+var profilesFile string
+var profileName string
+var verbose bool
+var proxyURL string
+var caCert string
+var insecure bool
+var resourceCacheDir string
+var version bool
+
+// defaultProfile is used without -profiles, it sends the mocked key.
+var defaultProfile = mcpclient.Profile{
+	Auth: &mcpclient.AuthConfig{Type: mcpclient.AuthBearer, Token: mocked_key},
+}
 
-func genHeaders() map[string]string {
-	// Set the Authorization header with the mocked key
-	return map[string]string{
-		"Authorization": "Bearer " + mocked_key,
-	}
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), `Usage: %s [flags] [command]
+
+Without a command the client runs a smoke test against the server.
+
+Commands:
+  diff <serverA> <serverB>            compare the tool catalogs of two servers, by URL or profile
+  auth check [-token t] [url]         validate the authorization of a streamable HTTP endpoint
+                                      against the MCP authorization spec, defaults to -mcpUri
+  tools call <name> [-args k=v,...]   call a tool, values are parsed as JSON when valid,
+                                      prompts for missing required arguments on a terminal
+  tools batch [-parallel n] <file>    call the tools of a JSONL file, - for stdin, concurrently
+  resources list [-cursor c] [-all]   list resources
+  resources read <uri>                read a resource
+  templates list [-cursor c] [-all]   list resource templates
+  prompts list [-cursor c] [-all]     list prompts
+  prompts get <name> [-args k=v,...]  get a prompt with arguments
+
+Flags:
+`, os.Args[0])
+	flag.PrintDefaults()
 }
 
 // pulled from https://github.com/mark3labs/mcp-go/blob/main/client/sse_test.go
 func main() {
 	flag.StringVar(&mcpTransport, "t", sse, "Transport to use for MCP client (sse, http)")
 	flag.StringVar(&mcpUri, "mcpUri", "http://localhost:8080/sse", "Fully qualified mcpUri to connect to including port i.e. http://localhost:8080/sse")
+	flag.StringVar(&outputFormat, "o", formatText, "Output format of commands (text, json)")
+	flag.BoolVar(&jsonEnvelope, "json", false, "Wrap results and errors in a JSON envelope on stdout")
+	flag.StringVar(&notificationMethods, "notifications", "", "Comma separated notification methods to handle, defaults to all")
+	flag.StringVar(&eventsLog, "events-log", "", "Append the handled notifications to this JSONL file")
+	flag.StringVar(&profilesFile, "profiles", "", "JSON file of header and auth profiles, defaults to the mocked bearer key")
+	flag.StringVar(&profileName, "profile", "default", "Profile of -profiles to use")
+	flag.BoolVar(&verbose, "v", false, "Verbose logging, credentials are redacted")
+	flag.StringVar(&proxyURL, "proxy", "", "Proxy URL, defaults to HTTP_PROXY/HTTPS_PROXY")
+	flag.StringVar(&caCert, "ca-cert", "", "PEM file of additional CA certificates to trust")
+	flag.BoolVar(&insecure, "insecure", false, "Skip TLS certificate verification, i.e. for self-signed dev servers")
+	flag.StringVar(&resourceCacheDir, "resource-cache", "", "Directory caching read resources, which are then only downloaded again when modified")
+	flag.DurationVar(&operationTimeout, "timeout", 5*time.Second, "Timeout of each request, i.e. a tool call or a list")
+	flag.DurationVar(&initTimeout, "init-timeout", 5*time.Second, "Timeout of the initialize handshake")
+	flag.DurationVar(&cancelAfter, "cancel-after", 0, "Cancel tool calls after this long, sending notifications/cancelled")
+	flag.BoolVar(&version, "version", false, "Print the version, commit and build date and exit")
+	flag.Usage = usage
 	flag.Parse()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	var c *client.Client
-	var err error
+	if version {
+		fmt.Println(buildinfo.Get())
+		return
+	}
 
-	headers := genHeaders()
+	if jsonEnvelope {
+		outputFormat = formatJSON
+	}
 
-	if mcpTransport == sse {
-		log.Printf("Using SSE transport")
-		// Create MCP client using SSE transport with headers
-		c, err = client.NewSSEMCPClient(mcpUri, transport.ClientOption(transport.WithHeaders(headers)))
-	} else if mcpTransport == http {
-		log.Printf("Using HTTP transport")
-		// Create MCP client using HTTP transport with headers
-		c, err = client.NewStreamableHttpClient(mcpUri, transport.StreamableHTTPCOption(transport.WithHTTPHeaders(headers)))
-	} else {
-		log.Fatalf("Unsupported transport type: %s", mcpTransport)
-		panic("Unsupported transport type")
+	commandName = "smoke test"
+	if flag.Arg(0) == "diff" {
+		commandName = "diff"
+	} else if flag.NArg() > 0 {
+		commandName = strings.Join(flag.Args()[:min(2, flag.NArg())], " ")
 	}
+	exit(commandName, run())
+}
+
+func run() error {
+	// Ctrl-C cancels the in-flight request, which notifies the server
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	// c, err := createClient(ctx, mcpUri, mcpTransport, true)
+	recorder, err := newNotificationRecorder(notificationMethods, eventsLog)
 	if err != nil {
-		log.Fatalf("Error creating client: %v\n", err)
+		return fmt.Errorf("error opening events log: %w", err)
+	}
+	defer recorder.Close()
 
+	p, err := selectedProfile()
+	if err != nil {
+		return err
 	}
-	// Start the client
-	if err := c.Start(ctx); err != nil {
-		log.Fatalf("Error starting client: %v", err)
+	if flag.Arg(0) == "diff" {
+		return runDiff(ctx, p, recorder, flag.Args()[1:])
+	}
+	if flag.Arg(0) == "auth" && flag.Arg(1) == "check" {
+		return runAuthCheck(ctx, p, flag.Args()[2:])
+	}
+
+	c, err := connect(ctx, p, recorder)
+	if err != nil {
+		return err
 	}
 	defer c.Close()
 
-	// Set up notification handler
-	c.OnNotification(func(notification mcp.JSONRPCNotification) {
-		log.Printf("Received notification: %s\n", notification.Method)
+	if flag.NArg() > 0 {
+		return runCommand(ctx, c, flag.Args())
+	}
+	return runSmokeTest(ctx, c)
+}
+
+// selectedProfile returns the profile of -profile, with -mcpUri and -t
+// applied.
+func selectedProfile() (mcpclient.Profile, error) {
+	p := defaultProfile
+	if profilesFile != "" {
+		var err error
+		if p, err = mcpclient.LoadProfile(profilesFile, profileName); err != nil {
+			return p, &usageError{fmt.Sprintf("error loading profile: %v", err)}
+		}
+	}
+	// the flags override the profile when set
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "mcpUri":
+			p.URL = mcpUri
+		case "t":
+			p.Transport = mcpTransport
+		}
 	})
+	if p.URL == "" {
+		p.URL = mcpUri
+	}
+	if p.Transport == "" {
+		p.Transport = mcpTransport
+	}
+	return p, nil
+}
 
-	// init request
-	initRequest := mcp.InitializeRequest{}
-	initRequest.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
-	initRequest.Params.ClientInfo = mcp.Implementation{
-		Name:    "go-client",
-		Version: "0.0.1",
+// connect creates the client for the profile and initializes the session.
+func connect(ctx context.Context, p mcpclient.Profile, recorder *notificationRecorder) (*mcpclient.Client, error) {
+	headers, secret, err := p.RenderHeaders()
+	if err != nil {
+		return nil, &usageError{fmt.Sprintf("error rendering headers of profile %s: %v", profileName, err)}
+	}
+	if verbose {
+		log.Printf("Request headers: %s", mcpclient.RedactHeaders(headers, secret))
+	}
+	if insecure {
+		log.Printf("TLS certificate verification is disabled")
 	}
-	initRequest.Params.Capabilities = mcp.ClientCapabilities{}
 
-	log.Println("Initializing client...")
-	result, err := c.Initialize(ctx, initRequest)
+	log.Printf("Connecting to %s over %s", p.URL, p.Transport)
+	c, err := mcpclient.Connect(ctx, p,
+		mcpclient.WithProxy(proxyURL),
+		mcpclient.WithCACert(caCert),
+		mcpclient.WithInsecure(insecure),
+		mcpclient.WithResourceCache(resourceCacheDir),
+		mcpclient.WithInitTimeout(initTimeout),
+		mcpclient.WithNotificationHandler(recorder.handle),
+	)
 	if err != nil {
-		log.Fatalf("Failed to initialize: %v", err)
+		return nil, err
 	}
+	recorder.attach(c)
 
-	log.Printf("Connected to server with name %s", result.ServerInfo.Name)
+	log.Printf("Connected to server with name %s version %s", c.ServerInfo().Name, c.ServerInfo().Version)
+	return c, nil
+}
 
+func runSmokeTest(ctx context.Context, c *mcpclient.Client) error {
 	// Test Ping
-	if err := c.Ping(ctx); err != nil {
-		log.Fatalf("Ping failed: %v", err)
+	pingCtx, cancel := operationContext(ctx)
+	defer cancel()
+	if err := c.Ping(pingCtx); err != nil {
+		return fmt.Errorf("ping failed: %w", err)
 	}
 
 	log.Printf("Ping successful")
@@ -96,9 +209,11 @@ func main() {
 	toolsRequest := mcp.ListToolsRequest{}
 
 	// toolsResponse = mcp.ListToolsResponse{}
-	toolsResponse, err := c.ListTools(ctx, toolsRequest)
+	listCtx, cancel := operationContext(ctx)
+	defer cancel()
+	toolsResponse, err := c.ListTools(listCtx, toolsRequest)
 	if err != nil {
-		log.Fatalf("ListTools failed: %v", err)
+		return fmt.Errorf("list tools failed: %w", err)
 	}
 
 	log.Printf("Found %d tools", len(toolsResponse.Tools))
@@ -108,67 +223,79 @@ func main() {
 	}
 
 	// callToolGoServer(ctx, c)
-	callToolLiteLLMServer(ctx, c)
-	callAuthTool(ctx, c)
+	if err := callToolLiteLLMServer(ctx, c); err != nil {
+		return err
+	}
+	if err := callAuthTool(ctx, c); err != nil {
+		return err
+	}
+
+	if jsonEnvelope {
+		return writeEnvelope(envelope{OK: true, Command: commandName, Result: toolsResponse})
+	}
+	return nil
 }
 
-func callAuthTool(ctx context.Context, c *client.Client) {
+func callAuthTool(ctx context.Context, c *mcpclient.Client) error {
 	log.Printf("Calling add tool")
 
-	request := mcp.CallToolRequest{}
-	request.Params.Name = "check_auth"
-	request.Params.Arguments = map[string]interface{}{
+	arguments := map[string]interface{}{
 		"message": "Hello, this is a test message for authentication",
 	}
 
-	result, err := c.CallTool(ctx, request)
-	if err != nil || result.IsError {
-		log.Fatalf("CallTool failed: %v", err)
+	ctx, cancel := toolCallContext(ctx)
+	defer cancel()
+	result, err := mcpclient.Call[*mcp.CallToolResult](ctx, c, "check_auth", arguments)
+	if err != nil {
+		return fmt.Errorf("call tool failed: %w", err)
 	}
 
 	if len(result.Content) != 1 {
-		log.Fatalf("Expected 1 content item, got %d", len(result.Content))
+		return fmt.Errorf("expected 1 content item, got %d", len(result.Content))
 	}
 	log.Printf("Result: %s", result.Content[0].(mcp.TextContent).Text)
+	return nil
 }
 
-func callToolGoServer(ctx context.Context, c *client.Client) {
+func callToolGoServer(ctx context.Context, c *mcpclient.Client) error {
 	log.Printf("Calling add tool")
 
-	request := mcp.CallToolRequest{}
-	request.Params.Name = "add"
-	request.Params.Arguments = map[string]interface{}{
+	arguments := map[string]interface{}{
 		"a": 1.50,
 		"b": 5,
 	}
 
-	result, err := c.CallTool(ctx, request)
-	if err != nil || result.IsError {
-		log.Fatalf("CallTool failed: %v", err)
+	ctx, cancel := toolCallContext(ctx)
+	defer cancel()
+	result, err := mcpclient.Call[*mcp.CallToolResult](ctx, c, "add", arguments)
+	if err != nil {
+		return fmt.Errorf("call tool failed: %w", err)
 	}
 
 	if len(result.Content) != 1 {
-		log.Fatalf("Expected 1 content item, got %d", len(result.Content))
+		return fmt.Errorf("expected 1 content item, got %d", len(result.Content))
 	}
 	log.Printf("Result: %s", result.Content[0].(mcp.TextContent).Text)
+	return nil
 }
 
-func callToolLiteLLMServer(ctx context.Context, c *client.Client) {
+func callToolLiteLLMServer(ctx context.Context, c *mcpclient.Client) error {
 	log.Printf("Calling get_current_time tool")
 
-	request := mcp.CallToolRequest{}
-	request.Params.Name = "get_current_time"
-	request.Params.Arguments = map[string]interface{}{
+	arguments := map[string]interface{}{
 		"format": "short",
 	}
 
-	result, err := c.CallTool(ctx, request)
-	if err != nil || result.IsError {
-		log.Fatalf("CallTool failed: %v", err)
+	ctx, cancel := toolCallContext(ctx)
+	defer cancel()
+	result, err := mcpclient.Call[*mcp.CallToolResult](ctx, c, "get_current_time", arguments)
+	if err != nil {
+		return fmt.Errorf("call tool failed: %w", err)
 	}
 
 	if len(result.Content) != 1 {
-		log.Fatalf("Expected 1 content item, got %d", len(result.Content))
+		return fmt.Errorf("expected 1 content item, got %d", len(result.Content))
 	}
 	log.Printf("Result: %s", result.Content[0].(mcp.TextContent).Text)
+	return nil
 }
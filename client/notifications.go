@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/wagnerjt/go-mcp/client/pkg/mcpclient"
+)
+
+// notificationEvent is one line of the JSONL event log.
+type notificationEvent struct {
+	Time      time.Time              `json:"time"`
+	SessionID string                 `json:"sessionId,omitempty"`
+	Method    string                 `json:"method"`
+	Params    mcp.NotificationParams `json:"params"`
+}
+
+// notificationRecorder filters the received notifications by method and
+// appends the accepted ones to an optional JSONL event log.
+type notificationRecorder struct {
+	methods map[string]bool
+
+	mu      sync.Mutex
+	client  *mcpclient.Client
+	file    *os.File
+	encoder *json.Encoder
+}
+
+// newNotificationRecorder accepts the comma separated methods, or every
+// method when empty, and appends them to logPath when set.
+func newNotificationRecorder(methods, logPath string) (*notificationRecorder, error) {
+	r := &notificationRecorder{}
+	for _, method := range strings.Split(methods, ",") {
+		if method = strings.TrimSpace(method); method != "" {
+			if r.methods == nil {
+				r.methods = make(map[string]bool)
+			}
+			r.methods[method] = true
+		}
+	}
+
+	if logPath != "" {
+		file, err := os.OpenFile(logPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		r.file = file
+		r.encoder = json.NewEncoder(file)
+	}
+	return r, nil
+}
+
+// attach sets the client whose session ID is logged with the events, the
+// recorder handles notifications through mcpclient.WithNotificationHandler.
+func (r *notificationRecorder) attach(c *mcpclient.Client) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.client = c
+}
+
+func (r *notificationRecorder) handle(notification mcp.JSONRPCNotification) {
+	if r.methods != nil && !r.methods[notification.Method] {
+		return
+	}
+	log.Printf("Received notification: %s\n", notification.Method)
+	if r.encoder == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	event := notificationEvent{
+		Time:      time.Now().UTC(),
+		SessionID: r.client.SessionID(),
+		Method:    notification.Method,
+		Params:    notification.Params,
+	}
+	if err := r.encoder.Encode(event); err != nil {
+		log.Printf("Failed to write notification event: %v", err)
+	}
+}
+
+func (r *notificationRecorder) Close() error {
+	if r.file == nil {
+		return nil
+	}
+	return r.file.Close()
+}
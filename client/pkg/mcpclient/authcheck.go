@@ -0,0 +1,264 @@
+package mcpclient
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// AuthCheckResult is one item of the authorization compliance report.
+type AuthCheckResult struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// authCheckTimeout bounds each request of CheckAuthorization.
+const authCheckTimeout = 10 * time.Second
+
+const authCheckInitialize = `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26","capabilities":{},"clientInfo":{"name":"auth-check","version":"0.0.1"}}}`
+
+var resourceMetadataParam = regexp.MustCompile(`resource_metadata="([^"]+)"`)
+
+// CheckAuthorization validates a protected streamable HTTP endpoint against
+// the MCP authorization spec: the 401 challenge, the protected resource and
+// authorization server metadata, PKCE support and which tokens it accepts.
+// The token checks need a valid token, they are skipped without one. The
+// options of Connect configure the proxy and TLS.
+func CheckAuthorization(ctx context.Context, endpoint, token string, opts ...Option) []AuthCheckResult {
+	c := &Client{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	transport, err := c.httpTransport()
+	if err != nil {
+		return []AuthCheckResult{{Name: "config", Detail: err.Error()}}
+	}
+	check := &authChecker{ctx: ctx, endpoint: endpoint, client: &http.Client{Transport: transport}}
+
+	var results []AuthCheckResult
+	metadataURL, result := check.challenge()
+	results = append(results, result)
+	authServer, result := check.resourceMetadata(metadataURL)
+	results = append(results, result)
+	results = append(results, check.authServerMetadata(authServer)...)
+	results = append(results, check.tokens(token)...)
+	return results
+}
+
+type authChecker struct {
+	ctx      context.Context
+	endpoint string
+	client   *http.Client
+}
+
+// post sends an initialize request, authorized with header when set.
+func (a *authChecker) post(endpoint, header string) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(a.ctx, authCheckTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(authCheckInitialize))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	if header != "" {
+		req.Header.Set("Authorization", header)
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+	resp.Body.Close()
+	return resp, nil
+}
+
+// getJSON fetches a metadata document into v.
+func (a *authChecker) getJSON(target string, v any) error {
+	ctx, cancel := context.WithTimeout(a.ctx, authCheckTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: status code %d", target, resp.StatusCode)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v); err != nil {
+		return fmt.Errorf("GET %s: %w", target, err)
+	}
+	return nil
+}
+
+// wellKnown is path on the origin of target.
+func wellKnown(target, path string) string {
+	u, err := url.Parse(target)
+	if err != nil {
+		return path
+	}
+	return (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: path}).String()
+}
+
+// challenge expects a 401 with a Bearer challenge for requests without a
+// token and returns the resource metadata URL it points to.
+func (a *authChecker) challenge() (string, AuthCheckResult) {
+	result := AuthCheckResult{Name: "401 challenge"}
+	fallback := wellKnown(a.endpoint, "/.well-known/oauth-protected-resource")
+	resp, err := a.post(a.endpoint, "")
+	if err != nil {
+		result.Detail = err.Error()
+		return fallback, result
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		result.Detail = fmt.Sprintf("unauthenticated request answered with status code %d, want 401", resp.StatusCode)
+		return fallback, result
+	}
+	challenge := resp.Header.Get("WWW-Authenticate")
+	if !strings.HasPrefix(strings.ToLower(challenge), "bearer") {
+		result.Detail = fmt.Sprintf("WWW-Authenticate %q is not a Bearer challenge", challenge)
+		return fallback, result
+	}
+	result.OK = true
+	if match := resourceMetadataParam.FindStringSubmatch(challenge); match != nil {
+		return match[1], result
+	}
+	result.Detail = "no resource_metadata in the challenge, using " + fallback
+	return fallback, result
+}
+
+// resourceMetadata validates the RFC 9728 protected resource metadata and
+// returns its first authorization server.
+func (a *authChecker) resourceMetadata(target string) (string, AuthCheckResult) {
+	result := AuthCheckResult{Name: "protected resource metadata"}
+	var metadata struct {
+		Resource             string   `json:"resource"`
+		AuthorizationServers []string `json:"authorization_servers"`
+	}
+	if err := a.getJSON(target, &metadata); err != nil {
+		result.Detail = err.Error()
+		return "", result
+	}
+	switch {
+	case metadata.Resource == "":
+		result.Detail = "resource is missing"
+	case len(metadata.AuthorizationServers) == 0:
+		result.Detail = "authorization_servers is empty"
+	default:
+		result.OK = true
+		return metadata.AuthorizationServers[0], result
+	}
+	return "", result
+}
+
+// authServerMetadata validates the RFC 8414 authorization server metadata,
+// served on the origin of the MCP server or else of the authorization
+// server, and that it supports PKCE with S256.
+func (a *authChecker) authServerMetadata(authServer string) []AuthCheckResult {
+	result := AuthCheckResult{Name: "authorization server metadata"}
+	candidates := []string{wellKnown(a.endpoint, "/.well-known/oauth-authorization-server")}
+	if authServer != "" {
+		candidates = append(candidates,
+			wellKnown(authServer, "/.well-known/oauth-authorization-server"),
+			wellKnown(authServer, "/.well-known/openid-configuration"),
+		)
+	}
+	var metadata struct {
+		AuthorizationEndpoint         string   `json:"authorization_endpoint"`
+		TokenEndpoint                 string   `json:"token_endpoint"`
+		ResponseTypesSupported        []string `json:"response_types_supported"`
+		CodeChallengeMethodsSupported []string `json:"code_challenge_methods_supported"`
+	}
+	var errs []string
+	found := ""
+	for _, candidate := range slices.Compact(candidates) {
+		if err := a.getJSON(candidate, &metadata); err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		found = candidate
+		break
+	}
+
+	pkce := AuthCheckResult{Name: "PKCE S256"}
+	switch {
+	case found == "":
+		result.Detail = strings.Join(errs, "; ")
+		pkce.Detail = "no authorization server metadata"
+		return []AuthCheckResult{result, pkce}
+	case metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "":
+		result.Detail = found + ": authorization_endpoint or token_endpoint is missing"
+	case len(metadata.ResponseTypesSupported) > 0 && !slices.Contains(metadata.ResponseTypesSupported, "code"):
+		result.Detail = found + ": response type code is not supported"
+	default:
+		result.OK = true
+		result.Detail = found
+	}
+	if slices.Contains(metadata.CodeChallengeMethodsSupported, "S256") {
+		pkce.OK = true
+	} else {
+		pkce.Detail = fmt.Sprintf("code_challenge_methods_supported is %v, clients must refuse servers without S256", metadata.CodeChallengeMethodsSupported)
+	}
+	return []AuthCheckResult{result, pkce}
+}
+
+// tokens expects invalid tokens and tokens in the query string to be
+// rejected and token to be accepted.
+func (a *authChecker) tokens(token string) []AuthCheckResult {
+	invalid := AuthCheckResult{Name: "invalid token rejected"}
+	b := make([]byte, 16)
+	rand.Read(b)
+	if resp, err := a.post(a.endpoint, "Bearer invalid-"+hex.EncodeToString(b)); err != nil {
+		invalid.Detail = err.Error()
+	} else if resp.StatusCode != http.StatusUnauthorized {
+		invalid.Detail = fmt.Sprintf("status code %d, want 401", resp.StatusCode)
+	} else {
+		invalid.OK = true
+	}
+
+	query := AuthCheckResult{Name: "query token rejected"}
+	valid := AuthCheckResult{Name: "valid token accepted"}
+	if token == "" {
+		query.OK, query.Detail = true, "skipped, no token given"
+		valid.OK, valid.Detail = true, "skipped, no token given"
+		return []AuthCheckResult{invalid, query, valid}
+	}
+
+	if u, err := url.Parse(a.endpoint); err != nil {
+		query.Detail = err.Error()
+	} else {
+		values := u.Query()
+		values.Set("access_token", token)
+		u.RawQuery = values.Encode()
+		if resp, err := a.post(u.String(), ""); err != nil {
+			query.Detail = err.Error()
+		} else if resp.StatusCode != http.StatusUnauthorized {
+			query.Detail = fmt.Sprintf("status code %d, tokens must only be accepted in the Authorization header", resp.StatusCode)
+		} else {
+			query.OK = true
+		}
+	}
+
+	if resp, err := a.post(a.endpoint, "Bearer "+token); err != nil {
+		valid.Detail = err.Error()
+	} else if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		valid.Detail = fmt.Sprintf("status code %d", resp.StatusCode)
+	} else {
+		valid.OK = true
+	}
+	return []AuthCheckResult{invalid, query, valid}
+}
@@ -0,0 +1,106 @@
+package mcpclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const authServerMetadata = `{
+	"issuer": "https://accounts.spotify.com",
+	"authorization_endpoint": "https://accounts.spotify.com/authorize",
+	"token_endpoint": "https://accounts.spotify.com/api/token",
+	"response_types_supported": ["code"],
+	"code_challenge_methods_supported": ["S256"]
+}`
+
+// protectedServer fakes an MCP server protected by OAuth: it serves the
+// protected resource and authorization server metadata, and answers
+// requests to /mcp without an accepted bearer token with the 401 challenge.
+// With an empty token it accepts any bearer token, as servers leaving the
+// validation to a gateway do. The checks run against the real Spotify
+// server in the tests of spotifyserver.
+func protectedServer(t *testing.T, token, metadata string) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/oauth-protected-resource", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"resource":"http://` + r.Host + `/mcp","authorization_servers":["https://accounts.spotify.com"]}`))
+	})
+	mux.HandleFunc("GET /.well-known/oauth-authorization-server", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(metadata))
+	})
+	mux.HandleFunc("POST /mcp", func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if auth == "" || (token != "" && auth != "Bearer "+token) {
+			challenge := `Bearer resource_metadata="http://` + r.Host + `/.well-known/oauth-protected-resource"`
+			if auth != "" {
+				challenge += `, error="invalid_token"`
+			}
+			w.Header().Set("WWW-Authenticate", challenge)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"protocolVersion":"2025-03-26","capabilities":{},"serverInfo":{"name":"fake","version":"1"}}}`))
+	})
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestCheckAuthorization(t *testing.T) {
+	const token = "valid-token"
+	tests := []struct {
+		name        string
+		serverToken string
+		metadata    string
+		token       string
+		failing     []string
+	}{
+		{
+			name:     "any token accepted",
+			metadata: authServerMetadata,
+			token:    token,
+			failing:  []string{"invalid token rejected"},
+		},
+		{
+			name:        "validating auth",
+			serverToken: token,
+			metadata:    authServerMetadata,
+			token:       token,
+		},
+		{
+			name:        "without token",
+			serverToken: token,
+			metadata:    authServerMetadata,
+		},
+		{
+			name:     "without PKCE",
+			metadata: `{"authorization_endpoint":"https://accounts.spotify.com/authorize","token_endpoint":"https://accounts.spotify.com/api/token"}`,
+			token:    token,
+			failing:  []string{"PKCE S256", "invalid token rejected"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := protectedServer(t, tt.serverToken, tt.metadata)
+
+			failing := make(map[string]bool)
+			for _, name := range tt.failing {
+				failing[name] = true
+			}
+			results := CheckAuthorization(context.Background(), ts.URL+"/mcp", tt.token)
+			if len(results) != 7 {
+				t.Errorf("got %d results, want 7: %+v", len(results), results)
+			}
+			for _, result := range results {
+				if result.OK == failing[result.Name] {
+					t.Errorf("%s: ok %v, want %v (%s)", result.Name, result.OK, !failing[result.Name], result.Detail)
+				}
+			}
+		})
+	}
+}
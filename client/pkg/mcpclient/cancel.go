@@ -0,0 +1,59 @@
+package mcpclient
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// cancellingTransport tells the server about requests given up on the client
+// side by sending notifications/cancelled, whether they timed out or their
+// context was cancelled. It also types the transport errors.
+type cancellingTransport struct {
+	transport.Interface
+}
+
+func (t *cancellingTransport) SendRequest(ctx context.Context, request transport.JSONRPCRequest) (*transport.JSONRPCResponse, error) {
+	response, err := t.Interface.SendRequest(ctx, request)
+	if err == nil {
+		return response, nil
+	}
+	// the spec forbids cancelling initialize
+	if ctx.Err() != nil && request.Method != "initialize" {
+		t.sendCancelled(request.ID, ctx.Err())
+	}
+	return nil, classify(err)
+}
+
+func (t *cancellingTransport) sendCancelled(id mcp.RequestId, cause error) {
+	reason := "cancelled by the client"
+	if errors.Is(cause, context.DeadlineExceeded) {
+		reason = "timed out on the client"
+	}
+
+	notification := mcp.JSONRPCNotification{
+		JSONRPC: mcp.JSONRPC_VERSION,
+		Notification: mcp.Notification{
+			Method: "notifications/cancelled",
+			Params: mcp.NotificationParams{
+				AdditionalFields: map[string]any{
+					"requestId": id,
+					"reason":    reason,
+				},
+			},
+		},
+	}
+
+	// the request context is done, give the notification its own deadline
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := t.Interface.SendNotification(ctx, notification); err != nil {
+		log.Printf("Failed to send cancellation: %v", err)
+		return
+	}
+	log.Printf("Cancelled request %v: %s", id.Value(), reason)
+}
@@ -0,0 +1,177 @@
+package mcpclient
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	// ChunkNotification carries a chunk of a streamed tool result.
+	ChunkNotification = "notifications/tools/chunk"
+	// chunkedMetaKey asks for chunked results in the _meta of calls and
+	// holds the number of chunks in the _meta of streamed results.
+	chunkedMetaKey = "chunked"
+)
+
+// chunkAssembler collects the chunks of the streamed results by the progress
+// token of their call.
+type chunkAssembler struct {
+	next    atomic.Int64
+	mu      sync.Mutex
+	pending map[string]*chunkedResult
+}
+
+type chunkedResult struct {
+	chunks  map[int]resultChunk
+	arrived chan struct{}
+}
+
+type resultChunk struct {
+	item    int
+	content mcp.Content
+}
+
+func (a *chunkAssembler) register() string {
+	token := fmt.Sprintf("chunks-%d", a.next.Add(1))
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.pending == nil {
+		a.pending = make(map[string]*chunkedResult)
+	}
+	a.pending[token] = &chunkedResult{chunks: make(map[int]resultChunk), arrived: make(chan struct{}, 1)}
+	return token
+}
+
+func (a *chunkAssembler) release(token string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.pending, token)
+}
+
+func (a *chunkAssembler) handle(notification mcp.JSONRPCNotification) {
+	if notification.Method != ChunkNotification {
+		return
+	}
+	params := notification.Params.AdditionalFields
+	token := fmt.Sprint(params["progressToken"])
+	index, okIndex := params["index"].(float64)
+	item, okItem := params["item"].(float64)
+	contentMap, okContent := params["content"].(map[string]any)
+	if !okIndex || !okItem || !okContent {
+		return
+	}
+	content, err := mcp.ParseContent(contentMap)
+	if err != nil {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	pending, ok := a.pending[token]
+	if !ok {
+		return
+	}
+	pending.chunks[int(index)] = resultChunk{item: int(item), content: content}
+	select {
+	case pending.arrived <- struct{}{}:
+	default:
+	}
+}
+
+// wait blocks until count chunks of token arrived and reassembles them.
+func (a *chunkAssembler) wait(ctx context.Context, token string, count int) ([]mcp.Content, error) {
+	a.mu.Lock()
+	pending := a.pending[token]
+	a.mu.Unlock()
+	for {
+		a.mu.Lock()
+		received := len(pending.chunks)
+		a.mu.Unlock()
+		if received >= count {
+			break
+		}
+		select {
+		case <-pending.arrived:
+		case <-ctx.Done():
+			return nil, fmt.Errorf("received %d of %d result chunks: %w", received, count, ctx.Err())
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	indexes := make([]int, 0, len(pending.chunks))
+	for index := range pending.chunks {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	var content []mcp.Content
+	var text strings.Builder
+	currentItem, textItem := -1, false
+	flush := func() {
+		if textItem {
+			content = append(content, mcp.NewTextContent(text.String()))
+			text.Reset()
+			textItem = false
+		}
+	}
+	for _, index := range indexes {
+		chunk := pending.chunks[index]
+		if chunk.item != currentItem {
+			flush()
+			currentItem = chunk.item
+		}
+		// the text of an item is split over consecutive chunks
+		if t, ok := chunk.content.(mcp.TextContent); ok {
+			text.WriteString(t.Text)
+			textItem = true
+			continue
+		}
+		content = append(content, chunk.content)
+	}
+	flush()
+	return content, nil
+}
+
+// CallToolChunked calls a tool asking the server to stream large results as
+// chunk notifications, and reassembles them. Servers that don't stream
+// return their results as usual.
+func (c *Client) CallToolChunked(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// the chunks are correlated by the progress token, calls with a token of
+	// their own are made as is
+	if request.Params.Meta != nil && request.Params.Meta.ProgressToken != nil {
+		return c.CallTool(ctx, request)
+	}
+	token := c.chunks.register()
+	defer c.chunks.release(token)
+
+	meta := &mcp.Meta{ProgressToken: token, AdditionalFields: map[string]any{chunkedMetaKey: true}}
+	if request.Params.Meta != nil {
+		for key, value := range request.Params.Meta.AdditionalFields {
+			meta.AdditionalFields[key] = value
+		}
+	}
+	request.Params.Meta = meta
+
+	result, err := c.CallTool(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	count, ok := result.Meta[chunkedMetaKey].(float64)
+	if !ok {
+		return result, nil
+	}
+	content, err := c.chunks.wait(ctx, token, int(count))
+	if err != nil {
+		return nil, err
+	}
+	result.Content = content
+	delete(result.Meta, chunkedMetaKey)
+	return result, nil
+}
@@ -0,0 +1,239 @@
+// Package mcpclient connects to MCP servers with the conveniences of the
+// go-mcp client, header and auth profiles, TLS and proxy settings,
+// cancellation and typed errors, so they can be used without the CLI.
+package mcpclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	TransportSSE  string = "sse"
+	TransportHTTP string = "http"
+)
+
+// DefaultInitTimeout bounds the initialize handshake.
+const DefaultInitTimeout = 5 * time.Second
+
+// Client is an initialized MCP client. The embedded client gives access to
+// every MCP request.
+type Client struct {
+	*client.Client
+	serverInfo mcp.Implementation
+
+	proxyURL             string
+	caCert               string
+	insecure             bool
+	initTimeout          time.Duration
+	clientInfo           mcp.Implementation
+	notificationHandlers []func(mcp.JSONRPCNotification)
+	chunks               chunkAssembler
+	resources            resourceCache
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithInitTimeout bounds the initialize handshake, DefaultInitTimeout by
+// default.
+func WithInitTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.initTimeout = timeout
+	}
+}
+
+// WithClientInfo sets the name and version sent to the server.
+func WithClientInfo(name, version string) Option {
+	return func(c *Client) {
+		c.clientInfo = mcp.Implementation{Name: name, Version: version}
+	}
+}
+
+// WithNotificationHandler calls handler for every notification, including
+// the ones received during the initialize handshake.
+func WithNotificationHandler(handler func(mcp.JSONRPCNotification)) Option {
+	return func(c *Client) {
+		c.notificationHandlers = append(c.notificationHandlers, handler)
+	}
+}
+
+// Connect connects to the server of profile and initializes the session. The
+// connection, i.e. the SSE stream, lives as long as ctx.
+func Connect(ctx context.Context, profile Profile, opts ...Option) (*Client, error) {
+	c := &Client{
+		initTimeout: DefaultInitTimeout,
+		clientInfo:  mcp.Implementation{Name: "go-client", Version: "0.0.1"},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	headers, _, err := profile.RenderHeaders()
+	if err != nil {
+		return nil, err
+	}
+	roundTripper, err := c.httpTransport()
+	if err != nil {
+		return nil, err
+	}
+
+	var t transport.Interface
+	switch profile.Transport {
+	case TransportSSE, "":
+		t, err = transport.NewSSE(profile.URL,
+			transport.WithHeaders(headers),
+			transport.WithHTTPClient(&http.Client{Transport: roundTripper}),
+		)
+	case TransportHTTP:
+		t, err = transport.NewStreamableHTTP(profile.URL,
+			transport.WithHTTPHeaders(headers),
+			transport.WithHTTPBasicClient(&http.Client{Transport: roundTripper}),
+		)
+	default:
+		return nil, fmt.Errorf("unsupported transport type: %s", profile.Transport)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transport: %w", err)
+	}
+
+	c.Client = client.NewClient(&cancellingTransport{Interface: t})
+	if err := c.Start(ctx); err != nil {
+		return nil, classify(fmt.Errorf("failed to start client: %w", err))
+	}
+	c.OnNotification(c.chunks.handle)
+	c.OnNotification(c.resources.handle)
+	for _, handler := range c.notificationHandlers {
+		c.OnNotification(handler)
+	}
+
+	initRequest := mcp.InitializeRequest{}
+	initRequest.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
+	initRequest.Params.ClientInfo = c.clientInfo
+	initRequest.Params.Capabilities = mcp.ClientCapabilities{}
+
+	initCtx, cancel := context.WithTimeout(ctx, c.initTimeout)
+	defer cancel()
+	result, err := c.Initialize(initCtx, initRequest)
+	if err != nil {
+		c.Close()
+		return nil, fmt.Errorf("failed to initialize: %w", err)
+	}
+	c.serverInfo = result.ServerInfo
+	return c, nil
+}
+
+// ServerInfo returns the name and version of the server.
+func (c *Client) ServerInfo() mcp.Implementation {
+	return c.serverInfo
+}
+
+// SessionID returns the MCP session ID of the transport, if any.
+func (c *Client) SessionID() string {
+	if c == nil || c.Client == nil {
+		return ""
+	}
+	t := c.GetTransport()
+	if wrapped, ok := t.(*cancellingTransport); ok {
+		t = wrapped.Interface
+	}
+	switch t := t.(type) {
+	case *transport.StreamableHTTP:
+		return t.GetSessionId()
+	case *transport.SSE:
+		if endpoint := t.GetEndpoint(); endpoint != nil {
+			return endpoint.Query().Get("sessionId")
+		}
+	}
+	return ""
+}
+
+// Call calls tool with args, a map or a struct encoded as JSON, and decodes
+// the result into T. A *mcp.CallToolResult is returned as is, anything else
+// is decoded from the structured content of the result or, for servers that
+// predate it, from its JSON text content. Large results streamed as chunks
+// are reassembled. Results with isError set are returned as a *ToolError.
+func Call[T any](ctx context.Context, c *Client, tool string, args any) (T, error) {
+	var out T
+
+	arguments, err := toArguments(args)
+	if err != nil {
+		return out, err
+	}
+	request := mcp.CallToolRequest{}
+	request.Params.Name = tool
+	request.Params.Arguments = arguments
+
+	result, err := c.CallToolChunked(ctx, request)
+	if err != nil {
+		return out, err
+	}
+	if result.IsError {
+		return out, &ToolError{Tool: tool, Message: ResultText(result)}
+	}
+	if raw, ok := any(result).(T); ok {
+		return raw, nil
+	}
+	return out, decode(result, &out)
+}
+
+func toArguments(args any) (map[string]any, error) {
+	switch args := args.(type) {
+	case nil:
+		return nil, nil
+	case map[string]any:
+		return args, nil
+	}
+	data, err := json.Marshal(args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode arguments: %w", err)
+	}
+	var arguments map[string]any
+	if err := json.Unmarshal(data, &arguments); err != nil {
+		return nil, fmt.Errorf("arguments must encode to a JSON object: %w", err)
+	}
+	return arguments, nil
+}
+
+func decode(result *mcp.CallToolResult, out any) error {
+	// read structuredContent through JSON, it is only a field of the result
+	// on recent versions of mcp-go
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	var fields struct {
+		StructuredContent json.RawMessage `json:"structuredContent"`
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	if len(fields.StructuredContent) > 0 && string(fields.StructuredContent) != "null" {
+		data = fields.StructuredContent
+	} else {
+		data = []byte(ResultText(result))
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode result: %w", err)
+	}
+	return nil
+}
+
+// ResultText joins the text content of a tool result.
+func ResultText(result *mcp.CallToolResult) string {
+	var texts []string
+	for _, content := range result.Content {
+		if text, ok := content.(mcp.TextContent); ok {
+			texts = append(texts, text.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
@@ -0,0 +1,57 @@
+package mcpclient
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// ToolError is returned when a tool call succeeds with isError set.
+type ToolError struct {
+	Tool    string
+	Message string
+}
+
+func (e *ToolError) Error() string {
+	return fmt.Sprintf("tool %s returned an error: %s", e.Tool, e.Message)
+}
+
+// AuthError is returned when the server rejects the credentials.
+type AuthError struct {
+	StatusCode int
+	Err        error
+}
+
+func (e *AuthError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *AuthError) Unwrap() error {
+	return e.Err
+}
+
+// TransportError is returned when the server could not be reached or the
+// request could not be completed, including on timeouts and cancellation.
+type TransportError struct {
+	Err error
+}
+
+func (e *TransportError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TransportError) Unwrap() error {
+	return e.Err
+}
+
+// the transports only report the status code in the error message
+var authStatus = regexp.MustCompile(`status( code:)? (401|403)\b`)
+
+// classify wraps a transport failure in AuthError or TransportError.
+func classify(err error) error {
+	if match := authStatus.FindStringSubmatch(err.Error()); match != nil {
+		code, _ := strconv.Atoi(match[2])
+		return &AuthError{StatusCode: code, Err: err}
+	}
+	return &TransportError{Err: err}
+}
@@ -0,0 +1,70 @@
+package mcpclient
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ToolCall is one call of CallAll.
+type ToolCall struct {
+	Tool      string `json:"tool"`
+	Arguments any    `json:"arguments,omitempty"`
+	// Timeout bounds the call, unbounded when zero.
+	Timeout time.Duration `json:"-"`
+}
+
+// ToolCallResult is the outcome of a ToolCall. Err is a *ToolError when the
+// tool returned isError.
+type ToolCallResult struct {
+	Call     ToolCall
+	Result   *mcp.CallToolResult
+	Err      error
+	Duration time.Duration
+}
+
+// CallAll issues calls concurrently over the session with at most workers
+// calls in flight and returns the results in the order of calls.
+func (c *Client) CallAll(ctx context.Context, calls []ToolCall, workers int) []ToolCallResult {
+	if workers < 1 {
+		workers = 1
+	}
+	results := make([]ToolCallResult, len(calls))
+	next := make(chan int)
+
+	var wg sync.WaitGroup
+	for range min(workers, len(calls)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				results[i] = c.callOne(ctx, calls[i])
+			}
+		}()
+	}
+
+	for i := range calls {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return results
+}
+
+func (c *Client) callOne(ctx context.Context, call ToolCall) ToolCallResult {
+	if call.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, call.Timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	result, err := Call[*mcp.CallToolResult](ctx, c, call.Tool, call.Arguments)
+	return ToolCallResult{
+		Call:     call,
+		Result:   result,
+		Err:      err,
+		Duration: time.Since(start),
+	}
+}
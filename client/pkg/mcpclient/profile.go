@@ -0,0 +1,176 @@
+package mcpclient
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+const (
+	AuthBearer string = "bearer"
+	AuthBasic  string = "basic"
+	AuthHeader string = "header"
+)
+
+// AuthConfig is a profile's auth helper. Every value is a header template.
+type AuthConfig struct {
+	Type     string `json:"type"`
+	Token    string `json:"token,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	// Header names the header carrying Token for the header type.
+	Header string `json:"header,omitempty"`
+}
+
+// Profile is a named server along with its header templates and auth, i.e.
+//
+//	{
+//	  "dev": {
+//	    "url": "http://localhost:8080/sse",
+//	    "headers": {"X-Team": "platform"},
+//	    "auth": {"type": "bearer", "token": "{{env \"MCP_TOKEN\"}}"}
+//	  }
+//	}
+type Profile struct {
+	URL string `json:"url,omitempty"`
+	// Transport is TransportSSE, the default, or TransportHTTP.
+	Transport string            `json:"transport,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	Auth      *AuthConfig       `json:"auth,omitempty"`
+}
+
+// LoadProfile reads the profile name from the JSON profiles file at path.
+func LoadProfile(path, name string) (Profile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Profile{}, fmt.Errorf("failed to read profiles: %w", err)
+	}
+	var profiles map[string]Profile
+	if err := json.Unmarshal(data, &profiles); err != nil {
+		return Profile{}, fmt.Errorf("failed to parse profiles: %w", err)
+	}
+	p, ok := profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("unknown profile %q", name)
+	}
+	return p, nil
+}
+
+var templateFuncs = template.FuncMap{
+	"env": func(key string) (string, error) {
+		value, ok := os.LookupEnv(key)
+		if !ok {
+			return "", fmt.Errorf("%s is not set", key)
+		}
+		return value, nil
+	},
+	"file": func(path string) (string, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(data)), nil
+	},
+}
+
+func render(text string) (string, error) {
+	tmpl, err := template.New("header").Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, nil); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// RenderHeaders renders the profile into request headers. secret holds the
+// names of the headers carrying credentials so they can be redacted from logs.
+func (p Profile) RenderHeaders() (headers map[string]string, secret map[string]bool, err error) {
+	headers = make(map[string]string, len(p.Headers)+1)
+	secret = make(map[string]bool)
+	for name, text := range p.Headers {
+		if headers[name], err = render(text); err != nil {
+			return nil, nil, fmt.Errorf("header %s: %w", name, err)
+		}
+	}
+	if p.Auth == nil {
+		return headers, secret, nil
+	}
+
+	name, value, err := p.Auth.header()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s auth: %w", p.Auth.Type, err)
+	}
+	headers[name] = value
+	secret[name] = true
+	return headers, secret, nil
+}
+
+func (a *AuthConfig) header() (name, value string, err error) {
+	switch a.Type {
+	case AuthBearer:
+		token, err := render(a.Token)
+		if err != nil {
+			return "", "", err
+		}
+		return "Authorization", "Bearer " + token, nil
+	case AuthBasic:
+		username, err := render(a.Username)
+		if err != nil {
+			return "", "", err
+		}
+		password, err := render(a.Password)
+		if err != nil {
+			return "", "", err
+		}
+		credentials := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+		return "Authorization", "Basic " + credentials, nil
+	case AuthHeader:
+		if a.Header == "" {
+			return "", "", fmt.Errorf("missing header name")
+		}
+		token, err := render(a.Token)
+		if err != nil {
+			return "", "", err
+		}
+		return a.Header, token, nil
+	default:
+		return "", "", fmt.Errorf("unsupported auth type %q", a.Type)
+	}
+}
+
+// RedactHeaders formats headers for logging without the credentials.
+func RedactHeaders(headers map[string]string, secret map[string]bool) string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var out []string
+	for _, name := range names {
+		value := headers[name]
+		if secret[name] || isSensitiveHeader(name) {
+			value = "[redacted]"
+		}
+		out = append(out, name+": "+value)
+	}
+	return strings.Join(out, ", ")
+}
+
+func isSensitiveHeader(name string) bool {
+	name = strings.ToLower(name)
+	for _, word := range []string{"authorization", "cookie", "token", "key", "secret"} {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,247 @@
+package mcpclient
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	// etagMetaKey holds the hash of the contents in the _meta of
+	// resources/read results of servers supporting conditional reads.
+	etagMetaKey = "etag"
+	// notModifiedMetaKey marks results whose contents were left out as they
+	// match the etag sent along.
+	notModifiedMetaKey = "notModified"
+	// ifNoneMatchArgument sends the etag of the cached contents.
+	ifNoneMatchArgument = "ifNoneMatch"
+)
+
+// WithResourceCache persists the resource cache in dir, so the contents read
+// by earlier runs are revalidated instead of downloaded again.
+func WithResourceCache(dir string) Option {
+	return func(c *Client) {
+		c.resources.dir = dir
+	}
+}
+
+// resourceCache holds the last contents read of each resource URI along with
+// their etag, in memory and optionally on disk. The contents of subscribed
+// resources are fresh until the server notifies that they were updated.
+type resourceCache struct {
+	dir string
+
+	mu         sync.Mutex
+	entries    map[string]cachedResource
+	subscribed map[string]bool
+}
+
+type cachedResource struct {
+	URI    string                  `json:"uri"`
+	ETag   string                  `json:"etag"`
+	Result map[string]any          `json:"result"`
+	result *mcp.ReadResourceResult `json:"-"`
+	// fresh is set when the contents were checked while subscribed, missing
+	// no update notification since
+	fresh bool `json:"-"`
+}
+
+func (r *resourceCache) path(uri string) string {
+	sum := sha256.Sum256([]byte(uri))
+	return filepath.Join(r.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (r *resourceCache) get(uri string) (cachedResource, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if entry, ok := r.entries[uri]; ok {
+		return entry, true
+	}
+	if r.dir == "" {
+		return cachedResource{}, false
+	}
+	entry, err := r.load(uri)
+	if err != nil {
+		return cachedResource{}, false
+	}
+	r.store(entry)
+	return entry, true
+}
+
+func (r *resourceCache) load(uri string) (cachedResource, error) {
+	var entry cachedResource
+	data, err := os.ReadFile(r.path(uri))
+	if err != nil {
+		return entry, err
+	}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return entry, err
+	}
+	if entry.URI != uri {
+		return entry, fmt.Errorf("cache entry of %s holds %s", uri, entry.URI)
+	}
+	raw, err := json.Marshal(entry.Result)
+	if err != nil {
+		return entry, err
+	}
+	message := json.RawMessage(raw)
+	entry.result, err = mcp.ParseReadResourceResult(&message)
+	return entry, err
+}
+
+func (r *resourceCache) put(uri, etag string, result *mcp.ReadResourceResult) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry := cachedResource{URI: uri, ETag: etag, result: result, fresh: r.subscribed[uri]}
+	r.store(entry)
+	if r.dir == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &entry.Result); err != nil {
+		return err
+	}
+	if data, err = json.Marshal(entry); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(r.dir, 0o700); err != nil {
+		return err
+	}
+	// write and rename, so concurrent runs never read a partial entry
+	tmp, err := os.CreateTemp(r.dir, ".entry-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), r.path(uri))
+}
+
+func (r *resourceCache) store(entry cachedResource) {
+	if r.entries == nil {
+		r.entries = make(map[string]cachedResource)
+	}
+	r.entries[entry.URI] = entry
+}
+
+// revalidated marks the entry fresh when the server confirmed it while
+// subscribed.
+func (r *resourceCache) revalidated(uri string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if entry, ok := r.entries[uri]; ok {
+		entry.fresh = r.subscribed[uri]
+		r.entries[uri] = entry
+	}
+}
+
+func (r *resourceCache) invalidate(uri string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.entries, uri)
+	if r.dir != "" {
+		_ = os.Remove(r.path(uri))
+	}
+}
+
+func (r *resourceCache) subscribe(uri string, subscribed bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.subscribed == nil {
+		r.subscribed = make(map[string]bool)
+	}
+	if subscribed {
+		r.subscribed[uri] = true
+		return
+	}
+	delete(r.subscribed, uri)
+	if entry, ok := r.entries[uri]; ok {
+		entry.fresh = false
+		r.entries[uri] = entry
+	}
+}
+
+func (r *resourceCache) handle(notification mcp.JSONRPCNotification) {
+	if notification.Method != "notifications/resources/updated" {
+		return
+	}
+	if uri, ok := notification.Params.AdditionalFields["uri"].(string); ok {
+		r.invalidate(uri)
+	}
+}
+
+// ReadResource reads a resource through the cache. Subscribed resources are
+// served from the cache until the server notifies that they were updated,
+// the others are revalidated with their etag so servers supporting
+// conditional reads only send them when modified. Servers without etags are
+// read as usual.
+func (c *Client) ReadResource(ctx context.Context, request mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+	uri := request.Params.URI
+	cached, ok := c.resources.get(uri)
+	if ok && cached.fresh {
+		return copyResult(cached.result), nil
+	}
+	if ok {
+		arguments := make(map[string]any, len(request.Params.Arguments)+1)
+		maps.Copy(arguments, request.Params.Arguments)
+		arguments[ifNoneMatchArgument] = cached.ETag
+		request.Params.Arguments = arguments
+	}
+
+	result, err := c.Client.ReadResource(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	if notModified, _ := result.Meta[notModifiedMetaKey].(bool); notModified && ok {
+		c.resources.revalidated(uri)
+		return copyResult(cached.result), nil
+	}
+	if etag, isString := result.Meta[etagMetaKey].(string); isString {
+		// a cache that can't be written only costs the next download
+		_ = c.resources.put(uri, etag, copyResult(result))
+	}
+	return result, nil
+}
+
+// Subscribe subscribes to the updates of a resource, its contents read from
+// then on are cached until the server notifies an update.
+func (c *Client) Subscribe(ctx context.Context, request mcp.SubscribeRequest) error {
+	if err := c.Client.Subscribe(ctx, request); err != nil {
+		return err
+	}
+	c.resources.subscribe(request.Params.URI, true)
+	return nil
+}
+
+// Unsubscribe stops the updates of a resource, its cached contents are
+// revalidated again.
+func (c *Client) Unsubscribe(ctx context.Context, request mcp.UnsubscribeRequest) error {
+	c.resources.subscribe(request.Params.URI, false)
+	return c.Client.Unsubscribe(ctx, request)
+}
+
+// copyResult keeps the callers from modifying the cached contents slice.
+func copyResult(result *mcp.ReadResourceResult) *mcp.ReadResourceResult {
+	copied := *result
+	copied.Contents = append([]mcp.ResourceContents(nil), result.Contents...)
+	copied.Meta = maps.Clone(result.Meta)
+	return &copied
+}
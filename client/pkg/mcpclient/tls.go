@@ -0,0 +1,68 @@
+package mcpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+// WithProxy sends the requests through the proxy at proxyURL. Without it the
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY variables apply.
+func WithProxy(proxyURL string) Option {
+	return func(c *Client) {
+		c.proxyURL = proxyURL
+	}
+}
+
+// WithCACert trusts the PEM encoded CA certificates in path on top of the
+// system pool.
+func WithCACert(path string) Option {
+	return func(c *Client) {
+		c.caCert = path
+	}
+}
+
+// WithInsecure skips TLS certificate verification, i.e. for self-signed dev
+// servers.
+func WithInsecure(insecure bool) Option {
+	return func(c *Client) {
+		c.insecure = insecure
+	}
+}
+
+// httpTransport builds the round tripper shared by the MCP transports.
+func (c *Client) httpTransport() (*http.Transport, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+
+	if c.proxyURL != "" {
+		proxy, err := url.Parse(c.proxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy %q: %w", c.proxyURL, err)
+		}
+		t.Proxy = http.ProxyURL(proxy)
+	}
+
+	if c.caCert == "" && !c.insecure {
+		return t, nil
+	}
+	tlsConfig := &tls.Config{InsecureSkipVerify: c.insecure}
+	if c.caCert != "" {
+		pem, err := os.ReadFile(c.caCert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", c.caCert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	t.TLSClientConfig = tlsConfig
+	return t, nil
+}
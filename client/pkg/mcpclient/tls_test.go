@@ -0,0 +1,44 @@
+package mcpclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// TestInsecureKeepsDefaultTransport connects to a self-signed server over
+// both transports without changing the TLS settings of the host program.
+func TestInsecureKeepsDefaultTransport(t *testing.T) {
+	mcpServer := server.NewMCPServer("test", "1")
+	mux := http.NewServeMux()
+	mux.Handle("/mcp", server.NewStreamableHTTPServer(mcpServer))
+	sse := server.NewSSEServer(mcpServer)
+	mux.Handle("/sse", sse.SSEHandler())
+	mux.Handle("/message", sse.MessageHandler())
+	ts := httptest.NewTLSServer(mux)
+	defer ts.Close()
+
+	defaultTransport := http.DefaultTransport
+	for _, profile := range []Profile{
+		{URL: ts.URL + "/mcp", Transport: TransportHTTP},
+		{URL: ts.URL + "/sse", Transport: TransportSSE},
+	} {
+		ctx, cancel := context.WithCancel(context.Background())
+		if _, err := Connect(ctx, profile); err == nil {
+			t.Errorf("%s: connected to a self-signed server without WithInsecure", profile.Transport)
+		}
+		c, err := Connect(ctx, profile, WithInsecure(true))
+		if err != nil {
+			t.Errorf("%s: %v", profile.Transport, err)
+		} else {
+			c.Close()
+		}
+		cancel()
+		if http.DefaultTransport != defaultTransport {
+			t.Fatalf("%s: http.DefaultTransport was replaced", profile.Transport)
+		}
+	}
+}
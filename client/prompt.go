@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/wagnerjt/go-mcp/client/pkg/mcpclient"
+)
+
+// isTerminal reports whether stdin is interactive.
+func isTerminal() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// findTool looks tool up in the catalog of the server.
+func findTool(ctx context.Context, c *mcpclient.Client, name string) (*mcp.Tool, error) {
+	ctx, cancel := operationContext(ctx)
+	defer cancel()
+	result, err := c.ListTools(ctx, mcp.ListToolsRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("list tools failed: %w", err)
+	}
+	for _, tool := range result.Tools {
+		if tool.Name == name {
+			return &tool, nil
+		}
+	}
+	return nil, &usageError{fmt.Sprintf("unknown tool %q", name)}
+}
+
+// argumentPrompter asks for the missing required arguments of a tool,
+// parsing the answers by the type of their schema.
+type argumentPrompter struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+func newArgumentPrompter() *argumentPrompter {
+	return &argumentPrompter{in: bufio.NewReader(os.Stdin), out: os.Stderr}
+}
+
+func (p *argumentPrompter) prompt(tool *mcp.Tool, arguments map[string]any) error {
+	required := append([]string(nil), tool.InputSchema.Required...)
+	sort.Strings(required)
+	for _, name := range required {
+		if _, ok := arguments[name]; ok {
+			continue
+		}
+		schema, _ := tool.InputSchema.Properties[name].(map[string]any)
+		value, err := p.ask(name, schema)
+		if err != nil {
+			return err
+		}
+		arguments[name] = value
+	}
+	return nil
+}
+
+// ask prompts for name until the answer parses.
+func (p *argumentPrompter) ask(name string, schema map[string]any) (any, error) {
+	kind, _ := schema["type"].(string)
+	description, _ := schema["description"].(string)
+	enum, _ := schema["enum"].([]any)
+
+	label := name
+	if kind != "" {
+		label += " (" + kind + ")"
+	}
+	if description != "" {
+		label += " - " + description
+	}
+	for i, choice := range enum {
+		fmt.Fprintf(p.out, "  %d) %v\n", i+1, choice)
+	}
+
+	for {
+		fmt.Fprintf(p.out, "%s: ", label)
+		answer, err := p.in.ReadString('\n')
+		if err != nil && answer == "" {
+			return nil, &usageError{fmt.Sprintf("missing argument %s", name)}
+		}
+		answer = strings.TrimSpace(answer)
+
+		value, err := parseAnswer(answer, kind, enum)
+		if err == nil {
+			return value, nil
+		}
+		fmt.Fprintf(p.out, "  %v\n", err)
+	}
+}
+
+// parseAnswer converts answer into the JSON type kind, an enum answer may be
+// the number of the choice.
+func parseAnswer(answer, kind string, enum []any) (any, error) {
+	if len(enum) > 0 {
+		if i, err := strconv.Atoi(answer); err == nil && i >= 1 && i <= len(enum) {
+			return enum[i-1], nil
+		}
+		for _, choice := range enum {
+			if fmt.Sprint(choice) == answer {
+				return choice, nil
+			}
+		}
+		return nil, fmt.Errorf("choose one of the %d choices", len(enum))
+	}
+
+	switch kind {
+	case "string", "":
+		if answer == "" && kind == "string" {
+			return nil, fmt.Errorf("a value is required")
+		}
+		return answer, nil
+	case "number":
+		n, err := strconv.ParseFloat(answer, 64)
+		if err != nil {
+			return nil, fmt.Errorf("expected a number")
+		}
+		return n, nil
+	case "integer":
+		n, err := strconv.ParseInt(answer, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("expected an integer")
+		}
+		return n, nil
+	case "boolean":
+		b, err := strconv.ParseBool(answer)
+		if err != nil {
+			return nil, fmt.Errorf("expected true or false")
+		}
+		return b, nil
+	default:
+		// arrays and objects are entered as JSON
+		var value any
+		if err := json.Unmarshal([]byte(answer), &value); err != nil {
+			return nil, fmt.Errorf("expected JSON %s: %v", kind, err)
+		}
+		return value, nil
+	}
+}
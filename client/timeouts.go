@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+var (
+	operationTimeout time.Duration
+	initTimeout      time.Duration
+	cancelAfter      time.Duration
+)
+
+// operationContext bounds a single operation by -timeout.
+func operationContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, operationTimeout)
+}
+
+// toolCallContext bounds a tool call by -timeout and, with -cancel-after,
+// cancels it early to exercise notifications/cancelled.
+func toolCallContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := operationContext(ctx)
+	if cancelAfter > 0 {
+		timer := time.AfterFunc(cancelAfter, cancel)
+		return ctx, func() {
+			timer.Stop()
+			cancel()
+		}
+	}
+	return ctx, cancel
+}
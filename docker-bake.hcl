@@ -6,18 +6,53 @@ variable "REGISTRY" {
     default = "ghcr.io/wagnerjt/go-mcp"
 }
 
+variable "COMMIT" {
+    default = "unknown"
+}
+
+variable "BUILD_DATE" {
+    default = "unknown"
+}
+
 group "default" {
     targets = ["server"]
 }
 
 group "all" {
-    targets = ["server", "litellm-bridge"]
+    targets = ["server", "spotify", "github", "litellm-bridge"]
 }
 
 target "server" {
-    dockerfile = "Dockerfile"
+    dockerfile = "server/Dockerfile"
     tags = ["${REGISTRY}/server:${TAG}"]
-    context = "./server"
+    context = "."
+    args = {
+        VERSION = "${TAG}"
+        COMMIT = "${COMMIT}"
+        BUILD_DATE = "${BUILD_DATE}"
+    }
+}
+
+target "spotify" {
+    dockerfile = "spotify/Dockerfile"
+    tags = ["${REGISTRY}/spotify:${TAG}"]
+    context = "."
+    args = {
+        VERSION = "${TAG}"
+        COMMIT = "${COMMIT}"
+        BUILD_DATE = "${BUILD_DATE}"
+    }
+}
+
+target "github" {
+    dockerfile = "github/Dockerfile"
+    tags = ["${REGISTRY}/github:${TAG}"]
+    context = "."
+    args = {
+        VERSION = "${TAG}"
+        COMMIT = "${COMMIT}"
+        BUILD_DATE = "${BUILD_DATE}"
+    }
 }
 
 target "litellm-bridge" {
@@ -0,0 +1,40 @@
+// Command github runs the GitHub OAuth protected MCP server.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/wagnerjt/go-mcp/github/pkg/githubserver"
+	"github.com/wagnerjt/go-mcp/shared/pkg/buildinfo"
+)
+
+func main() {
+	port := flag.String("port", "8081", "Port to run the MCP server on")
+	externalURL := flag.String("external-url", githubserver.DefaultExternalURL, "Public base URL of the server, the redirect URI is /auth/callback on it")
+	apiURL := flag.String("api-url", githubserver.GitHubAPIURL, "GitHub API URL, i.e. of a GitHub Enterprise server")
+	version := flag.Bool("version", false, "Print the version, commit and build date and exit")
+	flag.Parse()
+
+	if *version {
+		fmt.Println(buildinfo.Get())
+		return
+	}
+
+	srv, err := githubserver.New(
+		githubserver.WithClientCredentials(os.Getenv("GITHUB_CLIENT_ID"), os.Getenv("GITHUB_CLIENT_SECRET")),
+		githubserver.WithExternalURL(*externalURL),
+		githubserver.WithAPIURL(*apiURL),
+	)
+	if err != nil {
+		log.Fatalf("Failed to create server: %v", err)
+	}
+
+	log.Printf("HTTP server listening on port %s", *port)
+	if err := http.ListenAndServe(":"+*port, srv.Handler()); err != nil {
+		log.Fatalf("Server error: %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package githubserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/wagnerjt/go-mcp/shared/pkg/oauthflow"
+	"golang.org/x/oauth2"
+)
+
+// TokenCacheTTL is how long a bearer token found to belong to the logged in
+// user is trusted before GitHub is asked again.
+const TokenCacheTTL = 5 * time.Minute
+
+// verifiedToken is a bearer token GitHub reported to belong to userID.
+type verifiedToken struct {
+	userID  int64
+	expires time.Time
+}
+
+// user returns the ID of the GitHub user of token, along with the status
+// code of the failed call.
+func (s *Server) user(ctx context.Context, token *oauth2.Token) (int64, int, error) {
+	var user struct {
+		ID int64 `json:"id"`
+	}
+	status, err := s.call(ctx, token, http.MethodGet, "/user", nil, &user)
+	if err != nil {
+		return 0, status, err
+	}
+	if user.ID == 0 {
+		return 0, status, fmt.Errorf("github GET /user: no user ID")
+	}
+	return user.ID, status, nil
+}
+
+// authenticate only serves the requests whose bearer token belongs to the
+// GitHub user of the last login, since the tools act with the token of that
+// login. GitHub tells the user of a token on GET /user, which is cached for
+// TokenCacheTTL by the hash of the token.
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bearer, _ := oauthflow.BearerToken(r)
+		key := sha256.Sum256([]byte(bearer))
+		now := s.clock()
+
+		s.mu.RLock()
+		loginID := s.userID
+		cached, ok := s.verified[key]
+		s.mu.RUnlock()
+		if loginID == 0 {
+			s.rejectInvalidToken(w, r, "not logged in to GitHub")
+			return
+		}
+		if ok && cached.userID == loginID && now.Before(cached.expires) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		userID, status, err := s.user(r.Context(), &oauth2.Token{AccessToken: bearer})
+		if err != nil && status != http.StatusUnauthorized && status != http.StatusForbidden {
+			log.Printf("Failed to verify bearer token: %v", err)
+			http.Error(w, "failed to verify the token with GitHub", http.StatusBadGateway)
+			return
+		}
+		if err != nil {
+			s.rejectInvalidToken(w, r, err.Error())
+			return
+		}
+		if userID != loginID {
+			s.rejectInvalidToken(w, r, fmt.Sprintf("token of GitHub user %d, not of the logged in user", userID))
+			return
+		}
+
+		s.mu.Lock()
+		for key, cached := range s.verified {
+			if !now.Before(cached.expires) {
+				delete(s.verified, key)
+			}
+		}
+		s.verified[key] = verifiedToken{userID: userID, expires: now.Add(TokenCacheTTL)}
+		s.mu.Unlock()
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) rejectInvalidToken(w http.ResponseWriter, r *http.Request, reason string) {
+	log.Printf("Rejecting bearer token: %s", reason)
+	challenge := s.challenge(r)
+	challenge.Error = "invalid_token"
+	challenge.Reject(w)
+}
@@ -0,0 +1,96 @@
+package githubserver
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/wagnerjt/go-mcp/shared/pkg/oauthflow"
+	"golang.org/x/oauth2"
+)
+
+// fakeGitHub answers GET /user with the user of the tokens "alice-*" and
+// "bob-*", counting the calls.
+func fakeGitHub(t *testing.T, calls *atomic.Int32) *httptest.Server {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		switch {
+		case r.URL.Path != "/user":
+			http.NotFound(w, r)
+		case strings.HasPrefix(token, "alice-"):
+			fmt.Fprint(w, `{"id":1,"login":"alice"}`)
+		case strings.HasPrefix(token, "bob-"):
+			fmt.Fprint(w, `{"id":2,"login":"bob"}`)
+		case token == "down":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"message":"Bad credentials"}`)
+		}
+	}))
+	t.Cleanup(api.Close)
+	return api
+}
+
+func TestAuthenticate(t *testing.T) {
+	var calls atomic.Int32
+	api := fakeGitHub(t, &calls)
+	s, err := New(WithClientCredentials("id", "secret"), WithAPIURL(api.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+	s.clock = func() time.Time { return now }
+	handler := s.authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	serve := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := serve("alice-1"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("before login: status %d, want 401", rec.Code)
+	}
+	if _, err := s.storeToken(&oauth2.Token{AccessToken: "alice-login"}, oauthflow.Login{}); err != nil {
+		t.Fatal(err)
+	}
+
+	for token, want := range map[string]int{
+		"alice-1": http.StatusOK,
+		"bob-1":   http.StatusUnauthorized,
+		"revoked": http.StatusUnauthorized,
+		"down":    http.StatusBadGateway,
+	} {
+		rec := serve(token)
+		if rec.Code != want {
+			t.Errorf("%s: status %d, want %d", token, rec.Code, want)
+		}
+		if want == http.StatusUnauthorized && !strings.Contains(rec.Header().Get("WWW-Authenticate"), `error="invalid_token"`) {
+			t.Errorf("%s: challenge %q, want invalid_token", token, rec.Header().Get("WWW-Authenticate"))
+		}
+	}
+
+	calls.Store(0)
+	if rec := serve("alice-1"); rec.Code != http.StatusOK || calls.Load() != 0 {
+		t.Errorf("cached: status %d after %d calls, want 200 without a call", rec.Code, calls.Load())
+	}
+	now = now.Add(TokenCacheTTL)
+	if rec := serve("alice-1"); rec.Code != http.StatusOK || calls.Load() != 1 {
+		t.Errorf("expired: status %d after %d calls, want 200 after a call", rec.Code, calls.Load())
+	}
+
+	// a login of another user drops the tokens of the previous one
+	if _, err := s.storeToken(&oauth2.Token{AccessToken: "bob-login"}, oauthflow.Login{}); err != nil {
+		t.Fatal(err)
+	}
+	if rec := serve("alice-1"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("after another login: status %d, want 401", rec.Code)
+	}
+}
@@ -0,0 +1,221 @@
+// Package githubserver provides a GitHub OAuth protected MCP server, built on
+// the same OAuth flow as the Spotify server, as a template for other
+// providers.
+package githubserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/wagnerjt/go-mcp/shared/pkg/buildinfo"
+	"github.com/wagnerjt/go-mcp/shared/pkg/compensation"
+	"github.com/wagnerjt/go-mcp/shared/pkg/oauthflow"
+	"golang.org/x/oauth2"
+)
+
+const (
+	GitHubAuthEndpoint  = "https://github.com/login/oauth/authorize"
+	GitHubTokenEndpoint = "https://github.com/login/oauth/access_token"
+	GitHubAPIURL        = "https://api.github.com"
+
+	DefaultExternalURL = "http://127.0.0.1:8081"
+	CallbackPath       = "/auth/callback"
+	LoginPath          = "/auth/github/login"
+)
+
+// Server is the GitHub MCP server along with its OAuth endpoints. The token
+// of the last login is used for the GitHub API calls of the tools, so only
+// bearer tokens of the same GitHub user are accepted on /mcp.
+type Server struct {
+	mu           sync.RWMutex
+	clientID     string
+	clientSecret string
+	externalURL  string
+	scopes       []string
+	apiURL       string
+	httpClient   *http.Client
+	clock        func() time.Time
+	token        *oauth2.Token
+	// the GitHub user of the last login
+	userID int64
+	// the bearer tokens found to belong to userID, by their hash
+	verified map[[sha256.Size]byte]verifiedToken
+
+	auth      *oauthflow.Flow
+	undo      *compensation.Stacks
+	mcpServer *server.MCPServer
+}
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithClientCredentials sets the GitHub OAuth app client ID and secret.
+func WithClientCredentials(clientID, clientSecret string) Option {
+	return func(s *Server) {
+		s.clientID = clientID
+		s.clientSecret = clientSecret
+	}
+}
+
+// WithExternalURL sets the public base URL of the server, the redirect URI
+// registered with GitHub is CallbackPath on it.
+func WithExternalURL(externalURL string) Option {
+	return func(s *Server) {
+		s.externalURL = strings.TrimSuffix(externalURL, "/")
+	}
+}
+
+// WithScopes overrides the scopes requested during login.
+func WithScopes(scopes ...string) Option {
+	return func(s *Server) {
+		s.scopes = scopes
+	}
+}
+
+// WithAPIURL overrides the GitHub API URL, i.e. for GitHub Enterprise.
+func WithAPIURL(apiURL string) Option {
+	return func(s *Server) {
+		s.apiURL = strings.TrimSuffix(apiURL, "/")
+	}
+}
+
+// WithHTTPClient sets the client of the GitHub API calls.
+func WithHTTPClient(client *http.Client) Option {
+	return func(s *Server) {
+		s.httpClient = client
+	}
+}
+
+// New creates the GitHub MCP server.
+func New(opts ...Option) (*Server, error) {
+	s := &Server{
+		externalURL: DefaultExternalURL,
+		scopes:      []string{"repo"},
+		apiURL:      GitHubAPIURL,
+		httpClient:  http.DefaultClient,
+		clock:       time.Now,
+		verified:    make(map[[sha256.Size]byte]verifiedToken),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.clientID == "" || s.clientSecret == "" {
+		return nil, fmt.Errorf("github client credentials are required")
+	}
+	s.auth = oauthflow.New(s.oauthConfig, oauthflow.TokenStoreFunc(s.storeToken))
+	s.undo = compensation.New()
+	s.mcpServer = s.newMCPServer()
+	return s, nil
+}
+
+// MCPServer returns the underlying MCP server.
+func (s *Server) MCPServer() *server.MCPServer {
+	return s.mcpServer
+}
+
+func (s *Server) oauthConfig() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     s.clientID,
+		ClientSecret: s.clientSecret,
+		RedirectURL:  s.externalURL + CallbackPath,
+		Scopes:       s.scopes,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  GitHubAuthEndpoint,
+			TokenURL: GitHubTokenEndpoint,
+		},
+	}
+}
+
+// storeToken keeps the token of the last login and its user, GitHub reports
+// the granted scopes in the scope field.
+func (s *Server) storeToken(token *oauth2.Token, login oauthflow.Login) ([]string, error) {
+	granted := login.Scopes
+	if scope, ok := token.Extra("scope").(string); ok {
+		granted = strings.Split(scope, ",")
+	}
+	userID, _, err := s.user(context.Background(), token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the user of the token: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.token = token
+	s.userID = userID
+	clear(s.verified)
+	return granted, nil
+}
+
+func (s *Server) currentToken() *oauth2.Token {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.token
+}
+
+// Handler returns the http.Handler serving the MCP, OAuth and health endpoints.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"status":"UP"}`))
+	})
+	mux.HandleFunc("/version", buildinfo.Handler)
+	mux.Handle("/.well-known/oauth-protected-resource", s.resourceMetadata())
+	mux.HandleFunc("/.well-known/oauth-authorization-server", handleAuthServerMetadata)
+	mux.HandleFunc(LoginPath, s.handleLogin)
+	mux.Handle(CallbackPath, s.auth)
+
+	httpServer := server.NewStreamableHTTPServer(s.mcpServer)
+	mux.Handle("/mcp", oauthflow.RequireBearer(s.challenge)(s.authenticate(httpServer)))
+	return mux
+}
+
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	authURL, _, err := s.auth.Start(s.externalURL+CallbackPath, s.scopes, false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+func (s *Server) resourceMetadata() oauthflow.ProtectedResource {
+	return oauthflow.ProtectedResource{
+		Resource:               s.externalURL + "/",
+		AuthorizationServers:   []string{"https://github.com/login/oauth"},
+		BearerMethodsSupported: []string{"header"},
+		ScopesSupported:        s.scopes,
+	}
+}
+
+// handleAuthServerMetadata serves the authorization server metadata GitHub
+// does not publish itself.
+func handleAuthServerMetadata(w http.ResponseWriter, r *http.Request) {
+	body, _ := json.Marshal(map[string]any{
+		"issuer":                           "https://github.com",
+		"authorization_endpoint":           GitHubAuthEndpoint,
+		"token_endpoint":                   GitHubTokenEndpoint,
+		"response_types_supported":         []string{"code"},
+		"grant_types_supported":            []string{"authorization_code", "refresh_token"},
+		"code_challenge_methods_supported": []string{"S256"},
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+// challenge points clients without a bearer token to the protected resource
+// metadata, like the Spotify server.
+func (s *Server) challenge(r *http.Request) oauthflow.Challenge {
+	return oauthflow.Challenge{
+		Realm:            "github-go-server",
+		ResourceMetadata: s.externalURL + "/.well-known/oauth-protected-resource",
+		AuthorizationURI: GitHubAuthEndpoint,
+		Error:            "unauthorized",
+	}
+}
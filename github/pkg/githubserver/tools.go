@@ -0,0 +1,164 @@
+package githubserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/wagnerjt/go-mcp/shared/pkg/buildinfo"
+	"github.com/wagnerjt/go-mcp/shared/pkg/compensation"
+	"github.com/wagnerjt/go-mcp/shared/pkg/upstream"
+	"golang.org/x/oauth2"
+)
+
+func (s *Server) newMCPServer() *server.MCPServer {
+	mcpServer := server.NewMCPServer("github/tools", buildinfo.Get().Version,
+		server.WithToolCapabilities(true),
+		server.WithLogging(),
+	)
+
+	mcpServer.AddTool(mcp.NewTool("list_repos",
+		mcp.WithDescription("Lists the repositories of the logged in GitHub user, most recently updated first"),
+		mcp.WithString("visibility",
+			mcp.Description("Only list all, public or private repositories"),
+			mcp.Enum("all", "public", "private"),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("Maximum number of repositories, up to 100"),
+			mcp.DefaultNumber(30),
+		),
+	), s.handleListRepos)
+	mcpServer.AddTool(mcp.NewTool("create_issue",
+		mcp.WithDescription("Creates an issue in a GitHub repository. Undoing it with "+compensation.ToolName+" closes the issue as not planned, GitHub does not allow deleting it"),
+		mcp.WithString("repo",
+			mcp.Description("Repository as owner/name"),
+			mcp.Required(),
+		),
+		mcp.WithString("title",
+			mcp.Description("Title of the issue"),
+			mcp.Required(),
+		),
+		mcp.WithString("body",
+			mcp.Description("Markdown body of the issue"),
+		),
+	), s.handleCreateIssue)
+	mcpServer.AddTools(s.undo.Tool())
+
+	return mcpServer
+}
+
+// api calls the GitHub API with the token of the last login and decodes the
+// response into out.
+func (s *Server) api(ctx context.Context, method, path string, body, out any) error {
+	token := s.currentToken()
+	if token == nil {
+		return fmt.Errorf("not logged in to GitHub, open %s%s first", s.externalURL, LoginPath)
+	}
+	_, err := s.call(ctx, token, method, path, body, out)
+	return err
+}
+
+// call calls the GitHub API with token and decodes the response into out. It
+// returns the status code along with the error of a failed call.
+func (s *Server) call(ctx context.Context, token *oauth2.Token, method, path string, body, out any) (int, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, s.apiURL+path, reader)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	token.SetAuthHeader(req)
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("github %s %s: %w", method, path, upstream.Error(ctx, err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&apiErr)
+		return resp.StatusCode, fmt.Errorf("github %s %s: status code %d: %s", method, path, resp.StatusCode, apiErr.Message)
+	}
+	return resp.StatusCode, json.NewDecoder(resp.Body).Decode(out)
+}
+
+type repo struct {
+	FullName    string `json:"full_name"`
+	Private     bool   `json:"private"`
+	Description string `json:"description"`
+	HTMLURL     string `json:"html_url"`
+}
+
+func (s *Server) handleListRepos(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	query := url.Values{"sort": {"updated"}}
+	query.Set("visibility", request.GetString("visibility", "all"))
+	limit := request.GetInt("limit", 30)
+	if limit < 1 || limit > 100 {
+		return nil, fmt.Errorf("invalid arguments: limit must be between 1 and 100")
+	}
+	query.Set("per_page", fmt.Sprint(limit))
+
+	var repos []repo
+	if err := s.api(ctx, http.MethodGet, "/user/repos?"+query.Encode(), nil, &repos); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(repos)
+	if err != nil {
+		return nil, err
+	}
+	return mcp.NewToolResultText(string(data)), nil
+}
+
+func (s *Server) handleCreateIssue(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	fullName, err := request.RequireString("repo")
+	if err != nil {
+		return nil, fmt.Errorf("invalid arguments: %w", err)
+	}
+	owner, name, ok := strings.Cut(fullName, "/")
+	if !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+		return nil, fmt.Errorf("invalid arguments: repo must be owner/name, got %q", fullName)
+	}
+	title, err := request.RequireString("title")
+	if err != nil {
+		return nil, fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	issue := map[string]string{"title": title}
+	if body := request.GetString("body", ""); body != "" {
+		issue["body"] = body
+	}
+	var created struct {
+		Number  int    `json:"number"`
+		HTMLURL string `json:"html_url"`
+	}
+	path := "/repos/" + url.PathEscape(owner) + "/" + url.PathEscape(name) + "/issues"
+	if err := s.api(ctx, http.MethodPost, path, issue, &created); err != nil {
+		return nil, err
+	}
+	issuePath := fmt.Sprintf("%s/%d", path, created.Number)
+	s.undo.Push(ctx, fmt.Sprintf("create issue #%d in %s", created.Number, fullName), func(ctx context.Context) error {
+		closed := map[string]string{"state": "closed", "state_reason": "not_planned"}
+		return s.api(ctx, http.MethodPatch, issuePath, closed, &struct{}{})
+	})
+	return mcp.NewToolResultText(fmt.Sprintf("Created issue #%d: %s", created.Number, created.HTMLURL)), nil
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/wagnerjt/go-mcp/server/pkg/demoserver"
+)
+
+// secretFlags are masked in the effective config.
+var secretFlags = map[string]bool{"admin-token": true, "auth-tokens": true, "oidc-client-secret": true}
+
+// secretKeys are the secrets read with demoserver.LookupConfig, from the
+// environment or -config-dir.
+var secretKeys = []string{
+	"MCP_ADMIN_TOKEN",
+	"MCP_AUTH_TOKENS",
+	"MCP_DEMO_KEY",
+	"MCP_EMBEDDINGS_API_KEY",
+	"MCP_OIDC_CLIENT_SECRET",
+	"MCP_REGISTRY_TOKEN",
+	"MCP_SLACK_WEBHOOK_URL",
+	"MCP_SMTP_PASSWORD",
+	"MCP_SMTP_USERNAME",
+}
+
+type diagnostic struct {
+	level  string
+	name   string
+	detail string
+}
+
+// validateConfig checks the flags and secrets for options that are ignored,
+// conflict or miss a secret, and the config files for errors and unknown
+// keys.
+func validateConfig() []diagnostic {
+	var diagnostics []diagnostic
+	fail := func(name, format string, args ...any) {
+		diagnostics = append(diagnostics, diagnostic{"FAIL", name, fmt.Sprintf(format, args...)})
+	}
+	warn := func(name, format string, args ...any) {
+		diagnostics = append(diagnostics, diagnostic{"WARN", name, fmt.Sprintf(format, args...)})
+	}
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	secret := func(key string) bool {
+		value, _ := demoserver.LookupConfig(key, configDir)
+		return value != ""
+	}
+
+	// conflicting options, of which only one is used
+	if oidcIssuer != "" && (authTokens != "" || authTokensFile != "") {
+		fail("auth", "-oidc-issuer and bearer tokens are both set, only OIDC is used")
+	}
+	if authTokensFile != "" && authTokens != "" {
+		fail("auth", "-auth-tokens-file and -auth-tokens are both set, only the file is used")
+	}
+
+	// ignored options
+	if transport == demoserver.TransportStdio {
+		for _, name := range []string{"auth-tokens", "auth-tokens-file", "oidc-issuer", "forward-auth", "guest-tools", "allow-cidrs", "deny-cidrs", "max-conns-per-ip", "compress", "request-log"} {
+			if set[name] {
+				warn(name, "ignored on the stdio transport")
+			}
+		}
+	}
+	for _, option := range []struct{ name, requires string }{
+		{"oidc-audience", "oidc-issuer"},
+		{"oidc-validation", "oidc-issuer"},
+		{"oidc-introspection-url", "oidc-issuer"},
+		{"oidc-client-id", "oidc-issuer"},
+		{"git-allow-writes", "git-repos"},
+		{"docker-allow-writes", "docker"},
+		{"k8s-kubeconfig", "k8s-namespaces"},
+		{"k8s-context", "k8s-namespaces"},
+		{"smtp-from", "smtp"},
+		{"email-recipients", "smtp"},
+		{"outbox-retention", "outbox"},
+		{"max-queued-calls", "max-concurrent-calls"},
+		{"scheduler-weights", "max-concurrent-calls"},
+		{"sandbox-memory", "sandbox-tools"},
+		{"sandbox-cpu", "sandbox-tools"},
+		{"sandbox-timeout", "sandbox-tools"},
+		{"sandbox-no-new-privs", "sandbox-tools"},
+		{"embeddings-model", "embeddings-url"},
+		{"docs-poll-interval", "docs"},
+		{"demo-rate-limit", "demo"},
+		{"request-log-sample", "request-log"},
+		{"compress-min-size", "compress"},
+		{"compress-max-body", "compress"},
+		{"registry-endpoint", "registry-url"},
+		{"registry-id", "registry-url"},
+		{"registry-heartbeat", "registry-url"},
+	} {
+		requires := flag.Lookup(option.requires)
+		if set[option.name] && requires.Value.String() == requires.DefValue {
+			warn(option.name, "ignored without -%s", option.requires)
+		}
+	}
+
+	// missing secrets
+	if oidcIssuer != "" && oidcValidation == demoserver.ValidationIntrospection && (oidcClientID == "" || oidcClientSecret == "") {
+		fail("oidc", "introspection requires -oidc-client-id and -oidc-client-secret (or MCP_OIDC_CLIENT_SECRET)")
+	}
+	if smtpAddr != "" && secret("MCP_SMTP_USERNAME") != secret("MCP_SMTP_PASSWORD") {
+		fail("smtp", "MCP_SMTP_USERNAME and MCP_SMTP_PASSWORD must be set together")
+	}
+	if smtpAddr != "" && smtpFrom == "" {
+		fail("smtp", "-smtp requires -smtp-from")
+	}
+	if embeddingsURL != "" && !secret("MCP_EMBEDDINGS_API_KEY") {
+		warn("embeddings", "MCP_EMBEDDINGS_API_KEY is not set, the embeddings API is called without a key")
+	}
+	if registryURL != "" && registryEndpoint == "" {
+		fail("registry", "-registry-url requires -registry-endpoint, the public URL of the MCP endpoint")
+	}
+	if demo && !secret("MCP_DEMO_KEY") {
+		warn("demo", "MCP_DEMO_KEY is not set, the watermarks do not verify after a restart")
+	}
+
+	for _, file := range []struct {
+		kind demoserver.ConfigFile
+		path string
+	}{
+		{demoserver.ConfigFilePostProcessors, postProcessorsFile},
+		{demoserver.ConfigFileArgumentRules, argumentRulesFile},
+		{demoserver.ConfigFileClientCatalogs, clientCatalogsFile},
+	} {
+		if file.path == "" {
+			continue
+		}
+		if err := demoserver.ValidateConfigFile(file.kind, file.path); err != nil {
+			fail(string(file.kind), "%v", err)
+		}
+	}
+	return diagnostics
+}
+
+// runConfigValidate prints the diagnostics of validateConfig and of building
+// the server, followed by the effective config with the secrets masked, and
+// returns the exit code, 1 when a check failed.
+func runConfigValidate(w io.Writer, buildErr error) int {
+	diagnostics := validateConfig()
+	if buildErr != nil {
+		diagnostics = append(diagnostics, diagnostic{"FAIL", "server", buildErr.Error()})
+	}
+
+	code := 0
+	for _, d := range diagnostics {
+		if d.level == "FAIL" {
+			code = 1
+		}
+		fmt.Fprintf(w, "%s  %s: %s\n", d.level, d.name, d.detail)
+	}
+	if code == 0 {
+		fmt.Fprintln(w, "ok    config")
+	}
+
+	fmt.Fprintln(w, "\nEffective config:")
+	flag.VisitAll(func(f *flag.Flag) {
+		value := f.Value.String()
+		if secretFlags[f.Name] && value != "" {
+			value = "****"
+		}
+		fmt.Fprintf(w, "  -%s=%s\n", f.Name, value)
+	})
+	for _, key := range secretKeys {
+		value, _ := demoserver.LookupConfig(key, configDir)
+		if value != "" {
+			value = "****"
+		}
+		fmt.Fprintf(w, "  %s=%s\n", key, value)
+	}
+	return code
+}
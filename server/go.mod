@@ -2,10 +2,16 @@ module github.com/wagnerjt/go-mcp/server
 
 go 1.24.1
 
-require github.com/mark3labs/mcp-go v0.31.0
+require (
+	github.com/mark3labs/mcp-go v0.31.0
+	github.com/wagnerjt/go-mcp/shared v0.0.0
+)
 
 require (
 	github.com/google/uuid v1.6.0 // indirect
 	github.com/spf13/cast v1.7.1 // indirect
 	github.com/yosida95/uritemplate/v3 v3.0.2 // indirect
 )
+
+// the JWT validator and the build info are shared with the other modules
+replace github.com/wagnerjt/go-mcp/shared => ../shared
@@ -2,239 +2,508 @@ package main
 
 import (
 	"context"
-	"errors"
 	"flag"
 	"fmt"
 	"log"
-	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
+	// the time tools take IANA timezones on hosts without a tz database too
+	_ "time/tzdata"
 
-	"github.com/mark3labs/mcp-go/mcp"
-	"github.com/mark3labs/mcp-go/server"
+	"github.com/wagnerjt/go-mcp/server/pkg/demoserver"
+	"github.com/wagnerjt/go-mcp/shared/pkg/buildinfo"
+	"github.com/wagnerjt/go-mcp/shared/pkg/healthcheck"
 )
 
 var (
-	transport string
-	port      string
+	version            bool
+	transport          string
+	port               string
+	canaryPercent      int
+	canaryPrincipals   string
+	locale             string
+	localesDir         string
+	toolSets           string
+	authTokens         string
+	authTokensFile     string
+	oidcIssuer         string
+	oidcAudience       string
+	oidcPrincipalClaim string
+	oidcValidation     string
+	oidcIntrospectURL  string
+	oidcClientID       string
+	oidcClientSecret   string
+	oidcIntrospectTTL  time.Duration
+	configDir          string
+	forwardAuth        bool
+	guestTools         string
+	metrics            bool
+	adminToken         string
+	drainTimeout       time.Duration
+	sseHeartbeat       time.Duration
+	ssePing            time.Duration
+	sseWriteTimeout    time.Duration
+	sseProxyCompat     bool
+	debugErrors        bool
+	requestLog         bool
+	requestLogSample   float64
+	captureSessions    string
+	allowCIDRs         string
+	denyCIDRs          string
+	maxConnsPerIP      int
+	blockUserAgents    string
+	trustedProxies     string
+	check              bool
+	validateSpec       bool
+	chunkSize          int
+	historySize        int
+	approvalTimeout    time.Duration
+	policyURL          string
+	demo               bool
+	embeddingsURL      string
+	embeddingsModel    string
+	docsPaths          string
+	docsPollInterval   time.Duration
+	promptsDir         string
+	gitRepos           string
+	gitAllowWrites     bool
+	k8sNamespaces      string
+	k8sKubeconfig      string
+	k8sContext         string
+	docker             bool
+	dockerAllowWrites  bool
+	smtpAddr           string
+	smtpFrom           string
+	emailRecipients    string
+	notifyRateLimit    int
+	outboxFile         string
+	outboxRetention    time.Duration
+	maxConcurrentCalls int
+	maxQueuedCalls     int
+	schedulerWeights   string
+	sandboxTools       string
+	sandboxMemory      uint64
+	sandboxCPU         uint64
+	sandboxTimeout     time.Duration
+	sandboxNoNewPrivs  bool
+	tokenEstimates     bool
+	postProcessorsFile string
+	argumentRulesFile  string
+	featureRules       string
+	clientCatalogsFile string
+	otlpEndpoint       string
+	demoRateLimit      int
+	compress           bool
+	compressMinSize    int
+	compressMaxBody    int64
+	registryURL        string
+	registryEndpoint   string
+	registryID         string
+	registryHeartbeat  time.Duration
 )
 
-type ToolName string
-
-const (
-	ECHO ToolName = "echo"
-	ADD  ToolName = "add"
-	AUTH ToolName = "check_auth"
-)
-
-type authKey struct{}
-
-func withAuthKey(ctx context.Context, auth string) context.Context {
-	return context.WithValue(ctx, authKey{}, auth)
+func splitList(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' })
 }
 
-func authFromRequest(ctx context.Context, r *http.Request) context.Context {
-	return withAuthKey(ctx, r.Header.Get("Authorization"))
-}
-
-// tokenFromContext extracts the auth token from the context.
-// This can be used by tools to extract the token regardless of the
-// transport being used by the server.
-func tokenFromContext(ctx context.Context) (string, error) {
-	auth, ok := ctx.Value(authKey{}).(string)
-	if !ok {
-		return "", fmt.Errorf("missing auth")
-	}
-	return auth, nil
-}
+func main() {
+	// generate-manifest takes the server flags and prints a client configuration
+	generateManifest := len(os.Args) > 1 && os.Args[1] == "generate-manifest"
+	if generateManifest {
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+		registerManifestFlags()
+	}
+	// config validate takes the server flags and reports what is wrong with them
+	configValidate := len(os.Args) > 2 && os.Args[1] == "config" && os.Args[2] == "validate"
+	if configValidate {
+		os.Args = append(os.Args[:1], os.Args[3:]...)
+	}
+	// registry deregister takes the server flags and removes the registration
+	// of the server they describe, i.e. after a crash
+	registryDeregister := len(os.Args) > 2 && os.Args[1] == "registry" && os.Args[2] == "deregister"
+	if registryDeregister {
+		os.Args = append(os.Args[:1], os.Args[3:]...)
+	}
+	// sandbox-helper takes the server flags and runs the sandboxed tool calls
+	// of the server starting it, see -sandbox-tools
+	sandboxHelper := len(os.Args) > 1 && os.Args[1] == "sandbox-helper"
+	if sandboxHelper {
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+	}
+	flag.StringVar(&transport, "t", "sse", "Transport type (stdio, sse, or http)")
+	flag.StringVar(&port, "p", "8080", "Port to listen on")
+	flag.IntVar(&canaryPercent, "canary-percent", 0, "Percentage of principals routed to canary tool implementations")
+	flag.StringVar(&canaryPrincipals, "canary-principals", "", "Comma separated principals always routed to canary tool implementations")
+	flag.StringVar(&locale, "locale", demoserver.DefaultLocale, "Default locale of tool descriptions and error messages")
+	flag.StringVar(&localesDir, "locales-dir", "", "Directory of additional <locale>.json message catalogs")
+	flag.StringVar(&toolSets, "tools", "", "Comma separated tool sets to enable (echo, math, time, auth, notify, rollout, text), defaults to all")
+	flag.StringVar(&authTokens, "auth-tokens", "", "Comma separated bearer tokens required on the network transports")
+	flag.StringVar(&authTokensFile, "auth-tokens-file", "", "File of bearer tokens, one per line, reloaded on SIGHUP")
+	flag.StringVar(&oidcIssuer, "oidc-issuer", "", "Issuer URL of an OIDC provider whose tokens are required on the network transports")
+	flag.StringVar(&oidcAudience, "oidc-audience", "", "Audience required in the OIDC tokens, i.e. the client ID")
+	flag.StringVar(&oidcPrincipalClaim, "oidc-principal-claim", demoserver.DefaultPrincipalClaim, "Claim of the OIDC tokens identifying the caller")
+	flag.StringVar(&oidcValidation, "oidc-validation", demoserver.ValidationJWKS, "Validation of the OIDC tokens: jwks verifies JWTs locally, introspection asks the provider, also for opaque tokens")
+	flag.StringVar(&oidcIntrospectURL, "oidc-introspection-url", "", "RFC 7662 introspection endpoint, discovered from the issuer by default")
+	flag.StringVar(&oidcClientID, "oidc-client-id", "", "Client ID authenticating the server to the introspection endpoint")
+	flag.StringVar(&oidcClientSecret, "oidc-client-secret", "", "Client secret authenticating the server to the introspection endpoint (or MCP_OIDC_CLIENT_SECRET)")
+	flag.DurationVar(&oidcIntrospectTTL, "oidc-introspection-cache", demoserver.DefaultIntrospectionCacheTTL, "How long introspection results are cached")
+	flag.StringVar(&guestTools, "guest-tools", "", "Comma separated tools that requests without credentials may use as guests, e.g. "+strings.Join(demoserver.DefaultGuestTools, ","))
+	flag.BoolVar(&forwardAuth, "forward-auth", false, "Serve /forward-auth validating the bearer tokens of requests forwarded by an API gateway")
+	flag.StringVar(&configDir, "config-dir", "", "Directory of mounted config files, i.e. a Kubernetes projected secret")
+	flag.BoolVar(&metrics, "metrics", false, "Publish tool call metrics on /debug/vars")
+	flag.DurationVar(&approvalTimeout, "approval-timeout", 0, "Park destructive tool calls until approved through the admin API, denying them after this long, 0 disables")
+	flag.StringVar(&policyURL, "policy-url", "", "OPA data API URL deciding on every tool call, i.e. http://localhost:8181/v1/data/mcp/authz (or MCP_POLICY_URL)")
+	flag.StringVar(&embeddingsURL, "embeddings-url", "", "Base URL of an OpenAI-compatible API enabling embed_and_store and semantic_search, i.e. https://api.openai.com/v1 (or MCP_EMBEDDINGS_URL)")
+	flag.StringVar(&embeddingsModel, "embeddings-model", demoserver.DefaultEmbeddingModel, "Embedding model of the semantic search tools")
+	flag.StringVar(&docsPaths, "docs", "", "Comma separated files or directories of PDF, HTML, Markdown and text documents exposed as docs:// resources")
+	flag.DurationVar(&docsPollInterval, "docs-poll-interval", demoserver.DefaultDocumentsPollInterval, "How often the documents are checked for changes, negative for SIGHUP only")
+	flag.StringVar(&promptsDir, "prompts", "", "Directory of Markdown prompt templates with front-matter, reloaded on change")
+	flag.StringVar(&gitRepos, "git-repos", "", "Comma separated git work trees enabling the read-only git tools")
+	flag.BoolVar(&gitAllowWrites, "git-allow-writes", false, "Enable the destructive git_commit and git_branch tools")
+	flag.StringVar(&k8sNamespaces, "k8s-namespaces", "", "Comma separated namespaces enabling the read-only Kubernetes tools, * for all")
+	flag.StringVar(&k8sKubeconfig, "k8s-kubeconfig", "", "Kubeconfig in JSON of the Kubernetes tools, the in-cluster service account by default")
+	flag.StringVar(&k8sContext, "k8s-context", "", "Kubeconfig context of the Kubernetes tools, the current context by default")
+	flag.BoolVar(&docker, "docker", false, "Enable the read-only Docker tools on the engine of DOCKER_HOST, the local socket by default")
+	flag.BoolVar(&dockerAllowWrites, "docker-allow-writes", false, "Enable the destructive docker_start and docker_stop tools")
+	flag.StringVar(&smtpAddr, "smtp", "", "host:port of the SMTP server enabling send_email, authenticated with MCP_SMTP_USERNAME and MCP_SMTP_PASSWORD")
+	flag.StringVar(&smtpFrom, "smtp-from", "", "Sender address of send_email")
+	flag.StringVar(&emailRecipients, "email-recipients", "", "Comma separated addresses and @domains send_email may send to")
+	flag.IntVar(&notifyRateLimit, "notify-rate-limit", demoserver.DefaultNotificationRateLimit, "Messages per hour of send_slack_message and send_email")
+	flag.BoolVar(&tokenEstimates, "token-estimates", false, "Add the estimated LLM tokens of tool results and resource reads to their _meta")
+	flag.StringVar(&postProcessorsFile, "post-processors", "", "JSON file of the strip, project and trim steps applied to the results per tool, re-read on SIGHUP")
+	flag.StringVar(&argumentRulesFile, "argument-rules", "", "JSON file of the argument defaults and type coercions per tool, re-read on SIGHUP")
+	flag.StringVar(&featureRules, "features", "", "Experimental features per client name, i.e. mcp-inspector*=chunkedResults+tokenEstimates,*=tokenEstimates")
+	flag.StringVar(&clientCatalogsFile, "client-catalogs", "", "JSON file of rules tailoring the advertised tools and descriptions by client name, re-read on SIGHUP")
+	flag.StringVar(&otlpEndpoint, "otlp-endpoint", "", "OTLP/HTTP traces endpoint receiving the spans of the MCP requests and tool calls, i.e. http://collector:4318/v1/traces")
+	flag.StringVar(&outboxFile, "outbox", "", "JSON file persisting the approval requests and admin notifications until a session of their principal received them")
+	flag.DurationVar(&outboxRetention, "outbox-retention", demoserver.DefaultOutboxRetention, "How long undelivered notifications are kept in the -outbox")
+	flag.IntVar(&maxConcurrentCalls, "max-concurrent-calls", 0, "Tool calls running at once, the others are queued by principal and run by weighted fair queuing, 0 is unlimited")
+	flag.IntVar(&maxQueuedCalls, "max-queued-calls", 0, "Queued tool calls per principal before further calls are rejected, 0 is unlimited")
+	flag.StringVar(&schedulerWeights, "scheduler-weights", "", "Comma separated principal=weight shares of the queued tool calls, 1 by default")
+	flag.StringVar(&sandboxTools, "sandbox-tools", "", "Comma separated patterns of tools run in a helper process per call, i.e. git_*")
+	flag.Uint64Var(&sandboxMemory, "sandbox-memory", 0, "Address space limit in bytes of the sandbox helpers, 0 is unlimited")
+	flag.Uint64Var(&sandboxCPU, "sandbox-cpu", 0, "CPU time limit in seconds of the sandbox helpers, 0 is unlimited")
+	flag.DurationVar(&sandboxTimeout, "sandbox-timeout", demoserver.DefaultSandboxTimeout, "How long a sandboxed tool call may run before its helper is killed")
+	flag.BoolVar(&sandboxNoNewPrivs, "sandbox-no-new-privs", false, "Keep the sandbox helpers from gaining privileges through setuid binaries")
+	flag.BoolVar(&demo, "demo", false, "Public demo mode: anonymous access to the non-destructive tools, rate limited per IP with abuse bans, and watermarked results")
+	flag.IntVar(&demoRateLimit, "demo-rate-limit", demoserver.DefaultDemoRateLimit, "MCP requests per minute and client IP in demo mode")
+	flag.StringVar(&adminToken, "admin-token", "", "Bearer token enabling the admin API under /admin/")
+	flag.DurationVar(&drainTimeout, "drain-timeout", demoserver.DefaultDrainTimeout, "How long to drain in-flight tool calls and SSE streams on shutdown")
+	flag.DurationVar(&sseHeartbeat, "sse-heartbeat", demoserver.DefaultSSEHeartbeat, "Interval of heartbeat comments on idle SSE streams, 0 disables")
+	flag.DurationVar(&ssePing, "sse-ping", 0, "Interval of MCP ping requests on SSE streams, 0 disables")
+	flag.DurationVar(&sseWriteTimeout, "sse-write-timeout", demoserver.DefaultSSEWriteTimeout, "Close SSE streams of clients that stopped reading for this long, 0 disables")
+	flag.BoolVar(&sseProxyCompat, "sse-proxy-compat", false, "Disable proxy buffering of SSE streams and pad their start")
+	flag.BoolVar(&debugErrors, "debug-errors", false, "Include sanitized internal error details in the JSON-RPC error data")
+	flag.BoolVar(&requestLog, "request-log", false, "Log the MCP and HTTP requests, every error and a sample of the successes")
+	flag.Float64Var(&requestLogSample, "request-log-sample", demoserver.DefaultRequestLogSampleRate, "Share of successful requests logged, between 0 and 1")
+	flag.StringVar(&captureSessions, "capture-sessions", "", "Comma separated session IDs whose request and response bodies are logged")
+	flag.StringVar(&allowCIDRs, "allow-cidrs", "", "Comma separated CIDRs allowed to reach the MCP endpoints, defaults to all")
+	flag.StringVar(&denyCIDRs, "deny-cidrs", "", "Comma separated CIDRs blocked from the MCP endpoints")
+	flag.IntVar(&maxConnsPerIP, "max-conns-per-ip", 0, "Maximum concurrent requests and SSE streams per client IP, 0 is unlimited")
+	flag.StringVar(&blockUserAgents, "block-user-agents", "", "Comma separated user agent substrings to block")
+	flag.StringVar(&trustedProxies, "trusted-proxies", "", "Comma separated CIDRs of proxies whose X-Forwarded-For is trusted")
+	flag.IntVar(&chunkSize, "chunk-size", demoserver.DefaultChunkSize, "Stream tool results with more text than this many bytes as chunks to clients asking for it, 0 disables")
+	flag.IntVar(&historySize, "history-size", demoserver.DefaultHistorySize, "Tool calls per session listed by the history://calls resource, 0 disables it")
+	flag.BoolVar(&compress, "compress", false, "Gzip the streamable HTTP responses of clients accepting it and accept gzip request bodies")
+	flag.IntVar(&compressMinSize, "compress-min-size", demoserver.DefaultCompressionMinSize, "Minimum size in bytes of compressed responses")
+	flag.Int64Var(&compressMaxBody, "compress-max-body", demoserver.DefaultMaxDecompressedBody, "Maximum size in bytes of gzip request bodies once decompressed, larger ones get 413")
+	flag.StringVar(&registryURL, "registry-url", "", "MCP registry API the server registers with while serving, authenticated with MCP_REGISTRY_TOKEN")
+	flag.StringVar(&registryEndpoint, "registry-endpoint", "", "Public URL of the MCP endpoint published to the registry, i.e. https://mcp.example.com/mcp")
+	flag.StringVar(&registryID, "registry-id", "", "ID of the instance in the registry, derived from -registry-endpoint by default")
+	flag.DurationVar(&registryHeartbeat, "registry-heartbeat", demoserver.DefaultRegistryHeartbeat, "How often the registration is renewed, it expires after three missed heartbeats")
+	flag.BoolVar(&validateSpec, "validate-spec", false, "Validate outgoing messages against the MCP schema and log spec violations")
+	flag.BoolVar(&check, "check", false, "Construct the server, self-test its tools and dependencies, print a report and exit")
+	flag.BoolVar(&version, "version", false, "Print the version, commit and build date and exit")
+	flag.Parse()
 
-func NewMCPServer() *server.MCPServer {
-	mcpServer := server.NewMCPServer(
-		"go-mcp/tools",
-		"0.0.1",
-		server.WithToolCapabilities(true),
-		server.WithLogging(),
-	)
+	if version {
+		fmt.Println(buildinfo.Get())
+		return
+	}
+	if sandboxHelper {
+		if err := demoserver.EnterSandbox(); err != nil {
+			log.Fatalf("Failed to enter the sandbox: %v", err)
+		}
+		// the server starting the helper serves the clients, persists the
+		// outbox, schedules and records the calls
+		transport = demoserver.TransportStdio
+		sandboxTools, outboxFile, maxConcurrentCalls = "", "", 0
+		metrics, requestLog, otlpEndpoint = false, false, ""
+		// nor is the helper reachable on a network endpoint to register
+		registryURL, registryEndpoint, registryID = "", "", ""
+	}
 
-	mcpServer.AddTool(mcp.NewTool(string(ECHO),
-		mcp.WithDescription("Echoes back the input"),
-		mcp.WithString("message",
-			mcp.Description("Message to echo"),
-			mcp.Required(),
-		),
-	), handleEchoTool)
+	if authTokens == "" {
+		authTokens, _ = demoserver.LookupConfig("MCP_AUTH_TOKENS", configDir)
+	}
+	if oidcIssuer == "" {
+		oidcIssuer, _ = demoserver.LookupConfig("MCP_OIDC_ISSUER", configDir)
+	}
+	if oidcClientSecret == "" {
+		oidcClientSecret, _ = demoserver.LookupConfig("MCP_OIDC_CLIENT_SECRET", configDir)
+	}
+	if policyURL == "" {
+		policyURL, _ = demoserver.LookupConfig("MCP_POLICY_URL", configDir)
+	}
+	if adminToken == "" {
+		adminToken, _ = demoserver.LookupConfig("MCP_ADMIN_TOKEN", configDir)
+	}
+	demoKey, _ := demoserver.LookupConfig("MCP_DEMO_KEY", configDir)
+	if embeddingsURL == "" {
+		embeddingsURL, _ = demoserver.LookupConfig("MCP_EMBEDDINGS_URL", configDir)
+	}
+	if otlpEndpoint == "" {
+		otlpEndpoint = os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	}
 
-	mcpServer.AddTool(mcp.NewTool("get_current_time",
-		mcp.WithDescription("Get the current time"),
-	), handleCurrentTime)
+	if generateManifest {
+		if err := writeManifest(os.Stdout, os.Stderr); err != nil {
+			log.Fatalf("Failed to generate the manifest: %v", err)
+		}
+		return
+	}
 
-	mcpServer.AddTool(
-		mcp.NewTool("notify"),
-		handleSendNotification,
+	builder := demoserver.NewServerBuilder(
+		demoserver.WithTransport(transport),
+		demoserver.WithCanary(canaryPercent, splitList(canaryPrincipals)...),
+		demoserver.WithLocale(locale),
+		demoserver.WithLocalesDir(localesDir),
+		demoserver.WithAdminToken(adminToken),
+		demoserver.WithDrainTimeout(drainTimeout),
+		demoserver.WithSSEHeartbeat(sseHeartbeat),
+		demoserver.WithSSEPing(ssePing),
+		demoserver.WithSSEWriteTimeout(sseWriteTimeout),
+		demoserver.WithSSEProxyCompat(sseProxyCompat),
+		demoserver.WithDebugErrors(debugErrors),
+		demoserver.WithSpecValidation(validateSpec),
+		demoserver.WithChunkSize(chunkSize),
+		demoserver.WithHistory(historySize),
+		demoserver.WithApprovals(approvalTimeout),
+		demoserver.WithCompression(compress),
+		demoserver.WithCompressionMinSize(compressMinSize),
+		demoserver.WithMaxDecompressedBody(compressMaxBody),
+		demoserver.WithCapturedSessions(splitList(captureSessions)...),
+		demoserver.WithForwardAuth(forwardAuth),
+		demoserver.WithGuestTools(splitList(guestTools)...),
+		demoserver.WithAccessRules(demoserver.AccessRules{
+			Allow:             splitList(allowCIDRs),
+			Deny:              splitList(denyCIDRs),
+			MaxConnsPerIP:     maxConnsPerIP,
+			BlockedUserAgents: strings.FieldsFunc(blockUserAgents, func(r rune) bool { return r == ',' }),
+			TrustedProxies:    splitList(trustedProxies),
+		}),
 	)
-
-	mcpServer.AddTool(mcp.NewTool(string(ADD),
-		mcp.WithDescription("Adds two numbers"),
-		mcp.WithNumber("a",
-			mcp.Description("First number"),
-			mcp.Required(),
-		),
-		mcp.WithNumber("b",
-			mcp.Description("Second number"),
-			mcp.Required(),
-		),
-	), handleAddTool)
-
-	mcpServer.AddTool(mcp.NewTool(string(AUTH),
-		mcp.WithDescription("Checks for auth calls in the header"),
-		mcp.WithString("message",
-			mcp.Description("Message to echo"),
-			mcp.Required(),
-		),
-	), handleAuthTool)
-
-	mcpServer.AddNotificationHandler("notification", handleNotification)
-
-	return mcpServer
-}
-
-func handleEchoTool(
-	ctx context.Context,
-	request mcp.CallToolRequest,
-) (*mcp.CallToolResult, error) {
-	arguments := request.GetArguments()
-	message, ok := arguments["message"].(string)
-	if !ok {
-		return nil, fmt.Errorf("invalid message argument")
-	}
-	return &mcp.CallToolResult{
-		Content: []mcp.Content{
-			mcp.TextContent{
-				Type: "text",
-				Text: fmt.Sprintf("Echo: %s", message),
-			},
-		},
-	}, nil
-}
-
-func handleCurrentTime(
-	ctx context.Context,
-	request mcp.CallToolRequest,
-) (*mcp.CallToolResult, error) {
-	return &mcp.CallToolResult{
-		Content: []mcp.Content{
-			mcp.TextContent{
-				Type: "text",
-				Text: fmt.Sprintf("Time: %s", time.Now().Format(time.RFC3339)),
-			},
-		},
-	}, nil
-}
-
-func handleAddTool(
-	ctx context.Context,
-	request mcp.CallToolRequest,
-) (*mcp.CallToolResult, error) {
-	arguments := request.GetArguments()
-	a, ok1 := arguments["a"].(float64)
-	b, ok2 := arguments["b"].(float64)
-	if !ok1 || !ok2 {
-		return nil, fmt.Errorf("invalid number arguments")
-	}
-	sum := a + b
-	return &mcp.CallToolResult{
-		Content: []mcp.Content{
-			mcp.TextContent{
-				Type: "text",
-				Text: fmt.Sprintf("The sum of %f and %f is %f.", a, b, sum),
+	if toolSets != "" {
+		var sets []demoserver.ToolSet
+		for _, set := range splitList(toolSets) {
+			sets = append(sets, demoserver.ToolSet(set))
+		}
+		builder.With(demoserver.WithTools(sets...))
+	}
+	if oidcIssuer != "" {
+		builder.With(demoserver.WithOIDC(demoserver.OIDCConfig{
+			Issuer:         oidcIssuer,
+			Audience:       oidcAudience,
+			PrincipalClaim: oidcPrincipalClaim,
+
+			Validation:            oidcValidation,
+			IntrospectionURL:      oidcIntrospectURL,
+			ClientID:              oidcClientID,
+			ClientSecret:          oidcClientSecret,
+			IntrospectionCacheTTL: oidcIntrospectTTL,
+		}))
+	} else if authTokensFile != "" {
+		builder.With(demoserver.WithAuthTokensFile(authTokensFile))
+	} else if authTokens != "" {
+		builder.With(demoserver.WithAuth(demoserver.BearerAuth(splitList(authTokens)...)))
+	}
+	if policyURL != "" {
+		builder.With(demoserver.WithPolicy(demoserver.PolicyConfig{URL: policyURL}))
+	}
+	if embeddingsURL != "" {
+		apiKey, _ := demoserver.LookupConfig("MCP_EMBEDDINGS_API_KEY", configDir)
+		builder.With(demoserver.WithVectorSearch(demoserver.VectorSearchConfig{
+			Embedder: demoserver.NewOpenAIEmbedder(embeddingsURL, embeddingsModel, apiKey),
+		}))
+	}
+	if docsPaths != "" {
+		builder.With(demoserver.WithDocuments(demoserver.DocumentsConfig{
+			Paths:        splitList(docsPaths),
+			PollInterval: docsPollInterval,
+		}))
+	}
+	if promptsDir != "" {
+		builder.With(demoserver.WithPrompts(demoserver.PromptsConfig{Dir: promptsDir}))
+	}
+	if gitRepos != "" {
+		builder.With(demoserver.WithGit(demoserver.GitConfig{
+			Repositories: splitList(gitRepos),
+			AllowWrites:  gitAllowWrites,
+		}))
+	}
+	if k8sNamespaces != "" {
+		builder.With(demoserver.WithKubernetes(demoserver.KubernetesConfig{
+			Kubeconfig: k8sKubeconfig,
+			Context:    k8sContext,
+			Namespaces: splitList(k8sNamespaces),
+		}))
+	}
+	if docker {
+		builder.With(demoserver.WithDocker(demoserver.DockerConfig{
+			Host:        os.Getenv("DOCKER_HOST"),
+			AllowWrites: dockerAllowWrites,
+		}))
+	}
+	notifications := demoserver.NotificationConfig{
+		EmailRecipients: splitList(emailRecipients),
+		RateLimit:       notifyRateLimit,
+	}
+	if webhookURL, ok := demoserver.LookupConfig("MCP_SLACK_WEBHOOK_URL", configDir); ok && webhookURL != "" {
+		notifications.Slack = &demoserver.SlackChannel{WebhookURL: webhookURL}
+	}
+	if smtpAddr != "" {
+		username, _ := demoserver.LookupConfig("MCP_SMTP_USERNAME", configDir)
+		password, _ := demoserver.LookupConfig("MCP_SMTP_PASSWORD", configDir)
+		notifications.Email = &demoserver.EmailChannel{Addr: smtpAddr, Username: username, Password: password, From: smtpFrom}
+	}
+	if notifications.Slack != nil || notifications.Email != nil {
+		builder.With(demoserver.WithNotifications(notifications))
+	}
+	if outboxFile != "" {
+		builder.With(demoserver.WithOutbox(demoserver.OutboxConfig{Path: outboxFile, Retention: outboxRetention}))
+	}
+	if maxConcurrentCalls > 0 {
+		weights := make(map[string]int)
+		for _, entry := range splitList(schedulerWeights) {
+			principal, weight, ok := strings.Cut(entry, "=")
+			n, err := strconv.Atoi(weight)
+			if !ok || err != nil || n < 1 {
+				log.Fatalf("Invalid -scheduler-weights entry %q, want principal=weight", entry)
+			}
+			weights[principal] = n
+		}
+		builder.With(demoserver.WithFairScheduling(demoserver.SchedulerConfig{
+			MaxConcurrent: maxConcurrentCalls,
+			MaxQueued:     maxQueuedCalls,
+			Weights:       weights,
+		}))
+	}
+	if sandboxTools != "" {
+		executable, err := os.Executable()
+		if err != nil {
+			log.Fatalf("Failed to locate the sandbox helper: %v", err)
+		}
+		builder.With(demoserver.WithSandbox(demoserver.SandboxConfig{
+			Tools:   splitList(sandboxTools),
+			Command: append([]string{executable, "sandbox-helper"}, os.Args[1:]...),
+			Limits: demoserver.SandboxLimits{
+				MemoryBytes:     sandboxMemory,
+				CPUSeconds:      sandboxCPU,
+				NoNewPrivileges: sandboxNoNewPrivs,
 			},
-		},
-	}, nil
-}
-
-func handleAuthTool(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	message, ok := request.GetArguments()["message"].(string)
-	if !ok {
-		return nil, fmt.Errorf("missing required message")
+			Timeout: sandboxTimeout,
+		}))
 	}
-
-	token, err := tokenFromContext(ctx)
-	if err != nil {
-		return nil, err
+	if sandboxHelper {
+		builder.With(demoserver.WithSandboxHelper(os.Getenv(demoserver.SandboxToolEnv)))
 	}
-
-	if strings.HasPrefix(token, "Bearer ") && strings.Split(token, "Bearer ")[1] == "sk-1234" {
-
-	} else {
-		return nil, errors.New("token not correct")
+	if tokenEstimates {
+		builder.With(demoserver.WithTokenEstimates(nil))
+	}
+	if postProcessorsFile != "" {
+		builder.With(demoserver.WithPostProcessorsFile(postProcessorsFile))
+	}
+	if argumentRulesFile != "" {
+		builder.With(demoserver.WithArgumentRulesFile(argumentRulesFile))
+	}
+	if featureRules != "" {
+		rules, err := demoserver.ParseFeatureRules(featureRules)
+		if err != nil {
+			log.Fatalf("Invalid -features: %v", err)
+		}
+		builder.With(demoserver.WithFeatureRules(rules...))
+	}
+	if clientCatalogsFile != "" {
+		builder.With(demoserver.WithClientCatalogFile(clientCatalogsFile))
+	}
+	if otlpEndpoint != "" {
+		builder.With(demoserver.WithTracing(demoserver.TracingConfig{
+			Exporter: &demoserver.OTLPExporter{Endpoint: otlpEndpoint, ServiceName: os.Getenv("OTEL_SERVICE_NAME")},
+		}))
+	}
+	if demo {
+		builder.With(demoserver.WithDemoMode(demoserver.DemoConfig{
+			RateLimit:    demoRateLimit,
+			WatermarkKey: []byte(demoKey),
+		}))
+	}
+	if requestLog {
+		builder.With(demoserver.WithRequestLog(requestLogSample))
+	}
+	if metrics {
+		builder.With(demoserver.WithMetrics(demoserver.NewExpvarMetrics("demoserver")))
+	}
+	if registryURL != "" {
+		registryToken, _ := demoserver.LookupConfig("MCP_REGISTRY_TOKEN", configDir)
+		builder.With(demoserver.WithRegistry(demoserver.RegistryConfig{
+			URL:       registryURL,
+			Token:     registryToken,
+			Endpoint:  registryEndpoint,
+			ID:        registryID,
+			Heartbeat: registryHeartbeat,
+		}))
 	}
 
-	return mcp.NewToolResultText(fmt.Sprintf("Echoing %s with auth successful", message)), nil
-}
-
-func handleSendNotification(
-	ctx context.Context,
-	request mcp.CallToolRequest,
-) (*mcp.CallToolResult, error) {
-
-	server := server.ServerFromContext(ctx)
-
-	err := server.SendNotificationToClient(
-		ctx,
-		"notifications/progress",
-		map[string]interface{}{
-			"progress":      10,
-			"total":         10,
-			"progressToken": 0,
-		},
-	)
+	mcpServer, _, err := builder.Build()
+	if configValidate {
+		os.Exit(runConfigValidate(os.Stdout, err))
+	}
+	if check {
+		os.Exit(healthcheck.Run(context.Background(), os.Stdout, err, mcpServer.Check))
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to send notification: %w", err)
+		log.Fatalf("Failed to create server: %v", err)
 	}
-
-	return &mcp.CallToolResult{
-		Content: []mcp.Content{
-			mcp.TextContent{
-				Type: "text",
-				Text: "notification sent successfully",
-			},
-		},
-	}, nil
-}
-
-func handleNotification(
-	ctx context.Context,
-	notification mcp.JSONRPCNotification,
-) {
-	log.Printf("Received notification: %s", notification.Method)
-}
-
-func main() {
-	flag.StringVar(&transport, "t", "sse", "Transport type (stdio, sse, or http)")
-	flag.StringVar(&port, "p", "8080", "Port to listen on")
-	flag.Parse()
-
-	mcpServer := NewMCPServer()
-
-	// Only check for "sse" since stdio is the default
-	if transport == "sse" {
-		sseServer := server.NewSSEServer(mcpServer, server.WithSSEContextFunc(authFromRequest))
-		log.Printf("SSE server listening on port %s", port)
-		if err := sseServer.Start(":" + port); err != nil {
-			log.Fatalf("Server error: %v", err)
+	if registryDeregister {
+		if registryURL == "" {
+			log.Fatalf("registry deregister requires -registry-url")
+		}
+		if err := mcpServer.Deregister(context.Background()); err != nil {
+			log.Fatalf("Failed to deregister: %v", err)
 		}
-	} else if transport == "http" {
-		httpServer := server.NewStreamableHTTPServer(mcpServer, server.WithHTTPContextFunc(authFromRequest))
-		log.Printf("HTTP server listening on port %s", port)
-		if err := httpServer.Start(":" + port); err != nil {
-			log.Fatalf("Server error: %v", err)
+		return
+	}
+
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go func() {
+		for range reload {
+			if err := mcpServer.Reload(); err != nil {
+				log.Printf("Reload failed: %v", err)
+			}
 		}
-	} else if transport == "stdio" {
-		if err := server.ServeStdio(mcpServer); err != nil {
-			log.Fatalf("Server error: %v", err)
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, os.Interrupt)
+	stopped := make(chan struct{})
+	go func() {
+		<-stop
+		log.Printf("Draining before shutdown")
+		if err := mcpServer.Shutdown(context.Background()); err != nil {
+			log.Printf("Shutdown error: %v", err)
 		}
-	} else {
-		log.Fatalf("Unsupported transport type: %s", transport)
-		panic("Unsupported transport type")
+		close(stopped)
+	}()
+
+	if err := mcpServer.Serve(port); err != nil {
+		log.Fatalf("Server error: %v", err)
+	}
+	if transport != demoserver.TransportStdio {
+		<-stopped
 	}
 }
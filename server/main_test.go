@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// runMainEnv makes the test binary run main, so tests can start it as the
+// server or its helpers.
+const runMainEnv = "GO_MCP_TEST_RUN_MAIN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(runMainEnv) != "" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// TestSandboxHelperSkipsRegistry starts the sandbox helper with the flags of
+// a server registering with a registry: the helper serves on stdio and must
+// neither fail on nor use the registry flags.
+func TestSandboxHelperSkipsRegistry(t *testing.T) {
+	var registryCalls atomic.Int32
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		registryCalls.Add(1)
+	}))
+	defer registry.Close()
+
+	cmd := exec.Command(os.Args[0], "sandbox-helper", "-t", "http", "-sandbox-tools", "echo*",
+		"-registry-url", registry.URL, "-registry-endpoint", "https://mcp.example.com/mcp")
+	cmd.Env = append(os.Environ(), runMainEnv+"=1")
+	cmd.Stdin = strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26","capabilities":{},"clientInfo":{"name":"test","version":"1"}}}
+{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"echo","arguments":{"message":"sandboxed"}}}
+`)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("sandbox helper failed: %v\n%s", err, output)
+	}
+	if !strings.Contains(string(output), `"id":2`) || !strings.Contains(string(output), "sandboxed") {
+		t.Errorf("sandbox helper answered %s, want the echo result", output)
+	}
+	if n := registryCalls.Load(); n != 0 {
+		t.Errorf("sandbox helper called the registry %d times", n)
+	}
+}
+
+// TestManifestLeavesSecretsOut generates the manifests of a server with
+// secret flags, which must neither show up in the args nor in the env.
+func TestManifestLeavesSecretsOut(t *testing.T) {
+	secrets := []string{"-auth-tokens", "s3cret-token", "-admin-token", "s3cret-admin", "-oidc-client-secret", "s3cret-client"}
+	for _, args := range [][]string{
+		{"-format", "claude-desktop", "-t", "stdio"},
+		{"-format", "vscode", "-t", "stdio"},
+		{"-format", "claude-desktop", "-t", "http"},
+		{"-format", "vscode", "-t", "http"},
+	} {
+		cmd := exec.Command(os.Args[0], append(append([]string{"generate-manifest", "-command", "go-mcp"}, args...), secrets...)...)
+		cmd.Env = append(os.Environ(), runMainEnv+"=1")
+		var stderr strings.Builder
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("%v: %v: %s", args, err, stderr.String())
+		}
+		for _, secret := range []string{"s3cret-token", "s3cret-admin", "s3cret-client"} {
+			if strings.Contains(string(out), secret) {
+				t.Errorf("%v: the manifest contains %s:\n%s", args, secret, out)
+			}
+		}
+		if args[1] == "claude-desktop" && !strings.Contains(stderr.String(), "Replace the placeholder") {
+			t.Errorf("%v: no note about the placeholders: %s", args, stderr.String())
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/wagnerjt/go-mcp/server/pkg/demoserver"
+)
+
+const (
+	manifestClaudeDesktop string = "claude-desktop"
+	manifestVSCode        string = "vscode"
+
+	// manifestTokenInput is the VS Code input prompting for the bearer token,
+	// so it is not stored in the settings.
+	manifestTokenInput = "go-mcp-token"
+)
+
+var (
+	manifestFormat  string
+	manifestName    string
+	manifestHost    string
+	manifestCommand string
+)
+
+// manifestFlags are the flags of generate-manifest, the other flags describe
+// the server the snippet connects to.
+var manifestFlags = map[string]bool{"format": true, "name": true, "host": true, "command": true}
+
+// manifestSecretFlags are never copied into the snippet, which ends up in
+// settings files and dotfile repositories: VS Code prompts for them, the
+// other formats get a placeholder to fill in.
+var manifestSecretFlags = map[string]bool{"auth-tokens": true, "admin-token": true, "oidc-client-secret": true}
+
+// manifestPlaceholder stands for the value of a secret flag or token.
+func manifestPlaceholder(name string) string {
+	return "<" + name + ">"
+}
+
+// passwordInput is a VS Code input prompting for a secret.
+func passwordInput(id, description string) map[string]any {
+	return map[string]any{"type": "promptString", "id": id, "description": description, "password": true}
+}
+
+func registerManifestFlags() {
+	flag.StringVar(&manifestFormat, "format", manifestClaudeDesktop, "Snippet format (claude-desktop or vscode)")
+	flag.StringVar(&manifestName, "name", "go-mcp", "Name of the server in the client configuration")
+	flag.StringVar(&manifestHost, "host", "localhost", "Host clients reach the network transports on")
+	flag.StringVar(&manifestCommand, "command", "", "Command starting the server in stdio mode, defaults to this executable")
+}
+
+// writeManifest prints the client configuration of the server described by
+// the flags: the command and args in stdio mode, the URL and auth header of
+// the network transports otherwise. The secrets left out of it are listed on
+// notes.
+func writeManifest(w, notes io.Writer) error {
+	var entry map[string]any
+	var inputs []map[string]any
+	var placeholders []string
+	switch transport {
+	case demoserver.TransportStdio:
+		command := manifestCommand
+		if command == "" {
+			executable, err := os.Executable()
+			if err != nil {
+				return fmt.Errorf("failed to find the executable, set -command: %w", err)
+			}
+			command = executable
+		}
+		var args []string
+		flag.Visit(func(f *flag.Flag) {
+			switch {
+			case manifestFlags[f.Name]:
+			case manifestSecretFlags[f.Name] && manifestFormat == manifestVSCode:
+				id := manifestName + "-" + f.Name
+				args = append(args, fmt.Sprintf("-%s=${input:%s}", f.Name, id))
+				inputs = append(inputs, passwordInput(id, fmt.Sprintf("-%s of %s", f.Name, manifestName)))
+			case manifestSecretFlags[f.Name]:
+				args = append(args, fmt.Sprintf("-%s=%s", f.Name, manifestPlaceholder(f.Name)))
+				placeholders = append(placeholders, "-"+f.Name)
+			default:
+				args = append(args, fmt.Sprintf("-%s=%s", f.Name, f.Value))
+			}
+		})
+		entry = map[string]any{"command": command, "args": args}
+		if manifestFormat == manifestVSCode {
+			entry["type"] = "stdio"
+		}
+	case demoserver.TransportSSE, demoserver.TransportHTTP:
+		url := fmt.Sprintf("http://%s:%s/mcp", manifestHost, port)
+		if transport == demoserver.TransportSSE {
+			url = fmt.Sprintf("http://%s:%s/sse", manifestHost, port)
+		}
+		authenticated := authTokens != "" || authTokensFile != "" || oidcIssuer != ""
+
+		switch manifestFormat {
+		case manifestClaudeDesktop:
+			// Claude Desktop only starts local servers, mcp-remote bridges them
+			args := []string{"mcp-remote", url}
+			entry = map[string]any{"command": "npx", "args": args}
+			if authenticated {
+				entry["args"] = append(args, "--header", "Authorization:${AUTH_HEADER}")
+				entry["env"] = map[string]string{"AUTH_HEADER": "Bearer " + manifestPlaceholder("token")}
+				placeholders = append(placeholders, "the bearer token of AUTH_HEADER")
+			}
+		case manifestVSCode:
+			entry = map[string]any{"type": transport, "url": url}
+			if authenticated {
+				entry["headers"] = map[string]string{"Authorization": "Bearer ${input:" + manifestTokenInput + "}"}
+				inputs = append(inputs, passwordInput(manifestTokenInput, "Bearer token of "+manifestName))
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported transport type: %s", transport)
+	}
+
+	var manifest map[string]any
+	switch manifestFormat {
+	case manifestClaudeDesktop:
+		manifest = map[string]any{"mcpServers": map[string]any{manifestName: entry}}
+	case manifestVSCode:
+		manifest = map[string]any{"servers": map[string]any{manifestName: entry}}
+		if len(inputs) > 0 {
+			manifest["inputs"] = inputs
+		}
+	default:
+		return fmt.Errorf("unsupported manifest format %q, want %s or %s", manifestFormat, manifestClaudeDesktop, manifestVSCode)
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(manifest); err != nil {
+		return err
+	}
+	for _, placeholder := range placeholders {
+		fmt.Fprintf(notes, "Replace the placeholder of %s with its value, the manifest leaves the secrets out\n", placeholder)
+	}
+	return nil
+}
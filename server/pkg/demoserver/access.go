@@ -0,0 +1,245 @@
+package demoserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// AccessRules are the network level access controls applied to the MCP
+// endpoints before auth.
+type AccessRules struct {
+	// Allow only lets the listed CIDRs in when not empty.
+	Allow []string `json:"allow,omitempty"`
+	// Deny blocks the listed CIDRs, it wins over Allow.
+	Deny []string `json:"deny,omitempty"`
+	// MaxConnsPerIP caps the concurrent requests, SSE streams included, of a
+	// client IP. Zero is unlimited.
+	MaxConnsPerIP int `json:"maxConnsPerIp,omitempty"`
+	// BlockedUserAgents blocks user agents containing any of the substrings,
+	// case insensitive.
+	BlockedUserAgents []string `json:"blockedUserAgents,omitempty"`
+	// TrustedProxies are the CIDRs of the proxies whose X-Forwarded-For is
+	// used to find the client IP.
+	TrustedProxies []string `json:"trustedProxies,omitempty"`
+}
+
+// WithAccessRules applies rules to the MCP endpoints, they can be replaced at
+// runtime through the admin API.
+func WithAccessRules(rules AccessRules) Option {
+	return func(s *Server) {
+		s.accessRules = rules
+	}
+}
+
+type compiledAccessRules struct {
+	rules          AccessRules
+	allow          []*net.IPNet
+	deny           []*net.IPNet
+	trustedProxies []*net.IPNet
+	userAgents     []string
+}
+
+func compileAccessRules(rules AccessRules) (*compiledAccessRules, error) {
+	c := &compiledAccessRules{rules: rules}
+	var err error
+	if c.allow, err = parseCIDRs(rules.Allow); err != nil {
+		return nil, fmt.Errorf("invalid allow list: %w", err)
+	}
+	if c.deny, err = parseCIDRs(rules.Deny); err != nil {
+		return nil, fmt.Errorf("invalid deny list: %w", err)
+	}
+	if c.trustedProxies, err = parseCIDRs(rules.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+	if rules.MaxConnsPerIP < 0 {
+		return nil, fmt.Errorf("invalid max connections per IP: %d", rules.MaxConnsPerIP)
+	}
+	for _, agent := range rules.BlockedUserAgents {
+		if agent = strings.TrimSpace(agent); agent != "" {
+			c.userAgents = append(c.userAgents, strings.ToLower(agent))
+		}
+	}
+	return c, nil
+}
+
+// parseCIDRs parses CIDRs, a bare IP is a single address.
+func parseCIDRs(values []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP %q", value)
+			}
+			bits := 8 * len(ip.To16())
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP is the remote address, or for trusted proxies the last address of
+// X-Forwarded-For that is not a trusted proxy.
+func (c *compiledAccessRules) clientIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !containsIP(c.trustedProxies, ip) {
+		return ip
+	}
+	// proxies may append their hop as another header rather than to the last one
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			break
+		}
+		ip = hop
+		if !containsIP(c.trustedProxies, hop) {
+			break
+		}
+	}
+	return ip
+}
+
+// check returns why the request is blocked, or an empty string.
+func (c *compiledAccessRules) check(ip net.IP, userAgent string) string {
+	if ip == nil {
+		if len(c.allow) > 0 {
+			return "unknown client IP"
+		}
+	} else {
+		if containsIP(c.deny, ip) {
+			return "denied IP"
+		}
+		if len(c.allow) > 0 && !containsIP(c.allow, ip) {
+			return "IP not allowed"
+		}
+	}
+	userAgent = strings.ToLower(userAgent)
+	for _, blocked := range c.userAgents {
+		if strings.Contains(userAgent, blocked) {
+			return "blocked user agent"
+		}
+	}
+	return ""
+}
+
+// accessControl holds the active rules and the open connections per IP.
+type accessControl struct {
+	rules atomic.Pointer[compiledAccessRules]
+
+	mu    sync.Mutex
+	conns map[string]int
+}
+
+func (a *accessControl) acquire(ip string, max int) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.conns == nil {
+		a.conns = make(map[string]int)
+	}
+	if max > 0 && a.conns[ip] >= max {
+		return false
+	}
+	a.conns[ip]++
+	return true
+}
+
+func (a *accessControl) release(ip string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.conns[ip]--; a.conns[ip] <= 0 {
+		delete(a.conns, ip)
+	}
+}
+
+// SetAccessRules replaces the access rules at runtime.
+func (s *Server) SetAccessRules(rules AccessRules) error {
+	compiled, err := compileAccessRules(rules)
+	if err != nil {
+		return err
+	}
+	s.access.rules.Store(compiled)
+	return nil
+}
+
+// AccessRules returns the active access rules.
+func (s *Server) AccessRules() AccessRules {
+	return s.access.rules.Load().rules
+}
+
+func (s *Server) accessMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rules := s.access.rules.Load()
+		ip := rules.clientIP(r)
+		if reason := rules.check(ip, r.UserAgent()); reason != "" {
+			log.Printf("Blocked %s %s from %s: %s", r.Method, r.URL.Path, ip, reason)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		key := ip.String()
+		if !s.access.acquire(key, rules.rules.MaxConnsPerIP) {
+			log.Printf("Blocked %s %s from %s: too many connections", r.Method, r.URL.Path, ip)
+			http.Error(w, "Too many connections", http.StatusTooManyRequests)
+			return
+		}
+		defer s.access.release(key)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleAdminAccess returns the access rules on GET and replaces them with the
+// JSON body on PUT.
+func (s *Server) handleAdminAccess(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var rules AccessRules
+		if err := json.NewDecoder(r.Body).Decode(&rules); err != nil {
+			http.Error(w, fmt.Sprintf("invalid rules: %v", err), http.StatusBadRequest)
+			return
+		}
+		if err := s.SetAccessRules(rules); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("Access rules replaced: %d allowed and %d denied CIDRs, %d connections per IP, %d blocked user agents",
+			len(rules.Allow), len(rules.Deny), rules.MaxConnsPerIP, len(rules.BlockedUserAgents))
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.AccessRules())
+}
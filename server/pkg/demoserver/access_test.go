@@ -0,0 +1,87 @@
+package demoserver
+
+import (
+	"net"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	rules, err := compileAccessRules(AccessRules{TrustedProxies: []string{"10.0.0.0/8", "192.168.1.1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		remote    string
+		forwarded []string
+		want      string
+	}{
+		{"direct", "203.0.113.7:1234", nil, "203.0.113.7"},
+		{"untrusted remote ignores the header", "203.0.113.7:1234", []string{"198.51.100.1"}, "203.0.113.7"},
+		{"trusted proxy", "10.0.0.1:1234", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"chain of trusted proxies", "10.0.0.1:1234", []string{"198.51.100.1, 192.168.1.1, 10.0.0.2"}, "198.51.100.1"},
+		{"spoofed first hop", "10.0.0.1:1234", []string{"1.2.3.4, 198.51.100.1"}, "198.51.100.1"},
+		{"hops across headers", "10.0.0.1:1234", []string{"1.2.3.4, 198.51.100.1", "10.0.0.2"}, "198.51.100.1"},
+		{"invalid hop stops the walk", "10.0.0.1:1234", []string{"198.51.100.1, garbage, 10.0.0.2"}, "10.0.0.2"},
+		{"only trusted proxies", "10.0.0.1:1234", []string{"10.0.0.3, 10.0.0.2"}, "10.0.0.3"},
+		{"trusted proxy without header", "10.0.0.1:1234", nil, "10.0.0.1"},
+		{"remote without port", "203.0.113.7", nil, "203.0.113.7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/mcp", nil)
+			r.RemoteAddr = tt.remote
+			for _, value := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+			if got := rules.clientIP(r); got.String() != tt.want {
+				t.Errorf("clientIP() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAccessCheck(t *testing.T) {
+	tests := []struct {
+		name      string
+		rules     AccessRules
+		ip        string
+		userAgent string
+		want      string
+	}{
+		{"no rules", AccessRules{}, "203.0.113.7", "curl", ""},
+		{"allowed", AccessRules{Allow: []string{"203.0.113.0/24"}}, "203.0.113.7", "", ""},
+		{"not allowed", AccessRules{Allow: []string{"203.0.113.0/24"}}, "198.51.100.1", "", "IP not allowed"},
+		{"denied", AccessRules{Deny: []string{"203.0.113.7"}}, "203.0.113.7", "", "denied IP"},
+		{"deny wins over allow", AccessRules{Allow: []string{"203.0.113.0/24"}, Deny: []string{"203.0.113.7"}}, "203.0.113.7", "", "denied IP"},
+		{"ipv6 allowed", AccessRules{Allow: []string{"2001:db8::/32"}}, "2001:db8::1", "", ""},
+		{"unknown IP with allow list", AccessRules{Allow: []string{"203.0.113.0/24"}}, "", "", "unknown client IP"},
+		{"unknown IP without allow list", AccessRules{Deny: []string{"203.0.113.7"}}, "", "", ""},
+		{"blocked user agent", AccessRules{BlockedUserAgents: []string{" BadBot "}}, "203.0.113.7", "Mozilla badbot/1.0", "blocked user agent"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := compileAccessRules(tt.rules)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := rules.check(net.ParseIP(tt.ip), tt.userAgent); got != tt.want {
+				t.Errorf("check() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileAccessRulesErrors(t *testing.T) {
+	for _, rules := range []AccessRules{
+		{Allow: []string{"not-an-ip"}},
+		{Deny: []string{"10.0.0.0/33"}},
+		{TrustedProxies: []string{"10.0.0"}},
+		{MaxConnsPerIP: -1},
+	} {
+		if _, err := compileAccessRules(rules); err == nil {
+			t.Errorf("compileAccessRules(%+v) succeeded", rules)
+		}
+	}
+}
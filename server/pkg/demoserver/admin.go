@@ -0,0 +1,42 @@
+package demoserver
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// WithAdminToken enables the admin API under /admin/, guarded by the given
+// bearer token. The admin API is disabled without a token.
+func WithAdminToken(token string) Option {
+	return func(s *Server) {
+		s.adminToken = token
+	}
+}
+
+// adminHandler serves the admin API.
+func (s *Server) adminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/admin/maintenance", s.handleAdminMaintenance)
+	mux.HandleFunc("/admin/capture", s.handleAdminCapture)
+	mux.HandleFunc("/admin/access", s.handleAdminAccess)
+	mux.HandleFunc("/admin/approvals", s.handleAdminApprovals)
+	mux.HandleFunc("/admin/outbox", s.handleAdminOutbox)
+	mux.HandleFunc("/admin/bans", s.handleAdminBans)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) != 1 {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
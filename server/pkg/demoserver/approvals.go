@@ -0,0 +1,193 @@
+package demoserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	// ApprovalNotification tells the client a call was parked until an
+	// admin decides on it.
+	ApprovalNotification = "notifications/approval_required"
+
+	// DefaultApprovalTimeout is how long a parked call waits for a decision
+	// before it is denied.
+	DefaultApprovalTimeout = 5 * time.Minute
+)
+
+// WithApprovals parks the calls of destructive tools until they are approved
+// or denied through the admin API, denying them after timeout. Tools are
+// destructive unless annotated read-only or non-destructive, as in the MCP
+// spec. 0 disables approvals.
+func WithApprovals(timeout time.Duration) Option {
+	return func(s *Server) {
+		s.approvals.timeout = timeout
+	}
+}
+
+// Approval is a parked tool call.
+type Approval struct {
+	ID        string         `json:"id"`
+	Tool      string         `json:"tool"`
+	Arguments map[string]any `json:"arguments,omitempty"`
+	SessionID string         `json:"sessionId,omitempty"`
+	Created   time.Time      `json:"created"`
+
+	decision chan bool
+}
+
+// approvals holds the parked calls by approval ID.
+type approvals struct {
+	timeout time.Duration
+
+	mu      sync.Mutex
+	pending map[string]*Approval
+}
+
+func (a *approvals) park(approval *Approval) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.pending == nil {
+		a.pending = make(map[string]*Approval)
+	}
+	a.pending[approval.ID] = approval
+}
+
+func (a *approvals) remove(id string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.pending, id)
+}
+
+// decide hands the decision to the parked call, false when it is unknown
+// or was already decided.
+func (a *approvals) decide(id string, approve bool) bool {
+	a.mu.Lock()
+	approval, ok := a.pending[id]
+	delete(a.pending, id)
+	a.mu.Unlock()
+	if !ok {
+		return false
+	}
+	approval.decision <- approve
+	return true
+}
+
+func (a *approvals) list() []Approval {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	list := make([]Approval, 0, len(a.pending))
+	for _, approval := range a.pending {
+		list = append(list, *approval)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Created.Before(list[j].Created) })
+	return list
+}
+
+func isDestructive(tool mcp.Tool) bool {
+	annotations := tool.Annotations
+	if annotations.ReadOnlyHint != nil && *annotations.ReadOnlyHint {
+		return false
+	}
+	return annotations.DestructiveHint == nil || *annotations.DestructiveHint
+}
+
+// toolIsDestructive looks name up in the registered tools, the lists of the
+// sessions are filtered. Unknown tools were added to the MCP server directly
+// and are destructive, as unannotated tools.
+func (s *Server) toolIsDestructive(name string) bool {
+	tool, ok := s.registeredTool(name)
+	return !ok || isDestructive(tool)
+}
+
+// approvalMiddleware parks the calls of destructive tools and runs them once
+// approved.
+func (s *Server) approvalMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	if s.approvals.timeout <= 0 {
+		return next
+	}
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if !s.toolIsDestructive(request.Params.Name) {
+			return next(ctx, request)
+		}
+
+		approval := &Approval{
+			ID:        newCorrelationID(),
+			Tool:      request.Params.Name,
+			Arguments: redactArguments(request.GetArguments()),
+			SessionID: sessionIDFromContext(ctx),
+			Created:   s.clock(),
+			decision:  make(chan bool, 1),
+		}
+		s.approvals.park(approval)
+		defer s.approvals.remove(approval.ID)
+		log.Printf("Tool %s parked for approval %s", approval.Tool, approval.ID)
+		// with an outbox the other sessions of the caller learn about it too
+		principal, _ := PrincipalFromContext(ctx)
+		expires := approval.Created.Add(s.approvals.timeout)
+		err := s.Notify(ctx, OutboxMessage{
+			Recipient: principal.Name,
+			Method:    ApprovalNotification,
+			Params: map[string]any{
+				"approvalId": approval.ID,
+				"tool":       approval.Tool,
+				"expires":    expires,
+			},
+			Expires: expires,
+		})
+		if err != nil {
+			log.Printf("Failed to notify about approval %s: %v", approval.ID, err)
+		}
+
+		timer := time.NewTimer(s.approvals.timeout)
+		defer timer.Stop()
+		select {
+		case approved := <-approval.decision:
+			if !approved {
+				log.Printf("Approval %s denied", approval.ID)
+				return nil, localizedError(ctx, "error.approval_denied", approval.Tool)
+			}
+			log.Printf("Approval %s granted", approval.ID)
+			return next(ctx, request)
+		case <-timer.C:
+			log.Printf("Approval %s expired", approval.ID)
+			return nil, localizedError(ctx, "error.approval_expired", approval.Tool)
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// handleAdminApprovals lists the parked calls on GET and decides on one with
+// a {"id": "...", "approve": true} body on POST.
+func (s *Server) handleAdminApprovals(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, s.approvals.list())
+	case http.MethodPost:
+		var decision struct {
+			ID      string `json:"id"`
+			Approve bool   `json:"approve"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&decision); err != nil {
+			http.Error(w, fmt.Sprintf("invalid decision: %v", err), http.StatusBadRequest)
+			return
+		}
+		if !s.approvals.decide(decision.ID, decision.Approve) {
+			http.Error(w, "Unknown or already decided approval", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"id": decision.ID, "approved": decision.Approve})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
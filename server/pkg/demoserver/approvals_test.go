@@ -0,0 +1,82 @@
+package demoserver
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// awaitApproval waits until a call is parked and returns it.
+func awaitApproval(t *testing.T, s *Server) Approval {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if list := s.approvals.list(); len(list) == 1 {
+			return list[0]
+		}
+	}
+	t.Fatal("no call was parked")
+	return Approval{}
+}
+
+func TestApprovals(t *testing.T) {
+	calls := 0
+	s, err := New(WithTransport(TransportHTTP), WithAdminToken("admin"), WithApprovals(time.Minute), WithExtraTools(wipeTool(&calls)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// read-only tools are not parked
+	if _, ok := resultText(callTool(t, s, "add", map[string]any{"a": 1, "b": 2})); !ok {
+		t.Fatal("add failed")
+	}
+	for _, approve := range []bool{true, false} {
+		done := make(chan mcp.JSONRPCMessage)
+		go func() { done <- callTool(t, s, "wipe", map[string]any{"disk": "sda"}) }()
+		approval := awaitApproval(t, s)
+		if approval.Tool != "wipe" || approval.Arguments["disk"] != "sda" {
+			t.Errorf("parked %+v, want the wipe call", approval)
+		}
+		if !s.approvals.decide(approval.ID, approve) {
+			t.Fatalf("deciding on %s failed", approval.ID)
+		}
+		response := <-done
+		if text, _ := resultText(response); approve && text != "wiped" {
+			t.Errorf("approved call returned %v", response)
+		} else if !approve && !strings.Contains(errorMessage(response), "was denied") {
+			t.Errorf("denied call returned %v", response)
+		}
+		if s.approvals.decide(approval.ID, true) {
+			t.Errorf("decided on %s twice", approval.ID)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("wipe ran %d times, want once", calls)
+	}
+
+	s.approvals.timeout = 50 * time.Millisecond
+	if message := errorMessage(callTool(t, s, "wipe", nil)); !strings.Contains(message, "not approved in time") {
+		t.Errorf("unanswered call failed with %q, want it expired", message)
+	}
+	if calls != 1 || len(s.approvals.list()) != 0 {
+		t.Errorf("expired call ran or stayed parked")
+	}
+}
+
+// TestToolIsDestructive checks that the annotations are looked up in the
+// registered tools, not in a tool list filtered by the client catalogs.
+func TestToolIsDestructive(t *testing.T) {
+	calls := 0
+	s, err := New(WithTransport(TransportHTTP), WithExtraTools(wipeTool(&calls)),
+		WithClientCatalogRules(ClientCatalogRule{Client: "*", Hide: []string{"wipe", "add"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.mcpServer.AddTool(mcp.NewTool("unregistered", mcp.WithReadOnlyHintAnnotation(true)), nil)
+	for name, want := range map[string]bool{"wipe": true, "add": false, "unregistered": true} {
+		if got := s.toolIsDestructive(name); got != want {
+			t.Errorf("toolIsDestructive(%s) = %v, want %v", name, got, want)
+		}
+	}
+}
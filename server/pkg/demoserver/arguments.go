@@ -0,0 +1,222 @@
+package demoserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	// AppliedDefaultsMetaKey holds the argument defaults injected into a
+	// call in the _meta of its result, for transparency.
+	AppliedDefaultsMetaKey = "appliedDefaults"
+	// CoercedArgumentsMetaKey lists the arguments converted to the type of
+	// their rule in the _meta of the result.
+	CoercedArgumentsMetaKey = "coercedArguments"
+)
+
+// ArgumentRules preprocess the arguments of a tool before its handler
+// validates them.
+type ArgumentRules struct {
+	// Defaults are set for the arguments missing in a call, i.e.
+	// {"market": "US"}.
+	Defaults map[string]any `json:"defaults,omitempty"`
+	// Coerce converts string arguments to number, integer or boolean, and
+	// numbers and booleans to string, for clients sending the wrong type.
+	// Values that do not convert are left for the handler to reject.
+	Coerce map[string]string `json:"coerce,omitempty"`
+}
+
+func (r ArgumentRules) validate() error {
+	for name, kind := range r.Coerce {
+		switch kind {
+		case "string", "number", "integer", "boolean":
+		default:
+			return fmt.Errorf("argument %s: unknown type %q", name, kind)
+		}
+	}
+	return nil
+}
+
+// apply returns the preprocessed copy of arguments, along with the defaults
+// it applied and the names of the coerced arguments.
+func (r ArgumentRules) apply(arguments map[string]any) (map[string]any, map[string]any, []string) {
+	processed := maps.Clone(arguments)
+	if processed == nil {
+		processed = make(map[string]any)
+	}
+	var applied map[string]any
+	for name, value := range r.Defaults {
+		if _, ok := processed[name]; ok {
+			continue
+		}
+		if applied == nil {
+			applied = make(map[string]any)
+		}
+		processed[name] = value
+		applied[name] = value
+	}
+	var coerced []string
+	for name, kind := range r.Coerce {
+		value, ok := processed[name]
+		if !ok {
+			continue
+		}
+		if converted, ok := coerceArgument(value, kind); ok {
+			processed[name] = converted
+			coerced = append(coerced, name)
+		}
+	}
+	slices.Sort(coerced)
+	return processed, applied, coerced
+}
+
+// coerceArgument converts value to kind, reporting false when it has the type
+// already or does not convert.
+func coerceArgument(value any, kind string) (any, bool) {
+	switch v := value.(type) {
+	case string:
+		s := strings.TrimSpace(v)
+		switch kind {
+		case "number":
+			if f, err := strconv.ParseFloat(s, 64); err == nil {
+				return f, true
+			}
+		case "integer":
+			if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+				return float64(i), true
+			}
+		case "boolean":
+			if b, err := strconv.ParseBool(s); err == nil {
+				return b, true
+			}
+		}
+	case float64:
+		if kind == "string" {
+			return strconv.FormatFloat(v, 'f', -1, 64), true
+		}
+	case bool:
+		if kind == "string" {
+			return strconv.FormatBool(v), true
+		}
+	}
+	return nil, false
+}
+
+// WithArgumentRules preprocesses the arguments of tool by rules, overriding
+// the rules of WithArgumentRulesFile for the tool.
+func WithArgumentRules(tool string, rules ArgumentRules) Option {
+	return func(s *Server) {
+		if s.argumentRules.static == nil {
+			s.argumentRules.static = make(map[string]ArgumentRules)
+		}
+		s.argumentRules.static[tool] = rules
+	}
+}
+
+// WithArgumentRulesFile loads the ArgumentRules per tool name from the JSON
+// file at path, i.e.
+//
+//	{"search": {"defaults": {"market": "US"}, "coerce": {"limit": "integer"}}}
+//
+// The file is read again on Reload.
+func WithArgumentRulesFile(path string) Option {
+	return func(s *Server) {
+		s.argumentRules.path = path
+	}
+}
+
+type argumentRules struct {
+	static map[string]ArgumentRules
+	path   string
+
+	mu       sync.RWMutex
+	fromFile map[string]ArgumentRules
+}
+
+func (a *argumentRules) load() error {
+	fromFile, err := a.read()
+	if err != nil {
+		return err
+	}
+	a.set(fromFile)
+	return nil
+}
+
+func (a *argumentRules) read() (map[string]ArgumentRules, error) {
+	for tool, rules := range a.static {
+		if err := rules.validate(); err != nil {
+			return nil, fmt.Errorf("invalid argument rules of %s: %w", tool, err)
+		}
+	}
+	if a.path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(a.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read argument rules file: %w", err)
+	}
+	var fromFile map[string]ArgumentRules
+	if err := json.Unmarshal(data, &fromFile); err != nil {
+		return nil, fmt.Errorf("invalid argument rules file: %w", err)
+	}
+	for tool, rules := range fromFile {
+		if err := rules.validate(); err != nil {
+			return nil, fmt.Errorf("invalid argument rules of %s: %w", tool, err)
+		}
+	}
+	return fromFile, nil
+}
+
+func (a *argumentRules) set(fromFile map[string]ArgumentRules) {
+	a.mu.Lock()
+	a.fromFile = fromFile
+	a.mu.Unlock()
+}
+
+func (a *argumentRules) forTool(tool string) (ArgumentRules, bool) {
+	if rules, ok := a.static[tool]; ok {
+		return rules, true
+	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	rules, ok := a.fromFile[tool]
+	return rules, ok
+}
+
+// argumentMiddleware applies the argument rules of the tool before the
+// policy, the approvals and the handler see the arguments, and reports what
+// it changed in the _meta of the result.
+func (s *Server) argumentMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		rules, ok := s.argumentRules.forTool(request.Params.Name)
+		if !ok {
+			return next(ctx, request)
+		}
+		arguments, applied, coerced := rules.apply(request.GetArguments())
+		request.Params.Arguments = arguments
+		result, err := next(ctx, request)
+		if err != nil || result == nil || applied == nil && coerced == nil {
+			return result, err
+		}
+		if result.Meta == nil {
+			result.Meta = make(map[string]any)
+		}
+		if applied != nil {
+			result.Meta[AppliedDefaultsMetaKey] = applied
+		}
+		if coerced != nil {
+			result.Meta[CoercedArgumentsMetaKey] = coerced
+		}
+		return result, nil
+	}
+}
@@ -0,0 +1,214 @@
+package demoserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// The benchmarks call echo over each transport with a small and a large
+// message, reporting the allocations. Profile them with the go test flags:
+//
+//	go test ./pkg/demoserver -run '^$' -bench Transport -benchmem -cpuprofile cpu.out -memprofile mem.out
+
+var benchmarkPayloads = []struct {
+	name string
+	size int
+}{
+	{"small", 16},
+	{"large", 64 << 10},
+}
+
+// benchmarkTransports start the server and connect an initialized client to
+// it, the returned function stops both.
+var benchmarkTransports = []struct {
+	name    string
+	connect func(b *testing.B) (*client.Client, func())
+}{
+	{"in-process", connectInProcess},
+	{"stdio", connectStdio},
+	{"sse", connectNetwork(TransportSSE, "/sse")},
+	{"http", connectNetwork(TransportHTTP, "/mcp")},
+}
+
+func BenchmarkTransport(b *testing.B) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	for _, tr := range benchmarkTransports {
+		for _, payload := range benchmarkPayloads {
+			b.Run(fmt.Sprintf("%s/%s", tr.name, payload.name), func(b *testing.B) {
+				c, stop := tr.connect(b)
+				defer stop()
+
+				request := mcp.CallToolRequest{}
+				request.Params.Name = string(ECHO)
+				request.Params.Arguments = map[string]any{"message": strings.Repeat("x", payload.size)}
+
+				b.SetBytes(int64(payload.size))
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if _, err := c.CallTool(context.Background(), request); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkHandleMessage(b *testing.B) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	s, err := New(WithRequestLog(0))
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, payload := range benchmarkPayloads {
+		b.Run(payload.name, func(b *testing.B) {
+			message, _ := json.Marshal(map[string]any{
+				"jsonrpc": "2.0",
+				"id":      1,
+				"method":  "tools/call",
+				"params":  map[string]any{"name": ECHO, "arguments": map[string]any{"message": strings.Repeat("x", payload.size)}},
+			})
+			b.SetBytes(int64(payload.size))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				s.mcpServer.HandleMessage(context.Background(), message)
+			}
+		})
+	}
+}
+
+func connectInProcess(b *testing.B) (*client.Client, func()) {
+	s := newBenchmarkServer(b, TransportStdio)
+	c, err := client.NewInProcessClient(s.mcpServer)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return initializeBenchmarkClient(b, c), func() { c.Close() }
+}
+
+func connectStdio(b *testing.B) (*client.Client, func()) {
+	s := newBenchmarkServer(b, TransportStdio)
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stdio := server.NewStdioServer(s.mcpServer)
+	stdio.SetErrorLogger(log.New(io.Discard, "", 0))
+	go stdio.Listen(ctx, serverIn, serverOut)
+
+	c := client.NewClient(transport.NewIO(clientIn, clientOut, io.NopCloser(strings.NewReader(""))))
+	return initializeBenchmarkClient(b, c), func() {
+		c.Close()
+		cancel()
+		serverOut.Close()
+	}
+}
+
+func connectNetwork(transportName, path string) func(b *testing.B) (*client.Client, func()) {
+	return func(b *testing.B) (*client.Client, func()) {
+		s := newBenchmarkServer(b, transportName)
+		ts := httptest.NewServer(s.Handler())
+
+		var c *client.Client
+		var err error
+		if transportName == TransportSSE {
+			c, err = client.NewSSEMCPClient(ts.URL + path)
+		} else {
+			c, err = client.NewStreamableHttpClient(ts.URL + path)
+		}
+		if err != nil {
+			b.Fatal(err)
+		}
+		return initializeBenchmarkClient(b, c), func() {
+			// the streamable HTTP client deletes its session in the
+			// background on Close, closing the test server ends it as well
+			if transportName == TransportSSE {
+				c.Close()
+			}
+			ts.CloseClientConnections()
+			ts.Close()
+		}
+	}
+}
+
+func newBenchmarkServer(b *testing.B, transportName string) *Server {
+	b.Helper()
+	s, err := New(WithTransport(transportName), WithRequestLog(0), WithSSEHeartbeat(0))
+	if err != nil {
+		b.Fatal(err)
+	}
+	return s
+}
+
+func initializeBenchmarkClient(b *testing.B, c *client.Client) *client.Client {
+	b.Helper()
+	if err := c.Start(context.Background()); err != nil {
+		b.Fatal(err)
+	}
+	request := mcp.InitializeRequest{}
+	request.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
+	request.Params.ClientInfo = mcp.Implementation{Name: "benchmark", Version: "0"}
+	if _, err := c.Initialize(context.Background(), request); err != nil {
+		b.Fatal(err)
+	}
+	return c
+}
+
+// BenchmarkErrorDataWriter rewrites JSON-RPC errors carrying a correlation ID,
+// as written by the streamable HTTP and SSE transports.
+func BenchmarkErrorDataWriter(b *testing.B) {
+	const id = "0123456789abcdef"
+	message := `{"jsonrpc":"2.0","id":1,"error":{"code":-32603,"message":"internal error (correlation ID ` + id + `)"}}`
+	chunks := map[string][]byte{
+		"json": []byte(message + "\n"),
+		"sse":  []byte("event: message\ndata: " + message + "\n\n"),
+	}
+	for _, name := range []string{"json", "sse"} {
+		b.Run(name, func(b *testing.B) {
+			store := &errorDataStore{}
+			w := &errorDataWriter{ResponseWriter: discardResponseWriter{}, store: store}
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				store.put(errorData{CorrelationID: id, Details: "failed to send notification"})
+				if _, err := w.Write(chunks[name]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkRequestLogBody encodes the logged bodies of captured sessions.
+func BenchmarkRequestLogBody(b *testing.B) {
+	l := &requestLog{maxBody: DefaultMaxCapturedBody}
+	result := mcp.NewToolResultText(strings.Repeat("x", 1024))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		l.body(result)
+	}
+}
+
+type discardResponseWriter struct{}
+
+func (discardResponseWriter) Header() http.Header         { return http.Header{} }
+func (discardResponseWriter) Write(p []byte) (int, error) { return len(p), nil }
+func (discardResponseWriter) WriteHeader(int)             {}
@@ -0,0 +1,245 @@
+package demoserver
+
+import (
+	"context"
+	"crypto/subtle"
+	"expvar"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/wagnerjt/go-mcp/shared/pkg/buildinfo"
+)
+
+// ToolSet is a named group of built-in tools that are enabled together.
+type ToolSet string
+
+const (
+	ToolSetEcho    ToolSet = "echo"
+	ToolSetMath    ToolSet = "math"
+	ToolSetTime    ToolSet = "time"
+	ToolSetAuth    ToolSet = "auth"
+	ToolSetNotify  ToolSet = "notify"
+	ToolSetRollout ToolSet = "rollout"
+	ToolSetText    ToolSet = "text"
+)
+
+// DefaultToolSets are the tool sets enabled when WithTools is not used.
+var DefaultToolSets = []ToolSet{
+	ToolSetEcho,
+	ToolSetMath,
+	ToolSetTime,
+	ToolSetAuth,
+	ToolSetNotify,
+	ToolSetRollout,
+	ToolSetText,
+}
+
+// Metrics receives an observation for every tool call.
+type Metrics interface {
+	ObserveToolCall(tool string, duration time.Duration, err error)
+}
+
+// ExpvarMetrics publishes tool call counts, errors and latency through
+// expvar, so they are served on /debug/vars.
+type ExpvarMetrics struct {
+	calls         *expvar.Map
+	errors        *expvar.Map
+	latency       *expvar.Map
+	requests      *expvar.Map
+	requestErrors *expvar.Map
+	queued        *expvar.Map
+	queueWait     *expvar.Map
+}
+
+// NewExpvarMetrics publishes the metrics maps under the given prefix.
+func NewExpvarMetrics(prefix string) *ExpvarMetrics {
+	return &ExpvarMetrics{
+		calls:   expvar.NewMap(prefix + "_tool_calls"),
+		errors:  expvar.NewMap(prefix + "_tool_errors"),
+		latency: expvar.NewMap(prefix + "_tool_latency_ms"),
+		// requests by method and latency bucket, i.e. "tools/call le_25ms"
+		requests:      expvar.NewMap(prefix + "_requests"),
+		requestErrors: expvar.NewMap(prefix + "_request_errors"),
+		// tool calls that waited for the fair scheduler by principal
+		queued:    expvar.NewMap(prefix + "_queued_calls"),
+		queueWait: expvar.NewMap(prefix + "_queue_wait_ms"),
+	}
+}
+
+func (m *ExpvarMetrics) ObserveToolCall(tool string, duration time.Duration, err error) {
+	m.calls.Add(tool, 1)
+	m.latency.AddFloat(tool, float64(duration.Microseconds())/1000)
+	if err != nil {
+		m.errors.Add(tool, 1)
+	}
+}
+
+// WithTools enables only the given built-in tool sets.
+func WithTools(sets ...ToolSet) Option {
+	return func(s *Server) {
+		s.toolSets = sets
+	}
+}
+
+// WithExtraTools registers additional tools next to the built-in ones.
+func WithExtraTools(tools ...server.ServerTool) Option {
+	return func(s *Server) {
+		s.extraTools = append(s.extraTools, tools...)
+	}
+}
+
+// WithAuth wraps the HTTP handler of the network transports with middleware.
+func WithAuth(middleware func(http.Handler) http.Handler) Option {
+	return func(s *Server) {
+		s.authMiddleware = middleware
+	}
+}
+
+// WithMetrics reports every tool call to metrics.
+func WithMetrics(metrics Metrics) Option {
+	return func(s *Server) {
+		s.metrics = metrics
+	}
+}
+
+// WithTransport selects the transport served by Serve.
+func WithTransport(transport string) Option {
+	return func(s *Server) {
+		s.transport = transport
+	}
+}
+
+// ServerBuilder collects options and builds a Server along with the
+// http.Handler of its transport.
+type ServerBuilder struct {
+	opts []Option
+}
+
+// NewServerBuilder creates a builder with the given options.
+func NewServerBuilder(opts ...Option) *ServerBuilder {
+	return &ServerBuilder{opts: opts}
+}
+
+// With appends options to the builder.
+func (b *ServerBuilder) With(opts ...Option) *ServerBuilder {
+	b.opts = append(b.opts, opts...)
+	return b
+}
+
+// Build creates the server. The returned handler is nil for the stdio
+// transport.
+func (b *ServerBuilder) Build() (*Server, http.Handler, error) {
+	s, err := New(b.opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s, s.handler, nil
+}
+
+func (s *Server) buildHandler() (http.Handler, error) {
+	var handler http.Handler
+	switch s.transport {
+	case TransportStdio:
+		return nil, nil
+	case TransportSSE:
+		handler = s.sseStreamMiddleware(server.NewSSEServer(s.mcpServer, s.sseServerOptions()...))
+	case TransportHTTP:
+		mux := http.NewServeMux()
+		mux.Handle("/mcp", server.NewStreamableHTTPServer(s.mcpServer, server.WithHTTPContextFunc(s.ContextFromRequest)))
+		handler = mux
+	default:
+		return nil, fmt.Errorf("unsupported transport type: %s", s.transport)
+	}
+	handler = s.errorDataMiddleware(handler)
+	handler = s.specMiddleware(handler)
+	if s.transport == TransportHTTP {
+		handler = s.compressionMiddleware(handler)
+	}
+
+	switch {
+	case s.demo != nil && s.authMiddleware != nil:
+		return nil, fmt.Errorf("demo mode is anonymous and cannot be combined with an auth option")
+	case s.demo != nil:
+		handler = s.demoMiddleware(handler)
+	case len(s.guestTools) > 0 && s.authMiddleware == nil:
+		return nil, fmt.Errorf("guest tools require an auth option")
+	case len(s.guestTools) > 0:
+		handler = s.guestAuth(handler)
+	case s.authMiddleware != nil:
+		handler = s.authMiddleware(handler)
+	}
+	// blocked clients don't get to try credentials
+	handler = s.accessMiddleware(handler)
+	handler = s.maintenanceMiddleware(handler)
+	handler = s.requestLogMiddleware(handler)
+	handler = s.tracingMiddleware(handler)
+
+	// operational endpoints stay reachable without auth for probes
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", handleHealthz)
+	mux.HandleFunc("/readyz", s.handleReadyz)
+	mux.HandleFunc("/version", buildinfo.Handler)
+	if _, ok := s.metrics.(*ExpvarMetrics); ok {
+		mux.Handle("/debug/vars", expvar.Handler())
+	}
+	if s.adminToken != "" {
+		mux.Handle("/admin/", s.adminHandler())
+	}
+	if s.oidc != nil {
+		mux.HandleFunc("/.well-known/oauth-protected-resource", s.oidc.handleResourceMetadata)
+	}
+	if s.forwardAuth {
+		if s.authMiddleware == nil {
+			return nil, fmt.Errorf("forward auth requires an auth option")
+		}
+		// the gateway does not send the MCP request, only its headers
+		mux.HandleFunc(ForwardAuthPath, s.handleForwardAuth)
+	}
+	mux.Handle("/", handler)
+	return mux, nil
+}
+
+func (s *Server) metricsMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		start := time.Now()
+		result, err := next(ctx, request)
+		if err == nil && result != nil && result.IsError {
+			err = fmt.Errorf("tool returned an error result")
+		}
+		s.metrics.ObserveToolCall(request.Params.Name, time.Since(start), err)
+		return result, err
+	}
+}
+
+func (s *Server) toolSetEnabled(set ToolSet) bool {
+	for _, enabled := range s.toolSets {
+		if enabled == set {
+			return true
+		}
+	}
+	return false
+}
+
+// BearerAuth only lets requests through that carry one of the given bearer
+// tokens.
+func BearerAuth(tokens ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			auth := []byte(r.Header.Get("Authorization"))
+			// every token is compared in constant time, so the timing tells
+			// neither a token nor which one matched
+			matched := 0
+			for _, token := range tokens {
+				matched |= subtle.ConstantTimeCompare(auth, []byte("Bearer "+token))
+			}
+			if matched != 1 {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package demoserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBearerAuth(t *testing.T) {
+	handler := BearerAuth("first", "second")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for auth, want := range map[string]int{
+		"Bearer first":  http.StatusOK,
+		"Bearer second": http.StatusOK,
+		"Bearer third":  http.StatusUnauthorized,
+		"Bearer firs":   http.StatusUnauthorized,
+		"first":         http.StatusUnauthorized,
+		"":              http.StatusUnauthorized,
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/mcp", nil)
+		if auth != "" {
+			r.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != want {
+			t.Errorf("%q: status %d, want %d", auth, w.Code, want)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package demoserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// ClientCatalogRule tailors the tools advertised to the clients whose name
+// matches the path.Match pattern Client, as they introduced themselves at
+// initialize. The first matching rule applies, so a last rule for * covers
+// the unknown clients.
+type ClientCatalogRule struct {
+	Client string `json:"client"`
+	// Tools are path.Match patterns of the tools the clients see, all when
+	// empty.
+	Tools []string `json:"tools,omitempty"`
+	// Hide are path.Match patterns of tools hidden from the clients, i.e.
+	// docker_*. Hidden tools cannot be called either.
+	Hide []string `json:"hide,omitempty"`
+	// ShortDescriptions cuts the tool descriptions to their first sentence
+	// and leaves out the argument descriptions, for token-constrained
+	// clients.
+	ShortDescriptions bool `json:"shortDescriptions,omitempty"`
+}
+
+func (r ClientCatalogRule) validate() error {
+	for _, pattern := range append(append([]string{r.Client}, r.Tools...), r.Hide...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// allows reports whether the clients of the rule may see and call tool.
+func (r ClientCatalogRule) allows(tool string) bool {
+	if len(r.Tools) > 0 && !matchesAny(r.Tools, tool) {
+		return false
+	}
+	return !matchesAny(r.Hide, tool)
+}
+
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// WithClientCatalogRules tailors the tool catalog by client, checked before
+// the rules of WithClientCatalogFile.
+func WithClientCatalogRules(rules ...ClientCatalogRule) Option {
+	return func(s *Server) {
+		s.clientCatalogs.static = append(s.clientCatalogs.static, rules...)
+	}
+}
+
+// WithClientCatalogFile loads a list of ClientCatalogRule from the JSON file
+// at path, i.e.
+//
+//	[{"client": "claude-code", "shortDescriptions": true},
+//	 {"client": "*", "hide": ["docker_*", "git_*", "k8s_*"]}]
+//
+// The file is read again on Reload.
+func WithClientCatalogFile(path string) Option {
+	return func(s *Server) {
+		s.clientCatalogs.path = path
+	}
+}
+
+type clientCatalogs struct {
+	static []ClientCatalogRule
+	path   string
+
+	mu       sync.RWMutex
+	fromFile []ClientCatalogRule
+}
+
+func (c *clientCatalogs) load() error {
+	fromFile, err := c.read()
+	if err != nil {
+		return err
+	}
+	c.set(fromFile)
+	return nil
+}
+
+func (c *clientCatalogs) read() ([]ClientCatalogRule, error) {
+	for _, rule := range c.static {
+		if err := rule.validate(); err != nil {
+			return nil, fmt.Errorf("invalid client catalog rule for %s: %w", rule.Client, err)
+		}
+	}
+	if c.path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client catalog file: %w", err)
+	}
+	var fromFile []ClientCatalogRule
+	if err := json.Unmarshal(data, &fromFile); err != nil {
+		return nil, fmt.Errorf("invalid client catalog file: %w", err)
+	}
+	for _, rule := range fromFile {
+		if err := rule.validate(); err != nil {
+			return nil, fmt.Errorf("invalid client catalog rule for %s: %w", rule.Client, err)
+		}
+	}
+	return fromFile, nil
+}
+
+func (c *clientCatalogs) set(fromFile []ClientCatalogRule) {
+	c.mu.Lock()
+	c.fromFile = fromFile
+	c.mu.Unlock()
+}
+
+// rule returns the first rule matching client.
+func (c *clientCatalogs) rule(client string) (ClientCatalogRule, bool) {
+	for _, rule := range c.static {
+		if matched, _ := path.Match(rule.Client, client); matched {
+			return rule, true
+		}
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, rule := range c.fromFile {
+		if matched, _ := path.Match(rule.Client, client); matched {
+			return rule, true
+		}
+	}
+	return ClientCatalogRule{}, false
+}
+
+// clientCatalogRule returns the rule of the client of the session of ctx.
+func (s *Server) clientCatalogRule(ctx context.Context) (ClientCatalogRule, bool) {
+	client, _ := s.features.client(ctx)
+	return s.clientCatalogs.rule(client.Name)
+}
+
+// filterClientTools applies the catalog rule of the client, after the
+// descriptions were localized.
+func (s *Server) filterClientTools(ctx context.Context, tools []mcp.Tool) []mcp.Tool {
+	rule, ok := s.clientCatalogRule(ctx)
+	if !ok {
+		return tools
+	}
+	tailored := make([]mcp.Tool, 0, len(tools))
+	for _, tool := range tools {
+		if !rule.allows(tool.Name) {
+			continue
+		}
+		if rule.ShortDescriptions {
+			tool = shortenDescriptions(tool)
+		}
+		tailored = append(tailored, tool)
+	}
+	return tailored
+}
+
+// shortenDescriptions returns tool with the first sentence of its
+// description and without argument descriptions, leaving the registered
+// schema untouched.
+func shortenDescriptions(tool mcp.Tool) mcp.Tool {
+	if end := strings.Index(tool.Description, ". "); end >= 0 {
+		tool.Description = tool.Description[:end+1]
+	}
+	properties := make(map[string]any, len(tool.InputSchema.Properties))
+	for name, property := range tool.InputSchema.Properties {
+		if schema, ok := property.(map[string]any); ok {
+			schema = maps.Clone(schema)
+			delete(schema, "description")
+			property = schema
+		}
+		properties[name] = property
+	}
+	tool.InputSchema.Properties = properties
+	return tool
+}
+
+// clientCatalogMiddleware rejects the calls of tools hidden from the client,
+// which may still be called by name.
+func (s *Server) clientCatalogMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if rule, ok := s.clientCatalogRule(ctx); ok && !rule.allows(request.Params.Name) {
+			return nil, localizedError(ctx, "error.tool_not_available", request.Params.Name)
+		}
+		return next(ctx, request)
+	}
+}
@@ -0,0 +1,75 @@
+package demoserver
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/wagnerjt/go-mcp/shared/pkg/healthcheck"
+)
+
+// CheckResult is one item of the startup self-test report.
+type CheckResult = healthcheck.Result
+
+// CheckTimeout bounds each dependency check of Check.
+const CheckTimeout = 10 * time.Second
+
+// Check self-tests the constructed server without serving traffic: the tool
+// schemas, their translations and every readiness check, which is where the
+// upstream dependencies are verified.
+func (s *Server) Check(ctx context.Context) []CheckResult {
+	results := []CheckResult{{
+		Name:   "config",
+		OK:     true,
+		Detail: fmt.Sprintf("transport %s, tool sets %v", s.transport, s.toolSets),
+	}}
+
+	tools, err := s.listTools(ctx)
+	if err != nil {
+		return append(results, CheckResult{Name: "tools", Detail: err.Error()})
+	}
+	for _, tool := range tools {
+		result := CheckResult{Name: "tool " + tool.Name, OK: true}
+		if err := healthcheck.ValidateToolSchema(tool); err != nil {
+			result.OK = false
+			result.Detail = err.Error()
+		} else if missing := s.catalog.missingLocales("tool." + tool.Name + ".description"); len(missing) > 0 {
+			result.Detail = "no description in " + strings.Join(missing, ", ")
+		}
+		results = append(results, result)
+	}
+
+	for _, c := range s.readiness.checks {
+		checkCtx, cancel := context.WithTimeout(ctx, CheckTimeout)
+		err := c.check(checkCtx)
+		cancel()
+		result := CheckResult{Name: "dependency " + c.name, OK: err == nil}
+		if err != nil {
+			result.Detail = err.Error()
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// listTools lists the tools the way a client sees them.
+func (s *Server) listTools(ctx context.Context) ([]mcp.Tool, error) {
+	return healthcheck.ListTools(ctx, s.mcpServer)
+}
+
+// missingLocales lists the locales without a message for key.
+func (c *Catalog) missingLocales(key string) []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var missing []string
+	for locale, messages := range c.messages {
+		if _, ok := messages[key]; !ok {
+			missing = append(missing, locale)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
@@ -0,0 +1,176 @@
+package demoserver
+
+import (
+	"context"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	// ChunkNotification carries a chunk of a streamed tool result, along
+	// with the progress token of the call, the index of the chunk and the
+	// index of the content item it belongs to.
+	ChunkNotification = "notifications/tools/chunk"
+	// ChunkedMetaKey is set in the _meta of calls by clients able to
+	// reassemble streamed results, and holds the number of chunks in the _meta
+	// of the streamed results.
+	ChunkedMetaKey = "chunked"
+
+	// DefaultChunkSize is the size of the text chunks of streamed results.
+	DefaultChunkSize = 32 << 10
+)
+
+type chunkSizeKey struct{}
+
+// chunkedSessionKey marks the calls of sessions with FeatureChunkedResults.
+type chunkedSessionKey struct{}
+
+// WithChunkSize streams the results of tools with more text than size as
+// chunk notifications to the clients asking for it, zero disables streaming.
+// Streaming applies to SSE and stdio: mcp-go drops the notifications that
+// race the response of a streamable HTTP request, so those results are sent
+// whole.
+func WithChunkSize(size int) Option {
+	return func(s *Server) {
+		s.chunkSize = size
+	}
+}
+
+// ResultStream builds the result of a tool incrementally. For clients asking
+// for chunked results the content is sent as it is written, i.e. while a
+// large file is read, otherwise it is collected into the result.
+type ResultStream struct {
+	ctx    context.Context
+	server *server.MCPServer
+	token  mcp.ProgressToken
+	size   int
+
+	content []mcp.Content
+	items   int
+	chunks  int
+}
+
+// NewResultStream creates the result stream of a tool call.
+func NewResultStream(ctx context.Context, request mcp.CallToolRequest) *ResultStream {
+	stream := &ResultStream{ctx: ctx}
+	size, _ := ctx.Value(chunkSizeKey{}).(int)
+	meta := request.Params.Meta
+	if size <= 0 || meta == nil || meta.ProgressToken == nil {
+		return stream
+	}
+	chunked, _ := meta.AdditionalFields[ChunkedMetaKey].(bool)
+	if session, _ := ctx.Value(chunkedSessionKey{}).(bool); !chunked && !session {
+		return stream
+	}
+	stream.server = server.ServerFromContext(ctx)
+	stream.token = meta.ProgressToken
+	stream.size = size
+	return stream
+}
+
+// Streaming reports whether the content is sent as chunks.
+func (s *ResultStream) Streaming() bool {
+	return s.server != nil
+}
+
+// Write adds a content item to the result. Text longer than the chunk size
+// is split into several chunks of the item.
+func (s *ResultStream) Write(content mcp.Content) error {
+	if !s.Streaming() {
+		s.content = append(s.content, content)
+		return nil
+	}
+
+	item := s.items
+	s.items++
+	text, ok := content.(mcp.TextContent)
+	if !ok || len(text.Text) <= s.size {
+		return s.send(item, content)
+	}
+	for rest := text.Text; rest != ""; {
+		n := min(s.size, len(rest))
+		// split on rune boundaries so every chunk is valid UTF-8
+		for n < len(rest) && n > 0 && !utf8.RuneStart(rest[n]) {
+			n--
+		}
+		if n == 0 {
+			n = len(rest)
+		}
+		chunk := text
+		chunk.Text, rest = rest[:n], rest[n:]
+		if err := s.send(item, chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteText adds a text content item to the result.
+func (s *ResultStream) WriteText(text string) error {
+	return s.Write(mcp.NewTextContent(text))
+}
+
+func (s *ResultStream) send(item int, content mcp.Content) error {
+	err := s.server.SendNotificationToClient(s.ctx, ChunkNotification, map[string]any{
+		"progressToken": s.token,
+		"index":         s.chunks,
+		"item":          item,
+		"content":       content,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to stream chunk %d: %w", s.chunks, err)
+	}
+	s.chunks++
+	return nil
+}
+
+// Result returns the result of the tool. A streamed result has no content,
+// its _meta holds the number of chunks the client has to wait for.
+func (s *ResultStream) Result() *mcp.CallToolResult {
+	if !s.Streaming() {
+		return &mcp.CallToolResult{Content: s.content}
+	}
+	result := &mcp.CallToolResult{Content: []mcp.Content{}}
+	result.Meta = map[string]any{ChunkedMetaKey: s.chunks}
+	return result
+}
+
+// chunkMiddleware streams the large results of tools that return them at
+// once, the client reassembles them either way.
+func (s *Server) chunkMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		size := s.chunkSize
+		if s.transport == TransportHTTP {
+			size = 0
+		}
+		ctx = context.WithValue(ctx, chunkSizeKey{}, size)
+		ctx = context.WithValue(ctx, chunkedSessionKey{}, s.features.enabled(ctx, FeatureChunkedResults))
+		result, err := next(ctx, request)
+		if err != nil || result == nil || result.IsError || result.Meta[ChunkedMetaKey] != nil {
+			return result, err
+		}
+
+		text := 0
+		for _, content := range result.Content {
+			if t, ok := content.(mcp.TextContent); ok {
+				text += len(t.Text)
+			}
+		}
+		if size <= 0 || text <= size {
+			return result, nil
+		}
+		stream := NewResultStream(ctx, request)
+		if !stream.Streaming() {
+			return result, nil
+		}
+		for _, content := range result.Content {
+			if err := stream.Write(content); err != nil {
+				return nil, err
+			}
+		}
+		return stream.Result(), nil
+	}
+}
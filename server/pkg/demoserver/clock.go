@@ -0,0 +1,51 @@
+package demoserver
+
+import (
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// WithClock replaces time.Now in get_current_time, the approval and policy
+// timestamps, the bans and watermarks of demo mode, the call history, the
+// queue waits of the scheduler and the date of emails, so tests don't depend
+// on the wall clock or sleep through TTLs.
+func WithClock(clock func() time.Time) Option {
+	return func(s *Server) {
+		s.clock = clock
+	}
+}
+
+// WithRandSource replaces the randomness of the canary routing of anonymous
+// callers and of the request log sampling, so tests can be deterministic.
+// Secrets such as correlation IDs and watermark keys keep using crypto/rand.
+func WithRandSource(source rand.Source) Option {
+	return func(s *Server) {
+		s.rand = &lockedRand{r: rand.New(source)}
+	}
+}
+
+// lockedRand is a rand.Rand safe for concurrent use, the package functions
+// when nil.
+type lockedRand struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+func (l *lockedRand) IntN(n int) int {
+	if l == nil {
+		return rand.IntN(n)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.IntN(n)
+}
+
+func (l *lockedRand) Float64() float64 {
+	if l == nil {
+		return rand.Float64()
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Float64()
+}
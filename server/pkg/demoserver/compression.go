@@ -0,0 +1,215 @@
+package demoserver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// DefaultCompressionMinSize is the size below which responses are not
+	// worth compressing.
+	DefaultCompressionMinSize = 1024
+	// DefaultMaxDecompressedBody caps gzip request bodies once decompressed,
+	// a few KB of gzip can inflate to gigabytes.
+	DefaultMaxDecompressedBody = 4 << 20
+)
+
+// WithCompression gzips the streamable HTTP responses of clients accepting
+// it and decompresses gzip request bodies. SSE streams are never compressed,
+// proxies would buffer them.
+func WithCompression(enabled bool) Option {
+	return func(s *Server) {
+		s.compression.enabled = enabled
+	}
+}
+
+// WithCompressionMinSize only compresses responses of at least size bytes.
+func WithCompressionMinSize(size int) Option {
+	return func(s *Server) {
+		s.compression.minSize = size
+	}
+}
+
+// WithMaxDecompressedBody rejects gzip request bodies larger than size bytes
+// once decompressed with 413.
+func WithMaxDecompressedBody(size int64) Option {
+	return func(s *Server) {
+		s.compression.maxBody = size
+	}
+}
+
+type compressionConfig struct {
+	enabled bool
+	minSize int
+	maxBody int64
+}
+
+var gzipWriterPool = sync.Pool{
+	New: func() any { return gzip.NewWriter(io.Discard) },
+}
+
+func (s *Server) compressionMiddleware(next http.Handler) http.Handler {
+	if !s.compression.enabled {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.EqualFold(r.Header.Get("Content-Encoding"), "gzip") {
+			gz, err := gzip.NewReader(r.Body)
+			if err != nil {
+				http.Error(w, "invalid gzip request body", http.StatusBadRequest)
+				return
+			}
+			// the handlers read the whole body, so it is decompressed here
+			// up to the limit
+			body, err := io.ReadAll(io.LimitReader(gz, s.compression.maxBody+1))
+			gz.Close()
+			if err != nil {
+				http.Error(w, "invalid gzip request body", http.StatusBadRequest)
+				return
+			}
+			if int64(len(body)) > s.compression.maxBody {
+				http.Error(w, "decompressed request body too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			r.Header.Del("Content-Encoding")
+			r.Header.Del("Content-Length")
+			r.ContentLength = int64(len(body))
+		}
+		if !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Accept-Encoding")
+		cw := &compressedWriter{ResponseWriter: w, minSize: s.compression.minSize, status: http.StatusOK}
+		defer cw.close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// acceptsGzip parses the Accept-Encoding header, honoring q=0.
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if coding = strings.TrimSpace(coding); coding != "gzip" && coding != "*" {
+			continue
+		}
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+		return q > 0
+	}
+	return false
+}
+
+// compressedWriter buffers the start of the response until it reaches the
+// minimum size, the response is then gzipped, or ends or is flushed, it is
+// then sent as is.
+type compressedWriter struct {
+	http.ResponseWriter
+	minSize int
+
+	status      int
+	wroteHeader bool
+	decided     bool
+	buf         bytes.Buffer
+	gz          *gzip.Writer
+}
+
+func (w *compressedWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.status = status
+	// responses without a body are sent right away
+	if status == http.StatusNoContent || status == http.StatusNotModified || status < http.StatusOK {
+		w.decide(false)
+	}
+}
+
+func (w *compressedWriter) Write(p []byte) (int, error) {
+	if !w.decided {
+		w.buf.Write(p)
+		if w.buf.Len() < w.minSize {
+			return len(p), nil
+		}
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if w.gz != nil {
+		return w.gz.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// decide sends the header, compressing when worth it and the content allows,
+// along with the buffered start of the response.
+func (w *compressedWriter) decide(worthIt bool) error {
+	w.decided = true
+	header := w.Header()
+	contentType := header.Get("Content-Type")
+	if worthIt && header.Get("Content-Encoding") == "" && !strings.HasPrefix(contentType, "text/event-stream") {
+		header.Set("Content-Encoding", "gzip")
+		header.Del("Content-Length")
+		w.gz = gzipWriterPool.Get().(*gzip.Writer)
+		w.gz.Reset(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	var err error
+	if w.gz != nil {
+		_, err = w.gz.Write(w.buf.Bytes())
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf.Bytes())
+	}
+	w.buf = bytes.Buffer{}
+	return err
+}
+
+// Flush implements http.Flusher, which the streamable HTTP server uses for
+// its SSE responses.
+func (w *compressedWriter) Flush() {
+	if !w.decided {
+		w.decide(false)
+	}
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *compressedWriter) close() {
+	if !w.decided {
+		if !w.wroteHeader && w.buf.Len() == 0 {
+			// nothing was written, leave the default response to net/http
+			return
+		}
+		w.decide(false)
+	}
+	if w.gz != nil {
+		w.gz.Close()
+		w.gz.Reset(io.Discard)
+		gzipWriterPool.Put(w.gz)
+		w.gz = nil
+	}
+}
+
+func (w *compressedWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
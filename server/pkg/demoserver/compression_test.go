@@ -0,0 +1,45 @@
+package demoserver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestDecompressionLimit checks that gzip request bodies inflating beyond
+// the limit are rejected before a handler reads them.
+func TestDecompressionLimit(t *testing.T) {
+	s, err := New(WithTransport(TransportHTTP), WithCompression(true), WithMaxDecompressedBody(1024))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var received string
+	handler := s.compressionMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = string(body)
+	}))
+	post := func(body string) int {
+		var compressed bytes.Buffer
+		gz := gzip.NewWriter(&compressed)
+		gz.Write([]byte(body))
+		gz.Close()
+		req := httptest.NewRequest(http.MethodPost, "/mcp", &compressed)
+		req.Header.Set("Content-Encoding", "gzip")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	small := strings.Repeat("a", 1024)
+	if code := post(small); code != http.StatusOK || received != small {
+		t.Errorf("body at the limit answered %d with %d bytes received", code, len(received))
+	}
+	received = ""
+	if code := post(strings.Repeat("a", 1<<20)); code != http.StatusRequestEntityTooLarge || received != "" {
+		t.Errorf("body above the limit answered %d, want %d", code, http.StatusRequestEntityTooLarge)
+	}
+}
@@ -0,0 +1,53 @@
+package demoserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// ConfigFile is a kind of JSON config file of the server, named after its
+// flag.
+type ConfigFile string
+
+const (
+	ConfigFilePostProcessors ConfigFile = "post-processors"
+	ConfigFileArgumentRules  ConfigFile = "argument-rules"
+	ConfigFileClientCatalogs ConfigFile = "client-catalogs"
+)
+
+// ValidateConfigFile checks the config file of kind at path as the server
+// loads it, and reports unknown keys as well, which the server ignores, so
+// misspelled options do not go unnoticed.
+func ValidateConfigFile(kind ConfigFile, path string) error {
+	var loader interface{ load() error }
+	var schema any
+	switch kind {
+	case ConfigFilePostProcessors:
+		loader = &postProcessors{path: path}
+		schema = &map[string][]postProcessorStep{}
+	case ConfigFileArgumentRules:
+		loader = &argumentRules{path: path}
+		schema = &map[string]ArgumentRules{}
+	case ConfigFileClientCatalogs:
+		loader = &clientCatalogs{path: path}
+		schema = &[]ClientCatalogRule{}
+	default:
+		return fmt.Errorf("unknown config file kind %q", kind)
+	}
+	if err := loader.load(); err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(schema); err != nil {
+		return fmt.Errorf("invalid %s file: %w", kind, err)
+	}
+	return nil
+}
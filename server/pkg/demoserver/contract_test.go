@@ -0,0 +1,100 @@
+package demoserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wagnerjt/go-mcp/server/pkg/mcpschema"
+)
+
+// TestContract validates the responses of the server to the requests a client
+// makes against the MCP schema.
+func TestContract(t *testing.T) {
+	spec, err := mcpschema.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requests := []struct{ method, params string }{
+		{"initialize", `{"protocolVersion":"` + mcpschema.ProtocolVersion + `","capabilities":{},"clientInfo":{"name":"contract","version":"0"}}`},
+		{"ping", `{}`},
+		{"tools/list", `{}`},
+		{"tools/call", `{"name":"unknown"}`},
+		{"resources/list", `{}`},
+		{"prompts/list", `{}`},
+		{"unknown/method", `{}`},
+	}
+	data, err := os.ReadFile(filepath.Join("testdata", "calls.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var calls []goldenCall
+	if err := json.Unmarshal(data, &calls); err != nil {
+		t.Fatal(err)
+	}
+	for _, call := range calls {
+		params, _ := json.Marshal(map[string]any{"name": call.Tool, "arguments": call.Arguments})
+		requests = append(requests, struct{ method, params string }{"tools/call", string(params)})
+	}
+
+	s := newGoldenServer(t)
+	ctx := context.WithValue(context.Background(), authKey{}, "Bearer sk-1234")
+	for i, request := range requests {
+		message := fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":%q,"params":%s}`, i+1, request.method, request.params)
+		response, err := json.Marshal(s.mcpServer.HandleMessage(ctx, json.RawMessage(message)))
+		if err != nil {
+			t.Fatalf("%s: %v", request.method, err)
+		}
+		if err := spec.ValidateMessage(response, request.method); err != nil {
+			t.Errorf("%s %s: %v\n%s", request.method, request.params, err, response)
+		}
+	}
+}
+
+// TestSpecValidation runs the runtime validation over the streamable HTTP
+// transport, which logs no violations for a conforming server.
+func TestSpecValidation(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	s, err := New(WithTransport(TransportHTTP), WithSpecValidation(true), WithRequestLog(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	post := func(sessionID, body string) string {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+"/mcp", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if sessionID != "" {
+			req.Header.Set("Mcp-Session-Id", sessionID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		return resp.Header.Get("Mcp-Session-Id")
+	}
+	session := post("", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"`+mcpschema.ProtocolVersion+`","capabilities":{},"clientInfo":{"name":"contract","version":"0"}}}`)
+	post(session, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	post(session, `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`)
+	post(session, `{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"add","arguments":{"a":1,"b":2}}}`)
+	post(session, `{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"add","arguments":{"a":"x"}}}`)
+
+	if strings.Contains(logs.String(), "Spec violation") {
+		t.Errorf("spec violations logged:\n%s", logs.String())
+	}
+}
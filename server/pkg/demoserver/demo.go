@@ -0,0 +1,262 @@
+package demoserver
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	// DefaultDemoRateLimit is how many MCP requests a client IP may send per
+	// minute in demo mode.
+	DefaultDemoRateLimit = 30
+	// DefaultDemoBanAfter is how many rate limited requests get an IP banned.
+	DefaultDemoBanAfter = 20
+	// DefaultDemoBanDuration is how long an IP stays banned.
+	DefaultDemoBanDuration = time.Hour
+
+	// WatermarkMetaKey holds the signed watermark in the _meta of the tool
+	// results of demo mode.
+	WatermarkMetaKey = "demoWatermark"
+	demoNotice       = "[go-mcp public demo, results are not to be relied on]"
+
+	// demoPruneInterval is how often Serve forgets the idle clients.
+	demoPruneInterval = time.Minute
+)
+
+// DemoConfig is the public demo mode: anonymous access to the
+// non-destructive tools only, with rate limits, abuse bans and watermarked
+// results.
+type DemoConfig struct {
+	// RateLimit is the MCP requests per minute of a client IP,
+	// DefaultDemoRateLimit when zero.
+	RateLimit int
+	// BanAfter bans IPs after this many rate limited requests,
+	// DefaultDemoBanAfter when zero.
+	BanAfter int
+	// BanDuration is DefaultDemoBanDuration when zero.
+	BanDuration time.Duration
+	// WatermarkKey signs the watermarks, a random key per process when
+	// empty.
+	WatermarkKey []byte
+	// Rand is the source of the random WatermarkKey, crypto/rand by default.
+	Rand io.Reader
+}
+
+// WithDemoMode exposes the server publicly as a showcase, see DemoConfig. It
+// cannot be combined with the auth options. Serve forgets the idle clients
+// every minute.
+func WithDemoMode(config DemoConfig) Option {
+	return func(s *Server) {
+		if config.RateLimit <= 0 {
+			config.RateLimit = DefaultDemoRateLimit
+		}
+		if config.BanAfter <= 0 {
+			config.BanAfter = DefaultDemoBanAfter
+		}
+		if config.BanDuration <= 0 {
+			config.BanDuration = DefaultDemoBanDuration
+		}
+		s.demo = &demoMode{config: config, clients: make(map[string]*demoClient)}
+	}
+}
+
+// generateKey generates the random watermark key of a config without one, a
+// failed read would leave a zero key anyone can sign with.
+func (d *demoMode) generateKey() error {
+	if len(d.config.WatermarkKey) > 0 {
+		return nil
+	}
+	random := d.config.Rand
+	if random == nil {
+		random = rand.Reader
+	}
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(random, key); err != nil {
+		return fmt.Errorf("failed to generate the watermark key: %w", err)
+	}
+	d.config.WatermarkKey = key
+	return nil
+}
+
+type demoClient struct {
+	// tokens of the bucket refilled at RateLimit per minute
+	tokens     float64
+	updated    time.Time
+	violations int
+	bannedTill time.Time
+}
+
+type demoMode struct {
+	config DemoConfig
+
+	mu      sync.Mutex
+	clients map[string]*demoClient
+}
+
+// allow takes a token of the bucket of ip, and returns how long the client
+// has to wait otherwise.
+func (d *demoMode) allow(ip string, now time.Time) (wait time.Duration, banned bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	client, ok := d.clients[ip]
+	if !ok {
+		client = &demoClient{tokens: float64(d.config.RateLimit), updated: now}
+		d.clients[ip] = client
+	}
+	if now.Before(client.bannedTill) {
+		return client.bannedTill.Sub(now), true
+	}
+
+	perSecond := float64(d.config.RateLimit) / 60
+	client.tokens = math.Min(float64(d.config.RateLimit), client.tokens+now.Sub(client.updated).Seconds()*perSecond)
+	client.updated = now
+	if client.tokens >= 1 {
+		client.tokens--
+		return 0, false
+	}
+	if client.violations++; client.violations >= d.config.BanAfter {
+		client.violations = 0
+		client.bannedTill = now.Add(d.config.BanDuration)
+		log.Printf("Demo mode banned %s for %s", ip, d.config.BanDuration)
+		return d.config.BanDuration, true
+	}
+	return time.Duration((1 - client.tokens) / perSecond * float64(time.Second)), false
+}
+
+// prune forgets the clients with a full bucket and no ban, so idle IPs do
+// not pile up.
+func (d *demoMode) prune(now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for ip, client := range d.clients {
+		if now.After(client.bannedTill) && now.Sub(client.updated) > time.Minute {
+			delete(d.clients, ip)
+		}
+	}
+}
+
+// pruneDemoClients prunes the clients every demoPruneInterval until ctx is
+// done, rather than on the requests.
+func (s *Server) pruneDemoClients(ctx context.Context) {
+	ticker := time.NewTicker(demoPruneInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		s.demo.prune(s.clock())
+	}
+}
+
+// bans lists the banned IPs and when their ban ends.
+func (d *demoMode) bans(now time.Time) map[string]time.Time {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	bans := make(map[string]time.Time)
+	for ip, client := range d.clients {
+		if now.Before(client.bannedTill) {
+			bans[ip] = client.bannedTill
+		}
+	}
+	return bans
+}
+
+func (s *Server) demoMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := s.access.rules.Load().clientIP(r).String()
+		wait, banned := s.demo.allow(ip, s.clock())
+		if banned {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		if wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, "Too many requests", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// filterDemoTools is a tool filter hiding the destructive tools in demo mode.
+func (s *Server) filterDemoTools(ctx context.Context, tools []mcp.Tool) []mcp.Tool {
+	if s.demo == nil {
+		return tools
+	}
+	allowed := make([]mcp.Tool, 0, len(tools))
+	for _, tool := range tools {
+		if !isDestructive(tool) {
+			allowed = append(allowed, tool)
+		}
+	}
+	return allowed
+}
+
+// demoToolMiddleware rejects the calls of destructive tools and watermarks
+// the results of the others.
+func (s *Server) demoToolMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	if s.demo == nil {
+		return next
+	}
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if s.toolIsDestructive(request.Params.Name) {
+			return nil, localizedError(ctx, "error.demo_disabled", request.Params.Name)
+		}
+		result, err := next(ctx, request)
+		if err != nil || result == nil {
+			return result, err
+		}
+		s.demo.watermark(result, s.clock())
+		return result, nil
+	}
+}
+
+// watermark appends the demo notice to result and signs its text along with
+// the time, so the holder of the key can tell genuine demo output.
+func (d *demoMode) watermark(result *mcp.CallToolResult, now time.Time) {
+	var texts []string
+	for _, content := range result.Content {
+		if text, ok := content.(mcp.TextContent); ok {
+			texts = append(texts, text.Text)
+		}
+	}
+	issued := now.UTC().Format(time.RFC3339)
+	mac := hmac.New(sha256.New, d.config.WatermarkKey)
+	mac.Write([]byte(issued + "\n" + strings.Join(texts, "\n")))
+
+	result.Content = append(result.Content, mcp.NewTextContent(demoNotice))
+	if result.Meta == nil {
+		result.Meta = make(map[string]any)
+	}
+	result.Meta[WatermarkMetaKey] = fmt.Sprintf("%s.%s", issued, hex.EncodeToString(mac.Sum(nil)))
+}
+
+// handleAdminBans lists the IPs banned by demo mode.
+func (s *Server) handleAdminBans(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.demo == nil {
+		writeJSON(w, http.StatusOK, map[string]time.Time{})
+		return
+	}
+	writeJSON(w, http.StatusOK, s.demo.bans(s.clock()))
+}
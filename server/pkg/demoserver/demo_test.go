@@ -0,0 +1,82 @@
+package demoserver
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+func TestDemoModeFailsWithoutRandomness(t *testing.T) {
+	if _, err := New(WithTransport(TransportHTTP), WithDemoMode(DemoConfig{Rand: bytes.NewReader(nil)})); err == nil {
+		t.Fatal("New() succeeded without a watermark key")
+	}
+	s, err := New(WithTransport(TransportHTTP), WithDemoMode(DemoConfig{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key := s.demo.config.WatermarkKey; len(key) != 32 || bytes.Equal(key, make([]byte, 32)) {
+		t.Errorf("watermark key %x, want 32 random bytes", key)
+	}
+}
+
+func TestDemoPrune(t *testing.T) {
+	d := &demoMode{config: DemoConfig{RateLimit: 1, BanAfter: 1, BanDuration: time.Hour}, clients: make(map[string]*demoClient)}
+	now := time.Unix(1700000000, 0)
+	d.allow("192.0.2.1", now)
+	d.allow("192.0.2.2", now)
+	// a second request within the minute bans
+	if _, banned := d.allow("192.0.2.2", now); !banned {
+		t.Fatal("192.0.2.2 was not banned")
+	}
+	d.allow("192.0.2.3", now.Add(2*time.Minute))
+
+	d.prune(now.Add(2 * time.Minute))
+	for ip, want := range map[string]bool{"192.0.2.1": false, "192.0.2.2": true, "192.0.2.3": true} {
+		if _, ok := d.clients[ip]; ok != want {
+			t.Errorf("%s kept = %v, want %v", ip, ok, want)
+		}
+	}
+}
+
+// wipeTool is a destructive tool counting its calls.
+func wipeTool(calls *int) server.ServerTool {
+	return server.ServerTool{
+		Tool: mcp.NewTool("wipe", mcp.WithDestructiveHintAnnotation(true)),
+		Handler: func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			*calls++
+			return mcp.NewToolResultText("wiped"), nil
+		},
+	}
+}
+
+// errorMessage returns the message of a JSON-RPC error response.
+func errorMessage(response mcp.JSONRPCMessage) string {
+	if e, ok := response.(mcp.JSONRPCError); ok {
+		return e.Error.Message
+	}
+	return ""
+}
+
+// TestDemoRejectsDestructiveTools checks that the calls of destructive tools,
+// hidden from the tool lists of demo mode, are rejected too.
+func TestDemoRejectsDestructiveTools(t *testing.T) {
+	calls := 0
+	s, err := New(WithTransport(TransportHTTP), WithDemoMode(DemoConfig{}), WithExtraTools(wipeTool(&calls)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	response := callTool(t, s, "wipe", nil)
+	if message := errorMessage(response); !strings.Contains(message, "disabled in the public demo") || calls != 0 {
+		t.Errorf("destructive call returned %v and ran %d times, want error.demo_disabled", response, calls)
+	}
+	// the results of the others are watermarked
+	response = callTool(t, s, "add", map[string]any{"a": 1, "b": 2})
+	if result, ok := response.(mcp.JSONRPCResponse).Result.(mcp.CallToolResult); !ok || result.IsError || len(result.Content) < 2 {
+		t.Errorf("read-only call returned %v, want a watermarked result", response)
+	}
+}
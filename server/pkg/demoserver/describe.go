@@ -0,0 +1,205 @@
+package demoserver
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/wagnerjt/go-mcp/shared/pkg/buildinfo"
+)
+
+const (
+	// ServerName is the implementation the server introduces itself as at
+	// initialize, along with ServerVersion.
+	ServerName = "go-mcp/tools"
+
+	DESCRIBE_SERVER ToolName = "describe_server"
+
+	// ServerDescriptionURI is the resource of the ServerDescription.
+	ServerDescriptionURI = "server://description"
+)
+
+// ServerVersion is the version of the build, see buildinfo.
+var ServerVersion = buildinfo.Get().Version
+
+// ServerDescription is the structured capability report of describe_server,
+// so orchestrators can adapt to the server instead of probing it.
+type ServerDescription struct {
+	Name            string         `json:"name"`
+	Version         string         `json:"version"`
+	Build           buildinfo.Info `json:"build"`
+	ProtocolVersion string         `json:"protocolVersion"`
+	Transport       string         `json:"transport"`
+	ToolSets        []string       `json:"toolSets"`
+	// Subsystems are the optional features configured, i.e. git or
+	// approvals.
+	Subsystems []string            `json:"subsystems"`
+	Auth       AuthDescription     `json:"auth"`
+	RateLimits []RateLimit         `json:"rateLimits"`
+	Features   FeaturesDescription `json:"features"`
+	// Tools are the tools as the calling client sees them.
+	Tools []ToolDescription `json:"tools"`
+}
+
+// AuthDescription is how clients authenticate: none, bearer_tokens, oidc,
+// custom or demo, the anonymous public demo. The stdio transport is not
+// authenticated.
+type AuthDescription struct {
+	Mode        string   `json:"mode"`
+	ForwardAuth bool     `json:"forwardAuth,omitempty"`
+	GuestTools  []string `json:"guestTools,omitempty"`
+}
+
+// RateLimit is a limit of Limit calls per Per.
+type RateLimit struct {
+	Name  string `json:"name"`
+	Limit int    `json:"limit"`
+	Per   string `json:"per"`
+}
+
+// FeaturesDescription are the session features, see FeaturesCapability.
+type FeaturesDescription struct {
+	Supported []Feature `json:"supported"`
+	Enabled   []Feature `json:"enabled"`
+}
+
+// ToolDescription is a tool of the ServerDescription.
+type ToolDescription struct {
+	Name        string             `json:"name"`
+	Description string             `json:"description,omitempty"`
+	Annotations mcp.ToolAnnotation `json:"annotations"`
+}
+
+// Describe reports the configuration of the server as the client of ctx sees
+// it.
+func (s *Server) Describe(ctx context.Context) (ServerDescription, error) {
+	description := ServerDescription{
+		Name:            ServerName,
+		Version:         ServerVersion,
+		Build:           buildinfo.Get(),
+		ProtocolVersion: mcp.LATEST_PROTOCOL_VERSION,
+		Transport:       s.transport,
+		ToolSets:        []string{},
+		Subsystems:      s.subsystems(),
+		Auth:            s.authDescription(),
+		RateLimits:      []RateLimit{},
+		Features: FeaturesDescription{
+			Supported: Features,
+			Enabled:   []Feature{},
+		},
+		Tools: []ToolDescription{},
+	}
+	for _, set := range s.toolSets {
+		description.ToolSets = append(description.ToolSets, string(set))
+	}
+	if s.demo != nil {
+		description.RateLimits = append(description.RateLimits, RateLimit{Name: "demo requests per IP", Limit: s.demo.config.RateLimit, Per: "minute"})
+	}
+	if max := s.AccessRules().MaxConnsPerIP; max > 0 {
+		description.RateLimits = append(description.RateLimits, RateLimit{Name: "concurrent requests per IP", Limit: max, Per: "connection"})
+	}
+	if s.scheduler != nil {
+		description.RateLimits = append(description.RateLimits, RateLimit{Name: "concurrent tool calls", Limit: s.scheduler.config.MaxConcurrent, Per: "server"})
+	}
+	if s.notifications != nil {
+		description.RateLimits = append(description.RateLimits, RateLimit{Name: "notifications", Limit: s.notifications.config.RateLimit, Per: "hour"})
+	}
+	for _, feature := range Features {
+		if s.features.enabled(ctx, feature) {
+			description.Features.Enabled = append(description.Features.Enabled, feature)
+		}
+	}
+
+	tools, err := s.listTools(ctx)
+	if err != nil {
+		return ServerDescription{}, err
+	}
+	for _, tool := range tools {
+		description.Tools = append(description.Tools, ToolDescription{
+			Name:        tool.Name,
+			Description: tool.Description,
+			Annotations: tool.Annotations,
+		})
+	}
+	return description, nil
+}
+
+func (s *Server) subsystems() []string {
+	subsystems := []string{}
+	for _, subsystem := range []struct {
+		name    string
+		enabled bool
+	}{
+		{"approvals", s.approvals.timeout > 0},
+		{"policy", s.policy != nil},
+		{"demo", s.demo != nil},
+		{"history", s.history.size > 0},
+		{"chunked_results", s.chunkSize > 0},
+		{"vector_search", s.vector != nil},
+		{"documents", s.docs != nil},
+		{"prompts", s.prompts != nil},
+		{"git", s.git != nil},
+		{"kubernetes", s.k8s != nil},
+		{"docker", s.docker != nil},
+		{"notifications", s.notifications != nil},
+		{"registry", s.registry != nil},
+		{"outbox", s.outbox != nil},
+		{"fair_scheduling", s.scheduler != nil},
+		{"sandbox", s.sandbox != nil},
+		{"token_estimates", s.tokenEstimates},
+		{"metrics", s.metrics != nil},
+		{"admin_api", s.adminToken != ""},
+	} {
+		if subsystem.enabled {
+			subsystems = append(subsystems, subsystem.name)
+		}
+	}
+	return subsystems
+}
+
+func (s *Server) authDescription() AuthDescription {
+	auth := AuthDescription{Mode: "none", ForwardAuth: s.forwardAuth, GuestTools: s.guestTools}
+	switch {
+	case s.transport == TransportStdio:
+	case s.demo != nil:
+		auth.Mode = "demo"
+	case s.oidc != nil:
+		auth.Mode = "oidc"
+	case s.tokensFile != nil:
+		auth.Mode = "bearer_tokens"
+	case s.authMiddleware != nil:
+		auth.Mode = "custom"
+	}
+	return auth
+}
+
+func (s *Server) registerDescribeServer() {
+	s.AddTool(mcp.NewTool(string(DESCRIBE_SERVER),
+		mcp.WithDescription("Reports the version, subsystems, auth mode, rate limits and tools of the server in one document"),
+		mcp.WithReadOnlyHintAnnotation(true),
+	), s.handleDescribeServer)
+}
+
+func (s *Server) handleDescribeServer(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	description, err := s.Describe(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return jsonResult(description)
+}
+
+func (s *Server) readServerDescription(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	description, err := s.Describe(ctx)
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(description)
+	if err != nil {
+		return nil, err
+	}
+	return []mcp.ResourceContents{mcp.TextResourceContents{
+		URI:      ServerDescriptionURI,
+		MIMEType: "application/json",
+		Text:     string(body),
+	}}, nil
+}
@@ -0,0 +1,418 @@
+package demoserver
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	DOCKER_LIST_CONTAINERS ToolName = "docker_list_containers"
+	DOCKER_LIST_IMAGES     ToolName = "docker_list_images"
+	DOCKER_INSPECT         ToolName = "docker_inspect"
+	DOCKER_LOGS            ToolName = "docker_logs"
+	DOCKER_START           ToolName = "docker_start"
+	DOCKER_STOP            ToolName = "docker_stop"
+
+	// DefaultDockerHost is the socket of the local Docker engine.
+	DefaultDockerHost = "unix:///var/run/docker.sock"
+
+	maxDockerLogBytes = 1 << 20
+)
+
+// DockerConfig enables the Docker tools on an engine.
+type DockerConfig struct {
+	// Host is the engine API, DefaultDockerHost by default, or i.e.
+	// tcp://localhost:2375 as in DOCKER_HOST.
+	Host string
+	// AllowWrites registers docker_start and docker_stop, which are annotated
+	// destructive, so they go through approvals and the demo mode rejects
+	// them.
+	AllowWrites bool
+}
+
+// WithDocker registers the tools listing, inspecting and reading the logs of
+// the containers and images of a Docker engine. Access to the engine API is
+// root-equivalent, so only the tools of this server are exposed, not the API.
+func WithDocker(config DockerConfig) Option {
+	return func(s *Server) {
+		if config.Host == "" {
+			config.Host = DefaultDockerHost
+		}
+		s.docker = &dockerClient{config: config}
+		s.readiness.checks = append(s.readiness.checks, namedCheck{"docker", s.docker.health})
+	}
+}
+
+// dockerClient calls the Docker engine API.
+type dockerClient struct {
+	config DockerConfig
+	base   string
+	client *http.Client
+}
+
+func (d *dockerClient) load() error {
+	host, err := url.Parse(d.config.Host)
+	if err != nil {
+		return fmt.Errorf("invalid docker host: %w", err)
+	}
+	transport := &http.Transport{}
+	switch host.Scheme {
+	case "unix":
+		socket := host.Path
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socket)
+		}
+		d.base = "http://docker"
+	case "tcp", "http":
+		d.base = "http://" + host.Host
+	default:
+		return fmt.Errorf("unsupported docker host %s, expected unix:// or tcp://", d.config.Host)
+	}
+	// logs and stops take their time, the calls are bounded by their contexts
+	d.client = &http.Client{Transport: tracePropagation(transport)}
+	return nil
+}
+
+// do calls the engine API and decodes the JSON response into out, unless it
+// is nil.
+func (d *dockerClient) do(ctx context.Context, method, path string, query url.Values, out any) ([]byte, error) {
+	u := d.base + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach the docker engine: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 32<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotModified {
+		var message struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(body, &message) == nil && message.Message != "" {
+			return nil, localizedError(ctx, "error.docker_api", message.Message)
+		}
+		return nil, fmt.Errorf("docker engine: status code %d", resp.StatusCode)
+	}
+	if out != nil {
+		if err := json.Unmarshal(body, out); err != nil {
+			return nil, fmt.Errorf("failed to decode the docker response: %w", err)
+		}
+	}
+	return body, nil
+}
+
+func (d *dockerClient) health(ctx context.Context) error {
+	_, err := d.do(ctx, http.MethodGet, "/_ping", nil, nil)
+	return err
+}
+
+// dockerContainerName accepts container names and IDs.
+var dockerContainerName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+func dockerContainer(ctx context.Context, request mcp.CallToolRequest) (string, error) {
+	container, err := requireText(ctx, request, "container")
+	if err != nil {
+		return "", err
+	}
+	if !dockerContainerName.MatchString(container) {
+		return "", localizedError(ctx, "error.invalid_argument", "container", fmt.Sprintf("invalid container %q", container))
+	}
+	return container, nil
+}
+
+func (s *Server) registerDockerTools() {
+	if s.docker == nil {
+		return
+	}
+	container := mcp.WithString("container",
+		mcp.Description("Container name or ID"),
+		mcp.Required(),
+	)
+
+	s.AddTool(mcp.NewTool(string(DOCKER_LIST_CONTAINERS),
+		mcp.WithDescription("Lists the Docker containers with their image, state and ports"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithBoolean("all",
+			mcp.Description("Includes the stopped containers"),
+		),
+		mcp.WithString("label",
+			mcp.Description("Only containers with this label, i.e. com.docker.compose.project=web"),
+		),
+	), s.handleDockerListContainers)
+
+	s.AddTool(mcp.NewTool(string(DOCKER_LIST_IMAGES),
+		mcp.WithDescription("Lists the Docker images with their tags and sizes"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithString("reference",
+			mcp.Description("Only images matching this reference, i.e. nginx or nginx:1.*"),
+		),
+	), s.handleDockerListImages)
+
+	s.AddTool(mcp.NewTool(string(DOCKER_INSPECT),
+		mcp.WithDescription("Returns the configuration and state of a Docker container, without its environment variables"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		container,
+	), s.handleDockerInspect)
+
+	s.AddTool(mcp.NewTool(string(DOCKER_LOGS),
+		mcp.WithDescription("Returns the last log lines of a Docker container"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		container,
+		mcp.WithNumber("tail_lines",
+			mcp.Description("Number of lines, 200 by default"),
+			mcp.Min(1),
+			mcp.Max(5000),
+		),
+		mcp.WithNumber("since_seconds",
+			mcp.Description("Only lines logged in the last seconds"),
+			mcp.Min(1),
+		),
+		mcp.WithBoolean("timestamps",
+			mcp.Description("Prefixes the lines with their time"),
+		),
+	), s.handleDockerLogs)
+
+	if !s.docker.config.AllowWrites {
+		return
+	}
+	s.AddTool(mcp.NewTool(string(DOCKER_START),
+		mcp.WithDescription("Starts a stopped Docker container"),
+		mcp.WithDestructiveHintAnnotation(true),
+		container,
+	), s.handleDockerStart)
+
+	s.AddTool(mcp.NewTool(string(DOCKER_STOP),
+		mcp.WithDescription("Stops a running Docker container"),
+		mcp.WithDestructiveHintAnnotation(true),
+		container,
+		mcp.WithNumber("timeout_seconds",
+			mcp.Description("Seconds to wait for the container to exit before killing it, 10 by default"),
+			mcp.Min(0),
+			mcp.Max(300),
+		),
+	), s.handleDockerStop)
+}
+
+// ContainerSummary is a container listed by docker_list_containers.
+type ContainerSummary struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"`
+	Image   string    `json:"image"`
+	State   string    `json:"state"`
+	Status  string    `json:"status"`
+	Ports   []string  `json:"ports,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+func (s *Server) handleDockerListContainers(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	query := url.Values{}
+	if request.GetBool("all", false) {
+		query.Set("all", "true")
+	}
+	if label := request.GetString("label", ""); label != "" {
+		filters, _ := json.Marshal(map[string][]string{"label": {label}})
+		query.Set("filters", string(filters))
+	}
+	var list []struct {
+		ID      string   `json:"Id"`
+		Names   []string `json:"Names"`
+		Image   string   `json:"Image"`
+		State   string   `json:"State"`
+		Status  string   `json:"Status"`
+		Created int64    `json:"Created"`
+		Ports   []struct {
+			IP          string `json:"IP"`
+			PrivatePort int    `json:"PrivatePort"`
+			PublicPort  int    `json:"PublicPort"`
+			Type        string `json:"Type"`
+		} `json:"Ports"`
+	}
+	if _, err := s.docker.do(ctx, http.MethodGet, "/containers/json", query, &list); err != nil {
+		return nil, err
+	}
+	containers := make([]ContainerSummary, 0, len(list))
+	for _, item := range list {
+		container := ContainerSummary{
+			ID:      item.ID[:min(12, len(item.ID))],
+			Image:   item.Image,
+			State:   item.State,
+			Status:  item.Status,
+			Created: time.Unix(item.Created, 0).UTC(),
+		}
+		if len(item.Names) > 0 {
+			container.Name = strings.TrimPrefix(item.Names[0], "/")
+		}
+		for _, port := range item.Ports {
+			if port.PublicPort > 0 {
+				container.Ports = append(container.Ports, fmt.Sprintf("%s:%d->%d/%s", port.IP, port.PublicPort, port.PrivatePort, port.Type))
+			} else {
+				container.Ports = append(container.Ports, fmt.Sprintf("%d/%s", port.PrivatePort, port.Type))
+			}
+		}
+		containers = append(containers, container)
+	}
+	return jsonResult(map[string]any{"containers": containers})
+}
+
+// ImageSummary is an image listed by docker_list_images.
+type ImageSummary struct {
+	ID      string    `json:"id"`
+	Tags    []string  `json:"tags"`
+	Size    int64     `json:"size"`
+	Created time.Time `json:"created"`
+}
+
+func (s *Server) handleDockerListImages(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	query := url.Values{}
+	if reference := request.GetString("reference", ""); reference != "" {
+		filters, _ := json.Marshal(map[string][]string{"reference": {reference}})
+		query.Set("filters", string(filters))
+	}
+	var list []struct {
+		ID       string   `json:"Id"`
+		RepoTags []string `json:"RepoTags"`
+		Size     int64    `json:"Size"`
+		Created  int64    `json:"Created"`
+	}
+	if _, err := s.docker.do(ctx, http.MethodGet, "/images/json", query, &list); err != nil {
+		return nil, err
+	}
+	images := make([]ImageSummary, 0, len(list))
+	for _, item := range list {
+		id := strings.TrimPrefix(item.ID, "sha256:")
+		images = append(images, ImageSummary{
+			ID:      id[:min(12, len(id))],
+			Tags:    item.RepoTags,
+			Size:    item.Size,
+			Created: time.Unix(item.Created, 0).UTC(),
+		})
+	}
+	return jsonResult(map[string]any{"images": images})
+}
+
+func (s *Server) handleDockerInspect(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	container, err := dockerContainer(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	var inspect map[string]any
+	if _, err := s.docker.do(ctx, http.MethodGet, "/containers/"+url.PathEscape(container)+"/json", nil, &inspect); err != nil {
+		return nil, err
+	}
+	// the environment usually holds secrets
+	if config, ok := inspect["Config"].(map[string]any); ok {
+		if env, ok := config["Env"].([]any); ok {
+			names := make([]string, 0, len(env))
+			for _, variable := range env {
+				name, _, _ := strings.Cut(fmt.Sprint(variable), "=")
+				names = append(names, name+"=[redacted]")
+			}
+			config["Env"] = names
+		}
+	}
+	return jsonResult(inspect)
+}
+
+func (s *Server) handleDockerLogs(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	container, err := dockerContainer(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	tailLines := request.GetInt("tail_lines", DefaultLogTailLines)
+	if tailLines < 1 || tailLines > 5000 {
+		tailLines = DefaultLogTailLines
+	}
+	query := url.Values{
+		"stdout": {"true"},
+		"stderr": {"true"},
+		"tail":   {strconv.Itoa(tailLines)},
+	}
+	if since := request.GetInt("since_seconds", 0); since > 0 {
+		query.Set("since", strconv.FormatInt(s.clock().Add(-time.Duration(since)*time.Second).Unix(), 10))
+	}
+	if request.GetBool("timestamps", false) {
+		query.Set("timestamps", "true")
+	}
+	body, err := s.docker.do(ctx, http.MethodGet, "/containers/"+url.PathEscape(container)+"/logs", query, nil)
+	if err != nil {
+		return nil, err
+	}
+	logs := demultiplexDockerLogs(body)
+	if len(logs) > maxDockerLogBytes {
+		logs = logs[len(logs)-maxDockerLogBytes:]
+	}
+	if logs == "" {
+		logs = "No log lines"
+	}
+	return mcp.NewToolResultText(logs), nil
+}
+
+// demultiplexDockerLogs strips the 8 byte frame headers of the logs of
+// containers without a TTY, which interleave stdout and stderr. Logs of TTY
+// containers are returned as they are.
+func demultiplexDockerLogs(body []byte) string {
+	var logs strings.Builder
+	for rest := body; len(rest) > 0; {
+		if len(rest) < 8 || rest[0] > 2 || rest[1] != 0 || rest[2] != 0 || rest[3] != 0 {
+			if logs.Len() == 0 {
+				return string(body)
+			}
+			break
+		}
+		size := int(binary.BigEndian.Uint32(rest[4:8]))
+		rest = rest[8:]
+		size = min(size, len(rest))
+		logs.Write(rest[:size])
+		rest = rest[size:]
+	}
+	return logs.String()
+}
+
+func (s *Server) handleDockerStart(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	container, err := dockerContainer(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.docker.do(ctx, http.MethodPost, "/containers/"+url.PathEscape(container)+"/start", nil, nil); err != nil {
+		return nil, err
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("Started container %s", container)), nil
+}
+
+func (s *Server) handleDockerStop(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	container, err := dockerContainer(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	timeout := request.GetInt("timeout_seconds", 10)
+	if timeout < 0 || timeout > 300 {
+		timeout = 10
+	}
+	query := url.Values{"t": {strconv.Itoa(timeout)}}
+	if _, err := s.docker.do(ctx, http.MethodPost, "/containers/"+url.PathEscape(container)+"/stop", query, nil); err != nil {
+		return nil, err
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("Stopped container %s", container)), nil
+}
@@ -0,0 +1,297 @@
+package demoserver
+
+import (
+	"encoding/binary"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDockerEngine serves the parts of the engine API the tools call, with
+// a running container "web" and an image. requests returns the requests
+// since its last call, as "METHOD path?query".
+func fakeDockerEngine() (engine http.Handler, requests func() []string) {
+	var mu sync.Mutex
+	var recorded []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /_ping", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	})
+	mux.HandleFunc("GET /containers/json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"Id":"0123456789abcdef0123","Names":["/web"],"Image":"nginx:1.27","State":"running","Status":"Up 2 hours",
+			"Created":1700000000,"Ports":[{"IP":"0.0.0.0","PrivatePort":80,"PublicPort":8080,"Type":"tcp"},{"PrivatePort":443,"Type":"tcp"}]}]`))
+	})
+	mux.HandleFunc("GET /images/json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"Id":"sha256:fedcba9876543210fedc","RepoTags":["nginx:1.27"],"Size":1024,"Created":1700000000}]`))
+	})
+	mux.HandleFunc("GET /containers/web/json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"Id":"0123456789abcdef0123","Config":{"Image":"nginx:1.27","Env":["PATH=/usr/bin","DB_PASSWORD=s3cret"]}}`))
+	})
+	mux.HandleFunc("GET /containers/web/logs", func(w http.ResponseWriter, r *http.Request) {
+		// the multiplexed stream of a container without a TTY
+		for _, frame := range []struct {
+			stream byte
+			line   string
+		}{{1, "started\n"}, {2, "warning\n"}} {
+			header := []byte{frame.stream, 0, 0, 0, 0, 0, 0, 0}
+			binary.BigEndian.PutUint32(header[4:], uint32(len(frame.line)))
+			w.Write(append(header, frame.line...))
+		}
+	})
+	mux.HandleFunc("GET /containers/tty/logs", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("raw line\n"))
+	})
+	mux.HandleFunc("POST /containers/web/start", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotModified)
+	})
+	mux.HandleFunc("POST /containers/web/stop", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("/containers/missing/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message":"No such container: missing"}`))
+	})
+	mux.HandleFunc("/containers/broken/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("panic"))
+	})
+	mux.HandleFunc("/containers/garbled/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("not json"))
+	})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			request := r.Method + " " + r.URL.Path
+			if r.URL.RawQuery != "" {
+				request += "?" + r.URL.RawQuery
+			}
+			mu.Lock()
+			recorded = append(recorded, request)
+			mu.Unlock()
+			mux.ServeHTTP(w, r)
+		}), func() []string {
+			mu.Lock()
+			defer mu.Unlock()
+			list := recorded
+			recorded = nil
+			return list
+		}
+}
+
+func newDockerServer(t *testing.T, host string, options ...Option) *Server {
+	t.Helper()
+	s, err := New(append([]Option{WithTransport(TransportHTTP), WithDocker(DockerConfig{Host: host, AllowWrites: true}),
+		WithClock(func() time.Time { return time.Unix(1700000000, 0) })}, options...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestDockerTools(t *testing.T) {
+	handler, requests := fakeDockerEngine()
+	engine := httptest.NewServer(handler)
+	defer engine.Close()
+	s := newDockerServer(t, "tcp://"+engine.Listener.Addr().String())
+
+	tests := []struct {
+		tool        string
+		arguments   map[string]any
+		want        []string
+		notWant     string
+		wantRequest string
+	}{
+		{
+			tool:        string(DOCKER_LIST_CONTAINERS),
+			arguments:   map[string]any{"all": true, "label": "app=web"},
+			want:        []string{`"id":"0123456789ab"`, `"name":"web"`, `"0.0.0.0:8080-\u003e80/tcp"`, `"443/tcp"`, `"created":"2023-11-14T22:13:20Z"`},
+			wantRequest: `GET /containers/json?all=true&filters=%7B%22label%22%3A%5B%22app%3Dweb%22%5D%7D`,
+		},
+		{
+			tool:        string(DOCKER_LIST_IMAGES),
+			arguments:   map[string]any{"reference": "nginx"},
+			want:        []string{`"id":"fedcba987654"`, `"tags":["nginx:1.27"]`},
+			wantRequest: `GET /images/json?filters=%7B%22reference%22%3A%5B%22nginx%22%5D%7D`,
+		},
+		{
+			tool:        string(DOCKER_INSPECT),
+			arguments:   map[string]any{"container": "web"},
+			want:        []string{`"DB_PASSWORD=[redacted]"`, `"PATH=[redacted]"`},
+			notWant:     "s3cret",
+			wantRequest: "GET /containers/web/json",
+		},
+		{
+			tool:        string(DOCKER_LOGS),
+			arguments:   map[string]any{"container": "web", "tail_lines": 50, "since_seconds": 60, "timestamps": true},
+			want:        []string{"started\nwarning\n"},
+			wantRequest: "GET /containers/web/logs?since=1699999940&stderr=true&stdout=true&tail=50&timestamps=true",
+		},
+		{
+			tool:        string(DOCKER_LOGS),
+			arguments:   map[string]any{"container": "tty"},
+			want:        []string{"raw line\n"},
+			wantRequest: "GET /containers/tty/logs?stderr=true&stdout=true&tail=200",
+		},
+		{
+			// starting a running container answers 304
+			tool:        string(DOCKER_START),
+			arguments:   map[string]any{"container": "web"},
+			want:        []string{"Started container web"},
+			wantRequest: "POST /containers/web/start",
+		},
+		{
+			tool:        string(DOCKER_STOP),
+			arguments:   map[string]any{"container": "web", "timeout_seconds": 30},
+			want:        []string{"Stopped container web"},
+			wantRequest: "POST /containers/web/stop?t=30",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tool, func(t *testing.T) {
+			requests()
+			response := callTool(t, s, tt.tool, tt.arguments)
+			text, ok := resultText(response)
+			if !ok {
+				t.Fatalf("call failed: %v", response)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(text, want) {
+					t.Errorf("result %s does not contain %s", text, want)
+				}
+			}
+			if tt.notWant != "" && strings.Contains(text, tt.notWant) {
+				t.Errorf("result %s contains %s", text, tt.notWant)
+			}
+			if got := requests(); !slices.Equal(got, []string{tt.wantRequest}) {
+				t.Errorf("engine requests %q, want %q", got, tt.wantRequest)
+			}
+		})
+	}
+}
+
+// TestDockerToolErrors checks that invalid arguments never reach the engine,
+// and that the errors of the engine reach the client only when it explains
+// them.
+func TestDockerToolErrors(t *testing.T) {
+	handler, requests := fakeDockerEngine()
+	engine := httptest.NewServer(handler)
+	defer engine.Close()
+	s := newDockerServer(t, "tcp://"+engine.Listener.Addr().String())
+
+	tests := []struct {
+		name      string
+		tool      string
+		arguments map[string]any
+		wantErr   string
+		// wantCall is false for the arguments rejected before the engine
+		wantCall bool
+	}{
+		{name: "missing container", tool: string(DOCKER_INSPECT), wantErr: "container"},
+		{name: "path traversal", tool: string(DOCKER_INSPECT), arguments: map[string]any{"container": "../../images/json"}, wantErr: "invalid container"},
+		{name: "query injection", tool: string(DOCKER_STOP), arguments: map[string]any{"container": "web?signal=KILL"}, wantErr: "invalid container"},
+		{name: "leading dash", tool: string(DOCKER_START), arguments: map[string]any{"container": "-web"}, wantErr: "invalid container"},
+		{name: "not found", tool: string(DOCKER_INSPECT), arguments: map[string]any{"container": "missing"}, wantErr: "docker engine: No such container: missing", wantCall: true},
+		{name: "not found on stop", tool: string(DOCKER_STOP), arguments: map[string]any{"container": "missing"}, wantErr: "No such container", wantCall: true},
+		// failures without a message of the engine are not shown to the client
+		{name: "engine failure", tool: string(DOCKER_LOGS), arguments: map[string]any{"container": "broken"}, wantErr: "internal error", wantCall: true},
+		{name: "invalid response", tool: string(DOCKER_INSPECT), arguments: map[string]any{"container": "garbled"}, wantErr: "internal error", wantCall: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests()
+			response := callTool(t, s, tt.tool, tt.arguments)
+			if message := errorMessage(response); !strings.Contains(message, tt.wantErr) {
+				t.Errorf("call returned %v, want an error containing %q", response, tt.wantErr)
+			}
+			if got := requests(); (len(got) > 0) != tt.wantCall {
+				t.Errorf("engine requests %q, want a call %v", got, tt.wantCall)
+			}
+		})
+	}
+
+	// out of range limits fall back to the defaults
+	requests()
+	callTool(t, s, string(DOCKER_STOP), map[string]any{"container": "web", "timeout_seconds": 1000})
+	callTool(t, s, string(DOCKER_LOGS), map[string]any{"container": "tty", "tail_lines": 100000})
+	want := []string{"POST /containers/web/stop?t=10", "GET /containers/tty/logs?stderr=true&stdout=true&tail=200"}
+	if got := requests(); !slices.Equal(got, want) {
+		t.Errorf("engine requests %q, want %q", got, want)
+	}
+}
+
+func TestDockerUnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "docker.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	handler, _ := fakeDockerEngine()
+	engine := httptest.NewUnstartedServer(handler)
+	engine.Listener = listener
+	engine.Start()
+	defer engine.Close()
+
+	s := newDockerServer(t, "unix://"+socket)
+	if text, ok := resultText(callTool(t, s, string(DOCKER_LIST_IMAGES), nil)); !ok || !strings.Contains(text, "nginx:1.27") {
+		t.Errorf("list over the socket returned %q", text)
+	}
+	if err := s.docker.health(t.Context()); err != nil {
+		t.Errorf("health() = %v", err)
+	}
+	engine.Close()
+	if err := s.docker.health(t.Context()); err == nil || !strings.Contains(err.Error(), "failed to reach the docker engine") {
+		t.Errorf("health() of a stopped engine = %v", err)
+	}
+}
+
+func TestDockerConfig(t *testing.T) {
+	for _, host := range []string{"ssh://docker.example.com", "npipe:////./pipe/docker_engine", "://"} {
+		if _, err := New(WithTransport(TransportHTTP), WithDocker(DockerConfig{Host: host})); err == nil {
+			t.Errorf("New() succeeded with docker host %q", host)
+		}
+	}
+
+	// the writing tools are only registered when allowed, and destructive
+	s, err := New(WithTransport(TransportHTTP), WithDocker(DockerConfig{Host: "tcp://localhost:2375"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, stop := s.registeredTool(string(DOCKER_STOP))
+	_, logs := s.registeredTool(string(DOCKER_LOGS))
+	if stop || !logs {
+		t.Error("docker_stop registered without AllowWrites, or docker_logs missing")
+	}
+	s = newDockerServer(t, "tcp://localhost:2375")
+	for _, name := range []ToolName{DOCKER_START, DOCKER_STOP} {
+		if !s.toolIsDestructive(string(name)) {
+			t.Errorf("%s is not registered as destructive", name)
+		}
+	}
+}
+
+func TestDemultiplexDockerLogs(t *testing.T) {
+	frame := func(stream byte, line string) []byte {
+		header := []byte{stream, 0, 0, 0, 0, 0, 0, 0}
+		binary.BigEndian.PutUint32(header[4:], uint32(len(line)))
+		return append(header, line...)
+	}
+	for _, tt := range []struct {
+		name string
+		body []byte
+		want string
+	}{
+		{"empty", nil, ""},
+		{"tty", []byte("plain output"), "plain output"},
+		{"frames", append(frame(1, "a\n"), frame(2, "b\n")...), "a\nb\n"},
+		{"truncated frame", frame(1, "abc")[:9], "a"},
+		{"trailing garbage", append(frame(1, "a\n"), "xyz"...), "a\n"},
+	} {
+		if got := demultiplexDockerLogs(tt.body); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
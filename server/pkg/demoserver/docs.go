@@ -0,0 +1,360 @@
+package demoserver
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	// DocumentsScheme is the URI scheme of the configured documents.
+	DocumentsScheme = "docs://"
+	// DocumentsCollection is the vector search collection of the document
+	// chunks, whose IDs are the chunk URIs.
+	DocumentsCollection = "docs"
+	// DefaultDocumentChunkSize is the size in bytes of the document chunks.
+	DefaultDocumentChunkSize = 4000
+	// DefaultDocumentsPollInterval is how often the documents are checked for
+	// changes.
+	DefaultDocumentsPollInterval = 30 * time.Second
+
+	maxDocumentSize = 64 << 20
+	embedBatchSize  = 64
+)
+
+// DocumentsConfig exposes local documents as docs:// resources of their text.
+type DocumentsConfig struct {
+	// Paths are files or directories searched recursively for the file types
+	// of the Extractors.
+	Paths []string
+	// ChunkSize defaults to DefaultDocumentChunkSize.
+	ChunkSize int
+	// PollInterval defaults to DefaultDocumentsPollInterval, negative only
+	// checks for changes on Reload.
+	PollInterval time.Duration
+}
+
+// WithDocuments registers the documents found in config.Paths as resources,
+// which are read as the chunks of their extracted text. Added, changed and
+// removed files are picked up while the server runs, and with vector search
+// the chunks are indexed into the DocumentsCollection.
+func WithDocuments(config DocumentsConfig) Option {
+	return func(s *Server) {
+		if config.ChunkSize <= 0 {
+			config.ChunkSize = DefaultDocumentChunkSize
+		}
+		if config.PollInterval == 0 {
+			config.PollInterval = DefaultDocumentsPollInterval
+		}
+		s.docs = &documents{config: config, byURI: make(map[string]*document)}
+	}
+}
+
+type document struct {
+	uri      string
+	path     string
+	mimeType string
+	modified time.Time
+	size     int64
+	chunks   []string
+	// indexed is the number of chunks in the vector store, which are outdated
+	// while pending
+	indexed int
+	pending bool
+}
+
+func (d *document) chunkURI(i int) string {
+	return fmt.Sprintf("%s#%d", d.uri, i+1)
+}
+
+type documents struct {
+	config DocumentsConfig
+
+	// indexing serializes indexDocuments
+	indexing sync.Mutex
+
+	mu    sync.Mutex
+	byURI map[string]*document
+	// stale holds the chunk URIs to delete from the vector store
+	stale []string
+}
+
+// documentURI names the file path found under root by the base name of root
+// and the path within it.
+func documentURI(root, path string) string {
+	name := filepath.Base(root)
+	if rel, err := filepath.Rel(root, path); err == nil && rel != "." {
+		name = filepath.Join(name, rel)
+	}
+	segments := strings.Split(filepath.ToSlash(name), "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return DocumentsScheme + strings.Join(segments, "/")
+}
+
+// scan lists the documents under the configured paths by URI.
+func (d *documents) scan() (map[string]string, error) {
+	found := make(map[string]string)
+	for _, root := range d.config.Paths {
+		err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if entry.IsDir() {
+				if path != root && strings.HasPrefix(entry.Name(), ".") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if _, _, ok := extractorOf(path); ok && entry.Type().IsRegular() {
+				found[documentURI(root, path)] = path
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan documents: %w", err)
+		}
+	}
+	return found, nil
+}
+
+// refreshDocuments extracts the new and changed documents and registers
+// them, and unregisters the removed ones. Documents failing to extract are
+// logged and left out.
+func (s *Server) refreshDocuments() error {
+	found, err := s.docs.scan()
+	if err != nil {
+		return err
+	}
+	s.docs.mu.Lock()
+	defer s.docs.mu.Unlock()
+
+	for uri, doc := range s.docs.byURI {
+		if _, ok := found[uri]; ok {
+			continue
+		}
+		for i := range doc.indexed {
+			s.docs.stale = append(s.docs.stale, doc.chunkURI(i))
+		}
+		delete(s.docs.byURI, uri)
+		s.mcpServer.RemoveResource(uri)
+	}
+	for _, uri := range slices.Sorted(maps.Keys(found)) {
+		path := found[uri]
+		info, err := os.Stat(path)
+		if err != nil {
+			log.Printf("Failed to read document %s: %v", path, err)
+			continue
+		}
+		doc, known := s.docs.byURI[uri]
+		if known && doc.modified.Equal(info.ModTime()) && doc.size == info.Size() {
+			continue
+		}
+		chunks, mimeType, err := extractDocument(path, info, s.docs.config.ChunkSize)
+		if err != nil {
+			log.Printf("Failed to extract document %s: %v", path, err)
+			continue
+		}
+		if !known {
+			doc = &document{uri: uri, path: path}
+			s.docs.byURI[uri] = doc
+		}
+		for i := len(chunks); i < doc.indexed; i++ {
+			s.docs.stale = append(s.docs.stale, doc.chunkURI(i))
+		}
+		doc.indexed = min(doc.indexed, len(chunks))
+		doc.mimeType = mimeType
+		doc.modified = info.ModTime()
+		doc.size = info.Size()
+		doc.chunks = chunks
+		doc.pending = true
+		if !known {
+			s.mcpServer.AddResource(mcp.NewResource(uri, filepath.Base(path),
+				mcp.WithResourceDescription(fmt.Sprintf("Text of the document %s", filepath.Base(path))),
+				mcp.WithMIMEType(mimeType),
+			), s.readDocument)
+		}
+	}
+	return nil
+}
+
+func extractDocument(path string, info fs.FileInfo, chunkSize int) ([]string, string, error) {
+	if info.Size() > maxDocumentSize {
+		return nil, "", fmt.Errorf("larger than %d bytes", maxDocumentSize)
+	}
+	extract, mimeType, _ := extractorOf(path)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+	text, err := extract(data)
+	if err != nil {
+		return nil, "", err
+	}
+	if mimeType != "text/markdown" {
+		mimeType = "text/plain"
+	}
+	return chunkText(text, chunkSize), mimeType, nil
+}
+
+// chunkText splits text into chunks of up to size bytes, preferably between
+// paragraphs, then between lines and words. Only a size below
+// utf8.UTFMax can be exceeded, by a single rune.
+func chunkText(text string, size int) []string {
+	var chunks []string
+	for text = strings.TrimSpace(text); len(text) > size; {
+		cut := strings.LastIndex(text[:size], "\n\n")
+		if cut <= 0 {
+			cut = strings.LastIndexByte(text[:size], '\n')
+		}
+		if cut <= 0 {
+			cut = strings.LastIndexByte(text[:size], ' ')
+		}
+		if cut <= 0 {
+			cut = size
+			for cut > 0 && !utf8.RuneStart(text[cut]) {
+				cut--
+			}
+			if cut == 0 {
+				// size is smaller than the first rune
+				_, cut = utf8.DecodeRuneInString(text)
+			}
+		}
+		chunks = append(chunks, strings.TrimSpace(text[:cut]))
+		text = strings.TrimSpace(text[cut:])
+	}
+	if text != "" {
+		chunks = append(chunks, text)
+	}
+	return chunks
+}
+
+func (s *Server) readDocument(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	s.docs.mu.Lock()
+	defer s.docs.mu.Unlock()
+	doc, ok := s.docs.byURI[request.Params.URI]
+	if !ok {
+		return nil, fmt.Errorf("unknown document %s", request.Params.URI)
+	}
+	contents := make([]mcp.ResourceContents, 0, len(doc.chunks))
+	for i, chunk := range doc.chunks {
+		contents = append(contents, mcp.TextResourceContents{
+			URI:      doc.chunkURI(i),
+			MIMEType: doc.mimeType,
+			Text:     chunk,
+		})
+	}
+	return contents, nil
+}
+
+// indexDocuments embeds the chunks of the documents changed since the last
+// call into the DocumentsCollection, and deletes the outdated ones.
+func (s *Server) indexDocuments(ctx context.Context) error {
+	if s.vector == nil {
+		return nil
+	}
+	s.docs.indexing.Lock()
+	defer s.docs.indexing.Unlock()
+
+	s.docs.mu.Lock()
+	stale := s.docs.stale
+	s.docs.stale = nil
+	var pending []*document
+	for _, doc := range s.docs.byURI {
+		if doc.pending {
+			pending = append(pending, doc)
+		}
+	}
+	s.docs.mu.Unlock()
+
+	if len(stale) > 0 {
+		if err := s.vector.Store.Delete(ctx, DocumentsCollection, stale...); err != nil {
+			s.docs.mu.Lock()
+			s.docs.stale = append(s.docs.stale, stale...)
+			s.docs.mu.Unlock()
+			return err
+		}
+	}
+	for _, doc := range pending {
+		s.docs.mu.Lock()
+		uri, chunks, modified := doc.uri, doc.chunks, doc.modified
+		s.docs.mu.Unlock()
+
+		for start := 0; start < len(chunks); start += embedBatchSize {
+			batch := chunks[start:min(start+embedBatchSize, len(chunks))]
+			vectors, err := s.vector.Embedder.Embed(ctx, batch)
+			if err != nil {
+				return fmt.Errorf("failed to embed %s: %w", uri, err)
+			}
+			docs := make([]VectorDocument, len(batch))
+			for i, chunk := range batch {
+				docs[i] = VectorDocument{
+					ID:       doc.chunkURI(start + i),
+					Text:     chunk,
+					Metadata: map[string]string{"uri": uri},
+					Vector:   vectors[i],
+					Stored:   s.clock().UTC(),
+				}
+			}
+			if err := s.vector.Store.Upsert(ctx, DocumentsCollection, docs...); err != nil {
+				return fmt.Errorf("failed to index %s: %w", uri, err)
+			}
+			s.docs.mu.Lock()
+			doc.indexed = max(doc.indexed, start+len(batch))
+			s.docs.mu.Unlock()
+		}
+
+		s.docs.mu.Lock()
+		switch {
+		case s.docs.byURI[uri] != doc:
+			// removed while it was embedded
+			for i := range doc.indexed {
+				s.docs.stale = append(s.docs.stale, doc.chunkURI(i))
+			}
+		case doc.modified.Equal(modified):
+			doc.pending = false
+		}
+		s.docs.mu.Unlock()
+	}
+	return nil
+}
+
+// watchDocuments indexes the documents, then checks them for changes every
+// poll interval until ctx is done.
+func (s *Server) watchDocuments(ctx context.Context) {
+	if err := s.indexDocuments(ctx); err != nil {
+		log.Printf("Failed to index documents: %v", err)
+	}
+	if s.docs.config.PollInterval < 0 {
+		return
+	}
+	ticker := time.NewTicker(s.docs.config.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := s.refreshDocuments(); err != nil {
+			log.Printf("Failed to refresh documents: %v", err)
+			continue
+		}
+		if err := s.indexDocuments(ctx); err != nil {
+			log.Printf("Failed to index documents: %v", err)
+		}
+	}
+}
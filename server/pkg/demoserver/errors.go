@@ -0,0 +1,257 @@
+package demoserver
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// errorDataTTL is how long the data of an error waits for its response.
+const errorDataTTL = time.Minute
+
+// maxErrorDetails caps the length of the details in debug mode.
+const maxErrorDetails = 512
+
+// WithDebugErrors includes the sanitized internal details of tool errors in
+// the JSON-RPC error data. Otherwise clients get a generic message for
+// internal errors, in both modes along with a correlation ID that is logged
+// with the details.
+func WithDebugErrors(enabled bool) Option {
+	return func(s *Server) {
+		s.debugErrors = enabled
+	}
+}
+
+// userError is an error meant for the client, i.e. an invalid argument,
+// which is returned as is in production mode too.
+type userError struct {
+	message string
+}
+
+func (e *userError) Error() string {
+	return e.message
+}
+
+// errorData is the JSON-RPC error data of tool errors.
+type errorData struct {
+	CorrelationID string `json:"correlationId"`
+	Details       string `json:"details,omitempty"`
+}
+
+type pendingErrorData struct {
+	data    errorData
+	created time.Time
+}
+
+// errorDataStore hands the data of tool errors over to the transport, which
+// adds it to the JSON-RPC error matched by the correlation ID in the message.
+type errorDataStore struct {
+	mu      sync.Mutex
+	pending map[string]pendingErrorData
+}
+
+func (e *errorDataStore) put(data errorData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.pending == nil {
+		e.pending = make(map[string]pendingErrorData)
+	}
+	now := time.Now()
+	// responses of clients that went away are never written
+	for id, pending := range e.pending {
+		if now.Sub(pending.created) > errorDataTTL {
+			delete(e.pending, id)
+		}
+	}
+	e.pending[data.CorrelationID] = pendingErrorData{data: data, created: now}
+}
+
+func (e *errorDataStore) take(id string) (errorData, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	pending, ok := e.pending[id]
+	delete(e.pending, id)
+	return pending.data, ok
+}
+
+var correlationIDPattern = regexp.MustCompile(`\(correlation ID ([0-9a-f]{16})\)$`)
+
+func newCorrelationID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// errorMiddleware logs tool errors with a correlation ID and replaces
+// internal errors with a generic message.
+func (s *Server) errorMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		result, err := next(ctx, request)
+		if err == nil {
+			return result, nil
+		}
+
+		data := errorData{CorrelationID: newCorrelationID()}
+		log.Printf("Tool %s failed [%s]: %v", request.Params.Name, data.CorrelationID, err)
+
+		message := localize(ctx, "error.internal")
+		var user *userError
+		if errors.As(err, &user) {
+			message = user.message
+		}
+		if s.debugErrors {
+			data.Details = sanitizeError(err)
+		}
+		if s.transport != TransportStdio {
+			s.errorData.put(data)
+		}
+		return nil, fmt.Errorf("%s (correlation ID %s)", message, data.CorrelationID)
+	}
+}
+
+var secretPatterns = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	{regexp.MustCompile(`(?i)\b(bearer|basic)\s+[^\s"',]+`), "$1 [redacted]"},
+	{regexp.MustCompile(`(?i)\b(token|key|secret|password|code)=[^\s&"',]+`), "$1=[redacted]"},
+	{regexp.MustCompile(`\bsk-[A-Za-z0-9_-]+`), "sk-[redacted]"},
+}
+
+// sanitizeError strips credentials from the error message and caps its
+// length.
+func sanitizeError(err error) string {
+	details := redactSecrets(err.Error())
+	if len(details) > maxErrorDetails {
+		details = details[:maxErrorDetails] + "..."
+	}
+	return details
+}
+
+func redactSecrets(text string) string {
+	for _, secret := range secretPatterns {
+		// replacing copies the text even without a match
+		if secret.pattern.MatchString(text) {
+			text = secret.pattern.ReplaceAllString(text, secret.replacement)
+		}
+	}
+	return text
+}
+
+// errorDataMiddleware adds the data of tool errors to the JSON-RPC errors
+// written by the network transports, as plain JSON or SSE events.
+func (s *Server) errorDataMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(&errorDataWriter{ResponseWriter: w, store: &s.errorData}, r)
+	})
+}
+
+type errorDataWriter struct {
+	http.ResponseWriter
+	store *errorDataStore
+}
+
+var (
+	sseDataPrefix       = []byte("data:")
+	correlationIDMarker = []byte("correlation ID")
+)
+
+func (w *errorDataWriter) Write(p []byte) (int, error) {
+	if !bytes.Contains(p, correlationIDMarker) {
+		return w.ResponseWriter.Write(p)
+	}
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if trimmed := bytes.TrimSpace(p); bytes.HasPrefix(trimmed, []byte("{")) {
+		// a plain JSON response, encoded on a single line
+		line, rest, found := bytes.Cut(p, []byte("\n"))
+		w.addErrorData(buf, line)
+		if found {
+			buf.WriteByte('\n')
+			buf.Write(rest)
+		}
+	} else {
+		for rest := p; len(rest) > 0; {
+			var line []byte
+			var found bool
+			line, rest, found = bytes.Cut(rest, []byte("\n"))
+			if payload, ok := bytes.CutPrefix(line, sseDataPrefix); ok {
+				buf.WriteString("data: ")
+				w.addErrorData(buf, bytes.TrimSpace(payload))
+			} else {
+				buf.Write(line)
+			}
+			if found {
+				buf.WriteByte('\n')
+			}
+		}
+	}
+	if _, err := w.ResponseWriter.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	// the callers only care about errors, not the rewritten length
+	return len(p), nil
+}
+
+// addErrorData appends the payload to buf, with the error data added when
+// it is a JSON-RPC error with a pending correlation ID.
+func (w *errorDataWriter) addErrorData(buf *bytes.Buffer, payload []byte) {
+	var message struct {
+		JSONRPC string          `json:"jsonrpc"`
+		ID      json.RawMessage `json:"id"`
+		Error   *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+			Data    any    `json:"data,omitempty"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(payload, &message); err != nil || message.Error == nil {
+		buf.Write(payload)
+		return
+	}
+	match := correlationIDPattern.FindStringSubmatch(message.Error.Message)
+	if match == nil {
+		buf.Write(payload)
+		return
+	}
+	data, ok := w.store.take(match[1])
+	if !ok {
+		buf.Write(payload)
+		return
+	}
+	message.Error.Data = data
+
+	// encode in place, the encoder terminates the message with a newline
+	// the single line framing has no room for
+	start := buf.Len()
+	if err := json.NewEncoder(buf).Encode(message); err != nil {
+		buf.Truncate(start)
+		buf.Write(payload)
+		return
+	}
+	buf.Truncate(buf.Len() - 1)
+}
+
+// Flush implements http.Flusher, which the SSE server requires.
+func (w *errorDataWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *errorDataWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
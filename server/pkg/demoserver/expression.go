@@ -0,0 +1,726 @@
+package demoserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	EVALUATE_EXPRESSION ToolName = "evaluate_expression"
+
+	// DefaultExpressionPrecision is the number of decimal places of the
+	// results.
+	DefaultExpressionPrecision = 10
+
+	maxExpressionLength    = 1000
+	maxExpressionDepth     = 100
+	maxExpressionPrecision = 1000
+	// maxExpressionBits bounds the numerators and denominators, so exact
+	// arithmetic cannot be made to exhaust the memory, i.e. with 9^9^9
+	maxExpressionBits = 1 << 16
+)
+
+// Unit is a unit of the expressions, Factor converts it to the base unit of
+// its dimension.
+type Unit struct {
+	Name      string
+	Dimension string
+	Factor    *big.Rat
+}
+
+func newUnit(name, dimension, factor string) Unit {
+	f, _ := new(big.Rat).SetString(factor)
+	return Unit{Name: name, Dimension: dimension, Factor: f}
+}
+
+// Units are the units known to evaluate_expression. Temperatures are left
+// out as their scales don't share a zero.
+var Units = func() map[string]Unit {
+	units := make(map[string]Unit)
+	for _, u := range []Unit{
+		newUnit("mm", "length", "0.001"),
+		newUnit("cm", "length", "0.01"),
+		newUnit("m", "length", "1"),
+		newUnit("km", "length", "1000"),
+		newUnit("in", "length", "0.0254"),
+		newUnit("ft", "length", "0.3048"),
+		newUnit("yd", "length", "0.9144"),
+		newUnit("mi", "length", "1609.344"),
+		newUnit("mg", "mass", "0.000001"),
+		newUnit("g", "mass", "0.001"),
+		newUnit("kg", "mass", "1"),
+		newUnit("t", "mass", "1000"),
+		newUnit("oz", "mass", "0.028349523125"),
+		newUnit("lb", "mass", "0.45359237"),
+		newUnit("ms", "time", "0.001"),
+		newUnit("s", "time", "1"),
+		newUnit("min", "time", "60"),
+		newUnit("h", "time", "3600"),
+		newUnit("d", "time", "86400"),
+		newUnit("B", "data", "1"),
+		newUnit("KB", "data", "1000"),
+		newUnit("MB", "data", "1000000"),
+		newUnit("GB", "data", "1000000000"),
+		newUnit("TB", "data", "1000000000000"),
+		newUnit("KiB", "data", "1024"),
+		newUnit("MiB", "data", "1048576"),
+		newUnit("GiB", "data", "1073741824"),
+		newUnit("TiB", "data", "1099511627776"),
+	} {
+		units[u.Name] = u
+	}
+	return units
+}()
+
+// ExpressionResult is the structured result of evaluate_expression.
+type ExpressionResult struct {
+	Expression string `json:"expression"`
+	// Value is the decimal result rounded to the precision.
+	Value string `json:"value"`
+	// Fraction is the exact result of non-integer rationals, i.e. 1/3.
+	Fraction string `json:"fraction,omitempty"`
+	Unit     string `json:"unit,omitempty"`
+	// Exact is false when Value was rounded or a function such as sqrt
+	// approximated the result.
+	Exact bool `json:"exact"`
+}
+
+func (s *Server) registerExpressionTool() {
+	s.AddTool(mcp.NewTool(string(EVALUATE_EXPRESSION),
+		mcp.WithDescription("Evaluates an arithmetic expression with exact decimal arithmetic, variables and units, i.e. (a + 2.5) * 3 or 3 km + 200 m to mi"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithString("expression",
+			mcp.Description("Expression of numbers, variables, units, + - * / % ^, parentheses and abs, min, max, round, floor, ceil, sqrt. A trailing \"to <unit>\" converts the result"),
+			mcp.Required(),
+		),
+		mcp.WithObject("variables",
+			mcp.Description("Values of the variables of the expression, numbers or decimal strings"),
+		),
+		mcp.WithNumber("precision",
+			mcp.Description("Decimal places of the result, 10 by default"),
+			mcp.Min(0),
+			mcp.Max(maxExpressionPrecision),
+		),
+	), handleEvaluateExpression)
+	s.addToolExamples(string(EVALUATE_EXPRESSION),
+		ToolExample{
+			Description: "Exact decimal arithmetic",
+			Arguments:   map[string]any{"expression": "0.1 + 0.2"},
+			Result:      `{"expression":"0.1 + 0.2","value":"0.3","fraction":"3/10","exact":true}`,
+		},
+		ToolExample{
+			Description: "Variables",
+			Arguments:   map[string]any{"expression": "(a + 2.5) * 3", "variables": map[string]any{"a": 1.5}},
+			Result:      `{"expression":"(a + 2.5) * 3","value":"12","exact":true}`,
+		},
+		ToolExample{
+			Description: "Unit conversion",
+			Arguments:   map[string]any{"expression": "3 km + 200 m to mi", "precision": 4},
+			Result:      `{"expression":"3 km + 200 m to mi","value":"1.9884","fraction":"25000/12573","unit":"mi","exact":false}`,
+		},
+	)
+	s.addOutputSchema(string(EVALUATE_EXPRESSION), json.RawMessage(`{
+		"type": "object",
+		"required": ["expression", "value", "exact"],
+		"additionalProperties": false,
+		"properties": {
+			"expression": {"type": "string"},
+			"value": {"type": "string"},
+			"fraction": {"type": "string"},
+			"unit": {"type": "string"},
+			"exact": {"type": "boolean"}
+		}
+	}`))
+}
+
+func handleEvaluateExpression(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	expression := request.GetString("expression", "")
+	variables := make(map[string]*big.Rat)
+	if raw, ok := request.GetArguments()["variables"].(map[string]any); ok {
+		for name, value := range raw {
+			rat, ok := ratFromJSON(value)
+			if !ok {
+				return nil, localizedError(ctx, "error.invalid_expression", fmt.Sprintf("variable %s is not a number", name))
+			}
+			variables[name] = rat
+		}
+	}
+	precision := request.GetInt("precision", DefaultExpressionPrecision)
+	if precision < 0 || precision > maxExpressionPrecision {
+		return nil, localizedError(ctx, "error.invalid_expression", fmt.Sprintf("precision must be between 0 and %d", maxExpressionPrecision))
+	}
+
+	result, err := EvaluateExpression(expression, variables, precision)
+	if err != nil {
+		return nil, localizedError(ctx, "error.invalid_expression", err.Error())
+	}
+	body, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the result: %w", err)
+	}
+	return mcp.NewToolResultText(string(body)), nil
+}
+
+func ratFromJSON(value any) (*big.Rat, bool) {
+	switch v := value.(type) {
+	case float64:
+		// the shortest decimal, so 0.1 is not its binary approximation
+		return parseDecimal(strconv.FormatFloat(v, 'g', -1, 64))
+	case string:
+		return parseDecimal(strings.TrimSpace(v))
+	}
+	return nil, false
+}
+
+// decimalPattern bounds the exponents, big.Rat would expand 1e999999999.
+var decimalPattern = regexp.MustCompile(`^[+-]?(\d+\.?\d*|\.\d+)([eE][+-]?\d{1,4})?$`)
+
+func parseDecimal(s string) (*big.Rat, bool) {
+	if !decimalPattern.MatchString(s) {
+		return nil, false
+	}
+	return new(big.Rat).SetString(s)
+}
+
+// EvaluateExpression evaluates expression with exact rational arithmetic and
+// rounds the result to precision decimal places.
+func EvaluateExpression(expression string, variables map[string]*big.Rat, precision int) (ExpressionResult, error) {
+	if len(expression) > maxExpressionLength {
+		return ExpressionResult{}, fmt.Errorf("expression longer than %d characters", maxExpressionLength)
+	}
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return ExpressionResult{}, err
+	}
+	p := &expressionParser{tokens: tokens, variables: variables, exact: true}
+	q, err := p.parseConversion()
+	if err != nil {
+		return ExpressionResult{}, err
+	}
+	if p.pos < len(p.tokens) {
+		return ExpressionResult{}, fmt.Errorf("unexpected %q", p.tokens[p.pos].text)
+	}
+
+	result := ExpressionResult{
+		Expression: expression,
+		Value:      formatRat(q.value, precision),
+		Exact:      p.exact,
+	}
+	if q.unit != nil {
+		result.Unit = q.unit.Name
+	}
+	if !q.value.IsInt() && p.exact {
+		result.Fraction = q.value.RatString()
+	}
+	// rounded unless the decimal expansion ends within the precision
+	scaled := new(big.Rat).Mul(q.value, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(precision)), nil)))
+	if !scaled.IsInt() {
+		result.Exact = false
+	}
+	return result, nil
+}
+
+// formatRat formats r with precision decimal places, without trailing zeros.
+func formatRat(r *big.Rat, precision int) string {
+	s := r.FloatString(precision)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	if s == "-0" {
+		s = "0"
+	}
+	return s
+}
+
+type tokenKind int
+
+const (
+	tokenNumber tokenKind = iota
+	tokenIdent
+	tokenOperator
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+func tokenize(expression string) ([]token, error) {
+	var tokens []token
+	runes := []rune(expression)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsDigit(r) || r == '.':
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.' || runes[i] == '_') {
+				i++
+			}
+			// exponents such as 1e6, but not the unit of 5 e
+			if i+1 < len(runes) && (runes[i] == 'e' || runes[i] == 'E') &&
+				(unicode.IsDigit(runes[i+1]) || (i+2 < len(runes) && (runes[i+1] == '-' || runes[i+1] == '+') && unicode.IsDigit(runes[i+2]))) {
+				i += 2
+				for i < len(runes) && unicode.IsDigit(runes[i]) {
+					i++
+				}
+			}
+			tokens = append(tokens, token{tokenNumber, strings.ReplaceAll(string(runes[start:i]), "_", "")})
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, token{tokenIdent, string(runes[start:i])})
+		case strings.ContainsRune("+-*/%^(),", r):
+			tokens = append(tokens, token{tokenOperator, string(r)})
+			i++
+		default:
+			return nil, fmt.Errorf("unexpected character %q", r)
+		}
+	}
+	return tokens, nil
+}
+
+// quantity is a value with an optional unit, values with a unit are kept in
+// that unit.
+type quantity struct {
+	value *big.Rat
+	unit  *Unit
+}
+
+// expressionParser evaluates while it parses, by recursive descent.
+type expressionParser struct {
+	tokens    []token
+	pos       int
+	depth     int
+	variables map[string]*big.Rat
+	// exact is cleared by approximating functions
+	exact bool
+}
+
+var errUnexpectedEnd = errors.New("unexpected end of the expression")
+
+func (p *expressionParser) peek() (token, bool) {
+	if p.pos >= len(p.tokens) {
+		return token{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *expressionParser) operator(ops string) (string, bool) {
+	t, ok := p.peek()
+	if !ok || t.kind != tokenOperator || !strings.Contains(ops, t.text) {
+		return "", false
+	}
+	p.pos++
+	return t.text, true
+}
+
+func (p *expressionParser) expect(op string) error {
+	if _, ok := p.operator(op); !ok {
+		if t, ok := p.peek(); ok {
+			return fmt.Errorf("expected %q, got %q", op, t.text)
+		}
+		return errUnexpectedEnd
+	}
+	return nil
+}
+
+// parseConversion parses sum ["to" unit].
+func (p *expressionParser) parseConversion() (quantity, error) {
+	q, err := p.parseSum()
+	if err != nil {
+		return quantity{}, err
+	}
+	t, ok := p.peek()
+	if !ok || t.kind != tokenIdent || (t.text != "to" && t.text != "in") {
+		return q, nil
+	}
+	p.pos++
+	t, ok = p.peek()
+	if !ok {
+		return quantity{}, errUnexpectedEnd
+	}
+	unit, known := Units[t.text]
+	if !known {
+		return quantity{}, fmt.Errorf("unknown unit %q", t.text)
+	}
+	p.pos++
+	return convert(q, &unit)
+}
+
+func convert(q quantity, to *Unit) (quantity, error) {
+	if q.unit == nil {
+		return quantity{}, fmt.Errorf("cannot convert a number without unit to %s", to.Name)
+	}
+	if q.unit.Dimension != to.Dimension {
+		return quantity{}, fmt.Errorf("cannot convert %s to %s", q.unit.Name, to.Name)
+	}
+	value := new(big.Rat).Mul(q.value, q.unit.Factor)
+	return quantity{value: value.Quo(value, to.Factor), unit: to}, nil
+}
+
+func (p *expressionParser) parseSum() (quantity, error) {
+	left, err := p.parseProduct()
+	if err != nil {
+		return quantity{}, err
+	}
+	for {
+		op, ok := p.operator("+-")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseProduct()
+		if err != nil {
+			return quantity{}, err
+		}
+		// the result is in the unit of the left operand
+		switch {
+		case left.unit == nil && right.unit == nil:
+		case left.unit == nil || right.unit == nil:
+			return quantity{}, fmt.Errorf("cannot add a number with and without unit")
+		default:
+			if right, err = convert(right, left.unit); err != nil {
+				return quantity{}, err
+			}
+		}
+		value := new(big.Rat)
+		if op == "+" {
+			value.Add(left.value, right.value)
+		} else {
+			value.Sub(left.value, right.value)
+		}
+		left = quantity{value: value, unit: left.unit}
+	}
+}
+
+func (p *expressionParser) parseProduct() (quantity, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return quantity{}, err
+	}
+	for {
+		op, ok := p.operator("*/%")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return quantity{}, err
+		}
+		if left, err = multiply(op, left, right); err != nil {
+			return quantity{}, err
+		}
+		if err := checkSize(left.value); err != nil {
+			return quantity{}, err
+		}
+	}
+}
+
+// multiply applies op, scaling quantities by plain numbers. Dividing
+// quantities of the same dimension gives their ratio, other combinations
+// of units are not supported.
+func multiply(op string, left, right quantity) (quantity, error) {
+	unit := left.unit
+	switch {
+	case right.unit == nil:
+	case left.unit == nil && op == "*":
+		unit = right.unit
+	case left.unit != nil && op == "/" && left.unit.Dimension == right.unit.Dimension:
+		var err error
+		if right, err = convert(right, left.unit); err != nil {
+			return quantity{}, err
+		}
+		unit = nil
+	default:
+		return quantity{}, fmt.Errorf("unsupported combination of units with %s", op)
+	}
+
+	value := new(big.Rat)
+	switch op {
+	case "*":
+		value.Mul(left.value, right.value)
+	case "/", "%":
+		if right.value.Sign() == 0 {
+			return quantity{}, errors.New("division by zero")
+		}
+		value.Quo(left.value, right.value)
+		if op == "%" {
+			// truncated like Go's %, so the result has the sign of left
+			whole := new(big.Int).Quo(value.Num(), value.Denom())
+			value.Sub(left.value, new(big.Rat).Mul(new(big.Rat).SetInt(whole), right.value))
+		}
+	}
+	return quantity{value: value, unit: unit}, nil
+}
+
+func (p *expressionParser) parseUnary() (quantity, error) {
+	if op, ok := p.operator("+-"); ok {
+		q, err := p.parseUnary()
+		if err != nil {
+			return quantity{}, err
+		}
+		if op == "-" {
+			q.value = new(big.Rat).Neg(q.value)
+		}
+		return q, nil
+	}
+	return p.parsePower()
+}
+
+// parsePower parses postfix ["^" unary], right associative.
+func (p *expressionParser) parsePower() (quantity, error) {
+	base, err := p.parsePostfix()
+	if err != nil {
+		return quantity{}, err
+	}
+	if _, ok := p.operator("^"); !ok {
+		return base, nil
+	}
+	exponent, err := p.parseUnary()
+	if err != nil {
+		return quantity{}, err
+	}
+	if base.unit != nil || exponent.unit != nil {
+		return quantity{}, errors.New("units cannot be raised to a power")
+	}
+	if !exponent.value.IsInt() || exponent.value.Num().BitLen() > 16 {
+		return quantity{}, errors.New("exponents must be integers between -65535 and 65535")
+	}
+	n := exponent.value.Num().Int64()
+	if base.value.Sign() == 0 && n < 0 {
+		return quantity{}, errors.New("division by zero")
+	}
+	abs := n
+	if abs < 0 {
+		abs = -abs
+	}
+	// estimate the size before computing it
+	if int64(max(base.value.Num().BitLen(), base.value.Denom().BitLen()))*abs > maxExpressionBits {
+		return quantity{}, errors.New("result too large")
+	}
+	num := new(big.Int).Exp(base.value.Num(), big.NewInt(abs), nil)
+	denom := new(big.Int).Exp(base.value.Denom(), big.NewInt(abs), nil)
+	if n < 0 {
+		num, denom = denom, num
+	}
+	return quantity{value: new(big.Rat).SetFrac(num, denom)}, nil
+}
+
+// parsePostfix parses a primary with an optional unit, i.e. 5 km.
+func (p *expressionParser) parsePostfix() (quantity, error) {
+	q, err := p.parsePrimary()
+	if err != nil {
+		return quantity{}, err
+	}
+	t, ok := p.peek()
+	if !ok || t.kind != tokenIdent || q.unit != nil {
+		return q, nil
+	}
+	unit, known := Units[t.text]
+	if !known {
+		return q, nil
+	}
+	if next := p.pos + 1; next < len(p.tokens) && p.tokens[next].text == "(" {
+		// min(...) is a function, not minutes
+		return q, nil
+	}
+	p.pos++
+	q.unit = &unit
+	return q, nil
+}
+
+func (p *expressionParser) parsePrimary() (quantity, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxExpressionDepth {
+		return quantity{}, errors.New("expression nested too deeply")
+	}
+
+	t, ok := p.peek()
+	if !ok {
+		return quantity{}, errUnexpectedEnd
+	}
+	p.pos++
+	switch {
+	case t.kind == tokenNumber:
+		value, ok := parseDecimal(t.text)
+		if !ok {
+			return quantity{}, fmt.Errorf("invalid number %q", t.text)
+		}
+		if err := checkSize(value); err != nil {
+			return quantity{}, err
+		}
+		return quantity{value: value}, nil
+	case t.kind == tokenOperator && t.text == "(":
+		q, err := p.parseConversion()
+		if err != nil {
+			return quantity{}, err
+		}
+		return q, p.expect(")")
+	case t.kind == tokenIdent:
+		if next, ok := p.peek(); ok && next.text == "(" {
+			return p.parseCall(t.text)
+		}
+		if value, ok := p.variables[t.text]; ok {
+			return quantity{value: value}, nil
+		}
+		if unit, ok := Units[t.text]; ok {
+			return quantity{value: big.NewRat(1, 1), unit: &unit}, nil
+		}
+		return quantity{}, fmt.Errorf("unknown variable %q", t.text)
+	}
+	return quantity{}, fmt.Errorf("unexpected %q", t.text)
+}
+
+func (p *expressionParser) parseCall(name string) (quantity, error) {
+	p.pos++ // (
+	var args []quantity
+	for {
+		arg, err := p.parseConversion()
+		if err != nil {
+			return quantity{}, err
+		}
+		args = append(args, arg)
+		if _, ok := p.operator(","); !ok {
+			break
+		}
+	}
+	if err := p.expect(")"); err != nil {
+		return quantity{}, err
+	}
+	return p.call(name, args)
+}
+
+func (p *expressionParser) call(name string, args []quantity) (quantity, error) {
+	arity := func(n int) error {
+		if len(args) != n {
+			return fmt.Errorf("%s takes %d arguments, got %d", name, n, len(args))
+		}
+		return nil
+	}
+	switch name {
+	case "abs", "floor", "ceil", "sqrt":
+		if err := arity(1); err != nil {
+			return quantity{}, err
+		}
+		q := args[0]
+		switch name {
+		case "abs":
+			return quantity{value: new(big.Rat).Abs(q.value), unit: q.unit}, nil
+		case "floor", "ceil":
+			return quantity{value: roundRat(q.value, 0, name), unit: q.unit}, nil
+		default:
+			if q.unit != nil {
+				return quantity{}, errors.New("sqrt takes a number without unit")
+			}
+			if q.value.Sign() < 0 {
+				return quantity{}, errors.New("sqrt of a negative number")
+			}
+			return quantity{value: p.sqrt(q.value)}, nil
+		}
+	case "round":
+		if len(args) != 1 && len(args) != 2 {
+			return quantity{}, fmt.Errorf("round takes 1 or 2 arguments, got %d", len(args))
+		}
+		places := 0
+		if len(args) == 2 {
+			if args[1].unit != nil || !args[1].value.IsInt() || args[1].value.Num().BitLen() > 10 {
+				return quantity{}, errors.New("round takes an integer number of places")
+			}
+			places = int(args[1].value.Num().Int64())
+		}
+		return quantity{value: roundRat(args[0].value, places, name), unit: args[0].unit}, nil
+	case "min", "max":
+		if len(args) == 0 {
+			return quantity{}, fmt.Errorf("%s takes at least 1 argument", name)
+		}
+		best := args[0]
+		for _, arg := range args[1:] {
+			if (arg.unit == nil) != (best.unit == nil) {
+				return quantity{}, fmt.Errorf("%s of numbers with and without unit", name)
+			}
+			if arg.unit != nil {
+				var err error
+				if arg, err = convert(arg, best.unit); err != nil {
+					return quantity{}, err
+				}
+			}
+			if c := arg.value.Cmp(best.value); (name == "min" && c < 0) || (name == "max" && c > 0) {
+				best = arg
+			}
+		}
+		return best, nil
+	}
+	return quantity{}, fmt.Errorf("unknown function %q", name)
+}
+
+// sqrt approximates the square root with twice the precision of the
+// largest result, and marks the result inexact unless it is a perfect
+// square.
+func (p *expressionParser) sqrt(value *big.Rat) *big.Rat {
+	num, denom := value.Num(), value.Denom()
+	rootNum, rootDenom := new(big.Int).Sqrt(num), new(big.Int).Sqrt(denom)
+	if new(big.Int).Mul(rootNum, rootNum).Cmp(num) == 0 && new(big.Int).Mul(rootDenom, rootDenom).Cmp(denom) == 0 {
+		return new(big.Rat).SetFrac(rootNum, rootDenom)
+	}
+	p.exact = false
+	f := new(big.Float).SetPrec(4 * maxExpressionPrecision).SetRat(value)
+	root, _ := f.Sqrt(f).Rat(nil)
+	return root
+}
+
+// roundRat rounds r to places decimal places, half away from zero for
+// round.
+func roundRat(r *big.Rat, places int, mode string) *big.Rat {
+	scale := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(places))), nil))
+	if places < 0 {
+		scale.Inv(scale)
+	}
+	scaled := new(big.Rat).Mul(r, scale)
+	num, denom := scaled.Num(), scaled.Denom()
+	quotient, remainder := new(big.Int).QuoRem(num, denom, new(big.Int))
+	if remainder.Sign() != 0 {
+		switch mode {
+		case "floor":
+			if num.Sign() < 0 {
+				quotient.Sub(quotient, big.NewInt(1))
+			}
+		case "ceil":
+			if num.Sign() > 0 {
+				quotient.Add(quotient, big.NewInt(1))
+			}
+		default:
+			// compare twice the remainder with the denominator
+			if new(big.Int).Abs(new(big.Int).Lsh(remainder, 1)).Cmp(denom) >= 0 {
+				quotient.Add(quotient, big.NewInt(int64(num.Sign())))
+			}
+		}
+	}
+	return new(big.Rat).Quo(new(big.Rat).SetInt(quotient), scale)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func checkSize(r *big.Rat) error {
+	if r.Num().BitLen() > maxExpressionBits || r.Denom().BitLen() > maxExpressionBits {
+		return errors.New("result too large")
+	}
+	return nil
+}
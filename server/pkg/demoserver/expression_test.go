@@ -0,0 +1,165 @@
+package demoserver
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestEvaluateExpression(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       ExpressionResult
+	}{
+		// precedence and associativity
+		{"1 + 2 * 3", ExpressionResult{Value: "7", Exact: true}},
+		{"(1 + 2) * 3", ExpressionResult{Value: "9", Exact: true}},
+		{"10 - 4 - 3", ExpressionResult{Value: "3", Exact: true}},
+		{"24 / 4 / 2", ExpressionResult{Value: "3", Exact: true}},
+		{"2 ^ 3 ^ 2", ExpressionResult{Value: "512", Exact: true}},
+		{"-2 ^ 2", ExpressionResult{Value: "-4", Exact: true}},
+		{"2 ^ -2", ExpressionResult{Value: "0.25", Fraction: "1/4", Exact: true}},
+		{"2 * 3 % 4", ExpressionResult{Value: "2", Exact: true}},
+		{"-7 % 3", ExpressionResult{Value: "-1", Exact: true}},
+		{"1 - -1", ExpressionResult{Value: "2", Exact: true}},
+		// exact decimals and rounding
+		{"0.1 + 0.2", ExpressionResult{Value: "0.3", Fraction: "3/10", Exact: true}},
+		{"1 / 3", ExpressionResult{Value: "0.3333333333", Fraction: "1/3", Exact: false}},
+		{"1_000 * 1e3", ExpressionResult{Value: "1000000", Exact: true}},
+		// functions
+		{"max(1, 5, 3) - min(4, 2)", ExpressionResult{Value: "3", Exact: true}},
+		{"round(2.5) + floor(-1.5) + ceil(1.2) + abs(-3)", ExpressionResult{Value: "6", Exact: true}},
+		{"round(3.14159, 2)", ExpressionResult{Value: "3.14", Fraction: "157/50", Exact: true}},
+		{"sqrt(9/4)", ExpressionResult{Value: "1.5", Fraction: "3/2", Exact: true}},
+		{"sqrt(2)", ExpressionResult{Value: "1.4142135624", Exact: false}},
+		// units
+		{"3 km + 200 m", ExpressionResult{Value: "3.2", Fraction: "16/5", Unit: "km", Exact: true}},
+		{"90 min to h", ExpressionResult{Value: "1.5", Fraction: "3/2", Unit: "h", Exact: true}},
+		{"1 GiB / 1 MiB", ExpressionResult{Value: "1024", Exact: true}},
+		{"2 * 3 m", ExpressionResult{Value: "6", Unit: "m", Exact: true}},
+		// in the unit of the first argument
+		{"min(1 km, 500 m)", ExpressionResult{Value: "0.5", Fraction: "1/2", Unit: "km", Exact: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			got, err := EvaluateExpression(tt.expression, nil, DefaultExpressionPrecision)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.want.Expression = tt.expression
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateExpressionVariables(t *testing.T) {
+	variables := map[string]*big.Rat{"a": big.NewRat(3, 2), "rate": big.NewRat(1, 10)}
+	got, err := EvaluateExpression("(a + 2.5) * rate", variables, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Value != "0.4" || got.Fraction != "2/5" {
+		t.Errorf("got %+v, want 0.4", got)
+	}
+	if _, err := EvaluateExpression("a + b", variables, 2); err == nil || !strings.Contains(err.Error(), `unknown variable "b"`) {
+		t.Errorf("got %v, want the unknown variable b", err)
+	}
+}
+
+func TestEvaluateExpressionErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		wantErr    string
+	}{
+		{"division by zero", "1 / 0", "division by zero"},
+		{"division by a zero expression", "1 / (2 - 2)", "division by zero"},
+		{"modulo by zero", "5 % 0", "division by zero"},
+		{"zero to a negative power", "0 ^ -1", "division by zero"},
+		{"empty", "", "unexpected end"},
+		{"trailing operator", "1 +", "unexpected end"},
+		{"unbalanced open", "(1 + 2", "unexpected end"},
+		{"unbalanced close", "1 + 2)", `unexpected ")"`},
+		{"missing operator", "1 2", `unexpected "2"`},
+		{"invalid character", "1 & 2", "unexpected character"},
+		{"invalid number", "1.2.3", "invalid number"},
+		{"empty arguments", "max()", `unexpected ")"`},
+		{"unknown function", "foo(1)", "unknown function"},
+		{"arity", "abs(1, 2)", "abs takes 1 arguments"},
+		{"negative sqrt", "sqrt(-1)", "sqrt of a negative number"},
+		{"fractional exponent", "2 ^ 0.5", "exponents must be integers"},
+		{"huge exponent", "2 ^ 100000", "exponents must be integers"},
+		{"huge result", "9 ^ 9 ^ 9", "exponents must be integers"},
+		{"huge power", "1e4000 ^ 100", "result too large"},
+		{"huge number", "1e9999 * 1e9999 * 1e9999", "result too large"},
+		{"number without unit", "5 to km", "without unit"},
+		{"incompatible units", "1 km + 1 kg", "cannot convert kg to km"},
+		{"unit and number", "1 km + 1", "with and without unit"},
+		{"unknown unit", "1 km to parsec", "unknown unit"},
+		{"unit power", "2 m ^ 2", "units cannot be raised"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := EvaluateExpression(tt.expression, nil, DefaultExpressionPrecision)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("EvaluateExpression(%q) = %+v, %v, want an error containing %q", tt.expression, got, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestEvaluateExpressionLimits checks the bounds on the nesting and the
+// length of the expressions.
+func TestEvaluateExpressionLimits(t *testing.T) {
+	nested := func(depth int) string {
+		return strings.Repeat("(", depth) + "1" + strings.Repeat(")", depth)
+	}
+	if _, err := EvaluateExpression(nested(maxExpressionDepth-1), nil, 0); err != nil {
+		t.Errorf("nesting of %d: %v", maxExpressionDepth-1, err)
+	}
+	if _, err := EvaluateExpression(nested(maxExpressionDepth), nil, 0); err == nil || !strings.Contains(err.Error(), "nested too deeply") {
+		t.Errorf("nesting of %d: got %v, want nested too deeply", maxExpressionDepth, err)
+	}
+	// unary operators and function calls nest as well
+	if _, err := EvaluateExpression(strings.Repeat("abs(", 200)+"1"+strings.Repeat(")", 200), nil, 0); err == nil {
+		t.Error("nesting of 200 calls succeeded")
+	}
+	if _, err := EvaluateExpression(strings.Repeat("-", maxExpressionLength), nil, 0); err == nil {
+		t.Error("a chain of unary operators succeeded")
+	}
+
+	long := strings.Repeat("1+", maxExpressionLength/2)
+	if _, err := EvaluateExpression(long[:maxExpressionLength-1], nil, 0); err != nil {
+		t.Errorf("expression of %d characters: %v", maxExpressionLength-1, err)
+	}
+	if _, err := EvaluateExpression(long+"1", nil, 0); err == nil || !strings.Contains(err.Error(), "longer than") {
+		t.Errorf("expression of %d characters: got %v, want too long", len(long)+1, err)
+	}
+}
+
+func TestHandleEvaluateExpression(t *testing.T) {
+	s, err := New(WithTransport(TransportHTTP))
+	if err != nil {
+		t.Fatal(err)
+	}
+	response := callTool(t, s, string(EVALUATE_EXPRESSION), map[string]any{
+		"expression": "a * b",
+		"variables":  map[string]any{"a": 0.1, "b": "3"},
+		"precision":  2,
+	})
+	if text, ok := resultText(response); !ok || text != `{"expression":"a * b","value":"0.3","fraction":"3/10","exact":true}` {
+		t.Errorf("call returned %v", response)
+	}
+	for _, arguments := range []map[string]any{
+		{"expression": "a", "variables": map[string]any{"a": "1e99999"}},
+		{"expression": "a", "variables": map[string]any{"a": true}},
+		{"expression": "1", "precision": maxExpressionPrecision + 1},
+		{"expression": "1 / 0"},
+	} {
+		if _, ok := resultText(callTool(t, s, string(EVALUATE_EXPRESSION), arguments)); ok {
+			t.Errorf("%v succeeded", arguments)
+		}
+	}
+}
@@ -0,0 +1,407 @@
+package demoserver
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"html"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxExtractedStream bounds the decompressed size of a PDF stream.
+const maxExtractedStream = 16 << 20
+
+// Extractor turns the contents of a document into plain text.
+type Extractor func(data []byte) (string, error)
+
+// Extractors maps the file extensions of documents to their extractor and
+// MIME type. Files with other extensions are skipped.
+var Extractors = map[string]struct {
+	MIMEType string
+	Extract  Extractor
+}{
+	".txt":      {"text/plain", extractPlainText},
+	".md":       {"text/markdown", extractPlainText},
+	".markdown": {"text/markdown", extractPlainText},
+	".html":     {"text/html", ExtractHTMLText},
+	".htm":      {"text/html", ExtractHTMLText},
+	".pdf":      {"application/pdf", ExtractPDFText},
+}
+
+func extractorOf(path string) (Extractor, string, bool) {
+	extractor, ok := Extractors[strings.ToLower(filepath.Ext(path))]
+	return extractor.Extract, extractor.MIMEType, ok
+}
+
+func extractPlainText(data []byte) (string, error) {
+	if !utf8.Valid(data) {
+		return "", fmt.Errorf("not UTF-8 text")
+	}
+	return strings.ReplaceAll(string(data), "\r\n", "\n"), nil
+}
+
+// htmlBlockTags start a new line of text.
+var htmlBlockTags = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true, "br": true, "dd": true,
+	"div": true, "dl": true, "dt": true, "figcaption": true, "footer": true, "h1": true,
+	"h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "header": true, "hr": true,
+	"li": true, "main": true, "nav": true, "ol": true, "p": true, "pre": true, "section": true,
+	"table": true, "td": true, "th": true, "title": true, "tr": true, "ul": true,
+}
+
+// htmlSkippedTags hide their contents.
+var htmlSkippedTags = map[string]bool{"script": true, "style": true, "noscript": true, "template": true, "svg": true}
+
+var htmlTagName = regexp.MustCompile(`^</?\s*([a-zA-Z][a-zA-Z0-9]*)`)
+
+// ExtractHTMLText returns the visible text of an HTML document, one line per
+// block element. Invalid UTF-8 is replaced, as documents in other charsets
+// are not converted.
+func ExtractHTMLText(data []byte) (string, error) {
+	document := strings.ToValidUTF8(string(data), "\uFFFD")
+	var text strings.Builder
+	for len(document) > 0 {
+		start := strings.IndexByte(document, '<')
+		if start < 0 {
+			text.WriteString(html.UnescapeString(document))
+			break
+		}
+		text.WriteString(html.UnescapeString(document[:start]))
+		document = document[start:]
+
+		if strings.HasPrefix(document, "<!--") {
+			end := strings.Index(document, "-->")
+			if end < 0 {
+				break
+			}
+			document = document[end+3:]
+			continue
+		}
+		end := strings.IndexByte(document, '>')
+		if end < 0 {
+			break
+		}
+		tag := document[:end+1]
+		document = document[end+1:]
+
+		match := htmlTagName.FindStringSubmatch(tag)
+		if match == nil {
+			continue
+		}
+		name := strings.ToLower(match[1])
+		closing := strings.HasPrefix(tag, "</")
+		if htmlSkippedTags[name] && !closing && !strings.HasSuffix(tag, "/>") {
+			closeTag := indexClosingTag(document, name)
+			if closeTag < 0 {
+				break
+			}
+			document = document[closeTag:]
+			continue
+		}
+		if htmlBlockTags[name] {
+			text.WriteByte('\n')
+		} else if name == "img" || name == "input" {
+			text.WriteByte(' ')
+		}
+	}
+	return collapseWhitespace(text.String()), nil
+}
+
+// indexClosingTag returns the index of the first closing tag of name in
+// document, in any case, or -1.
+func indexClosingTag(document, name string) int {
+	for offset := 0; ; {
+		i := strings.Index(document[offset:], "</")
+		if i < 0 {
+			return -1
+		}
+		offset += i
+		if end := offset + 2 + len(name); end <= len(document) && strings.EqualFold(document[offset+2:end], name) {
+			return offset
+		}
+		offset += 2
+	}
+}
+
+// collapseWhitespace collapses the runs of spaces within lines and of empty
+// lines.
+func collapseWhitespace(text string) string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.Join(strings.FieldsFunc(line, unicode.IsSpace), " ")
+		if line == "" && (len(lines) == 0 || lines[len(lines)-1] == "") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+var (
+	pdfStream = regexp.MustCompile(`>>\s*stream\r?\n`)
+	pdfLength = regexp.MustCompile(`/Length\s+(\d+)(\s+\d+\s+R)?`)
+)
+
+// ExtractPDFText returns the text drawn by the content streams of a PDF, in
+// the order of the streams in the file. Only uncompressed and Flate streams
+// are read, and text is decoded as single-byte strings, so fonts with custom
+// encodings, as most CID fonts, come out garbled or empty; scanned documents
+// have no text at all.
+func ExtractPDFText(data []byte) (string, error) {
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		return "", fmt.Errorf("not a PDF document")
+	}
+	var text strings.Builder
+	for _, match := range pdfStream.FindAllIndex(data, -1) {
+		// the dictionary of the stream follows the header of its object
+		header := bytes.LastIndex(data[:match[0]], []byte("obj"))
+		if header < 0 {
+			continue
+		}
+		dictionary := data[header:match[0]]
+		if bytes.Contains(dictionary, []byte("/Subtype")) || bytes.Contains(dictionary, []byte("/Type")) {
+			// images, fonts, object and xref streams
+			continue
+		}
+		body := data[match[1]:]
+		// an indirect length is not resolved, the stream ends at endstream
+		if length := pdfLength.FindSubmatch(dictionary); length != nil && length[2] == nil {
+			if n, err := strconv.Atoi(string(length[1])); err == nil && n <= len(body) {
+				body = body[:n]
+			}
+		} else if end := bytes.Index(body, []byte("endstream")); end >= 0 {
+			body = body[:end]
+		}
+
+		switch {
+		case bytes.Contains(dictionary, []byte("/FlateDecode")):
+			if hasOtherPDFFilter(dictionary) {
+				continue
+			}
+			reader, err := zlib.NewReader(bytes.NewReader(body))
+			if err != nil {
+				continue
+			}
+			// truncated streams still yield their text
+			body, _ = io.ReadAll(io.LimitReader(reader, maxExtractedStream))
+		case bytes.Contains(dictionary, []byte("/Filter")):
+			continue
+		}
+		if page := pdfContentText(body); page != "" {
+			text.WriteString(page)
+			text.WriteString("\n\n")
+		}
+	}
+	return collapseWhitespace(text.String()), nil
+}
+
+// hasOtherPDFFilter reports whether a Flate stream is also encoded with
+// another filter, i.e. /Filter [/FlateDecode /DCTDecode].
+func hasOtherPDFFilter(dictionary []byte) bool {
+	for _, filter := range []string{"/DCTDecode", "/JPXDecode", "/JBIG2Decode", "/CCITTFaxDecode", "/LZWDecode", "/ASCII85Decode", "/ASCIIHexDecode", "/RunLengthDecode"} {
+		if bytes.Contains(dictionary, []byte(filter)) {
+			return true
+		}
+	}
+	return false
+}
+
+// pdfOperand is an operand of a content stream operator, text for strings.
+type pdfOperand struct {
+	value string
+	text  bool
+}
+
+// pdfContentText interprets the text operators of a content stream.
+func pdfContentText(content []byte) string {
+	var text strings.Builder
+	var operands []pdfOperand
+	inText := false
+	for i := 0; i < len(content); {
+		c := content[i]
+		switch {
+		case c == '(':
+			s, n := pdfLiteralString(content[i:])
+			operands = append(operands, pdfOperand{s, true})
+			i += n
+		case c == '<' && i+1 < len(content) && content[i+1] != '<':
+			end := bytes.IndexByte(content[i:], '>')
+			if end < 0 {
+				return text.String()
+			}
+			operands = append(operands, pdfOperand{pdfHexString(content[i+1 : i+end]), true})
+			i += end + 1
+		case c == '[':
+			operands = append(operands, pdfOperand{value: "["})
+			i++
+		case c == ']':
+			// collapse the array into one operand of its strings
+			var joined strings.Builder
+			j := len(operands) - 1
+			for ; j >= 0 && (operands[j].text || operands[j].value != "["); j-- {
+			}
+			for _, operand := range operands[j+1:] {
+				if operand.text {
+					joined.WriteString(operand.value)
+				} else if n, err := strconv.ParseFloat(operand.value, 64); err == nil && n < -200 {
+					// large negative kerning separates words
+					joined.WriteByte(' ')
+				}
+			}
+			operands = append(operands[:max(j, 0)], pdfOperand{joined.String(), true})
+			i++
+		case c == '%':
+			for i < len(content) && content[i] != '\n' && content[i] != '\r' {
+				i++
+			}
+		case isPDFDelimiter(c) || isPDFSpace(c):
+			i++
+		default:
+			start := i
+			for i++; i < len(content) && !isPDFDelimiter(content[i]) && !isPDFSpace(content[i]) && content[i] != '/'; i++ {
+			}
+			token := string(content[start:i])
+			if strings.ContainsRune("/+-.0123456789", rune(token[0])) {
+				operands = append(operands, pdfOperand{value: token})
+				continue
+			}
+			last := pdfOperand{}
+			if len(operands) > 0 {
+				last = operands[len(operands)-1]
+			}
+			switch token {
+			case "BT":
+				inText = true
+			case "ET":
+				inText = false
+				text.WriteByte('\n')
+			case "Tj", "TJ":
+				if inText && last.text {
+					text.WriteString(last.value)
+				}
+			case "'", "\"":
+				if inText && last.text {
+					text.WriteByte('\n')
+					text.WriteString(last.value)
+				}
+			case "T*", "Tm":
+				text.WriteByte('\n')
+			case "Td", "TD":
+				// a vertical move starts a new line
+				if ty, err := strconv.ParseFloat(last.value, 64); err == nil && ty != 0 {
+					text.WriteByte('\n')
+				} else {
+					text.WriteByte(' ')
+				}
+			}
+			operands = operands[:0]
+		}
+	}
+	return text.String()
+}
+
+// isPDFDelimiter reports the delimiters ending a token, names start at a
+// slash but are read as operands.
+func isPDFDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}%", c) >= 0
+}
+
+func isPDFSpace(c byte) bool {
+	return strings.IndexByte(" \n\r\t\f\x00", c) >= 0
+}
+
+// pdfLiteralString decodes the literal string at the start of data and
+// returns it along with its length in data.
+func pdfLiteralString(data []byte) (string, int) {
+	var s []byte
+	depth := 0
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case c == '\\' && i+1 < len(data):
+			i++
+			switch e := data[i]; e {
+			case 'n':
+				s = append(s, '\n')
+			case 'r':
+				s = append(s, '\r')
+			case 't':
+				s = append(s, '\t')
+			case 'b', 'f':
+			case '\r', '\n':
+				// line continuation
+			default:
+				if e >= '0' && e <= '7' {
+					n := 0
+					for j := 0; j < 3 && i < len(data) && data[i] >= '0' && data[i] <= '7'; j++ {
+						n = n*8 + int(data[i]-'0')
+						i++
+					}
+					i--
+					s = append(s, byte(n))
+				} else {
+					s = append(s, e)
+				}
+			}
+		case c == '(':
+			if depth > 0 {
+				s = append(s, c)
+			}
+			depth++
+		case c == ')':
+			depth--
+			if depth == 0 {
+				return pdfDecodeBytes(s), i + 1
+			}
+			s = append(s, c)
+		default:
+			s = append(s, c)
+		}
+	}
+	return pdfDecodeBytes(s), len(data)
+}
+
+func pdfHexString(hex []byte) string {
+	digits := make([]byte, 0, len(hex))
+	for _, c := range hex {
+		if c != ' ' && c != '\n' && c != '\r' && c != '\t' {
+			digits = append(digits, c)
+		}
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	s := make([]byte, 0, len(digits)/2)
+	for i := 0; i < len(digits); i += 2 {
+		n, err := strconv.ParseUint(string(digits[i:i+2]), 16, 8)
+		if err != nil {
+			return ""
+		}
+		s = append(s, byte(n))
+	}
+	return pdfDecodeBytes(s)
+}
+
+// pdfDecodeBytes decodes UTF-16 strings with a byte order mark, and else
+// single bytes as Latin-1, dropping control characters.
+func pdfDecodeBytes(s []byte) string {
+	var text strings.Builder
+	if len(s) >= 2 && s[0] == 0xfe && s[1] == 0xff {
+		for i := 2; i+1 < len(s); i += 2 {
+			text.WriteRune(rune(s[i])<<8 | rune(s[i+1]))
+		}
+		return text.String()
+	}
+	for _, c := range s {
+		if c >= 0x20 || c == '\n' || c == '\t' {
+			text.WriteRune(rune(c))
+		}
+	}
+	return text.String()
+}
@@ -0,0 +1,155 @@
+package demoserver
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Feature is an experimental behavior enabled per session, so clients that
+// cannot handle it keep working next to those that can.
+type Feature string
+
+const (
+	// FeatureChunkedResults streams large results of every call with a
+	// progress token as chunks, as if the call set ChunkedMetaKey.
+	FeatureChunkedResults Feature = "chunkedResults"
+	// FeatureTokenEstimates adds the token estimates of WithTokenEstimates
+	// to the results of the session.
+	FeatureTokenEstimates Feature = "tokenEstimates"
+
+	// FeaturesCapability is the experimental capability negotiating the
+	// features. Clients list the features they want in it at initialize,
+	// as mcp-go drops the _meta of the initialize request, and the server
+	// answers with the supported and the enabled features.
+	FeaturesCapability = "go-mcp/features"
+)
+
+// Features are the known features.
+var Features = []Feature{FeatureChunkedResults, FeatureTokenEstimates}
+
+// FeatureRule enables features for the clients whose name matches the
+// path.Match pattern ClientName, i.e. mcp-inspector*.
+type FeatureRule struct {
+	ClientName string
+	Features   []Feature
+}
+
+// WithFeatureRules enables features by the name of the client, on top of the
+// features the clients ask for.
+func WithFeatureRules(rules ...FeatureRule) Option {
+	return func(s *Server) {
+		s.features.rules = append(s.features.rules, rules...)
+	}
+}
+
+// ParseFeatureRules parses rules as pattern=feature+feature, separated by
+// commas, i.e. "mcp-inspector*=chunkedResults+tokenEstimates,*=tokenEstimates".
+func ParseFeatureRules(rules string) ([]FeatureRule, error) {
+	var parsed []FeatureRule
+	for _, rule := range strings.Split(rules, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		pattern, features, ok := strings.Cut(rule, "=")
+		if !ok {
+			return nil, fmt.Errorf("feature rule %q is not pattern=features", rule)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("feature rule %q: %w", rule, err)
+		}
+		parsedRule := FeatureRule{ClientName: pattern}
+		for _, feature := range strings.Split(features, "+") {
+			if !slices.Contains(Features, Feature(feature)) {
+				return nil, fmt.Errorf("feature rule %q: unknown feature %q", rule, feature)
+			}
+			parsedRule.Features = append(parsedRule.Features, Feature(feature))
+		}
+		parsed = append(parsed, parsedRule)
+	}
+	return parsed, nil
+}
+
+type sessionFeatures struct {
+	rules []FeatureRule
+
+	mu       sync.RWMutex
+	sessions map[string]negotiatedSession
+}
+
+// negotiatedSession is what a session agreed on at initialize.
+type negotiatedSession struct {
+	client   mcp.Implementation
+	features []Feature
+}
+
+// negotiate returns the features of a client, those of the matching rules
+// and the known ones it asks for.
+func (f *sessionFeatures) negotiate(client mcp.Implementation, capabilities mcp.ClientCapabilities) []Feature {
+	enabled := []Feature{}
+	for _, rule := range f.rules {
+		if matched, _ := path.Match(rule.ClientName, client.Name); matched {
+			enabled = append(enabled, rule.Features...)
+		}
+	}
+	if requested, ok := capabilities.Experimental[FeaturesCapability].([]any); ok {
+		for _, feature := range requested {
+			if name, ok := feature.(string); ok && slices.Contains(Features, Feature(name)) {
+				enabled = append(enabled, Feature(name))
+			}
+		}
+	}
+	slices.Sort(enabled)
+	return slices.Compact(enabled)
+}
+
+// enabled reports whether feature is enabled for the session of ctx.
+func (f *sessionFeatures) enabled(ctx context.Context, feature Feature) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return slices.Contains(f.sessions[sessionIDFromContext(ctx)].features, feature)
+}
+
+// client returns the client of the session of ctx, as it introduced itself
+// at initialize.
+func (f *sessionFeatures) client(ctx context.Context) (mcp.Implementation, bool) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	session, ok := f.sessions[sessionIDFromContext(ctx)]
+	return session.client, ok
+}
+
+func (s *Server) registerFeatureHooks(hooks *server.Hooks) {
+	hooks.AddAfterInitialize(func(ctx context.Context, id any, message *mcp.InitializeRequest, result *mcp.InitializeResult) {
+		enabled := s.features.negotiate(message.Params.ClientInfo, message.Params.Capabilities)
+		s.features.mu.Lock()
+		if s.features.sessions == nil {
+			s.features.sessions = make(map[string]negotiatedSession)
+		}
+		s.features.sessions[sessionIDFromContext(ctx)] = negotiatedSession{
+			client:   message.Params.ClientInfo,
+			features: enabled,
+		}
+		s.features.mu.Unlock()
+
+		if result.Capabilities.Experimental == nil {
+			result.Capabilities.Experimental = make(map[string]any)
+		}
+		result.Capabilities.Experimental[FeaturesCapability] = map[string]any{
+			"supported": Features,
+			"enabled":   enabled,
+		}
+	})
+	hooks.AddOnUnregisterSession(func(ctx context.Context, session server.ClientSession) {
+		s.features.mu.Lock()
+		delete(s.features.sessions, session.SessionID())
+		s.features.mu.Unlock()
+	})
+}
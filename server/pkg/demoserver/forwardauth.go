@@ -0,0 +1,56 @@
+package demoserver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+const (
+	// ForwardAuthPath validates the bearer token of requests forwarded by an
+	// API gateway, i.e. Traefik's ForwardAuth or NGINX's auth_request.
+	ForwardAuthPath = "/forward-auth"
+
+	ForwardAuthUserHeader  = "X-Auth-Request-User"
+	ForwardAuthEmailHeader = "X-Auth-Request-Email"
+)
+
+// WithForwardAuth serves ForwardAuthPath, which runs the auth of the MCP
+// endpoints on the forwarded request so it can protect other services too.
+// It answers 200 with the identity headers, or the rejection of the auth
+// middleware, usually a 401 with its challenge.
+func WithForwardAuth(enabled bool) Option {
+	return func(s *Server) {
+		s.forwardAuth = enabled
+	}
+}
+
+func (s *Server) handleForwardAuth(w http.ResponseWriter, r *http.Request) {
+	authorized := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, email := forwardedIdentity(r)
+		w.Header().Set(ForwardAuthUserHeader, user)
+		if email != "" {
+			w.Header().Set(ForwardAuthEmailHeader, email)
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	s.authMiddleware(authorized).ServeHTTP(w, r)
+}
+
+// forwardedIdentity is the OIDC principal, or a fingerprint of the static
+// bearer token which must not be leaked to the upstream service.
+func forwardedIdentity(r *http.Request) (user, email string) {
+	if principal, ok := PrincipalFromContext(r.Context()); ok {
+		email, _ = principal.Claims["email"].(string)
+		return principal.Name, email
+	}
+	return tokenFingerprint(r.Header.Get("Authorization")), ""
+}
+
+// tokenFingerprint identifies the caller of a static bearer token without
+// revealing it.
+func tokenFingerprint(authorization string) string {
+	sum := sha256.Sum256([]byte(strings.TrimPrefix(authorization, "Bearer ")))
+	return "token:" + hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,112 @@
+package demoserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestForwardAuth checks the answers of ForwardAuthPath to a gateway, for
+// the answers of the introspection endpoint of the provider behind it.
+func TestForwardAuth(t *testing.T) {
+	release := make(chan struct{})
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.PostForm.Get("token") {
+		case "active":
+			json.NewEncoder(w).Encode(map[string]any{"active": true, "sub": "alice", "email": "alice@example.com"})
+		case "no-email":
+			json.NewEncoder(w).Encode(map[string]any{"active": true, "sub": "bob"})
+		case "inactive":
+			json.NewEncoder(w).Encode(map[string]any{"active": false})
+		case "unauthorized":
+			w.WriteHeader(http.StatusUnauthorized)
+		case "forbidden":
+			w.WriteHeader(http.StatusForbidden)
+		case "slow":
+			<-release
+		}
+	}))
+	defer provider.Close()
+	// runs first, so Close does not wait for the slow request forever
+	defer close(release)
+
+	s, err := New(WithTransport(TransportHTTP), WithForwardAuth(true), WithOIDC(OIDCConfig{
+		Issuer:           provider.URL,
+		PrincipalClaim:   "sub",
+		Validation:       ValidationIntrospection,
+		IntrospectionURL: provider.URL + "/introspect",
+		HTTPClient:       &http.Client{Timeout: 200 * time.Millisecond},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		token     string
+		wantCode  int
+		wantUser  string
+		wantEmail string
+	}{
+		{name: "active", token: "active", wantCode: http.StatusOK, wantUser: "alice", wantEmail: "alice@example.com"},
+		{name: "without email", token: "no-email", wantCode: http.StatusOK, wantUser: "bob"},
+		{name: "inactive", token: "inactive", wantCode: http.StatusUnauthorized},
+		{name: "without token", wantCode: http.StatusUnauthorized},
+		// the provider rejecting the server is not the client's fault
+		{name: "provider 401", token: "unauthorized", wantCode: http.StatusServiceUnavailable},
+		{name: "provider 403", token: "forbidden", wantCode: http.StatusServiceUnavailable},
+		{name: "provider timeout", token: "slow", wantCode: http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, ForwardAuthPath, nil)
+			if tt.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			s.Handler().ServeHTTP(w, r)
+			if w.Code != tt.wantCode {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if user := w.Header().Get(ForwardAuthUserHeader); user != tt.wantUser {
+				t.Errorf("%s = %q, want %q", ForwardAuthUserHeader, user, tt.wantUser)
+			}
+			if email := w.Header().Get(ForwardAuthEmailHeader); email != tt.wantEmail {
+				t.Errorf("%s = %q, want %q", ForwardAuthEmailHeader, email, tt.wantEmail)
+			}
+			if tt.wantCode == http.StatusUnauthorized && !strings.Contains(w.Header().Get("WWW-Authenticate"), "resource_metadata") {
+				t.Errorf("rejection without challenge: %v", w.Header())
+			}
+		})
+	}
+}
+
+// TestForwardAuthStaticToken checks that the identity forwarded for a static
+// token does not reveal the token.
+func TestForwardAuthStaticToken(t *testing.T) {
+	s, err := New(WithTransport(TransportHTTP), WithForwardAuth(true), WithAuth(BearerAuth("s3cret")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for token, want := range map[string]int{"s3cret": http.StatusOK, "wrong": http.StatusUnauthorized} {
+		r := httptest.NewRequest(http.MethodGet, ForwardAuthPath, nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, r)
+		if w.Code != want {
+			t.Errorf("%s: status %d, want %d", token, w.Code, want)
+		}
+		user := w.Header().Get(ForwardAuthUserHeader)
+		if want == http.StatusOK && (user != tokenFingerprint("Bearer s3cret") || strings.Contains(user, "s3cret")) {
+			t.Errorf("%s = %q, want the fingerprint of the token", ForwardAuthUserHeader, user)
+		}
+	}
+
+	if _, err := New(WithTransport(TransportHTTP), WithForwardAuth(true)); err == nil {
+		t.Error("New() succeeded with forward auth but without auth")
+	}
+}
@@ -0,0 +1,169 @@
+package demoserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"unicode/utf8"
+)
+
+// The fuzz targets run their seeds as part of go test, fuzzing is opt-in:
+//
+//	go test ./pkg/demoserver -run '^$' -fuzz FuzzHandleMessage -fuzztime 30s
+
+// FuzzHandleMessage feeds arbitrary JSON-RPC messages to the server, which
+// has to answer without panicking.
+func FuzzHandleMessage(f *testing.F) {
+	for _, seed := range []string{
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26","clientInfo":{"name":"fuzz","version":"0"}}}`,
+		`{"jsonrpc":"2.0","id":1,"method":"ping"}`,
+		`{"jsonrpc":"2.0","id":1,"method":"tools/list","params":{"cursor":"x"}}`,
+		`{"jsonrpc":"2.0","id":"a","method":"tools/call","params":{"name":"add","arguments":{"a":1,"b":2}}}`,
+		`{"jsonrpc":"2.0","id":null,"method":"tools/call","params":{"name":"echo@9"}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":1,"result":{}}`,
+		`[{"jsonrpc":"2.0","id":1,"method":"ping"}]`,
+		`{"jsonrpc":"1.0","id":{},"method":7}`,
+		``,
+	} {
+		f.Add([]byte(seed))
+	}
+
+	s := newFuzzServer(f)
+	f.Fuzz(func(t *testing.T, message []byte) {
+		response := s.mcpServer.HandleMessage(context.Background(), message)
+		if response == nil {
+			return
+		}
+		if _, err := json.Marshal(response); err != nil {
+			t.Fatalf("response does not encode: %v", err)
+		}
+	})
+}
+
+// FuzzToolArguments calls the tools with arbitrary arguments, the way a
+// confused agent would.
+func FuzzToolArguments(f *testing.F) {
+	calls, err := os.ReadFile(filepath.Join("testdata", "calls.json"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	var seeds []goldenCall
+	if err := json.Unmarshal(calls, &seeds); err != nil {
+		f.Fatal(err)
+	}
+	for _, seed := range seeds {
+		arguments, _ := json.Marshal(seed.Arguments)
+		f.Add(seed.Tool, arguments)
+	}
+	f.Add("add", []byte(`{"a":"1e999","b":null}`))
+	f.Add("echo", []byte(`{"message":{"nested":[1,2,3]},"version":"1"}`))
+	f.Add("check_auth", []byte(`"not an object"`))
+	f.Add("evaluate_expression", []byte(`{"expression":"9^9^9 % 0 to km","variables":{"x":"1e99999"},"precision":-1}`))
+
+	s := newFuzzServer(f)
+	f.Fuzz(func(t *testing.T, tool string, arguments []byte) {
+		message, err := json.Marshal(map[string]any{
+			"jsonrpc": "2.0",
+			"id":      1,
+			"method":  "tools/call",
+			"params": map[string]any{
+				"name":      tool,
+				"arguments": json.RawMessage(arguments),
+			},
+		})
+		if err != nil {
+			// not valid JSON, covered by FuzzHandleMessage
+			return
+		}
+		ctx := context.WithValue(context.Background(), authKey{}, "Bearer sk-1234")
+		if response := s.mcpServer.HandleMessage(ctx, message); response == nil {
+			t.Fatal("tools/call got no response")
+		}
+	})
+}
+
+// FuzzErrorDataWriter rewrites arbitrary response chunks, which have to pass
+// through untouched unless they carry a known correlation ID.
+func FuzzErrorDataWriter(f *testing.F) {
+	f.Add([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32603,"message":"internal error (correlation ID 0123456789abcdef)"}}` + "\n"))
+	f.Add([]byte("event: message\ndata: {\"jsonrpc\":\"2.0\",\"id\":1,\"error\":{\"code\":-32603,\"message\":\"x (correlation ID 0123456789abcdef)\"}}\n\n"))
+	f.Add([]byte(": heartbeat\n\n"))
+	f.Add([]byte("data:correlation ID\n"))
+
+	f.Fuzz(func(t *testing.T, chunk []byte) {
+		store := &errorDataStore{}
+		store.put(errorData{CorrelationID: "0123456789abcdef", Details: "details"})
+
+		recorder := httptest.NewRecorder()
+		w := &errorDataWriter{ResponseWriter: recorder, store: store}
+		n, err := w.Write(chunk)
+		if err != nil || n != len(chunk) {
+			t.Fatalf("Write = %d, %v, want %d, nil", n, err, len(chunk))
+		}
+		if !bytes.Contains(chunk, []byte("correlation ID")) && !bytes.Equal(recorder.Body.Bytes(), chunk) {
+			t.Fatalf("chunk without correlation ID was rewritten to %q", recorder.Body.Bytes())
+		}
+	})
+}
+
+// FuzzExtractText extracts arbitrary documents, which must yield valid UTF-8
+// chunks within the chunk size without panicking.
+func FuzzExtractText(f *testing.F) {
+	f.Add([]byte("<html><title>T &amp; C</title><script>x='<p>'</script><p>a<br>b</p><!-- c -->"), 16)
+	f.Add([]byte("%PDF-1.4\n4 0 obj\n<< /Length 44 >>\nstream\nBT (Hello \\(world\\)) Tj [(a)-300(b)] TJ ET\nendstream\nendobj\n"), 8)
+	f.Add([]byte("%PDF-1.4\n1 0 obj << /Filter /FlateDecode >> stream\nx\x9c\x03\x00endstream <FEFF00"), 4)
+
+	f.Fuzz(func(t *testing.T, data []byte, size int) {
+		size = utf8.UTFMax + int(uint(size)%4096)
+		for _, extract := range []Extractor{ExtractHTMLText, ExtractPDFText} {
+			text, err := extract(data)
+			if err != nil {
+				continue
+			}
+			for _, chunk := range chunkText(text, size) {
+				if chunk == "" || len(chunk) > size || !utf8.ValidString(chunk) {
+					t.Fatalf("invalid chunk %q of size %d", chunk, size)
+				}
+			}
+		}
+	})
+}
+
+// FuzzParsePrompt parses arbitrary prompt files, which must either fail or
+// yield a prompt with uniquely named arguments that renders without
+// arguments.
+func FuzzParsePrompt(f *testing.F) {
+	f.Add([]byte("---\nname: review\ndescription: 'it''s \"quoted\"'\narguments:\n  - name: diff\n    required: true\n  - name: focus # optional\n---\n{{if .focus}}{{.focus}}{{end}} {{.diff}}\n"))
+	f.Add([]byte("---\r\narguments:\r\n- name: a\r\n---"))
+	f.Add([]byte("plain {{.x}}"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		prompt, err := parsePrompt("fuzz", data)
+		if err != nil {
+			return
+		}
+		seen := make(map[string]bool)
+		for _, argument := range prompt.arguments {
+			if argument.name == "" || seen[argument.name] {
+				t.Fatalf("invalid arguments %+v", prompt.arguments)
+			}
+			seen[argument.name] = true
+		}
+		prompt.template.Execute(io.Discard, map[string]string{})
+	})
+}
+
+func newFuzzServer(f *testing.F) *Server {
+	f.Helper()
+	s, err := New(WithRequestLog(0))
+	if err != nil {
+		f.Fatalf("New: %v", err)
+	}
+	return s
+}
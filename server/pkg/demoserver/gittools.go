@@ -0,0 +1,515 @@
+package demoserver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	GIT_STATUS ToolName = "git_status"
+	GIT_LOG    ToolName = "git_log"
+	GIT_DIFF   ToolName = "git_diff"
+	GIT_SHOW   ToolName = "git_show"
+	GIT_BLAME  ToolName = "git_blame"
+	GIT_COMMIT ToolName = "git_commit"
+	GIT_BRANCH ToolName = "git_branch"
+
+	// DefaultGitLogCount is how many commits git_log returns.
+	DefaultGitLogCount = 20
+
+	gitTimeout   = 30 * time.Second
+	maxGitOutput = 1 << 20
+)
+
+// GitConfig enables the git tools on local repositories.
+type GitConfig struct {
+	// Repositories are the paths of the work trees, which the tools name by
+	// their base name. The first one is the default.
+	Repositories []string
+	// AllowWrites registers git_commit and git_branch, which are annotated
+	// destructive, so they go through approvals and the demo mode rejects
+	// them.
+	AllowWrites bool
+}
+
+// WithGit registers the git tools, which run the git binary confined to the
+// configured repositories.
+func WithGit(config GitConfig) Option {
+	return func(s *Server) {
+		s.git = &config
+	}
+}
+
+// gitRevision accepts branch, tag and commit names along with the revision
+// suffixes, but nothing git would take for an option.
+var gitRevision = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_./@{}~^+-]*$`)
+
+// validate resolves the repositories and checks that their names are unique.
+func (c *GitConfig) validate() error {
+	if len(c.Repositories) == 0 {
+		return fmt.Errorf("git tools require a repository")
+	}
+	names := make(map[string]bool)
+	for i, path := range c.Repositories {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		if !isDir(abs) {
+			return fmt.Errorf("git repository %s not found", path)
+		}
+		name := filepath.Base(abs)
+		if names[name] {
+			return fmt.Errorf("git repositories with the same name %s", name)
+		}
+		names[name] = true
+		c.Repositories[i] = abs
+	}
+	return nil
+}
+
+func (s *Server) registerGitTools() {
+	if s.git == nil {
+		return
+	}
+	names := make([]string, len(s.git.Repositories))
+	for i, path := range s.git.Repositories {
+		names[i] = filepath.Base(path)
+	}
+	repository := mcp.WithString("repository",
+		mcp.Description("Repository name, "+names[0]+" by default"),
+		mcp.Enum(names...),
+	)
+	path := mcp.WithString("path",
+		mcp.Description("Limits the result to a file or directory, relative to the repository root"),
+	)
+
+	s.AddTool(mcp.NewTool(string(GIT_STATUS),
+		mcp.WithDescription("Shows the current branch and the changed and untracked files of a repository"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		repository,
+	), s.handleGitStatus)
+
+	s.AddTool(mcp.NewTool(string(GIT_LOG),
+		mcp.WithDescription("Lists the commits of a repository, newest first"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		repository,
+		mcp.WithString("revision",
+			mcp.Description("Branch, tag, commit or range such as main..feature, HEAD by default"),
+		),
+		path,
+		mcp.WithNumber("max_count",
+			mcp.Description("Maximum number of commits, 20 by default"),
+			mcp.Min(1),
+			mcp.Max(500),
+		),
+	), s.handleGitLog)
+
+	s.AddTool(mcp.NewTool(string(GIT_DIFF),
+		mcp.WithDescription("Shows the changes between the work tree, the index and commits as a unified diff"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		repository,
+		mcp.WithString("from",
+			mcp.Description("Commit to compare from, the index by default"),
+		),
+		mcp.WithString("to",
+			mcp.Description("Commit to compare to, the work tree by default"),
+		),
+		mcp.WithBoolean("staged",
+			mcp.Description("Compares the index to from, HEAD by default, instead of the work tree"),
+		),
+		path,
+		mcp.WithBoolean("stat",
+			mcp.Description("Returns the changed files and line counts instead of the diff"),
+		),
+	), s.handleGitDiff)
+
+	s.AddTool(mcp.NewTool(string(GIT_SHOW),
+		mcp.WithDescription("Shows a commit with its diff, or a file as of a commit"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		repository,
+		mcp.WithString("revision",
+			mcp.Description("Commit, branch or tag, HEAD by default"),
+		),
+		mcp.WithString("path",
+			mcp.Description("File to show as of the revision instead of the commit"),
+		),
+	), s.handleGitShow)
+
+	s.AddTool(mcp.NewTool(string(GIT_BLAME),
+		mcp.WithDescription("Shows the commit and author that last changed each line of a file"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		repository,
+		mcp.WithString("path",
+			mcp.Description("File relative to the repository root"),
+			mcp.Required(),
+		),
+		mcp.WithString("revision",
+			mcp.Description("Commit to blame as of, the work tree by default"),
+		),
+		mcp.WithNumber("start_line",
+			mcp.Description("First line, 1 by default"),
+			mcp.Min(1),
+		),
+		mcp.WithNumber("end_line",
+			mcp.Description("Last line, the end of the file by default"),
+			mcp.Min(1),
+		),
+	), s.handleGitBlame)
+
+	if !s.git.AllowWrites {
+		return
+	}
+	s.AddTool(mcp.NewTool(string(GIT_COMMIT),
+		mcp.WithDescription("Stages files and commits them to the current branch"),
+		mcp.WithDestructiveHintAnnotation(true),
+		repository,
+		mcp.WithString("message",
+			mcp.Description("Commit message"),
+			mcp.Required(),
+		),
+		mcp.WithArray("paths",
+			mcp.Description("Files or directories to stage, relative to the repository root, the already staged changes by default"),
+			mcp.Items(map[string]any{"type": "string"}),
+		),
+	), s.handleGitCommit)
+
+	s.AddTool(mcp.NewTool(string(GIT_BRANCH),
+		mcp.WithDescription("Creates a branch and optionally switches the work tree to it"),
+		mcp.WithDestructiveHintAnnotation(true),
+		repository,
+		mcp.WithString("name",
+			mcp.Description("Name of the new branch"),
+			mcp.Required(),
+		),
+		mcp.WithString("start_point",
+			mcp.Description("Commit the branch starts at, HEAD by default"),
+		),
+		mcp.WithBoolean("checkout",
+			mcp.Description("Switches the work tree to the new branch"),
+		),
+	), s.handleGitBranch)
+}
+
+// gitRepository returns the path of the repository named in the request.
+func (s *Server) gitRepository(ctx context.Context, request mcp.CallToolRequest) (string, error) {
+	name := request.GetString("repository", "")
+	if name == "" {
+		return s.git.Repositories[0], nil
+	}
+	for _, path := range s.git.Repositories {
+		if filepath.Base(path) == name {
+			return path, nil
+		}
+	}
+	return "", localizedError(ctx, "error.invalid_argument", "repository", fmt.Sprintf("unknown repository %q", name))
+}
+
+// gitRevisionArgument returns the revision argument name, fallback when
+// empty.
+func gitRevisionArgument(ctx context.Context, request mcp.CallToolRequest, name, fallback string) (string, error) {
+	revision := request.GetString(name, fallback)
+	if revision != "" && !gitRevision.MatchString(revision) {
+		return "", localizedError(ctx, "error.invalid_argument", name, fmt.Sprintf("invalid revision %q", revision))
+	}
+	return revision, nil
+}
+
+// runGit runs git in the repository with the given arguments, which never
+// start with untrusted options. The output is truncated at maxGitOutput.
+func runGit(ctx context.Context, repository string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, gitTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "git", append([]string{
+		"-C", repository,
+		"--no-pager",
+		"-c", "core.quotePath=false",
+		"-c", "color.ui=false",
+		"-c", "core.fsmonitor=false",
+		// the hooks of the repository would run arbitrary code on commit,
+		// checkout and the like, --no-verify only skips some of them
+		"-c", "core.hooksPath=/dev/null",
+	}, args...)...)
+	// no prompts for credentials, no config of the machine
+	cmd.Env = append(cmd.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_CONFIG_NOSYSTEM=1", "GIT_OPTIONAL_LOCKS=0")
+	stdout := &limitedBuffer{limit: maxGitOutput}
+	var stderr bytes.Buffer
+	cmd.Stdout = stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			message := strings.TrimSpace(stderr.String())
+			if message == "" {
+				// i.e. nothing to commit
+				message = strings.TrimSpace(stdout.String())
+			}
+			return "", localizedError(ctx, "error.git_failed", args[0], message)
+		}
+		return "", fmt.Errorf("failed to run git: %w", err)
+	}
+	output := stdout.String()
+	if stdout.truncated {
+		output += fmt.Sprintf("\n[output truncated at %d bytes]", maxGitOutput)
+	}
+	return output, nil
+}
+
+// limitedBuffer keeps the first limit bytes written to it.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.Len(); len(p) > room {
+		b.truncated = true
+		b.Buffer.Write(p[:max(room, 0)])
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
+
+// gitPaths confines the path arguments to the repository and returns them
+// after a "--" separator.
+func gitPaths(ctx context.Context, repository string, paths ...string) ([]string, error) {
+	args := []string{"--"}
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		rel, err := confinePath(ctx, repository, path)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, rel)
+	}
+	return args, nil
+}
+
+func (s *Server) handleGitStatus(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	repository, err := s.gitRepository(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	output, err := runGit(ctx, repository, "status", "--branch", "--short", "--untracked-files=all")
+	if err != nil {
+		return nil, err
+	}
+	return mcp.NewToolResultText(output), nil
+}
+
+func (s *Server) handleGitLog(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	repository, err := s.gitRepository(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	revision, err := gitRevisionArgument(ctx, request, "revision", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	paths, err := gitPaths(ctx, repository, request.GetString("path", ""))
+	if err != nil {
+		return nil, err
+	}
+	count := request.GetInt("max_count", DefaultGitLogCount)
+	if count < 1 || count > 500 {
+		count = DefaultGitLogCount
+	}
+	args := []string{"log", "--max-count=" + strconv.Itoa(count), "--date=iso-strict", "--format=%H %ad %an <%ae>%n    %s", "--end-of-options", revision}
+	output, err := runGit(ctx, repository, append(args, paths...)...)
+	if err != nil {
+		return nil, err
+	}
+	if output == "" {
+		output = "No commits"
+	}
+	return mcp.NewToolResultText(output), nil
+}
+
+func (s *Server) handleGitDiff(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	repository, err := s.gitRepository(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	from, err := gitRevisionArgument(ctx, request, "from", "")
+	if err != nil {
+		return nil, err
+	}
+	to, err := gitRevisionArgument(ctx, request, "to", "")
+	if err != nil {
+		return nil, err
+	}
+	if to != "" && from == "" {
+		return nil, localizedError(ctx, "error.invalid_argument", "to", "requires from")
+	}
+	paths, err := gitPaths(ctx, repository, request.GetString("path", ""))
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{"diff", "--no-ext-diff", "--no-textconv"}
+	if request.GetBool("staged", false) {
+		args = append(args, "--cached")
+	}
+	if request.GetBool("stat", false) {
+		args = append(args, "--stat")
+	}
+	args = append(args, "--end-of-options")
+	for _, revision := range []string{from, to} {
+		if revision != "" {
+			args = append(args, revision)
+		}
+	}
+	output, err := runGit(ctx, repository, append(args, paths...)...)
+	if err != nil {
+		return nil, err
+	}
+	if output == "" {
+		output = "No changes"
+	}
+	return mcp.NewToolResultText(output), nil
+}
+
+func (s *Server) handleGitShow(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	repository, err := s.gitRepository(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	revision, err := gitRevisionArgument(ctx, request, "revision", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	if path := request.GetString("path", ""); path != "" {
+		rel, err := confinePath(ctx, repository, path)
+		if err != nil {
+			return nil, err
+		}
+		output, err := runGit(ctx, repository, "show", "--no-textconv", "--end-of-options", revision+":"+rel)
+		if err != nil {
+			return nil, err
+		}
+		return mcp.NewToolResultText(output), nil
+	}
+	output, err := runGit(ctx, repository, "show", "--no-ext-diff", "--no-textconv", "--stat", "--patch", "--date=iso-strict", "--end-of-options", revision)
+	if err != nil {
+		return nil, err
+	}
+	return mcp.NewToolResultText(output), nil
+}
+
+func (s *Server) handleGitBlame(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	repository, err := s.gitRepository(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	revision, err := gitRevisionArgument(ctx, request, "revision", "")
+	if err != nil {
+		return nil, err
+	}
+	path, err := requireText(ctx, request, "path")
+	if err != nil {
+		return nil, err
+	}
+	paths, err := gitPaths(ctx, repository, path)
+	if err != nil {
+		return nil, err
+	}
+	args := []string{"blame", "--date=short"}
+	start, end := request.GetInt("start_line", 1), request.GetInt("end_line", 0)
+	if start < 1 || end != 0 && end < start {
+		return nil, localizedError(ctx, "error.invalid_argument", "end_line", "must not be before start_line")
+	}
+	if end > 0 {
+		args = append(args, fmt.Sprintf("-L%d,%d", start, end))
+	} else if start > 1 {
+		args = append(args, fmt.Sprintf("-L%d,", start))
+	}
+	if revision != "" {
+		args = append(args, "--end-of-options", revision)
+	}
+	output, err := runGit(ctx, repository, append(args, paths...)...)
+	if err != nil {
+		return nil, err
+	}
+	return mcp.NewToolResultText(output), nil
+}
+
+func (s *Server) handleGitCommit(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	repository, err := s.gitRepository(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	message, err := requireText(ctx, request, "message")
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(message) == "" {
+		return nil, localizedError(ctx, "error.invalid_argument", "message", "must not be empty")
+	}
+	var paths []string
+	for _, path := range request.GetStringSlice("paths", nil) {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	if len(paths) > 0 {
+		args, err := gitPaths(ctx, repository, paths...)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := runGit(ctx, repository, append([]string{"add", "--all"}, args...)...); err != nil {
+			return nil, err
+		}
+	}
+	// runGit disables all hooks, --no-verify only makes sure
+	if _, err := runGit(ctx, repository, "commit", "--no-verify", "--message", message); err != nil {
+		return nil, err
+	}
+	output, err := runGit(ctx, repository, "log", "--max-count=1", "--stat", "--format=%H%n    %s", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	return mcp.NewToolResultText(output), nil
+}
+
+func (s *Server) handleGitBranch(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	repository, err := s.gitRepository(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	name, err := gitRevisionArgument(ctx, request, "name", "")
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		return nil, localizedError(ctx, "error.invalid_argument", "name", "a branch name is required")
+	}
+	if _, err := runGit(ctx, repository, "check-ref-format", "--branch", name); err != nil {
+		return nil, localizedError(ctx, "error.invalid_argument", "name", fmt.Sprintf("invalid branch name %q", name))
+	}
+	startPoint, err := gitRevisionArgument(ctx, request, "start_point", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	if request.GetBool("checkout", false) {
+		_, err = runGit(ctx, repository, "switch", "--create", name, "--end-of-options", startPoint)
+	} else {
+		_, err = runGit(ctx, repository, "branch", "--end-of-options", name, startPoint)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("Created branch %s at %s", name, startPoint)), nil
+}
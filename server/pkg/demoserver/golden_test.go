@@ -0,0 +1,197 @@
+package demoserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/wagnerjt/go-mcp/server/pkg/mcpschema"
+)
+
+// update rewrites the golden files instead of comparing against them:
+//
+//	go test ./pkg/demoserver -run Golden -update
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+// goldenCall is a canonical tool call of testdata/calls.json.
+type goldenCall struct {
+	Name          string         `json:"name"`
+	Tool          string         `json:"tool"`
+	Arguments     map[string]any `json:"arguments"`
+	Locale        string         `json:"locale,omitempty"`
+	Authorization string         `json:"authorization,omitempty"`
+}
+
+// correlationIDs are random, they are masked in the golden files.
+var correlationIDs = regexp.MustCompile(`correlation ID [0-9a-f]+`)
+
+// goldenTime is the clock of the golden server, so get_current_time has a
+// stable result.
+var goldenTime = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func newGoldenServer(t *testing.T) *Server {
+	t.Helper()
+	s, err := New(WithRequestLog(0), WithClock(func() time.Time { return goldenTime }))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return s
+}
+
+// TestGoldenToolSchemas snapshots the advertised definition of every tool, so
+// renamed arguments or lost required flags show up as a diff.
+func TestGoldenToolSchemas(t *testing.T) {
+	s := newGoldenServer(t)
+	tools, err := s.listTools(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join("testdata", "golden", "tools")
+	seen := make(map[string]bool, len(tools))
+	for _, tool := range tools {
+		seen[tool.Name+".json"] = true
+		t.Run(tool.Name, func(t *testing.T) {
+			assertGolden(t, filepath.Join(dir, tool.Name+".json"), tool)
+		})
+	}
+
+	// a golden file without its tool means the tool was removed or renamed
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	for _, file := range files {
+		if seen[filepath.Base(file)] {
+			continue
+		}
+		if *update {
+			os.Remove(file)
+			continue
+		}
+		t.Errorf("tool %s is no longer advertised, run with -update if this is intended", strings.TrimSuffix(filepath.Base(file), ".json"))
+	}
+}
+
+// TestGoldenToolCalls replays the calls of testdata/calls.json and snapshots
+// their JSON-RPC responses.
+func TestGoldenToolCalls(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "calls.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var calls []goldenCall
+	if err := json.Unmarshal(data, &calls); err != nil {
+		t.Fatalf("testdata/calls.json: %v", err)
+	}
+
+	s := newGoldenServer(t)
+	for _, call := range calls {
+		t.Run(call.Name, func(t *testing.T) {
+			ctx := context.Background()
+			if call.Locale != "" {
+				ctx = withLocale(ctx, call.Locale)
+			}
+			if call.Authorization != "" {
+				ctx = context.WithValue(ctx, authKey{}, call.Authorization)
+			}
+
+			// the id is the name of the call, so adding or reordering calls
+			// leaves the other golden files alone
+			message, err := json.Marshal(map[string]any{
+				"jsonrpc": "2.0",
+				"id":      call.Name,
+				"method":  "tools/call",
+				"params":  map[string]any{"name": call.Tool, "arguments": call.Arguments},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			response := s.mcpServer.HandleMessage(ctx, message)
+			assertGolden(t, filepath.Join("testdata", "golden", "calls", call.Name+".json"), response)
+		})
+	}
+}
+
+// TestToolExamples calls the tools with the examples of their documentation,
+// which must still yield the documented results and match the output
+// schemas.
+func TestToolExamples(t *testing.T) {
+	s := newGoldenServer(t)
+	for tool, examples := range s.toolExamples {
+		for i, example := range examples {
+			t.Run(fmt.Sprintf("%s/%d", tool, i), func(t *testing.T) {
+				text, ok := resultText(callTool(t, s, tool, example.Arguments))
+				if !ok {
+					t.Fatalf("example %v failed", example.Arguments)
+				}
+				if example.Result != "" && text != example.Result {
+					t.Errorf("example %v returned\n%s\nwant\n%s", example.Arguments, text, example.Result)
+				}
+				if schema, ok := s.outputSchemas[tool]; ok {
+					definition, err := mcpschema.Compile(schema)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if err := validateOutput(definition, mcp.NewToolResultText(text)); err != nil {
+						t.Errorf("example %v does not match the output schema: %v", example.Arguments, err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// assertGolden compares the indented JSON of got with the golden file, or
+// writes it in update mode.
+func assertGolden(t *testing.T, path string, got any) {
+	t.Helper()
+	body, err := json.MarshalIndent(got, "", "  ")
+	if err != nil {
+		t.Fatalf("encoding %s: %v", path, err)
+	}
+	body = correlationIDs.ReplaceAll(append(body, '\n'), []byte("correlation ID <id>"))
+
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, body, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v, run with -update to create it", err)
+	}
+	if !bytes.Equal(want, body) {
+		t.Errorf("%s differs, run with -update if this is intended\n%s", path, lineDiff(string(want), string(body)))
+	}
+}
+
+// lineDiff lists the lines of want and got that differ.
+func lineDiff(want, got string) string {
+	wantLines, gotLines := strings.Split(want, "\n"), strings.Split(got, "\n")
+	var b strings.Builder
+	for i := 0; i < max(len(wantLines), len(gotLines)); i++ {
+		var w, g string
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if w != g {
+			fmt.Fprintf(&b, "line %d:\n- %s\n+ %s\n", i+1, w, g)
+		}
+	}
+	return b.String()
+}
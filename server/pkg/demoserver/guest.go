@@ -0,0 +1,67 @@
+package demoserver
+
+import (
+	"context"
+	"net/http"
+	"slices"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// DefaultGuestTools are the tools that are safe to expose to anyone.
+var DefaultGuestTools = []string{string(ECHO), "get_current_time"}
+
+type guestKey struct{}
+
+// WithGuestTools lets requests without credentials through the auth option
+// as guests, which only see and may only call the listed tools. Requests
+// with credentials are still authenticated, invalid ones are rejected.
+func WithGuestTools(names ...string) Option {
+	return func(s *Server) {
+		s.guestTools = names
+	}
+}
+
+// guestAuth runs the auth middleware, except for requests without
+// credentials in guest mode.
+func (s *Server) guestAuth(next http.Handler) http.Handler {
+	authenticated := s.authMiddleware(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			authenticated.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), guestKey{}, true)))
+	})
+}
+
+func isGuest(ctx context.Context) bool {
+	guest, _ := ctx.Value(guestKey{}).(bool)
+	return guest
+}
+
+// filterGuestTools is a tool filter hiding the privileged tools from guests.
+func (s *Server) filterGuestTools(ctx context.Context, tools []mcp.Tool) []mcp.Tool {
+	if !isGuest(ctx) {
+		return tools
+	}
+	allowed := make([]mcp.Tool, 0, len(tools))
+	for _, tool := range tools {
+		if slices.Contains(s.guestTools, tool.Name) {
+			allowed = append(allowed, tool)
+		}
+	}
+	return allowed
+}
+
+// guestMiddleware rejects calls of guests to privileged tools, which are
+// hidden from them but may still be called by name.
+func (s *Server) guestMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if isGuest(ctx) && !slices.Contains(s.guestTools, request.Params.Name) {
+			return nil, localizedError(ctx, "error.auth_required", request.Params.Name)
+		}
+		return next(ctx, request)
+	}
+}
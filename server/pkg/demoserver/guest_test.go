@@ -0,0 +1,78 @@
+package demoserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestGuestTools(t *testing.T) {
+	calls := 0
+	s, err := New(WithTransport(TransportHTTP), WithAuth(BearerAuth("token")), WithGuestTools(string(ECHO)),
+		WithExtraTools(wipeTool(&calls)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	guest := context.WithValue(context.Background(), guestKey{}, true)
+	names := func(ctx context.Context) []string {
+		response := s.mcpServer.HandleMessage(ctx, json.RawMessage(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`))
+		var names []string
+		for _, tool := range response.(mcp.JSONRPCResponse).Result.(mcp.ListToolsResult).Tools {
+			names = append(names, tool.Name)
+		}
+		return names
+	}
+	if got := names(guest); !slices.Equal(got, []string{string(ECHO)}) {
+		t.Errorf("guest tools %v, want only echo", got)
+	}
+	if got := names(context.Background()); !slices.Contains(got, "wipe") || !slices.Contains(got, string(ECHO)) {
+		t.Errorf("authenticated tools %v, want wipe and echo", got)
+	}
+
+	call := func(ctx context.Context, tool string, arguments any) mcp.JSONRPCMessage {
+		message, _ := json.Marshal(map[string]any{
+			"jsonrpc": "2.0",
+			"id":      1,
+			"method":  "tools/call",
+			"params":  map[string]any{"name": tool, "arguments": arguments},
+		})
+		return s.mcpServer.HandleMessage(ctx, message)
+	}
+	if _, ok := resultText(call(guest, string(ECHO), map[string]any{"message": "hi"})); !ok {
+		t.Error("guest call of echo failed")
+	}
+	if message := errorMessage(call(guest, "wipe", nil)); !strings.Contains(message, "requires authentication") || calls != 0 {
+		t.Errorf("guest call of the hidden wipe returned %q and ran %d times, want error.auth_required", message, calls)
+	}
+	if _, ok := resultText(call(context.Background(), "wipe", nil)); !ok || calls != 1 {
+		t.Errorf("authenticated call of wipe ran %d times, want once", calls)
+	}
+}
+
+// TestGuestAuth checks that only requests without credentials become
+// guests, invalid credentials are still rejected.
+func TestGuestAuth(t *testing.T) {
+	s := &Server{authMiddleware: BearerAuth("token")}
+	handler := s.guestAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isGuest(r.Context()) {
+			w.Write([]byte("guest"))
+		}
+	}))
+	for auth, want := range map[string]string{"": "guest", "Bearer token": "", "Bearer wrong": "Unauthorized\n"} {
+		r := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+		if auth != "" {
+			r.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Body.String() != want {
+			t.Errorf("%q: body %q, want %q", auth, w.Body, want)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package demoserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	// HistoryURI is the resource listing the recent tool calls of the
+	// session reading it.
+	HistoryURI = "history://calls"
+
+	// DefaultHistorySize is how many calls are kept per session.
+	DefaultHistorySize = 50
+
+	// maxHistoryText truncates the recorded results.
+	maxHistoryText = 1024
+	// maxHistorySessions caps the sessions with a history, the streamable
+	// HTTP sessions are never unregistered.
+	maxHistorySessions = 1000
+)
+
+// WithHistory keeps the last size tool calls of each session for the
+// HistoryURI resource, 0 disables it.
+func WithHistory(size int) Option {
+	return func(s *Server) {
+		s.history.size = size
+	}
+}
+
+// HistoryEntry is a recorded tool call, with the secrets in its arguments
+// and result redacted.
+type HistoryEntry struct {
+	Tool      string         `json:"tool"`
+	Arguments map[string]any `json:"arguments,omitempty"`
+	Result    string         `json:"result,omitempty"`
+	IsError   bool           `json:"isError,omitempty"`
+	Error     string         `json:"error,omitempty"`
+	Started   time.Time      `json:"started"`
+	Duration  string         `json:"duration"`
+}
+
+type sessionHistory struct {
+	entries  []HistoryEntry
+	lastUsed time.Time
+}
+
+// callHistory holds the recent calls per session ID.
+type callHistory struct {
+	size int
+
+	mu       sync.Mutex
+	sessions map[string]*sessionHistory
+}
+
+func (h *callHistory) record(sessionID string, entry HistoryEntry) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.sessions == nil {
+		h.sessions = make(map[string]*sessionHistory)
+	}
+	history, ok := h.sessions[sessionID]
+	if !ok {
+		if len(h.sessions) >= maxHistorySessions {
+			h.evictOldest()
+		}
+		history = &sessionHistory{}
+		h.sessions[sessionID] = history
+	}
+	history.lastUsed = entry.Started
+	history.entries = append(history.entries, entry)
+	if len(history.entries) > h.size {
+		history.entries = history.entries[len(history.entries)-h.size:]
+	}
+}
+
+func (h *callHistory) evictOldest() {
+	var oldest string
+	var oldestUsed time.Time
+	for id, history := range h.sessions {
+		if oldest == "" || history.lastUsed.Before(oldestUsed) {
+			oldest, oldestUsed = id, history.lastUsed
+		}
+	}
+	delete(h.sessions, oldest)
+}
+
+func (h *callHistory) entries(sessionID string) []HistoryEntry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	history, ok := h.sessions[sessionID]
+	if !ok {
+		return []HistoryEntry{}
+	}
+	return append([]HistoryEntry(nil), history.entries...)
+}
+
+func (h *callHistory) forget(sessionID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.sessions, sessionID)
+}
+
+// historyMiddleware records the calls as the client sees them.
+func (s *Server) historyMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	if s.history.size <= 0 {
+		return next
+	}
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		started := s.clock()
+		result, err := next(ctx, request)
+		entry := HistoryEntry{
+			Tool:      request.Params.Name,
+			Arguments: redactArguments(request.GetArguments()),
+			Started:   started,
+			Duration:  s.clock().Sub(started).Round(time.Microsecond).String(),
+		}
+		if err != nil {
+			entry.Error = redactSecrets(err.Error())
+		} else if result != nil {
+			entry.IsError = result.IsError
+			entry.Result = historyText(result)
+		}
+		s.history.record(sessionIDFromContext(ctx), entry)
+		return result, err
+	}
+}
+
+// historyText is the redacted and truncated text content of result.
+func historyText(result *mcp.CallToolResult) string {
+	var texts []string
+	for _, content := range result.Content {
+		if text, ok := content.(mcp.TextContent); ok {
+			texts = append(texts, text.Text)
+		}
+	}
+	text := redactSecrets(strings.Join(texts, "\n"))
+	if len(text) > maxHistoryText {
+		text = text[:maxHistoryText] + "..."
+	}
+	return text
+}
+
+// redactArguments hides the values of arguments named like credentials and
+// the secrets in the others.
+func redactArguments(arguments map[string]any) map[string]any {
+	if len(arguments) == 0 {
+		return nil
+	}
+	redacted := make(map[string]any, len(arguments))
+	for name, value := range arguments {
+		switch value := value.(type) {
+		case string:
+			if isSecretName(name) {
+				redacted[name] = "[redacted]"
+			} else {
+				redacted[name] = redactSecrets(value)
+			}
+		case map[string]any:
+			redacted[name] = redactArguments(value)
+		default:
+			if isSecretName(name) {
+				redacted[name] = "[redacted]"
+			} else {
+				redacted[name] = value
+			}
+		}
+	}
+	return redacted
+}
+
+func isSecretName(name string) bool {
+	name = strings.ToLower(name)
+	for _, word := range []string{"authorization", "token", "secret", "password", "key"} {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) registerHistoryHooks(hooks *server.Hooks) {
+	if s.history.size <= 0 {
+		return
+	}
+	hooks.AddOnUnregisterSession(func(ctx context.Context, session server.ClientSession) {
+		s.history.forget(session.SessionID())
+	})
+}
+
+func (s *Server) readHistory(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	body, err := json.Marshal(s.history.entries(sessionIDFromContext(ctx)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the history: %w", err)
+	}
+	return []mcp.ResourceContents{mcp.TextResourceContents{
+		URI:      HistoryURI,
+		MIMEType: "application/json",
+		Text:     string(body),
+	}}, nil
+}
@@ -0,0 +1,214 @@
+package demoserver
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	DefaultLocale        string = "en"
+	AcceptLanguageHeader string = "Accept-Language"
+)
+
+//go:embed locales/*.json
+var embeddedLocales embed.FS
+
+type localeKey struct{}
+
+type catalogKey struct{}
+
+// Catalog holds the translated messages of every loaded locale, keyed by
+// locale and then by message key.
+type Catalog struct {
+	Default string
+
+	mu       sync.RWMutex
+	messages map[string]map[string]string
+}
+
+// LoadCatalog reads the embedded locales and then any locale files found in
+// dir, which may add new locales or override embedded messages.
+func LoadCatalog(defaultLocale, dir string) (*Catalog, error) {
+	c := &Catalog{Default: defaultLocale, messages: make(map[string]map[string]string)}
+
+	entries, err := embeddedLocales.ReadDir("locales")
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		data, err := embeddedLocales.ReadFile("locales/" + entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		if err := c.add(entry.Name(), data); err != nil {
+			return nil, err
+		}
+	}
+
+	if dir != "" {
+		files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+			if err := c.add(filepath.Base(file), data); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if _, ok := c.messages[defaultLocale]; !ok {
+		return nil, fmt.Errorf("no messages for default locale %s", defaultLocale)
+	}
+	return c, nil
+}
+
+func (c *Catalog) add(filename string, data []byte) error {
+	var messages map[string]string
+	if err := json.Unmarshal(data, &messages); err != nil {
+		return fmt.Errorf("failed to parse locale %s: %w", filename, err)
+	}
+	locale := strings.ToLower(strings.TrimSuffix(filename, filepath.Ext(filename)))
+	if c.messages[locale] == nil {
+		c.messages[locale] = make(map[string]string)
+	}
+	for key, message := range messages {
+		c.messages[locale][key] = message
+	}
+	return nil
+}
+
+// Lookup returns the message for key in locale, falling back to the default
+// locale.
+func (c *Catalog) Lookup(locale, key string) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if message, ok := c.messages[locale][key]; ok {
+		return message, true
+	}
+	message, ok := c.messages[c.Default][key]
+	return message, ok
+}
+
+// Negotiate picks the best supported locale for an Accept-Language style
+// value such as "de-CH, de;q=0.9, en;q=0.5".
+func (c *Catalog) Negotiate(acceptLanguage string) string {
+	type candidate struct {
+		tag string
+		q   float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+		if tag != "" && q > 0 {
+			candidates = append(candidates, candidate{strings.ToLower(tag), q})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, cand := range candidates {
+		if _, ok := c.messages[cand.tag]; ok {
+			return cand.tag
+		}
+		primary, _, _ := strings.Cut(cand.tag, "-")
+		if _, ok := c.messages[primary]; ok {
+			return primary
+		}
+	}
+	return c.Default
+}
+
+// replace swaps in the messages of other, used when reloading.
+func (c *Catalog) replace(other *Catalog) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.messages = other.messages
+}
+
+func withLocale(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, localeKey{}, locale)
+}
+
+func withCatalog(ctx context.Context, c *Catalog) context.Context {
+	return context.WithValue(ctx, catalogKey{}, c)
+}
+
+func (s *Server) localeFromRequest(ctx context.Context, r *http.Request) context.Context {
+	return withLocale(ctx, s.catalog.Negotiate(r.Header.Get(AcceptLanguageHeader)))
+}
+
+// catalogMiddleware makes the catalog available to the tool handlers
+// regardless of the transport.
+func (s *Server) catalogMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return next(withCatalog(ctx, s.catalog), request)
+	}
+}
+
+// localize formats the message for key in the locale of the request.
+func localize(ctx context.Context, key string, args ...any) string {
+	c, ok := ctx.Value(catalogKey{}).(*Catalog)
+	if !ok {
+		return key
+	}
+	locale, ok := ctx.Value(localeKey{}).(string)
+	if !ok {
+		locale = c.Default
+	}
+	message, ok := c.Lookup(locale, key)
+	if !ok {
+		return key
+	}
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
+
+// localizedError returns the standard error message for key in the locale of
+// the request.
+func localizedError(ctx context.Context, key string, args ...any) error {
+	return &userError{localize(ctx, key, args...)}
+}
+
+// localizeTools is a tool filter replacing the tool descriptions with the
+// ones of the request locale.
+func (s *Server) localizeTools(ctx context.Context, tools []mcp.Tool) []mcp.Tool {
+	locale, ok := ctx.Value(localeKey{}).(string)
+	if !ok {
+		locale = s.catalog.Default
+	}
+	localized := make([]mcp.Tool, len(tools))
+	for i, tool := range tools {
+		if description, ok := s.catalog.Lookup(locale, "tool."+tool.Name+".description"); ok {
+			tool.Description = description
+		}
+		localized[i] = tool
+	}
+	return localized
+}
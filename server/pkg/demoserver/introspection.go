@@ -0,0 +1,186 @@
+package demoserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wagnerjt/go-mcp/shared/pkg/jwtauth"
+)
+
+const (
+	// ValidationJWKS verifies the signature and claims of JWT tokens with the
+	// keys of the provider.
+	ValidationJWKS = "jwks"
+	// ValidationIntrospection asks the RFC 7662 introspection endpoint of the
+	// provider about every token, which also works for opaque tokens.
+	ValidationIntrospection = "introspection"
+
+	// DefaultIntrospectionCacheTTL bounds how long a revoked token is still
+	// accepted.
+	DefaultIntrospectionCacheTTL = 30 * time.Second
+
+	// maxIntrospectionCache caps the cached results, the expired ones are
+	// dropped first.
+	maxIntrospectionCache = 10000
+)
+
+type introspectionResult struct {
+	principal Principal
+	active    bool
+	expires   time.Time
+}
+
+// introspectionCache holds the results of active and inactive tokens by the
+// hash of the token, so they do not linger in memory.
+type introspectionCache struct {
+	mu      sync.Mutex
+	results map[[sha256.Size]byte]introspectionResult
+}
+
+func (c *introspectionCache) get(key [sha256.Size]byte, now time.Time) (introspectionResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	result, ok := c.results[key]
+	if !ok || now.After(result.expires) {
+		return introspectionResult{}, false
+	}
+	return result, true
+}
+
+func (c *introspectionCache) put(key [sha256.Size]byte, result introspectionResult, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.results == nil {
+		c.results = make(map[[sha256.Size]byte]introspectionResult)
+	}
+	if len(c.results) >= maxIntrospectionCache {
+		for k, cached := range c.results {
+			if now.After(cached.expires) {
+				delete(c.results, k)
+			}
+		}
+		if len(c.results) >= maxIntrospectionCache {
+			clear(c.results)
+		}
+	}
+	c.results[key] = result
+}
+
+// validateConfig rejects unknown validation modes.
+func (v *oidcVerifier) validateConfig() error {
+	switch v.config.Validation {
+	case ValidationJWKS:
+	case ValidationIntrospection:
+		if v.config.IntrospectionCacheTTL < 0 {
+			return fmt.Errorf("invalid introspection cache TTL %s", v.config.IntrospectionCacheTTL)
+		}
+	default:
+		return fmt.Errorf("unsupported OIDC validation %q, want %s or %s", v.config.Validation, ValidationJWKS, ValidationIntrospection)
+	}
+	return nil
+}
+
+// introspect validates token with the introspection endpoint, reusing the
+// cached result of the token.
+func (v *oidcVerifier) introspect(ctx context.Context, token string) (Principal, error) {
+	key := sha256.Sum256([]byte(token))
+	now := v.now()
+	if result, ok := v.introspections.get(key, now); ok {
+		if !result.active {
+			return Principal{}, fmt.Errorf("%w: inactive (cached)", errInvalidToken)
+		}
+		return result.principal, nil
+	}
+
+	claims, err := v.postIntrospection(ctx, token)
+	if err != nil {
+		return Principal{}, err
+	}
+	result := introspectionResult{expires: now.Add(v.config.IntrospectionCacheTTL)}
+	principal, err := v.introspectionPrincipal(claims)
+	if err == nil {
+		result.principal, result.active = principal, true
+		if exp, ok := claims["exp"].(float64); ok && time.Unix(int64(exp), 0).Before(result.expires) {
+			result.expires = time.Unix(int64(exp), 0)
+		}
+	}
+	v.introspections.put(key, result, now)
+	return principal, err
+}
+
+// introspectionPrincipal checks the introspection response of RFC 7662
+// section 2.2, where every member but active is optional.
+func (v *oidcVerifier) introspectionPrincipal(claims map[string]any) (Principal, error) {
+	if active, _ := claims["active"].(bool); !active {
+		return Principal{}, fmt.Errorf("%w: inactive", errInvalidToken)
+	}
+	parsed, err := jwtauth.ParseClaims(claims)
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: %v", errInvalidToken, err)
+	}
+	if !parsed.ExpiresAt.IsZero() && v.now().After(parsed.ExpiresAt.Add(oidcLeeway)) {
+		return Principal{}, fmt.Errorf("%w: token expired", errInvalidToken)
+	}
+	if !parsed.HasAudience(v.audiences()) {
+		return Principal{}, fmt.Errorf("%w: audience %q does not include %q", errInvalidToken, parsed.Audience, v.config.Audience)
+	}
+	return v.principal(claims)
+}
+
+func (v *oidcVerifier) introspectionURL(ctx context.Context) (string, error) {
+	if v.config.IntrospectionURL != "" {
+		return v.config.IntrospectionURL, nil
+	}
+	v.mu.RLock()
+	discovered := v.discovery != nil
+	v.mu.RUnlock()
+	if !discovered {
+		if err := v.discoverOnDemand(ctx); err != nil {
+			return "", err
+		}
+	}
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.discovery.IntrospectionEndpoint, nil
+}
+
+// postIntrospection sends the token to the introspection endpoint, errors
+// other than errInvalidToken mean the provider could not answer.
+func (v *oidcVerifier) postIntrospection(ctx context.Context, token string) (map[string]any, error) {
+	endpoint, err := v.introspectionURL(ctx)
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{"token": {token}, "token_type_hint": {"access_token"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if v.config.ClientID != "" {
+		req.SetBasicAuth(url.QueryEscape(v.config.ClientID), url.QueryEscape(v.config.ClientSecret))
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to introspect the token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+		return nil, fmt.Errorf("failed to introspect the token: status code %d", resp.StatusCode)
+	}
+	var claims map[string]any
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&claims); err != nil {
+		return nil, fmt.Errorf("failed to decode the introspection response: %w", err)
+	}
+	return claims, nil
+}
@@ -0,0 +1,132 @@
+package demoserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIntrospect(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	responses := map[string]map[string]any{
+		"active":         {"active": true, "sub": "alice", "aud": "mcp", "exp": float64(now.Add(time.Hour).Unix())},
+		"audience list":  {"active": true, "sub": "alice", "aud": []any{"other", "mcp"}},
+		"no audience":    {"active": true, "sub": "alice"},
+		"inactive":       {"active": false},
+		"other audience": {"active": true, "sub": "alice", "aud": "other"},
+		"expired":        {"active": true, "sub": "alice", "aud": "mcp", "exp": float64(now.Add(-time.Hour).Unix())},
+		"no subject":     {"active": true, "aud": "mcp"},
+		"invalid exp":    {"active": true, "sub": "alice", "aud": "mcp", "exp": "soon"},
+	}
+	var clientID, clientSecret string
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		clientID, clientSecret, _ = r.BasicAuth()
+		response, ok := responses[r.PostForm.Get("token")]
+		if !ok {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer provider.Close()
+
+	for _, test := range []struct {
+		token   string
+		invalid bool
+		failed  bool
+	}{
+		{token: "active"},
+		{token: "audience list"},
+		{token: "no audience", invalid: true},
+		{token: "inactive", invalid: true},
+		{token: "other audience", invalid: true},
+		{token: "expired", invalid: true},
+		{token: "no subject", invalid: true},
+		{token: "invalid exp", invalid: true},
+		{token: "provider error", failed: true},
+	} {
+		verifier := newOIDCVerifier(OIDCConfig{
+			Issuer: provider.URL, Audience: "mcp", PrincipalClaim: "sub", Validation: ValidationIntrospection,
+			IntrospectionURL: provider.URL, ClientID: "server", ClientSecret: "secret",
+		})
+		verifier.now = func() time.Time { return now }
+		principal, err := verifier.introspect(context.Background(), test.token)
+		switch {
+		case test.invalid && !errors.Is(err, errInvalidToken):
+			t.Errorf("%s: got %v, want an invalid token", test.token, err)
+		case test.failed && (err == nil || errors.Is(err, errInvalidToken)):
+			t.Errorf("%s: got %v, want a provider failure", test.token, err)
+		case !test.invalid && !test.failed && (err != nil || principal.Name != "alice"):
+			t.Errorf("%s: got %+v, %v, want alice", test.token, principal, err)
+		}
+	}
+	if clientID != "server" || clientSecret != "secret" {
+		t.Errorf("introspection authenticated as %q:%q, want server:secret", clientID, clientSecret)
+	}
+
+	// without an audience any audience is accepted
+	verifier := newOIDCVerifier(OIDCConfig{Issuer: provider.URL, PrincipalClaim: "sub", Validation: ValidationIntrospection, IntrospectionURL: provider.URL})
+	verifier.now = func() time.Time { return now }
+	if _, err := verifier.introspect(context.Background(), "other audience"); err != nil {
+		t.Errorf("other audience without a configured audience: %v", err)
+	}
+}
+
+// TestIntrospectionCache checks that the results are reused until the cache
+// TTL, or the expiry of the token when sooner.
+func TestIntrospectionCache(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	expiry := now.Add(10 * time.Second)
+	var calls atomic.Int32
+	active := true
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		r.ParseForm()
+		response := map[string]any{"active": active, "sub": "alice"}
+		if r.PostForm.Get("token") == "short-lived" {
+			response["exp"] = float64(expiry.Unix())
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer provider.Close()
+
+	verifier := newOIDCVerifier(OIDCConfig{Issuer: provider.URL, PrincipalClaim: "sub", Validation: ValidationIntrospection,
+		IntrospectionURL: provider.URL, IntrospectionCacheTTL: time.Minute})
+	start := now
+	verifier.now = func() time.Time { return now }
+
+	introspect := func(token string, wantCalls int32, wantActive bool) {
+		t.Helper()
+		_, err := verifier.introspect(context.Background(), token)
+		if (err == nil) != wantActive {
+			t.Errorf("%s at %s: error %v, want active %v", token, now.Sub(start), err, wantActive)
+		}
+		if got := calls.Load(); got != wantCalls {
+			t.Errorf("%s at %s: %d introspections, want %d", token, now.Sub(start), got, wantCalls)
+		}
+	}
+	introspect("token", 1, true)
+	// a revocation is only seen once the cached result expired
+	active = false
+	now = now.Add(30 * time.Second)
+	introspect("token", 1, true)
+	now = now.Add(31 * time.Second)
+	introspect("token", 2, false)
+	// the inactive result is cached as well
+	active = true
+	introspect("token", 2, false)
+
+	now = start
+	introspect("short-lived", 3, true)
+	// the provider reports the expired token as active within the leeway
+	now = expiry.Add(time.Second)
+	introspect("short-lived", 4, true)
+	now = expiry.Add(oidcLeeway + time.Second)
+	introspect("short-lived", 5, false)
+}
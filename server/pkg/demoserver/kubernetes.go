@@ -0,0 +1,663 @@
+package demoserver
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	K8S_LIST_PODS        ToolName = "k8s_list_pods"
+	K8S_LIST_DEPLOYMENTS ToolName = "k8s_list_deployments"
+	K8S_LIST_EVENTS      ToolName = "k8s_list_events"
+	K8S_POD_LOGS         ToolName = "k8s_pod_logs"
+
+	// DefaultLogTailLines is how many log lines k8s_pod_logs returns.
+	DefaultLogTailLines = 200
+	// AllNamespaces in the namespace allowlist allows every namespace.
+	AllNamespaces = "*"
+
+	inClusterTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	inClusterCAFile    = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+	inClusterNamespace = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+	maxK8sItems        = 500
+	maxK8sLogBytes     = 1 << 20
+)
+
+// KubernetesConfig enables the read-only cluster tools.
+type KubernetesConfig struct {
+	// Kubeconfig is the path of a kubeconfig in JSON, as written by
+	// kubectl config view --raw --minify --flatten -o json. Exec and auth
+	// provider plugins are not supported. The in-cluster service account is
+	// used when empty.
+	Kubeconfig string
+	// Context defaults to the current context of the kubeconfig.
+	Context string
+	// Namespaces the tools may inspect, AllNamespaces for any. The first one
+	// is the default, for AllNamespaces the namespace of the context or of
+	// the service account.
+	Namespaces []string
+	// HTTPClient overrides the client built from the kubeconfig, i.e. in
+	// tests.
+	HTTPClient *http.Client
+	// Server is the API server URL of HTTPClient.
+	Server string
+}
+
+// WithKubernetes registers the tools listing pods, deployments and events
+// and reading pod logs, limited to the namespaces of config. The tools only
+// read, the Kubernetes RBAC of the credentials should not grant more.
+func WithKubernetes(config KubernetesConfig) Option {
+	return func(s *Server) {
+		s.k8s = &kubeClient{config: config}
+		s.readiness.checks = append(s.readiness.checks, namedCheck{"kubernetes", s.k8s.health})
+	}
+}
+
+// kubeClient calls the Kubernetes REST API.
+type kubeClient struct {
+	config     KubernetesConfig
+	server     string
+	client     *http.Client
+	token      string
+	tokenFile  string
+	namespaces []string
+	// defaultNamespace is used when a call names none, the namespace is
+	// required when it is empty.
+	defaultNamespace string
+	// contextNamespace is the namespace of the kubeconfig context or the
+	// service account.
+	contextNamespace string
+}
+
+type kubeconfig struct {
+	CurrentContext string `json:"current-context"`
+	Clusters       []struct {
+		Name    string `json:"name"`
+		Cluster struct {
+			Server                   string `json:"server"`
+			CertificateAuthority     string `json:"certificate-authority"`
+			CertificateAuthorityData string `json:"certificate-authority-data"`
+			InsecureSkipTLSVerify    bool   `json:"insecure-skip-tls-verify"`
+		} `json:"cluster"`
+	} `json:"clusters"`
+	Users []struct {
+		Name string `json:"name"`
+		User struct {
+			Token                 string          `json:"token"`
+			TokenFile             string          `json:"tokenFile"`
+			ClientCertificate     string          `json:"client-certificate"`
+			ClientCertificateData string          `json:"client-certificate-data"`
+			ClientKey             string          `json:"client-key"`
+			ClientKeyData         string          `json:"client-key-data"`
+			Exec                  json.RawMessage `json:"exec"`
+			AuthProvider          json.RawMessage `json:"auth-provider"`
+		} `json:"user"`
+	} `json:"users"`
+	Contexts []struct {
+		Name    string `json:"name"`
+		Context struct {
+			Cluster   string `json:"cluster"`
+			User      string `json:"user"`
+			Namespace string `json:"namespace"`
+		} `json:"context"`
+	} `json:"contexts"`
+}
+
+// load sets up the connection to the API server.
+func (k *kubeClient) load() error {
+	k.namespaces = k.config.Namespaces
+	if k.config.HTTPClient != nil {
+		k.server, k.client = k.config.Server, k.config.HTTPClient
+	} else if k.config.Kubeconfig == "" {
+		if err := k.loadInCluster(); err != nil {
+			return err
+		}
+	} else if err := k.loadKubeconfig(); err != nil {
+		return err
+	}
+	if len(k.namespaces) == 0 {
+		return fmt.Errorf("kubernetes tools require a namespace allowlist")
+	}
+	k.defaultNamespace = k.namespaces[0]
+	if k.defaultNamespace == AllNamespaces {
+		k.defaultNamespace = k.contextNamespace
+	}
+	k.server = strings.TrimSuffix(k.server, "/")
+	return nil
+}
+
+func (k *kubeClient) loadInCluster() error {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return fmt.Errorf("kubernetes tools require a kubeconfig outside of a cluster")
+	}
+	ca, err := os.ReadFile(inClusterCAFile)
+	if err != nil {
+		return fmt.Errorf("failed to read the service account CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(ca)
+	k.server = "https://" + net.JoinHostPort(host, port)
+	k.tokenFile = inClusterTokenFile
+	if namespace, err := os.ReadFile(inClusterNamespace); err == nil {
+		k.contextNamespace = strings.TrimSpace(string(namespace))
+	}
+	k.client = &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: tracePropagation(&http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}),
+	}
+	return nil
+}
+
+func (k *kubeClient) loadKubeconfig() error {
+	data, err := os.ReadFile(k.config.Kubeconfig)
+	if err != nil {
+		return fmt.Errorf("failed to read the kubeconfig: %w", err)
+	}
+	var config kubeconfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("kubeconfig %s is not JSON, convert it with kubectl config view --raw --minify --flatten -o json: %w", k.config.Kubeconfig, err)
+	}
+	name := k.config.Context
+	if name == "" {
+		name = config.CurrentContext
+	}
+	var cluster, user, namespace string
+	found := false
+	for _, c := range config.Contexts {
+		if c.Name == name {
+			cluster, user, namespace, found = c.Context.Cluster, c.Context.User, c.Context.Namespace, true
+		}
+	}
+	if !found {
+		return fmt.Errorf("kubeconfig context %q not found", name)
+	}
+	k.contextNamespace = namespace
+	if len(k.namespaces) == 0 && namespace != "" {
+		k.namespaces = []string{namespace}
+	}
+
+	// relative paths in a kubeconfig are relative to the kubeconfig
+	dir := filepath.Dir(k.config.Kubeconfig)
+	readFile := func(path string) ([]byte, error) {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		return os.ReadFile(path)
+	}
+	readData := func(data, path string) ([]byte, error) {
+		if data != "" {
+			return base64.StdEncoding.DecodeString(data)
+		}
+		if path != "" {
+			return readFile(path)
+		}
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{}
+	found = false
+	for _, c := range config.Clusters {
+		if c.Name != cluster {
+			continue
+		}
+		found = true
+		k.server = c.Cluster.Server
+		ca, err := readData(c.Cluster.CertificateAuthorityData, c.Cluster.CertificateAuthority)
+		if err != nil {
+			return fmt.Errorf("failed to read the cluster CA: %w", err)
+		}
+		if ca != nil {
+			tlsConfig.RootCAs = x509.NewCertPool()
+			tlsConfig.RootCAs.AppendCertsFromPEM(ca)
+		}
+		tlsConfig.InsecureSkipVerify = c.Cluster.InsecureSkipTLSVerify
+	}
+	if !found {
+		return fmt.Errorf("kubeconfig cluster %q not found", cluster)
+	}
+	for _, u := range config.Users {
+		if u.Name != user {
+			continue
+		}
+		if len(u.User.Exec) > 0 || len(u.User.AuthProvider) > 0 {
+			return fmt.Errorf("kubeconfig user %q uses a credential plugin, which is not supported", u.Name)
+		}
+		k.token = u.User.Token
+		if u.User.TokenFile != "" {
+			k.tokenFile = u.User.TokenFile
+			if !filepath.IsAbs(k.tokenFile) {
+				k.tokenFile = filepath.Join(dir, k.tokenFile)
+			}
+		}
+		cert, err := readData(u.User.ClientCertificateData, u.User.ClientCertificate)
+		if err != nil {
+			return fmt.Errorf("failed to read the client certificate: %w", err)
+		}
+		key, err := readData(u.User.ClientKeyData, u.User.ClientKey)
+		if err != nil {
+			return fmt.Errorf("failed to read the client key: %w", err)
+		}
+		if cert != nil {
+			pair, err := tls.X509KeyPair(cert, key)
+			if err != nil {
+				return fmt.Errorf("invalid client certificate: %w", err)
+			}
+			tlsConfig.Certificates = []tls.Certificate{pair}
+		}
+	}
+	k.client = &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: tracePropagation(&http.Transport{TLSClientConfig: tlsConfig, Proxy: http.ProxyFromEnvironment}),
+	}
+	return nil
+}
+
+// get fetches path from the API server.
+func (k *kubeClient) get(ctx context.Context, path string, query url.Values) ([]byte, error) {
+	u := k.server + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	token := k.token
+	if k.tokenFile != "" {
+		// service account tokens are rotated
+		data, err := os.ReadFile(k.tokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the kubernetes token: %w", err)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach the kubernetes API: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 32<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var status struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(body, &status) == nil && status.Message != "" {
+			return nil, localizedError(ctx, "error.kubernetes_api", status.Message)
+		}
+		return nil, fmt.Errorf("kubernetes API: status code %d", resp.StatusCode)
+	}
+	return body, nil
+}
+
+func (k *kubeClient) health(ctx context.Context) error {
+	_, err := k.get(ctx, "/version", nil)
+	return err
+}
+
+// namespace returns the namespace of the request if it is allowed.
+func (k *kubeClient) namespace(ctx context.Context, request mcp.CallToolRequest) (string, error) {
+	namespace := request.GetString("namespace", k.defaultNamespace)
+	if namespace == "" {
+		return "", localizedError(ctx, "error.invalid_argument", "namespace", "a namespace is required")
+	}
+	if namespace == AllNamespaces || !slices.Contains(k.namespaces, namespace) && !slices.Contains(k.namespaces, AllNamespaces) {
+		return "", localizedError(ctx, "error.namespace_not_allowed", namespace)
+	}
+	return namespace, nil
+}
+
+// listQuery passes the label selector and the limit of the request.
+func listQuery(request mcp.CallToolRequest) url.Values {
+	query := url.Values{"limit": {strconv.Itoa(maxK8sItems)}}
+	if selector := request.GetString("label_selector", ""); selector != "" {
+		query.Set("labelSelector", selector)
+	}
+	return query
+}
+
+func (s *Server) registerKubernetesTools() {
+	if s.k8s == nil {
+		return
+	}
+	namespace := mcp.WithString("namespace",
+		mcp.Description("Namespace, "+s.k8s.defaultNamespace+" by default"),
+	)
+	if s.k8s.defaultNamespace == "" {
+		namespace = mcp.WithString("namespace",
+			mcp.Required(),
+			mcp.Description("Namespace"),
+		)
+	}
+	labelSelector := mcp.WithString("label_selector",
+		mcp.Description("Label selector, i.e. app=web,tier!=cache"),
+	)
+
+	s.AddTool(mcp.NewTool(string(K8S_LIST_PODS),
+		mcp.WithDescription("Lists the pods of a namespace with their phase, readiness, restarts and node"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		namespace,
+		labelSelector,
+	), s.handleListPods)
+
+	s.AddTool(mcp.NewTool(string(K8S_LIST_DEPLOYMENTS),
+		mcp.WithDescription("Lists the deployments of a namespace with their replicas and images"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		namespace,
+		labelSelector,
+	), s.handleListDeployments)
+
+	s.AddTool(mcp.NewTool(string(K8S_LIST_EVENTS),
+		mcp.WithDescription("Lists the recent events of a namespace, newest first"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		namespace,
+		mcp.WithString("object",
+			mcp.Description("Only events of the object with this name, i.e. a pod"),
+		),
+		mcp.WithBoolean("warnings_only",
+			mcp.Description("Only Warning events"),
+		),
+	), s.handleListEvents)
+
+	s.AddTool(mcp.NewTool(string(K8S_POD_LOGS),
+		mcp.WithDescription("Returns the last log lines of a pod container"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		namespace,
+		mcp.WithString("pod",
+			mcp.Description("Pod name"),
+			mcp.Required(),
+		),
+		mcp.WithString("container",
+			mcp.Description("Container name, required for pods with several containers"),
+		),
+		mcp.WithNumber("tail_lines",
+			mcp.Description("Number of lines, 200 by default"),
+			mcp.Min(1),
+			mcp.Max(5000),
+		),
+		mcp.WithNumber("since_seconds",
+			mcp.Description("Only lines logged in the last seconds"),
+			mcp.Min(1),
+		),
+		mcp.WithBoolean("previous",
+			mcp.Description("Logs of the previous, crashed instance of the container"),
+		),
+	), s.handlePodLogs)
+}
+
+// kubeMeta is the metadata of Kubernetes objects.
+type kubeMeta struct {
+	Name              string            `json:"name"`
+	Labels            map[string]string `json:"labels,omitempty"`
+	CreationTimestamp time.Time         `json:"creationTimestamp"`
+}
+
+// PodSummary is a pod listed by k8s_list_pods.
+type PodSummary struct {
+	Name     string    `json:"name"`
+	Phase    string    `json:"phase"`
+	Ready    string    `json:"ready"`
+	Restarts int       `json:"restarts"`
+	Reason   string    `json:"reason,omitempty"`
+	Node     string    `json:"node,omitempty"`
+	IP       string    `json:"ip,omitempty"`
+	Created  time.Time `json:"created"`
+}
+
+func (s *Server) handleListPods(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	namespace, err := s.k8s.namespace(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	body, err := s.k8s.get(ctx, "/api/v1/namespaces/"+url.PathEscape(namespace)+"/pods", listQuery(request))
+	if err != nil {
+		return nil, err
+	}
+	var list struct {
+		Items []struct {
+			Metadata kubeMeta `json:"metadata"`
+			Spec     struct {
+				NodeName string `json:"nodeName"`
+			} `json:"spec"`
+			Status struct {
+				Phase             string `json:"phase"`
+				Reason            string `json:"reason"`
+				PodIP             string `json:"podIP"`
+				ContainerStatuses []struct {
+					Ready        bool `json:"ready"`
+					RestartCount int  `json:"restartCount"`
+					State        struct {
+						Waiting *struct {
+							Reason string `json:"reason"`
+						} `json:"waiting"`
+					} `json:"state"`
+				} `json:"containerStatuses"`
+			} `json:"status"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, fmt.Errorf("failed to decode the pods: %w", err)
+	}
+	pods := make([]PodSummary, 0, len(list.Items))
+	for _, item := range list.Items {
+		pod := PodSummary{
+			Name:    item.Metadata.Name,
+			Phase:   item.Status.Phase,
+			Reason:  item.Status.Reason,
+			Node:    item.Spec.NodeName,
+			IP:      item.Status.PodIP,
+			Created: item.Metadata.CreationTimestamp,
+		}
+		ready := 0
+		for _, container := range item.Status.ContainerStatuses {
+			if container.Ready {
+				ready++
+			}
+			pod.Restarts += container.RestartCount
+			// i.e. CrashLoopBackOff says more than the phase
+			if container.State.Waiting != nil && pod.Reason == "" {
+				pod.Reason = container.State.Waiting.Reason
+			}
+		}
+		pod.Ready = fmt.Sprintf("%d/%d", ready, len(item.Status.ContainerStatuses))
+		pods = append(pods, pod)
+	}
+	return jsonResult(map[string]any{"namespace": namespace, "pods": pods})
+}
+
+// DeploymentSummary is a deployment listed by k8s_list_deployments.
+type DeploymentSummary struct {
+	Name      string    `json:"name"`
+	Replicas  int       `json:"replicas"`
+	Ready     int       `json:"ready"`
+	Updated   int       `json:"updated"`
+	Available int       `json:"available"`
+	Images    []string  `json:"images"`
+	Created   time.Time `json:"created"`
+}
+
+func (s *Server) handleListDeployments(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	namespace, err := s.k8s.namespace(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	body, err := s.k8s.get(ctx, "/apis/apps/v1/namespaces/"+url.PathEscape(namespace)+"/deployments", listQuery(request))
+	if err != nil {
+		return nil, err
+	}
+	var list struct {
+		Items []struct {
+			Metadata kubeMeta `json:"metadata"`
+			Spec     struct {
+				Replicas *int `json:"replicas"`
+				Template struct {
+					Spec struct {
+						Containers []struct {
+							Image string `json:"image"`
+						} `json:"containers"`
+					} `json:"spec"`
+				} `json:"template"`
+			} `json:"spec"`
+			Status struct {
+				ReadyReplicas     int `json:"readyReplicas"`
+				UpdatedReplicas   int `json:"updatedReplicas"`
+				AvailableReplicas int `json:"availableReplicas"`
+			} `json:"status"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, fmt.Errorf("failed to decode the deployments: %w", err)
+	}
+	deployments := make([]DeploymentSummary, 0, len(list.Items))
+	for _, item := range list.Items {
+		deployment := DeploymentSummary{
+			Name:      item.Metadata.Name,
+			Replicas:  1,
+			Ready:     item.Status.ReadyReplicas,
+			Updated:   item.Status.UpdatedReplicas,
+			Available: item.Status.AvailableReplicas,
+			Created:   item.Metadata.CreationTimestamp,
+		}
+		if item.Spec.Replicas != nil {
+			deployment.Replicas = *item.Spec.Replicas
+		}
+		for _, container := range item.Spec.Template.Spec.Containers {
+			deployment.Images = append(deployment.Images, container.Image)
+		}
+		deployments = append(deployments, deployment)
+	}
+	return jsonResult(map[string]any{"namespace": namespace, "deployments": deployments})
+}
+
+// EventSummary is an event listed by k8s_list_events.
+type EventSummary struct {
+	Type     string    `json:"type"`
+	Reason   string    `json:"reason"`
+	Object   string    `json:"object"`
+	Message  string    `json:"message"`
+	Count    int       `json:"count"`
+	LastSeen time.Time `json:"lastSeen"`
+}
+
+func (s *Server) handleListEvents(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	namespace, err := s.k8s.namespace(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{"limit": {strconv.Itoa(maxK8sItems)}}
+	var selectors []string
+	if object := request.GetString("object", ""); object != "" {
+		selectors = append(selectors, "involvedObject.name="+object)
+	}
+	if request.GetBool("warnings_only", false) {
+		selectors = append(selectors, "type=Warning")
+	}
+	if len(selectors) > 0 {
+		query.Set("fieldSelector", strings.Join(selectors, ","))
+	}
+	body, err := s.k8s.get(ctx, "/api/v1/namespaces/"+url.PathEscape(namespace)+"/events", query)
+	if err != nil {
+		return nil, err
+	}
+	var list struct {
+		Items []struct {
+			Type           string `json:"type"`
+			Reason         string `json:"reason"`
+			Message        string `json:"message"`
+			Count          int    `json:"count"`
+			InvolvedObject struct {
+				Kind string `json:"kind"`
+				Name string `json:"name"`
+			} `json:"involvedObject"`
+			Metadata      kubeMeta   `json:"metadata"`
+			LastTimestamp *time.Time `json:"lastTimestamp"`
+			EventTime     *time.Time `json:"eventTime"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, fmt.Errorf("failed to decode the events: %w", err)
+	}
+	events := make([]EventSummary, 0, len(list.Items))
+	for _, item := range list.Items {
+		event := EventSummary{
+			Type:     item.Type,
+			Reason:   item.Reason,
+			Object:   strings.ToLower(item.InvolvedObject.Kind) + "/" + item.InvolvedObject.Name,
+			Message:  item.Message,
+			Count:    max(item.Count, 1),
+			LastSeen: item.Metadata.CreationTimestamp,
+		}
+		// events of the events.k8s.io API only set the event time
+		if item.LastTimestamp != nil {
+			event.LastSeen = *item.LastTimestamp
+		} else if item.EventTime != nil {
+			event.LastSeen = *item.EventTime
+		}
+		events = append(events, event)
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].LastSeen.After(events[j].LastSeen) })
+	return jsonResult(map[string]any{"namespace": namespace, "events": events})
+}
+
+func (s *Server) handlePodLogs(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	namespace, err := s.k8s.namespace(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	pod, err := requireText(ctx, request, "pod")
+	if err != nil {
+		return nil, err
+	}
+	if pod == "" || strings.ContainsAny(pod, "/?#") {
+		return nil, localizedError(ctx, "error.invalid_argument", "pod", fmt.Sprintf("invalid pod name %q", pod))
+	}
+	tailLines := request.GetInt("tail_lines", DefaultLogTailLines)
+	if tailLines < 1 || tailLines > 5000 {
+		tailLines = DefaultLogTailLines
+	}
+	query := url.Values{
+		"tailLines":  {strconv.Itoa(tailLines)},
+		"limitBytes": {strconv.Itoa(maxK8sLogBytes)},
+		"timestamps": {"true"},
+	}
+	if container := request.GetString("container", ""); container != "" {
+		query.Set("container", container)
+	}
+	if since := request.GetInt("since_seconds", 0); since > 0 {
+		query.Set("sinceSeconds", strconv.Itoa(since))
+	}
+	if request.GetBool("previous", false) {
+		query.Set("previous", "true")
+	}
+	body, err := s.k8s.get(ctx, "/api/v1/namespaces/"+url.PathEscape(namespace)+"/pods/"+url.PathEscape(pod)+"/log", query)
+	if err != nil {
+		return nil, err
+	}
+	if len(body) == 0 {
+		return mcp.NewToolResultText("No log lines"), nil
+	}
+	return mcp.NewToolResultText(string(body)), nil
+}
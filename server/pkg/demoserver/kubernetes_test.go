@@ -0,0 +1,57 @@
+package demoserver
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// TestKubernetesDefaultNamespace checks that an allowlist starting with * defaults
+// to the namespace of the kubeconfig context, or requires one.
+func TestKubernetesDefaultNamespace(t *testing.T) {
+	kubeconfig := filepath.Join(t.TempDir(), "kubeconfig.json")
+	os.WriteFile(kubeconfig, []byte(`{
+		"current-context": "dev",
+		"clusters": [{"name": "dev", "cluster": {"server": "https://127.0.0.1:6443"}}],
+		"users": [{"name": "dev", "user": {"token": "token"}}],
+		"contexts": [{"name": "dev", "context": {"cluster": "dev", "user": "dev", "namespace": "team"}}]
+	}`), 0o600)
+
+	namespace := func(k *kubeClient, arguments map[string]any) (string, error) {
+		request := mcp.CallToolRequest{}
+		request.Params.Arguments = arguments
+		return k.namespace(context.Background(), request)
+	}
+	for _, test := range []struct {
+		config    KubernetesConfig
+		arguments map[string]any
+		want      string // empty when the call is rejected
+	}{
+		{KubernetesConfig{Kubeconfig: kubeconfig, Namespaces: []string{AllNamespaces}}, nil, "team"},
+		{KubernetesConfig{Kubeconfig: kubeconfig, Namespaces: []string{AllNamespaces}}, map[string]any{"namespace": "ops"}, "ops"},
+		{KubernetesConfig{Kubeconfig: kubeconfig}, nil, "team"},
+		{KubernetesConfig{Kubeconfig: kubeconfig}, map[string]any{"namespace": "ops"}, ""},
+		{KubernetesConfig{Kubeconfig: kubeconfig, Namespaces: []string{"ops", "team"}}, nil, "ops"},
+		{KubernetesConfig{HTTPClient: http.DefaultClient, Namespaces: []string{AllNamespaces}}, nil, ""},
+		{KubernetesConfig{HTTPClient: http.DefaultClient, Namespaces: []string{AllNamespaces}}, map[string]any{"namespace": AllNamespaces}, ""},
+	} {
+		k := &kubeClient{config: test.config}
+		if err := k.load(); err != nil {
+			t.Fatal(err)
+		}
+		got, err := namespace(k, test.arguments)
+		if test.want == "" {
+			if err == nil {
+				t.Errorf("%v with %v = %q, want an error", test.config.Namespaces, test.arguments, got)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("%v with %v = %q, %v, want %q", test.config.Namespaces, test.arguments, got, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package demoserver
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const DefaultDrainTimeout = 30 * time.Second
+
+// maintenance tracks whether new sessions are accepted and what is still
+// running, so deploys can drain the server.
+type maintenance struct {
+	enabled  atomic.Bool
+	inFlight atomic.Int64
+	sessions atomic.Int64
+	streams  atomic.Int64
+}
+
+// WithDrainTimeout bounds how long Shutdown waits for in-flight tool calls
+// and open SSE streams. It is also the Retry-After of the sessions rejected in maintenance, at
+// least 1s.
+func WithDrainTimeout(timeout time.Duration) Option {
+	return func(s *Server) {
+		s.drainTimeout = timeout
+	}
+}
+
+// SetMaintenance toggles maintenance mode. In maintenance mode new sessions
+// are rejected with a Retry-After while existing ones keep working.
+func (s *Server) SetMaintenance(enabled bool) {
+	s.maintenance.enabled.Store(enabled)
+	log.Printf("Maintenance mode enabled=%t", enabled)
+}
+
+// InMaintenance reports whether maintenance mode is enabled.
+func (s *Server) InMaintenance() bool {
+	return s.maintenance.enabled.Load()
+}
+
+func (s *Server) registerMaintenanceHooks(hooks *server.Hooks) {
+	hooks.AddOnRegisterSession(func(ctx context.Context, session server.ClientSession) {
+		s.maintenance.sessions.Add(1)
+	})
+	hooks.AddOnUnregisterSession(func(ctx context.Context, session server.ClientSession) {
+		s.maintenance.sessions.Add(-1)
+	})
+}
+
+func (s *Server) inFlightMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		s.maintenance.inFlight.Add(1)
+		defer s.maintenance.inFlight.Add(-1)
+		return next(ctx, request)
+	}
+}
+
+// isNewSession reports whether the request would open a new session: an SSE
+// stream or a streamable HTTP request without a session ID.
+func isNewSession(r *http.Request) bool {
+	if r.Method == http.MethodGet && r.URL.Path == "/sse" {
+		return true
+	}
+	return r.Method == http.MethodPost && r.URL.Path == "/mcp" && r.Header.Get("Mcp-Session-Id") == ""
+}
+
+func (s *Server) maintenanceMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.InMaintenance() && isNewSession(r) {
+			w.Header().Set("Retry-After", strconv.Itoa(max(1, int(s.drainTimeout.Seconds()))))
+			http.Error(w, "Server is in maintenance, retry later", http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) handleAdminMaintenance(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		s.SetMaintenance(true)
+	case http.MethodDelete:
+		s.SetMaintenance(false)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"maintenance": s.InMaintenance(),
+		"inFlight":    s.maintenance.inFlight.Load(),
+		"sessions":    s.maintenance.sessions.Load(),
+		"streams":     s.maintenance.streams.Load(),
+	})
+}
+
+// drain enables maintenance mode and waits until the in-flight tool calls are
+// done and the open SSE streams are closed, or the drain timeout passed.
+// Streamable HTTP sessions are not waited for, those of clients that never
+// send a DELETE stay registered, and Shutdown cuts off what is left.
+func (s *Server) drain(ctx context.Context) {
+	s.SetMaintenance(true)
+	ctx, cancel := context.WithTimeout(ctx, s.drainTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for s.maintenance.inFlight.Load() > 0 || s.maintenance.streams.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			log.Printf("Drain timeout reached with %d tool calls in flight and %d SSE streams open",
+				s.maintenance.inFlight.Load(), s.maintenance.streams.Load())
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Shutdown deregisters the server, drains it and stops listening.
+func (s *Server) Shutdown(ctx context.Context) error {
+	if err := s.Deregister(ctx); err != nil {
+		log.Printf("Failed to deregister: %v", err)
+	}
+	s.drain(ctx)
+	if s.tracer != nil {
+		s.tracer.flush(ctx)
+	}
+	if s.httpServer == nil {
+		return nil
+	}
+	// whatever is left after draining, like SSE streams past the drain
+	// timeout, is cut off
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := s.httpServer.Shutdown(ctx); err != nil {
+		return s.httpServer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,311 @@
+package demoserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	SEND_SLACK_MESSAGE ToolName = "send_slack_message"
+	SEND_EMAIL         ToolName = "send_email"
+
+	// DefaultNotificationRateLimit is how many messages the notification
+	// tools send per hour.
+	DefaultNotificationRateLimit = 20
+
+	maxNotificationText = 16 << 10
+)
+
+// Notification is a message for humans.
+type Notification struct {
+	Subject string
+	Text    string
+	// To are the recipients of channels addressing them, i.e. email.
+	To []string
+	// Date is when the notification was sent, now when zero.
+	Date time.Time
+}
+
+// NotificationChannel delivers notifications, i.e. to Slack or by email. The
+// channels are independent of the tools, so alerts of the server can use them
+// too.
+type NotificationChannel interface {
+	Send(ctx context.Context, notification Notification) error
+}
+
+// SlackChannel posts to a Slack incoming webhook, whose URL is a secret that
+// also selects the Slack channel.
+type SlackChannel struct {
+	WebhookURL string
+	// HTTPClient defaults to a client with a 10s timeout.
+	HTTPClient *http.Client
+}
+
+// Send implements NotificationChannel.
+func (c *SlackChannel) Send(ctx context.Context, notification Notification) error {
+	text := notification.Text
+	if notification.Subject != "" {
+		text = "*" + notification.Subject + "*\n" + text
+	}
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := c.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second, Transport: tracePropagation(nil)}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		// the URL holds the secret, which *url.Error includes
+		return fmt.Errorf("failed to reach the Slack webhook")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return fmt.Errorf("slack webhook: status code %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// EmailChannel sends emails through an SMTP server. With a username the
+// server has to support STARTTLS, unless it is on localhost.
+type EmailChannel struct {
+	// Addr is the host:port of the SMTP server.
+	Addr     string
+	Username string
+	Password string
+	From     string
+}
+
+// Send implements NotificationChannel.
+func (c *EmailChannel) Send(ctx context.Context, notification Notification) error {
+	if len(notification.To) == 0 {
+		return fmt.Errorf("email without recipients")
+	}
+	var auth smtp.Auth
+	if c.Username != "" {
+		host, _, err := net.SplitHostPort(c.Addr)
+		if err != nil {
+			return fmt.Errorf("invalid SMTP address: %w", err)
+		}
+		auth = smtp.PlainAuth("", c.Username, c.Password, host)
+	}
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", c.From)
+	fmt.Fprintf(&message, "To: %s\r\n", strings.Join(notification.To, ", "))
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", notification.Subject))
+	date := notification.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+	fmt.Fprintf(&message, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	message.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: 8bit\r\n\r\n")
+	message.WriteString(strings.ReplaceAll(strings.ReplaceAll(notification.Text, "\r\n", "\n"), "\n", "\r\n"))
+
+	// net/smtp has no context, the send runs until the server times out
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(c.Addr, auth, c.From, notification.To, message.Bytes())
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// NotificationConfig enables the notification tools for the configured
+// channels.
+type NotificationConfig struct {
+	// Slack enables send_slack_message.
+	Slack NotificationChannel
+	// Email enables send_email.
+	Email NotificationChannel
+	// EmailRecipients allowlists addresses, or domains as @example.com, the
+	// agents may email. send_email requires at least one.
+	EmailRecipients []string
+	// RateLimit is the messages per hour across both tools,
+	// DefaultNotificationRateLimit when zero.
+	RateLimit int
+}
+
+// WithNotifications registers send_slack_message and send_email for the
+// channels of config, so agents can surface results to humans. Every
+// message is logged for audit and counts towards the rate limit.
+func WithNotifications(config NotificationConfig) Option {
+	return func(s *Server) {
+		if config.RateLimit <= 0 {
+			config.RateLimit = DefaultNotificationRateLimit
+		}
+		s.notifications = &notifications{config: config}
+	}
+}
+
+type notifications struct {
+	config NotificationConfig
+
+	mu   sync.Mutex
+	sent []time.Time
+}
+
+// allow records a message unless RateLimit messages were sent in the last
+// hour.
+func (n *notifications) allow(now time.Time) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	recent := n.sent[:0]
+	for _, sent := range n.sent {
+		if now.Sub(sent) < time.Hour {
+			recent = append(recent, sent)
+		}
+	}
+	n.sent = recent
+	if len(n.sent) >= n.config.RateLimit {
+		return false
+	}
+	n.sent = append(n.sent, now)
+	return true
+}
+
+func (n *notifications) recipientAllowed(address string) bool {
+	address = strings.ToLower(address)
+	for _, allowed := range n.config.EmailRecipients {
+		allowed = strings.ToLower(allowed)
+		if address == allowed || strings.HasPrefix(allowed, "@") && strings.HasSuffix(address, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) registerNotificationTools() {
+	if s.notifications == nil {
+		return
+	}
+	if s.notifications.config.Slack != nil {
+		s.AddTool(mcp.NewTool(string(SEND_SLACK_MESSAGE),
+			mcp.WithDescription("Posts a message to the configured Slack channel"),
+			mcp.WithDestructiveHintAnnotation(false),
+			mcp.WithOpenWorldHintAnnotation(true),
+			mcp.WithString("text",
+				mcp.Description("Message in Slack mrkdwn"),
+				mcp.Required(),
+			),
+			mcp.WithString("title",
+				mcp.Description("Bold first line of the message"),
+			),
+		), s.handleSendSlackMessage)
+	}
+	if s.notifications.config.Email != nil && len(s.notifications.config.EmailRecipients) > 0 {
+		s.AddTool(mcp.NewTool(string(SEND_EMAIL),
+			mcp.WithDescription("Sends a plain text email to allowed recipients"),
+			mcp.WithDestructiveHintAnnotation(false),
+			mcp.WithOpenWorldHintAnnotation(true),
+			mcp.WithArray("to",
+				mcp.Description("Recipient addresses"),
+				mcp.Required(),
+				mcp.Items(map[string]any{"type": "string"}),
+			),
+			mcp.WithString("subject",
+				mcp.Description("Subject line"),
+				mcp.Required(),
+			),
+			mcp.WithString("body",
+				mcp.Description("Plain text body"),
+				mcp.Required(),
+			),
+		), s.handleSendEmail)
+	}
+}
+
+// sendNotification rate limits, audits and sends a notification.
+func (s *Server) sendNotification(ctx context.Context, tool ToolName, channel NotificationChannel, notification Notification) error {
+	if len(notification.Text) > maxNotificationText {
+		return localizedError(ctx, "error.invalid_argument", "text", fmt.Sprintf("longer than %d bytes", maxNotificationText))
+	}
+	now := s.clock()
+	if !s.notifications.allow(now) {
+		return localizedError(ctx, "error.notification_rate_limited", s.notifications.config.RateLimit)
+	}
+	notification.Date = now
+	principal := "anonymous"
+	if p, ok := PrincipalFromContext(ctx); ok {
+		principal = p.Name
+	}
+	err := channel.Send(ctx, notification)
+	if err != nil {
+		log.Printf("Notification %s by %s to %v with subject %q failed: %v", tool, principal, notification.To, notification.Subject, err)
+		return err
+	}
+	log.Printf("Notification %s by %s to %v with subject %q, %d bytes", tool, principal, notification.To, notification.Subject, len(notification.Text))
+	return nil
+}
+
+func (s *Server) handleSendSlackMessage(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	text, err := requireText(ctx, request, "text")
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(text) == "" {
+		return nil, localizedError(ctx, "error.invalid_argument", "text", "must not be empty")
+	}
+	notification := Notification{Subject: request.GetString("title", ""), Text: text}
+	if err := s.sendNotification(ctx, SEND_SLACK_MESSAGE, s.notifications.config.Slack, notification); err != nil {
+		return nil, err
+	}
+	return mcp.NewToolResultText("Message posted to Slack"), nil
+}
+
+func (s *Server) handleSendEmail(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var to []string
+	for _, recipient := range request.GetStringSlice("to", nil) {
+		address, err := mail.ParseAddress(recipient)
+		if err != nil {
+			return nil, localizedError(ctx, "error.invalid_argument", "to", fmt.Sprintf("invalid address %q", recipient))
+		}
+		if !s.notifications.recipientAllowed(address.Address) {
+			return nil, localizedError(ctx, "error.recipient_not_allowed", address.Address)
+		}
+		to = append(to, address.Address)
+	}
+	if len(to) == 0 {
+		return nil, localizedError(ctx, "error.invalid_argument", "to", "a recipient is required")
+	}
+	subject, err := requireText(ctx, request, "subject")
+	if err != nil {
+		return nil, err
+	}
+	if strings.ContainsAny(subject, "\r\n") {
+		return nil, localizedError(ctx, "error.invalid_argument", "subject", "must be a single line")
+	}
+	body, err := requireText(ctx, request, "body")
+	if err != nil {
+		return nil, err
+	}
+	notification := Notification{Subject: subject, Text: body, To: to}
+	if err := s.sendNotification(ctx, SEND_EMAIL, s.notifications.config.Email, notification); err != nil {
+		return nil, err
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("Email sent to %s", strings.Join(to, ", "))), nil
+}
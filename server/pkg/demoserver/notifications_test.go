@@ -0,0 +1,173 @@
+package demoserver
+
+import (
+	"context"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordingChannel records the notifications it was asked to send.
+type recordingChannel struct {
+	sent []Notification
+}
+
+func (c *recordingChannel) Send(ctx context.Context, notification Notification) error {
+	c.sent = append(c.sent, notification)
+	return nil
+}
+
+// TestSendEmailHeaderInjection checks that line breaks in the subject or
+// the recipients, which would add headers or recipients to the email, are
+// rejected before anything is sent.
+func TestSendEmailHeaderInjection(t *testing.T) {
+	channel := &recordingChannel{}
+	s, err := New(WithTransport(TransportHTTP), WithNotifications(NotificationConfig{
+		Email:           channel,
+		EmailRecipients: []string{"@example.com"},
+		RateLimit:       1,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		to      []string
+		subject string
+		wantErr string
+	}{
+		{"CRLF in subject", []string{"bob@example.com"}, "Report\r\nBcc: eve@evil.test", "subject"},
+		{"LF in subject", []string{"bob@example.com"}, "Report\nBcc: eve@evil.test", "subject"},
+		{"CR in subject", []string{"bob@example.com"}, "Report\rBcc: eve@evil.test", "subject"},
+		{"CRLF in recipient", []string{"bob@example.com\r\nBcc: eve@evil.test"}, "Report", "invalid address"},
+		{"LF in recipient", []string{"bob@example.com\nBcc: eve@evil.test"}, "Report", "invalid address"},
+		{"CRLF in display name", []string{"\"Bob\r\nBcc: eve@evil.test\" <bob@example.com>"}, "Report", "invalid address"},
+		{"recipient not allowed", []string{"eve@evil.test"}, "Report", "eve@evil.test"},
+		{"lookalike domain", []string{"eve@notexample.com"}, "Report", "eve@notexample.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := callTool(t, s, string(SEND_EMAIL), map[string]any{"to": tt.to, "subject": tt.subject, "body": "Done."})
+			if message := errorMessage(response); !strings.Contains(message, tt.wantErr) {
+				t.Errorf("call returned %v, want an error containing %q", response, tt.wantErr)
+			}
+		})
+	}
+	if len(channel.sent) != 0 {
+		t.Fatalf("sent %+v", channel.sent)
+	}
+
+	// the rejected calls did not use up the rate limit of 1
+	response := callTool(t, s, string(SEND_EMAIL), map[string]any{"to": []string{"Bob <bob@example.com>"}, "subject": "Report", "body": "Line 1\nLine 2"})
+	if _, ok := resultText(response); !ok {
+		t.Fatalf("valid email failed: %v", response)
+	}
+	if len(channel.sent) != 1 || channel.sent[0].To[0] != "bob@example.com" {
+		t.Errorf("sent %+v, want the email to bob@example.com", channel.sent)
+	}
+	response = callTool(t, s, string(SEND_EMAIL), map[string]any{"to": []string{"bob@example.com"}, "subject": "Again", "body": "Done."})
+	if message := errorMessage(response); message == "" || len(channel.sent) != 1 {
+		t.Errorf("email over the rate limit returned %v", response)
+	}
+}
+
+// TestEmailChannel checks the message EmailChannel hands to the SMTP server,
+// and that it encodes the line breaks of subjects of other callers than
+// send_email, and rejects those of recipients.
+func TestEmailChannel(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	messages := make(chan string, 1)
+	go serveSMTP(listener, messages)
+
+	channel := &EmailChannel{Addr: listener.Addr().String(), From: "mcp@example.com"}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = channel.Send(ctx, Notification{
+		Subject: "Report\r\nBcc: eve@evil.test",
+		Text:    "Line 1\nLine 2",
+		To:      []string{"bob@example.com"},
+		Date:    time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := <-messages
+	header, body, _ := strings.Cut(message, "\r\n\r\n")
+	for _, line := range strings.Split(header, "\r\n") {
+		if strings.HasPrefix(line, "Bcc:") {
+			t.Errorf("injected header %q in\n%s", line, header)
+		}
+	}
+	for _, want := range []string{"From: mcp@example.com", "To: bob@example.com", "Subject: =?utf-8?q?", "Date: Fri, 02 Jan 2026 03:04:05 +0000"} {
+		if !strings.Contains(header, want) {
+			t.Errorf("header does not contain %q:\n%s", want, header)
+		}
+	}
+	if body != "Line 1\r\nLine 2" {
+		t.Errorf("body %q, want CRLF line breaks", body)
+	}
+
+	err = channel.Send(ctx, Notification{Subject: "Report", Text: "Done.", To: []string{"bob@example.com\r\nRCPT TO:<eve@evil.test>"}})
+	if err == nil {
+		t.Error("Send() succeeded with a line break in a recipient")
+	}
+	if err := channel.Send(ctx, Notification{Subject: "Report", Text: "Done."}); err == nil {
+		t.Error("Send() succeeded without recipients")
+	}
+}
+
+// serveSMTP accepts SMTP sessions on listener and sends the data of their
+// messages to messages, without the final line break.
+func serveSMTP(listener net.Listener, messages chan<- string) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			text := textproto.NewConn(conn)
+			text.PrintfLine("220 localhost ESMTP")
+			for {
+				line, err := text.ReadLine()
+				if err != nil {
+					return
+				}
+				switch command := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); command {
+				case "EHLO", "HELO":
+					text.PrintfLine("250 localhost")
+				case "MAIL", "RCPT", "RSET", "NOOP":
+					text.PrintfLine("250 OK")
+				case "DATA":
+					text.PrintfLine("354 Go ahead")
+					// read as sent, textproto would convert the line breaks
+					var data strings.Builder
+					for {
+						line, err := text.R.ReadString('\n')
+						if err != nil {
+							return
+						}
+						if line == ".\r\n" {
+							break
+						}
+						data.WriteString(line)
+					}
+					messages <- strings.TrimSuffix(data.String(), "\r\n")
+					text.PrintfLine("250 OK")
+				case "QUIT":
+					text.PrintfLine("221 Bye")
+					return
+				default:
+					text.PrintfLine("502 %s not implemented", command)
+				}
+			}
+		}()
+	}
+}
@@ -0,0 +1,311 @@
+package demoserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wagnerjt/go-mcp/shared/pkg/jwtauth"
+)
+
+const (
+	// DefaultPrincipalClaim identifies the caller of OIDC tokens.
+	DefaultPrincipalClaim = "sub"
+
+	// oidcLeeway tolerates clock skew with the provider.
+	oidcLeeway = time.Minute
+)
+
+// OIDCConfig protects the network transports with the ID or JWT access
+// tokens of any OpenID Connect provider.
+type OIDCConfig struct {
+	// Issuer is the issuer URL, the endpoints are discovered from its
+	// /.well-known/openid-configuration.
+	Issuer string
+	// Audience is required in the aud claim when set, i.e. the client ID for
+	// ID tokens or the API identifier for access tokens.
+	Audience string
+	// PrincipalClaim is the claim mapped to the principal, DefaultPrincipalClaim
+	// when empty, i.e. email or preferred_username.
+	PrincipalClaim string
+	// Validation selects how tokens are validated, ValidationJWKS when empty.
+	Validation string
+	// IntrospectionURL is the RFC 7662 endpoint of ValidationIntrospection,
+	// the discovered introspection_endpoint when empty.
+	IntrospectionURL string
+	// ClientID and ClientSecret authenticate the server to the introspection
+	// endpoint.
+	ClientID     string
+	ClientSecret string
+	// IntrospectionCacheTTL is how long introspection results are reused,
+	// DefaultIntrospectionCacheTTL when zero. Active tokens are never cached
+	// past their expiry.
+	IntrospectionCacheTTL time.Duration
+	// HTTPClient fetches the discovery document and keys, a client with a
+	// 10s timeout by default.
+	HTTPClient *http.Client
+}
+
+// WithOIDC requires a bearer token signed by the OIDC provider of config on
+// the network transports. The provider is discovered by a readiness check and
+// its protected resource metadata is served for MCP clients.
+func WithOIDC(config OIDCConfig) Option {
+	return func(s *Server) {
+		s.oidc = newOIDCVerifier(config)
+		s.authMiddleware = s.oidc.middleware
+		s.readiness.checks = append(s.readiness.checks, namedCheck{"oidc " + config.Issuer, s.oidc.discover})
+	}
+}
+
+// Principal is a caller authenticated by OIDC.
+type Principal struct {
+	Name   string
+	Claims map[string]any
+}
+
+type principalKey struct{}
+
+func withPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the OIDC principal of the request.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(Principal)
+	return principal, ok
+}
+
+type oidcDiscovery struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	IntrospectionEndpoint string   `json:"introspection_endpoint"`
+	ScopesSupported       []string `json:"scopes_supported"`
+}
+
+type oidcVerifier struct {
+	config OIDCConfig
+	client *http.Client
+	now    func() time.Time
+
+	mu        sync.RWMutex
+	discovery *oidcDiscovery
+	// validator verifies the JWTs against the keys of the discovered jwks_uri
+	validator *jwtauth.Validator
+	// the last discovery of a request and its error, see discoverOnDemand
+	discoveryAttempted time.Time
+	discoveryErr       error
+
+	introspections introspectionCache
+}
+
+func newOIDCVerifier(config OIDCConfig) *oidcVerifier {
+	config.Issuer = strings.TrimSuffix(config.Issuer, "/")
+	if config.PrincipalClaim == "" {
+		config.PrincipalClaim = DefaultPrincipalClaim
+	}
+	if config.Validation == "" {
+		config.Validation = ValidationJWKS
+	}
+	if config.IntrospectionCacheTTL == 0 {
+		config.IntrospectionCacheTTL = DefaultIntrospectionCacheTTL
+	}
+	client := config.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second, Transport: tracePropagation(nil)}
+	}
+	return &oidcVerifier{config: config, client: client, now: time.Now}
+}
+
+func (v *oidcVerifier) getJSON(ctx context.Context, url string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch %s: status code %d", url, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", url, err)
+	}
+	return nil
+}
+
+// discover fetches the discovery document and the signing keys, which
+// introspection does not need.
+func (v *oidcVerifier) discover(ctx context.Context) error {
+	var discovery oidcDiscovery
+	if err := v.getJSON(ctx, v.config.Issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+		return err
+	}
+	if strings.TrimSuffix(discovery.Issuer, "/") != v.config.Issuer {
+		return fmt.Errorf("discovered issuer %s does not match %s", discovery.Issuer, v.config.Issuer)
+	}
+	if v.config.Validation == ValidationIntrospection {
+		if v.config.IntrospectionURL == "" && discovery.IntrospectionEndpoint == "" {
+			return fmt.Errorf("no introspection_endpoint in the discovery document of %s", v.config.Issuer)
+		}
+		v.mu.Lock()
+		v.discovery = &discovery
+		v.mu.Unlock()
+		return nil
+	}
+	if discovery.JWKSURI == "" {
+		return fmt.Errorf("no jwks_uri in the discovery document of %s", v.config.Issuer)
+	}
+	validator, err := jwtauth.New(jwtauth.Config{Issuer: discovery.Issuer, Audience: v.audiences(), JWKSURL: discovery.JWKSURI})
+	if err != nil {
+		return err
+	}
+	validator.Leeway, validator.Clock, validator.HTTPClient = oidcLeeway, v.now, v.client
+	if err := validator.Refresh(ctx); err != nil {
+		return err
+	}
+	v.mu.Lock()
+	v.discovery, v.validator = &discovery, validator
+	v.mu.Unlock()
+	return nil
+}
+
+// discoverOnDemand discovers the provider for a request arriving before the
+// readiness check did, at most once per jwtauth.MinRefreshInterval like the
+// JWKS fetches, so an unreachable issuer is not fetched on every request.
+func (v *oidcVerifier) discoverOnDemand(ctx context.Context) error {
+	v.mu.Lock()
+	now := v.now()
+	if !v.discoveryAttempted.IsZero() && now.Sub(v.discoveryAttempted) < jwtauth.MinRefreshInterval {
+		err := v.discoveryErr
+		v.mu.Unlock()
+		return err
+	}
+	v.discoveryAttempted = now
+	v.discoveryErr = fmt.Errorf("discovery of %s in progress", v.config.Issuer)
+	v.mu.Unlock()
+
+	err := v.discover(ctx)
+	v.mu.Lock()
+	v.discoveryErr = err
+	v.mu.Unlock()
+	return err
+}
+
+func (v *oidcVerifier) audiences() []string {
+	if v.config.Audience == "" {
+		return nil
+	}
+	return []string{v.config.Audience}
+}
+
+// verify checks the signature and claims of a JWT with the jwtauth
+// validator of the discovered issuer and maps it to a principal.
+func (v *oidcVerifier) verify(ctx context.Context, token string) (Principal, error) {
+	v.mu.RLock()
+	validator := v.validator
+	v.mu.RUnlock()
+	if validator == nil {
+		if err := v.discoverOnDemand(ctx); err != nil {
+			return Principal{}, err
+		}
+		v.mu.RLock()
+		validator = v.validator
+		v.mu.RUnlock()
+	}
+	claims, err := validator.Validate(ctx, token)
+	if errors.Is(err, jwtauth.ErrInvalidToken) {
+		return Principal{}, fmt.Errorf("%w: %v", errInvalidToken, err)
+	}
+	if err != nil {
+		return Principal{}, err
+	}
+	return v.principal(claims.Raw)
+}
+
+var errInvalidToken = errors.New("invalid token")
+
+// principal maps the claims of a valid token to its principal.
+func (v *oidcVerifier) principal(claims map[string]any) (Principal, error) {
+	var name string
+	switch value := claims[v.config.PrincipalClaim].(type) {
+	case string:
+		name = value
+	case float64:
+		name = fmt.Sprint(value)
+	}
+	if name == "" {
+		return Principal{}, fmt.Errorf("%w: no %s claim", errInvalidToken, v.config.PrincipalClaim)
+	}
+	return Principal{Name: name, Claims: claims}, nil
+}
+
+// middleware lets requests with a valid bearer token through and challenges
+// the others as described by the MCP authorization spec.
+func (v *oidcVerifier) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			v.challenge(w, r, "")
+			return
+		}
+		verify := v.verify
+		if v.config.Validation == ValidationIntrospection {
+			verify = v.introspect
+		}
+		principal, err := verify(r.Context(), token)
+		if err != nil {
+			log.Printf("Rejected OIDC token from %s: %v", r.RemoteAddr, err)
+			if !errors.Is(err, errInvalidToken) {
+				// the provider could not be reached, not the client's fault
+				http.Error(w, "Authorization server unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			v.challenge(w, r, "invalid_token")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(withPrincipal(r.Context(), principal)))
+	})
+}
+
+func requestBaseURL(r *http.Request) string {
+	if r.TLS != nil {
+		return "https://" + r.Host
+	}
+	return "http://" + r.Host
+}
+
+func (v *oidcVerifier) challenge(w http.ResponseWriter, r *http.Request, code string) {
+	challenge := fmt.Sprintf(`Bearer realm="go-mcp", resource_metadata="%s/.well-known/oauth-protected-resource"`, requestBaseURL(r))
+	if code != "" {
+		challenge += fmt.Sprintf(`, error="%s"`, code)
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	http.Error(w, "Unauthorized", http.StatusUnauthorized)
+}
+
+// handleResourceMetadata serves the RFC 9728 metadata pointing MCP clients
+// to the provider.
+func (v *oidcVerifier) handleResourceMetadata(w http.ResponseWriter, r *http.Request) {
+	metadata := map[string]any{
+		"resource":                 requestBaseURL(r) + "/",
+		"authorization_servers":    []string{v.config.Issuer},
+		"bearer_methods_supported": []string{"header"},
+	}
+	v.mu.RLock()
+	if v.discovery != nil && len(v.discovery.ScopesSupported) > 0 {
+		metadata["scopes_supported"] = v.discovery.ScopesSupported
+	}
+	v.mu.RUnlock()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(metadata)
+}
@@ -0,0 +1,111 @@
+package demoserver
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/wagnerjt/go-mcp/shared/pkg/jwtauth"
+)
+
+// TestOIDC validates tokens of a fake provider through the OIDC middleware,
+// which verifies them with jwtauth.
+func TestOIDC(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b64 := base64.RawURLEncoding.EncodeToString
+	var provider *httptest.Server
+	provider = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			json.NewEncoder(w).Encode(map[string]string{"issuer": provider.URL, "jwks_uri": provider.URL + "/jwks"})
+		case "/jwks":
+			json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{
+				{"kty": "RSA", "kid": "k1", "use": "sig", "n": b64(key.N.Bytes()), "e": b64(big.NewInt(int64(key.E)).Bytes())},
+			}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer provider.Close()
+	sign := func(claims map[string]any) string {
+		head, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "k1"})
+		body, _ := json.Marshal(claims)
+		signed := b64(head) + "." + b64(body)
+		sum := sha256.Sum256([]byte(signed))
+		signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed + "." + b64(signature)
+	}
+	exp := time.Now().Add(time.Hour).Unix()
+
+	verifier := newOIDCVerifier(OIDCConfig{Issuer: provider.URL, Audience: "mcp"})
+	handler := verifier.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, _ := PrincipalFromContext(r.Context())
+		w.Write([]byte(principal.Name))
+	}))
+	for name, tt := range map[string]struct {
+		token string
+		want  int
+	}{
+		"valid":          {sign(map[string]any{"iss": provider.URL, "aud": "mcp", "sub": "alice", "exp": exp}), http.StatusOK},
+		"other audience": {sign(map[string]any{"iss": provider.URL, "aud": "other", "sub": "alice", "exp": exp}), http.StatusUnauthorized},
+		"other issuer":   {sign(map[string]any{"iss": "https://other", "aud": "mcp", "sub": "alice", "exp": exp}), http.StatusUnauthorized},
+		"expired":        {sign(map[string]any{"iss": provider.URL, "aud": "mcp", "sub": "alice", "exp": time.Now().Add(-time.Hour).Unix()}), http.StatusUnauthorized},
+		"no principal":   {sign(map[string]any{"iss": provider.URL, "aud": "mcp", "exp": exp}), http.StatusUnauthorized},
+		"not a JWT":      {"opaque", http.StatusUnauthorized},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+		req.Header.Set("Authorization", "Bearer "+tt.token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d: %s", name, rec.Code, tt.want, rec.Body)
+		}
+		if tt.want == http.StatusOK && rec.Body.String() != "alice" {
+			t.Errorf("%s: principal %q, want alice", name, rec.Body)
+		}
+	}
+}
+
+// TestOIDCDiscoveryThrottled fetches the discovery document of an
+// unreachable issuer once per jwtauth.MinRefreshInterval, not per request.
+func TestOIDCDiscoveryThrottled(t *testing.T) {
+	var fetches atomic.Int32
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer provider.Close()
+
+	now := time.Unix(1700000000, 0)
+	verifier := newOIDCVerifier(OIDCConfig{Issuer: provider.URL})
+	verifier.now = func() time.Time { return now }
+	for i := 0; i < 3; i++ {
+		if _, err := verifier.verify(context.Background(), "token"); err == nil {
+			t.Fatal("verified a token without a provider")
+		}
+	}
+	if got := fetches.Load(); got != 1 {
+		t.Errorf("%d fetches, want 1", got)
+	}
+	now = now.Add(jwtauth.MinRefreshInterval)
+	verifier.verify(context.Background(), "token")
+	if got := fetches.Load(); got != 2 {
+		t.Errorf("%d fetches after the interval, want 2", got)
+	}
+}
@@ -0,0 +1,303 @@
+package demoserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	// DefaultOutboxRetention is how long undelivered notifications are kept.
+	DefaultOutboxRetention = 24 * time.Hour
+	// DefaultOutboxLimit is how many undelivered notifications are kept per
+	// recipient.
+	DefaultOutboxLimit = 100
+)
+
+// OutboxConfig keeps the notifications that must not be lost, i.e. approval
+// requests, until a session of their recipient received them. A recipient
+// without a connected session gets them on its next request.
+type OutboxConfig struct {
+	// Path is the JSON file the undelivered notifications are persisted in,
+	// so they survive restarts. Empty keeps them in memory.
+	Path string
+	// Retention drops undelivered notifications after this long,
+	// DefaultOutboxRetention by default.
+	Retention time.Duration
+	// Limit caps the undelivered notifications per recipient, dropping the
+	// oldest, DefaultOutboxLimit by default.
+	Limit int
+}
+
+// WithOutbox delivers the approval requests and the notifications of Notify
+// through an outbox.
+func WithOutbox(config OutboxConfig) Option {
+	return func(s *Server) {
+		if config.Retention <= 0 {
+			config.Retention = DefaultOutboxRetention
+		}
+		if config.Limit <= 0 {
+			config.Limit = DefaultOutboxLimit
+		}
+		s.outbox = &outbox{config: config}
+	}
+}
+
+// OutboxMessage is a notification in the outbox.
+type OutboxMessage struct {
+	ID string `json:"id"`
+	// Recipient is the principal the notification is for, empty for the
+	// sessions without a principal, i.e. on stdio.
+	Recipient string         `json:"recipient"`
+	Method    string         `json:"method"`
+	Params    map[string]any `json:"params,omitempty"`
+	Created   time.Time      `json:"created"`
+	// Expires drops the notification once it is of no use, i.e. when the
+	// approval it requests expired.
+	Expires time.Time `json:"expires,omitzero"`
+}
+
+type outbox struct {
+	config OutboxConfig
+
+	mu       sync.Mutex
+	messages []OutboxMessage
+	// sessions are the recipients of the sessions seen since they
+	// registered.
+	sessions map[string]string
+}
+
+func (o *outbox) load() error {
+	if o.config.Path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(o.config.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read outbox: %w", err)
+	}
+	var messages []OutboxMessage
+	if err := json.Unmarshal(data, &messages); err != nil {
+		return fmt.Errorf("invalid outbox file: %w", err)
+	}
+	o.mu.Lock()
+	o.messages = messages
+	o.mu.Unlock()
+	return nil
+}
+
+// save persists the messages, replacing the file at once so a crash leaves
+// the last complete outbox. The caller holds mu.
+func (o *outbox) save() error {
+	if o.config.Path == "" {
+		return nil
+	}
+	data, err := json.Marshal(o.messages)
+	if err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(filepath.Dir(o.config.Path), ".outbox-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), o.config.Path)
+}
+
+// prune drops the expired messages and the oldest beyond the limit of each
+// recipient. The caller holds mu.
+func (o *outbox) prune(now time.Time) {
+	count := make(map[string]int)
+	kept := make([]OutboxMessage, 0, len(o.messages))
+	for i := len(o.messages) - 1; i >= 0; i-- {
+		message := o.messages[i]
+		if now.Sub(message.Created) > o.config.Retention || !message.Expires.IsZero() && now.After(message.Expires) {
+			continue
+		}
+		if count[message.Recipient]++; count[message.Recipient] > o.config.Limit {
+			log.Printf("Outbox dropped %s to %q, over the limit of %d", message.Method, message.Recipient, o.config.Limit)
+			continue
+		}
+		kept = append(kept, message)
+	}
+	// kept is newest first
+	for i, j := 0, len(kept)-1; i < j; i, j = i+1, j-1 {
+		kept[i], kept[j] = kept[j], kept[i]
+	}
+	o.messages = kept
+}
+
+func (o *outbox) list() []OutboxMessage {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]OutboxMessage{}, o.messages...)
+}
+
+// Notify persists a notification to the principal recipient in the outbox
+// and delivers it to the connected sessions of the recipient. It stays in the
+// outbox until a session received it, the sessions seen later get it at
+// their first request. Without an outbox it is sent to the
+// session of ctx only.
+func (s *Server) Notify(ctx context.Context, message OutboxMessage) error {
+	if s.outbox == nil {
+		if mcpServer := server.ServerFromContext(ctx); mcpServer != nil {
+			return mcpServer.SendNotificationToClient(ctx, message.Method, message.Params)
+		}
+		return nil
+	}
+	if message.ID == "" {
+		message.ID = newCorrelationID()
+	}
+	if message.Created.IsZero() {
+		message.Created = s.clock()
+	}
+
+	s.outbox.mu.Lock()
+	s.outbox.messages = append(s.outbox.messages, message)
+	s.outbox.prune(s.clock())
+	err := s.outbox.save()
+	var sessions []string
+	for sessionID, recipient := range s.outbox.sessions {
+		if recipient == message.Recipient {
+			sessions = append(sessions, sessionID)
+		}
+	}
+	s.outbox.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to persist notification: %w", err)
+	}
+	delivered := false
+	for _, sessionID := range sessions {
+		if err := s.mcpServer.SendNotificationToSpecificClient(sessionID, message.Method, message.Params); err == nil {
+			delivered = true
+		}
+	}
+	if delivered {
+		s.outbox.remove(message.ID)
+	}
+	return nil
+}
+
+// remove drops the message with id once delivered.
+func (o *outbox) remove(id string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	kept := o.messages[:0]
+	for _, message := range o.messages {
+		if message.ID != id {
+			kept = append(kept, message)
+		}
+	}
+	o.messages = kept
+	if err := o.save(); err != nil {
+		log.Printf("Failed to persist outbox: %v", err)
+	}
+}
+
+// deliverOutbox sends the messages of recipient to a session, removing those
+// it received.
+func (s *Server) deliverOutbox(sessionID, recipient string) {
+	s.outbox.mu.Lock()
+	defer s.outbox.mu.Unlock()
+	s.outbox.prune(s.clock())
+	kept := s.outbox.messages[:0]
+	delivered := 0
+	for _, message := range s.outbox.messages {
+		if message.Recipient == recipient {
+			if err := s.mcpServer.SendNotificationToSpecificClient(sessionID, message.Method, message.Params); err == nil {
+				delivered++
+				continue
+			}
+		}
+		kept = append(kept, message)
+	}
+	s.outbox.messages = kept
+	if delivered == 0 {
+		return
+	}
+	log.Printf("Outbox delivered %d notifications to session %s", delivered, sessionID)
+	if err := s.outbox.save(); err != nil {
+		log.Printf("Failed to persist outbox: %v", err)
+	}
+}
+
+// registerOutboxHooks delivers the pending notifications of the recipient at
+// the first request of a session after initialize, once it can receive them.
+func (s *Server) registerOutboxHooks(hooks *server.Hooks) {
+	if s.outbox == nil {
+		return
+	}
+	hooks.AddBeforeAny(func(ctx context.Context, id any, method mcp.MCPMethod, message any) {
+		sessionID := sessionIDFromContext(ctx)
+		if method == mcp.MethodInitialize || sessionID == "" {
+			return
+		}
+		principal, _ := PrincipalFromContext(ctx)
+		s.outbox.mu.Lock()
+		if s.outbox.sessions == nil {
+			s.outbox.sessions = make(map[string]string)
+		}
+		_, seen := s.outbox.sessions[sessionID]
+		s.outbox.sessions[sessionID] = principal.Name
+		s.outbox.mu.Unlock()
+		if !seen {
+			s.deliverOutbox(sessionID, principal.Name)
+		}
+	})
+	hooks.AddOnUnregisterSession(func(ctx context.Context, session server.ClientSession) {
+		s.outbox.mu.Lock()
+		delete(s.outbox.sessions, session.SessionID())
+		s.outbox.mu.Unlock()
+	})
+}
+
+// handleAdminOutbox lists the undelivered notifications on GET and adds one
+// on POST, i.e. a budget alert of another system, with a body of
+// {"recipient": "...", "method": "...", "params": {...}}.
+func (s *Server) handleAdminOutbox(w http.ResponseWriter, r *http.Request) {
+	if s.outbox == nil {
+		http.Error(w, "The outbox is not enabled", http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, s.outbox.list())
+	case http.MethodPost:
+		var message OutboxMessage
+		if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+			http.Error(w, fmt.Sprintf("invalid notification: %v", err), http.StatusBadRequest)
+			return
+		}
+		if message.Method == "" {
+			http.Error(w, "invalid notification: method is required", http.StatusBadRequest)
+			return
+		}
+		message.ID, message.Created = newCorrelationID(), time.Time{}
+		if err := s.Notify(r.Context(), message); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusAccepted, map[string]any{"id": message.ID})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,218 @@
+package demoserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// outboxSession is a client session receiving up to capacity notifications,
+// the sends beyond fail as they do for a stalled client.
+type outboxSession struct {
+	id            string
+	notifications chan mcp.JSONRPCNotification
+}
+
+func newOutboxSession(id string, capacity int) *outboxSession {
+	return &outboxSession{id: id, notifications: make(chan mcp.JSONRPCNotification, capacity)}
+}
+
+func (s *outboxSession) Initialize()       {}
+func (s *outboxSession) Initialized() bool { return true }
+func (s *outboxSession) SessionID() string { return s.id }
+func (s *outboxSession) NotificationChannel() chan<- mcp.JSONRPCNotification {
+	return s.notifications
+}
+
+// connect registers session for recipient and sends its first request.
+func connect(t *testing.T, s *Server, session *outboxSession, recipient string) {
+	t.Helper()
+	ctx := withPrincipal(context.Background(), Principal{Name: recipient})
+	if err := s.mcpServer.RegisterSession(ctx, session); err != nil {
+		t.Fatal(err)
+	}
+	ping(s, session, recipient)
+}
+
+// ping sends a request of session, the first one after initialize delivers
+// the pending notifications.
+func ping(s *Server, session *outboxSession, recipient string) {
+	ctx := withPrincipal(context.Background(), Principal{Name: recipient})
+	s.mcpServer.HandleMessage(s.mcpServer.WithContext(ctx, session), json.RawMessage(`{"jsonrpc":"2.0","id":1,"method":"ping"}`))
+}
+
+func received(session *outboxSession) []string {
+	var methods []string
+	for {
+		select {
+		case notification := <-session.notifications:
+			methods = append(methods, notification.Method)
+		default:
+			return methods
+		}
+	}
+}
+
+func readOutbox(t *testing.T, path string) []OutboxMessage {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var messages []OutboxMessage
+	if err := json.Unmarshal(data, &messages); err != nil {
+		t.Fatalf("invalid outbox file: %v", err)
+	}
+	return messages
+}
+
+// TestOutboxReplay checks that the undelivered notifications survive a
+// restart, a crash in the middle of saving included, and are delivered to
+// the first session of their recipient.
+func TestOutboxReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.json")
+	s, err := New(WithTransport(TransportHTTP), WithOutbox(OutboxConfig{Path: path}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, recipient := range []string{"alice", "bob", "alice"} {
+		if err := s.Notify(context.Background(), OutboxMessage{Recipient: recipient, Method: "notifications/" + recipient}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if messages := readOutbox(t, path); len(messages) != 3 {
+		t.Fatalf("outbox file has %d notifications, want 3", len(messages))
+	}
+
+	// a crash while saving leaves a temporary file next to the outbox
+	if err := os.WriteFile(filepath.Join(filepath.Dir(path), ".outbox-123"), []byte(`[{"id":"tor`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	restarted, err := New(WithTransport(TransportHTTP), WithOutbox(OutboxConfig{Path: path}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if messages := restarted.outbox.list(); len(messages) != 3 {
+		t.Fatalf("restarted outbox has %d notifications, want 3", len(messages))
+	}
+
+	alice := newOutboxSession("alice-1", 10)
+	connect(t, restarted, alice, "alice")
+	if methods := received(alice); len(methods) != 2 || methods[0] != "notifications/alice" {
+		t.Errorf("alice received %v, want her 2 notifications", methods)
+	}
+	messages := readOutbox(t, path)
+	if len(messages) != 1 || messages[0].Recipient != "bob" {
+		t.Errorf("outbox file keeps %+v, want the notification of bob", messages)
+	}
+	// the following requests of the session deliver nothing twice
+	ping(restarted, alice, "alice")
+	if methods := received(alice); len(methods) != 0 {
+		t.Errorf("alice received %v again", methods)
+	}
+
+	if err := os.WriteFile(path, []byte(`[{"id":`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(WithTransport(TransportHTTP), WithOutbox(OutboxConfig{Path: path})); err == nil {
+		t.Error("New() succeeded with a corrupt outbox file")
+	}
+}
+
+// TestOutboxConcurrentNotify checks that no notification is lost when they
+// are added concurrently.
+func TestOutboxConcurrentNotify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.json")
+	s, err := New(WithTransport(TransportHTTP), WithOutbox(OutboxConfig{Path: path, Limit: 1000}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.Notify(context.Background(), OutboxMessage{Recipient: "alice", Method: fmt.Sprintf("notifications/%d", i)}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	ids := make(map[string]bool)
+	for _, message := range readOutbox(t, path) {
+		ids[message.ID] = true
+	}
+	if len(ids) != 50 || len(s.outbox.list()) != 50 {
+		t.Errorf("outbox has %d notifications, %d distinct in its file, want 50", len(s.outbox.list()), len(ids))
+	}
+}
+
+// TestOutboxDeliveryRetry checks that a notification a session failed to
+// receive stays in the outbox for the next session of the recipient.
+func TestOutboxDeliveryRetry(t *testing.T) {
+	s, err := New(WithTransport(TransportHTTP), WithOutbox(OutboxConfig{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stalled := newOutboxSession("stalled", 0)
+	connect(t, s, stalled, "alice")
+	if err := s.Notify(context.Background(), OutboxMessage{Recipient: "alice", Method: "notifications/approval"}); err != nil {
+		t.Fatal(err)
+	}
+	if messages := s.outbox.list(); len(messages) != 1 {
+		t.Fatalf("outbox has %d notifications after a failed delivery, want 1", len(messages))
+	}
+
+	next := newOutboxSession("next", 10)
+	connect(t, s, next, "alice")
+	if methods := received(next); len(methods) != 1 || methods[0] != "notifications/approval" {
+		t.Errorf("next session received %v, want the approval", methods)
+	}
+	if messages := s.outbox.list(); len(messages) != 0 {
+		t.Errorf("outbox keeps %+v after the delivery", messages)
+	}
+
+	// connected sessions get the notifications right away
+	if err := s.Notify(context.Background(), OutboxMessage{Recipient: "alice", Method: "notifications/alert"}); err != nil {
+		t.Fatal(err)
+	}
+	if methods := received(next); len(methods) != 1 || len(s.outbox.list()) != 0 {
+		t.Errorf("connected session received %v, outbox keeps %d", methods, len(s.outbox.list()))
+	}
+}
+
+func TestOutboxPrune(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	s, err := New(WithTransport(TransportHTTP), WithOutbox(OutboxConfig{Retention: time.Hour, Limit: 2}),
+		WithClock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, message := range []OutboxMessage{
+		{Recipient: "alice", Method: "old", Created: now.Add(-2 * time.Hour)},
+		{Recipient: "alice", Method: "expired", Expires: now.Add(-time.Minute)},
+		{Recipient: "alice", Method: "first"},
+		{Recipient: "alice", Method: "second"},
+		{Recipient: "alice", Method: "third"},
+		{Recipient: "bob", Method: "other"},
+	} {
+		if err := s.Notify(context.Background(), message); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var methods []string
+	for _, message := range s.outbox.list() {
+		methods = append(methods, message.Method)
+	}
+	if fmt.Sprint(methods) != "[second third other]" {
+		t.Errorf("outbox keeps %v, want the 2 latest of alice and the one of bob", methods)
+	}
+}
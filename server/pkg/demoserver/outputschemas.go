@@ -0,0 +1,94 @@
+package demoserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/wagnerjt/go-mcp/server/pkg/mcpschema"
+)
+
+// WithOutputSchema declares the JSON schema of the results of tool, whose
+// text content is JSON. The schema is listed in the documentation of the
+// tool, and with WithSpecValidation the results are validated against it
+// and mismatches logged, to catch the drift between what the tool
+// promises and what it produces.
+func WithOutputSchema(tool string, schema json.RawMessage) Option {
+	return func(s *Server) {
+		if s.outputSchemas == nil {
+			s.outputSchemas = make(map[string]json.RawMessage)
+		}
+		s.outputSchemas[tool] = schema
+	}
+}
+
+// addOutputSchema declares the output schema of a built-in tool, unless
+// WithOutputSchema replaced it.
+func (s *Server) addOutputSchema(tool string, schema json.RawMessage) {
+	if _, ok := s.outputSchemas[tool]; ok {
+		return
+	}
+	if s.outputSchemas == nil {
+		s.outputSchemas = make(map[string]json.RawMessage)
+	}
+	s.outputSchemas[tool] = schema
+}
+
+// compileOutputSchemas compiles the declared output schemas, so invalid ones
+// fail New, and keeps them for validation with WithSpecValidation.
+func (s *Server) compileOutputSchemas() error {
+	for tool, schema := range s.outputSchemas {
+		definition, err := mcpschema.Compile(schema)
+		if err != nil {
+			return fmt.Errorf("output schema of %s: %w", tool, err)
+		}
+		if s.spec == nil {
+			continue
+		}
+		if s.outputValidators == nil {
+			s.outputValidators = make(map[string]*mcpschema.Definition)
+		}
+		s.outputValidators[tool] = definition
+	}
+	return nil
+}
+
+// validateOutput checks the JSON text of a successful result against the
+// output schema of its tool.
+func validateOutput(definition *mcpschema.Definition, result *mcp.CallToolResult) error {
+	for _, content := range result.Content {
+		text, ok := content.(mcp.TextContent)
+		if !ok {
+			continue
+		}
+		var value any
+		if err := json.Unmarshal([]byte(text.Text), &value); err != nil {
+			return fmt.Errorf("the result is not JSON: %w", err)
+		}
+		return definition.Validate(value)
+	}
+	return fmt.Errorf("the result has no text content")
+}
+
+// outputSchemaMiddleware validates the results as produced by the tools,
+// before the post-processors change their shape on purpose.
+func (s *Server) outputSchemaMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	if len(s.outputValidators) == 0 {
+		return next
+	}
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		result, err := next(ctx, request)
+		if err != nil || result == nil || result.IsError {
+			return result, err
+		}
+		if definition, ok := s.outputValidators[request.Params.Name]; ok {
+			if err := validateOutput(definition, result); err != nil {
+				log.Printf("Output schema violation in the %s result: %v", request.Params.Name, err)
+			}
+		}
+		return result, nil
+	}
+}
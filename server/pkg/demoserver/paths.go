@@ -0,0 +1,52 @@
+package demoserver
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// confinePath resolves the slash separated path relative to root, failing
+// with error.path_outside_root when it would leave root: absolute paths,
+// .. elements and symlinks pointing outside. The path may not exist, i.e. a
+// file deleted in the history of a repository. It returns the cleaned
+// relative path, "." for root itself.
+func confinePath(ctx context.Context, root, path string) (string, error) {
+	rel := filepath.Clean(filepath.FromSlash(strings.TrimPrefix(path, "./")))
+	if rel == "." || path == "" {
+		return ".", nil
+	}
+	if !filepath.IsLocal(rel) {
+		return "", localizedError(ctx, "error.path_outside_root", path)
+	}
+
+	resolvedRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+	// the deepest existing ancestor decides where the path leads
+	existing := filepath.Join(resolvedRoot, rel)
+	for {
+		resolved, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			inside, err := filepath.Rel(resolvedRoot, resolved)
+			if err != nil || !filepath.IsLocal(inside) {
+				return "", localizedError(ctx, "error.path_outside_root", path)
+			}
+			break
+		}
+		// a missing file, or a file used as directory
+		if existing == resolvedRoot {
+			return "", err
+		}
+		existing = filepath.Dir(existing)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// isDir reports whether path is an existing directory.
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,56 @@
+package demoserver
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfinePath(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "dir"), 0o755)
+	os.WriteFile(filepath.Join(root, "dir", "file"), nil, 0o644)
+	os.WriteFile(filepath.Join(outside, "secret"), nil, 0o644)
+	if err := os.Symlink(outside, filepath.Join(root, "out")); err != nil {
+		t.Skip(err)
+	}
+	os.Symlink(filepath.Join(root, "dir"), filepath.Join(root, "in"))
+	os.Symlink(filepath.Join(outside, "secret"), filepath.Join(root, "secret"))
+
+	for _, test := range []struct {
+		path string
+		want string // empty when the path is rejected
+	}{
+		{"", "."},
+		{".", "."},
+		{"dir/file", "dir/file"},
+		{"./dir/file", "dir/file"},
+		{"dir/../dir/file", "dir/file"},
+		{"in/file", "in/file"},
+		// deleted files, only in the history
+		{"deleted", "deleted"},
+		{"dir/deleted/file", "dir/deleted/file"},
+		{"..", ""},
+		{"../secret", ""},
+		{"dir/../../secret", ""},
+		{"/etc/passwd", ""},
+		{filepath.Join(outside, "secret"), ""},
+		{"out", ""},
+		{"out/secret", ""},
+		{"out/deleted", ""},
+		{"secret", ""},
+	} {
+		got, err := confinePath(context.Background(), root, test.path)
+		if test.want == "" {
+			if err == nil {
+				t.Errorf("confinePath(%q) = %q, want an error", test.path, got)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("confinePath(%q) = %q, %v, want %q", test.path, got, err, test.want)
+		}
+	}
+}
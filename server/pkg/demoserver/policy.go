@@ -0,0 +1,158 @@
+package demoserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// PolicyConfig authorizes every tools/call with an Open Policy Agent, whose
+// Rego policies OPA loads and hot-reloads itself, i.e. with
+// opa run --server --watch policy.rego.
+type PolicyConfig struct {
+	// URL is the OPA data API of the decision, i.e.
+	// http://localhost:8181/v1/data/mcp/authz.
+	URL string
+	// HTTPClient queries OPA, a client with a 5s timeout by default.
+	HTTPClient *http.Client
+}
+
+// WithPolicy asks the OPA of config for a decision on every tools/call. The
+// input holds the principal, the tool, its arguments and the time. The
+// decision is either a boolean or an object with allow, an optional reason
+// and optional arguments replacing the ones of the call. Calls are denied
+// when OPA fails or the decision is undefined.
+func WithPolicy(config PolicyConfig) Option {
+	return func(s *Server) {
+		if config.HTTPClient == nil {
+			config.HTTPClient = &http.Client{Timeout: 5 * time.Second, Transport: tracePropagation(nil)}
+		}
+		s.policy = &config
+		s.readiness.checks = append(s.readiness.checks, namedCheck{"policy " + config.URL, config.health})
+	}
+}
+
+// PolicyInput is the input document of the policy.
+type PolicyInput struct {
+	Principal string         `json:"principal"`
+	Claims    map[string]any `json:"claims,omitempty"`
+	Guest     bool           `json:"guest"`
+	SessionID string         `json:"sessionId,omitempty"`
+	Tool      string         `json:"tool"`
+	Arguments map[string]any `json:"arguments"`
+	Time      time.Time      `json:"time"`
+}
+
+// PolicyDecision is the decision of the policy, see WithPolicy.
+type PolicyDecision struct {
+	Allow     bool           `json:"allow"`
+	Reason    string         `json:"reason,omitempty"`
+	Arguments map[string]any `json:"arguments,omitempty"`
+}
+
+// health checks the OPA health API on the origin of the decision URL.
+func (c *PolicyConfig) health(ctx context.Context) error {
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return fmt.Errorf("invalid policy URL: %w", err)
+	}
+	health := (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/health"}).String()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, health, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach OPA: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("OPA health: status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// decide queries the decision for input.
+func (c *PolicyConfig) decide(ctx context.Context, input PolicyInput) (PolicyDecision, error) {
+	body, err := json.Marshal(map[string]any{"input": input})
+	if err != nil {
+		return PolicyDecision{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader(body))
+	if err != nil {
+		return PolicyDecision{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return PolicyDecision{}, fmt.Errorf("failed to query the policy: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+		return PolicyDecision{}, fmt.Errorf("failed to query the policy: status code %d", resp.StatusCode)
+	}
+	var response struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&response); err != nil {
+		return PolicyDecision{}, fmt.Errorf("failed to decode the policy decision: %w", err)
+	}
+
+	var decision PolicyDecision
+	switch {
+	case len(response.Result) == 0:
+		// OPA leaves the result out when no rule matched
+		decision.Reason = "undefined decision"
+	case json.Unmarshal(response.Result, &decision.Allow) == nil:
+	default:
+		if err := json.Unmarshal(response.Result, &decision); err != nil {
+			return PolicyDecision{}, fmt.Errorf("invalid policy decision %s: %w", response.Result, err)
+		}
+	}
+	return decision, nil
+}
+
+// policyMiddleware runs the calls the policy allows, with the arguments it
+// modified.
+func (s *Server) policyMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	if s.policy == nil {
+		return next
+	}
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		input := PolicyInput{
+			Guest:     isGuest(ctx),
+			SessionID: sessionIDFromContext(ctx),
+			Tool:      request.Params.Name,
+			Arguments: request.GetArguments(),
+			Time:      s.clock().UTC(),
+		}
+		if principal, ok := PrincipalFromContext(ctx); ok {
+			input.Principal, input.Claims = principal.Name, principal.Claims
+		} else if auth, err := tokenFromContext(ctx); err == nil && auth != "" {
+			input.Principal = tokenFingerprint(auth)
+		}
+
+		decision, err := s.policy.decide(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		if !decision.Allow {
+			log.Printf("Policy denied tool %s for %q: %s", input.Tool, input.Principal, decision.Reason)
+			return nil, localizedError(ctx, "error.policy_denied", input.Tool, decision.Reason)
+		}
+		if decision.Arguments != nil {
+			request.Params.Arguments = decision.Arguments
+		}
+		return next(ctx, request)
+	}
+}
@@ -0,0 +1,135 @@
+package demoserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// recordTool echoes the arguments it was called with.
+var recordTool = server.ServerTool{
+	Tool: mcp.NewTool("record", mcp.WithString("path")),
+	Handler: func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		data, _ := json.Marshal(request.GetArguments())
+		return mcp.NewToolResultText(string(data)), nil
+	},
+}
+
+func TestPolicy(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name string
+		// response is the body of the fake OPA, status its status code
+		response string
+		status   int
+		want     string
+		wantErr  string
+		// failed policies deny the call with an internal error, whose
+		// cause only decide reports
+		failed bool
+	}{
+		{name: "allowed", response: `{"result":true}`, want: `{"path":"/tmp/a"}`},
+		{name: "denied", response: `{"result":false}`, wantErr: "denied by policy"},
+		{name: "allowed object", response: `{"result":{"allow":true}}`, want: `{"path":"/tmp/a"}`},
+		{name: "denied with reason", response: `{"result":{"allow":false,"reason":"outside /srv"}}`, wantErr: "outside /srv"},
+		{name: "rewritten arguments", response: `{"result":{"allow":true,"arguments":{"path":"/srv/a"}}}`, want: `{"path":"/srv/a"}`},
+		{name: "undefined decision", response: `{}`, wantErr: "undefined decision"},
+		{name: "invalid decision", response: `{"result":"yes"}`, wantErr: "invalid policy decision", failed: true},
+		{name: "malformed response", response: `not json`, wantErr: "failed to decode the policy decision", failed: true},
+		{name: "policy error", response: `{"code":"internal_error"}`, status: http.StatusInternalServerError, wantErr: "status code 500", failed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var input PolicyInput
+			opa := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var body struct {
+					Input PolicyInput `json:"input"`
+				}
+				if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+					t.Errorf("invalid policy query: %v", err)
+				}
+				input = body.Input
+				if tt.status != 0 {
+					w.WriteHeader(tt.status)
+				}
+				w.Write([]byte(tt.response))
+			}))
+			defer opa.Close()
+
+			s, err := New(WithTransport(TransportHTTP), WithPolicy(PolicyConfig{URL: opa.URL + "/v1/data/mcp/authz"}),
+				WithExtraTools(recordTool), WithClock(func() time.Time { return now }))
+			if err != nil {
+				t.Fatal(err)
+			}
+			response := callTool(t, s, "record", map[string]any{"path": "/tmp/a"})
+			if input.Tool != "record" || input.Arguments["path"] != "/tmp/a" || !input.Time.Equal(now) {
+				t.Errorf("policy input %+v, want the tool, its arguments and the time", input)
+			}
+			if tt.failed {
+				if message := errorMessage(response); !strings.Contains(message, "internal error") {
+					t.Errorf("call returned %v, want an internal error", response)
+				}
+				if _, err := s.policy.decide(context.Background(), input); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("decide() = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if tt.wantErr != "" {
+				if message := errorMessage(response); !strings.Contains(message, tt.wantErr) {
+					t.Errorf("call returned %v, want an error containing %q", response, tt.wantErr)
+				}
+				return
+			}
+			if text, ok := resultText(response); !ok || text != tt.want {
+				t.Errorf("call returned %v, want %s", response, tt.want)
+			}
+		})
+	}
+}
+
+// TestPolicyUnreachable checks that the calls are denied, and the server
+// not ready, while OPA cannot be reached.
+func TestPolicyUnreachable(t *testing.T) {
+	opa := httptest.NewServer(http.NotFoundHandler())
+	url := opa.URL + "/v1/data/mcp/authz"
+	opa.Close()
+
+	config := PolicyConfig{URL: url, HTTPClient: &http.Client{Timeout: time.Second}}
+	s, err := New(WithTransport(TransportHTTP), WithPolicy(config), WithExtraTools(recordTool))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message := errorMessage(callTool(t, s, "record", nil)); !strings.Contains(message, "internal error") {
+		t.Errorf("call returned %q, want an internal error", message)
+	}
+	if _, err := s.policy.decide(context.Background(), PolicyInput{Tool: "record"}); err == nil || !strings.Contains(err.Error(), "failed to query the policy") {
+		t.Errorf("decide() = %v, want the policy failure", err)
+	}
+	if err := s.policy.health(context.Background()); err == nil || !strings.Contains(err.Error(), "failed to reach OPA") {
+		t.Errorf("health() = %v, want OPA unreachable", err)
+	}
+}
+
+func TestPolicyHealth(t *testing.T) {
+	opa := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer opa.Close()
+	config := &PolicyConfig{URL: opa.URL + "/v1/data/mcp/authz", HTTPClient: opa.Client()}
+	if err := config.health(context.Background()); err != nil {
+		t.Errorf("health() = %v, want OPA healthy", err)
+	}
+	config.URL = "://invalid"
+	if err := config.health(context.Background()); err == nil {
+		t.Error("health() succeeded with an invalid URL")
+	}
+}
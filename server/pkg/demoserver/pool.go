@@ -0,0 +1,28 @@
+package demoserver
+
+import (
+	"bytes"
+	"sync"
+)
+
+// maxPooledBuffer keeps the occasional huge response from pinning its buffer
+// in the pool.
+const maxPooledBuffer = 1 << 20
+
+// bufferPool recycles the buffers of the response path, i.e. rewritten error
+// responses and logged bodies, which would otherwise allocate per message.
+var bufferPool = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+func getBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+func putBuffer(b *bytes.Buffer) {
+	if b.Cap() > maxPooledBuffer {
+		return
+	}
+	b.Reset()
+	bufferPool.Put(b)
+}
@@ -0,0 +1,301 @@
+package demoserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// PostProcessor transforms the result of a tool before it is returned, i.e.
+// to cut the noise of verbose upstream APIs. Processors must not modify the
+// result they get, but return a changed copy.
+type PostProcessor interface {
+	Process(ctx context.Context, result *mcp.CallToolResult) (*mcp.CallToolResult, error)
+}
+
+// PostProcessorFunc adapts a function to PostProcessor.
+type PostProcessorFunc func(ctx context.Context, result *mcp.CallToolResult) (*mcp.CallToolResult, error)
+
+// Process implements PostProcessor.
+func (f PostProcessorFunc) Process(ctx context.Context, result *mcp.CallToolResult) (*mcp.CallToolResult, error) {
+	return f(ctx, result)
+}
+
+// mapText returns a copy of result with f applied to its text contents.
+func mapText(result *mcp.CallToolResult, f func(text string) (string, error)) (*mcp.CallToolResult, error) {
+	processed := *result
+	processed.Content = make([]mcp.Content, len(result.Content))
+	for i, content := range result.Content {
+		text, ok := content.(mcp.TextContent)
+		if !ok {
+			processed.Content[i] = content
+			continue
+		}
+		changed, err := f(text.Text)
+		if err != nil {
+			return nil, err
+		}
+		text.Text = changed
+		processed.Content[i] = text
+	}
+	return &processed, nil
+}
+
+// StripPattern removes the matches of pattern from the text contents.
+func StripPattern(pattern *regexp.Regexp) PostProcessor {
+	return PostProcessorFunc(func(ctx context.Context, result *mcp.CallToolResult) (*mcp.CallToolResult, error) {
+		return mapText(result, func(text string) (string, error) {
+			return pattern.ReplaceAllString(text, ""), nil
+		})
+	})
+}
+
+// TrimText cuts text contents longer than max bytes, noting how much was cut.
+func TrimText(max int) PostProcessor {
+	return PostProcessorFunc(func(ctx context.Context, result *mcp.CallToolResult) (*mcp.CallToolResult, error) {
+		return mapText(result, func(text string) (string, error) {
+			if len(text) <= max {
+				return text, nil
+			}
+			cut := max
+			for cut > 0 && !utf8.RuneStart(text[cut]) {
+				cut--
+			}
+			return fmt.Sprintf("%s\n[%d bytes trimmed]", text[:cut], len(text)-cut), nil
+		})
+	})
+}
+
+// JSONProjection keeps the named fields of JSON text contents, selected
+// by QueryJSON paths. Queries iterating with [] select arrays, the others a
+// single value, left out when missing. Text that is not JSON is left as is.
+type JSONProjection struct {
+	// Each selects the values to project, i.e. .items[], the whole
+	// document when empty.
+	Each string `json:"each,omitempty"`
+	// Fields map the names of the projected fields to their queries.
+	Fields map[string]string `json:"fields"`
+}
+
+func (p JSONProjection) validate() error {
+	if len(p.Fields) == 0 {
+		return fmt.Errorf("projection without fields")
+	}
+	if _, err := QueryJSON(nil, p.Each); err != nil {
+		return fmt.Errorf("each %q: %w", p.Each, err)
+	}
+	for name, query := range p.Fields {
+		if _, err := QueryJSON(nil, query); err != nil {
+			return fmt.Errorf("field %s %q: %w", name, query, err)
+		}
+	}
+	return nil
+}
+
+// Process implements PostProcessor.
+func (p JSONProjection) Process(ctx context.Context, result *mcp.CallToolResult) (*mcp.CallToolResult, error) {
+	return mapText(result, func(text string) (string, error) {
+		var document any
+		if json.Unmarshal([]byte(text), &document) != nil {
+			return text, nil
+		}
+		var projected any
+		if p.Each == "" {
+			projected = p.project(document)
+		} else {
+			values, err := QueryJSON(document, p.Each)
+			if err != nil {
+				return "", err
+			}
+			items := make([]any, len(values))
+			for i, value := range values {
+				items[i] = p.project(value)
+			}
+			projected = items
+		}
+		body, err := json.Marshal(projected)
+		return string(body), err
+	})
+}
+
+func (p JSONProjection) project(value any) map[string]any {
+	projected := make(map[string]any, len(p.Fields))
+	for name, query := range p.Fields {
+		// validated, the queries do not fail
+		selected, _ := QueryJSON(value, query)
+		switch {
+		case strings.Contains(query, "[]"):
+			projected[name] = selected
+		case len(selected) > 0:
+			projected[name] = selected[0]
+		}
+	}
+	return projected
+}
+
+// Summarizer condenses a text, i.e. with an LLM.
+type Summarizer interface {
+	Summarize(ctx context.Context, text string) (string, error)
+}
+
+// Summarize replaces text contents longer than minSize bytes with their
+// summary.
+func Summarize(summarizer Summarizer, minSize int) PostProcessor {
+	return PostProcessorFunc(func(ctx context.Context, result *mcp.CallToolResult) (*mcp.CallToolResult, error) {
+		return mapText(result, func(text string) (string, error) {
+			if len(text) <= minSize {
+				return text, nil
+			}
+			return summarizer.Summarize(ctx, text)
+		})
+	})
+}
+
+// WithPostProcessors applies processors in order to the successful results
+// of tool, after the processors of WithPostProcessorsFile.
+func WithPostProcessors(tool string, processors ...PostProcessor) Option {
+	return func(s *Server) {
+		if s.postProcessors.static == nil {
+			s.postProcessors.static = make(map[string][]PostProcessor)
+		}
+		s.postProcessors.static[tool] = append(s.postProcessors.static[tool], processors...)
+	}
+}
+
+// WithPostProcessorsFile loads post-processors per tool from the JSON file at
+// path, a list of steps per tool name:
+//
+//	{"search": [
+//	  {"strip": "https?://\\S+"},
+//	  {"project": {"each": ".items[]", "fields": {"name": ".name", "artists": ".artists[].name"}}},
+//	  {"trim": 4000}
+//	]}
+//
+// The file is read again on Reload.
+func WithPostProcessorsFile(path string) Option {
+	return func(s *Server) {
+		s.postProcessors.path = path
+	}
+}
+
+type postProcessors struct {
+	static map[string][]PostProcessor
+	path   string
+
+	mu       sync.RWMutex
+	fromFile map[string][]PostProcessor
+}
+
+// postProcessorStep is a step of the post-processors file, with exactly one
+// field set.
+type postProcessorStep struct {
+	Strip   string          `json:"strip,omitempty"`
+	Project *JSONProjection `json:"project,omitempty"`
+	Trim    int             `json:"trim,omitempty"`
+}
+
+func (p *postProcessors) load() error {
+	fromFile, err := p.read()
+	if err != nil {
+		return err
+	}
+	p.set(fromFile)
+	return nil
+}
+
+func (p *postProcessors) read() (map[string][]PostProcessor, error) {
+	if p.path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read post-processors file: %w", err)
+	}
+	var steps map[string][]postProcessorStep
+	if err := json.Unmarshal(data, &steps); err != nil {
+		return nil, fmt.Errorf("invalid post-processors file: %w", err)
+	}
+	fromFile := make(map[string][]PostProcessor, len(steps))
+	for tool, toolSteps := range steps {
+		for i, step := range toolSteps {
+			processor, err := step.processor()
+			if err != nil {
+				return nil, fmt.Errorf("invalid post-processor %d of %s: %w", i+1, tool, err)
+			}
+			fromFile[tool] = append(fromFile[tool], processor)
+		}
+	}
+	return fromFile, nil
+}
+
+func (p *postProcessors) set(fromFile map[string][]PostProcessor) {
+	p.mu.Lock()
+	p.fromFile = fromFile
+	p.mu.Unlock()
+}
+
+func (step postProcessorStep) processor() (PostProcessor, error) {
+	set := 0
+	for _, isSet := range []bool{step.Strip != "", step.Project != nil, step.Trim != 0} {
+		if isSet {
+			set++
+		}
+	}
+	if set != 1 {
+		return nil, fmt.Errorf("a step is one of strip, project and trim")
+	}
+	switch {
+	case step.Strip != "":
+		pattern, err := regexp.Compile(step.Strip)
+		if err != nil {
+			return nil, err
+		}
+		return StripPattern(pattern), nil
+	case step.Project != nil:
+		if err := step.Project.validate(); err != nil {
+			return nil, err
+		}
+		return *step.Project, nil
+	default:
+		if step.Trim < 0 {
+			return nil, fmt.Errorf("negative trim %d", step.Trim)
+		}
+		return TrimText(step.Trim), nil
+	}
+}
+
+func (p *postProcessors) forTool(tool string) []PostProcessor {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return append(append([]PostProcessor(nil), p.fromFile[tool]...), p.static[tool]...)
+}
+
+// postProcessMiddleware runs the post-processors of the tool on its
+// successful results. A failing processor is logged and the result returned
+// as it was before that processor.
+func (s *Server) postProcessMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		result, err := next(ctx, request)
+		if err != nil || result == nil || result.IsError {
+			return result, err
+		}
+		for _, processor := range s.postProcessors.forTool(request.Params.Name) {
+			processed, err := processor.Process(ctx, result)
+			if err != nil {
+				log.Printf("Post-processor of %s failed: %v", request.Params.Name, err)
+				break
+			}
+			result = processed
+		}
+		return result, nil
+	}
+}
@@ -0,0 +1,324 @@
+package demoserver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// DefaultPromptsPollInterval is how often the prompts directory is checked
+// for changes.
+const DefaultPromptsPollInterval = 2 * time.Second
+
+// PromptsConfig serves the Markdown files of a directory as prompts.
+//
+// A prompt file starts with front-matter naming and describing the prompt and
+// its arguments, followed by the prompt text, a text/template the arguments
+// are filled into:
+//
+//	---
+//	name: review_change
+//	description: Review a change for bugs
+//	arguments:
+//	  - name: diff
+//	    description: The unified diff
+//	    required: true
+//	  - name: focus
+//	---
+//	Review this change{{if .focus}}, focusing on {{.focus}}{{end}}:
+//
+//	{{.diff}}
+//
+// The front-matter is the subset of YAML above: scalars, which may be quoted,
+// and the list of arguments. The name defaults to the file name without
+// .md.
+type PromptsConfig struct {
+	Dir string
+	// PollInterval defaults to DefaultPromptsPollInterval, negative only
+	// checks for changes on Reload.
+	PollInterval time.Duration
+}
+
+// WithPrompts registers the prompts of config.Dir, and picks up added,
+// changed and removed files while the server runs, announced with
+// notifications/prompts/list_changed.
+func WithPrompts(config PromptsConfig) Option {
+	return func(s *Server) {
+		if config.PollInterval == 0 {
+			config.PollInterval = DefaultPromptsPollInterval
+		}
+		s.prompts = &prompts{config: config, byPath: make(map[string]*promptFile)}
+	}
+}
+
+type promptArgument struct {
+	name        string
+	description string
+	required    bool
+}
+
+type promptFile struct {
+	name        string
+	description string
+	arguments   []promptArgument
+	template    *template.Template
+	modified    time.Time
+	size        int64
+}
+
+type prompts struct {
+	config PromptsConfig
+
+	mu     sync.Mutex
+	byPath map[string]*promptFile
+	byName map[string]*promptFile
+}
+
+// parsePrompt parses a prompt file of PromptsConfig, named name unless its
+// front-matter says otherwise.
+func parsePrompt(name string, data []byte) (*promptFile, error) {
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	prompt := &promptFile{name: name}
+	if rest, ok := strings.CutPrefix(text, "---\n"); ok {
+		frontMatter, body, ok := strings.Cut(rest, "\n---\n")
+		if !ok {
+			frontMatter, ok = strings.CutSuffix(rest, "\n---")
+			body = ""
+		}
+		if !ok {
+			return nil, fmt.Errorf("front-matter is not closed by ---")
+		}
+		if err := prompt.parseFrontMatter(frontMatter); err != nil {
+			return nil, err
+		}
+		text = body
+	}
+	if prompt.name == "" {
+		return nil, fmt.Errorf("the prompt has no name")
+	}
+	seen := make(map[string]bool)
+	for _, argument := range prompt.arguments {
+		if argument.name == "" {
+			return nil, fmt.Errorf("argument without name")
+		}
+		if seen[argument.name] {
+			return nil, fmt.Errorf("duplicate argument %q", argument.name)
+		}
+		seen[argument.name] = true
+	}
+	tmpl, err := template.New(prompt.name).Option("missingkey=zero").Parse(strings.TrimSpace(text))
+	if err != nil {
+		return nil, fmt.Errorf("invalid prompt template: %w", err)
+	}
+	prompt.template = tmpl
+	return prompt, nil
+}
+
+func (p *promptFile) parseFrontMatter(frontMatter string) error {
+	var argument *promptArgument
+	inArguments := false
+	for i, line := range strings.Split(frontMatter, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		indented := strings.TrimLeft(line, " \t") != line
+		if !indented {
+			inArguments, argument = false, nil
+		}
+		if inArguments {
+			if item, ok := strings.CutPrefix(trimmed, "- "); ok {
+				p.arguments = append(p.arguments, promptArgument{})
+				argument = &p.arguments[len(p.arguments)-1]
+				trimmed = strings.TrimSpace(item)
+			} else if argument == nil {
+				return fmt.Errorf("line %d: want a list item of arguments", i+1)
+			}
+		} else if indented {
+			return fmt.Errorf("line %d: unexpected indentation", i+1)
+		}
+
+		key, raw, ok := strings.Cut(trimmed, ":")
+		if !ok {
+			return fmt.Errorf("line %d: want key: value", i+1)
+		}
+		value, err := frontMatterScalar(raw)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", i+1, err)
+		}
+		switch key = strings.TrimSpace(key); {
+		case argument != nil && key == "name":
+			argument.name = value
+		case argument != nil && key == "description":
+			argument.description = value
+		case argument != nil && key == "required":
+			if argument.required, err = strconv.ParseBool(value); err != nil {
+				return fmt.Errorf("line %d: required must be true or false", i+1)
+			}
+		case argument != nil:
+			return fmt.Errorf("line %d: unknown argument key %q", i+1, key)
+		case key == "name":
+			p.name = value
+		case key == "description":
+			p.description = value
+		case key == "arguments" && value == "":
+			inArguments = true
+		default:
+			return fmt.Errorf("line %d: unknown key %q", i+1, key)
+		}
+	}
+	return nil
+}
+
+// frontMatterScalar returns the plain, single or double quoted value of a
+// front-matter line.
+func frontMatterScalar(raw string) (string, error) {
+	value := strings.TrimSpace(raw)
+	switch {
+	case strings.HasPrefix(value, `"`):
+		unquoted, err := strconv.Unquote(value)
+		if err != nil {
+			return "", fmt.Errorf("invalid double quoted value %s", value)
+		}
+		return unquoted, nil
+	case strings.HasPrefix(value, "'"):
+		if len(value) < 2 || !strings.HasSuffix(value, "'") {
+			return "", fmt.Errorf("invalid single quoted value %s", value)
+		}
+		return strings.ReplaceAll(value[1:len(value)-1], "''", "'"), nil
+	}
+	if before, _, ok := strings.Cut(value, " #"); ok {
+		value = strings.TrimSpace(before)
+	}
+	return value, nil
+}
+
+func (p *promptFile) prompt() mcp.Prompt {
+	options := []mcp.PromptOption{mcp.WithPromptDescription(p.description)}
+	for _, argument := range p.arguments {
+		argumentOptions := []mcp.ArgumentOption{mcp.ArgumentDescription(argument.description)}
+		if argument.required {
+			argumentOptions = append(argumentOptions, mcp.RequiredArgument())
+		}
+		options = append(options, mcp.WithArgument(argument.name, argumentOptions...))
+	}
+	return mcp.NewPrompt(p.name, options...)
+}
+
+// refreshPrompts parses the new and changed prompt files and registers them,
+// and unregisters the removed ones. Files failing to parse are logged and
+// left out, a prompt named like one of another file too.
+func (s *Server) refreshPrompts() error {
+	paths, err := filepath.Glob(filepath.Join(s.prompts.config.Dir, "*.md"))
+	if err != nil {
+		return fmt.Errorf("failed to scan prompts: %w", err)
+	}
+	if _, err := os.Stat(s.prompts.config.Dir); err != nil {
+		return fmt.Errorf("failed to scan prompts: %w", err)
+	}
+	s.prompts.mu.Lock()
+	defer s.prompts.mu.Unlock()
+
+	byPath := make(map[string]*promptFile)
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		if prompt, ok := s.prompts.byPath[path]; ok && prompt.modified.Equal(info.ModTime()) && prompt.size == info.Size() {
+			byPath[path] = prompt
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("Failed to read prompt %s: %v", path, err)
+			continue
+		}
+		prompt, err := parsePrompt(strings.TrimSuffix(filepath.Base(path), ".md"), data)
+		if err != nil {
+			log.Printf("Invalid prompt %s: %v", path, err)
+			continue
+		}
+		prompt.modified, prompt.size = info.ModTime(), info.Size()
+		byPath[path] = prompt
+	}
+
+	byName := make(map[string]*promptFile)
+	for _, path := range slices.Sorted(maps.Keys(byPath)) {
+		prompt := byPath[path]
+		if _, ok := byName[prompt.name]; ok {
+			log.Printf("Invalid prompt %s: the name %s is taken", path, prompt.name)
+			delete(byPath, path)
+			continue
+		}
+		byName[prompt.name] = prompt
+	}
+	var removed []string
+	for name := range s.prompts.byName {
+		if _, ok := byName[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+	if len(removed) > 0 {
+		s.mcpServer.DeletePrompts(removed...)
+	}
+	for _, name := range slices.Sorted(maps.Keys(byName)) {
+		if prompt := byName[name]; s.prompts.byName[name] != prompt {
+			s.mcpServer.AddPrompt(prompt.prompt(), s.getPrompt)
+		}
+	}
+	s.prompts.byPath, s.prompts.byName = byPath, byName
+	return nil
+}
+
+func (s *Server) getPrompt(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+	s.prompts.mu.Lock()
+	prompt, ok := s.prompts.byName[request.Params.Name]
+	s.prompts.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown prompt %s", request.Params.Name)
+	}
+	for _, argument := range prompt.arguments {
+		if argument.required && request.Params.Arguments[argument.name] == "" {
+			return nil, fmt.Errorf("missing required argument %s", argument.name)
+		}
+	}
+	var text bytes.Buffer
+	if err := prompt.template.Execute(&text, request.Params.Arguments); err != nil {
+		return nil, fmt.Errorf("failed to render prompt %s: %w", prompt.name, err)
+	}
+	return mcp.NewGetPromptResult(prompt.description, []mcp.PromptMessage{
+		mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent(text.String())),
+	}), nil
+}
+
+// watchPrompts refreshes the prompts every PollInterval.
+func (s *Server) watchPrompts(ctx context.Context) {
+	if s.prompts.config.PollInterval < 0 {
+		return
+	}
+	ticker := time.NewTicker(s.prompts.config.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := s.refreshPrompts(); err != nil {
+			log.Printf("Failed to refresh prompts: %v", err)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package demoserver
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/wagnerjt/go-mcp/shared/pkg/healthcheck"
+)
+
+// property checks that f holds for generated inputs. testing/quick stands in
+// for rapid or gopter to keep the module free of test dependencies, its
+// arguments are generated from their types or by a quick.Generator.
+func property(t *testing.T, f any) {
+	t.Helper()
+	if err := quick.Check(f, &quick.Config{MaxCount: 300}); err != nil {
+		t.Error(err)
+	}
+}
+
+// jsonValue is an arbitrary JSON value, nested up to jsonValueDepth levels.
+type jsonValue struct{ V any }
+
+const jsonValueDepth = 6
+
+func (jsonValue) Generate(r *rand.Rand, size int) reflect.Value {
+	return reflect.ValueOf(jsonValue{generateJSON(r, size, jsonValueDepth)})
+}
+
+func generateJSON(r *rand.Rand, size, depth int) any {
+	kinds := 6
+	if depth == 0 {
+		kinds = 4
+	}
+	switch r.Intn(kinds) {
+	case 0:
+		return nil
+	case 1:
+		return r.Intn(2) == 1
+	case 2:
+		return (r.Float64() - 0.5) * math.Pow(10, float64(r.Intn(600)-300))
+	case 3:
+		s, _ := quick.Value(reflect.TypeOf(""), r)
+		return s.String()
+	case 4:
+		values := make([]any, r.Intn(size/10+1))
+		for i := range values {
+			values[i] = generateJSON(r, size, depth-1)
+		}
+		return values
+	default:
+		n := r.Intn(size/10 + 1)
+		values := make(map[string]any, n)
+		for i := 0; i < n; i++ {
+			key, _ := quick.Value(reflect.TypeOf(""), r)
+			values[key.String()] = generateJSON(r, size, depth-1)
+		}
+		return values
+	}
+}
+
+// callTool calls the tool through the JSON-RPC layer, the way a client does.
+func callTool(t *testing.T, s *Server, tool string, arguments any) mcp.JSONRPCMessage {
+	t.Helper()
+	message, err := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "tools/call",
+		"params":  map[string]any{"name": tool, "arguments": arguments},
+	})
+	if err != nil {
+		t.Fatalf("encoding %s call: %v", tool, err)
+	}
+	return s.mcpServer.HandleMessage(context.Background(), message)
+}
+
+// resultText returns the text of a successful tool result, ok is false for
+// errors of either kind.
+func resultText(response mcp.JSONRPCMessage) (text string, ok bool) {
+	r, isResponse := response.(mcp.JSONRPCResponse)
+	if !isResponse {
+		return "", false
+	}
+	result, isResult := r.Result.(mcp.CallToolResult)
+	if !isResult || result.IsError || len(result.Content) != 1 {
+		return "", false
+	}
+	content, isText := result.Content[0].(mcp.TextContent)
+	return content.Text, isText
+}
+
+// Any pair of JSON numbers adds up exactly as float64 does, overflowing to
+// +Inf or -Inf rather than failing.
+func TestAddProperty(t *testing.T) {
+	s := newGoldenServer(t)
+	property(t, func(a, b float64) bool {
+		text, ok := resultText(callTool(t, s, "add", map[string]any{"a": a, "b": b}))
+		return ok && text == fmt.Sprintf("The sum of %f and %f is %f.", a, b, a+b)
+	})
+	property(t, func(a, b float64) bool {
+		ab, _ := resultText(callTool(t, s, "add", map[string]any{"a": a, "b": b}))
+		ba, _ := resultText(callTool(t, s, "add", map[string]any{"a": b, "b": a}))
+		return strings.TrimPrefix(ab, fmt.Sprintf("The sum of %f and %f", a, b)) ==
+			strings.TrimPrefix(ba, fmt.Sprintf("The sum of %f and %f", b, a))
+	})
+}
+
+// NaN and the infinities cannot be encoded in JSON, so clients cannot send
+// them. Numbers beyond float64 are rejected when the request is decoded,
+// sums beyond it overflow.
+func TestAddSpecialNumbers(t *testing.T) {
+	s := newGoldenServer(t)
+
+	response := s.mcpServer.HandleMessage(context.Background(), json.RawMessage(
+		`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"add","arguments":{"a":1e999,"b":1}}}`))
+	if _, ok := response.(mcp.JSONRPCError); !ok {
+		t.Errorf("add with 1e999 = %#v, want a JSON-RPC error", response)
+	}
+
+	text, ok := resultText(callTool(t, s, "add", map[string]any{"a": math.MaxFloat64, "b": math.MaxFloat64}))
+	if !ok || !strings.HasSuffix(text, "is +Inf.") {
+		t.Errorf("add overflowing = %q, want +Inf", text)
+	}
+
+	// the handler itself formats NaN rather than failing
+	request := mcp.CallToolRequest{}
+	request.Params.Arguments = map[string]any{"a": math.NaN(), "b": 1.0}
+	result, err := handleAddTool(context.Background(), request)
+	if err != nil || !strings.HasSuffix(result.Content[0].(mcp.TextContent).Text, "is NaN.") {
+		t.Errorf("add with NaN = %v, %v, want NaN", result, err)
+	}
+}
+
+// Anything but two numbers, numeric strings included, is rejected.
+func TestAddRejectsNonNumbers(t *testing.T) {
+	s := newGoldenServer(t)
+	property(t, func(a jsonValue, b float64) bool {
+		if _, isNumber := a.V.(float64); isNumber {
+			return true
+		}
+		_, ok := resultText(callTool(t, s, "add", map[string]any{"a": a.V, "b": b}))
+		return !ok
+	})
+}
+
+// echo@2 returns any non-empty message verbatim, unicode included.
+func TestEchoProperty(t *testing.T) {
+	s := newGoldenServer(t)
+	property(t, func(message string) bool {
+		text, ok := resultText(callTool(t, s, "echo@2", map[string]any{"message": message}))
+		if message == "" {
+			return !ok
+		}
+		return ok && text == message
+	})
+}
+
+// Invalid UTF-8 is replaced when the request is encoded, never echoed raw.
+func TestEchoInvalidUTF8(t *testing.T) {
+	s := newGoldenServer(t)
+	text, ok := resultText(callTool(t, s, "echo@2", map[string]any{"message": "a\xffb"}))
+	if !ok || text != "a�b" {
+		t.Errorf("echo of invalid UTF-8 = %q, want %q", text, "a�b")
+	}
+}
+
+// Messages that are not strings, however deeply nested, are tool errors.
+func TestEchoRejectsNonStrings(t *testing.T) {
+	s := newGoldenServer(t)
+	property(t, func(message jsonValue) bool {
+		if _, isString := message.V.(string); isString {
+			return true
+		}
+		_, ok := resultText(callTool(t, s, "echo", map[string]any{"message": message.V}))
+		return !ok
+	})
+}
+
+// Arguments nested beyond the limit of the JSON decoder fail to parse
+// instead of exhausting the stack.
+func TestDeeplyNestedArguments(t *testing.T) {
+	s := newGoldenServer(t)
+	nested := strings.Repeat("[", 20000) + strings.Repeat("]", 20000)
+	response := s.mcpServer.HandleMessage(context.Background(), json.RawMessage(
+		`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"echo","arguments":{"message":`+nested+`}}}`))
+	if _, ok := response.(mcp.JSONRPCError); !ok {
+		t.Errorf("deeply nested arguments = %#v, want a JSON-RPC error", response)
+	}
+}
+
+// A schema is valid exactly when every required property is declared,
+// whatever the property names.
+func TestValidateToolSchemaProperty(t *testing.T) {
+	property(t, func(names []string, required []bool, undeclared string) bool {
+		opts := []mcp.ToolOption{}
+		declared := map[string]bool{}
+		for i, name := range names {
+			propertyOpts := []mcp.PropertyOption{mcp.Description(name)}
+			if i < len(required) && required[i] {
+				propertyOpts = append(propertyOpts, mcp.Required())
+			}
+			opts = append(opts, mcp.WithString(name, propertyOpts...))
+			declared[name] = true
+		}
+		if healthcheck.ValidateToolSchema(mcp.NewTool("tool", opts...)) != nil {
+			return false
+		}
+
+		if declared[undeclared] {
+			return true
+		}
+		tool := mcp.NewTool("tool", opts...)
+		tool.InputSchema.Required = append(tool.InputSchema.Required, undeclared)
+		return healthcheck.ValidateToolSchema(tool) != nil
+	})
+}
+
+// Texts separated by a space count as the sum of their tokens, so estimates
+// of parts add up to the estimate of the whole.
+func TestHeuristicTokenizerProperty(t *testing.T) {
+	var tokenizer HeuristicTokenizer
+	property(t, func(a, b string) bool {
+		return tokenizer.CountTokens(a+" "+b) == tokenizer.CountTokens(a)+tokenizer.CountTokens(b)
+	})
+}
+
+// TestRangeContentProperty reads contents in ranges of chunk bytes, each
+// resuming at the end of the last, and expects them to add up to the whole.
+func TestRangeContentProperty(t *testing.T) {
+	property(t, func(text string, data []byte, chunk uint8) bool {
+		length := int(chunk%16) + 1
+		var resumed strings.Builder
+		for offset := 0; ; {
+			content, r := rangeContent(mcp.TextResourceContents{Text: text}, offset, length)
+			resumed.WriteString(content.(mcp.TextResourceContents).Text)
+			if r.Total != len(text) || r.Offset+r.Length == r.Total {
+				break
+			}
+			offset = r.Offset + r.Length
+		}
+		var blob []byte
+		for offset := 0; ; {
+			content, r := rangeContent(mcp.BlobResourceContents{Blob: base64.StdEncoding.EncodeToString(data)}, offset, length)
+			decoded, _ := base64.StdEncoding.DecodeString(content.(mcp.BlobResourceContents).Blob)
+			blob = append(blob, decoded...)
+			if r.Offset+r.Length >= r.Total {
+				break
+			}
+			offset = r.Offset + r.Length
+		}
+		return resumed.String() == text && string(blob) == string(data)
+	})
+}
@@ -0,0 +1,225 @@
+package demoserver
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultRegistryHeartbeat is how often the registration with the registry
+// is renewed.
+const DefaultRegistryHeartbeat = 30 * time.Second
+
+// RegistryConfig publishes the server to an MCP server registry, so
+// clients and gateways discover the instances that are up. The server is
+// registered with PUT URL/servers/ID when it starts serving, the
+// registration is renewed every heartbeat and expires when the registry
+// misses three of them, and it is removed with DELETE URL/servers/ID on
+// shutdown.
+type RegistryConfig struct {
+	// URL is the registry API, i.e. https://registry.example.com/v1.
+	URL string
+	// Token authenticates the server to the registry as a bearer token.
+	Token string
+	// Endpoint is the URL clients reach the MCP endpoint on, i.e.
+	// https://mcp.example.com/mcp.
+	Endpoint string
+	// ID identifies the instance in the registry, by default derived from
+	// the endpoint, so a restarted instance renews its registration.
+	ID string
+	// Heartbeat defaults to DefaultRegistryHeartbeat.
+	Heartbeat time.Duration
+	// HTTPClient defaults to a client with a 10s timeout.
+	HTTPClient *http.Client
+}
+
+// RegistryManifest is the document published to the registry.
+type RegistryManifest struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Version   string `json:"version"`
+	Endpoint  string `json:"endpoint"`
+	Transport string `json:"transport"`
+	// Capabilities are the MCP server capabilities, i.e. tools and prompts.
+	Capabilities []string        `json:"capabilities"`
+	Auth         AuthDescription `json:"auth"`
+	Tools        []string        `json:"tools"`
+	// TTL is how many seconds the registration lasts without a heartbeat.
+	TTL int `json:"ttl"`
+}
+
+// WithRegistry registers the server with the registry of config while it
+// serves, see RegistryConfig.
+func WithRegistry(config RegistryConfig) Option {
+	return func(s *Server) {
+		if config.Heartbeat <= 0 {
+			config.Heartbeat = DefaultRegistryHeartbeat
+		}
+		if config.HTTPClient == nil {
+			config.HTTPClient = &http.Client{Timeout: 10 * time.Second, Transport: tracePropagation(nil)}
+		}
+		if config.ID == "" {
+			sum := sha256.Sum256([]byte(config.Endpoint))
+			config.ID = strings.ReplaceAll(ServerName, "/", "-") + "-" + hex.EncodeToString(sum[:6])
+		}
+		config.URL = strings.TrimSuffix(config.URL, "/")
+		s.registry = &registry{config: config}
+	}
+}
+
+type registry struct {
+	config RegistryConfig
+
+	mu         sync.Mutex
+	stop       context.CancelFunc
+	registered bool
+}
+
+func (r *registry) validate(transport string) error {
+	if transport == TransportStdio {
+		return fmt.Errorf("the registry lists network endpoints, the stdio transport has none")
+	}
+	if u, err := url.Parse(r.config.URL); err != nil || u.Host == "" {
+		return fmt.Errorf("invalid registry URL %q", r.config.URL)
+	}
+	if u, err := url.Parse(r.config.Endpoint); err != nil || u.Host == "" {
+		return fmt.Errorf("invalid registry endpoint %q, want the public URL of the MCP endpoint", r.config.Endpoint)
+	}
+	return nil
+}
+
+func (r *registry) serverURL() string {
+	return r.config.URL + "/servers/" + url.PathEscape(r.config.ID)
+}
+
+// Manifest is the registration of the server, see WithRegistry.
+func (s *Server) Manifest(ctx context.Context) (RegistryManifest, error) {
+	if s.registry == nil {
+		return RegistryManifest{}, fmt.Errorf("no registry configured")
+	}
+	description, err := s.Describe(ctx)
+	if err != nil {
+		return RegistryManifest{}, err
+	}
+	manifest := RegistryManifest{
+		ID:           s.registry.config.ID,
+		Name:         description.Name,
+		Version:      description.Version,
+		Endpoint:     s.registry.config.Endpoint,
+		Transport:    description.Transport,
+		Capabilities: []string{"tools", "resources", "logging"},
+		Auth:         description.Auth,
+		Tools:        []string{},
+		TTL:          int(3 * s.registry.config.Heartbeat / time.Second),
+	}
+	if s.prompts != nil {
+		manifest.Capabilities = append(manifest.Capabilities, "prompts")
+	}
+	for _, tool := range description.Tools {
+		manifest.Tools = append(manifest.Tools, tool.Name)
+	}
+	return manifest, nil
+}
+
+// runRegistry registers the server and renews the registration every
+// heartbeat until Shutdown. Failures are logged and retried on the next
+// heartbeat, the registry is not required to serve.
+func (s *Server) runRegistry() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.registry.mu.Lock()
+	s.registry.stop = cancel
+	s.registry.mu.Unlock()
+
+	ticker := time.NewTicker(s.registry.config.Heartbeat)
+	defer ticker.Stop()
+	for {
+		if err := s.register(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Registry heartbeat failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// register publishes the manifest, the tools may have changed since the
+// last heartbeat.
+func (s *Server) register(ctx context.Context) error {
+	manifest, err := s.Manifest(ctx)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	if err := s.registry.call(ctx, http.MethodPut, body); err != nil {
+		return err
+	}
+	s.registry.mu.Lock()
+	first := !s.registry.registered
+	s.registry.registered = true
+	s.registry.mu.Unlock()
+	if first {
+		log.Printf("Registered as %s with the registry %s", manifest.ID, s.registry.config.URL)
+	}
+	return nil
+}
+
+// Deregister stops the heartbeats and removes the registration, which is
+// no error when there is none. Shutdown deregisters before draining, so
+// the registry stops sending clients.
+func (s *Server) Deregister(ctx context.Context) error {
+	if s.registry == nil {
+		return nil
+	}
+	s.registry.mu.Lock()
+	if s.registry.stop != nil {
+		s.registry.stop()
+	}
+	s.registry.registered = false
+	s.registry.mu.Unlock()
+	if err := s.registry.call(ctx, http.MethodDelete, nil); err != nil {
+		return err
+	}
+	log.Printf("Deregistered %s from the registry %s", s.registry.config.ID, s.registry.config.URL)
+	return nil
+}
+
+func (r *registry) call(ctx context.Context, method string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, method, r.serverURL(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if r.config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.config.Token)
+	}
+	resp, err := r.config.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach the registry: %w", err)
+	}
+	defer resp.Body.Close()
+	if method == http.MethodDelete && resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	if resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return fmt.Errorf("registry %s: status code %d: %s", method, resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
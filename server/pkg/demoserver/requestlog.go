@@ -0,0 +1,283 @@
+package demoserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// DefaultRequestLogSampleRate is the share of successful requests logged,
+// errors are always logged.
+const DefaultRequestLogSampleRate = 0.1
+
+// DefaultMaxCapturedBody caps the bodies logged for captured sessions.
+const DefaultMaxCapturedBody = 4096
+
+// LatencyBuckets are the upper bounds of the request latency histogram.
+var LatencyBuckets = []time.Duration{
+	5 * time.Millisecond,
+	25 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
+// RequestMetrics is implemented by Metrics that also observe every MCP
+// request, not only tool calls.
+type RequestMetrics interface {
+	ObserveRequest(method string, duration time.Duration, err error)
+}
+
+// ObserveRequest counts the request in its latency bucket.
+func (m *ExpvarMetrics) ObserveRequest(method string, duration time.Duration, err error) {
+	m.requests.Add(method+" "+latencyBucket(duration), 1)
+	if err != nil {
+		m.requestErrors.Add(method, 1)
+	}
+}
+
+func latencyBucket(duration time.Duration) string {
+	for _, bound := range LatencyBuckets {
+		if duration <= bound {
+			return "le_" + bound.String()
+		}
+	}
+	return "le_inf"
+}
+
+// WithRequestLog logs the MCP requests and the HTTP requests of the network
+// transports, every error and sampleRate of the successes.
+func WithRequestLog(sampleRate float64) Option {
+	return func(s *Server) {
+		s.requestLog.enabled = true
+		s.requestLog.sampleRate = sampleRate
+	}
+}
+
+// WithCapturedSessions logs the full request and response bodies of the
+// given sessions, more can be flagged through the admin API.
+func WithCapturedSessions(sessionIDs ...string) Option {
+	return func(s *Server) {
+		for _, id := range sessionIDs {
+			s.requestLog.capture(id, true)
+		}
+	}
+}
+
+type requestLog struct {
+	enabled    bool
+	sampleRate float64
+	maxBody    int
+	rand       *lockedRand
+
+	mu       sync.Mutex
+	captured map[string]bool
+	started  sync.Map
+}
+
+type requestKey struct {
+	session string
+	id      string
+}
+
+func (l *requestLog) capture(sessionID string, enabled bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.captured == nil {
+		l.captured = make(map[string]bool)
+	}
+	if enabled {
+		l.captured[sessionID] = true
+	} else {
+		delete(l.captured, sessionID)
+	}
+}
+
+func (l *requestLog) isCaptured(sessionID string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.captured[sessionID]
+}
+
+func (l *requestLog) capturedSessions() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	sessions := make([]string, 0, len(l.captured))
+	for id := range l.captured {
+		sessions = append(sessions, id)
+	}
+	sort.Strings(sessions)
+	return sessions
+}
+
+// sampled decides whether a request is logged.
+func (l *requestLog) sampled(failed, captured bool) bool {
+	return failed || captured || l.rand.Float64() < l.sampleRate
+}
+
+func (l *requestLog) body(v any) string {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := json.NewEncoder(buf).Encode(v); err != nil {
+		return fmt.Sprintf("<%v>", err)
+	}
+	body := redactSecrets(string(bytes.TrimSuffix(buf.Bytes(), []byte("\n"))))
+	if len(body) > l.maxBody {
+		return body[:l.maxBody] + "..."
+	}
+	return body
+}
+
+func sessionIDFromContext(ctx context.Context) string {
+	if session := server.ClientSessionFromContext(ctx); session != nil {
+		return session.SessionID()
+	}
+	return ""
+}
+
+// registerRequestLogHooks times every MCP request to log it and report it to
+// the metrics.
+func (s *Server) registerRequestLogHooks(hooks *server.Hooks) {
+	requestMetrics, _ := s.metrics.(RequestMetrics)
+	if !s.requestLog.enabled && requestMetrics == nil {
+		return
+	}
+
+	keyOf := func(ctx context.Context, id any) requestKey {
+		return requestKey{session: sessionIDFromContext(ctx), id: fmt.Sprint(id)}
+	}
+	done := func(ctx context.Context, id any, method mcp.MCPMethod, message any, result any, err error) {
+		start, ok := s.requestLog.started.LoadAndDelete(keyOf(ctx, id))
+		if !ok {
+			return
+		}
+		duration := time.Since(start.(time.Time))
+		if toolResult, ok := result.(*mcp.CallToolResult); ok && toolResult.IsError && err == nil {
+			err = fmt.Errorf("tool returned an error result")
+		}
+		if requestMetrics != nil {
+			requestMetrics.ObserveRequest(string(method), duration, err)
+		}
+		if s.requestLog.enabled {
+			s.logRequest(ctx, id, method, message, result, duration, err)
+		}
+	}
+
+	hooks.AddBeforeAny(func(ctx context.Context, id any, method mcp.MCPMethod, message any) {
+		// notifications have no ID and get no response
+		if id != nil {
+			s.requestLog.started.Store(keyOf(ctx, id), time.Now())
+		}
+	})
+	hooks.AddOnSuccess(func(ctx context.Context, id any, method mcp.MCPMethod, message any, result any) {
+		done(ctx, id, method, message, result, nil)
+	})
+	hooks.AddOnError(func(ctx context.Context, id any, method mcp.MCPMethod, message any, err error) {
+		done(ctx, id, method, message, nil, err)
+	})
+}
+
+func (s *Server) logRequest(ctx context.Context, id any, method mcp.MCPMethod, message, result any, duration time.Duration, err error) {
+	session := sessionIDFromContext(ctx)
+	captured := session != "" && s.requestLog.isCaptured(session)
+	if !s.requestLog.sampled(err != nil, captured) {
+		return
+	}
+
+	line := fmt.Sprintf("mcp session=%s id=%v method=%s duration=%s", session, id, method, duration.Round(time.Microsecond))
+	if request, ok := message.(*mcp.CallToolRequest); ok {
+		line += " tool=" + request.Params.Name
+	}
+	if sc, ok := SpanContextFromContext(ctx); ok {
+		line += " trace=" + hex.EncodeToString(sc.TraceID[:])
+	}
+	if err != nil {
+		line += fmt.Sprintf(" error=%q", err)
+	}
+	if captured {
+		line += " request=" + s.requestLog.body(message)
+		if result != nil {
+			line += " response=" + s.requestLog.body(result)
+		}
+	}
+	log.Print(line)
+}
+
+// requestLogMiddleware logs the HTTP requests of the network transports,
+// every error and the sampled successes.
+func (s *Server) requestLogMiddleware(next http.Handler) http.Handler {
+	if !s.requestLog.enabled {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		session := r.URL.Query().Get("sessionId")
+		if session == "" {
+			session = r.Header.Get("Mcp-Session-Id")
+		}
+		captured := session != "" && s.requestLog.isCaptured(session)
+		if !s.requestLog.sampled(recorder.status >= http.StatusBadRequest, captured) {
+			return
+		}
+		log.Printf("http %s %s session=%s status=%d duration=%s", r.Method, r.URL.Path, session, recorder.status, time.Since(start).Round(time.Microsecond))
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Flush implements http.Flusher, which the SSE server requires.
+func (w *statusRecorder) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// handleAdminCapture lists the captured sessions on GET, and starts or stops
+// capturing the session of the session query parameter on POST and DELETE.
+func (s *Server) handleAdminCapture(w http.ResponseWriter, r *http.Request) {
+	session := r.URL.Query().Get("session")
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost, http.MethodDelete:
+		if session == "" {
+			http.Error(w, "missing session", http.StatusBadRequest)
+			return
+		}
+		s.requestLog.capture(session, r.Method == http.MethodPost)
+		log.Printf("Body capture of session %s enabled=%t", session, r.Method == http.MethodPost)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"sessions": s.requestLog.capturedSessions()})
+}
@@ -0,0 +1,201 @@
+package demoserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	// ETagMetaKey holds the hash of the contents in the _meta of
+	// resources/read results.
+	ETagMetaKey = "etag"
+	// NotModifiedMetaKey is set in the _meta of resources/read results whose
+	// contents are left out, because they still match the etag of the client.
+	NotModifiedMetaKey = "notModified"
+	// IfNoneMatchArgument carries the etag of the contents cached by the
+	// client in the arguments of resources/read, as mcp-go drops the _meta of
+	// the request.
+	IfNoneMatchArgument = "ifNoneMatch"
+	// OffsetArgument and LengthArgument of resources/read select a byte range
+	// of each of the contents, so clients resume a partial read of a large
+	// resource instead of reading it again. The range applies to the text,
+	// or to the decoded blob. Without a length the range runs to the end.
+	OffsetArgument = "offset"
+	LengthArgument = "length"
+	// RangeMetaKey lists the ranges served in the _meta of ranged reads, one
+	// ContentRange per content.
+	RangeMetaKey = "range"
+
+	// RolloutStatsURI is the resource of the rollout stats, for dashboards
+	// polling them.
+	RolloutStatsURI = "rollout://stats"
+)
+
+// Resource is a resource along with the handler reading it.
+type Resource struct {
+	Resource mcp.Resource
+	Handler  server.ResourceHandlerFunc
+}
+
+// WithExtraResources registers resources on top of the built-in ones.
+func WithExtraResources(resources ...Resource) Option {
+	return func(s *Server) {
+		s.extraResources = append(s.extraResources, resources...)
+	}
+}
+
+func (s *Server) registerResources() {
+	if s.toolSetEnabled(ToolSetRollout) {
+		s.mcpServer.AddResource(mcp.NewResource(RolloutStatsURI, "Rollout stats",
+			mcp.WithResourceDescription("Per-variant metrics of the tools under canary rollout"),
+			mcp.WithMIMEType("application/json"),
+		), s.readRolloutStats)
+	}
+	if s.history.size > 0 {
+		s.mcpServer.AddResource(mcp.NewResource(HistoryURI, "Tool call history",
+			mcp.WithResourceDescription("The recent tool calls of this session and their results, with secrets redacted"),
+			mcp.WithMIMEType("application/json"),
+		), s.readHistory)
+	}
+	s.mcpServer.AddResource(mcp.NewResource(ServerDescriptionURI, "Server description",
+		mcp.WithResourceDescription("The version, subsystems, auth mode, rate limits and tools of the server"),
+		mcp.WithMIMEType("application/json"),
+	), s.readServerDescription)
+	s.registerToolDocs()
+	for _, r := range s.extraResources {
+		s.mcpServer.AddResource(r.Resource, r.Handler)
+	}
+}
+
+func (s *Server) readRolloutStats(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	body, err := s.rolloutStatsJSON()
+	if err != nil {
+		return nil, err
+	}
+	return []mcp.ResourceContents{mcp.TextResourceContents{
+		URI:      RolloutStatsURI,
+		MIMEType: "application/json",
+		Text:     string(body),
+	}}, nil
+}
+
+// ContentRange is the range of a content served by a ranged read. Offset and
+// Length may differ from the requested range, which is clamped to the
+// content and moved to the next character boundary of texts.
+type ContentRange struct {
+	Offset int `json:"offset"`
+	Length int `json:"length"`
+	Total  int `json:"total"`
+}
+
+// registerResourceHooks tags every resources/read result with the hash of its
+// contents, and leaves out the contents the client already has. The etag is
+// that of the full contents, so clients resuming a ranged read tell whether
+// the resource changed in between.
+func (s *Server) registerResourceHooks(hooks *server.Hooks) {
+	hooks.AddAfterReadResource(func(ctx context.Context, id any, message *mcp.ReadResourceRequest, result *mcp.ReadResourceResult) {
+		if result == nil {
+			return
+		}
+		etag, err := resourceETag(result.Contents)
+		if err != nil {
+			return
+		}
+		if result.Meta == nil {
+			result.Meta = make(map[string]any)
+		}
+		result.Meta[ETagMetaKey] = etag
+
+		if message != nil && message.Params.Arguments[IfNoneMatchArgument] == etag {
+			result.Contents = []mcp.ResourceContents{}
+			result.Meta[NotModifiedMetaKey] = true
+			return
+		}
+		if offset, length, ok := requestedRange(message); ok {
+			ranges := make([]ContentRange, len(result.Contents))
+			for i, content := range result.Contents {
+				result.Contents[i], ranges[i] = rangeContent(content, offset, length)
+			}
+			result.Meta[RangeMetaKey] = ranges
+		}
+	})
+}
+
+// requestedRange returns the byte range of a ranged read, a length of -1
+// running to the end.
+func requestedRange(message *mcp.ReadResourceRequest) (offset, length int, ok bool) {
+	if message == nil {
+		return 0, 0, false
+	}
+	offsetArg, hasOffset := message.Params.Arguments[OffsetArgument].(float64)
+	lengthArg, hasLength := message.Params.Arguments[LengthArgument].(float64)
+	if !hasOffset && !hasLength {
+		return 0, 0, false
+	}
+	offset = max(int(offsetArg), 0)
+	length = -1
+	if hasLength {
+		length = max(int(lengthArg), 0)
+	}
+	return offset, length, true
+}
+
+// rangeContent returns the range of content from offset, up to length bytes.
+func rangeContent(content mcp.ResourceContents, offset, length int) (mcp.ResourceContents, ContentRange) {
+	switch c := content.(type) {
+	case mcp.TextResourceContents:
+		data := []byte(c.Text)
+		start, end := byteRange(len(data), offset, length)
+		for start < len(data) && !utf8.RuneStart(data[start]) {
+			start++
+		}
+		for end > start && end < len(data) && !utf8.RuneStart(data[end]) {
+			end--
+		}
+		// a range shorter than the character at start still makes progress
+		if end == start && start < len(data) && length != 0 {
+			end++
+			for end < len(data) && !utf8.RuneStart(data[end]) {
+				end++
+			}
+		}
+		c.Text = string(data[start:end])
+		return c, ContentRange{Offset: start, Length: end - start, Total: len(data)}
+	case mcp.BlobResourceContents:
+		data, err := base64.StdEncoding.DecodeString(c.Blob)
+		if err != nil {
+			return c, ContentRange{Length: len(c.Blob), Total: len(c.Blob)}
+		}
+		start, end := byteRange(len(data), offset, length)
+		c.Blob = base64.StdEncoding.EncodeToString(data[start:end])
+		return c, ContentRange{Offset: start, Length: end - start, Total: len(data)}
+	}
+	return content, ContentRange{}
+}
+
+// byteRange clamps the range from offset of length, -1 to the end, to size.
+func byteRange(size, offset, length int) (start, end int) {
+	start = min(offset, size)
+	end = size
+	if length >= 0 && length < size-start {
+		end = start + length
+	}
+	return start, end
+}
+
+// resourceETag hashes the contents as sent to the client.
+func resourceETag(contents []mcp.ResourceContents) (string, error) {
+	data, err := json.Marshal(contents)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16]), nil
+}
@@ -0,0 +1,136 @@
+package demoserver
+
+import (
+	"context"
+	"hash/fnv"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	VariantPrimary string = "primary"
+	VariantCanary  string = "canary"
+)
+
+// Rollout routes calls for a single tool between a primary and a canary
+// implementation. Principals listed in Principals always get the canary,
+// everyone else gets it Percent% of the time. The same principal is always
+// routed to the same variant so results stay comparable.
+type Rollout struct {
+	Tool       string
+	Primary    server.ToolHandlerFunc
+	Canary     server.ToolHandlerFunc
+	Percent    int
+	Principals []string
+	// IntN buckets the callers without a principal, math/rand by default.
+	IntN func(n int) int
+
+	mu    sync.Mutex
+	stats map[string]*VariantStats
+}
+
+// VariantStats are the comparative metrics tracked for one variant.
+type VariantStats struct {
+	Calls        int           `json:"calls"`
+	Errors       int           `json:"errors"`
+	TotalLatency time.Duration `json:"totalLatencyNs"`
+}
+
+// AvgLatency returns the mean latency of the calls made to the variant.
+func (s VariantStats) AvgLatency() time.Duration {
+	if s.Calls == 0 {
+		return 0
+	}
+	return s.TotalLatency / time.Duration(s.Calls)
+}
+
+// principalFromContext returns the caller identity used for routing, which is
+// the OIDC principal or else the bearer token when present.
+func principalFromContext(ctx context.Context) string {
+	if principal, ok := PrincipalFromContext(ctx); ok {
+		return principal.Name
+	}
+	token, err := tokenFromContext(ctx)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(token, "Bearer ")
+}
+
+func (r *Rollout) variantFor(principal string) string {
+	if r.Canary == nil {
+		return VariantPrimary
+	}
+	for _, p := range r.Principals {
+		if p == principal {
+			return VariantCanary
+		}
+	}
+
+	var bucket int
+	if principal != "" {
+		h := fnv.New32a()
+		h.Write([]byte(r.Tool + ":" + principal))
+		bucket = int(h.Sum32() % 100)
+	} else {
+		intN := r.IntN
+		if intN == nil {
+			intN = rand.IntN
+		}
+		bucket = intN(100)
+	}
+	if bucket < r.Percent {
+		return VariantCanary
+	}
+	return VariantPrimary
+}
+
+// Handler returns the tool handler that performs the routing.
+func (r *Rollout) Handler() server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		variant := r.variantFor(principalFromContext(ctx))
+		handler := r.Primary
+		if variant == VariantCanary {
+			handler = r.Canary
+		}
+
+		start := time.Now()
+		result, err := handler(ctx, request)
+		r.record(variant, time.Since(start), err != nil || (result != nil && result.IsError))
+		return result, err
+	}
+}
+
+func (r *Rollout) record(variant string, latency time.Duration, failed bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stats == nil {
+		r.stats = make(map[string]*VariantStats)
+	}
+	s, ok := r.stats[variant]
+	if !ok {
+		s = &VariantStats{}
+		r.stats[variant] = s
+	}
+	s.Calls++
+	s.TotalLatency += latency
+	if failed {
+		s.Errors++
+	}
+}
+
+// Stats returns a snapshot of the metrics for each variant.
+func (r *Rollout) Stats() map[string]VariantStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	snapshot := make(map[string]VariantStats, len(r.stats))
+	for variant, s := range r.stats {
+		snapshot[variant] = *s
+	}
+	return snapshot
+}
@@ -0,0 +1,228 @@
+package demoserver
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReadinessCheck verifies a dependency before the server reports ready.
+type ReadinessCheck func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	check ReadinessCheck
+}
+
+// readiness gates /readyz until every check passed once.
+type readiness struct {
+	mu     sync.RWMutex
+	ready  bool
+	checks []namedCheck
+}
+
+// LookupConfig resolves a config value from the environment variable key,
+// then from the file named by key_FILE, then from the file key in dir, which
+// is how Kubernetes projected secrets are usually mounted.
+func LookupConfig(key, dir string) (string, bool) {
+	if value, ok := os.LookupEnv(key); ok {
+		return value, true
+	}
+	if path, ok := os.LookupEnv(key + "_FILE"); ok {
+		if data, err := os.ReadFile(path); err == nil {
+			return strings.TrimSpace(string(data)), true
+		}
+	}
+	if dir != "" {
+		if data, err := os.ReadFile(filepath.Join(dir, key)); err == nil {
+			return strings.TrimSpace(string(data)), true
+		}
+	}
+	return "", false
+}
+
+// WithReadinessCheck delays readiness until check succeeds.
+func WithReadinessCheck(name string, check ReadinessCheck) Option {
+	return func(s *Server) {
+		s.readiness.checks = append(s.readiness.checks, namedCheck{name, check})
+	}
+}
+
+// WithAuthTokensFile requires one of the bearer tokens listed in path, one per
+// line, on the network transports. The file is read again on Reload.
+func WithAuthTokensFile(path string) Option {
+	return func(s *Server) {
+		s.tokensFile = &tokensFile{path: path}
+		s.authMiddleware = s.tokensFile.middleware
+	}
+}
+
+type tokensFile struct {
+	path   string
+	mu     sync.RWMutex
+	tokens map[string]bool
+}
+
+func (f *tokensFile) load() error {
+	tokens, err := f.read()
+	if err != nil {
+		return err
+	}
+	f.set(tokens)
+	return nil
+}
+
+func (f *tokensFile) read() (map[string]bool, error) {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open tokens file: %w", err)
+	}
+	defer file.Close()
+
+	tokens := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if token := strings.TrimSpace(scanner.Text()); token != "" {
+			tokens[token] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read tokens file: %w", err)
+	}
+	return tokens, nil
+}
+
+func (f *tokensFile) set(tokens map[string]bool) {
+	f.mu.Lock()
+	f.tokens = tokens
+	f.mu.Unlock()
+}
+
+func (f *tokensFile) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		f.mu.RLock()
+		ok := found && f.tokens[token]
+		f.mu.RUnlock()
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Reload re-reads the locale catalogs, the auth tokens file and the files of
+// the post-processors, argument rules and client catalogs, and picks up the
+// changed documents. Everything is read before anything is replaced, so the
+// current config is kept whole when any file fails to load.
+func (s *Server) Reload() error {
+	catalog, err := LoadCatalog(s.locale, s.localesDir)
+	if err != nil {
+		return fmt.Errorf("failed to reload locales: %w", err)
+	}
+	var tokens map[string]bool
+	if s.tokensFile != nil {
+		if tokens, err = s.tokensFile.read(); err != nil {
+			return err
+		}
+	}
+	processors, err := s.postProcessors.read()
+	if err != nil {
+		return err
+	}
+	rules, err := s.argumentRules.read()
+	if err != nil {
+		return err
+	}
+	catalogRules, err := s.clientCatalogs.read()
+	if err != nil {
+		return err
+	}
+
+	// concurrent reloads must not interleave their swaps
+	s.reloadMu.Lock()
+	s.catalog.replace(catalog)
+	if s.tokensFile != nil {
+		s.tokensFile.set(tokens)
+	}
+	s.postProcessors.set(processors)
+	s.argumentRules.set(rules)
+	s.clientCatalogs.set(catalogRules)
+	s.reloadMu.Unlock()
+	if s.docs != nil {
+		if err := s.refreshDocuments(); err != nil {
+			return err
+		}
+		go func() {
+			if err := s.indexDocuments(context.Background()); err != nil {
+				log.Printf("Failed to index documents: %v", err)
+			}
+		}()
+	}
+	if s.prompts != nil {
+		if err := s.refreshPrompts(); err != nil {
+			return err
+		}
+	}
+	log.Printf("Configuration reloaded")
+	return nil
+}
+
+// Ready reports whether every readiness check passed.
+func (s *Server) Ready() bool {
+	s.readiness.mu.RLock()
+	defer s.readiness.mu.RUnlock()
+	return s.readiness.ready
+}
+
+// awaitReadiness runs the readiness checks until all of them pass.
+func (s *Server) awaitReadiness(ctx context.Context) {
+	backoff := time.Second
+	for {
+		var failed bool
+		for _, c := range s.readiness.checks {
+			if err := c.check(ctx); err != nil {
+				log.Printf("Readiness check %s failed: %v", c.name, err)
+				failed = true
+			}
+		}
+		if !failed {
+			s.readiness.mu.Lock()
+			s.readiness.ready = true
+			s.readiness.mu.Unlock()
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"status":"UP"}`))
+}
+
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if !s.Ready() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"status":"NOT_READY"}`))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"status":"READY"}`))
+}
@@ -0,0 +1,100 @@
+package demoserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestReloadKeepsConfig checks that a reload failing on any file replaces
+// nothing, and that the tokens file only accepts bearer tokens.
+func TestReloadKeepsConfig(t *testing.T) {
+	dir := t.TempDir()
+	tokensPath := filepath.Join(dir, "tokens")
+	rulesPath := filepath.Join(dir, "rules.json")
+	os.WriteFile(tokensPath, []byte("old-token\n"), 0o600)
+	os.WriteFile(rulesPath, []byte(`{}`), 0o600)
+	s, err := New(WithTransport(TransportHTTP), WithAuthTokensFile(tokensPath), WithArgumentRulesFile(rulesPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := s.tokensFile.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	status := func(authorization string) int {
+		req := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+		req.Header.Set("Authorization", authorization)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	os.WriteFile(tokensPath, []byte("new-token\n"), 0o600)
+	os.WriteFile(rulesPath, []byte(`{"echo": `), 0o600)
+	if err := s.Reload(); err == nil {
+		t.Fatal("Reload() of an invalid argument rules file succeeded")
+	}
+	if status("Bearer old-token") != http.StatusOK || status("Bearer new-token") != http.StatusUnauthorized {
+		t.Error("a failed Reload() replaced the tokens")
+	}
+
+	os.WriteFile(rulesPath, []byte(`{}`), 0o600)
+	if err := s.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	for authorization, want := range map[string]int{
+		"Bearer new-token": http.StatusOK,
+		"Bearer old-token": http.StatusUnauthorized,
+		"new-token":        http.StatusUnauthorized,
+		"Basic new-token":  http.StatusUnauthorized,
+	} {
+		if got := status(authorization); got != want {
+			t.Errorf("Authorization %q answered %d, want %d", authorization, got, want)
+		}
+	}
+}
+
+// TestDrain checks that draining waits for the tool calls and the open SSE
+// streams, and that maintenance never asks to retry after 0s.
+func TestDrain(t *testing.T) {
+	s, err := New(WithTransport(TransportHTTP), WithDrainTimeout(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	s.SetMaintenance(true)
+	s.maintenanceMiddleware(http.NotFoundHandler()).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/mcp", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "1" {
+		t.Errorf("new session in maintenance answered %d with Retry-After %q, want 503 and 1", rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	s.drainTimeout = 5 * time.Second
+	s.maintenance.sessions.Add(1)
+	s.maintenance.inFlight.Add(1)
+	time.AfterFunc(200*time.Millisecond, func() { s.maintenance.inFlight.Add(-1) })
+	start := time.Now()
+	s.drain(context.Background())
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("drain took %s, want it to end with the tool call", elapsed)
+	}
+
+	s.sse.heartbeat = 0
+	closed := make(chan struct{})
+	opened := make(chan struct{})
+	stream := s.sseStreamMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(opened)
+		<-closed
+	}))
+	go stream.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/sse", nil))
+	<-opened
+	time.AfterFunc(200*time.Millisecond, func() { close(closed) })
+	start = time.Now()
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("Shutdown took %s, want it to wait for the SSE stream", elapsed)
+	}
+}
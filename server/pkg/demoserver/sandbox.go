@@ -0,0 +1,326 @@
+package demoserver
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	// DefaultSandboxTimeout is how long a sandboxed call may run before its
+	// helper is killed.
+	DefaultSandboxTimeout = 30 * time.Second
+
+	// SandboxLimitsEnv passes the SandboxLimits to the helper as JSON, for
+	// EnterSandbox.
+	SandboxLimitsEnv = "MCP_SANDBOX_LIMITS"
+	// SandboxToolEnv names the tool of the call to the helper, which only
+	// registers that tool, see WithSandboxHelper.
+	SandboxToolEnv = "MCP_SANDBOX_TOOL"
+
+	// sandboxCallerMeta is the _meta field of the call passing the caller to
+	// the helper, which serves the calls of the server starting it only.
+	sandboxCallerMeta = "go-mcp/caller"
+
+	// maxSandboxResponse caps a response line of the helper.
+	maxSandboxResponse = 16 << 20
+)
+
+// SandboxConfig runs the calls of selected tools in a helper process each, so
+// a misbehaving tool cannot crash the server or hog its memory and CPU.
+type SandboxConfig struct {
+	// Tools are path.Match patterns of the sandboxed tools, i.e. git_* or
+	// docker_*.
+	Tools []string
+	// Command starts the helper, an MCP server on stdio offering the same
+	// tools without middleware, see WithSandboxHelper. The server
+	// applies its middleware to the calls before they reach the helper.
+	Command []string
+	// Limits are applied by the helper to itself, see EnterSandbox.
+	Limits SandboxLimits
+	// Timeout kills the helper of a call running longer,
+	// DefaultSandboxTimeout by default.
+	Timeout time.Duration
+}
+
+// SandboxLimits are the resource limits of a helper, 0 leaves a limit as
+// inherited.
+type SandboxLimits struct {
+	// MemoryBytes caps the address space, allocations beyond fail.
+	MemoryBytes uint64 `json:"memoryBytes,omitempty"`
+	// CPUSeconds caps the CPU time, the helper is killed beyond.
+	CPUSeconds uint64 `json:"cpuSeconds,omitempty"`
+	// OpenFiles caps the open file descriptors.
+	OpenFiles uint64 `json:"openFiles,omitempty"`
+	// FileSizeBytes caps the size of the files the helper writes.
+	FileSizeBytes uint64 `json:"fileSizeBytes,omitempty"`
+	// NoNewPrivileges keeps the helper and what it executes from gaining
+	// privileges, i.e. through setuid binaries.
+	NoNewPrivileges bool `json:"noNewPrivileges,omitempty"`
+}
+
+func (l SandboxLimits) isZero() bool {
+	return l == SandboxLimits{}
+}
+
+// WithSandbox runs the tools matching config.Tools in a helper process per
+// call.
+func WithSandbox(config SandboxConfig) Option {
+	return func(s *Server) {
+		if config.Timeout <= 0 {
+			config.Timeout = DefaultSandboxTimeout
+		}
+		s.sandbox = &config
+	}
+}
+
+// WithSandboxHelper configures the server as the helper of WithSandbox: its
+// tools run without middleware, which the sandboxing server applied
+// already, as the caller it passed along. Only tool is registered, the one
+// of SandboxToolEnv, or every tool when empty.
+func WithSandboxHelper(tool string) Option {
+	return func(s *Server) {
+		s.sandboxHelper = true
+		s.sandboxTool = tool
+	}
+}
+
+// sandboxCaller is what the tools know about the caller from the context,
+// passed to the helper in the _meta of the call.
+type sandboxCaller struct {
+	Auth        *string           `json:"auth,omitempty"`
+	Principal   *Principal        `json:"principal,omitempty"`
+	Locale      string            `json:"locale,omitempty"`
+	VersionPins map[string]string `json:"versionPins,omitempty"`
+}
+
+func callerFromContext(ctx context.Context) sandboxCaller {
+	var caller sandboxCaller
+	if auth, err := tokenFromContext(ctx); err == nil {
+		caller.Auth = &auth
+	}
+	if principal, ok := PrincipalFromContext(ctx); ok {
+		caller.Principal = &principal
+	}
+	caller.Locale, _ = ctx.Value(localeKey{}).(string)
+	caller.VersionPins, _ = ctx.Value(versionPinsKey{}).(map[string]string)
+	return caller
+}
+
+// withCaller returns ctx with the values of callerFromContext.
+func (c sandboxCaller) withCaller(ctx context.Context) context.Context {
+	if c.Auth != nil {
+		ctx = withAuthKey(ctx, *c.Auth)
+	}
+	if c.Principal != nil {
+		ctx = withPrincipal(ctx, *c.Principal)
+	}
+	if c.Locale != "" {
+		ctx = withLocale(ctx, c.Locale)
+	}
+	if c.VersionPins != nil {
+		ctx = withVersionPins(ctx, c.VersionPins)
+	}
+	return ctx
+}
+
+// sandboxCallerMiddleware runs the calls of the helper as the caller the
+// sandboxing server passed along.
+func (s *Server) sandboxCallerMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if request.Params.Meta != nil {
+			if value, ok := request.Params.Meta.AdditionalFields[sandboxCallerMeta]; ok {
+				var caller sandboxCaller
+				data, _ := json.Marshal(value)
+				if err := json.Unmarshal(data, &caller); err != nil {
+					return nil, fmt.Errorf("invalid sandbox caller: %w", err)
+				}
+				ctx = caller.withCaller(ctx)
+			}
+		}
+		return next(ctx, request)
+	}
+}
+
+// EnterSandbox applies the SandboxLimits the sandboxing server passed in
+// SandboxLimitsEnv to the current process. Helpers call it at startup,
+// before they serve.
+func EnterSandbox() error {
+	value := os.Getenv(SandboxLimitsEnv)
+	if value == "" {
+		return nil
+	}
+	var limits SandboxLimits
+	if err := json.Unmarshal([]byte(value), &limits); err != nil {
+		return fmt.Errorf("invalid %s: %w", SandboxLimitsEnv, err)
+	}
+	return applySandboxLimits(limits)
+}
+
+func (c *SandboxConfig) validate() error {
+	if len(c.Command) == 0 {
+		return fmt.Errorf("the sandbox requires a helper command")
+	}
+	for _, pattern := range c.Tools {
+		if err := (ClientCatalogRule{Client: "*", Tools: []string{pattern}}).validate(); err != nil {
+			return fmt.Errorf("invalid sandboxed tool %w", err)
+		}
+	}
+	return nil
+}
+
+// sandboxMiddleware hands the calls of the sandboxed tools to a helper, as
+// the innermost middleware.
+func (s *Server) sandboxMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	if s.sandbox == nil {
+		return next
+	}
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if !matchesAny(s.sandbox.Tools, request.Params.Name) {
+			return next(ctx, request)
+		}
+		result, err := s.sandbox.call(ctx, request)
+		if err != nil {
+			return nil, fmt.Errorf("sandboxed tool %s: %w", request.Params.Name, err)
+		}
+		return result, nil
+	}
+}
+
+// call starts a helper, initializes it and makes the tool call, killing the
+// helper when ctx is done or the call times out.
+func (c *SandboxConfig) call(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, c.Command[0], c.Command[1:]...)
+	cmd.Env = append(os.Environ(), SandboxToolEnv+"="+request.Params.Name)
+	if !c.Limits.isZero() {
+		limits, err := json.Marshal(c.Limits)
+		if err != nil {
+			return nil, err
+		}
+		cmd.Env = append(cmd.Env, SandboxLimitsEnv+"="+string(limits))
+	}
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start helper: %w", err)
+	}
+
+	// the helper has the context of its stdio session only
+	meta := &mcp.Meta{AdditionalFields: map[string]any{sandboxCallerMeta: callerFromContext(ctx)}}
+	if request.Params.Meta != nil {
+		meta.ProgressToken = request.Params.Meta.ProgressToken
+		for name, value := range request.Params.Meta.AdditionalFields {
+			if name != sandboxCallerMeta {
+				meta.AdditionalFields[name] = value
+			}
+		}
+	}
+	request.Params.Meta = meta
+
+	result, callErr := exchangeSandboxCall(stdin, stdout, request)
+	stdin.Close()
+	waitErr := cmd.Wait()
+	switch {
+	case callErr == nil:
+		return result, nil
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return nil, fmt.Errorf("helper killed after %v", c.Timeout)
+	case ctx.Err() != nil:
+		return nil, ctx.Err()
+	case waitErr != nil:
+		// i.e. killed for exceeding its CPU time or out of memory
+		return nil, fmt.Errorf("helper exited: %v: %s", waitErr, lastLine(stderr.String()))
+	}
+	return nil, callErr
+}
+
+// exchangeSandboxCall speaks JSON-RPC with the helper: the initialize
+// handshake and the tool call, whose result it waits for.
+func exchangeSandboxCall(stdin io.Writer, stdout io.Reader, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	encoder := json.NewEncoder(stdin)
+	messages := []any{
+		mcp.JSONRPCRequest{
+			JSONRPC: mcp.JSONRPC_VERSION,
+			ID:      mcp.NewRequestId(1),
+			Request: mcp.Request{Method: string(mcp.MethodInitialize)},
+			Params: mcp.InitializeParams{
+				ProtocolVersion: mcp.LATEST_PROTOCOL_VERSION,
+				ClientInfo:      mcp.Implementation{Name: ServerName + "/sandbox", Version: ServerVersion},
+			},
+		},
+		mcp.JSONRPCNotification{
+			JSONRPC:      mcp.JSONRPC_VERSION,
+			Notification: mcp.Notification{Method: "notifications/initialized"},
+		},
+		mcp.JSONRPCRequest{
+			JSONRPC: mcp.JSONRPC_VERSION,
+			ID:      mcp.NewRequestId(2),
+			Request: mcp.Request{Method: string(mcp.MethodToolsCall)},
+			Params:  request.Params,
+		},
+	}
+	for _, message := range messages {
+		if err := encoder.Encode(message); err != nil {
+			return nil, fmt.Errorf("failed to write to helper: %w", err)
+		}
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(nil, maxSandboxResponse)
+	for scanner.Scan() {
+		var response struct {
+			ID     any              `json:"id"`
+			Result *json.RawMessage `json:"result"`
+			Error  *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &response); err != nil {
+			return nil, fmt.Errorf("invalid helper response: %w", err)
+		}
+		// skip the initialize result and the notifications
+		if id, ok := response.ID.(float64); !ok || id != 2 {
+			continue
+		}
+		if response.Error != nil {
+			return nil, errors.New(response.Error.Message)
+		}
+		if response.Result == nil {
+			return nil, fmt.Errorf("helper response without result")
+		}
+		return mcp.ParseCallToolResult(response.Result)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read from helper: %w", err)
+	}
+	return nil, fmt.Errorf("helper closed without a result")
+}
+
+func lastLine(text string) string {
+	text = strings.TrimSpace(text)
+	if i := strings.LastIndexByte(text, '\n'); i >= 0 {
+		return text[i+1:]
+	}
+	return text
+}
@@ -0,0 +1,45 @@
+package demoserver
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+)
+
+// prSetNoNewPrivs is PR_SET_NO_NEW_PRIVS of prctl(2).
+const prSetNoNewPrivs = 38
+
+// applySandboxLimits sets the rlimits of the current process and keeps it
+// from gaining privileges through execve. no_new_privs is a per-thread
+// attribute, so it is set on all threads of the runtime: a child forked from
+// any of them inherits it.
+func applySandboxLimits(limits SandboxLimits) error {
+	for _, rlimit := range []struct {
+		name     string
+		resource int
+		value    uint64
+	}{
+		{"memory", syscall.RLIMIT_AS, limits.MemoryBytes},
+		{"CPU time", syscall.RLIMIT_CPU, limits.CPUSeconds},
+		{"open files", syscall.RLIMIT_NOFILE, limits.OpenFiles},
+		{"file size", syscall.RLIMIT_FSIZE, limits.FileSizeBytes},
+	} {
+		if rlimit.value == 0 {
+			continue
+		}
+		if err := syscall.Setrlimit(rlimit.resource, &syscall.Rlimit{Cur: rlimit.value, Max: rlimit.value}); err != nil {
+			return fmt.Errorf("failed to limit %s: %w", rlimit.name, err)
+		}
+	}
+	if limits.NoNewPrivileges {
+		_, _, errno := syscall.AllThreadsSyscall(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0)
+		if errors.Is(errno, syscall.ENOTSUP) {
+			// i.e. built with cgo, whose threads the runtime cannot reach
+			return errors.New("failed to set no_new_privs: not supported on all threads of this binary")
+		}
+		if errno != 0 {
+			return fmt.Errorf("failed to set no_new_privs: %w", errno)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package demoserver
+
+import "errors"
+
+// applySandboxLimits fails for any limit, they are only supported on Linux.
+func applySandboxLimits(limits SandboxLimits) error {
+	if limits.isZero() {
+		return nil
+	}
+	return errors.New("sandbox limits are only supported on Linux")
+}
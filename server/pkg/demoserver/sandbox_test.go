@@ -0,0 +1,124 @@
+package demoserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+func TestExchangeSandboxCall(t *testing.T) {
+	request := mcp.CallToolRequest{}
+	request.Params.Name = "echo"
+	request.Params.Arguments = map[string]any{"message": "hi"}
+
+	var stdin bytes.Buffer
+	result, err := exchangeSandboxCall(&stdin, strings.NewReader(
+		`{"jsonrpc":"2.0","id":1,"result":{"protocolVersion":"2025-03-26"}}`+"\n"+
+			`{"jsonrpc":"2.0","method":"notifications/message","params":{}}`+"\n"+
+			`{"jsonrpc":"2.0","id":2,"result":{"content":[{"type":"text","text":"hi"}]}}`+"\n"), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text := result.Content[0].(mcp.TextContent).Text; text != "hi" {
+		t.Errorf("result %q, want hi", text)
+	}
+	sent := strings.Split(strings.TrimSpace(stdin.String()), "\n")
+	if len(sent) != 3 || !strings.Contains(sent[0], `"initialize"`) || !strings.Contains(sent[1], `"notifications/initialized"`) ||
+		!strings.Contains(sent[2], `"tools/call"`) || !strings.Contains(sent[2], `"message":"hi"`) {
+		t.Errorf("sent %q, want initialize, initialized and the call", sent)
+	}
+
+	for response, want := range map[string]string{
+		`{"jsonrpc":"2.0","id":2,"error":{"code":-32603,"message":"boom"}}`: "boom",
+		`{"jsonrpc":"2.0","id":2}`:             "without result",
+		`not json`:                             "invalid helper response",
+		`{"jsonrpc":"2.0","id":1,"result":{}}`: "closed without a result",
+	} {
+		_, err := exchangeSandboxCall(&bytes.Buffer{}, strings.NewReader(response+"\n"), request)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("response %s failed with %v, want %q", response, err, want)
+		}
+	}
+}
+
+// TestSandboxCallKillsHelper checks that helpers are killed on timeout and
+// cancellation and that the exit of a helper is reported.
+func TestSandboxCallKillsHelper(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip(err)
+	}
+	request := mcp.CallToolRequest{}
+	request.Params.Name = "echo"
+
+	hanging := &SandboxConfig{Command: []string{"sh", "-c", "exec sleep 10"}, Timeout: 200 * time.Millisecond}
+	start := time.Now()
+	if _, err := hanging.call(context.Background(), request); err == nil || !strings.Contains(err.Error(), "helper killed after") {
+		t.Errorf("hanging helper failed with %v, want it killed", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("killing the helper took %s", elapsed)
+	}
+
+	hanging.Timeout = time.Minute
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(200*time.Millisecond, cancel)
+	if _, err := hanging.call(ctx, request); err != context.Canceled {
+		t.Errorf("cancelled call failed with %v, want %v", err, context.Canceled)
+	}
+
+	crashing := &SandboxConfig{Command: []string{"sh", "-c", "echo out of memory >&2; exit 3"}, Timeout: time.Minute}
+	if _, err := crashing.call(context.Background(), request); err == nil || !strings.Contains(err.Error(), "exit status 3: out of memory") {
+		t.Errorf("crashing helper failed with %v, want its exit and last stderr line", err)
+	}
+}
+
+// TestSandboxHelperCaller checks that the helper only registers the tool of
+// the call and runs it as the caller of the sandboxing server.
+func TestSandboxHelperCaller(t *testing.T) {
+	whoami := server.ServerTool{
+		Tool: mcp.NewTool("whoami"),
+		Handler: func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			auth, _ := tokenFromContext(ctx)
+			principal, _ := PrincipalFromContext(ctx)
+			return mcp.NewToolResultText(fmt.Sprintf("%s|%s|%s|%s", auth, principal.Name,
+				localize(ctx, "error.demo_disabled", "x"), versionPinFromContext(ctx, "echo"))), nil
+		},
+	}
+	s, err := New(WithSandboxHelper("whoami"), WithExtraTools(whoami))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.registeredTool("echo"); ok {
+		t.Error("the helper of whoami registered echo")
+	}
+
+	ctx := withAuthKey(context.Background(), "Bearer secret")
+	ctx = withPrincipal(ctx, Principal{Name: "alice", Claims: map[string]any{"sub": "alice"}})
+	ctx = withLocale(ctx, "de")
+	ctx = withVersionPins(ctx, map[string]string{"echo": "2"})
+	message, err := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "tools/call",
+		"params": map[string]any{
+			"name":  "whoami",
+			"_meta": map[string]any{sandboxCallerMeta: callerFromContext(ctx)},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, ok := resultText(s.mcpServer.HandleMessage(context.Background(), message))
+	want := fmt.Sprintf("Bearer secret|alice|%s|2", localize(withCatalog(ctx, s.catalog), "error.demo_disabled", "x"))
+	if !ok || text != want {
+		t.Errorf("whoami = %q, want %q", text, want)
+	}
+}
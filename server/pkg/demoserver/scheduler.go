@@ -0,0 +1,220 @@
+package demoserver
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// SchedulerConfig shares the tool calls fairly between principals once more
+// than MaxConcurrent run, so the burst of one API key cannot starve the
+// others.
+type SchedulerConfig struct {
+	// MaxConcurrent is how many tool calls run at once across principals,
+	// the others wait in the queue of their principal.
+	MaxConcurrent int
+	// Weights are the shares of principals while calls are queued, 1 by
+	// default, i.e. a principal of weight 2 gets twice the calls of one of
+	// weight 1. Principals are named as in the metrics, see
+	// principalLabel.
+	Weights map[string]int
+	// MaxQueued rejects the calls of a principal with that many calls
+	// waiting already, 0 queues them all.
+	MaxQueued int
+}
+
+// WithFairScheduling queues the tool calls beyond config.MaxConcurrent by
+// principal and runs them by weighted fair queuing.
+func WithFairScheduling(config SchedulerConfig) Option {
+	return func(s *Server) {
+		if config.MaxConcurrent > 0 {
+			s.scheduler = &scheduler{config: config}
+		}
+	}
+}
+
+// QueueMetrics is implemented by Metrics that also observe how long tool
+// calls waited for the fair scheduler.
+type QueueMetrics interface {
+	ObserveQueueWait(principal string, wait time.Duration)
+}
+
+// ObserveQueueWait counts the queued calls and their wait by principal.
+func (m *ExpvarMetrics) ObserveQueueWait(principal string, wait time.Duration) {
+	m.queued.Add(principal, 1)
+	m.queueWait.AddFloat(principal, float64(wait.Microseconds())/1000)
+}
+
+// principalLabel names the caller of ctx for scheduling and metrics: the OIDC
+// principal, the tokenFingerprint of the bearer token as in the policy input
+// and the forward-auth headers, or anonymous.
+func principalLabel(ctx context.Context) string {
+	if principal, ok := PrincipalFromContext(ctx); ok {
+		return principal.Name
+	}
+	token := principalFromContext(ctx)
+	if token == "" {
+		return "anonymous"
+	}
+	return tokenFingerprint(token)
+}
+
+// scheduler is a start-time fair queue: each queued call is tagged with the
+// virtual time its principal would finish it in, which advances by
+// 1/weight per call, and the smallest finish tag runs next.
+type scheduler struct {
+	config SchedulerConfig
+
+	mu      sync.Mutex
+	running int
+	// virtual is the start tag of the call that ran last.
+	virtual float64
+	// finish is the finish tag of the last queued call per principal, for
+	// the current backlog only.
+	finish map[string]float64
+	queued map[string]int
+	queue  waitQueue
+	seq    uint64
+}
+
+type waiter struct {
+	principal     string
+	start, finish float64
+	seq           uint64
+	ready         chan struct{}
+	index         int
+}
+
+// waitQueue orders the waiters by finish tag, then by arrival.
+type waitQueue []*waiter
+
+func (q waitQueue) Len() int { return len(q) }
+func (q waitQueue) Less(i, j int) bool {
+	if q[i].finish != q[j].finish {
+		return q[i].finish < q[j].finish
+	}
+	return q[i].seq < q[j].seq
+}
+func (q waitQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index, q[j].index = i, j
+}
+func (q *waitQueue) Push(x any) {
+	w := x.(*waiter)
+	w.index = len(*q)
+	*q = append(*q, w)
+}
+func (q *waitQueue) Pop() any {
+	old := *q
+	w := old[len(old)-1]
+	old[len(old)-1] = nil
+	w.index = -1
+	*q = old[:len(old)-1]
+	return w
+}
+
+func (s *scheduler) weight(principal string) float64 {
+	if weight := s.config.Weights[principal]; weight > 0 {
+		return float64(weight)
+	}
+	return 1
+}
+
+// errQueueFull is returned by acquire for a principal at MaxQueued.
+var errQueueFull = errors.New("scheduler queue full")
+
+// acquire waits for a slot for a call of principal and returns how long it
+// waited by clock. The caller releases the slot.
+func (s *scheduler) acquire(ctx context.Context, principal string, clock func() time.Time) (time.Duration, error) {
+	s.mu.Lock()
+	if s.running < s.config.MaxConcurrent && s.queue.Len() == 0 {
+		s.running++
+		s.mu.Unlock()
+		return 0, nil
+	}
+	if s.config.MaxQueued > 0 && s.queued[principal] >= s.config.MaxQueued {
+		s.mu.Unlock()
+		return 0, errQueueFull
+	}
+	if s.finish == nil {
+		s.finish = make(map[string]float64)
+		s.queued = make(map[string]int)
+	}
+	start := max(s.virtual, s.finish[principal])
+	w := &waiter{
+		principal: principal,
+		start:     start,
+		finish:    start + 1/s.weight(principal),
+		seq:       s.seq,
+		ready:     make(chan struct{}),
+	}
+	s.seq++
+	s.finish[principal] = w.finish
+	s.queued[principal]++
+	heap.Push(&s.queue, w)
+	s.mu.Unlock()
+
+	queuedAt := clock()
+	select {
+	case <-w.ready:
+		return clock().Sub(queuedAt), nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		if w.index >= 0 {
+			heap.Remove(&s.queue, w.index)
+			s.queued[principal]--
+			s.mu.Unlock()
+		} else {
+			// the slot was handed over meanwhile
+			s.mu.Unlock()
+			s.release()
+		}
+		return clock().Sub(queuedAt), ctx.Err()
+	}
+}
+
+// release hands the slot to the queued call with the smallest finish tag.
+func (s *scheduler) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.queue.Len() == 0 {
+		s.running--
+		return
+	}
+	w := heap.Pop(&s.queue).(*waiter)
+	s.queued[w.principal]--
+	s.virtual = w.start
+	if s.queue.Len() == 0 {
+		// the backlog is over, past usage does not count against anyone
+		s.virtual = 0
+		clear(s.finish)
+	}
+	close(w.ready)
+}
+
+func (s *Server) schedulerMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	if s.scheduler == nil {
+		return next
+	}
+	queueMetrics, _ := s.metrics.(QueueMetrics)
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		principal := principalLabel(ctx)
+		wait, err := s.scheduler.acquire(ctx, principal, s.clock)
+		if err == errQueueFull {
+			return nil, localizedError(ctx, "error.scheduler_queue_full", s.scheduler.config.MaxQueued)
+		}
+		if err != nil {
+			return nil, err
+		}
+		defer s.scheduler.release()
+		if wait > 0 && queueMetrics != nil {
+			queueMetrics.ObserveQueueWait(principal, wait)
+		}
+		return next(ctx, request)
+	}
+}
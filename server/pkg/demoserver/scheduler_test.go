@@ -0,0 +1,114 @@
+package demoserver
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// queue makes principal wait for a slot of s, returning once the call is
+// queued. The call reports its principal on order when it gets the slot.
+func queue(t *testing.T, s *scheduler, principal string, order chan<- string) {
+	t.Helper()
+	s.mu.Lock()
+	queued := s.queue.Len()
+	s.mu.Unlock()
+	go func() {
+		if _, err := s.acquire(context.Background(), principal, time.Now); err != nil {
+			t.Errorf("%s: %v", principal, err)
+		}
+		order <- principal
+	}()
+	for deadline := time.Now().Add(time.Second); ; {
+		s.mu.Lock()
+		done := s.queue.Len() > queued
+		s.mu.Unlock()
+		if done {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s was not queued", principal)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSchedulerWeightedFairness(t *testing.T) {
+	s := &scheduler{config: SchedulerConfig{MaxConcurrent: 1, Weights: map[string]int{"alice": 2}}}
+	if _, err := s.acquire(context.Background(), "busy", time.Now); err != nil {
+		t.Fatal(err)
+	}
+	order := make(chan string)
+	for range 4 {
+		queue(t, s, "alice", order)
+		queue(t, s, "bob", order)
+	}
+
+	var got []string
+	for range 8 {
+		// each call hands its slot to the next one
+		s.release()
+		got = append(got, <-order)
+	}
+	s.release()
+	// alice of weight 2 runs twice as many calls as bob while both wait
+	if want := "alice bob alice alice bob alice bob bob"; strings.Join(got, " ") != want {
+		t.Errorf("order %v, want %s", got, want)
+	}
+	if s.running != 0 || s.queue.Len() != 0 {
+		t.Errorf("%d running and %d queued after the backlog", s.running, s.queue.Len())
+	}
+}
+
+func TestSchedulerQueueFull(t *testing.T) {
+	s := &scheduler{config: SchedulerConfig{MaxConcurrent: 1, MaxQueued: 1}}
+	if _, err := s.acquire(context.Background(), "busy", time.Now); err != nil {
+		t.Fatal(err)
+	}
+	order := make(chan string)
+	queue(t, s, "alice", order)
+	if _, err := s.acquire(context.Background(), "alice", time.Now); !errors.Is(err, errQueueFull) {
+		t.Errorf("second queued call of alice: error %v, want %v", err, errQueueFull)
+	}
+	// the limit is per principal
+	queue(t, s, "bob", order)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// the wait is measured on the clock of the server
+	now := time.Unix(1700000000, 0)
+	clock := func() time.Time {
+		now = now.Add(time.Minute)
+		return now
+	}
+	if wait, err := s.acquire(ctx, "carol", clock); !errors.Is(err, context.Canceled) || wait != time.Minute {
+		t.Errorf("canceled call: waited %s with error %v, want 1m0s and %v", wait, err, context.Canceled)
+	}
+	for _, want := range []string{"alice", "bob"} {
+		s.release()
+		if got := <-order; got != want {
+			t.Errorf("ran %s, want %s", got, want)
+		}
+	}
+	s.release()
+	if s.running != 0 || s.queue.Len() != 0 || s.queued["carol"] != 0 {
+		t.Errorf("%d running and %d queued after the backlog", s.running, s.queue.Len())
+	}
+}
+
+// TestPrincipalLabel names bearer tokens like the policy input and the
+// forward-auth headers, so the weights can be copied from their logs.
+func TestPrincipalLabel(t *testing.T) {
+	ctx := context.WithValue(context.Background(), authKey{}, "Bearer sk-1234")
+	if got, want := principalLabel(ctx), tokenFingerprint("Bearer sk-1234"); got != want {
+		t.Errorf("principalLabel = %s, want %s", got, want)
+	}
+	if got := principalLabel(withPrincipal(ctx, Principal{Name: "alice"})); got != "alice" {
+		t.Errorf("principalLabel of an OIDC principal = %s, want alice", got)
+	}
+	if got := principalLabel(context.Background()); got != "anonymous" {
+		t.Errorf("principalLabel without a token = %s, want anonymous", got)
+	}
+}
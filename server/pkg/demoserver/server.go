@@ -0,0 +1,380 @@
+// Package demoserver provides the go-mcp demo tools server so it can be
+// embedded in other Go programs.
+package demoserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/wagnerjt/go-mcp/server/pkg/mcpschema"
+)
+
+const (
+	TransportStdio string = "stdio"
+	TransportSSE   string = "sse"
+	TransportHTTP  string = "http"
+)
+
+type authKey struct{}
+
+// Server is the demo MCP server along with the state shared by its tools.
+type Server struct {
+	mcpServer *server.MCPServer
+	handler   http.Handler
+	catalog   *Catalog
+	rollouts  []*Rollout
+
+	transport      string
+	toolSets       []ToolSet
+	extraTools     []server.ServerTool
+	extraResources []Resource
+	authMiddleware func(http.Handler) http.Handler
+	tokensFile     *tokensFile
+	reloadMu       sync.Mutex
+	oidc           *oidcVerifier
+	forwardAuth    bool
+	guestTools     []string
+	history        callHistory
+	approvals      approvals
+	policy         *PolicyConfig
+	demo           *demoMode
+	vector         *VectorSearchConfig
+	docs           *documents
+	prompts        *prompts
+	toolExamples   map[string][]ToolExample
+	outputSchemas  map[string]json.RawMessage
+	// outputValidators are the compiled outputSchemas with WithSpecValidation
+	outputValidators map[string]*mcpschema.Definition
+	git              *GitConfig
+	k8s              *kubeClient
+	docker           *dockerClient
+	notifications    *notifications
+	registry         *registry
+	outbox           *outbox
+	scheduler        *scheduler
+	sandbox          *SandboxConfig
+	sandboxHelper    bool
+	sandboxTool      string
+	tokenizer        Tokenizer
+	tokenEstimates   bool
+	postProcessors   postProcessors
+	argumentRules    argumentRules
+	features         sessionFeatures
+	clientCatalogs   clientCatalogs
+	tracer           *tracer
+	metrics          Metrics
+	readiness        readiness
+	adminToken       string
+	drainTimeout     time.Duration
+	maintenance      maintenance
+	httpServer       *http.Server
+	sse              sseConfig
+	debugErrors      bool
+	errorData        errorDataStore
+	requestLog       requestLog
+	accessRules      AccessRules
+	access           accessControl
+	specValidation   bool
+	chunkSize        int
+	compression      compressionConfig
+	spec             *mcpschema.Schema
+	registered       registeredTools
+
+	canaryPercent    int
+	canaryPrincipals []string
+	locale           string
+	localesDir       string
+	clock            func() time.Time
+	rand             *lockedRand
+}
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithCanary routes percent% of the principals, plus every listed principal,
+// to the canary implementation of tools under rollout.
+func WithCanary(percent int, principals ...string) Option {
+	return func(s *Server) {
+		s.canaryPercent = percent
+		s.canaryPrincipals = principals
+	}
+}
+
+// WithLocale sets the default locale of tool descriptions and errors.
+func WithLocale(locale string) Option {
+	return func(s *Server) {
+		s.locale = locale
+	}
+}
+
+// WithLocalesDir loads additional <locale>.json message catalogs from dir.
+func WithLocalesDir(dir string) Option {
+	return func(s *Server) {
+		s.localesDir = dir
+	}
+}
+
+// New creates the demo server with all of its tools registered.
+func New(opts ...Option) (*Server, error) {
+	s := &Server{
+		locale:       DefaultLocale,
+		transport:    TransportSSE,
+		toolSets:     DefaultToolSets,
+		drainTimeout: DefaultDrainTimeout,
+		clock:        time.Now,
+		tokenizer:    HeuristicTokenizer{},
+		chunkSize:    DefaultChunkSize,
+		history:      callHistory{size: DefaultHistorySize},
+		compression:  compressionConfig{minSize: DefaultCompressionMinSize, maxBody: DefaultMaxDecompressedBody},
+		requestLog: requestLog{
+			sampleRate: DefaultRequestLogSampleRate,
+			maxBody:    DefaultMaxCapturedBody,
+		},
+		sse: sseConfig{
+			heartbeat:    DefaultSSEHeartbeat,
+			writeTimeout: DefaultSSEWriteTimeout,
+		},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.requestLog.rand = s.rand
+
+	catalog, err := LoadCatalog(s.locale, s.localesDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load locales: %w", err)
+	}
+	s.catalog = catalog
+	if err := s.SetAccessRules(s.accessRules); err != nil {
+		return nil, err
+	}
+	if s.specValidation {
+		if s.spec, err = mcpschema.Load(); err != nil {
+			return nil, err
+		}
+	}
+	if s.oidc != nil {
+		if err := s.oidc.validateConfig(); err != nil {
+			return nil, err
+		}
+	}
+	if s.vector != nil && s.vector.Embedder == nil {
+		return nil, fmt.Errorf("vector search requires an embedder")
+	}
+	if s.git != nil {
+		if err := s.git.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if s.k8s != nil {
+		if err := s.k8s.load(); err != nil {
+			return nil, err
+		}
+	}
+	if s.docker != nil {
+		if err := s.docker.load(); err != nil {
+			return nil, err
+		}
+	}
+	if s.approvals.timeout > 0 && s.adminToken == "" {
+		return nil, fmt.Errorf("approvals are decided through the admin API, which requires an admin token")
+	}
+	if s.tokensFile != nil {
+		if err := s.tokensFile.load(); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.postProcessors.load(); err != nil {
+		return nil, err
+	}
+	if err := s.argumentRules.load(); err != nil {
+		return nil, err
+	}
+	if err := s.clientCatalogs.load(); err != nil {
+		return nil, err
+	}
+	if s.outbox != nil {
+		if err := s.outbox.load(); err != nil {
+			return nil, err
+		}
+	}
+	if s.sandbox != nil {
+		if err := s.sandbox.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if s.registry != nil {
+		if err := s.registry.validate(s.transport); err != nil {
+			return nil, err
+		}
+	}
+	if s.demo != nil {
+		if err := s.demo.generateKey(); err != nil {
+			return nil, err
+		}
+	}
+
+	hooks := &server.Hooks{}
+	s.registerMaintenanceHooks(hooks)
+	s.registerRequestLogHooks(hooks)
+	s.registerSpecHooks(hooks)
+	s.registerResourceHooks(hooks)
+	s.registerHistoryHooks(hooks)
+	s.registerTokenHooks(hooks)
+	s.registerFeatureHooks(hooks)
+	s.registerOutboxHooks(hooks)
+
+	serverOpts := []server.ServerOption{
+		server.WithToolCapabilities(true),
+		server.WithResourceCapabilities(false, s.docs != nil),
+		server.WithPromptCapabilities(s.prompts != nil),
+		server.WithLogging(),
+		server.WithHooks(hooks),
+		server.WithToolFilter(s.localizeTools),
+		server.WithToolFilter(s.filterClientTools),
+		server.WithToolFilter(s.filterGuestTools),
+		server.WithToolFilter(s.filterDemoTools),
+	}
+	if s.sandboxHelper {
+		serverOpts = append(serverOpts,
+			server.WithToolHandlerMiddleware(s.catalogMiddleware),
+			server.WithToolHandlerMiddleware(s.sandboxCallerMiddleware))
+	} else {
+		serverOpts = append(serverOpts, s.toolMiddleware()...)
+	}
+	s.mcpServer = server.NewMCPServer(ServerName, ServerVersion, serverOpts...)
+	s.registerTools()
+	for _, tool := range s.extraTools {
+		s.AddTool(tool.Tool, tool.Handler)
+	}
+	if err := s.compileOutputSchemas(); err != nil {
+		return nil, err
+	}
+	s.registerResources()
+	if s.docs != nil {
+		if err := s.refreshDocuments(); err != nil {
+			return nil, err
+		}
+	}
+	if s.prompts != nil {
+		if err := s.refreshPrompts(); err != nil {
+			return nil, err
+		}
+	}
+	s.mcpServer.AddNotificationHandler("notification", handleNotification)
+
+	handler, err := s.buildHandler()
+	if err != nil {
+		return nil, err
+	}
+	s.handler = handler
+
+	return s, nil
+}
+
+// MCPServer returns the underlying MCP server.
+func (s *Server) MCPServer() *server.MCPServer {
+	return s.mcpServer
+}
+
+// Handler returns the http.Handler of the configured transport, nil for stdio.
+func (s *Server) Handler() http.Handler {
+	return s.handler
+}
+
+func withAuthKey(ctx context.Context, auth string) context.Context {
+	return context.WithValue(ctx, authKey{}, auth)
+}
+
+func authFromRequest(ctx context.Context, r *http.Request) context.Context {
+	return withAuthKey(ctx, r.Header.Get("Authorization"))
+}
+
+// tokenFromContext extracts the auth token from the context.
+// This can be used by tools to extract the token regardless of the
+// transport being used by the server.
+func tokenFromContext(ctx context.Context) (string, error) {
+	auth, ok := ctx.Value(authKey{}).(string)
+	if !ok {
+		return "", fmt.Errorf("missing auth")
+	}
+	return auth, nil
+}
+
+// ContextFromRequest copies everything the tools need from the HTTP request
+// into the context.
+func (s *Server) ContextFromRequest(ctx context.Context, r *http.Request) context.Context {
+	ctx = authFromRequest(ctx, r)
+	ctx = s.localeFromRequest(ctx, r)
+	return versionPinsFromRequest(ctx, r)
+}
+
+// Serve runs the server over the configured transport, listening on port
+// for the network transports.
+func (s *Server) Serve(port string) error {
+	go s.awaitReadiness(context.Background())
+	if s.docs != nil {
+		go s.watchDocuments(context.Background())
+	}
+	if s.prompts != nil {
+		go s.watchPrompts(context.Background())
+	}
+	if s.tracer != nil {
+		go s.tracer.run(context.Background())
+	}
+	if s.registry != nil {
+		go s.runRegistry()
+	}
+	if s.demo != nil {
+		go s.pruneDemoClients(context.Background())
+	}
+	if s.transport == TransportStdio {
+		return server.ServeStdio(s.mcpServer)
+	}
+	s.httpServer = &http.Server{Addr: ":" + port, Handler: s.handler}
+	log.Printf("%s server listening on port %s", strings.ToUpper(s.transport), port)
+	if err := s.httpServer.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// toolMiddleware are the middleware of the tool calls, the first is the
+// outermost. A sandbox helper runs without, the sandboxing server applied them
+// to its calls already.
+func (s *Server) toolMiddleware() []server.ServerOption {
+	middleware := []server.ServerOption{
+		server.WithToolHandlerMiddleware(s.toolTracingMiddleware),
+		server.WithToolHandlerMiddleware(s.inFlightMiddleware),
+		server.WithToolHandlerMiddleware(s.catalogMiddleware),
+		// records the errors as the client sees them
+		server.WithToolHandlerMiddleware(s.historyMiddleware),
+		server.WithToolHandlerMiddleware(s.errorMiddleware),
+		server.WithToolHandlerMiddleware(s.argumentMiddleware),
+		server.WithToolHandlerMiddleware(s.guestMiddleware),
+		server.WithToolHandlerMiddleware(s.clientCatalogMiddleware),
+		server.WithToolHandlerMiddleware(s.demoToolMiddleware),
+		server.WithToolHandlerMiddleware(s.policyMiddleware),
+		server.WithToolHandlerMiddleware(s.approvalMiddleware),
+		server.WithToolHandlerMiddleware(s.schedulerMiddleware),
+		server.WithToolHandlerMiddleware(s.chunkMiddleware),
+	}
+	middleware = append(middleware, server.WithToolHandlerMiddleware(s.tokenMiddleware))
+	// chunks, token estimates and watermarks are of the processed results
+	middleware = append(middleware, server.WithToolHandlerMiddleware(s.postProcessMiddleware))
+	if s.metrics != nil {
+		middleware = append(middleware, server.WithToolHandlerMiddleware(s.metricsMiddleware))
+	}
+	middleware = append(middleware, server.WithToolHandlerMiddleware(s.outputSchemaMiddleware))
+	// hands the processed calls to the helper, so a sandboxed tool is only
+	// executed there
+	middleware = append(middleware, server.WithToolHandlerMiddleware(s.sandboxMiddleware))
+	return middleware
+}
@@ -0,0 +1,88 @@
+package demoserver
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/wagnerjt/go-mcp/server/pkg/mcpschema"
+)
+
+// WithSpecValidation validates the outgoing messages against the MCP schema
+// and logs the spec violations. The results are validated on every transport,
+// the JSON-RPC envelopes on the network transports. It costs a decode of
+// every message, so it is meant for development and CI.
+func WithSpecValidation(enabled bool) Option {
+	return func(s *Server) {
+		s.specValidation = enabled
+	}
+}
+
+func (s *Server) registerSpecHooks(hooks *server.Hooks) {
+	if s.spec == nil {
+		return
+	}
+	hooks.AddOnSuccess(func(ctx context.Context, id any, method mcp.MCPMethod, message any, result any) {
+		if err := s.spec.ValidateResult(string(method), result); err != nil {
+			log.Printf("Spec violation in the %s result %v: %v", method, id, err)
+		}
+	})
+}
+
+// specMiddleware validates the JSON-RPC messages written to the response,
+// plain JSON or SSE events.
+func (s *Server) specMiddleware(next http.Handler) http.Handler {
+	if s.spec == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(&specWriter{ResponseWriter: w, spec: s.spec}, r)
+	})
+}
+
+type specWriter struct {
+	http.ResponseWriter
+	spec *mcpschema.Schema
+}
+
+func (w *specWriter) Write(p []byte) (int, error) {
+	for _, message := range jsonMessages(p) {
+		if err := w.spec.ValidateMessage(message, ""); err != nil {
+			log.Printf("Spec violation in %s: %v", message, err)
+		}
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush implements http.Flusher, which the SSE server requires.
+func (w *specWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *specWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// jsonMessages extracts the JSON objects of a chunk of a plain JSON response
+// or of an SSE stream, skipping everything else such as the endpoint event.
+func jsonMessages(p []byte) [][]byte {
+	trimmed := bytes.TrimSpace(p)
+	if bytes.HasPrefix(trimmed, []byte("{")) {
+		return [][]byte{trimmed}
+	}
+	var messages [][]byte
+	for _, line := range bytes.Split(p, []byte("\n")) {
+		if !bytes.HasPrefix(line, sseDataPrefix) {
+			continue
+		}
+		if payload := bytes.TrimSpace(bytes.TrimPrefix(line, sseDataPrefix)); bytes.HasPrefix(payload, []byte("{")) {
+			messages = append(messages, payload)
+		}
+	}
+	return messages
+}
@@ -0,0 +1,198 @@
+package demoserver
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	// DefaultSSEHeartbeat is the interval of the heartbeat comments on SSE
+	// streams, below the usual 30-60s idle timeouts of proxies.
+	DefaultSSEHeartbeat = 15 * time.Second
+	// DefaultSSEWriteTimeout is how long a write to an SSE stream may block
+	// before the client is considered gone.
+	DefaultSSEWriteTimeout = 30 * time.Second
+
+	// sseProxyPadding is the size of the comment opening the streams in
+	// proxy-compatibility mode, some proxies buffer the first few KB.
+	sseProxyPadding = 2048
+)
+
+// the comments are written on every stream and beat, they are allocated once
+var (
+	sseHeartbeatComment    = []byte(": heartbeat\n\n")
+	sseProxyPaddingComment = []byte(":" + strings.Repeat(" ", sseProxyPadding) + "\n\n")
+)
+
+type sseConfig struct {
+	heartbeat    time.Duration
+	ping         time.Duration
+	writeTimeout time.Duration
+	proxyCompat  bool
+}
+
+// WithSSEHeartbeat sends a comment on idle SSE streams every interval, zero
+// disables the heartbeat.
+func WithSSEHeartbeat(interval time.Duration) Option {
+	return func(s *Server) {
+		s.sse.heartbeat = interval
+	}
+}
+
+// WithSSEPing sends an MCP ping request on SSE streams every interval, which
+// unlike the heartbeat is answered by the client. Zero disables it.
+func WithSSEPing(interval time.Duration) Option {
+	return func(s *Server) {
+		s.sse.ping = interval
+	}
+}
+
+// WithSSEWriteTimeout closes SSE streams whose writes block for longer than
+// timeout, i.e. when the client stopped reading. Zero disables it.
+func WithSSEWriteTimeout(timeout time.Duration) Option {
+	return func(s *Server) {
+		s.sse.writeTimeout = timeout
+	}
+}
+
+// WithSSEProxyCompat disables buffering and transformations by proxies on the
+// SSE streams and opens them with a padding comment that pushes through
+// proxies buffering the start of responses.
+func WithSSEProxyCompat(enabled bool) Option {
+	return func(s *Server) {
+		s.sse.proxyCompat = enabled
+	}
+}
+
+func (s *Server) sseServerOptions() []server.SSEOption {
+	opts := []server.SSEOption{server.WithSSEContextFunc(s.ContextFromRequest)}
+	if s.sse.ping > 0 {
+		opts = append(opts, server.WithKeepAliveInterval(s.sse.ping))
+	}
+	return opts
+}
+
+// sseStreamMiddleware applies the heartbeat, write timeout and proxy settings
+// to the SSE streams and counts them for draining.
+func (s *Server) sseStreamMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/sse" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		s.maintenance.streams.Add(1)
+		defer s.maintenance.streams.Add(-1)
+
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		stream := &sseStream{
+			ResponseWriter: w,
+			controller:     http.NewResponseController(w),
+			config:         s.sse,
+			remote:         r.RemoteAddr,
+			ctx:            ctx,
+			cancel:         cancel,
+		}
+		if s.sse.heartbeat > 0 {
+			go stream.heartbeat(ctx)
+		}
+		next.ServeHTTP(stream, r.WithContext(ctx))
+	})
+}
+
+// sseStream serializes the writes of the SSE server and the heartbeat and
+// ends the stream, by cancelling its request context, once a write fails.
+type sseStream struct {
+	http.ResponseWriter
+	controller *http.ResponseController
+	config     sseConfig
+	remote     string
+	ctx        context.Context
+	cancel     context.CancelFunc
+
+	mu      sync.Mutex
+	started bool
+	stopped bool
+}
+
+func (w *sseStream) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.write(p)
+}
+
+func (w *sseStream) write(p []byte) (int, error) {
+	if !w.started {
+		w.started = true
+		if w.config.proxyCompat {
+			w.Header().Set("Cache-Control", "no-cache, no-transform")
+			w.Header().Set("X-Accel-Buffering", "no")
+			if _, err := w.write(sseProxyPaddingComment); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if w.config.writeTimeout > 0 {
+		// not every ResponseWriter supports deadlines, they are best effort
+		_ = w.controller.SetWriteDeadline(time.Now().Add(w.config.writeTimeout))
+	}
+	n, err := w.ResponseWriter.Write(p)
+	if err != nil {
+		w.stop(err)
+	}
+	return n, err
+}
+
+// Flush implements http.Flusher, which the SSE server requires.
+func (w *sseStream) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.flush()
+}
+
+func (w *sseStream) flush() {
+	if err := w.controller.Flush(); err != nil {
+		w.stop(err)
+	}
+}
+
+func (w *sseStream) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *sseStream) heartbeat(ctx context.Context) {
+	ticker := time.NewTicker(w.config.heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			w.mu.Lock()
+			if !w.stopped {
+				if _, err := w.write(sseHeartbeatComment); err == nil {
+					w.flush()
+				}
+			}
+			w.mu.Unlock()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (w *sseStream) stop(err error) {
+	if w.stopped {
+		return
+	}
+	w.stopped = true
+	// writes also fail once the client disconnected, that is no news
+	if w.ctx.Err() == nil {
+		log.Printf("SSE client %s stopped reading, closing the stream: %v", w.remote, err)
+	}
+	w.cancel()
+}
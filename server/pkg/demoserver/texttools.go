@@ -0,0 +1,563 @@
+package demoserver
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	REGEX_EXTRACT ToolName = "regex_extract"
+	DIFF_TEXT     ToolName = "diff_text"
+	QUERY_JSON    ToolName = "query_json"
+	ENCODE_TEXT   ToolName = "encode_text"
+	HASH_TEXT     ToolName = "hash_text"
+
+	// DefaultMaxMatches is how many matches regex_extract returns by
+	// default.
+	DefaultMaxMatches = 100
+
+	maxTextLength  = 1 << 20
+	maxDiffLines   = 2000
+	maxPatternSize = 1000
+)
+
+// hashes are the algorithms of hash_text, md5 and sha1 for checksums only.
+var hashes = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+func (s *Server) registerTextTools() {
+	s.AddTool(mcp.NewTool(string(REGEX_EXTRACT),
+		mcp.WithDescription("Extracts the matches of a regular expression (RE2 syntax) and their groups from a text"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithString("pattern",
+			mcp.Description("RE2 regular expression, i.e. (?i)order #(?P<id>\\d+)"),
+			mcp.Required(),
+		),
+		mcp.WithString("text",
+			mcp.Description("Text to search"),
+			mcp.Required(),
+		),
+		mcp.WithNumber("max_matches",
+			mcp.Description("Maximum number of matches, 100 by default"),
+			mcp.Min(1),
+		),
+	), handleRegexExtract)
+	s.addToolExamples(string(REGEX_EXTRACT), ToolExample{
+		Arguments: map[string]any{"pattern": "order #(?P<id>\\d+)", "text": "order #12 and order #345"},
+		Result:    `{"count":2,"matches":[{"match":"order #12","start":0,"end":9,"groups":{"id":"12"}},{"match":"order #345","start":14,"end":24,"groups":{"id":"345"}}],"truncated":false}`,
+	})
+	s.addOutputSchema(string(REGEX_EXTRACT), json.RawMessage(`{
+		"type": "object",
+		"required": ["count", "matches", "truncated"],
+		"additionalProperties": false,
+		"properties": {
+			"count": {"type": "integer", "minimum": 0},
+			"truncated": {"type": "boolean"},
+			"matches": {
+				"type": "array",
+				"items": {
+					"type": "object",
+					"required": ["match", "start", "end"],
+					"additionalProperties": false,
+					"properties": {
+						"match": {"type": "string"},
+						"start": {"type": "integer", "minimum": 0},
+						"end": {"type": "integer", "minimum": 0},
+						"groups": {"type": "object", "additionalProperties": {"type": "string"}}
+					}
+				}
+			}
+		}
+	}`))
+
+	s.AddTool(mcp.NewTool(string(DIFF_TEXT),
+		mcp.WithDescription("Compares two texts line by line and returns a unified diff"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithString("old",
+			mcp.Description("Original text"),
+			mcp.Required(),
+		),
+		mcp.WithString("new",
+			mcp.Description("Changed text"),
+			mcp.Required(),
+		),
+		mcp.WithNumber("context",
+			mcp.Description("Unchanged lines around each change, 3 by default"),
+			mcp.Min(0),
+		),
+	), handleDiffText)
+
+	s.AddTool(mcp.NewTool(string(QUERY_JSON),
+		mcp.WithDescription("Queries a JSON document with a jq-like path, i.e. .items[].name or .users[0][\"e-mail\"]"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithString("json",
+			mcp.Description("JSON document"),
+			mcp.Required(),
+		),
+		mcp.WithString("query",
+			mcp.Description("Path of .field, [\"field\"], [index], negative indexes from the end, and [] or .[] iterating over arrays and objects"),
+			mcp.Required(),
+		),
+	), handleQueryJSON)
+
+	s.AddTool(mcp.NewTool(string(ENCODE_TEXT),
+		mcp.WithDescription("Encodes or decodes a text as base64, base64url or hex"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithString("text",
+			mcp.Description("Text to encode or decode"),
+			mcp.Required(),
+		),
+		mcp.WithString("encoding",
+			mcp.Description("Encoding, base64 by default"),
+			mcp.Enum("base64", "base64url", "hex"),
+		),
+		mcp.WithBoolean("decode",
+			mcp.Description("Decode the text instead of encoding it"),
+		),
+	), handleEncodeText)
+
+	s.AddTool(mcp.NewTool(string(HASH_TEXT),
+		mcp.WithDescription("Hashes a text, optionally as an HMAC with a key"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithString("text",
+			mcp.Description("Text to hash"),
+			mcp.Required(),
+		),
+		mcp.WithString("algorithm",
+			mcp.Description("Hash algorithm, sha256 by default. md5 and sha1 are only fit for checksums"),
+			mcp.Enum("md5", "sha1", "sha256", "sha512"),
+		),
+		mcp.WithString("hmac_key",
+			mcp.Description("Key of an HMAC instead of a plain hash"),
+		),
+	), handleHashText)
+}
+
+// requireText returns the string argument name, bounded by maxTextLength.
+func requireText(ctx context.Context, request mcp.CallToolRequest, name string) (string, error) {
+	text, ok := request.GetArguments()[name].(string)
+	if !ok {
+		return "", localizedError(ctx, "error.invalid_argument", name, "a string is required")
+	}
+	if len(text) > maxTextLength {
+		return "", localizedError(ctx, "error.invalid_argument", name, fmt.Sprintf("longer than %d bytes", maxTextLength))
+	}
+	return text, nil
+}
+
+func jsonResult(v any) (*mcp.CallToolResult, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the result: %w", err)
+	}
+	return mcp.NewToolResultText(string(body)), nil
+}
+
+// RegexMatch is a match of regex_extract, Groups holds the submatches by
+// name, or by number for unnamed groups.
+type RegexMatch struct {
+	Match  string            `json:"match"`
+	Start  int               `json:"start"`
+	End    int               `json:"end"`
+	Groups map[string]string `json:"groups,omitempty"`
+}
+
+func handleRegexExtract(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	pattern, err := requireText(ctx, request, "pattern")
+	if err != nil {
+		return nil, err
+	}
+	text, err := requireText(ctx, request, "text")
+	if err != nil {
+		return nil, err
+	}
+	if len(pattern) > maxPatternSize {
+		return nil, localizedError(ctx, "error.invalid_argument", "pattern", fmt.Sprintf("longer than %d bytes", maxPatternSize))
+	}
+	// RE2 runs in linear time, so patterns cannot backtrack catastrophically
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, localizedError(ctx, "error.invalid_argument", "pattern", err.Error())
+	}
+	limit := request.GetInt("max_matches", DefaultMaxMatches)
+	if limit < 1 {
+		limit = DefaultMaxMatches
+	}
+
+	names := re.SubexpNames()
+	matches := make([]RegexMatch, 0)
+	for _, indexes := range re.FindAllStringSubmatchIndex(text, limit) {
+		match := RegexMatch{Match: text[indexes[0]:indexes[1]], Start: indexes[0], End: indexes[1]}
+		for group := 1; group < len(names); group++ {
+			start, end := indexes[2*group], indexes[2*group+1]
+			if start < 0 {
+				continue
+			}
+			if match.Groups == nil {
+				match.Groups = make(map[string]string)
+			}
+			name := names[group]
+			if name == "" {
+				name = strconv.Itoa(group)
+			}
+			match.Groups[name] = text[start:end]
+		}
+		matches = append(matches, match)
+	}
+	return jsonResult(map[string]any{"matches": matches, "count": len(matches), "truncated": len(matches) == limit})
+}
+
+func handleDiffText(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	old, err := requireText(ctx, request, "old")
+	if err != nil {
+		return nil, err
+	}
+	changed, err := requireText(ctx, request, "new")
+	if err != nil {
+		return nil, err
+	}
+	oldLines, newLines := splitLines(old), splitLines(changed)
+	if len(oldLines) > maxDiffLines || len(newLines) > maxDiffLines {
+		return nil, localizedError(ctx, "error.invalid_argument", "old", fmt.Sprintf("texts longer than %d lines cannot be diffed", maxDiffLines))
+	}
+	contextLines := request.GetInt("context", 3)
+	if contextLines < 0 {
+		contextLines = 3
+	}
+	diff := unifiedDiff(oldLines, newLines, contextLines)
+	if diff == "" {
+		return mcp.NewToolResultText("The texts are identical"), nil
+	}
+	return mcp.NewToolResultText("--- old\n+++ new\n" + diff), nil
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// diffOp is a line of the edit script, ' ' kept, '-' removed or '+' added.
+type diffOp struct {
+	kind byte
+	line string
+}
+
+// editScript turns a into b through their longest common subsequence of
+// lines.
+func editScript(a, b []string) []diffOp {
+	// lcs[i][j] is the LCS length of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	ops := make([]diffOp, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			// removals come first, as in diff -u
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+	return ops
+}
+
+// unifiedDiff formats the hunks of the edit script with context lines
+// around the changes.
+func unifiedDiff(a, b []string, context int) string {
+	ops := editScript(a, b)
+	var out strings.Builder
+	for start := 0; start < len(ops); {
+		// find the next change
+		for start < len(ops) && ops[start].kind == ' ' {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+		// extend the hunk while changes are within 2*context lines
+		end := start
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			next := end
+			for next < len(ops) && ops[next].kind == ' ' {
+				next++
+			}
+			if next == len(ops) || next-end > 2*context {
+				break
+			}
+			end = next
+		}
+		from, to := max(0, start-context), min(len(ops), end+context)
+
+		// line numbers of the hunk in a and b
+		oldStart, newStart := 1, 1
+		for _, op := range ops[:from] {
+			if op.kind != '+' {
+				oldStart++
+			}
+			if op.kind != '-' {
+				newStart++
+			}
+		}
+		var oldCount, newCount int
+		for _, op := range ops[from:to] {
+			if op.kind != '+' {
+				oldCount++
+			}
+			if op.kind != '-' {
+				newCount++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(oldStart, oldCount), hunkRange(newStart, newCount))
+		for _, op := range ops[from:to] {
+			out.WriteByte(op.kind)
+			out.WriteString(op.line)
+			out.WriteByte('\n')
+		}
+		start = to
+	}
+	return out.String()
+}
+
+func hunkRange(start, count int) string {
+	if count == 0 {
+		// an empty range names the line before it
+		return fmt.Sprintf("%d,0", start-1)
+	}
+	if count == 1 {
+		return strconv.Itoa(start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+func handleQueryJSON(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	document, err := requireText(ctx, request, "json")
+	if err != nil {
+		return nil, err
+	}
+	query, err := requireText(ctx, request, "query")
+	if err != nil {
+		return nil, err
+	}
+	var value any
+	if err := json.Unmarshal([]byte(document), &value); err != nil {
+		return nil, localizedError(ctx, "error.invalid_argument", "json", err.Error())
+	}
+	results, err := QueryJSON(value, query)
+	if err != nil {
+		return nil, localizedError(ctx, "error.invalid_argument", "query", err.Error())
+	}
+	return jsonResult(map[string]any{"results": results})
+}
+
+// QueryJSON evaluates the jq-like path query on value and returns every
+// value it selects. Missing fields and indexes select nothing rather than
+// failing.
+func QueryJSON(value any, query string) ([]any, error) {
+	current := []any{value}
+	rest := strings.TrimSpace(query)
+	if rest == "" || rest == "." {
+		return current, nil
+	}
+	if !strings.HasPrefix(rest, ".") && !strings.HasPrefix(rest, "[") {
+		return nil, fmt.Errorf("queries start with . or [")
+	}
+	for rest != "" {
+		var next []any
+		switch {
+		case strings.HasPrefix(rest, ".["):
+			rest = rest[1:]
+		case strings.HasPrefix(rest, "."):
+			end := 1
+			for end < len(rest) && rest[end] != '.' && rest[end] != '[' {
+				end++
+			}
+			field := rest[1:end]
+			if field == "" {
+				return nil, fmt.Errorf("empty field name")
+			}
+			rest = rest[end:]
+			for _, v := range current {
+				if object, ok := v.(map[string]any); ok {
+					if child, ok := object[field]; ok {
+						next = append(next, child)
+					}
+				}
+			}
+			current = next
+		case strings.HasPrefix(rest, "["):
+			end := strings.Index(rest, "]")
+			if strings.HasPrefix(rest, `["`) {
+				// the closing quote comes first, field names may contain ]
+				quoted, err := strconv.QuotedPrefix(rest[1:])
+				if err != nil {
+					return nil, fmt.Errorf("invalid quoted field in %s", rest)
+				}
+				field, _ := strconv.Unquote(quoted)
+				if !strings.HasPrefix(rest[1+len(quoted):], "]") {
+					return nil, fmt.Errorf("missing ] after %s", quoted)
+				}
+				rest = rest[2+len(quoted):]
+				for _, v := range current {
+					if object, ok := v.(map[string]any); ok {
+						if child, ok := object[field]; ok {
+							next = append(next, child)
+						}
+					}
+				}
+				current = next
+				continue
+			}
+			if end < 0 {
+				return nil, fmt.Errorf("missing ] in %s", rest)
+			}
+			selector := strings.TrimSpace(rest[1:end])
+			rest = rest[end+1:]
+			if selector == "" {
+				for _, v := range current {
+					switch v := v.(type) {
+					case []any:
+						next = append(next, v...)
+					case map[string]any:
+						for _, key := range slices.Sorted(maps.Keys(v)) {
+							next = append(next, v[key])
+						}
+					}
+				}
+				current = next
+				continue
+			}
+			index, err := strconv.Atoi(selector)
+			if err != nil {
+				return nil, fmt.Errorf("invalid index %q", selector)
+			}
+			for _, v := range current {
+				if array, ok := v.([]any); ok {
+					i := index
+					if i < 0 {
+						i += len(array)
+					}
+					if i >= 0 && i < len(array) {
+						next = append(next, array[i])
+					}
+				}
+			}
+			current = next
+		default:
+			return nil, fmt.Errorf("unexpected %q", rest)
+		}
+	}
+	if current == nil {
+		current = []any{}
+	}
+	return current, nil
+}
+
+func handleEncodeText(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	text, err := requireText(ctx, request, "text")
+	if err != nil {
+		return nil, err
+	}
+	encoding := request.GetString("encoding", "base64")
+	var codec interface {
+		EncodeToString([]byte) string
+		DecodeString(string) ([]byte, error)
+	}
+	switch encoding {
+	case "base64":
+		codec = base64.StdEncoding
+	case "base64url":
+		codec = base64.RawURLEncoding
+	case "hex":
+		codec = hexCodec{}
+	default:
+		return nil, localizedError(ctx, "error.invalid_argument", "encoding", fmt.Sprintf("unknown encoding %q", encoding))
+	}
+
+	if !request.GetBool("decode", false) {
+		return jsonResult(map[string]any{"encoding": encoding, "text": codec.EncodeToString([]byte(text))})
+	}
+	decoded, err := codec.DecodeString(strings.TrimSpace(text))
+	if err != nil {
+		return nil, localizedError(ctx, "error.invalid_argument", "text", err.Error())
+	}
+	if !utf8.Valid(decoded) {
+		// binary content is returned as hex rather than mangled
+		return jsonResult(map[string]any{"encoding": encoding, "hex": hex.EncodeToString(decoded), "binary": true})
+	}
+	return jsonResult(map[string]any{"encoding": encoding, "text": string(decoded)})
+}
+
+type hexCodec struct{}
+
+func (hexCodec) EncodeToString(b []byte) string        { return hex.EncodeToString(b) }
+func (hexCodec) DecodeString(s string) ([]byte, error) { return hex.DecodeString(s) }
+
+func handleHashText(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	text, err := requireText(ctx, request, "text")
+	if err != nil {
+		return nil, err
+	}
+	algorithm := request.GetString("algorithm", "sha256")
+	newHash, ok := hashes[algorithm]
+	if !ok {
+		return nil, localizedError(ctx, "error.invalid_argument", "algorithm", fmt.Sprintf("unknown algorithm %q", algorithm))
+	}
+	h := newHash()
+	if key := request.GetString("hmac_key", ""); key != "" {
+		h = hmac.New(newHash, []byte(key))
+	}
+	h.Write([]byte(text))
+	sum := h.Sum(nil)
+	return jsonResult(map[string]any{
+		"algorithm": algorithm,
+		"hmac":      request.GetString("hmac_key", "") != "",
+		"hex":       hex.EncodeToString(sum),
+		"base64":    base64.StdEncoding.EncodeToString(sum),
+	})
+}
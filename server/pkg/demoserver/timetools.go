@@ -0,0 +1,293 @@
+package demoserver
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	CURRENT_TIME    ToolName = "get_current_time"
+	CONVERT_TIME    ToolName = "convert_time"
+	PARSE_TIME      ToolName = "parse_time"
+	ADD_DURATION    ToolName = "add_duration"
+	TIME_DIFFERENCE ToolName = "time_difference"
+)
+
+// TimeFormats are the format presets of the time tools, any other format is
+// used as a Go reference layout, i.e. "Mon 02 Jan 15:04".
+var TimeFormats = map[string]string{
+	"rfc3339":  time.RFC3339,
+	"rfc1123":  time.RFC1123,
+	"datetime": time.DateTime,
+	"date":     time.DateOnly,
+	"short":    "15:04",
+	"kitchen":  time.Kitchen,
+	"unix":     "",
+}
+
+// parseLayouts are tried in order for times given without a format.
+var parseLayouts = []string{time.RFC3339Nano, time.DateTime, "2006-01-02T15:04", time.DateOnly, time.RFC1123, time.RFC1123Z}
+
+func (s *Server) registerTimeTools() {
+	timezone := mcp.WithString("timezone",
+		mcp.Description("IANA timezone of the result, i.e. Europe/Berlin, the server's local time by default"),
+	)
+	format := mcp.WithString("format",
+		mcp.Description("Format of the result: rfc3339 (default), rfc1123, datetime, date, short, kitchen, unix, or a Go reference layout"),
+	)
+
+	s.AddTool(mcp.NewTool(string(CURRENT_TIME),
+		mcp.WithDescription("Get the current time"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		timezone,
+		format,
+	), s.handleCurrentTime)
+
+	s.AddTool(mcp.NewTool(string(CONVERT_TIME),
+		mcp.WithDescription("Converts a time to another timezone"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithString("time",
+			mcp.Description("Time to convert, RFC 3339 or 2006-01-02 15:04:05"),
+			mcp.Required(),
+		),
+		mcp.WithString("from_timezone",
+			mcp.Description("IANA timezone of a time without UTC offset, UTC by default"),
+		),
+		mcp.WithString("to_timezone",
+			mcp.Description("IANA timezone to convert to"),
+			mcp.Required(),
+		),
+		format,
+	), handleConvertTime)
+	s.addToolExamples(string(CONVERT_TIME), ToolExample{
+		Arguments: map[string]any{"time": "2025-06-01 12:00:00", "from_timezone": "America/New_York", "to_timezone": "Europe/Berlin"},
+		Result:    "2025-06-01T18:00:00+02:00",
+	})
+
+	s.AddTool(mcp.NewTool(string(PARSE_TIME),
+		mcp.WithDescription("Parses a time and returns it as RFC 3339 and Unix time"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithString("time",
+			mcp.Description("Time to parse"),
+			mcp.Required(),
+		),
+		mcp.WithString("layout",
+			mcp.Description("Format preset or Go reference layout of the time, common formats and Unix seconds are detected by default"),
+		),
+		mcp.WithString("timezone",
+			mcp.Description("IANA timezone of a time without UTC offset, UTC by default"),
+		),
+	), handleParseTime)
+
+	s.AddTool(mcp.NewTool(string(ADD_DURATION),
+		mcp.WithDescription("Adds a duration to a time, days are calendar days of the timezone"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithString("time",
+			mcp.Description("Time to add to, now by default"),
+		),
+		mcp.WithString("duration",
+			mcp.Description("Duration to add, negative to subtract, i.e. 1h30m or -2d12h"),
+			mcp.Required(),
+		),
+		timezone,
+		format,
+	), s.handleAddDuration)
+
+	s.AddTool(mcp.NewTool(string(TIME_DIFFERENCE),
+		mcp.WithDescription("Computes the duration from one time to another"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithString("start",
+			mcp.Description("Start time"),
+			mcp.Required(),
+		),
+		mcp.WithString("end",
+			mcp.Description("End time, now by default"),
+		),
+		mcp.WithString("timezone",
+			mcp.Description("IANA timezone of times without UTC offset, UTC by default"),
+		),
+	), s.handleTimeDifference)
+}
+
+func (s *Server) handleCurrentTime(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	location, err := loadLocation(ctx, request.GetString("timezone", ""), time.Local)
+	if err != nil {
+		return nil, err
+	}
+	formatted, err := formatTime(ctx, s.clock().In(location), request.GetString("format", ""))
+	if err != nil {
+		return nil, err
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("Time: %s", formatted)), nil
+}
+
+func handleConvertTime(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	from, err := loadLocation(ctx, request.GetString("from_timezone", ""), time.UTC)
+	if err != nil {
+		return nil, err
+	}
+	t, err := parseTime(ctx, request.GetString("time", ""), "", from)
+	if err != nil {
+		return nil, err
+	}
+	to, err := loadLocation(ctx, request.GetString("to_timezone", ""), nil)
+	if err != nil {
+		return nil, err
+	}
+	formatted, err := formatTime(ctx, t.In(to), request.GetString("format", ""))
+	if err != nil {
+		return nil, err
+	}
+	return mcp.NewToolResultText(formatted), nil
+}
+
+func handleParseTime(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	location, err := loadLocation(ctx, request.GetString("timezone", ""), time.UTC)
+	if err != nil {
+		return nil, err
+	}
+	t, err := parseTime(ctx, request.GetString("time", ""), request.GetString("layout", ""), location)
+	if err != nil {
+		return nil, err
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("%s (Unix %d, %s)", t.Format(time.RFC3339Nano), t.Unix(), t.Weekday())), nil
+}
+
+func (s *Server) handleAddDuration(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	location, err := loadLocation(ctx, request.GetString("timezone", ""), time.Local)
+	if err != nil {
+		return nil, err
+	}
+	t := s.clock().In(location)
+	if value := request.GetString("time", ""); value != "" {
+		if t, err = parseTime(ctx, value, "", location); err != nil {
+			return nil, err
+		}
+		t = t.In(location)
+	}
+	days, duration, err := parseDuration(request.GetString("duration", ""))
+	if err != nil {
+		return nil, localizedError(ctx, "error.invalid_duration", request.GetString("duration", ""))
+	}
+	formatted, err := formatTime(ctx, t.AddDate(0, 0, days).Add(duration), request.GetString("format", ""))
+	if err != nil {
+		return nil, err
+	}
+	return mcp.NewToolResultText(formatted), nil
+}
+
+func (s *Server) handleTimeDifference(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	location, err := loadLocation(ctx, request.GetString("timezone", ""), time.UTC)
+	if err != nil {
+		return nil, err
+	}
+	start, err := parseTime(ctx, request.GetString("start", ""), "", location)
+	if err != nil {
+		return nil, err
+	}
+	end := s.clock()
+	if value := request.GetString("end", ""); value != "" {
+		if end, err = parseTime(ctx, value, "", location); err != nil {
+			return nil, err
+		}
+	}
+	difference := end.Sub(start)
+	return mcp.NewToolResultText(fmt.Sprintf("%s (%.0f seconds)", difference, difference.Seconds())), nil
+}
+
+// loadLocation loads the IANA timezone name, fallback when empty.
+func loadLocation(ctx context.Context, name string, fallback *time.Location) (*time.Location, error) {
+	if name == "" && fallback != nil {
+		return fallback, nil
+	}
+	if name == "" {
+		return nil, localizedError(ctx, "error.invalid_timezone", name)
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, localizedError(ctx, "error.invalid_timezone", name)
+	}
+	return location, nil
+}
+
+func formatTime(ctx context.Context, t time.Time, format string) (string, error) {
+	if format == "" {
+		format = "rfc3339"
+	}
+	if strings.EqualFold(format, "unix") {
+		return strconv.FormatInt(t.Unix(), 10), nil
+	}
+	layout, ok := TimeFormats[strings.ToLower(format)]
+	if !ok {
+		// a layout without any reference value would be returned verbatim
+		if !strings.ContainsAny(format, "0123456789") {
+			return "", localizedError(ctx, "error.invalid_format", format)
+		}
+		layout = format
+	}
+	return t.Format(layout), nil
+}
+
+// parseTime parses value with the layout, or else the parseLayouts and Unix
+// seconds. Times without UTC offset are in location.
+func parseTime(ctx context.Context, value, layout string, location *time.Location) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, localizedError(ctx, "error.invalid_time", value)
+	}
+	if layout != "" {
+		if preset, ok := TimeFormats[strings.ToLower(layout)]; ok {
+			layout = preset
+		}
+	}
+	if layout == "" {
+		if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return time.Unix(seconds, 0).In(location), nil
+		}
+		for _, candidate := range parseLayouts {
+			if t, err := time.ParseInLocation(candidate, value, location); err == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, localizedError(ctx, "error.invalid_time", value)
+	}
+	t, err := time.ParseInLocation(layout, value, location)
+	if err != nil {
+		return time.Time{}, localizedError(ctx, "error.invalid_time", value)
+	}
+	return t, nil
+}
+
+var daysPattern = regexp.MustCompile(`^([+-]?)(\d+)d(.*)$`)
+
+// parseDuration parses a Go duration with an optional leading number of
+// days, i.e. -2d12h.
+func parseDuration(value string) (days int, duration time.Duration, err error) {
+	value = strings.TrimSpace(value)
+	if match := daysPattern.FindStringSubmatch(value); match != nil {
+		if days, err = strconv.Atoi(match[2]); err != nil {
+			return 0, 0, err
+		}
+		if match[1] == "-" {
+			days = -days
+		}
+		if match[3] == "" {
+			return days, 0, nil
+		}
+		if duration, err = time.ParseDuration(match[3]); err != nil {
+			return 0, 0, err
+		}
+		if match[1] == "-" {
+			duration = -duration
+		}
+		return days, duration, nil
+	}
+	duration, err = time.ParseDuration(value)
+	return 0, duration, err
+}
@@ -0,0 +1,178 @@
+package demoserver
+
+import (
+	"context"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	ESTIMATE_TOKENS ToolName = "estimate_tokens"
+
+	// TokenEstimateMetaKey holds the estimated LLM tokens of the contents in
+	// the _meta of tool results and resources/read results, with
+	// WithTokenEstimates.
+	TokenEstimateMetaKey = "estimatedTokens"
+)
+
+// Tokenizer counts the LLM tokens of a text, i.e. with the vocabulary of the
+// model the orchestrator budgets for.
+type Tokenizer interface {
+	CountTokens(text string) int
+}
+
+// HeuristicTokenizer approximates BPE tokenizers without a vocabulary: about
+// five letters or three digits per token, one token per punctuation mark and
+// per CJK character. Whitespace merges into the following word, except line
+// breaks.
+type HeuristicTokenizer struct{}
+
+// CountTokens implements Tokenizer.
+func (HeuristicTokenizer) CountTokens(text string) int {
+	tokens := 0
+	letters, digits := 0, 0
+	flush := func() {
+		tokens += (letters+4)/5 + (digits+2)/3
+		letters, digits = 0, 0
+	}
+	newline := false
+	for _, r := range text {
+		switch {
+		case unicode.IsLetter(r) && (r < utf8.RuneSelf || unicode.In(r, unicode.Latin, unicode.Greek, unicode.Cyrillic)):
+			if digits > 0 {
+				flush()
+			}
+			letters++
+		case unicode.IsDigit(r):
+			if letters > 0 {
+				flush()
+			}
+			digits++
+		case r == '\n':
+			flush()
+			// a run of line breaks is one token
+			if !newline {
+				tokens++
+			}
+			newline = true
+			continue
+		case unicode.IsSpace(r):
+			flush()
+		default:
+			// punctuation, symbols and the characters of scripts without
+			// spaces
+			flush()
+			tokens++
+		}
+		newline = false
+	}
+	flush()
+	return tokens
+}
+
+// WithTokenEstimates adds the tokens of tool results and resources/read
+// results, as counted by tokenizer, to their _meta, so orchestrators can
+// budget their context. A nil tokenizer is the HeuristicTokenizer. The
+// estimate_tokens tool and the sessions with FeatureTokenEstimates use the
+// tokenizer as well.
+func WithTokenEstimates(tokenizer Tokenizer) Option {
+	return func(s *Server) {
+		if tokenizer == nil {
+			tokenizer = HeuristicTokenizer{}
+		}
+		s.tokenizer = tokenizer
+		s.tokenEstimates = true
+	}
+}
+
+// EstimateContentTokens counts the tokens of the text contents and embedded
+// text resources. Images, audio and blobs are not counted, their cost depends
+// on the model.
+func EstimateContentTokens(tokenizer Tokenizer, contents []mcp.Content) int {
+	tokens := 0
+	for _, content := range contents {
+		switch c := content.(type) {
+		case mcp.TextContent:
+			tokens += tokenizer.CountTokens(c.Text)
+		case mcp.EmbeddedResource:
+			if text, ok := c.Resource.(mcp.TextResourceContents); ok {
+				tokens += tokenizer.CountTokens(text.Text)
+			}
+		}
+	}
+	return tokens
+}
+
+// EstimateResourceTokens counts the tokens of the text resource contents.
+func EstimateResourceTokens(tokenizer Tokenizer, contents []mcp.ResourceContents) int {
+	tokens := 0
+	for _, content := range contents {
+		if text, ok := content.(mcp.TextResourceContents); ok {
+			tokens += tokenizer.CountTokens(text.Text)
+		}
+	}
+	return tokens
+}
+
+// tokenMiddleware estimates the tokens of the whole result, before
+// chunkMiddleware streams it.
+func (s *Server) tokenMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		result, err := next(ctx, request)
+		if err != nil || result == nil || !s.tokenEstimates && !s.features.enabled(ctx, FeatureTokenEstimates) {
+			return result, err
+		}
+		if result.Meta == nil {
+			result.Meta = make(map[string]any)
+		}
+		result.Meta[TokenEstimateMetaKey] = EstimateContentTokens(s.tokenizer, result.Content)
+		return result, nil
+	}
+}
+
+// registerTokenHooks estimates the tokens of the resource contents sent to
+// the client, none when the client has them already.
+func (s *Server) registerTokenHooks(hooks *server.Hooks) {
+	hooks.AddAfterReadResource(func(ctx context.Context, id any, message *mcp.ReadResourceRequest, result *mcp.ReadResourceResult) {
+		if result == nil || !s.tokenEstimates && !s.features.enabled(ctx, FeatureTokenEstimates) {
+			return
+		}
+		if result.Meta == nil {
+			result.Meta = make(map[string]any)
+		}
+		result.Meta[TokenEstimateMetaKey] = EstimateResourceTokens(s.tokenizer, result.Contents)
+	})
+}
+
+// TokenEstimate is the result of estimate_tokens.
+type TokenEstimate struct {
+	Tokens     int `json:"tokens"`
+	Characters int `json:"characters"`
+	Bytes      int `json:"bytes"`
+}
+
+func (s *Server) registerTokenTools() {
+	s.AddTool(mcp.NewTool(string(ESTIMATE_TOKENS),
+		mcp.WithDescription("Estimates the LLM tokens of a text, i.e. to check that a prompt or document fits the context"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithString("text",
+			mcp.Description("Text to count"),
+			mcp.Required(),
+		),
+	), s.handleEstimateTokens)
+}
+
+func (s *Server) handleEstimateTokens(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	text, err := requireText(ctx, request, "text")
+	if err != nil {
+		return nil, err
+	}
+	return jsonResult(TokenEstimate{
+		Tokens:     s.tokenizer.CountTokens(text),
+		Characters: utf8.RuneCountInString(text),
+		Bytes:      len(text),
+	})
+}
@@ -0,0 +1,97 @@
+package demoserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ToolDocsURITemplate is the resource template of the documentation of each
+// tool, docs://tools/echo for the echo tool.
+const ToolDocsURITemplate = DocumentsScheme + "tools/{name}"
+
+// ToolExample is an example call of a tool, listed in its documentation.
+type ToolExample struct {
+	Description string         `json:"description,omitempty"`
+	Arguments   map[string]any `json:"arguments"`
+	// Result is the text of the result, empty when it varies, i.e. with
+	// the time.
+	Result string `json:"result,omitempty"`
+}
+
+// ToolDocs is the documentation of a tool served at ToolDocsURITemplate.
+type ToolDocs struct {
+	Name        string             `json:"name"`
+	Description string             `json:"description,omitempty"`
+	InputSchema any                `json:"inputSchema"`
+	Annotations mcp.ToolAnnotation `json:"annotations"`
+	Examples    []ToolExample      `json:"examples,omitempty"`
+	// OutputSchema is the schema of the JSON text of the results, see
+	// WithOutputSchema.
+	OutputSchema json.RawMessage `json:"outputSchema,omitempty"`
+}
+
+// WithToolExamples adds examples to the documentation of tool, i.e. of the
+// tools of WithExtraTools.
+func WithToolExamples(tool string, examples ...ToolExample) Option {
+	return func(s *Server) {
+		s.addToolExamples(tool, examples...)
+	}
+}
+
+func (s *Server) addToolExamples(tool string, examples ...ToolExample) {
+	if s.toolExamples == nil {
+		s.toolExamples = make(map[string][]ToolExample)
+	}
+	s.toolExamples[tool] = append(s.toolExamples[tool], examples...)
+}
+
+func (s *Server) registerToolDocs() {
+	s.mcpServer.AddResourceTemplate(mcp.NewResourceTemplate(ToolDocsURITemplate, "Tool documentation",
+		mcp.WithTemplateDescription("The input and output schema, annotations and example calls of a tool"),
+		mcp.WithTemplateMIMEType("application/json"),
+	), s.readToolDocs)
+}
+
+// readToolDocs documents a tool the client can list, so the tools hidden
+// from it stay hidden.
+func (s *Server) readToolDocs(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	name := strings.TrimPrefix(request.Params.URI, DocumentsScheme+"tools/")
+	if unescaped, err := url.PathUnescape(name); err == nil {
+		name = unescaped
+	}
+	tools, err := s.listTools(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, tool := range tools {
+		if tool.Name != name {
+			continue
+		}
+		docs := ToolDocs{
+			Name:         tool.Name,
+			Description:  tool.Description,
+			InputSchema:  tool.InputSchema,
+			Annotations:  tool.Annotations,
+			Examples:     s.toolExamples[tool.Name],
+			OutputSchema: s.outputSchemas[tool.Name],
+		}
+		if tool.RawInputSchema != nil {
+			docs.InputSchema = tool.RawInputSchema
+		}
+		body, err := json.Marshal(docs)
+		if err != nil {
+			return nil, err
+		}
+		return []mcp.ResourceContents{mcp.TextResourceContents{
+			URI:      request.Params.URI,
+			MIMEType: "application/json",
+			Text:     string(body),
+		}}, nil
+	}
+	return nil, fmt.Errorf("unknown tool %s", name)
+}
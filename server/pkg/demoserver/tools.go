@@ -0,0 +1,334 @@
+package demoserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+type ToolName string
+
+const (
+	ECHO ToolName = "echo"
+	ADD  ToolName = "add"
+	AUTH ToolName = "check_auth"
+
+	ROLLOUT_STATS ToolName = "rollout_stats"
+)
+
+// ToolRegistrar adds tools to an MCP server, i.e. a server.MCPServer or a
+// Server.
+type ToolRegistrar interface {
+	AddTool(tool mcp.Tool, handler server.ToolHandlerFunc)
+}
+
+// registeredTools keeps the tools as registered, before the tool filters of
+// the sessions, for the middleware deciding by their annotations.
+type registeredTools struct {
+	mu    sync.RWMutex
+	tools map[string]mcp.Tool
+}
+
+// AddTool registers tool with the MCP server. Tools added to MCPServer
+// directly are unknown to the approvals and the demo mode, which treat them
+// as destructive. A sandbox helper skips the tools other than the one it
+// calls.
+func (s *Server) AddTool(tool mcp.Tool, handler server.ToolHandlerFunc) {
+	if s.sandboxTool != "" && tool.Name != s.sandboxTool {
+		return
+	}
+	s.registered.mu.Lock()
+	if s.registered.tools == nil {
+		s.registered.tools = make(map[string]mcp.Tool)
+	}
+	s.registered.tools[tool.Name] = tool
+	s.registered.mu.Unlock()
+	s.mcpServer.AddTool(tool, handler)
+}
+
+// registeredTool returns the tool registered with AddTool as name.
+func (s *Server) registeredTool(name string) (mcp.Tool, bool) {
+	s.registered.mu.RLock()
+	defer s.registered.mu.RUnlock()
+	tool, ok := s.registered.tools[name]
+	return tool, ok
+}
+
+func (s *Server) registerTools() {
+	if s.toolSetEnabled(ToolSetEcho) {
+		s.registerEchoTools()
+	}
+
+	if s.toolSetEnabled(ToolSetRollout) {
+		s.AddTool(mcp.NewTool(string(ROLLOUT_STATS),
+			mcp.WithDescription("Reports per-variant metrics for tools under canary rollout"),
+			mcp.WithReadOnlyHintAnnotation(true),
+		), s.handleRolloutStats)
+	}
+
+	if s.toolSetEnabled(ToolSetTime) {
+		s.registerTimeTools()
+	}
+
+	if s.toolSetEnabled(ToolSetNotify) {
+		s.AddTool(
+			mcp.NewTool("notify"),
+			handleSendNotification,
+		)
+	}
+
+	if s.toolSetEnabled(ToolSetMath) {
+		s.registerExpressionTool()
+		// kept for the clients calling it, evaluate_expression replaces it
+		s.AddTool(mcp.NewTool(string(ADD),
+			mcp.WithDescription("Adds two numbers (deprecated: use evaluate_expression)"),
+			mcp.WithReadOnlyHintAnnotation(true),
+			mcp.WithNumber("a",
+				mcp.Description("First number"),
+				mcp.Required(),
+			),
+			mcp.WithNumber("b",
+				mcp.Description("Second number"),
+				mcp.Required(),
+			),
+		), withDeprecation(string(ADD), ToolVersion{
+			Version:    "1",
+			Handler:    handleAddTool,
+			Deprecated: "use evaluate_expression",
+		}))
+	}
+
+	if s.toolSetEnabled(ToolSetText) {
+		s.registerTextTools()
+		s.registerTokenTools()
+	}
+
+	if s.toolSetEnabled(ToolSetAuth) {
+		s.AddTool(mcp.NewTool(string(AUTH),
+			mcp.WithDescription("Checks for auth calls in the header"),
+			mcp.WithReadOnlyHintAnnotation(true),
+			mcp.WithString("message",
+				mcp.Description("Message to echo"),
+				mcp.Required(),
+			),
+		), handleAuthTool)
+	}
+
+	s.registerVectorTools()
+	s.registerGitTools()
+	s.registerKubernetesTools()
+	s.registerDockerTools()
+	s.registerNotificationTools()
+	s.registerDescribeServer()
+}
+
+func (s *Server) registerEchoTools() {
+	echoRollout := &Rollout{
+		Tool:       string(ECHO),
+		Primary:    handleEchoTool,
+		Canary:     mcp.NewTypedToolHandler(handleTypedEchoTool),
+		Percent:    s.canaryPercent,
+		Principals: s.canaryPrincipals,
+		IntN:       s.rand.IntN,
+	}
+	s.rollouts = append(s.rollouts, echoRollout)
+
+	echoVersions := &VersionedTool{
+		Name:    string(ECHO),
+		Default: "1",
+		Versions: []ToolVersion{
+			{
+				Version: "1",
+				Tool: mcp.NewTool(string(ECHO),
+					mcp.WithDescription("Echoes back the input prefixed with Echo:"),
+					mcp.WithReadOnlyHintAnnotation(true),
+					mcp.WithString("message",
+						mcp.Description("Message to echo"),
+						mcp.Required(),
+					),
+				),
+				Handler:    echoRollout.Handler(),
+				Deprecated: "echo@1 prefixes the message, use echo@2 for the verbatim message",
+			},
+			{
+				Version: "2",
+				Tool: mcp.NewTool(string(ECHO),
+					mcp.WithDescription("Echoes back the input"),
+					mcp.WithReadOnlyHintAnnotation(true),
+					mcp.WithString("message",
+						mcp.Description("Message to echo"),
+						mcp.Required(),
+					),
+				),
+				Handler: mcp.NewTypedToolHandler(handleEchoToolV2),
+			},
+		},
+	}
+	echoVersions.Register(s)
+	s.addToolExamples(string(ECHO), ToolExample{
+		Arguments: map[string]any{"message": "hello"},
+		Result:    "Echo: hello",
+	})
+}
+
+func handleEchoTool(
+	ctx context.Context,
+	request mcp.CallToolRequest,
+) (*mcp.CallToolResult, error) {
+	arguments := request.GetArguments()
+	message, ok := arguments["message"].(string)
+	if !ok {
+		return nil, localizedError(ctx, "error.invalid_message")
+	}
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("Echo: %s", message),
+			},
+		},
+	}, nil
+}
+
+type echoArgs struct {
+	Message string `json:"message"`
+}
+
+// handleTypedEchoTool is the rewrite of handleEchoTool on top of typed
+// arguments, rolled out as the canary variant of echo.
+func handleTypedEchoTool(
+	ctx context.Context,
+	request mcp.CallToolRequest,
+	args echoArgs,
+) (*mcp.CallToolResult, error) {
+	if args.Message == "" {
+		return mcp.NewToolResultError(localize(ctx, "error.invalid_message")), nil
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("Echo: %s", args.Message)), nil
+}
+
+// handleEchoToolV2 returns the message verbatim.
+func handleEchoToolV2(
+	ctx context.Context,
+	request mcp.CallToolRequest,
+	args echoArgs,
+) (*mcp.CallToolResult, error) {
+	if args.Message == "" {
+		return mcp.NewToolResultError(localize(ctx, "error.invalid_message")), nil
+	}
+	return mcp.NewToolResultText(args.Message), nil
+}
+
+func (s *Server) handleRolloutStats(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	body, err := s.rolloutStatsJSON()
+	if err != nil {
+		return nil, err
+	}
+	return mcp.NewToolResultText(string(body)), nil
+}
+
+func (s *Server) rolloutStatsJSON() ([]byte, error) {
+	report := make(map[string]map[string]VariantStats, len(s.rollouts))
+	for _, r := range s.rollouts {
+		report[r.Tool] = r.Stats()
+	}
+	body, err := json.Marshal(report)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode rollout stats: %w", err)
+	}
+	return body, nil
+}
+
+func handleAddTool(
+	ctx context.Context,
+	request mcp.CallToolRequest,
+) (*mcp.CallToolResult, error) {
+	arguments := request.GetArguments()
+	a, ok1 := arguments["a"].(float64)
+	b, ok2 := arguments["b"].(float64)
+	if !ok1 || !ok2 {
+		return nil, localizedError(ctx, "error.invalid_numbers")
+	}
+	sum := a + b
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("The sum of %f and %f is %f.", a, b, sum),
+			},
+		},
+	}, nil
+}
+
+func handleAuthTool(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	message, ok := request.GetArguments()["message"].(string)
+	if !ok {
+		return nil, localizedError(ctx, "error.invalid_message")
+	}
+
+	token, err := tokenFromContext(ctx)
+	if err != nil {
+		return nil, localizedError(ctx, "error.missing_auth")
+	}
+
+	if strings.HasPrefix(token, "Bearer ") && strings.Split(token, "Bearer ")[1] == "sk-1234" {
+
+	} else {
+		return nil, localizedError(ctx, "error.invalid_token")
+	}
+
+	return mcp.NewToolResultText(fmt.Sprintf("Echoing %s with auth successful", message)), nil
+}
+
+func handleSendNotification(
+	ctx context.Context,
+	request mcp.CallToolRequest,
+) (*mcp.CallToolResult, error) {
+
+	server := server.ServerFromContext(ctx)
+
+	err := server.SendNotificationToClient(
+		ctx,
+		"notifications/progress",
+		map[string]interface{}{
+			"progress":      10,
+			"total":         10,
+			"progressToken": 0,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send notification: %w", err)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: "notification sent successfully",
+			},
+		},
+	}, nil
+}
+
+func handleNotification(
+	ctx context.Context,
+	notification mcp.JSONRPCNotification,
+) {
+	log.Printf("Received notification: %s", notification.Method)
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,408 @@
+package demoserver
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	// TraceparentHeader carries the W3C trace context.
+	TraceparentHeader = "traceparent"
+
+	// DefaultTraceExportInterval is how often the finished spans are
+	// exported.
+	DefaultTraceExportInterval = 5 * time.Second
+
+	// maxPendingSpans drops spans when the exporter falls behind.
+	maxPendingSpans = 4096
+)
+
+// SpanKind is the OpenTelemetry span kind.
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+)
+
+// SpanContext identifies a span of a W3C trace.
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Sampled bool
+}
+
+// ParseTraceparent parses a W3C traceparent header of version 00.
+func ParseTraceparent(header string) (SpanContext, error) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return SpanContext{}, fmt.Errorf("invalid traceparent %q", header)
+	}
+	if parts[0] == "00" && len(parts) != 4 {
+		return SpanContext{}, fmt.Errorf("invalid traceparent %q", header)
+	}
+	var sc SpanContext
+	traceID, err := hex.DecodeString(parts[1])
+	if err != nil || len(traceID) != len(sc.TraceID) {
+		return SpanContext{}, fmt.Errorf("invalid trace ID in traceparent %q", header)
+	}
+	spanID, err := hex.DecodeString(parts[2])
+	if err != nil || len(spanID) != len(sc.SpanID) {
+		return SpanContext{}, fmt.Errorf("invalid span ID in traceparent %q", header)
+	}
+	flags, err := strconv.ParseUint(parts[3], 16, 8)
+	if err != nil || len(parts[3]) != 2 {
+		return SpanContext{}, fmt.Errorf("invalid flags in traceparent %q", header)
+	}
+	copy(sc.TraceID[:], traceID)
+	copy(sc.SpanID[:], spanID)
+	sc.Sampled = flags&1 == 1
+	if sc.TraceID == [16]byte{} || sc.SpanID == [8]byte{} {
+		return SpanContext{}, fmt.Errorf("zero ID in traceparent %q", header)
+	}
+	return sc, nil
+}
+
+// Traceparent formats the span context as W3C traceparent header.
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", hex.EncodeToString(sc.TraceID[:]), hex.EncodeToString(sc.SpanID[:]), flags)
+}
+
+type spanContextKey struct{}
+
+func withSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// SpanContextFromContext returns the current span, the one of the incoming
+// traceparent before the server started its own.
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc, ok
+}
+
+// Span is a finished span.
+type Span struct {
+	Name         string
+	Kind         SpanKind
+	TraceID      [16]byte
+	SpanID       [8]byte
+	ParentSpanID [8]byte
+	Start        time.Time
+	End          time.Time
+	Attributes   map[string]string
+	// Error is the failure of the operation, empty on success.
+	Error string
+}
+
+// SpanExporter sends finished spans to a tracing backend.
+type SpanExporter interface {
+	ExportSpans(ctx context.Context, spans []Span) error
+}
+
+// TracingConfig enables tracing.
+type TracingConfig struct {
+	Exporter SpanExporter
+	// Interval is DefaultTraceExportInterval when zero.
+	Interval time.Duration
+}
+
+// WithTracing continues the W3C traces of the requests to the MCP endpoints,
+// records spans of the requests and the tool calls, and propagates the trace
+// to the upstream APIs called by the tools. Requests without traceparent
+// start a new trace. Spans of traces the caller did not sample are not
+// exported.
+func WithTracing(config TracingConfig) Option {
+	return func(s *Server) {
+		if config.Interval <= 0 {
+			config.Interval = DefaultTraceExportInterval
+		}
+		s.tracer = &tracer{config: config}
+	}
+}
+
+type tracer struct {
+	config TracingConfig
+
+	mu      sync.Mutex
+	pending []Span
+	dropped int
+}
+
+// activeSpan is a span in progress.
+type activeSpan struct {
+	tracer  *tracer
+	span    Span
+	sampled bool
+}
+
+// start begins a span, the child of the span of ctx.
+func (t *tracer) start(ctx context.Context, name string, kind SpanKind) (context.Context, *activeSpan) {
+	span := &activeSpan{tracer: t, span: Span{Name: name, Kind: kind, Start: time.Now(), Attributes: map[string]string{}}, sampled: true}
+	if parent, ok := SpanContextFromContext(ctx); ok {
+		span.span.TraceID = parent.TraceID
+		span.span.ParentSpanID = parent.SpanID
+		span.sampled = parent.Sampled
+	} else {
+		rand.Read(span.span.TraceID[:])
+	}
+	rand.Read(span.span.SpanID[:])
+	return withSpanContext(ctx, span.context()), span
+}
+
+func (s *activeSpan) context() SpanContext {
+	return SpanContext{TraceID: s.span.TraceID, SpanID: s.span.SpanID, Sampled: s.sampled}
+}
+
+// end finishes the span, failed when err is not nil.
+func (s *activeSpan) end(err error) {
+	s.span.End = time.Now()
+	if err != nil {
+		s.span.Error = err.Error()
+	}
+	if !s.sampled {
+		return
+	}
+	t := s.tracer
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.pending) >= maxPendingSpans {
+		t.dropped++
+		return
+	}
+	t.pending = append(t.pending, s.span)
+}
+
+// flush exports the pending spans.
+func (t *tracer) flush(ctx context.Context) {
+	t.mu.Lock()
+	spans, dropped := t.pending, t.dropped
+	t.pending, t.dropped = nil, 0
+	t.mu.Unlock()
+	if dropped > 0 {
+		log.Printf("Dropped %d spans, the exporter is falling behind", dropped)
+	}
+	if len(spans) == 0 {
+		return
+	}
+	if err := t.config.Exporter.ExportSpans(ctx, spans); err != nil {
+		log.Printf("Failed to export %d spans: %v", len(spans), err)
+	}
+}
+
+// run exports the spans every interval until ctx is done.
+func (t *tracer) run(ctx context.Context) {
+	ticker := time.NewTicker(t.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t.flush(ctx)
+		}
+	}
+}
+
+// tracingMiddleware continues the trace of the requests to the MCP endpoints
+// in a server span. SSE streams only carry the trace to their messages, they
+// last too long for a span.
+func (s *Server) tracingMiddleware(next http.Handler) http.Handler {
+	if s.tracer == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if parent, err := ParseTraceparent(r.Header.Get(TraceparentHeader)); err == nil {
+			ctx = withSpanContext(ctx, parent)
+		}
+		if r.Method == http.MethodGet {
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+		ctx, span := s.tracer.start(ctx, r.Method+" "+r.URL.Path, SpanKindServer)
+		span.span.Attributes["http.request.method"] = r.Method
+		span.span.Attributes["url.path"] = r.URL.Path
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+		span.span.Attributes["http.response.status_code"] = strconv.Itoa(recorder.status)
+		var err error
+		if recorder.status >= http.StatusInternalServerError {
+			err = fmt.Errorf("status code %d", recorder.status)
+		}
+		span.end(err)
+	})
+}
+
+// toolTracingMiddleware records a span of every tool call.
+func (s *Server) toolTracingMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	if s.tracer == nil {
+		return next
+	}
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		ctx, span := s.tracer.start(ctx, "tools/call "+request.Params.Name, SpanKindInternal)
+		span.span.Attributes["mcp.tool.name"] = request.Params.Name
+		if session := sessionIDFromContext(ctx); session != "" {
+			span.span.Attributes["mcp.session.id"] = session
+		}
+		result, err := next(ctx, request)
+		if err == nil && result != nil && result.IsError {
+			span.end(fmt.Errorf("tool returned an error result"))
+		} else {
+			span.end(err)
+		}
+		return result, err
+	}
+}
+
+// tracePropagation sets the traceparent of the current span on the requests
+// of the tools to upstream APIs. Without a span the requests are unchanged.
+func tracePropagation(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return propagatingTransport{base}
+}
+
+type propagatingTransport struct {
+	base http.RoundTripper
+}
+
+func (t propagatingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	sc, ok := SpanContextFromContext(r.Context())
+	if !ok {
+		return t.base.RoundTrip(r)
+	}
+	r = r.Clone(r.Context())
+	r.Header.Set(TraceparentHeader, sc.Traceparent())
+	return t.base.RoundTrip(r)
+}
+
+// OTLPExporter exports spans as OTLP/HTTP JSON, i.e. to the
+// http://collector:4318/v1/traces endpoint of an OpenTelemetry collector.
+type OTLPExporter struct {
+	Endpoint    string
+	ServiceName string
+	// Headers are added to the export requests, i.e. for authentication.
+	Headers map[string]string
+	// HTTPClient defaults to a client with a 10s timeout.
+	HTTPClient *http.Client
+}
+
+type otlpAttribute struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue string `json:"stringValue"`
+	} `json:"value"`
+}
+
+func otlpAttributes(attributes map[string]string) []otlpAttribute {
+	converted := make([]otlpAttribute, 0, len(attributes))
+	for key, value := range attributes {
+		attribute := otlpAttribute{Key: key}
+		attribute.Value.StringValue = value
+		converted = append(converted, attribute)
+	}
+	return converted
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              SpanKind        `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes"`
+	Status            struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	} `json:"status"`
+}
+
+// ExportSpans implements SpanExporter.
+func (e *OTLPExporter) ExportSpans(ctx context.Context, spans []Span) error {
+	converted := make([]otlpSpan, len(spans))
+	for i, span := range spans {
+		c := otlpSpan{
+			TraceID:           hex.EncodeToString(span.TraceID[:]),
+			SpanID:            hex.EncodeToString(span.SpanID[:]),
+			Name:              span.Name,
+			Kind:              span.Kind,
+			StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.End.UnixNano(), 10),
+			Attributes:        otlpAttributes(span.Attributes),
+		}
+		if span.ParentSpanID != [8]byte{} {
+			c.ParentSpanID = hex.EncodeToString(span.ParentSpanID[:])
+		}
+		// unset, ok or error
+		c.Status.Code = 1
+		if span.Error != "" {
+			c.Status.Code = 2
+			c.Status.Message = span.Error
+		}
+		converted[i] = c
+	}
+	serviceName := e.ServiceName
+	if serviceName == "" {
+		serviceName = ServerName
+	}
+	body, err := json.Marshal(map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{
+				"attributes": otlpAttributes(map[string]string{"service.name": serviceName, "service.version": ServerVersion}),
+			},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]any{"name": "demoserver"},
+				"spans": converted,
+			}},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range e.Headers {
+		req.Header.Set(key, value)
+	}
+	client := e.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return fmt.Errorf("OTLP endpoint: status code %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
@@ -0,0 +1,361 @@
+package demoserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	EMBED_AND_STORE ToolName = "embed_and_store"
+	SEMANTIC_SEARCH ToolName = "semantic_search"
+
+	// DefaultEmbeddingModel is the model requested from OpenAI-compatible
+	// endpoints.
+	DefaultEmbeddingModel = "text-embedding-3-small"
+	// DefaultCollection holds the documents stored without a collection.
+	DefaultCollection = "default"
+	// DefaultMaxDocuments bounds each collection of the MemoryVectorStore.
+	DefaultMaxDocuments = 10000
+	// DefaultSearchLimit is how many matches semantic_search returns.
+	DefaultSearchLimit = 5
+
+	maxEmbeddedText = 32 << 10
+)
+
+var ErrStoreFull = errors.New("vector store is full")
+
+// Embedder turns texts into vectors.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// VectorDocument is a stored text with its vector.
+type VectorDocument struct {
+	ID       string            `json:"id"`
+	Text     string            `json:"text"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Vector   []float32         `json:"-"`
+	Stored   time.Time         `json:"stored"`
+}
+
+// VectorMatch is a document found by a search, with the cosine similarity of
+// its vector and the query.
+type VectorMatch struct {
+	VectorDocument
+	Score float64 `json:"score"`
+}
+
+// VectorStore stores documents by collection and searches them by vector.
+// Upsert replaces the documents with the same ID, Delete ignores unknown IDs.
+type VectorStore interface {
+	Upsert(ctx context.Context, collection string, docs ...VectorDocument) error
+	Delete(ctx context.Context, collection string, ids ...string) error
+	Search(ctx context.Context, collection string, vector []float32, limit int) ([]VectorMatch, error)
+}
+
+// VectorSearchConfig enables the embed_and_store and semantic_search tools.
+type VectorSearchConfig struct {
+	Embedder Embedder
+	// Store defaults to a MemoryVectorStore.
+	Store VectorStore
+}
+
+// WithVectorSearch registers the retrieval tools, which embed texts with
+// config.Embedder and keep them in config.Store.
+func WithVectorSearch(config VectorSearchConfig) Option {
+	return func(s *Server) {
+		if config.Store == nil {
+			config.Store = NewMemoryVectorStore(DefaultMaxDocuments)
+		}
+		s.vector = &config
+	}
+}
+
+// OpenAIEmbedder calls the /embeddings endpoint of an OpenAI-compatible API,
+// i.e. OpenAI, a LiteLLM proxy or Ollama.
+type OpenAIEmbedder struct {
+	// BaseURL is the API base, i.e. https://api.openai.com/v1.
+	BaseURL string
+	Model   string
+	APIKey  string
+	// HTTPClient defaults to a client with a 30s timeout.
+	HTTPClient *http.Client
+}
+
+// NewOpenAIEmbedder creates an OpenAIEmbedder of model, DefaultEmbeddingModel
+// when empty.
+func NewOpenAIEmbedder(baseURL, model, apiKey string) *OpenAIEmbedder {
+	if model == "" {
+		model = DefaultEmbeddingModel
+	}
+	return &OpenAIEmbedder{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		Model:      model,
+		APIKey:     apiKey,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second, Transport: tracePropagation(nil)},
+	}
+}
+
+// Embed implements Embedder.
+func (e *OpenAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	body, err := json.Marshal(map[string]any{"model": e.Model, "input": texts})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.BaseURL+"/embeddings", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.APIKey)
+	}
+	resp, err := e.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach the embeddings endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return nil, fmt.Errorf("embeddings endpoint: status code %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	var response struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<20)).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode the embeddings: %w", err)
+	}
+	vectors := make([][]float32, len(texts))
+	for _, data := range response.Data {
+		if data.Index < 0 || data.Index >= len(texts) {
+			return nil, fmt.Errorf("embeddings endpoint returned index %d for %d texts", data.Index, len(texts))
+		}
+		vectors[data.Index] = data.Embedding
+	}
+	for i, vector := range vectors {
+		if len(vector) == 0 {
+			return nil, fmt.Errorf("embeddings endpoint returned no embedding for text %d", i)
+		}
+	}
+	return vectors, nil
+}
+
+// MemoryVectorStore keeps normalized vectors in memory and searches them
+// exhaustively by cosine similarity, which is fast enough for tens of
+// thousands of documents. It forgets everything on restart.
+type MemoryVectorStore struct {
+	maxDocuments int
+
+	mu          sync.RWMutex
+	collections map[string]map[string]VectorDocument
+}
+
+// NewMemoryVectorStore creates a store of up to maxDocuments per collection.
+func NewMemoryVectorStore(maxDocuments int) *MemoryVectorStore {
+	return &MemoryVectorStore{maxDocuments: maxDocuments, collections: make(map[string]map[string]VectorDocument)}
+}
+
+// Upsert implements VectorStore.
+func (m *MemoryVectorStore) Upsert(ctx context.Context, collection string, docs ...VectorDocument) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored, ok := m.collections[collection]
+	if !ok {
+		stored = make(map[string]VectorDocument)
+		m.collections[collection] = stored
+	}
+	added := 0
+	for _, doc := range docs {
+		if _, ok := stored[doc.ID]; !ok {
+			added++
+		}
+	}
+	if len(stored)+added > m.maxDocuments {
+		return fmt.Errorf("%w: collection %s holds %d documents", ErrStoreFull, collection, len(stored))
+	}
+	for _, doc := range docs {
+		doc.Vector = normalize(doc.Vector)
+		stored[doc.ID] = doc
+	}
+	return nil
+}
+
+// Delete implements VectorStore.
+func (m *MemoryVectorStore) Delete(ctx context.Context, collection string, ids ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, id := range ids {
+		delete(m.collections[collection], id)
+	}
+	return nil
+}
+
+// Search implements VectorStore.
+func (m *MemoryVectorStore) Search(ctx context.Context, collection string, vector []float32, limit int) ([]VectorMatch, error) {
+	query := normalize(vector)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	matches := make([]VectorMatch, 0, len(m.collections[collection]))
+	for _, doc := range m.collections[collection] {
+		if len(doc.Vector) != len(query) {
+			// embedded with another model
+			continue
+		}
+		var score float64
+		for i := range query {
+			score += float64(query[i]) * float64(doc.Vector[i])
+		}
+		matches = append(matches, VectorMatch{VectorDocument: doc, Score: score})
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, nil
+}
+
+// normalize scales vector to unit length, so the dot product is the cosine
+// similarity.
+func normalize(vector []float32) []float32 {
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		return vector
+	}
+	norm = math.Sqrt(norm)
+	normalized := make([]float32, len(vector))
+	for i, v := range vector {
+		normalized[i] = float32(float64(v) / norm)
+	}
+	return normalized
+}
+
+func (s *Server) registerVectorTools() {
+	if s.vector == nil {
+		return
+	}
+	collection := mcp.WithString("collection",
+		mcp.Description("Collection of the documents, default by default"),
+	)
+
+	s.AddTool(mcp.NewTool(string(EMBED_AND_STORE),
+		mcp.WithDescription("Embeds a text and stores it for semantic_search, replacing the document with the same ID"),
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithIdempotentHintAnnotation(true),
+		mcp.WithString("text",
+			mcp.Description("Text to store"),
+			mcp.Required(),
+		),
+		mcp.WithString("id",
+			mcp.Description("Document ID, a new one by default"),
+		),
+		mcp.WithObject("metadata",
+			mcp.Description("String attributes returned with the document, i.e. its source"),
+		),
+		collection,
+	), s.handleEmbedAndStore)
+
+	s.AddTool(mcp.NewTool(string(SEMANTIC_SEARCH),
+		mcp.WithDescription("Finds the stored documents most similar in meaning to a query"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithString("query",
+			mcp.Description("Text to search for"),
+			mcp.Required(),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("Maximum number of documents, 5 by default"),
+			mcp.Min(1),
+			mcp.Max(100),
+		),
+		mcp.WithNumber("min_score",
+			mcp.Description("Minimum cosine similarity between -1 and 1"),
+		),
+		collection,
+	), s.handleSemanticSearch)
+}
+
+func (s *Server) handleEmbedAndStore(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	text, err := requireText(ctx, request, "text")
+	if err != nil {
+		return nil, err
+	}
+	if text == "" || len(text) > maxEmbeddedText {
+		return nil, localizedError(ctx, "error.invalid_argument", "text", fmt.Sprintf("between 1 and %d bytes are required", maxEmbeddedText))
+	}
+	doc := VectorDocument{
+		ID:     request.GetString("id", ""),
+		Text:   text,
+		Stored: s.clock().UTC(),
+	}
+	if doc.ID == "" {
+		doc.ID = newCorrelationID()
+	}
+	if metadata, ok := request.GetArguments()["metadata"].(map[string]any); ok {
+		doc.Metadata = make(map[string]string, len(metadata))
+		for key, value := range metadata {
+			doc.Metadata[key] = fmt.Sprint(value)
+		}
+	}
+
+	vectors, err := s.vector.Embedder.Embed(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	doc.Vector = vectors[0]
+	collection := request.GetString("collection", DefaultCollection)
+	if err := s.vector.Store.Upsert(ctx, collection, doc); err != nil {
+		if errors.Is(err, ErrStoreFull) {
+			return nil, localizedError(ctx, "error.invalid_argument", "collection", err.Error())
+		}
+		return nil, err
+	}
+	return jsonResult(map[string]any{"id": doc.ID, "collection": collection, "dimensions": len(doc.Vector)})
+}
+
+func (s *Server) handleSemanticSearch(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	query, err := requireText(ctx, request, "query")
+	if err != nil {
+		return nil, err
+	}
+	if query == "" || len(query) > maxEmbeddedText {
+		return nil, localizedError(ctx, "error.invalid_argument", "query", fmt.Sprintf("between 1 and %d bytes are required", maxEmbeddedText))
+	}
+	limit := request.GetInt("limit", DefaultSearchLimit)
+	if limit < 1 || limit > 100 {
+		limit = DefaultSearchLimit
+	}
+	minScore := request.GetFloat("min_score", -1)
+
+	vectors, err := s.vector.Embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, err
+	}
+	matches, err := s.vector.Store.Search(ctx, request.GetString("collection", DefaultCollection), vectors[0], limit)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]VectorMatch, 0, len(matches))
+	for _, match := range matches {
+		if match.Score >= minScore {
+			results = append(results, match)
+		}
+	}
+	return jsonResult(map[string]any{"matches": results})
+}
@@ -0,0 +1,142 @@
+package demoserver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	// VersionArgument pins a tool version from within the call arguments.
+	VersionArgument string = "_version"
+	// ToolVersionsHeader pins tool versions for the whole connection,
+	// i.e. "Mcp-Tool-Versions: echo=1, add=2".
+	ToolVersionsHeader string = "Mcp-Tool-Versions"
+)
+
+type versionPinsKey struct{}
+
+// ToolVersion is a single registered version of a tool. A non-empty
+// Deprecated notice marks the version as deprecated.
+type ToolVersion struct {
+	Version    string
+	Tool       mcp.Tool
+	Handler    server.ToolHandlerFunc
+	Deprecated string
+}
+
+// VersionedTool groups every version of a tool. Each version is registered as
+// name@version and the bare name resolves to the pinned version, falling back
+// to Default.
+type VersionedTool struct {
+	Name     string
+	Default  string
+	Versions []ToolVersion
+}
+
+func withVersionPins(ctx context.Context, pins map[string]string) context.Context {
+	return context.WithValue(ctx, versionPinsKey{}, pins)
+}
+
+func versionPinsFromRequest(ctx context.Context, r *http.Request) context.Context {
+	pins := make(map[string]string)
+	for _, pin := range splitList(r.Header.Get(ToolVersionsHeader)) {
+		name, version, ok := strings.Cut(pin, "=")
+		if ok {
+			pins[strings.TrimSpace(name)] = strings.TrimSpace(version)
+		}
+	}
+	return withVersionPins(ctx, pins)
+}
+
+func versionPinFromContext(ctx context.Context, name string) string {
+	pins, _ := ctx.Value(versionPinsKey{}).(map[string]string)
+	return pins[name]
+}
+
+func (v *VersionedTool) lookup(version string) (ToolVersion, bool) {
+	for _, tv := range v.Versions {
+		if tv.Version == version {
+			return tv, true
+		}
+	}
+	return ToolVersion{}, false
+}
+
+// Register adds every version of the tool to the server.
+func (v *VersionedTool) Register(s ToolRegistrar) {
+	var versions []string
+	for _, tv := range v.Versions {
+		tool := tv.Tool
+		tool.Name = fmt.Sprintf("%s@%s", v.Name, tv.Version)
+		if tv.Deprecated != "" {
+			tool.Description = fmt.Sprintf("%s (deprecated: %s)", tool.Description, tv.Deprecated)
+		}
+		s.AddTool(tool, withDeprecation(v.Name, tv))
+		versions = append(versions, tv.Version)
+	}
+
+	current, ok := v.lookup(v.Default)
+	if !ok {
+		return
+	}
+	tool := current.Tool
+	tool.Name = v.Name
+	// copy the properties so the version argument does not leak into name@version
+	properties := make(map[string]any, len(tool.InputSchema.Properties)+1)
+	for k, p := range tool.InputSchema.Properties {
+		properties[k] = p
+	}
+	tool.InputSchema.Properties = properties
+	tool.Description = fmt.Sprintf("%s (versions: %s, default %s)", tool.Description, strings.Join(versions, ", "), v.Default)
+	mcp.WithString(VersionArgument,
+		mcp.Description("Pin a specific version of the tool"),
+		mcp.Enum(versions...),
+	)(&tool)
+	s.AddTool(tool, v.handleUnversioned)
+}
+
+func (v *VersionedTool) handleUnversioned(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	version := versionPinFromContext(ctx, v.Name)
+	if arguments := request.GetArguments(); arguments != nil {
+		if pinned, ok := arguments[VersionArgument].(string); ok {
+			version = pinned
+			delete(arguments, VersionArgument)
+		}
+	}
+	if version == "" {
+		version = v.Default
+	}
+
+	tv, ok := v.lookup(version)
+	if !ok {
+		return nil, localizedError(ctx, "error.unknown_version", version, v.Name)
+	}
+	return withDeprecation(v.Name, tv)(ctx, request)
+}
+
+// withDeprecation attaches the deprecation notice of tv to the result _meta.
+func withDeprecation(name string, tv ToolVersion) server.ToolHandlerFunc {
+	if tv.Deprecated == "" {
+		return tv.Handler
+	}
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		result, err := tv.Handler(ctx, request)
+		if err != nil || result == nil {
+			return result, err
+		}
+		if result.Meta == nil {
+			result.Meta = make(map[string]any)
+		}
+		result.Meta["deprecation"] = map[string]any{
+			"tool":    name,
+			"version": tv.Version,
+			"notice":  tv.Deprecated,
+		}
+		return result, nil
+	}
+}
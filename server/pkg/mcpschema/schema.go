@@ -0,0 +1,417 @@
+// Package mcpschema validates MCP messages against the embedded JSON schema
+// of the supported protocol version, to catch spec violations such as renamed
+// fields or a missing jsonrpc version before clients do.
+package mcpschema
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ProtocolVersion is the MCP version of the embedded schema.
+const ProtocolVersion = "2025-03-26"
+
+//go:embed 2025-03-26.json
+var schemaJSON []byte
+
+// resultDefinitions maps the request methods to the definition of their
+// result.
+var resultDefinitions = map[string]string{
+	"initialize":               "InitializeResult",
+	"ping":                     "EmptyResult",
+	"tools/list":               "ListToolsResult",
+	"tools/call":               "CallToolResult",
+	"resources/list":           "ListResourcesResult",
+	"resources/templates/list": "ListResourceTemplatesResult",
+	"resources/read":           "ReadResourceResult",
+	"prompts/list":             "ListPromptsResult",
+	"prompts/get":              "GetPromptResult",
+	"logging/setLevel":         "EmptyResult",
+}
+
+// notificationDefinitions maps the notification methods to their definition,
+// the ones without parameters only have to be valid notifications.
+var notificationDefinitions = map[string]string{
+	"notifications/progress":          "ProgressNotification",
+	"notifications/message":           "LoggingMessageNotification",
+	"notifications/cancelled":         "CancelledNotification",
+	"notifications/resources/updated": "ResourceUpdatedNotification",
+}
+
+// Schema is a compiled MCP schema.
+type Schema struct {
+	definitions map[string]*node
+}
+
+// Load compiles the embedded schema.
+func Load() (*Schema, error) {
+	var document struct {
+		Definitions map[string]*node `json:"definitions"`
+	}
+	if err := json.Unmarshal(schemaJSON, &document); err != nil {
+		return nil, fmt.Errorf("failed to parse the MCP %s schema: %w", ProtocolVersion, err)
+	}
+	s := &Schema{definitions: document.Definitions}
+	for name, definition := range s.definitions {
+		if err := s.resolve(definition); err != nil {
+			return nil, fmt.Errorf("definition %s: %w", name, err)
+		}
+	}
+	return s, nil
+}
+
+// Definition is a compiled standalone schema, see Compile.
+type Definition struct {
+	root   *node
+	schema *Schema
+}
+
+// Compile compiles a standalone JSON schema, i.e. the output schema of a
+// tool. Only the keywords of the MCP schema are checked, others are ignored,
+// and references point to its own definitions.
+func Compile(data []byte) (*Definition, error) {
+	var root node
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	var document struct {
+		Definitions map[string]*node `json:"definitions"`
+	}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	s := &Schema{definitions: document.Definitions}
+	for name, definition := range s.definitions {
+		if err := s.resolve(definition); err != nil {
+			return nil, fmt.Errorf("definition %s: %w", name, err)
+		}
+	}
+	if err := s.resolve(&root); err != nil {
+		return nil, err
+	}
+	return &Definition{root: &root, schema: s}, nil
+}
+
+// Validate checks a value decoded from JSON against the schema.
+func (d *Definition) Validate(value any) error {
+	return d.schema.result(d.root.validate("", value))
+}
+
+// Violation is a place where a message does not match the schema.
+type Violation struct {
+	// Path locates the value, i.e. result.tools[0].inputSchema.type
+	Path    string
+	Message string
+}
+
+func (v Violation) String() string {
+	if v.Path == "" {
+		return v.Message
+	}
+	return v.Path + ": " + v.Message
+}
+
+// Violations is the error returned by the validation.
+type Violations []Violation
+
+func (v Violations) Error() string {
+	lines := make([]string, len(v))
+	for i, violation := range v {
+		lines[i] = violation.String()
+	}
+	return strings.Join(lines, "; ")
+}
+
+// Validate checks the JSON data against a definition of the schema, i.e.
+// Tool.
+func (s *Schema) Validate(definition string, data []byte) error {
+	d, ok := s.definitions[definition]
+	if !ok {
+		return fmt.Errorf("unknown definition %s", definition)
+	}
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return Violations{{Message: fmt.Sprintf("not JSON: %v", err)}}
+	}
+	return s.result(d.validate("", value))
+}
+
+// ValidateResult checks the result of a request method, encoded as JSON.
+// Results of methods without a definition only have to be objects.
+func (s *Schema) ValidateResult(method string, result any) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return Violations{{Message: fmt.Sprintf("result does not encode: %v", err)}}
+	}
+	definition, ok := resultDefinitions[method]
+	if !ok {
+		definition = "Result"
+	}
+	return s.Validate(definition, data)
+}
+
+// ValidateMessage checks a JSON-RPC message the server sends. The kind of
+// message follows from its fields, the result of a response is checked
+// against the definition of method when known.
+func (s *Schema) ValidateMessage(data []byte, method string) error {
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return Violations{{Message: fmt.Sprintf("not JSON: %v", err)}}
+	}
+	message, ok := value.(map[string]any)
+	if !ok {
+		return Violations{{Message: fmt.Sprintf("message is %s, not an object", typeOf(value))}}
+	}
+
+	var violations []Violation
+	_, hasID := message["id"]
+	_, hasResult := message["result"]
+	_, hasError := message["error"]
+	notificationMethod, hasMethod := message["method"].(string)
+	switch {
+	case hasMethod && hasID:
+		violations = s.definitions["JSONRPCRequest"].validate("", message)
+	case hasMethod:
+		violations = s.definitions["JSONRPCNotification"].validate("", message)
+		if definition, ok := notificationDefinitions[notificationMethod]; ok {
+			violations = append(violations, s.definitions[definition].validate("", message)...)
+		}
+	case hasError:
+		violations = s.definitions["JSONRPCError"].validate("", message)
+	case hasResult:
+		violations = s.definitions["JSONRPCResponse"].validate("", message)
+		if definition, ok := resultDefinitions[method]; ok {
+			violations = append(violations, s.definitions[definition].validate("result", message["result"])...)
+		}
+	default:
+		violations = []Violation{{Message: "neither a request, a notification, a response nor an error"}}
+	}
+	return s.result(violations)
+}
+
+func (s *Schema) result(violations []Violation) error {
+	if len(violations) == 0 {
+		return nil
+	}
+	return Violations(violations)
+}
+
+// node is a JSON schema, restricted to the keywords of the MCP schema.
+type node struct {
+	Ref                  string           `json:"$ref"`
+	Type                 typeList         `json:"type"`
+	Const                *json.RawMessage `json:"const"`
+	Enum                 []any            `json:"enum"`
+	Properties           map[string]*node `json:"properties"`
+	Required             []string         `json:"required"`
+	Items                *node            `json:"items"`
+	AnyOf                []*node          `json:"anyOf"`
+	AdditionalProperties *additional      `json:"additionalProperties"`
+	Minimum              *float64         `json:"minimum"`
+	Maximum              *float64         `json:"maximum"`
+
+	ref      *node
+	refName  string
+	constant any
+}
+
+// typeList is the type keyword, a single type or a list of them.
+type typeList []string
+
+func (t *typeList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = typeList{single}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(t))
+}
+
+// additional is the additionalProperties keyword, a boolean or a schema.
+type additional struct {
+	forbidden bool
+	schema    *node
+}
+
+func (a *additional) UnmarshalJSON(data []byte) error {
+	var allowed bool
+	if err := json.Unmarshal(data, &allowed); err == nil {
+		a.forbidden = !allowed
+		return nil
+	}
+	return json.Unmarshal(data, &a.schema)
+}
+
+// resolve links the references and decodes the constants of the tree.
+func (s *Schema) resolve(n *node) error {
+	if n == nil {
+		return nil
+	}
+	if n.Ref != "" {
+		name := strings.TrimPrefix(n.Ref, "#/definitions/")
+		ref, ok := s.definitions[name]
+		if !ok {
+			return fmt.Errorf("unresolved reference %s", n.Ref)
+		}
+		n.ref, n.refName = ref, name
+	}
+	if n.Const != nil {
+		if err := json.Unmarshal(*n.Const, &n.constant); err != nil {
+			return err
+		}
+	}
+	children := append([]*node{n.Items}, n.AnyOf...)
+	for _, property := range n.Properties {
+		children = append(children, property)
+	}
+	if n.AdditionalProperties != nil {
+		children = append(children, n.AdditionalProperties.schema)
+	}
+	for _, child := range children {
+		if err := s.resolve(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *node) validate(path string, value any) []Violation {
+	if n.ref != nil {
+		return n.ref.validate(path, value)
+	}
+
+	fail := func(format string, args ...any) []Violation {
+		return []Violation{{Path: path, Message: fmt.Sprintf(format, args...)}}
+	}
+	if len(n.Type) > 0 && !n.Type.matches(value) {
+		return fail("is %s, want %s", typeOf(value), strings.Join(n.Type, " or "))
+	}
+	if n.Const != nil && !reflect.DeepEqual(value, n.constant) {
+		return fail("is %s, want %s", describe(value), describe(n.constant))
+	}
+	if len(n.Enum) > 0 && !contains(n.Enum, value) {
+		return fail("is %s, not one of %v", describe(value), n.Enum)
+	}
+	if number, ok := value.(float64); ok {
+		if n.Minimum != nil && number < *n.Minimum {
+			return fail("is %v, below the minimum %v", number, *n.Minimum)
+		}
+		if n.Maximum != nil && number > *n.Maximum {
+			return fail("is %v, above the maximum %v", number, *n.Maximum)
+		}
+	}
+	if len(n.AnyOf) > 0 {
+		return n.validateAnyOf(path, value)
+	}
+
+	var violations []Violation
+	switch value := value.(type) {
+	case map[string]any:
+		for _, name := range n.Required {
+			if _, ok := value[name]; !ok {
+				violations = append(violations, Violation{Path: path, Message: fmt.Sprintf("missing required field %q", name)})
+			}
+		}
+		names := make([]string, 0, len(value))
+		for name := range value {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			childPath := join(path, name)
+			if property, ok := n.Properties[name]; ok {
+				violations = append(violations, property.validate(childPath, value[name])...)
+			} else if n.AdditionalProperties != nil {
+				if n.AdditionalProperties.forbidden {
+					violations = append(violations, Violation{Path: childPath, Message: "is not allowed"})
+				} else if n.AdditionalProperties.schema != nil {
+					violations = append(violations, n.AdditionalProperties.schema.validate(childPath, value[name])...)
+				}
+			}
+		}
+	case []any:
+		if n.Items != nil {
+			for i, item := range value {
+				violations = append(violations, n.Items.validate(fmt.Sprintf("%s[%d]", path, i), item)...)
+			}
+		}
+	}
+	return violations
+}
+
+// validateAnyOf reports the violations of the closest alternative, which
+// reads better than the violations of all of them.
+func (n *node) validateAnyOf(path string, value any) []Violation {
+	var closest []Violation
+	names := make([]string, 0, len(n.AnyOf))
+	for _, alternative := range n.AnyOf {
+		violations := alternative.validate(path, value)
+		if len(violations) == 0 {
+			return nil
+		}
+		if closest == nil || len(violations) < len(closest) {
+			closest = violations
+		}
+		names = append(names, alternative.refName)
+	}
+	return append([]Violation{{Path: path, Message: fmt.Sprintf("matches none of %s", strings.Join(names, ", "))}}, closest...)
+}
+
+func (t typeList) matches(value any) bool {
+	for _, name := range t {
+		switch name {
+		case "integer":
+			if number, ok := value.(float64); ok && number == math.Trunc(number) {
+				return true
+			}
+		default:
+			if typeOf(value) == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func typeOf(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+func describe(value any) string {
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+func contains(values []any, value any) bool {
+	for _, v := range values {
+		if reflect.DeepEqual(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
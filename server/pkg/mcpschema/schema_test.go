@@ -0,0 +1,124 @@
+package mcpschema
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	if _, err := Load(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidateMessage(t *testing.T) {
+	s, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		message string
+		method  string
+		// violation is a substring of the expected error, empty when valid
+		violation string
+	}{
+		{"response", `{"jsonrpc":"2.0","id":1,"result":{}}`, "ping", ""},
+		{"error", `{"jsonrpc":"2.0","id":"a","error":{"code":-32601,"message":"not found"}}`, "", ""},
+		{"notification", `{"jsonrpc":"2.0","method":"notifications/progress","params":{"progress":1,"progressToken":0}}`, "", ""},
+		{"missing jsonrpc", `{"id":1,"result":{}}`, "ping", `missing required field "jsonrpc"`},
+		{"wrong jsonrpc", `{"jsonrpc":"1.0","id":1,"result":{}}`, "", `jsonrpc: is "1.0", want "2.0"`},
+		{"null id", `{"jsonrpc":"2.0","id":null,"result":{}}`, "", "id: is null"},
+		{"fractional error code", `{"jsonrpc":"2.0","id":1,"error":{"code":1.5,"message":"x"}}`, "", "error.code: is number, want integer"},
+		{"not a message", `{"jsonrpc":"2.0"}`, "", "neither"},
+		{
+			"renamed tool field",
+			`{"jsonrpc":"2.0","id":1,"result":{"tools":[{"name":"add","input_schema":{"type":"object"}}]}}`,
+			"tools/list",
+			`result.tools[0]: missing required field "inputSchema"`,
+		},
+		{
+			"non object input schema",
+			`{"jsonrpc":"2.0","id":1,"result":{"tools":[{"name":"add","inputSchema":{"type":"string"}}]}}`,
+			"tools/list",
+			`result.tools[0].inputSchema.type: is "string", want "object"`,
+		},
+		{
+			"unknown content type",
+			`{"jsonrpc":"2.0","id":1,"result":{"content":[{"type":"txt","text":"hi"}]}}`,
+			"tools/call",
+			"result.content[0]: matches none of TextContent",
+		},
+		{
+			"priority out of range",
+			`{"jsonrpc":"2.0","id":1,"result":{"content":[{"type":"text","text":"hi","annotations":{"priority":2}}]}}`,
+			"tools/call",
+			"above the maximum",
+		},
+		{
+			"progress without token",
+			`{"jsonrpc":"2.0","method":"notifications/progress","params":{"progress":1}}`,
+			"",
+			`params: missing required field "progressToken"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.ValidateMessage([]byte(tt.message), tt.method)
+			switch {
+			case tt.violation == "" && err != nil:
+				t.Errorf("unexpected violation: %v", err)
+			case tt.violation != "" && err == nil:
+				t.Errorf("got no violation, want %q", tt.violation)
+			case tt.violation != "" && !strings.Contains(err.Error(), tt.violation):
+				t.Errorf("got %q, want %q", err, tt.violation)
+			}
+		})
+	}
+}
+
+func TestCompile(t *testing.T) {
+	d, err := Compile([]byte(`{
+		"type": "object",
+		"required": ["count", "matches"],
+		"properties": {
+			"count": {"type": "integer", "minimum": 0},
+			"matches": {"type": "array", "items": {"$ref": "#/definitions/match"}}
+		},
+		"definitions": {
+			"match": {"type": "object", "required": ["match"], "properties": {"match": {"type": "string"}}}
+		}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		value any
+		// violation is a substring of the expected error, empty when valid
+		violation string
+	}{
+		{"valid", map[string]any{"count": 1.0, "matches": []any{map[string]any{"match": "a"}}}, ""},
+		{"missing", map[string]any{"count": 0.0}, `missing required field "matches"`},
+		{"negative", map[string]any{"count": -1.0, "matches": []any{}}, "count: is -1, below the minimum 0"},
+		{"reference", map[string]any{"count": 1.0, "matches": []any{map[string]any{"match": 1.0}}}, "matches[0].match: is number, want string"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := d.Validate(tt.value)
+			switch {
+			case tt.violation == "" && err != nil:
+				t.Errorf("unexpected violation: %v", err)
+			case tt.violation != "" && err == nil:
+				t.Errorf("got no violation, want %q", tt.violation)
+			case tt.violation != "" && !strings.Contains(err.Error(), tt.violation):
+				t.Errorf("got %q, want %q", err, tt.violation)
+			}
+		})
+	}
+
+	if _, err := Compile([]byte(`{"$ref": "#/definitions/missing"}`)); err == nil {
+		t.Error("compiled a schema with an unresolved reference")
+	}
+}
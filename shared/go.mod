@@ -0,0 +1,15 @@
+module github.com/wagnerjt/go-mcp/shared
+
+go 1.24.1
+
+require (
+	github.com/grokify/go-pkce v0.2.3
+	github.com/mark3labs/mcp-go v0.31.0
+	golang.org/x/oauth2 v0.30.0
+)
+
+require (
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/spf13/cast v1.7.1 // indirect
+	github.com/yosida95/uritemplate/v3 v3.0.2 // indirect
+)
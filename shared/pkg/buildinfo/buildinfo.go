@@ -0,0 +1,73 @@
+// Package buildinfo identifies the build of a binary, so deployed instances
+// can be told apart. Release builds inject the values with ldflags, i.e.
+//
+//	go build -ldflags "-X github.com/wagnerjt/go-mcp/shared/pkg/buildinfo.version=v1.2.0
+//	  -X github.com/wagnerjt/go-mcp/shared/pkg/buildinfo.commit=$(git rev-parse HEAD)
+//	  -X github.com/wagnerjt/go-mcp/shared/pkg/buildinfo.date=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// Other builds fall back to the module version and the VCS stamp of the Go
+// toolchain.
+package buildinfo
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+)
+
+// set with -ldflags "-X ..."
+var (
+	version string
+	commit  string
+	date    string
+)
+
+// Info is the build of the running binary.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	Date      string `json:"date"`
+	GoVersion string `json:"goVersion"`
+}
+
+// Get returns the build of the running binary, with "dev" and "unknown" for
+// what neither the ldflags nor the toolchain stamped.
+func Get() Info {
+	info := Info{Version: version, Commit: commit, Date: date, GoVersion: runtime.Version()}
+	if build, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "" && build.Main.Version != "(devel)" {
+			info.Version = build.Main.Version
+		}
+		for _, setting := range build.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = setting.Value
+			case setting.Key == "vcs.time" && info.Date == "":
+				info.Date = setting.Value
+			}
+		}
+	}
+	if info.Version == "" {
+		info.Version = "dev"
+	}
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	if info.Date == "" {
+		info.Date = "unknown"
+	}
+	return info
+}
+
+// String formats the build for -version flags.
+func (i Info) String() string {
+	return fmt.Sprintf("%s (commit %s, built %s, %s)", i.Version, i.Commit, i.Date, i.GoVersion)
+}
+
+// Handler serves the build as JSON, i.e. on /version.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(Get())
+}
@@ -0,0 +1,153 @@
+// Package compensation lets destructive tools register how to undo their
+// effect, and provides the undo_last tool running the last registered
+// compensation of the session.
+package compensation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	// ToolName is the tool undoing the last undoable call of the session.
+	ToolName = "undo_last"
+
+	// DefaultDepth is how many compensations are kept per session.
+	DefaultDepth = 10
+	// DefaultTTL is how long a call can be undone.
+	DefaultTTL = time.Hour
+)
+
+var ErrNothingToUndo = errors.New("nothing to undo")
+
+// Func reverts the effect of a tool call. It runs with the context of the
+// undo_last call, not the one of the original call.
+type Func func(ctx context.Context) error
+
+type entry struct {
+	description string
+	undo        Func
+	registered  time.Time
+}
+
+// Stacks holds the compensations of each session. They are kept in memory
+// only, so a restart forgets them.
+type Stacks struct {
+	// Depth defaults to DefaultDepth, older compensations are dropped.
+	Depth int
+	// TTL defaults to DefaultTTL, expired compensations are dropped.
+	TTL time.Duration
+	// Clock defaults to time.Now.
+	Clock func() time.Time
+
+	mu       sync.Mutex
+	sessions map[string][]entry
+}
+
+// New creates Stacks with the default limits.
+func New() *Stacks {
+	return &Stacks{Depth: DefaultDepth, TTL: DefaultTTL, Clock: time.Now}
+}
+
+func sessionID(ctx context.Context) string {
+	if session := server.ClientSessionFromContext(ctx); session != nil {
+		return session.SessionID()
+	}
+	return ""
+}
+
+// Push registers the compensation of a successful call on the stack of the
+// session of ctx. description tells the user what undoing does.
+func (s *Stacks) Push(ctx context.Context, description string, undo Func) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sessions == nil {
+		s.sessions = make(map[string][]entry)
+	}
+	id := sessionID(ctx)
+	stack := append(s.live(s.sessions[id]), entry{description: description, undo: undo, registered: s.Clock()})
+	if len(stack) > s.Depth {
+		stack = stack[len(stack)-s.Depth:]
+	}
+	s.sessions[id] = stack
+}
+
+// live drops the expired compensations, the oldest come first.
+func (s *Stacks) live(stack []entry) []entry {
+	now := s.Clock()
+	for len(stack) > 0 && now.Sub(stack[0].registered) > s.TTL {
+		stack = stack[1:]
+	}
+	return stack
+}
+
+// Undo runs the last compensation of the session and returns its
+// description. It is removed even when it fails, so a failing compensation
+// does not block the ones below it.
+func (s *Stacks) Undo(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	id := sessionID(ctx)
+	stack := s.live(s.sessions[id])
+	if len(stack) == 0 {
+		delete(s.sessions, id)
+		s.mu.Unlock()
+		return "", ErrNothingToUndo
+	}
+	last := stack[len(stack)-1]
+	if stack = stack[:len(stack)-1]; len(stack) == 0 {
+		delete(s.sessions, id)
+	} else {
+		s.sessions[id] = stack
+	}
+	s.mu.Unlock()
+
+	if err := last.undo(ctx); err != nil {
+		return last.description, fmt.Errorf("failed to undo %s: %w", last.description, err)
+	}
+	return last.description, nil
+}
+
+// Tool returns the undo_last tool, its title annotation states the limits.
+func (s *Stacks) Tool() server.ServerTool {
+	return server.ServerTool{
+		Tool: mcp.NewTool(ToolName,
+			mcp.WithDescription("Undoes the last undoable tool call of this session. The calls are undone in reverse order, "+
+				"and they are forgotten when the server restarts. Undoing is best effort: it runs a compensating action, "+
+				"i.e. closing a created issue, which cannot restore everything the call changed."),
+			mcp.WithTitleAnnotation(fmt.Sprintf("Undo the last call (up to %d calls of the past %s)", s.Depth, shortDuration(s.TTL))),
+			mcp.WithDestructiveHintAnnotation(true),
+			mcp.WithIdempotentHintAnnotation(false),
+		),
+		Handler: s.handleUndo,
+	}
+}
+
+// shortDuration formats d without its zero units, i.e. 1h instead of 1h0m0s.
+func shortDuration(d time.Duration) string {
+	text := d.String()
+	if strings.HasSuffix(text, "m0s") {
+		text = strings.TrimSuffix(text, "0s")
+	}
+	if strings.HasSuffix(text, "h0m") {
+		text = strings.TrimSuffix(text, "0m")
+	}
+	return text
+}
+
+func (s *Stacks) handleUndo(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	description, err := s.Undo(ctx)
+	if errors.Is(err, ErrNothingToUndo) {
+		return mcp.NewToolResultText("Nothing to undo in this session"), nil
+	}
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	return mcp.NewToolResultText("Undone: " + description), nil
+}
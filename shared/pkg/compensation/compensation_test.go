@@ -0,0 +1,102 @@
+package compensation
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+type fakeSession struct{ id string }
+
+func (s fakeSession) SessionID() string                                   { return s.id }
+func (s fakeSession) NotificationChannel() chan<- mcp.JSONRPCNotification { return nil }
+func (s fakeSession) Initialize()                                         {}
+func (s fakeSession) Initialized() bool                                   { return true }
+
+func sessionContext(id string) context.Context {
+	return server.NewMCPServer("test", "0.0.1").WithContext(context.Background(), fakeSession{id})
+}
+
+func TestStacksUndo(t *testing.T) {
+	tests := []struct {
+		name string
+		// pushed are the descriptions pushed in order, "fail" fails to undo
+		pushed  []string
+		depth   int
+		advance time.Duration
+		undos   int
+		want    []string
+		wantErr []error
+	}{
+		{name: "last in first out", pushed: []string{"a", "b"}, undos: 2, want: []string{"b", "a"}},
+		{name: "empty", undos: 1, want: []string{""}, wantErr: []error{ErrNothingToUndo}},
+		{name: "depth drops the oldest", pushed: []string{"a", "b", "c"}, depth: 2, undos: 3, want: []string{"c", "b", ""}, wantErr: []error{nil, nil, ErrNothingToUndo}},
+		{name: "expired", pushed: []string{"a"}, advance: DefaultTTL + time.Second, undos: 1, want: []string{""}, wantErr: []error{ErrNothingToUndo}},
+		{name: "failure is removed", pushed: []string{"a", "fail"}, undos: 2, want: []string{"fail", "a"}, wantErr: []error{errUndo, nil}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Unix(1700000000, 0)
+			stacks := New()
+			stacks.Clock = func() time.Time { return now }
+			if tt.depth > 0 {
+				stacks.Depth = tt.depth
+			}
+			ctx := sessionContext("s1")
+			for _, description := range tt.pushed {
+				stacks.Push(ctx, description, func(context.Context) error {
+					if description == "fail" {
+						return errUndo
+					}
+					return nil
+				})
+			}
+			now = now.Add(tt.advance)
+
+			for i := 0; i < tt.undos; i++ {
+				got, err := stacks.Undo(ctx)
+				var wantErr error
+				if tt.wantErr != nil {
+					wantErr = tt.wantErr[i]
+				}
+				if !errors.Is(err, wantErr) {
+					t.Fatalf("undo %d: error = %v, want %v", i, err, wantErr)
+				}
+				if got != tt.want[i] {
+					t.Fatalf("undo %d: undid %q, want %q", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+var errUndo = errors.New("undo failed")
+
+func TestStacksPerSession(t *testing.T) {
+	stacks := New()
+	stacks.Push(sessionContext("s1"), "a", func(context.Context) error { return nil })
+
+	if _, err := stacks.Undo(sessionContext("s2")); !errors.Is(err, ErrNothingToUndo) {
+		t.Fatalf("other session: error = %v, want %v", err, ErrNothingToUndo)
+	}
+	if got, err := stacks.Undo(sessionContext("s1")); err != nil || got != "a" {
+		t.Fatalf("own session: undid %q, %v, want a", got, err)
+	}
+}
+
+func TestToolTitleStatesLimits(t *testing.T) {
+	stacks := New()
+	want := "Undo the last call (up to 10 calls of the past 1h)"
+	if got := stacks.Tool().Tool.Annotations.Title; got != want {
+		t.Errorf("title = %q, want %q", got, want)
+	}
+	stacks.Depth, stacks.TTL = 3, 90*time.Minute
+	want = "Undo the last call (up to 3 calls of the past 1h30m)"
+	if got := stacks.Tool().Tool.Annotations.Title; got != want {
+		t.Errorf("title = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,40 @@
+// Package healthcheck reports the startup self-test of the -check flag of
+// the servers.
+package healthcheck
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// Result is one item of the startup self-test report.
+type Result struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Run prints the results of check to w, or buildErr when the server could
+// not be built, and returns the exit code, 1 when a check failed.
+func Run(ctx context.Context, w io.Writer, buildErr error, check func(context.Context) []Result) int {
+	if buildErr != nil {
+		fmt.Fprintf(w, "FAIL  config: %v\n", buildErr)
+		return 1
+	}
+
+	code := 0
+	for _, result := range check(ctx) {
+		status := "ok  "
+		if !result.OK {
+			status = "FAIL"
+			code = 1
+		}
+		line := fmt.Sprintf("%s  %s", status, result.Name)
+		if result.Detail != "" {
+			line += ": " + result.Detail
+		}
+		fmt.Fprintln(w, line)
+	}
+	return code
+}
@@ -0,0 +1,45 @@
+package healthcheck
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name     string
+		buildErr error
+		results  []Result
+		want     string
+		wantCode int
+	}{
+		{
+			name:    "ok",
+			results: []Result{{Name: "config", OK: true, Detail: "transport sse"}, {Name: "tool echo", OK: true}},
+			want:    "ok    config: transport sse\nok    tool echo\n",
+		},
+		{
+			name:     "failed check",
+			results:  []Result{{Name: "tool echo", OK: true}, {Name: "dependency redis", Detail: "connection refused"}},
+			want:     "ok    tool echo\nFAIL  dependency redis: connection refused\n",
+			wantCode: 1,
+		},
+		{
+			name:     "build error",
+			buildErr: errors.New("credentials are required"),
+			want:     "FAIL  config: credentials are required\n",
+			wantCode: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			code := Run(context.Background(), &out, tt.buildErr, func(context.Context) []Result { return tt.results })
+			if code != tt.wantCode || out.String() != tt.want {
+				t.Errorf("Run() = %d, %q, want %d, %q", code, out.String(), tt.wantCode, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package healthcheck
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// ListTools lists the tools of s the way a client sees them.
+func ListTools(ctx context.Context, s *server.MCPServer) ([]mcp.Tool, error) {
+	response := s.HandleMessage(ctx, json.RawMessage(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`))
+	switch response := response.(type) {
+	case mcp.JSONRPCResponse:
+		result, ok := response.Result.(mcp.ListToolsResult)
+		if !ok {
+			return nil, fmt.Errorf("unexpected tools/list result %T", response.Result)
+		}
+		return result.Tools, nil
+	case mcp.JSONRPCError:
+		return nil, fmt.Errorf("tools/list failed: %s", response.Error.Message)
+	default:
+		return nil, fmt.Errorf("unexpected tools/list response %T", response)
+	}
+}
+
+// ValidateToolSchema dry-runs the compilation of the input schema: it has to
+// encode, be an object schema and only require declared properties.
+func ValidateToolSchema(tool mcp.Tool) error {
+	data, err := json.Marshal(tool)
+	if err != nil {
+		return fmt.Errorf("schema does not encode: %w", err)
+	}
+	var encoded struct {
+		InputSchema struct {
+			Type       string                     `json:"type"`
+			Properties map[string]json.RawMessage `json:"properties"`
+			Required   []string                   `json:"required"`
+		} `json:"inputSchema"`
+	}
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return fmt.Errorf("schema does not decode: %w", err)
+	}
+	schema := encoded.InputSchema
+	if schema.Type != "object" {
+		return fmt.Errorf("input schema type is %q, not object", schema.Type)
+	}
+	for name, property := range schema.Properties {
+		var p map[string]any
+		if err := json.Unmarshal(property, &p); err != nil {
+			return fmt.Errorf("property %s is not a schema: %w", name, err)
+		}
+	}
+	for _, name := range schema.Required {
+		if _, ok := schema.Properties[name]; !ok {
+			return fmt.Errorf("required property %s is not declared", name)
+		}
+	}
+	return nil
+}
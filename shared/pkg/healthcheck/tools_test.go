@@ -0,0 +1,49 @@
+package healthcheck
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+func TestListTools(t *testing.T) {
+	s := server.NewMCPServer("test", "1", server.WithToolCapabilities(true))
+	s.AddTool(mcp.NewTool("echo", mcp.WithString("message", mcp.Required())), nil)
+	tools, err := ListTools(context.Background(), s)
+	if err != nil || len(tools) != 1 || tools[0].Name != "echo" {
+		t.Fatalf("ListTools() = %v, %v, want echo", tools, err)
+	}
+}
+
+func TestValidateToolSchema(t *testing.T) {
+	tests := []struct {
+		name string
+		tool mcp.Tool
+		want string
+	}{
+		{name: "valid", tool: mcp.NewTool("echo", mcp.WithString("message", mcp.Required()))},
+		{name: "no properties", tool: mcp.NewTool("time")},
+		{name: "not an object", tool: mcp.Tool{Name: "list", InputSchema: mcp.ToolInputSchema{Type: "array"}}, want: "not object"},
+		{
+			name: "undeclared required property",
+			tool: mcp.Tool{Name: "echo", InputSchema: mcp.ToolInputSchema{Type: "object", Required: []string{"message"}}},
+			want: "required property message is not declared",
+		},
+		{
+			name: "property not a schema",
+			tool: mcp.Tool{Name: "echo", InputSchema: mcp.ToolInputSchema{Type: "object", Properties: map[string]any{"message": "string"}}},
+			want: "property message is not a schema",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateToolSchema(tt.tool)
+			if tt.want == "" && err != nil || tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)) {
+				t.Errorf("ValidateToolSchema() = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,241 @@
+package jwtauth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// KeysTTL is how long a fetched JWKS is used before it is fetched again.
+	KeysTTL = time.Hour
+	// MinRefreshInterval throttles the fetches for tokens signed by an
+	// unknown key, i.e. after a key rotation, and after a failed fetch, so
+	// forged key IDs or an outage cannot flood the authorization server.
+	MinRefreshInterval = 30 * time.Second
+	// FetchTimeout bounds a fetch of the JWKS, along with its discovery.
+	FetchTimeout = 10 * time.Second
+)
+
+// jsonWebKey is a public key of a JWKS.
+type jsonWebKey struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n"`
+	E         string `json:"e"`
+	Curve     string `json:"crv"`
+	X         string `json:"x"`
+	Y         string `json:"y"`
+
+	public any
+}
+
+// keySet caches the JWKS of the issuer of a Validator.
+type keySet struct {
+	validator *Validator
+	// url is the JWKS URL, discovered from the issuer metadata when empty
+	url string
+
+	mu      sync.Mutex
+	keys    []*jsonWebKey
+	fetched time.Time
+	// attempted is the last fetch, err its error
+	attempted time.Time
+	err       error
+}
+
+// key returns the key of id, fetching the JWKS when it is stale or does not
+// have the key, at most once per MinRefreshInterval. An empty id matches the
+// only signing key.
+func (k *keySet) key(ctx context.Context, id string) (*jsonWebKey, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	now := k.validator.Clock()
+	stale := k.fetched.IsZero() || now.Sub(k.fetched) >= KeysTTL
+	key, found := k.find(id)
+	if (stale || !found) && (k.attempted.IsZero() || now.Sub(k.attempted) >= MinRefreshInterval) {
+		k.refresh(ctx, now)
+		key, found = k.find(id)
+	}
+	switch {
+	case found:
+		// cached keys keep validating while the issuer is down
+		return key, nil
+	case k.err != nil:
+		return nil, k.err
+	}
+	return nil, invalid("unknown signing key %q", id)
+}
+
+// Refresh fetches the JWKS now, i.e. to check that the authorization server
+// is reachable before serving.
+func (v *Validator) Refresh(ctx context.Context) error {
+	k := v.keys
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.refresh(ctx, v.Clock())
+	if k.err == nil && len(k.keys) == 0 {
+		return fmt.Errorf("no usable signing keys at %s", k.url)
+	}
+	return k.err
+}
+
+// refresh fetches the JWKS for the caller of ctx and the ones waiting on
+// the lock, so the fetch is detached from the cancellation of ctx, which
+// would otherwise fail it for all of them and be cached as the error of the
+// issuer. FetchTimeout bounds it instead.
+func (k *keySet) refresh(ctx context.Context, now time.Time) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), FetchTimeout)
+	defer cancel()
+	k.attempted = now
+	keys, err := k.fetch(ctx)
+	k.err = err
+	if err == nil {
+		k.keys, k.fetched = keys, now
+	}
+}
+
+func (k *keySet) find(id string) (*jsonWebKey, bool) {
+	var signing []*jsonWebKey
+	for _, key := range k.keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		if id != "" && key.KeyID == id {
+			return key, true
+		}
+		signing = append(signing, key)
+	}
+	if id == "" && len(signing) == 1 {
+		return signing[0], true
+	}
+	return nil, false
+}
+
+func (k *keySet) fetch(ctx context.Context) ([]*jsonWebKey, error) {
+	if k.url == "" {
+		url, err := k.discover(ctx)
+		if err != nil {
+			return nil, err
+		}
+		k.url = url
+	}
+	var set struct {
+		Keys []*jsonWebKey `json:"keys"`
+	}
+	if err := k.get(ctx, k.url, &set); err != nil {
+		return nil, fmt.Errorf("failed to fetch the JWKS: %w", err)
+	}
+	keys := make([]*jsonWebKey, 0, len(set.Keys))
+	for _, key := range set.Keys {
+		// keys of unsupported types are skipped rather than failing the set
+		if err := key.parse(); err == nil {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// discover returns the jwks_uri of the OpenID or the OAuth authorization
+// server metadata of the issuer.
+func (k *keySet) discover(ctx context.Context) (string, error) {
+	issuer := strings.TrimSuffix(k.validator.config.Issuer, "/")
+	var errs []string
+	for _, path := range []string{"/.well-known/openid-configuration", "/.well-known/oauth-authorization-server"} {
+		var metadata struct {
+			JWKSURI string `json:"jwks_uri"`
+		}
+		err := k.get(ctx, issuer+path, &metadata)
+		if err == nil && metadata.JWKSURI != "" {
+			return metadata.JWKSURI, nil
+		}
+		if err == nil {
+			err = fmt.Errorf("%s%s has no jwks_uri", issuer, path)
+		}
+		errs = append(errs, err.Error())
+	}
+	return "", fmt.Errorf("failed to discover the JWKS of %s: %s", issuer, strings.Join(errs, "; "))
+}
+
+func (k *keySet) get(ctx context.Context, url string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := k.validator.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: status code %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(out)
+}
+
+// parse decodes the public key of an RSA or EC key.
+func (key *jsonWebKey) parse() error {
+	number := func(value string) (*big.Int, error) {
+		data, err := base64.RawURLEncoding.DecodeString(value)
+		if err != nil || len(data) == 0 {
+			return nil, fmt.Errorf("key %s: invalid number", key.KeyID)
+		}
+		return new(big.Int).SetBytes(data), nil
+	}
+	switch key.KeyType {
+	case "RSA":
+		n, err := number(key.N)
+		if err != nil {
+			return err
+		}
+		e, err := number(key.E)
+		if err != nil {
+			return err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return fmt.Errorf("key %s: invalid exponent", key.KeyID)
+		}
+		key.public = &rsa.PublicKey{N: n, E: int(e.Int64())}
+	case "EC":
+		var curve elliptic.Curve
+		switch key.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return fmt.Errorf("key %s: unsupported curve %q", key.KeyID, key.Curve)
+		}
+		x, err := number(key.X)
+		if err != nil {
+			return err
+		}
+		y, err := number(key.Y)
+		if err != nil {
+			return err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return fmt.Errorf("key %s: the point is not on %s", key.KeyID, key.Curve)
+		}
+		key.public = &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+	default:
+		return fmt.Errorf("key %s: unsupported key type %q", key.KeyID, key.KeyType)
+	}
+	return nil
+}
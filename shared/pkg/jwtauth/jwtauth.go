@@ -0,0 +1,282 @@
+// Package jwtauth validates the JWT bearer tokens of the requests to a
+// protected MCP endpoint against the keys an authorization server publishes
+// as a JWKS, so the servers of this repo can share it with any provider
+// issuing JWT access tokens.
+package jwtauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DefaultLeeway is the clock skew tolerated in the exp and nbf claims.
+const DefaultLeeway = time.Minute
+
+// ErrInvalidToken is wrapped by the errors of tokens that failed
+// validation, as opposed to a JWKS that could not be fetched.
+var ErrInvalidToken = errors.New("invalid token")
+
+// TokenValidator validates a bearer token and returns its claims.
+type TokenValidator interface {
+	Validate(ctx context.Context, token string) (*Claims, error)
+}
+
+// TokenValidatorFunc adapts a function to a TokenValidator.
+type TokenValidatorFunc func(ctx context.Context, token string) (*Claims, error)
+
+// Validate calls f.
+func (f TokenValidatorFunc) Validate(ctx context.Context, token string) (*Claims, error) {
+	return f(ctx, token)
+}
+
+// Claims are the registered claims of a validated token, along with all of
+// its claims in Raw.
+type Claims struct {
+	Issuer    string
+	Subject   string
+	Audience  []string
+	ExpiresAt time.Time
+	NotBefore time.Time
+	IssuedAt  time.Time
+	// Scope is the space separated scope claim of RFC 8693.
+	Scope string
+	Raw   map[string]any
+}
+
+// Config configures a Validator.
+type Config struct {
+	// Issuer is the required iss claim, and the authorization server whose
+	// metadata names the JWKS when JWKSURL is empty.
+	Issuer string
+	// Audience lists the accepted aud claims, a token must name one of them.
+	// Empty accepts any audience, which is only safe when the issuer issues
+	// tokens for this server alone.
+	Audience []string
+	// JWKSURL overrides the jwks_uri of the metadata of Issuer.
+	JWKSURL string
+}
+
+// Validator validates JWTs signed with the RS, PS and ES algorithms by the
+// keys of a JWKS, checking the issuer, the audience and the expiry.
+type Validator struct {
+	config Config
+	keys   *keySet
+
+	// Leeway defaults to DefaultLeeway.
+	Leeway time.Duration
+	// Clock defaults to time.Now.
+	Clock func() time.Time
+	// HTTPClient fetches the metadata and the JWKS, http.DefaultClient by
+	// default.
+	HTTPClient *http.Client
+}
+
+// New creates a Validator for config. The JWKS is fetched on the first
+// validation, so New does not need the authorization server to be up.
+func New(config Config) (*Validator, error) {
+	if config.Issuer == "" {
+		return nil, fmt.Errorf("the issuer is required")
+	}
+	v := &Validator{config: config, Leeway: DefaultLeeway, Clock: time.Now, HTTPClient: http.DefaultClient}
+	v.keys = &keySet{validator: v, url: config.JWKSURL}
+	return v, nil
+}
+
+type header struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+func invalid(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrInvalidToken, fmt.Sprintf(format, args...))
+}
+
+// Validate verifies the signature of token and checks its claims.
+func (v *Validator) Validate(ctx context.Context, token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, invalid("not a JWT")
+	}
+	var head header
+	if err := decodeSegment(parts[0], &head); err != nil {
+		return nil, invalid("header: %v", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, invalid("signature: %v", err)
+	}
+	key, err := v.keys.key(ctx, head.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	if err := verify(head.Algorithm, key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	var raw map[string]any
+	if err := decodeSegment(parts[1], &raw); err != nil {
+		return nil, invalid("claims: %v", err)
+	}
+	claims, err := ParseClaims(raw)
+	if err != nil {
+		return nil, err
+	}
+	if err := v.check(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func decodeSegment(segment string, out any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// verify checks the signature of signed with key for the algorithm of the
+// header, which must be the algorithm of the key when the JWKS names one.
+func verify(algorithm string, key *jsonWebKey, signed string, signature []byte) error {
+	if key.Algorithm != "" && key.Algorithm != algorithm {
+		return invalid("algorithm %s does not match the key %s", algorithm, key.KeyID)
+	}
+	var hash crypto.Hash
+	switch algorithm[min(2, len(algorithm)):] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	default:
+		return invalid("unsupported algorithm %q", algorithm)
+	}
+	digest := hash.New()
+	digest.Write([]byte(signed))
+	sum := digest.Sum(nil)
+
+	switch public := key.public.(type) {
+	case *rsa.PublicKey:
+		switch algorithm[:2] {
+		case "RS":
+			if rsa.VerifyPKCS1v15(public, hash, sum, signature) == nil {
+				return nil
+			}
+		case "PS":
+			if rsa.VerifyPSS(public, hash, sum, signature, nil) == nil {
+				return nil
+			}
+		default:
+			return invalid("algorithm %s does not match the RSA key %s", algorithm, key.KeyID)
+		}
+	case *ecdsa.PublicKey:
+		size := (public.Curve.Params().BitSize + 7) / 8
+		if algorithm[:2] != "ES" || len(signature) != 2*size {
+			return invalid("algorithm %s does not match the EC key %s", algorithm, key.KeyID)
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if ecdsa.Verify(public, sum, r, s) {
+			return nil
+		}
+	}
+	return invalid("bad signature")
+}
+
+// ParseClaims reads the registered claims of raw, i.e. of an RFC 7662
+// introspection response, without checking them.
+func ParseClaims(raw map[string]any) (*Claims, error) {
+	claims := &Claims{Raw: raw}
+	claims.Issuer, _ = raw["iss"].(string)
+	claims.Subject, _ = raw["sub"].(string)
+	claims.Scope, _ = raw["scope"].(string)
+	switch audience := raw["aud"].(type) {
+	case string:
+		claims.Audience = []string{audience}
+	case []any:
+		for _, item := range audience {
+			if name, ok := item.(string); ok {
+				claims.Audience = append(claims.Audience, name)
+			}
+		}
+	}
+	for name, field := range map[string]*time.Time{"exp": &claims.ExpiresAt, "nbf": &claims.NotBefore, "iat": &claims.IssuedAt} {
+		value, ok := raw[name]
+		if !ok {
+			continue
+		}
+		seconds, ok := value.(float64)
+		if !ok {
+			return nil, invalid("%s is not a number", name)
+		}
+		*field = time.Unix(int64(seconds), 0)
+	}
+	return claims, nil
+}
+
+func (v *Validator) check(claims *Claims) error {
+	now := v.Clock()
+	switch {
+	case claims.Issuer != v.config.Issuer:
+		return invalid("issuer %q is not %q", claims.Issuer, v.config.Issuer)
+	case !claims.HasAudience(v.config.Audience):
+		return invalid("audience %q is not accepted", claims.Audience)
+	case claims.ExpiresAt.IsZero():
+		return invalid("the token has no expiry")
+	case !now.Before(claims.ExpiresAt.Add(v.Leeway)):
+		return invalid("the token expired at %s", claims.ExpiresAt.UTC().Format(time.RFC3339))
+	case !claims.NotBefore.IsZero() && now.Before(claims.NotBefore.Add(-v.Leeway)):
+		return invalid("the token is not valid before %s", claims.NotBefore.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// HasAudience reports whether the aud claim names one of accepted, any
+// audience is accepted when empty.
+func (c *Claims) HasAudience(accepted []string) bool {
+	return len(accepted) == 0 || slices.ContainsFunc(c.Audience, func(audience string) bool { return slices.Contains(accepted, audience) })
+}
+
+type claimsKey struct{}
+
+// ClaimsFromContext returns the claims of the token the request was
+// authenticated with by Middleware.
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(*Claims)
+	return claims, ok
+}
+
+// Middleware validates the bearer token of the requests with validator and
+// passes its claims on in the context. Requests without a valid token are
+// passed to reject along with the error, which answers them.
+func Middleware(validator TokenValidator, reject func(w http.ResponseWriter, r *http.Request, err error)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || token == "" {
+				reject(w, r, invalid("missing bearer token"))
+				return
+			}
+			claims, err := validator.Validate(r.Context(), token)
+			if err != nil {
+				reject(w, r, err)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), claimsKey{}, claims)))
+		})
+	}
+}
@@ -0,0 +1,283 @@
+package jwtauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// issuer is a fake authorization server publishing its metadata and JWKS.
+type issuer struct {
+	*httptest.Server
+	rsaKey *rsa.PrivateKey
+	ecKey  *ecdsa.PrivateKey
+	// jwksFetches counts the fetches of the JWKS
+	jwksFetches atomic.Int32
+	// down fails the fetches of the JWKS
+	down atomic.Bool
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func newIssuer(t *testing.T) *issuer {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iss := &issuer{rsaKey: rsaKey, ecKey: ecKey}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/oauth-authorization-server", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"issuer": iss.URL, "jwks_uri": iss.URL + "/jwks"})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		iss.jwksFetches.Add(1)
+		if iss.down.Load() {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{
+			{"kty": "RSA", "kid": "rsa", "use": "sig", "alg": "RS256", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+			{"kty": "EC", "kid": "ec", "crv": "P-256", "x": b64(ecKey.X.FillBytes(make([]byte, 32))), "y": b64(ecKey.Y.FillBytes(make([]byte, 32)))},
+			{"kty": "OKP", "kid": "unsupported", "crv": "Ed25519", "x": "AA"},
+		}})
+	})
+	iss.Server = httptest.NewServer(mux)
+	t.Cleanup(iss.Close)
+	return iss
+}
+
+// sign returns a JWT of claims signed by the key kid with alg.
+func (iss *issuer) sign(t *testing.T, alg, kid string, claims map[string]any) string {
+	t.Helper()
+	head, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	body, _ := json.Marshal(claims)
+	signed := b64(head) + "." + b64(body)
+	sum := sha256.Sum256([]byte(signed))
+	var signature []byte
+	var err error
+	switch alg {
+	case "RS256":
+		signature, err = rsa.SignPKCS1v15(rand.Reader, iss.rsaKey, crypto.SHA256, sum[:])
+	case "PS256":
+		signature, err = rsa.SignPSS(rand.Reader, iss.rsaKey, crypto.SHA256, sum[:], &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+	case "ES256":
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, iss.ecKey, sum[:])
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	default:
+		signature = []byte("unsigned")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + b64(signature)
+}
+
+func TestValidate(t *testing.T) {
+	iss := newIssuer(t)
+	now := time.Unix(1700000000, 0)
+	claims := func(changes map[string]any) map[string]any {
+		base := map[string]any{"iss": iss.URL, "sub": "user", "aud": []string{"other", "mcp"}, "exp": now.Add(time.Hour).Unix(), "scope": "read"}
+		for name, value := range changes {
+			if value == nil {
+				delete(base, name)
+			} else {
+				base[name] = value
+			}
+		}
+		return base
+	}
+
+	tests := []struct {
+		name  string
+		token func() string
+		// wantErr is whether the token is rejected with ErrInvalidToken
+		wantErr bool
+	}{
+		{"RS256", func() string { return iss.sign(t, "RS256", "rsa", claims(nil)) }, false},
+		{"ES256", func() string { return iss.sign(t, "ES256", "ec", claims(nil)) }, false},
+		{"audience string", func() string { return iss.sign(t, "ES256", "ec", claims(map[string]any{"aud": "mcp"})) }, false},
+		{"within leeway", func() string {
+			return iss.sign(t, "RS256", "rsa", claims(map[string]any{"exp": now.Add(-30 * time.Second).Unix()}))
+		}, false},
+		{"algorithm of the key", func() string { return iss.sign(t, "PS256", "rsa", claims(nil)) }, true},
+		{"none", func() string { return iss.sign(t, "none", "rsa", claims(nil)) }, true},
+		{"HS256", func() string { return iss.sign(t, "HS256", "ec", claims(nil)) }, true},
+		{"tampered claims", func() string {
+			token := strings.Split(iss.sign(t, "RS256", "rsa", claims(nil)), ".")
+			forged := strings.Split(iss.sign(t, "RS256", "rsa", claims(map[string]any{"sub": "admin"})), ".")
+			return token[0] + "." + forged[1] + "." + token[2]
+		}, true},
+		{"unknown key", func() string { return iss.sign(t, "RS256", "rotated", claims(nil)) }, true},
+		{"issuer", func() string {
+			return iss.sign(t, "RS256", "rsa", claims(map[string]any{"iss": "https://evil.example"}))
+		}, true},
+		{"audience", func() string { return iss.sign(t, "RS256", "rsa", claims(map[string]any{"aud": "other"})) }, true},
+		{"expired", func() string {
+			return iss.sign(t, "RS256", "rsa", claims(map[string]any{"exp": now.Add(-2 * time.Minute).Unix()}))
+		}, true},
+		{"no expiry", func() string { return iss.sign(t, "RS256", "rsa", claims(map[string]any{"exp": nil})) }, true},
+		{"not yet valid", func() string {
+			return iss.sign(t, "RS256", "rsa", claims(map[string]any{"nbf": now.Add(time.Hour).Unix()}))
+		}, true},
+		{"not a JWT", func() string { return "opaque-spotify-token" }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validator, err := New(Config{Issuer: iss.URL, Audience: []string{"mcp"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			validator.Clock = func() time.Time { return now }
+
+			got, err := validator.Validate(context.Background(), tt.token())
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidToken) || got != nil {
+					t.Fatalf("Validate() = %v, %v, want %v", got, err, ErrInvalidToken)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if got.Subject != "user" || got.Scope != "read" || !slices.Contains(got.Audience, "mcp") {
+				t.Errorf("Validate() = %+v, want the claims of the token", got)
+			}
+		})
+	}
+}
+
+func TestKeyRefresh(t *testing.T) {
+	iss := newIssuer(t)
+	now := time.Unix(1700000000, 0)
+	validator, err := New(Config{Issuer: iss.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	validator.Clock = func() time.Time { return now }
+	token := iss.sign(t, "RS256", "rsa", map[string]any{"iss": iss.URL, "exp": now.Add(2 * time.Hour).Unix()})
+	unknown := iss.sign(t, "RS256", "rotated", map[string]any{"iss": iss.URL, "exp": now.Add(2 * time.Hour).Unix()})
+
+	for range 3 {
+		if _, err := validator.Validate(context.Background(), token); err != nil {
+			t.Fatal(err)
+		}
+		validator.Validate(context.Background(), unknown)
+	}
+	if got := iss.jwksFetches.Load(); got != 1 {
+		t.Errorf("fetched the JWKS %d times, want once within MinRefreshInterval", got)
+	}
+	now = now.Add(MinRefreshInterval)
+	validator.Validate(context.Background(), unknown)
+	if got := iss.jwksFetches.Load(); got != 2 {
+		t.Errorf("fetched the JWKS %d times, want a refresh for the unknown key", got)
+	}
+	now = now.Add(KeysTTL)
+	iss.Close()
+	if _, err := validator.Validate(context.Background(), token); err != nil {
+		t.Errorf("Validate() error = %v, want the cached key while the issuer is down", err)
+	}
+}
+
+func TestFailedFetchThrottled(t *testing.T) {
+	iss := newIssuer(t)
+	iss.down.Store(true)
+	now := time.Unix(1700000000, 0)
+	validator, err := New(Config{Issuer: iss.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	validator.Clock = func() time.Time { return now }
+	token := iss.sign(t, "RS256", "rsa", map[string]any{"iss": iss.URL, "exp": now.Add(2 * time.Hour).Unix()})
+
+	for range 3 {
+		if _, err := validator.Validate(context.Background(), token); err == nil || errors.Is(err, ErrInvalidToken) {
+			t.Fatalf("Validate() error = %v, want the fetch error", err)
+		}
+	}
+	if got := iss.jwksFetches.Load(); got != 1 {
+		t.Errorf("fetched the JWKS %d times, want once within MinRefreshInterval of the failure", got)
+	}
+	if err := validator.Refresh(context.Background()); err == nil {
+		t.Error("Refresh() succeeded while the issuer is down")
+	}
+
+	iss.down.Store(false)
+	now = now.Add(MinRefreshInterval)
+	if _, err := validator.Validate(context.Background(), token); err != nil {
+		t.Errorf("Validate() error = %v, want a fetch after MinRefreshInterval", err)
+	}
+}
+
+// TestCancelledCallerNotCached checks that a caller giving up does not fail
+// the fetch of the JWKS, nor get a failure cached for the next callers.
+func TestCancelledCallerNotCached(t *testing.T) {
+	iss := newIssuer(t)
+	now := time.Unix(1700000000, 0)
+	validator, err := New(Config{Issuer: iss.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	validator.Clock = func() time.Time { return now }
+	token := iss.sign(t, "RS256", "rsa", map[string]any{"iss": iss.URL, "exp": now.Add(2 * time.Hour).Unix()})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	validator.Validate(ctx, token)
+	if _, err := validator.Validate(context.Background(), token); err != nil {
+		t.Errorf("Validate() error = %v after a cancelled caller, want the fetched key", err)
+	}
+	if got := iss.jwksFetches.Load(); got != 1 {
+		t.Errorf("fetched the JWKS %d times, want once", got)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	validator := TokenValidatorFunc(func(ctx context.Context, token string) (*Claims, error) {
+		if token != "good" {
+			return nil, ErrInvalidToken
+		}
+		return &Claims{Subject: "user"}, nil
+	})
+	handler := Middleware(validator, func(w http.ResponseWriter, r *http.Request, err error) {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, _ := ClaimsFromContext(r.Context())
+		w.Write([]byte(claims.Subject))
+	}))
+
+	for header, want := range map[string]int{"": http.StatusUnauthorized, "Bearer bad": http.StatusUnauthorized, "Basic good": http.StatusUnauthorized, "Bearer good": http.StatusOK} {
+		req := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+		req.Header.Set("Authorization", header)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("Authorization %q: status %d, want %d", header, rec.Code, want)
+		}
+		if want == http.StatusOK && rec.Body.String() != "user" {
+			t.Errorf("handler got claims %q, want the claims of the token", rec.Body.String())
+		}
+	}
+}
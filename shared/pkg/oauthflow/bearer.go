@@ -0,0 +1,85 @@
+package oauthflow
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// ProtectedResource is the protected resource metadata of RFC 9728, which the
+// challenge of a rejected request points clients to.
+type ProtectedResource struct {
+	// Required: The uri that uniquely identifies the resource.
+	Resource string `json:"resource"`
+	// Lists the authorization servers that can be used to access the resource.
+	AuthorizationServers []string `json:"authorization_servers"`
+	// Optional: The OAuth 2.0 presentation methods supported by the resource.
+	BearerMethodsSupported []string `json:"bearer_methods_supported,omitempty"`
+	// Optional: Where the resource's public keys live
+	JwksURI string `json:"jwks_uri,omitempty"`
+	// Recommended
+	ScopesSupported []string `json:"scopes_supported,omitempty"`
+}
+
+// ServeHTTP serves the metadata on /.well-known/oauth-protected-resource.
+func (p ProtectedResource) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := json.Marshal(p)
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+// Challenge is the Bearer challenge of RFC 6750 sent in the WWW-Authenticate
+// header of a rejected request.
+type Challenge struct {
+	Realm string
+	// ResourceMetadata is the URL of the ProtectedResource.
+	ResourceMetadata string
+	// AuthorizationURI is the authorization endpoint, optional.
+	AuthorizationURI string
+	// Error is the error code, i.e. invalid_token.
+	Error string
+}
+
+func (c Challenge) String() string {
+	params := []string{fmt.Sprintf("realm=%q", c.Realm), fmt.Sprintf("resource_metadata=%q", c.ResourceMetadata)}
+	if c.AuthorizationURI != "" {
+		params = append(params, fmt.Sprintf("authorization_uri=%q", c.AuthorizationURI))
+	}
+	if c.Error != "" {
+		params = append(params, fmt.Sprintf("error=%q", c.Error))
+	}
+	return "Bearer " + strings.Join(params, ",")
+}
+
+// Reject answers a request with 401 and the challenge.
+func (c Challenge) Reject(w http.ResponseWriter) {
+	body, _ := json.Marshal(map[string]string{"error": c.Error})
+	w.Header().Set("WWW-Authenticate", c.String())
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+	w.Write(body)
+}
+
+// BearerToken returns the token of the Bearer Authorization header of r.
+func BearerToken(r *http.Request) (string, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return token, ok && token != ""
+}
+
+// RequireBearer rejects the requests without a Bearer token with the
+// challenge returned for them. The token itself is left to next.
+func RequireBearer(challenge func(r *http.Request) Challenge) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := BearerToken(r); !ok {
+				c := challenge(r)
+				log.Printf("Missing bearer token, pointing to %s", c.ResourceMetadata)
+				c.Reject(w)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package oauthflow
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireBearer(t *testing.T) {
+	challenge := Challenge{
+		Realm:            "test",
+		ResourceMetadata: "https://mcp.example.com/.well-known/oauth-protected-resource",
+		Error:            "unauthorized",
+	}
+	handler := RequireBearer(func(r *http.Request) Challenge { return challenge })(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for authorization, want := range map[string]int{
+		"Bearer token": http.StatusOK,
+		"":             http.StatusUnauthorized,
+		"Bearer ":      http.StatusUnauthorized,
+		"token":        http.StatusUnauthorized,
+		"Basic dXNlcg": http.StatusUnauthorized,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("Authorization %q answered %d, want %d", authorization, rec.Code, want)
+		}
+		if want == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") != challenge.String() {
+			t.Errorf("Authorization %q challenged with %q", authorization, rec.Header().Get("WWW-Authenticate"))
+		}
+	}
+
+	want := `Bearer realm="test",resource_metadata="https://mcp.example.com/.well-known/oauth-protected-resource",error="unauthorized"`
+	if got := challenge.String(); got != want {
+		t.Errorf("String() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,285 @@
+// Package oauthflow runs the OAuth authorization code flow with PKCE for any
+// provider, the servers only differ in their oauth2.Config and token store.
+package oauthflow
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/grokify/go-pkce"
+	"github.com/wagnerjt/go-mcp/shared/pkg/upstream"
+	"golang.org/x/oauth2"
+)
+
+// DefaultStateTTL is how long a login may take from its start to the
+// callback.
+const DefaultStateTTL = 10 * time.Minute
+
+// the query parameters of the redirect
+const (
+	QueryState string = "state"
+	QueryCode  string = "code"
+)
+
+// Stage is the stage of a login: start → pending → exchanged → stored.
+type Stage int
+
+const (
+	StageStart Stage = iota
+	StagePending
+	StageExchanged
+	StageStored
+)
+
+func (s Stage) String() string {
+	switch s {
+	case StageStart:
+		return "start"
+	case StagePending:
+		return "pending"
+	case StageExchanged:
+		return "exchanged"
+	case StageStored:
+		return "stored"
+	default:
+		return fmt.Sprintf("Stage(%d)", int(s))
+	}
+}
+
+var (
+	// ErrInvalidCallback is a callback without a code or a state.
+	ErrInvalidCallback = errors.New("missing code or state parameter")
+	// ErrUnknownState is a callback for a login that was not started or
+	// already finished.
+	ErrUnknownState = errors.New("invalid state")
+	// ErrStateExpired is a callback for a login older than the state TTL.
+	ErrStateExpired = errors.New("login expired")
+	// ErrConsentDenied is a callback of a user that declined the consent.
+	ErrConsentDenied = errors.New("consent denied")
+	// ErrExchangeFailed is a code the token endpoint did not exchange.
+	ErrExchangeFailed = errors.New("failed to exchange token")
+)
+
+// Login is an authorization in progress, keyed by its state.
+type Login struct {
+	State        string
+	CodeVerifier string
+	RedirectURI  string
+	Scopes       []string
+	// incremental logins add to the scopes of the stored token
+	Incremental bool
+	Started     time.Time
+	Stage       Stage
+}
+
+// TokenStore stores the token of a finished login and returns the scopes
+// it was granted.
+type TokenStore interface {
+	StoreToken(token *oauth2.Token, login Login) ([]string, error)
+}
+
+// TokenStoreFunc adapts a function to a TokenStore.
+type TokenStoreFunc func(token *oauth2.Token, login Login) ([]string, error)
+
+func (f TokenStoreFunc) StoreToken(token *oauth2.Token, login Login) ([]string, error) {
+	return f(token, login)
+}
+
+// Flow runs the PKCE authorization code flow. The clock and HTTP client
+// used for the token exchange are injected so the flow can be tested
+// without the provider.
+type Flow struct {
+	// Config returns the OAuth config, resolved per login so reloaded
+	// credentials are used.
+	Config func() *oauth2.Config
+	Store  TokenStore
+	// Clock defaults to time.Now.
+	Clock func() time.Time
+	// Rand is the source of the states and code verifiers, crypto/rand by
+	// default.
+	Rand io.Reader
+	// HTTPClient defaults to http.DefaultClient.
+	HTTPClient *http.Client
+	// StateTTL defaults to DefaultStateTTL.
+	StateTTL time.Duration
+	// Sessions issues a session cookie after a successful login when set.
+	Sessions *Sessions
+
+	mu      sync.Mutex
+	pending map[string]Login
+}
+
+// New creates a Flow with the default clock, randomness, client and TTL.
+func New(config func() *oauth2.Config, store TokenStore) *Flow {
+	return &Flow{
+		Config:   config,
+		Store:    store,
+		Clock:    time.Now,
+		Rand:     rand.Reader,
+		StateTTL: DefaultStateTTL,
+	}
+}
+
+// random reads n bytes of f.Rand.
+func (f *Flow) random(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(f.Rand, b); err != nil {
+		return nil, fmt.Errorf("failed to read randomness: %w", err)
+	}
+	return b, nil
+}
+
+// Start begins a login for scopes and returns the authorization URL to
+// send the user to.
+func (f *Flow) Start(redirectURI string, scopes []string, incremental bool) (string, Login, error) {
+	state, err := f.random(16)
+	if err != nil {
+		return "", Login{}, err
+	}
+	verifier, err := f.random(48)
+	if err != nil {
+		return "", Login{}, err
+	}
+	login := Login{
+		State:        hex.EncodeToString(state),
+		CodeVerifier: pkce.NewCodeVerifierBytes(verifier),
+		RedirectURI:  redirectURI,
+		Scopes:       scopes,
+		Incremental:  incremental,
+		Started:      f.Clock(),
+		Stage:        StageStart,
+	}
+
+	config := f.Config()
+	config.RedirectURL = redirectURI
+	config.Scopes = scopes
+	opts := []oauth2.AuthCodeOption{
+		oauth2.SetAuthURLParam(pkce.ParamCodeChallenge, pkce.CodeChallengeS256(login.CodeVerifier)),
+		oauth2.SetAuthURLParam(pkce.ParamCodeChallengeMethod, pkce.MethodS256),
+	}
+	if incremental {
+		opts = append(opts, oauth2.SetAuthURLParam("include_granted_scopes", "true"))
+	}
+	authURL := config.AuthCodeURL(login.State, opts...)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.pending == nil {
+		f.pending = make(map[string]Login)
+	}
+	// logins of users that never came back are never taken
+	for state, pending := range f.pending {
+		if f.expired(pending) {
+			delete(f.pending, state)
+		}
+	}
+	login.Stage = StagePending
+	f.pending[login.State] = login
+	return authURL, login, nil
+}
+
+func (f *Flow) expired(login Login) bool {
+	return f.Clock().Sub(login.Started) > f.StateTTL
+}
+
+// take removes the pending login of state, each state is used once.
+func (f *Flow) take(state string) (Login, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	login, ok := f.pending[state]
+	delete(f.pending, state)
+	return login, ok
+}
+
+// Callback finishes the login the redirect query belongs to: it exchanges
+// the code and stores the token. The login is returned in the stage it
+// reached along with the granted scopes.
+func (f *Flow) Callback(ctx context.Context, query url.Values) (Login, []string, error) {
+	state := query.Get(QueryState)
+	if denied := query.Get("error"); denied != "" {
+		// the login is over either way
+		login, _ := f.take(state)
+		if description := query.Get("error_description"); description != "" {
+			denied += ": " + description
+		}
+		return login, nil, fmt.Errorf("%w: %s", ErrConsentDenied, denied)
+	}
+	code := query.Get(QueryCode)
+	if code == "" || state == "" {
+		return Login{}, nil, ErrInvalidCallback
+	}
+
+	login, ok := f.take(state)
+	if !ok {
+		return Login{}, nil, ErrUnknownState
+	}
+	if f.expired(login) {
+		return login, nil, ErrStateExpired
+	}
+
+	if f.HTTPClient != nil {
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, f.HTTPClient)
+	}
+	// the redirect URI has to match the one of the authorization request
+	config := f.Config()
+	config.RedirectURL = login.RedirectURI
+	token, err := config.Exchange(ctx, code,
+		oauth2.SetAuthURLParam(pkce.ParamCodeVerifier, login.CodeVerifier),
+	)
+	if err != nil {
+		return login, nil, fmt.Errorf("%w: %w", ErrExchangeFailed, upstream.Error(ctx, err))
+	}
+	login.Stage = StageExchanged
+
+	scopes, err := f.Store.StoreToken(token, login)
+	if err != nil {
+		return login, nil, fmt.Errorf("failed to store token: %w", err)
+	}
+	login.Stage = StageStored
+	return login, scopes, nil
+}
+
+// ServeHTTP serves the OAuth redirect URI.
+func (f *Flow) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, scopes, err := f.Callback(r.Context(), r.URL.Query())
+	if err != nil {
+		log.Printf("OAuth callback failed: %v", err)
+		http.Error(w, err.Error(), callbackStatus(err))
+		return
+	}
+	log.Printf("Token granted scopes %v", scopes)
+	if f.Sessions != nil {
+		if _, err := f.Sessions.Issue(w); err != nil {
+			log.Printf("Failed to issue a session: %v", err)
+			http.Error(w, "Failed to issue a session", http.StatusInternalServerError)
+			return
+		}
+	}
+	// Redirect to a success page or return a message
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"status":"authenticated"}`))
+}
+
+func callbackStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrInvalidCallback), errors.Is(err, ErrUnknownState), errors.Is(err, ErrStateExpired):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrConsentDenied):
+		return http.StatusForbidden
+	case errors.Is(err, upstream.ErrTimeout):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, ErrExchangeFailed):
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
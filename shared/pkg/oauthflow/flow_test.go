@@ -0,0 +1,335 @@
+package oauthflow
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wagnerjt/go-mcp/shared/pkg/upstream"
+	"golang.org/x/oauth2"
+)
+
+const testRedirectURI = "http://127.0.0.1:8080/auth/callback"
+
+// tokenEndpoint fakes the token endpoint: it checks the code and the PKCE
+// verifier of the last started login and answers with status and body.
+type tokenEndpoint struct {
+	status   int
+	body     string
+	verifier string
+	requests int
+}
+
+func (e *tokenEndpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.requests++
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if r.PostForm.Get("code_verifier") != e.verifier || r.PostForm.Get("redirect_uri") != testRedirectURI {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid_grant"}`))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(e.status)
+	w.Write([]byte(e.body))
+}
+
+type fakeClock struct{ now time.Time }
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func TestFlowCallback(t *testing.T) {
+	tests := []struct {
+		name string
+		// query builds the callback query from the state of the started login
+		query     func(state string) url.Values
+		advance   time.Duration
+		status    int
+		body      string
+		storeErr  error
+		wantErr   error
+		wantStage Stage
+		wantCode  int
+		exchanged bool
+	}{
+		{
+			name:      "stored",
+			query:     func(state string) url.Values { return url.Values{"code": {"abc"}, "state": {state}} },
+			status:    http.StatusOK,
+			body:      `{"access_token":"access","token_type":"Bearer","refresh_token":"refresh","expires_in":3600,"scope":"user-read-private"}`,
+			wantStage: StageStored,
+			wantCode:  http.StatusOK,
+			exchanged: true,
+		},
+		{
+			name: "denied consent",
+			query: func(state string) url.Values {
+				return url.Values{"error": {"access_denied"}, "state": {state}}
+			},
+			wantErr:   ErrConsentDenied,
+			wantStage: StagePending,
+			wantCode:  http.StatusForbidden,
+		},
+		{
+			name:      "missing code",
+			query:     func(state string) url.Values { return url.Values{"state": {state}} },
+			wantErr:   ErrInvalidCallback,
+			wantCode:  http.StatusBadRequest,
+			wantStage: StageStart,
+		},
+		{
+			name:      "unknown state",
+			query:     func(string) url.Values { return url.Values{"code": {"abc"}, "state": {"forged"}} },
+			wantErr:   ErrUnknownState,
+			wantCode:  http.StatusBadRequest,
+			wantStage: StageStart,
+		},
+		{
+			name:      "expired state",
+			query:     func(state string) url.Values { return url.Values{"code": {"abc"}, "state": {state}} },
+			advance:   DefaultStateTTL + time.Second,
+			wantErr:   ErrStateExpired,
+			wantStage: StagePending,
+			wantCode:  http.StatusBadRequest,
+		},
+		{
+			name:      "exchange failure",
+			query:     func(state string) url.Values { return url.Values{"code": {"abc"}, "state": {state}} },
+			status:    http.StatusBadRequest,
+			body:      `{"error":"invalid_grant","error_description":"Invalid authorization code"}`,
+			wantErr:   ErrExchangeFailed,
+			wantStage: StagePending,
+			wantCode:  http.StatusBadGateway,
+			exchanged: true,
+		},
+		{
+			name:      "store failure",
+			query:     func(state string) url.Values { return url.Values{"code": {"abc"}, "state": {state}} },
+			status:    http.StatusOK,
+			body:      `{"access_token":"access","token_type":"Bearer","expires_in":3600}`,
+			storeErr:  errors.New("disk full"),
+			wantStage: StageExchanged,
+			wantCode:  http.StatusInternalServerError,
+			exchanged: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			endpoint := &tokenEndpoint{status: tt.status, body: tt.body}
+			upstream := httptest.NewServer(endpoint)
+			defer upstream.Close()
+
+			var stored *oauth2.Token
+			clock := &fakeClock{now: time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)}
+			flow := New(func() *oauth2.Config {
+				return &oauth2.Config{
+					ClientID:     "client",
+					ClientSecret: "secret",
+					Endpoint:     oauth2.Endpoint{AuthURL: upstream.URL + "/authorize", TokenURL: upstream.URL + "/token"},
+				}
+			}, TokenStoreFunc(func(token *oauth2.Token, login Login) ([]string, error) {
+				if tt.storeErr != nil {
+					return nil, tt.storeErr
+				}
+				stored = token
+				return login.Scopes, nil
+			}))
+			flow.Clock = clock.Now
+			flow.HTTPClient = upstream.Client()
+
+			authURL, started, err := flow.Start(testRedirectURI, []string{"user-read-private"}, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if started.Stage != StagePending {
+				t.Errorf("started login is %s, want pending", started.Stage)
+			}
+			parsed, _ := url.Parse(authURL)
+			if got := parsed.Query().Get("state"); got != started.State {
+				t.Errorf("authorization URL state %q, want %q", got, started.State)
+			}
+			if parsed.Query().Get("code_challenge") == "" || parsed.Query().Get("code_challenge_method") != "S256" {
+				t.Errorf("authorization URL without PKCE challenge: %s", authURL)
+			}
+			endpoint.verifier = started.CodeVerifier
+			clock.now = clock.now.Add(tt.advance)
+
+			login, scopes, err := flow.Callback(context.Background(), tt.query(started.State))
+			switch {
+			case tt.wantErr != nil && !errors.Is(err, tt.wantErr):
+				t.Errorf("error %v, want %v", err, tt.wantErr)
+			case tt.storeErr != nil && !errors.Is(err, tt.storeErr):
+				t.Errorf("error %v, want %v", err, tt.storeErr)
+			case tt.wantErr == nil && tt.storeErr == nil && err != nil:
+				t.Errorf("unexpected error %v", err)
+			}
+			if login.Stage != tt.wantStage {
+				t.Errorf("login is %s, want %s", login.Stage, tt.wantStage)
+			}
+			if got := endpoint.requests > 0; got != tt.exchanged {
+				t.Errorf("token endpoint called %v, want %v", got, tt.exchanged)
+			}
+			if err != nil {
+				if code := callbackStatus(err); code != tt.wantCode {
+					t.Errorf("status %d, want %d", code, tt.wantCode)
+				}
+				return
+			}
+			if stored == nil || stored.AccessToken != "access" || stored.RefreshToken != "refresh" {
+				t.Errorf("stored token %+v", stored)
+			}
+			if !slices.Equal(scopes, []string{"user-read-private"}) {
+				t.Errorf("scopes %v", scopes)
+			}
+		})
+	}
+}
+
+func TestFlowStateIsUsedOnce(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"access","token_type":"Bearer"}`))
+	}))
+	defer upstream.Close()
+
+	flow := New(func() *oauth2.Config {
+		return &oauth2.Config{Endpoint: oauth2.Endpoint{TokenURL: upstream.URL}}
+	}, TokenStoreFunc(func(*oauth2.Token, Login) ([]string, error) { return nil, nil }))
+	flow.HTTPClient = upstream.Client()
+
+	_, login, err := flow.Start(testRedirectURI, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := url.Values{"code": {"abc"}, "state": {login.State}}
+	if _, _, err := flow.Callback(context.Background(), query); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := flow.Callback(context.Background(), query); !errors.Is(err, ErrUnknownState) {
+		t.Errorf("replayed callback: error %v, want %v", err, ErrUnknownState)
+	}
+}
+
+func TestFlowCallbackAbortsExchange(t *testing.T) {
+	tests := []struct {
+		name     string
+		ctx      func() (context.Context, context.CancelFunc)
+		wantErr  error
+		wantCode int
+	}{
+		{
+			name: "canceled",
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				time.AfterFunc(50*time.Millisecond, cancel)
+				return ctx, cancel
+			},
+			wantErr:  upstream.ErrCanceled,
+			wantCode: http.StatusBadGateway,
+		},
+		{
+			name: "deadline",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 50*time.Millisecond)
+			},
+			wantErr:  upstream.ErrTimeout,
+			wantCode: http.StatusGatewayTimeout,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the token endpoint hangs until the exchange is aborted
+			done := make(chan struct{})
+			tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-r.Context().Done():
+				case <-done:
+				}
+			}))
+			defer tokenServer.Close()
+			defer close(done)
+
+			flow := New(func() *oauth2.Config {
+				return &oauth2.Config{Endpoint: oauth2.Endpoint{TokenURL: tokenServer.URL}}
+			}, TokenStoreFunc(func(*oauth2.Token, Login) ([]string, error) { return nil, nil }))
+			flow.HTTPClient = tokenServer.Client()
+			_, login, err := flow.Start(testRedirectURI, nil, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := tt.ctx()
+			defer cancel()
+			start := time.Now()
+			_, _, err = flow.Callback(ctx, url.Values{"code": {"abc"}, "state": {login.State}})
+			if !errors.Is(err, ErrExchangeFailed) || !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error %v, want %v and %v", err, ErrExchangeFailed, tt.wantErr)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("exchange returned after %v, want it aborted", elapsed)
+			}
+			if code := callbackStatus(err); code != tt.wantCode {
+				t.Errorf("status %d, want %d", code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestFlowStartIsDeterministic(t *testing.T) {
+	start := func() (string, Login) {
+		flow := New(func() *oauth2.Config {
+			return &oauth2.Config{Endpoint: oauth2.Endpoint{AuthURL: "https://provider/authorize"}}
+		}, nil)
+		flow.Clock = func() time.Time { return time.Unix(1700000000, 0) }
+		flow.Rand = bytes.NewReader(bytes.Repeat([]byte{7}, 64))
+		authURL, login, err := flow.Start(testRedirectURI, nil, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return authURL, login
+	}
+
+	firstURL, first := start()
+	secondURL, second := start()
+	if firstURL != secondURL || first.State != second.State || first.CodeVerifier != second.CodeVerifier {
+		t.Errorf("same clock and randomness started different logins:\n%s\n%s", firstURL, secondURL)
+	}
+	if want := strings.Repeat("07", 16); first.State != want {
+		t.Errorf("state %q, want %q", first.State, want)
+	}
+}
+
+func TestFlowStartFailsWithoutRandomness(t *testing.T) {
+	flow := New(func() *oauth2.Config { return &oauth2.Config{} }, nil)
+	flow.Rand = bytes.NewReader(nil)
+	if _, _, err := flow.Start(testRedirectURI, nil, false); err == nil {
+		t.Error("started a login without randomness")
+	}
+}
+
+func TestFlowServeHTTP(t *testing.T) {
+	flow := New(func() *oauth2.Config { return &oauth2.Config{} }, nil)
+	for query, want := range map[string]int{
+		"":                            http.StatusBadRequest,
+		"code=abc&state=unknown":      http.StatusBadRequest,
+		"error=access_denied&state=x": http.StatusForbidden,
+	} {
+		rec := httptest.NewRecorder()
+		flow.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/auth/callback?%s", query), nil))
+		if rec.Code != want {
+			t.Errorf("callback ?%s: status %d, want %d", query, rec.Code, want)
+		}
+	}
+}
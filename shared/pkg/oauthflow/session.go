@@ -0,0 +1,212 @@
+package oauthflow
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultSessionCookie is the name of the session cookie.
+	DefaultSessionCookie = "mcp_session"
+	// DefaultSessionTTL is how long a session cookie is valid.
+	DefaultSessionTTL = 24 * time.Hour
+)
+
+var ErrInvalidSession = errors.New("invalid session cookie")
+
+// Sessions issues signed, HttpOnly session cookies after a login, for
+// browser clients that cannot set an Authorization header, i.e. on an
+// EventSource. A cookie holds a session ID and its expiry, signed with
+// HMAC-SHA256, so the server only keeps the revoked sessions until they
+// expire. The revocations are kept in memory: a restart with the same Key
+// makes the revoked cookies valid again until their expiry.
+type Sessions struct {
+	// Name defaults to DefaultSessionCookie.
+	Name string
+	// Key signs the cookies, cookies of other keys are rejected.
+	Key []byte
+	// TTL defaults to DefaultSessionTTL.
+	TTL time.Duration
+	// Secure only sends the cookie over HTTPS, set it when the server is
+	// served over HTTPS.
+	Secure bool
+	// Clock defaults to time.Now.
+	Clock func() time.Time
+	// Rand is the source of the session IDs, crypto/rand by default.
+	Rand io.Reader
+
+	mu sync.Mutex
+	// revoked are the expiries of the revoked session IDs
+	revoked map[string]time.Time
+	// revokedBefore rejects the cookies issued before, see RevokeAll
+	revokedBefore time.Time
+}
+
+// NewSessions creates Sessions signing with key, a random key when empty,
+// which invalidates the cookies on every restart.
+func NewSessions(key []byte) (*Sessions, error) {
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("failed to generate the session key: %w", err)
+		}
+	}
+	return &Sessions{Name: DefaultSessionCookie, Key: key, TTL: DefaultSessionTTL, Clock: time.Now, Rand: rand.Reader}, nil
+}
+
+type sessionKey struct{}
+
+// SessionFromContext returns the ID of the session the request was
+// authenticated with.
+func SessionFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(sessionKey{}).(string)
+	return id, ok
+}
+
+func (s *Sessions) sign(payload string) string {
+	mac := hmac.New(sha256.New, s.Key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Issue sets a new session cookie on w and returns its session ID.
+func (s *Sessions) Issue(w http.ResponseWriter) (string, error) {
+	b := make([]byte, 16)
+	if _, err := io.ReadFull(s.Rand, b); err != nil {
+		return "", fmt.Errorf("failed to read randomness: %w", err)
+	}
+	id := hex.EncodeToString(b)
+	expires := s.Clock().Add(s.TTL)
+	payload := id + "." + strconv.FormatInt(expires.Unix(), 10)
+	http.SetCookie(w, &http.Cookie{
+		Name:     s.Name,
+		Value:    payload + "." + s.sign(payload),
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   s.Secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return id, nil
+}
+
+// Clear removes the session cookie from the browser.
+func (s *Sessions) Clear(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     s.Name,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   s.Secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// Revoke invalidates the session cookie of r, if valid, and removes it from
+// the browser, i.e. on logout.
+func (s *Sessions) Revoke(w http.ResponseWriter, r *http.Request) {
+	if id, expires, err := s.verify(r); err == nil {
+		s.mu.Lock()
+		now := s.Clock()
+		for id, expires := range s.revoked {
+			if now.After(expires) {
+				delete(s.revoked, id)
+			}
+		}
+		if s.revoked == nil {
+			s.revoked = make(map[string]time.Time)
+		}
+		s.revoked[id] = expires
+		s.mu.Unlock()
+	}
+	s.Clear(w)
+}
+
+// RevokeAll invalidates every session cookie issued so far, i.e. when the
+// login they were issued for is gone.
+func (s *Sessions) RevokeAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// the cookies carry their expiry in seconds
+	s.revokedBefore = s.Clock().Truncate(time.Second)
+	s.revoked = nil
+}
+
+// Verify returns the session ID of the valid, unexpired and unrevoked
+// session cookie of r.
+func (s *Sessions) Verify(r *http.Request) (string, error) {
+	id, expires, err := s.verify(r)
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, revoked := s.revoked[id]
+	if revoked || expires.Add(-s.TTL).Before(s.revokedBefore) {
+		return "", fmt.Errorf("%w: revoked", ErrInvalidSession)
+	}
+	return id, nil
+}
+
+// verify returns the session ID and the expiry of the signed, unexpired
+// session cookie of r.
+func (s *Sessions) verify(r *http.Request) (string, time.Time, error) {
+	cookie, err := r.Cookie(s.Name)
+	if err != nil {
+		return "", time.Time{}, ErrInvalidSession
+	}
+	payload, signature, ok := cutLast(cookie.Value, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(s.sign(payload))) {
+		return "", time.Time{}, fmt.Errorf("%w: bad signature", ErrInvalidSession)
+	}
+	id, expiry, ok := strings.Cut(payload, ".")
+	if !ok {
+		return "", time.Time{}, fmt.Errorf("%w: malformed", ErrInvalidSession)
+	}
+	unix, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("%w: malformed expiry", ErrInvalidSession)
+	}
+	expires := time.Unix(unix, 0)
+	if s.Clock().After(expires) {
+		return "", time.Time{}, fmt.Errorf("%w: expired", ErrInvalidSession)
+	}
+	return id, expires, nil
+}
+
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+// Middleware accepts requests without an Authorization header but with a
+// valid session cookie, the others go through auth as before.
+func (s *Sessions) Middleware(auth func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		authenticated := auth(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") == "" {
+				if id, err := s.Verify(r); err == nil {
+					next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), sessionKey{}, id)))
+					return
+				}
+			}
+			authenticated.ServeHTTP(w, r)
+		})
+	}
+}
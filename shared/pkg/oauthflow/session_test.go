@@ -0,0 +1,177 @@
+package oauthflow
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSessionsVerify(t *testing.T) {
+	tests := []struct {
+		name string
+		// cookie tampers with the issued cookie
+		cookie  func(c *http.Cookie)
+		key     []byte
+		advance time.Duration
+		wantErr bool
+	}{
+		{name: "valid"},
+		{name: "no cookie", cookie: func(c *http.Cookie) { c.Name = "other" }, wantErr: true},
+		{name: "tampered id", cookie: func(c *http.Cookie) { c.Value = "x" + c.Value[1:] }, wantErr: true},
+		{name: "truncated", cookie: func(c *http.Cookie) { c.Value = c.Value[:10] }, wantErr: true},
+		{name: "other key", key: []byte("other key"), wantErr: true},
+		{name: "expired", advance: DefaultSessionTTL + time.Second, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &fakeClock{now: time.Unix(1700000000, 0)}
+			sessions, err := NewSessions([]byte("test key"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			sessions.Clock = clock.Now
+
+			rec := httptest.NewRecorder()
+			id, err := sessions.Issue(rec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cookie := rec.Result().Cookies()[0]
+			if !cookie.HttpOnly || cookie.SameSite != http.SameSiteLaxMode {
+				t.Fatalf("cookie %+v is not HttpOnly and SameSite=Lax", cookie)
+			}
+			if tt.cookie != nil {
+				tt.cookie(cookie)
+			}
+			if tt.key != nil {
+				sessions.Key = tt.key
+			}
+			clock.now = clock.now.Add(tt.advance)
+
+			req := httptest.NewRequest(http.MethodGet, "/mcp", nil)
+			req.AddCookie(cookie)
+			got, err := sessions.Verify(req)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidSession) {
+					t.Fatalf("Verify() error = %v, want %v", err, ErrInvalidSession)
+				}
+				return
+			}
+			if err != nil || got != id {
+				t.Fatalf("Verify() = %q, %v, want %q", got, err, id)
+			}
+		})
+	}
+}
+
+func TestSessionsIssueFailsWithoutRandomness(t *testing.T) {
+	sessions, err := NewSessions([]byte("test key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessions.Rand = bytes.NewReader(nil)
+	rec := httptest.NewRecorder()
+	if _, err := sessions.Issue(rec); err == nil {
+		t.Error("issued a session without randomness")
+	}
+	if cookies := rec.Result().Cookies(); len(cookies) != 0 {
+		t.Errorf("issued the cookie %v without randomness", cookies[0])
+	}
+}
+
+func TestSessionsMiddleware(t *testing.T) {
+	sessions, err := NewSessions(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	id, err := sessions.Issue(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookie := rec.Result().Cookies()[0]
+
+	// the bearer auth rejects everything so only sessions get through
+	reject := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		})
+	}
+	handler := sessions.Middleware(reject)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, ok := SessionFromContext(r.Context()); !ok || got != id {
+			t.Errorf("SessionFromContext() = %q, %v, want %q", got, ok, id)
+		}
+	}))
+
+	tests := []struct {
+		name          string
+		cookie        bool
+		authorization string
+		wantCode      int
+	}{
+		{name: "session cookie", cookie: true, wantCode: http.StatusOK},
+		{name: "no credentials", wantCode: http.StatusUnauthorized},
+		// an explicit bearer token is always checked by the auth middleware
+		{name: "cookie and bearer token", cookie: true, authorization: "Bearer invalid", wantCode: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/mcp", nil)
+			if tt.cookie {
+				req.AddCookie(cookie)
+			}
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestSessionsRevoke(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	sessions, err := NewSessions([]byte("test key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessions.Clock = clock.Now
+	issue := func() *http.Request {
+		rec := httptest.NewRecorder()
+		if _, err := sessions.Issue(rec); err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest(http.MethodGet, "/mcp", nil)
+		req.AddCookie(rec.Result().Cookies()[0])
+		return req
+	}
+
+	loggedOut, other := issue(), issue()
+	rec := httptest.NewRecorder()
+	sessions.Revoke(rec, loggedOut)
+	if cookies := rec.Result().Cookies(); len(cookies) != 1 || cookies[0].MaxAge >= 0 {
+		t.Errorf("Revoke() cookies = %v, want the cookie cleared", cookies)
+	}
+	if _, err := sessions.Verify(loggedOut); !errors.Is(err, ErrInvalidSession) {
+		t.Errorf("Verify() error = %v after logout, want %v", err, ErrInvalidSession)
+	}
+	if _, err := sessions.Verify(other); err != nil {
+		t.Errorf("Verify() error = %v for another session, want it valid", err)
+	}
+
+	clock.now = clock.now.Add(time.Minute)
+	sessions.RevokeAll()
+	if _, err := sessions.Verify(other); !errors.Is(err, ErrInvalidSession) {
+		t.Errorf("Verify() error = %v after RevokeAll, want %v", err, ErrInvalidSession)
+	}
+	clock.now = clock.now.Add(time.Second)
+	if _, err := sessions.Verify(issue()); err != nil {
+		t.Errorf("Verify() error = %v for a session issued after RevokeAll, want it valid", err)
+	}
+}
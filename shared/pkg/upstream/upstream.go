@@ -0,0 +1,50 @@
+// Package upstream tells apart why calls to upstream APIs ended early, so a
+// slow provider is not reported like a client that gave up.
+package upstream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// CleanupTimeout bounds the cleanup after a call, see CleanupContext.
+const CleanupTimeout = 10 * time.Second
+
+var (
+	// ErrTimeout is a call that ran out of time, the deadline of the tool
+	// call or of the HTTP client.
+	ErrTimeout = errors.New("upstream timed out")
+	// ErrCanceled is a call whose caller went away, i.e. a cancelled tool
+	// call or a closed connection.
+	ErrCanceled = errors.New("upstream call canceled")
+)
+
+// Error wraps err of a call made with ctx with ErrTimeout or ErrCanceled
+// when that is why it failed, and returns it unchanged otherwise.
+func Error(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	var netErr net.Error
+	switch {
+	case errors.Is(err, ErrTimeout), errors.Is(err, ErrCanceled):
+		return err
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(ctx.Err(), context.DeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	case errors.Is(err, context.Canceled), errors.Is(ctx.Err(), context.Canceled):
+		return fmt.Errorf("%w: %w", ErrCanceled, err)
+	}
+	return err
+}
+
+// CleanupContext returns a context for the cleanup after a call made with
+// ctx, i.e. revoking a token that was already forgotten. It keeps the values
+// of ctx but not its cancellation, so the cleanup still runs when the caller
+// went away, for up to CleanupTimeout.
+func CleanupContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), CleanupTimeout)
+}
@@ -0,0 +1,116 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// hangingServer accepts requests and answers none until the test ends.
+func hangingServer(t *testing.T) *httptest.Server {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}))
+	t.Cleanup(func() {
+		close(done)
+		server.Close()
+	})
+	return server
+}
+
+func TestErrorAbortsInFlightRequest(t *testing.T) {
+	tests := []struct {
+		name string
+		// ctx returns the context of the request and a func ending it early
+		ctx     func() (context.Context, context.CancelFunc)
+		client  *http.Client
+		wantErr error
+	}{
+		{
+			name: "canceled",
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				time.AfterFunc(50*time.Millisecond, cancel)
+				return ctx, cancel
+			},
+			client:  http.DefaultClient,
+			wantErr: ErrCanceled,
+		},
+		{
+			name: "deadline",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 50*time.Millisecond)
+			},
+			client:  http.DefaultClient,
+			wantErr: ErrTimeout,
+		},
+		{
+			name: "client timeout",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithCancel(context.Background())
+			},
+			client:  &http.Client{Timeout: 50 * time.Millisecond},
+			wantErr: ErrTimeout,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := hangingServer(t)
+			ctx, cancel := tt.ctx()
+			defer cancel()
+
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			start := time.Now()
+			_, err = tt.client.Do(req)
+			err = Error(ctx, err)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("request returned after %v, want it aborted", elapsed)
+			}
+		})
+	}
+}
+
+func TestErrorKeepsOtherErrors(t *testing.T) {
+	err := errors.New("status code 500")
+	if got := Error(context.Background(), err); got != err {
+		t.Errorf("Error() = %v, want %v unchanged", got, err)
+	}
+	if got := Error(context.Background(), nil); got != nil {
+		t.Errorf("Error(nil) = %v, want nil", got)
+	}
+	wrapped := Error(context.Background(), context.DeadlineExceeded)
+	if got := Error(context.Background(), wrapped); got != wrapped {
+		t.Errorf("Error() wrapped %v again", got)
+	}
+}
+
+func TestCleanupContext(t *testing.T) {
+	type key struct{}
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "value"))
+	cancel()
+
+	cleanup, cancelCleanup := CleanupContext(ctx)
+	defer cancelCleanup()
+	if err := cleanup.Err(); err != nil {
+		t.Errorf("cleanup context is done: %v", err)
+	}
+	if _, ok := cleanup.Deadline(); !ok {
+		t.Error("cleanup context has no deadline")
+	}
+	if cleanup.Value(key{}) != "value" {
+		t.Error("cleanup context lost the values of ctx")
+	}
+}
@@ -20,25 +20,21 @@ func main() {
 	var token string = getEnv("SPOTIFY_TOKEN")
 	req, err := http.NewRequest("GET", "https://api.spotify.com/v1/me", nil)
 	if err != nil {
-		fmt.Errorf("error creating request: %w", err)
-		return
+		log.Fatalf("error creating request: %v", err)
 	}
 	req.Header.Set("Authorization", "Bearer "+token)
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		fmt.Errorf("error making request: %w", err)
-		return
+		log.Fatalf("error making request: %v", err)
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		fmt.Errorf("unexpected status code: got %v want %v", resp.StatusCode, http.StatusOK)
-		return
+		log.Fatalf("unexpected status code: got %v want %v", resp.StatusCode, http.StatusOK)
 	}
 	fmt.Println("Successfully fetched user data from Spotify API")
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		fmt.Errorf("error reading response body: %w", err)
-		return
+		log.Fatalf("error reading response body: %v", err)
 	}
 	fmt.Println("Response body:", string(body))
 }
@@ -1,14 +1,23 @@
-module github.com/wagnerjt/go-mcp/server
+module github.com/wagnerjt/go-mcp/spotify
 
 go 1.24.1
 
-require github.com/mark3labs/mcp-go v0.32.0
+require (
+	github.com/mark3labs/mcp-go v0.32.0
+	github.com/wagnerjt/go-mcp/client v0.0.0
+	github.com/wagnerjt/go-mcp/shared v0.0.0
+	golang.org/x/oauth2 v0.30.0
+)
 
 require (
-	github.com/golang-jwt/jwt/v5 v5.2.2 // indirect
 	github.com/google/uuid v1.6.0 // indirect
 	github.com/grokify/go-pkce v0.2.3 // indirect
 	github.com/spf13/cast v1.7.1 // indirect
 	github.com/yosida95/uritemplate/v3 v3.0.2 // indirect
-	golang.org/x/oauth2 v0.30.0 // indirect
 )
+
+// the OAuth flow, the compensations and the build info are shared with the other modules
+replace github.com/wagnerjt/go-mcp/shared => ../shared
+
+// the tests run the authorization checks of the client against the server
+replace github.com/wagnerjt/go-mcp/client => ../client
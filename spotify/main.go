@@ -2,335 +2,171 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"flag"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"os"
-
-	"github.com/grokify/go-pkce"
-	"github.com/mark3labs/mcp-go/mcp"
-	"github.com/mark3labs/mcp-go/server"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/wagnerjt/go-mcp/shared/pkg/buildinfo"
+	"github.com/wagnerjt/go-mcp/shared/pkg/healthcheck"
+	"github.com/wagnerjt/go-mcp/shared/pkg/jwtauth"
+	"github.com/wagnerjt/go-mcp/spotify/pkg/spotifymock"
+	"github.com/wagnerjt/go-mcp/spotify/pkg/spotifyserver"
+	"github.com/wagnerjt/go-mcp/spotify/pkg/tokenstore"
 	"golang.org/x/oauth2"
 )
 
 var (
-	port              string
-	well_known_config []byte
-	// In-memory store for PKCE state and code_verifier
-	pkceStore            = make(map[string]string) // state -> code_verifier
-	Client_Id     string = getEnv("SPOTIFY_CLIENT_ID")
-	Client_Secret string = getEnv("SPOTIFY_CLIENT_SECRET")
-)
-
-const (
-	AuthorizationHeader string = "Authorization"
-	QueryState          string = "state"
-	QueryCode           string = "code"
-	RedirectURL         string = "http://127.0.0.1:8080/auth/callback"
-	// Spotify endpoints from .well-known (hardcoded for now)
-	SpotifyAuthEndpoint  = "https://accounts.spotify.com/authorize"
-	SpotifyTokenEndpoint = "https://accounts.spotify.com/api/token"
+	port      string
+	configDir string
+	check     bool
+	version   bool
+	tokenFile string
+
+	externalURL    string
+	redirectURL    string
+	callbackPath   string
+	trustForwarded bool
+	sessionCookies bool
+
+	jwtIssuer   string
+	jwtAudience string
+	jwksURL     string
+
+	spotifyMock   bool
+	spotifyRecord string
 )
 
-type authKey struct{}
-
-type OAuthProtectedResource struct {
-	// Required: The uri that uniquely identifies the resource.
-	Resource string `json:"resource"`
-	// Lists the authorization servers that can be used to access the resource.
-	AuthorizationServers []string `json:"authorization_servers"`
-	// Optional: The OAuth 2.0 presentation methods supported by the resource.
-	BearerMethodsSupported []string `json:"bearer_methods_supported,omitempty"`
-	// Optional: Where the resource's public keys live
-	JwksURI string `json:"jwks_uri,omitempty"`
-	// Recommended
-	ScopesSupported []string `json:"scopes_supported,omitempty"`
-}
-
-type OAuthRedirectHandler struct {
-	State        string
-	CodeVerifier string
-	OAuthConfig  *oauth2.Config
-}
-
-type AuthUrl struct {
-	URL          string
-	State        string
-	CodeVerifier string
-}
-
-// Implement the http.Handler interface
-func (h *OAuthRedirectHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// Extract the code from the request
-	code := r.URL.Query().Get(QueryCode)
-	state := r.URL.Query().Get(QueryState)
-	if code == "" || state == "" {
-		http.Error(w, "Missing code or state parameter", http.StatusBadRequest)
-		return
-	}
-	// TODO: Validate the state does not have timing attacks on it..
-
-	codeVerifier, ok := pkceStore[state]
-	if !ok {
-		http.Error(w, "Invalid state", http.StatusBadRequest)
-		return
-	}
-	delete(pkceStore, state) // Clean up
+func main() {
+	flag.StringVar(&port, "port", "8080", "Port to run the MCP server on")
+	flag.StringVar(&configDir, "config-dir", "", "Directory of mounted config files, i.e. a Kubernetes projected secret")
+	flag.BoolVar(&check, "check", false, "Construct the server, self-test its tools and the Spotify upstream, print a report and exit")
+	flag.StringVar(&tokenFile, "token-file", "", "Persist the Spotify token of the last login to this file, encrypted with TOKEN_STORE_KEY when set")
+	flag.StringVar(&externalURL, "external-url", "", "Public base URL of the server, i.e. behind a TLS-terminating proxy (default: the URL of each request)")
+	flag.StringVar(&redirectURL, "redirect-url", "", "OAuth redirect URI registered with Spotify (default: the callback path on the base URL)")
+	flag.StringVar(&callbackPath, "callback-path", spotifyserver.CallbackPath, "Path of the OAuth redirect URI")
+	flag.BoolVar(&trustForwarded, "trust-forwarded", false, "Build URLs from the X-Forwarded-Proto and X-Forwarded-Host headers of the proxy")
+	flag.BoolVar(&sessionCookies, "session-cookies", false, "Issue signed session cookies after login, accepted in place of bearer tokens (signing key from SESSION_COOKIE_KEY, random by default)")
+	flag.StringVar(&jwtIssuer, "jwt-issuer", "", "Validate the bearer tokens of /mcp as JWTs of this issuer, against the JWKS of its metadata")
+	flag.StringVar(&jwtAudience, "jwt-audience", "", "Comma separated audiences the JWT bearer tokens must name one of")
+	flag.StringVar(&jwksURL, "jwks-url", "", "JWKS URL of the -jwt-issuer (default: the jwks_uri of its metadata)")
+	flag.BoolVar(&spotifyMock, "spotify-mock", false, "Serve canned Spotify API responses from the built-in fixtures, without credentials or network")
+	flag.StringVar(&spotifyRecord, "spotify-record", "", "Record the Spotify API responses as fixtures to this directory")
+	flag.BoolVar(&version, "version", false, "Print the version, commit and build date and exit")
+	flag.Parse()
 
-	// Use the code to exchange for an access token
-	token, err := h.OAuthConfig.Exchange(context.Background(), code,
-		oauth2.SetAuthURLParam(pkce.ParamCodeVerifier, codeVerifier),
-	)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to exchange token: %v", err), http.StatusInternalServerError)
+	if version {
+		fmt.Println(buildinfo.Get())
 		return
 	}
 
-	log.Printf("Received token: %s", token.AccessToken)
-	// Redirect to a success page or return a message
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(`{"status":"authenticated"}`))
-}
-
-func getEnv(key string) string {
-	value, ok := os.LookupEnv(key)
-	if !ok {
-		log.Fatalf("Environment variable %s not set", key)
-	}
-	return value
-}
-
-func AuthorizationUrl(config *oauth2.Config) (*AuthUrl, error) {
-	codeVerifier, _ := pkce.NewCodeVerifier(48)
-
-	codeChallenge := pkce.CodeChallengeS256(codeVerifier)
-	state := "spotify-auth-state"
-	authUrl := config.AuthCodeURL(
-		state,
-		oauth2.SetAuthURLParam(pkce.ParamCodeChallenge, codeChallenge),
-		oauth2.SetAuthURLParam(pkce.ParamCodeChallengeMethod, pkce.MethodS256),
-	)
-
-	return &AuthUrl{
-		URL:          authUrl,
-		State:        state,
-		CodeVerifier: codeVerifier,
-	}, nil
-}
-
-func withAuthKey(ctx context.Context, auth string) context.Context {
-	return context.WithValue(ctx, authKey{}, auth)
-}
-
-func authFromRequest(ctx context.Context, r *http.Request) context.Context {
-	return withAuthKey(ctx, r.Header.Get(AuthorizationHeader))
-}
-
-func ValidateJWT(r *http.Request) bool {
-	return true
-}
-
-func handleEchoTool(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	arguments := request.GetArguments()
-	message, ok := arguments["message"].(string)
-	if !ok {
-		return nil, fmt.Errorf("invalid arguments: message is required")
-	}
-	return &mcp.CallToolResult{
-		Content: []mcp.Content{
-			mcp.NewTextContent(fmt.Sprintf("Echo: %s", message)),
-		},
-	}, nil
-}
-
-func NewMCPServer() *server.MCPServer {
-	hooks := &server.Hooks{}
-
-	mcpServer := server.NewMCPServer("vscode-spotify/tools", "0.0.1",
-		server.WithToolCapabilities(true),
-		server.WithLogging(),
-		server.WithHooks(hooks),
-	)
-
-	// Add a simple echo tool
-	mcpServer.AddTool(mcp.NewTool("echo",
-		mcp.WithDescription("Echoes back the input"),
-		mcp.WithString("message",
-			mcp.Description("Message to echo"),
-			mcp.Required(),
-		),
-	), handleEchoTool)
-
-	return mcpServer
-}
-
-func textResponse(rw http.ResponseWriter, status int, body string) {
-	rw.Header().Set("Content-Type", "application/json")
-	rw.WriteHeader(status)
-	if body != "" {
-		rw.Write([]byte(body))
-	}
-}
-
-func rejectWithOAuthResponseCodes(rw http.ResponseWriter) {
-	resource_metadata := "http://127.0.0.1:8080/.well-known/oauth-protected-resource"
-	authorization_uri := SpotifyAuthEndpoint
-	header_response := fmt.Sprintf(`Bearer realm="spotify-go-server",resource_metadata="%s",authorization_uri="%s",error="unauthorized"`, resource_metadata, authorization_uri)
-	rw.Header().Set("WWW-Authenticate", header_response)
-	rw.WriteHeader(http.StatusUnauthorized)
-	body := `{"error":"unauthorized","error_description":"You must authenticate to access this resource"}`
-	bodyJson, _ := json.Marshal(body)
-	rw.Write(bodyJson)
-}
-
-func authMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		auth := r.Header.Get(AuthorizationHeader)
-		if auth == "" {
-			// TODO: make better instead of just missing auth header
-			log.Printf("Missing Authorization header, redirecting to the oauth endpoints")
-			rejectWithOAuthResponseCodes(w)
-			return
-		} else if !ValidateJWT(r) {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			return
+	sessionKey, _ := spotifyserver.LookupConfig("SESSION_COOKIE_KEY", configDir)
+	tokens := spotifyserver.WithTokenFile(tokenFile)
+	if tokenKey, ok := spotifyserver.LookupConfig("TOKEN_STORE_KEY", configDir); ok && tokenFile != "" {
+		store, err := tokenstore.NewFile(tokenFile, []byte(tokenKey))
+		if err != nil {
+			log.Fatalf("Invalid token store: %v", err)
 		}
-		next.ServeHTTP(w, r)
-		// ctx := authFromRequest(r.Context(), r)
-		// next.ServeHTTP(w, r.WithContext(ctx))
-	})
-}
-
-func GetResponseBodyBytes(url string) []byte {
-	resp, err := http.Get(url)
-	if err != nil {
-		log.Fatalf("Failed to fetch %s: %v", url, err)
+		tokens = spotifyserver.WithTokenStore(store)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		log.Fatalf("Failed to fetch %s: status code %d", url, resp.StatusCode)
+	var validation []spotifyserver.Option
+	if jwtIssuer != "" {
+		var audience []string
+		if jwtAudience != "" {
+			audience = strings.Split(jwtAudience, ",")
+		}
+		validator, err := jwtauth.New(jwtauth.Config{Issuer: jwtIssuer, Audience: audience, JWKSURL: jwksURL})
+		if err != nil {
+			log.Fatalf("Invalid JWT validation: %v", err)
+		}
+		validation = append(validation, spotifyserver.WithTokenValidator(validator))
+	} else if !spotifyMock {
+		log.Printf("No -jwt-issuer: /mcp accepts the Spotify access tokens of the logged in user")
 	}
-
-	body, err := io.ReadAll(resp.Body)
+	opts := append([]spotifyserver.Option{
+		spotifyserver.WithCredentialsLoader(spotifyserver.EnvCredentials(configDir)),
+		tokens,
+		spotifyserver.WithExternalURL(externalURL),
+		spotifyserver.WithRedirectURL(redirectURL),
+		spotifyserver.WithCallbackPath(callbackPath),
+		spotifyserver.WithForwardedHeaders(trustForwarded),
+		spotifyserver.WithSessionCookies(sessionCookies, sessionKey),
+	}, validation...)
+	upstream, err := upstreamOptions()
 	if err != nil {
-		log.Fatalf("Failed to read response body from %s: %v", url, err)
+		log.Fatalf("Failed to start the Spotify upstream: %v", err)
 	}
-
-	return body
-}
-
-// HTTP endpoints
-func handleHealth(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(`{"status":"UP"}`))
-}
-
-func handleAuthSmokeTest(w http.ResponseWriter, r *http.Request) {
-	auth := r.Header.Get(AuthorizationHeader)
-	fmt.Printf("Received header %s request for auth smoke test\n", auth)
-
-	if auth == "" {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
+	srv, err := spotifyserver.New(append(opts, upstream...)...)
+	if check {
+		os.Exit(healthcheck.Run(context.Background(), os.Stdout, err, srv.Check))
 	}
-
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(`{"status":"AUTHENTICATED"}`))
-}
-
-func returnWellKnownAuthServer(w http.ResponseWriter, r *http.Request) {
-	fmt.Println("Returning well-known OAuth protected resource endpoint")
-
-	w.Header().Add("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	// body := OAuthProtectedResource{
-	// 	Resource:               "https://accounts.spotify.com",
-	// 	AuthorizationServers:   []string{SpotifyAuthEndpoint},
-	// 	BearerMethodsSupported: []string{"header"},
-	// 	ScopesSupported:        []string{"user-read-private", "user-read-email"},
-	// }
-
-	proxy_body := OAuthProtectedResource{
-		Resource:               "http://127.0.0.1:8080/",
-		AuthorizationServers:   []string{SpotifyAuthEndpoint},
-		BearerMethodsSupported: []string{"header"},
-		ScopesSupported:        []string{"user-read-private", "user-read-email"},
+	if err != nil {
+		log.Fatalf("Failed to create server: %v", err)
 	}
-
-	// ignore error for simplicity
-	bodyJSON, _ := json.Marshal(proxy_body)
-	w.Write(bodyJSON)
-}
-
-func returnWellKnownProxy(w http.ResponseWriter, r *http.Request) {
-	// Spotify does not have a well-known endpoint for OAuth authorization resources, proxy it for now
-	fmt.Println("Returning well-known OAuth protected server metadata")
-	w.Header().Add("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(well_known_config))
-}
-
-// Handler to start the PKCE OAuth flow
-func handleSpotifyLogin(w http.ResponseWriter, r *http.Request) {
-	clientID := Client_Id
-	redirectURI := RedirectURL
-	scopes := "user-read-private user-read-email"
-
-	codeVerifier, _ := pkce.NewCodeVerifier(48)
-	codeChallenge := pkce.CodeChallengeS256(codeVerifier)
-	state := fmt.Sprintf("state-%d", len(pkceStore)+1) // simple state
-	pkceStore[state] = codeVerifier
-
-	authURL := fmt.Sprintf("%s?client_id=%s&response_type=code&redirect_uri=%s&scope=%s&state=%s&code_challenge=%s&code_challenge_method=S256",
-		SpotifyAuthEndpoint, clientID, redirectURI, scopes, state, codeChallenge)
-
-	http.Redirect(w, r, authURL, http.StatusFound)
-}
-
-func main() {
-	flag.StringVar(&port, "port", "8080", "Port to run the MCP server on")
-	flag.Parse()
-
-	// Get spotify's well-known configuration initially for proxying
-	well_known_config = GetResponseBodyBytes("https://accounts.spotify.com/.well-known/openid-configuration")
-
-	mux := http.NewServeMux()
-
-	// Simple health endpoint
-	mux.HandleFunc("/health", handleHealth)
-
-	// Adding MCP spec endpoints
-	mux.HandleFunc("/.well-known/oauth-protected-resource", returnWellKnownAuthServer)
-	mux.HandleFunc("/.well-known/oauth-authorization-server", returnWellKnownProxy)
-	// Provide a valid OAuthConfig to the callback handler
-	mux.Handle("/auth/callback", &OAuthRedirectHandler{
-		OAuthConfig: &oauth2.Config{
-			ClientID:     Client_Id,
-			ClientSecret: Client_Secret,
-			RedirectURL:  RedirectURL,
-			Scopes:       []string{"user-read-private", "user-read-email"},
-			Endpoint: oauth2.Endpoint{
-				AuthURL:  SpotifyAuthEndpoint,
-				TokenURL: SpotifyTokenEndpoint,
-			},
-		},
-	})
-	// Add the login endpoint
-	mux.HandleFunc("/auth/spotify/login", handleSpotifyLogin)
-
-	// Add the mcp server endpoint with the auth middleware
-	mcpServer := NewMCPServer()
-	httpServer := server.NewStreamableHTTPServer(mcpServer, server.WithHTTPContextFunc(authFromRequest))
-	mux.Handle("/mcp", authMiddleware(http.HandlerFunc(httpServer.ServeHTTP)))
-	mux.Handle("/auth/smoke", authMiddleware(http.HandlerFunc(handleAuthSmokeTest)))
+	go srv.AwaitReadiness(context.Background())
+	go srv.WatchToken(context.Background())
+
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go func() {
+		for range reload {
+			if err := srv.Reload(); err != nil {
+				log.Printf("Reload failed: %v", err)
+			}
+		}
+	}()
 
 	// Start the server
 	log.Printf("HTTP server listening on port %s", port)
-	if err := http.ListenAndServe(":"+port, mux); err != nil {
+	if err := http.ListenAndServe(":"+port, srv.Handler()); err != nil {
 		log.Fatalf("Server error: %v", err)
 	}
 }
+
+// upstreamOptions points the server at the Spotify mock or recorder of the
+// -spotify-mock and -spotify-record flags, if any.
+func upstreamOptions() ([]spotifyserver.Option, error) {
+	switch {
+	case spotifyMock:
+		mock, err := spotifymock.New(spotifymock.Fixtures)
+		if err != nil {
+			return nil, err
+		}
+		listener, err := spotifymock.Listen(mock)
+		if err != nil {
+			return nil, err
+		}
+		// a logged in user, the mock accepts any token
+		store := tokenstore.NewMemory()
+		store.Put(context.Background(), spotifyserver.TokenKey, tokenstore.Token{Token: &oauth2.Token{AccessToken: "mock"}})
+		log.Printf("Serving the Spotify API from fixtures at %s", listener.URL)
+		opts := []spotifyserver.Option{
+			spotifyserver.WithCredentialsLoader(func() (string, string, error) { return "mock", "mock", nil }),
+			spotifyserver.WithWellKnownConfig(spotifymock.WellKnownConfig),
+			spotifyserver.WithAPIURL(listener.URL),
+			spotifyserver.WithTokenStore(store),
+		}
+		if jwtIssuer == "" {
+			// nothing is at stake with the mock, so /mcp takes any bearer token
+			opts = append(opts, spotifyserver.WithTokenValidator(jwtauth.TokenValidatorFunc(
+				func(ctx context.Context, token string) (*jwtauth.Claims, error) { return &jwtauth.Claims{}, nil })))
+		}
+		return opts, nil
+	case spotifyRecord != "":
+		recorder, err := spotifymock.NewRecorder(spotifyserver.SpotifyAPIURL, spotifyRecord)
+		if err != nil {
+			return nil, err
+		}
+		listener, err := spotifymock.Listen(recorder)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Recording the Spotify API responses to %s", spotifyRecord)
+		return []spotifyserver.Option{spotifyserver.WithAPIURL(listener.URL)}, nil
+	}
+	return nil, nil
+}
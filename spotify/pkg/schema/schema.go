@@ -0,0 +1,382 @@
+// Package schema builds the JSON Schemas of tool arguments that the property
+// options of mcp-go cannot express, i.e. arrays of objects and oneOf unions,
+// and validates the arguments of calls against them.
+package schema
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Schema is the subset of JSON Schema supported by the builders and
+// Validate.
+type Schema struct {
+	Type        string             `json:"type,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Enum        []any              `json:"enum,omitempty"`
+	Default     any                `json:"default,omitempty"`
+	Examples    []any              `json:"examples,omitempty"`
+	Minimum     *float64           `json:"minimum,omitempty"`
+	Maximum     *float64           `json:"maximum,omitempty"`
+	MinLength   *int               `json:"minLength,omitempty"`
+	MaxLength   *int               `json:"maxLength,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+	MinItems    *int               `json:"minItems,omitempty"`
+	MaxItems    *int               `json:"maxItems,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	// AdditionalProperties is false for the objects of Object, which reject
+	// unknown properties.
+	AdditionalProperties *bool     `json:"additionalProperties,omitempty"`
+	OneOf                []*Schema `json:"oneOf,omitempty"`
+}
+
+// Option sets a keyword of a Schema.
+type Option func(*Schema)
+
+func build(s *Schema, options []Option) *Schema {
+	for _, option := range options {
+		option(s)
+	}
+	return s
+}
+
+// String is a string schema.
+func String(options ...Option) *Schema {
+	return build(&Schema{Type: "string"}, options)
+}
+
+// Number is a number schema.
+func Number(options ...Option) *Schema {
+	return build(&Schema{Type: "number"}, options)
+}
+
+// Integer is a number schema without fraction.
+func Integer(options ...Option) *Schema {
+	return build(&Schema{Type: "integer"}, options)
+}
+
+// Boolean is a boolean schema.
+func Boolean(options ...Option) *Schema {
+	return build(&Schema{Type: "boolean"}, options)
+}
+
+// Array is an array schema of items.
+func Array(items *Schema, options ...Option) *Schema {
+	return build(&Schema{Type: "array", Items: items}, options)
+}
+
+// Properties are the properties of an Object by name.
+type Properties map[string]*Schema
+
+// Object is an object schema of properties, rejecting others.
+func Object(properties Properties, options ...Option) *Schema {
+	closed := false
+	return build(&Schema{Type: "object", Properties: properties, AdditionalProperties: &closed}, options)
+}
+
+// Open accepts properties of an Object besides the declared ones, i.e. in the
+// schema of a result whose items are trimmed to the fields a call names.
+func Open() Option {
+	return func(s *Schema) { s.AdditionalProperties = nil }
+}
+
+// OneOf is a union of schemas, of which a value must match exactly one, i.e.
+// objects told apart by an Enum property of a single value.
+func OneOf(schemas ...*Schema) *Schema {
+	return &Schema{OneOf: schemas}
+}
+
+// Description describes a schema.
+func Description(description string) Option {
+	return func(s *Schema) { s.Description = description }
+}
+
+// Enum restricts a schema to values.
+func Enum[T any](values ...T) Option {
+	return func(s *Schema) {
+		for _, value := range values {
+			s.Enum = append(s.Enum, value)
+		}
+	}
+}
+
+// Default is the value of a property left out of the arguments, which
+// NewTool fills in before the handler sees them.
+func Default(value any) Option {
+	return func(s *Schema) { s.Default = value }
+}
+
+// Examples are example values of a schema, shown to the clients.
+func Examples(values ...any) Option {
+	return func(s *Schema) { s.Examples = append(s.Examples, values...) }
+}
+
+// ApplyDefaults fills the properties left out of value, decoded from JSON, by
+// the defaults of their schemas, also in the nested objects and the objects
+// in arrays. The properties of oneOf unions are left alone, as their shape is
+// not known before validation. Defaults are copied through JSON, so they
+// have the types of decoded arguments, i.e. float64 for numbers.
+func (s *Schema) ApplyDefaults(value any) error {
+	switch value := value.(type) {
+	case map[string]any:
+		if s.Type != "object" {
+			return nil
+		}
+		for _, name := range slices.Sorted(maps.Keys(s.Properties)) {
+			property := s.Properties[name]
+			if _, ok := value[name]; !ok && property.Default != nil {
+				data, err := json.Marshal(property.Default)
+				if err != nil {
+					return fmt.Errorf("default of %s: %w", name, err)
+				}
+				var decoded any
+				if err := json.Unmarshal(data, &decoded); err != nil {
+					return fmt.Errorf("default of %s: %w", name, err)
+				}
+				value[name] = decoded
+			}
+			if err := property.ApplyDefaults(value[name]); err != nil {
+				return err
+			}
+		}
+	case []any:
+		if s.Type != "array" || s.Items == nil {
+			return nil
+		}
+		for _, item := range value {
+			if err := s.Items.ApplyDefaults(item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Min bounds a number from below.
+func Min(minimum float64) Option {
+	return func(s *Schema) { s.Minimum = &minimum }
+}
+
+// Max bounds a number from above.
+func Max(maximum float64) Option {
+	return func(s *Schema) { s.Maximum = &maximum }
+}
+
+// Length bounds the length of a string, or the items of an array, to between
+// minimum and maximum, a negative maximum leaving it unbounded.
+func Length(minimum, maximum int) Option {
+	return func(s *Schema) {
+		lower, upper := &s.MinLength, &s.MaxLength
+		if s.Type == "array" {
+			lower, upper = &s.MinItems, &s.MaxItems
+		}
+		*lower = &minimum
+		if maximum >= 0 {
+			*upper = &maximum
+		}
+	}
+}
+
+// Required lists the properties an Object requires.
+func Required(names ...string) Option {
+	return func(s *Schema) { s.Required = append(s.Required, names...) }
+}
+
+// Validate checks value, decoded from JSON, against the schema. The error
+// names the path of the first invalid value, i.e. tracks[2].uri.
+func (s *Schema) Validate(value any) error {
+	return s.validate("", value)
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func (s *Schema) validate(path string, value any) error {
+	fail := func(format string, args ...any) error {
+		if path == "" {
+			return fmt.Errorf(format, args...)
+		}
+		return fmt.Errorf("%s: %s", path, fmt.Sprintf(format, args...))
+	}
+
+	if len(s.OneOf) > 0 {
+		matched := 0
+		var first error
+		for _, option := range s.OneOf {
+			if err := option.validate(path, value); err == nil {
+				matched++
+			} else if first == nil {
+				first = err
+			}
+		}
+		switch {
+		case matched == 0 && len(s.OneOf) == 1:
+			return first
+		case matched == 0:
+			return fail("matches none of the %d allowed shapes", len(s.OneOf))
+		case matched > 1:
+			return fail("matches %d of the allowed shapes, want exactly one", matched)
+		}
+	}
+	if len(s.Enum) > 0 && !slices.ContainsFunc(s.Enum, func(allowed any) bool { return equal(allowed, value) }) {
+		return fail("must be one of %s", enumList(s.Enum))
+	}
+
+	switch s.Type {
+	case "":
+		return nil
+	case "string":
+		text, ok := value.(string)
+		if !ok {
+			return fail("want a string, got %s", typeName(value))
+		}
+		length := len([]rune(text))
+		if s.MinLength != nil && length < *s.MinLength {
+			return fail("must have at least %d characters", *s.MinLength)
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			return fail("must have at most %d characters", *s.MaxLength)
+		}
+	case "number", "integer":
+		number, ok := value.(float64)
+		if !ok {
+			return fail("want a number, got %s", typeName(value))
+		}
+		if s.Type == "integer" && number != math.Trunc(number) {
+			return fail("want an integer, got %v", number)
+		}
+		if s.Minimum != nil && number < *s.Minimum {
+			return fail("must be at least %v", *s.Minimum)
+		}
+		if s.Maximum != nil && number > *s.Maximum {
+			return fail("must be at most %v", *s.Maximum)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fail("want a boolean, got %s", typeName(value))
+		}
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			return fail("want an array, got %s", typeName(value))
+		}
+		if s.MinItems != nil && len(items) < *s.MinItems {
+			return fail("must have at least %d items", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(items) > *s.MaxItems {
+			return fail("must have at most %d items", *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range items {
+				if err := s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+					return err
+				}
+			}
+		}
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			return fail("want an object, got %s", typeName(value))
+		}
+		for _, name := range s.Required {
+			if _, ok := object[name]; !ok {
+				return fail("%s is required", name)
+			}
+		}
+		for _, name := range slices.Sorted(maps.Keys(object)) {
+			property, known := s.Properties[name]
+			if !known {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					return fail("unknown property %s", name)
+				}
+				continue
+			}
+			if err := property.validate(join(path, name), object[name]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fail("unsupported schema type %s", s.Type)
+	}
+	return nil
+}
+
+// equal compares JSON values, the numbers of the enum of any Go numeric type.
+func equal(allowed, value any) bool {
+	a, err := json.Marshal(allowed)
+	if err != nil {
+		return false
+	}
+	b, err := json.Marshal(value)
+	return err == nil && string(a) == string(b)
+}
+
+func enumList(values []any) string {
+	items := make([]string, len(values))
+	for i, value := range values {
+		data, _ := json.Marshal(value)
+		items[i] = string(data)
+	}
+	return strings.Join(items, ", ")
+}
+
+func typeName(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "a string"
+	case float64:
+		return "a number"
+	case bool:
+		return "a boolean"
+	case []any:
+		return "an array"
+	case map[string]any:
+		return "an object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// NewTool is a tool whose arguments are described by input, an Object, and
+// completed by its defaults and validated against it before they reach
+// handler. options add the description and annotations.
+func NewTool(name string, input *Schema, handler server.ToolHandlerFunc, options ...mcp.ToolOption) server.ServerTool {
+	raw, err := json.Marshal(input)
+	if err != nil {
+		panic(fmt.Sprintf("schema of tool %s: %v", name, err))
+	}
+	tool := mcp.NewTool(name, options...)
+	tool.InputSchema = mcp.ToolInputSchema{}
+	tool.RawInputSchema = raw
+	return server.ServerTool{
+		Tool: tool,
+		Handler: func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			arguments := request.Params.Arguments
+			if arguments == nil {
+				arguments = map[string]any{}
+				request.Params.Arguments = arguments
+			}
+			if err := input.ApplyDefaults(arguments); err != nil {
+				return nil, err
+			}
+			if err := input.Validate(arguments); err != nil {
+				return nil, fmt.Errorf("invalid arguments: %w", err)
+			}
+			return handler(ctx, request)
+		},
+	}
+}
@@ -0,0 +1,184 @@
+package schema
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// queueInput is the shape of a richer Spotify tool: an array of objects, each
+// a track or an episode.
+var queueInput = Object(Properties{
+	"items": Array(OneOf(
+		Object(Properties{
+			"type": String(Enum("track")),
+			"uri":  String(Length(1, -1)),
+		}, Required("type", "uri")),
+		Object(Properties{
+			"type":     String(Enum("episode")),
+			"uri":      String(Length(1, -1)),
+			"resumeAt": Integer(Min(0)),
+		}, Required("type", "uri")),
+	), Length(1, 3)),
+	"mode":   String(Enum("append", "replace"), Default("append")),
+	"volume": Number(Min(0), Max(100)),
+}, Required("items"))
+
+func decode(t *testing.T, value string) any {
+	t.Helper()
+	var decoded any
+	if err := json.Unmarshal([]byte(value), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	return decoded
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		// wantErr is a substring of the error, empty for valid values
+		wantErr string
+	}{
+		{"track", `{"items":[{"type":"track","uri":"spotify:track:1"}]}`, ""},
+		{"mixed", `{"items":[{"type":"track","uri":"a"},{"type":"episode","uri":"b","resumeAt":30}],"mode":"replace","volume":55.5}`, ""},
+		{"missing required", `{"mode":"append"}`, "items is required"},
+		{"unknown property", `{"items":[{"type":"track","uri":"a"}],"shuffle":true}`, "unknown property shuffle"},
+		{"enum", `{"items":[{"type":"track","uri":"a"}],"mode":"prepend"}`, `mode: must be one of "append", "replace"`},
+		{"too few items", `{"items":[]}`, "items: must have at least 1 items"},
+		{"too many items", `{"items":[{"type":"track","uri":"a"},{"type":"track","uri":"b"},{"type":"track","uri":"c"},{"type":"track","uri":"d"}]}`, "at most 3 items"},
+		{"no shape", `{"items":[{"type":"album","uri":"a"}]}`, "items[0]: matches none of the 2 allowed shapes"},
+		{"track with resume", `{"items":[{"type":"track","uri":"a","resumeAt":3}]}`, "items[0]: matches none"},
+		{"integer", `{"items":[{"type":"episode","uri":"a","resumeAt":1.5}]}`, "items[0]: matches none"},
+		{"maximum", `{"items":[{"type":"track","uri":"a"}],"volume":101}`, "volume: must be at most 100"},
+		{"type", `{"items":"spotify:track:1"}`, "items: want an array, got a string"},
+		{"not an object", `[]`, "want an object, got an array"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := queueInput.Validate(decode(t, tt.value))
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("Validate() = %v, want no error", err)
+			case tt.wantErr != "" && err == nil:
+				t.Errorf("Validate() = nil, want %q", tt.wantErr)
+			case tt.wantErr != "" && !strings.Contains(err.Error(), tt.wantErr):
+				t.Errorf("Validate() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOpen(t *testing.T) {
+	page := Object(Properties{"total": Integer(Min(0))}, Required("total"), Open())
+	if err := page.Validate(decode(t, `{"total":2,"items":[]}`)); err != nil {
+		t.Errorf("Validate() of an open object with another property = %v", err)
+	}
+	if err := page.Validate(decode(t, `{"total":-1}`)); err == nil || !strings.Contains(err.Error(), "total: must be at least 0") {
+		t.Errorf("Validate() of an open object = %v, want the declared properties validated", err)
+	}
+	if data, _ := json.Marshal(page); strings.Contains(string(data), "additionalProperties") {
+		t.Errorf("open object encodes as %s, want no additionalProperties", data)
+	}
+}
+
+func TestOneOfMatchingSeveral(t *testing.T) {
+	union := OneOf(String(), String(Length(0, 5)))
+	if err := union.Validate("abc"); err == nil || !strings.Contains(err.Error(), "want exactly one") {
+		t.Errorf("Validate() = %v, want an ambiguity error", err)
+	}
+	if err := union.Validate("abcdefgh"); err != nil {
+		t.Errorf("Validate() = %v, want the first shape to match", err)
+	}
+}
+
+func TestNewTool(t *testing.T) {
+	called := false
+	tool := NewTool("queue_items", queueInput, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		called = true
+		return mcp.NewToolResultText("queued"), nil
+	}, mcp.WithDescription("Queues tracks and episodes"), mcp.WithDestructiveHintAnnotation(false))
+
+	encoded, err := json.Marshal(tool.Tool)
+	if err != nil {
+		t.Fatalf("tool does not encode: %v", err)
+	}
+	var advertised struct {
+		Description string  `json:"description"`
+		InputSchema *Schema `json:"inputSchema"`
+	}
+	if err := json.Unmarshal(encoded, &advertised); err != nil {
+		t.Fatal(err)
+	}
+	if advertised.Description != "Queues tracks and episodes" || advertised.InputSchema.Properties["items"].Items.OneOf == nil {
+		t.Errorf("advertised %s, want the description and the oneOf items", encoded)
+	}
+
+	request := mcp.CallToolRequest{}
+	request.Params.Arguments = decode(t, `{"items":[{"type":"album","uri":"a"}]}`)
+	if _, err := tool.Handler(context.Background(), request); err == nil || !strings.HasPrefix(err.Error(), "invalid arguments: ") || called {
+		t.Errorf("Handler() = %v, called %v, want an invalid arguments error without calling the handler", err, called)
+	}
+	request.Params.Arguments = decode(t, `{"items":[{"type":"track","uri":"a"}]}`)
+	if _, err := tool.Handler(context.Background(), request); err != nil || !called {
+		t.Errorf("Handler() = %v, called %v, want the handler called", err, called)
+	}
+}
+
+func TestApplyDefaults(t *testing.T) {
+	input := Object(Properties{
+		"limit":  Integer(Default(20), Examples(5, 50)),
+		"market": String(Default("US")),
+		"filters": Object(Properties{
+			"explicit": Boolean(Default(false)),
+		}),
+		"seeds": Array(Object(Properties{
+			"uri":    String(),
+			"weight": Number(Default(1)),
+		})),
+		"item": OneOf(Object(Properties{"kind": String(Default("track"))})),
+	})
+	arguments := decode(t, `{"market":"DE","filters":{},"seeds":[{"uri":"a"},{"uri":"b","weight":0.5}],"item":{}}`)
+	if err := input.ApplyDefaults(arguments); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := json.Marshal(arguments)
+	want := `{"filters":{"explicit":false},"item":{},"limit":20,"market":"DE","seeds":[{"uri":"a","weight":1},{"uri":"b","weight":0.5}]}`
+	if string(got) != want {
+		t.Errorf("ApplyDefaults() = %s, want %s", got, want)
+	}
+	if _, ok := arguments.(map[string]any)["limit"].(float64); !ok {
+		t.Errorf("default limit is %T, want float64 as decoded from JSON", arguments.(map[string]any)["limit"])
+	}
+
+	encoded, _ := json.Marshal(input.Properties["limit"])
+	if string(encoded) != `{"type":"integer","default":20,"examples":[5,50]}` {
+		t.Errorf("advertised %s, want the default and examples", encoded)
+	}
+}
+
+func TestNewToolAppliesDefaults(t *testing.T) {
+	var got map[string]any
+	tool := NewTool("search", Object(Properties{
+		"query": String(),
+		"limit": Integer(Default(10), Max(50)),
+	}), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		got = request.GetArguments()
+		return mcp.NewToolResultText("ok"), nil
+	})
+	if _, err := tool.Handler(context.Background(), mcp.CallToolRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if got["limit"] != float64(10) {
+		t.Errorf("handler got %v, want the default limit", got)
+	}
+
+	request := mcp.CallToolRequest{}
+	request.Params.Arguments = []any{}
+	if _, err := tool.Handler(context.Background(), request); err == nil {
+		t.Errorf("Handler() accepted an array of arguments")
+	}
+}
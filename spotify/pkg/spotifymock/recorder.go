@@ -0,0 +1,97 @@
+package spotifymock
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Recorder proxies the requests to the real Spotify Web API and writes each
+// JSON response as a fixture to a directory, to be trimmed and added to
+// the fixtures. The recorded fixtures require the full query of the
+// request.
+type Recorder struct {
+	dir      string
+	basePath string
+	proxy    *httputil.ReverseProxy
+}
+
+// NewRecorder records the responses of apiURL, i.e. SpotifyAPIURL, to dir.
+func NewRecorder(apiURL, dir string) (*Recorder, error) {
+	target, err := url.Parse(apiURL)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	r := &Recorder{dir: dir, basePath: strings.TrimSuffix(target.Path, "/")}
+	r.proxy = &httputil.ReverseProxy{
+		Rewrite: func(request *httputil.ProxyRequest) {
+			request.SetURL(target)
+			request.Out.Host = target.Host
+			// uncompressed, so the recorded bodies are readable
+			request.Out.Header.Del("Accept-Encoding")
+		},
+		ModifyResponse: r.record,
+	}
+	return r, nil
+}
+
+// ServeHTTP implements http.Handler.
+func (r *Recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.proxy.ServeHTTP(w, req)
+}
+
+// record writes the fixture of resp, named after the request, i.e.
+// GET_search_1a2b3c4d.json. The Authorization header is never recorded.
+func (r *Recorder) record(resp *http.Response) error {
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	var fixture Fixture
+	request := resp.Request
+	// the fixtures are relative to the API URL
+	apiPath := "/" + strings.TrimPrefix(strings.TrimPrefix(request.URL.Path, r.basePath), "/")
+	fixture.Request.Method = request.Method
+	fixture.Request.Path = apiPath
+	if query := request.URL.Query(); len(query) > 0 {
+		fixture.Request.Query = make(map[string]string, len(query))
+		for name := range query {
+			fixture.Request.Query[name] = query.Get(name)
+		}
+	}
+	fixture.Response.Status = resp.StatusCode
+	if len(body) > 0 {
+		if !json.Valid(body) {
+			log.Printf("Not recording %s %s: the response is not JSON", request.Method, apiPath)
+			return nil
+		}
+		fixture.Response.Body = body
+	}
+
+	data, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256([]byte(request.URL.RawQuery))
+	name := fmt.Sprintf("%s%s_%s.json", request.Method, strings.ReplaceAll(apiPath, "/", "_"), hex.EncodeToString(sum[:4]))
+	if err := os.WriteFile(filepath.Join(r.dir, name), data, 0o644); err != nil {
+		log.Printf("Failed to record %s %s: %v", request.Method, apiPath, err)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+// Package spotifymock serves canned Spotify Web API responses from
+// fixtures, so the Spotify server and its tests run without credentials or
+// network, and records real responses to create new fixtures.
+package spotifymock
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Fixtures are the built-in fixtures, covering the calls of the tools of
+// the Spotify server.
+//
+//go:embed fixtures/*.json
+var Fixtures embed.FS
+
+// WellKnownConfig is the authorization server metadata served in place of
+// the one of Spotify in mock mode.
+var WellKnownConfig = []byte(`{"issuer":"https://accounts.spotify.com","authorization_endpoint":"https://accounts.spotify.com/authorize","token_endpoint":"https://accounts.spotify.com/api/token","response_types_supported":["code"],"code_challenge_methods_supported":["S256"]}`)
+
+// Fixture is a canned response for the requests it matches.
+type Fixture struct {
+	Request struct {
+		Method string `json:"method"`
+		// Path is a path.Match pattern of the path below the API URL, i.e.
+		// /shows/*/episodes.
+		Path string `json:"path"`
+		// Query lists query parameters the request must have, others are
+		// ignored.
+		Query map[string]string `json:"query,omitempty"`
+	} `json:"request"`
+	Response struct {
+		// Status defaults to 200.
+		Status int             `json:"status,omitempty"`
+		Body   json.RawMessage `json:"body,omitempty"`
+	} `json:"response"`
+}
+
+func (f *Fixture) matches(r *http.Request) bool {
+	if !strings.EqualFold(f.Request.Method, r.Method) {
+		return false
+	}
+	if ok, _ := path.Match(f.Request.Path, r.URL.Path); !ok {
+		return false
+	}
+	query := r.URL.Query()
+	for name, value := range f.Request.Query {
+		if query.Get(name) != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Mock serves fixtures as the Spotify Web API.
+type Mock struct {
+	fixtures []*Fixture
+}
+
+// New loads the *.json fixtures of fsys and its directories. When several
+// fixtures match a request, the one requiring the most query parameters
+// wins, then the first by file name.
+func New(fsys fs.FS) (*Mock, error) {
+	var paths []string
+	err := fs.WalkDir(fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() && strings.HasSuffix(name, ".json") {
+			paths = append(paths, name)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Mock{}
+	for _, name := range paths {
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		fixture := &Fixture{}
+		if err := json.Unmarshal(data, fixture); err != nil {
+			return nil, fmt.Errorf("invalid fixture %s: %w", name, err)
+		}
+		if fixture.Request.Method == "" || fixture.Request.Path == "" {
+			return nil, fmt.Errorf("invalid fixture %s: the request method and path are required", name)
+		}
+		if _, err := path.Match(fixture.Request.Path, "/"); err != nil {
+			return nil, fmt.Errorf("invalid fixture %s: %w", name, err)
+		}
+		m.fixtures = append(m.fixtures, fixture)
+	}
+	sort.SliceStable(m.fixtures, func(i, j int) bool {
+		return len(m.fixtures[i].Request.Query) > len(m.fixtures[j].Request.Query)
+	})
+	return m, nil
+}
+
+// ServeHTTP answers r with the response of its fixture, or a 404 error in
+// the format of Spotify naming the request without one.
+func (m *Mock) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	for _, fixture := range m.fixtures {
+		if !fixture.matches(r) {
+			continue
+		}
+		status := fixture.Response.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		if len(fixture.Response.Body) > 0 {
+			w.Header().Set("Content-Type", "application/json")
+		}
+		w.WriteHeader(status)
+		w.Write(fixture.Response.Body)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNotFound)
+	json.NewEncoder(w).Encode(map[string]any{"error": map[string]any{
+		"status":  http.StatusNotFound,
+		"message": fmt.Sprintf("spotify mock: no fixture for %s %s", r.Method, r.URL.RequestURI()),
+	}})
+}
+
+// Listener serves handler, i.e. a Mock or a Recorder, on a local port until
+// Close.
+type Listener struct {
+	// URL is the API URL to pass to spotifyserver.WithAPIURL.
+	URL      string
+	listener net.Listener
+}
+
+// Listen serves handler on a random port of 127.0.0.1.
+func Listen(handler http.Handler) (*Listener, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	go http.Serve(listener, handler)
+	return &Listener{URL: (&url.URL{Scheme: "http", Host: listener.Addr().String()}).String(), listener: listener}, nil
+}
+
+// Close stops serving.
+func (l *Listener) Close() error {
+	return l.listener.Close()
+}
@@ -0,0 +1,198 @@
+package spotifymock_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wagnerjt/go-mcp/spotify/pkg/spotifymock"
+	"github.com/wagnerjt/go-mcp/spotify/pkg/spotifyserver"
+	"github.com/wagnerjt/go-mcp/spotify/pkg/tokenstore"
+	"golang.org/x/oauth2"
+)
+
+func listen(t *testing.T, handler http.Handler) string {
+	t.Helper()
+	listener, err := spotifymock.Listen(handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	return listener.URL
+}
+
+// newServer is the Spotify server on the built-in fixtures, as in mock mode.
+func newServer(t *testing.T) *spotifyserver.Server {
+	t.Helper()
+	mock, err := spotifymock.New(spotifymock.Fixtures)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens := tokenstore.NewMemory()
+	tokens.Put(context.Background(), spotifyserver.TokenKey, tokenstore.Token{Token: &oauth2.Token{AccessToken: "mock"}})
+	srv, err := spotifyserver.New(
+		spotifyserver.WithClientCredentials("mock", "mock"),
+		spotifyserver.WithWellKnownConfig(spotifymock.WellKnownConfig),
+		spotifyserver.WithAPIURL(listen(t, mock)),
+		spotifyserver.WithTokenStore(tokens),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return srv
+}
+
+// callTool calls a tool through the MCP server and returns the text of the
+// result, or of the error.
+func callTool(t *testing.T, srv *spotifyserver.Server, name string, arguments string) string {
+	t.Helper()
+	message := fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":%q,"arguments":%s}}`, name, arguments)
+	data, _ := json.Marshal(srv.MCPServer().HandleMessage(context.Background(), []byte(message)))
+	var response struct {
+		Result struct {
+			Content []struct {
+				Text string `json:"text"`
+			} `json:"content"`
+		} `json:"result"`
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(data, &response); err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Result.Content) == 0 {
+		return response.Error.Message
+	}
+	return response.Result.Content[0].Text
+}
+
+func TestFixtures(t *testing.T) {
+	srv := newServer(t)
+	tests := []struct {
+		tool      string
+		arguments string
+		want      string
+	}{
+		{"search_spotify", `{"query":"karma"}`, `"name":"Karma Police"`},
+		{"search_spotify", `{"query":"radiohead","types":["artist","playlist"]}`, `"followers":{"total":9000000}`},
+		{"search_shows", `{"query":"syntax"}`, `"publisher":"Wes Bos`},
+		{"list_show_episodes", `{"show":"spotify:show:5CfCWKI5pZ28U0uOzXkDHe"}`, `"name":"The Model Context Protocol"`},
+		{"get_episode", `{"episode":"7makk4oTQel546B0PZlDM5"}`, `"resume_position_ms":600000`},
+		{"list_saved_shows", `{}`, `"added_at":"2025-01-02T10:00:00Z"`},
+		{"save_shows", `{"shows":["5CfCWKI5pZ28U0uOzXkDHe"]}`, "Saved 1 shows"},
+		{"undo_last", `{}`, "Undone: saved 1 shows"},
+		{"start_dj_session", `{"seed_genres":["rock"],"max_tracks":1}`, `"stopped":"max_tracks queued"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tool, func(t *testing.T) {
+			if got := callTool(t, srv, tt.tool, tt.arguments); !strings.Contains(got, tt.want) {
+				t.Errorf("%s = %s, want %s", tt.tool, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchResultSchema(t *testing.T) {
+	srv := newServer(t)
+	for _, arguments := range []string{
+		`{"query":"karma","limit":2}`,
+		`{"query":"radiohead","types":["track","album","artist","playlist","show","episode"],"fields":["*"]}`,
+	} {
+		var result any
+		if err := json.Unmarshal([]byte(callTool(t, srv, "search_spotify", arguments)), &result); err != nil {
+			t.Fatal(err)
+		}
+		if err := spotifyserver.SearchResultSchema.Validate(result); err != nil {
+			t.Errorf("search_spotify %s does not match its schema: %v", arguments, err)
+		}
+	}
+
+	page := callTool(t, srv, "search_spotify", `{"query":"karma","limit":2}`)
+	if !strings.Contains(page, `"total":3,"offset":0,"nextOffset":2`) {
+		t.Errorf("first page = %s, want a nextOffset of 2", page)
+	}
+	if last := callTool(t, srv, "search_spotify", `{"query":"karma"}`); strings.Contains(last, "nextOffset") {
+		t.Errorf("last page = %s, want no nextOffset", last)
+	}
+}
+
+// TestWellKnownConfigKept checks that mock mode never fetches the well-known
+// config of Spotify, so it works offline.
+func TestWellKnownConfigKept(t *testing.T) {
+	srv := newServer(t)
+	for _, result := range srv.Check(context.Background()) {
+		if !result.OK {
+			t.Errorf("check %s failed: %s", result.Name, result.Detail)
+		}
+	}
+	if err := srv.Reload(); err != nil {
+		t.Fatalf("Reload() = %v", err)
+	}
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/.well-known/oauth-authorization-server", nil))
+	if !strings.Contains(rec.Body.String(), `"issuer":"https://accounts.spotify.com"`) || strings.Contains(rec.Body.String(), "jwks_uri") {
+		t.Errorf("well-known config after Reload() = %s, want the mock config", rec.Body)
+	}
+}
+
+func TestNoFixture(t *testing.T) {
+	mock, err := spotifymock.New(spotifymock.Fixtures)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	mock.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/artists/1/albums", nil))
+	if rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), "no fixture for GET /artists/1/albums") {
+		t.Errorf("ServeHTTP() = %d %s, want a 404 naming the request", rec.Code, rec.Body)
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/me" || r.Header.Get("Authorization") != "Bearer real" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"id":"recorded-user"}`))
+	}))
+	defer upstream.Close()
+	dir := t.TempDir()
+	recorder, err := spotifymock.NewRecorder(upstream.URL+"/v1", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, listen(t, recorder)+"/me?market=DE", nil)
+	req.Header.Set("Authorization", "Bearer real")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	files, _ := filepath.Glob(filepath.Join(dir, "GET_me_*.json"))
+	if len(files) != 1 {
+		t.Fatalf("recorded %v, want one fixture of GET /me", files)
+	}
+	data, _ := os.ReadFile(files[0])
+	if strings.Contains(string(data), "real") && strings.Contains(string(data), "Bearer") {
+		t.Errorf("the fixture recorded the Authorization header: %s", data)
+	}
+
+	replay, err := spotifymock.New(os.DirFS(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	replay.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/me?market=DE", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "recorded-user") {
+		t.Errorf("replayed %d %s, want the recorded response", rec.Code, rec.Body)
+	}
+}
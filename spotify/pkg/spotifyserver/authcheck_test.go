@@ -0,0 +1,116 @@
+package spotifyserver_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/wagnerjt/go-mcp/client/pkg/mcpclient"
+	"github.com/wagnerjt/go-mcp/shared/pkg/jwtauth"
+	"github.com/wagnerjt/go-mcp/spotify/pkg/spotifymock"
+	"github.com/wagnerjt/go-mcp/spotify/pkg/spotifyserver"
+	"github.com/wagnerjt/go-mcp/spotify/pkg/tokenstore"
+	"golang.org/x/oauth2"
+)
+
+// onlyToken validates token and rejects every other one.
+func onlyToken(token string) jwtauth.TokenValidator {
+	return jwtauth.TokenValidatorFunc(func(ctx context.Context, got string) (*jwtauth.Claims, error) {
+		if got != token {
+			return nil, fmt.Errorf("%w: unknown token", jwtauth.ErrInvalidToken)
+		}
+		return &jwtauth.Claims{}, nil
+	})
+}
+
+// TestCheckAuthorization runs the MCP authorization spec checks of the
+// client against the Spotify server in mock mode, so a server drifting from
+// the spec fails here.
+func TestCheckAuthorization(t *testing.T) {
+	mock, err := spotifymock.New(spotifymock.Fixtures)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const token = "valid-token"
+	// unlike the mock, Spotify rejects the tokens it did not issue
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth := r.Header.Get("Authorization"); auth != "Bearer mock" && auth != "Bearer "+token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mock.ServeHTTP(w, r)
+	}))
+	defer api.Close()
+
+	tests := []struct {
+		name    string
+		opts    []spotifyserver.Option
+		token   string
+		failing []string
+	}{
+		{
+			name:  "validating auth",
+			opts:  []spotifyserver.Option{spotifyserver.WithTokenValidator(onlyToken(token))},
+			token: token,
+		},
+		{
+			name: "without token",
+			opts: []spotifyserver.Option{spotifyserver.WithTokenValidator(onlyToken(token))},
+		},
+		{
+			name:  "spotify tokens",
+			token: token,
+		},
+		{
+			name: "any token accepted",
+			opts: []spotifyserver.Option{spotifyserver.WithTokenValidator(jwtauth.TokenValidatorFunc(
+				func(ctx context.Context, token string) (*jwtauth.Claims, error) { return &jwtauth.Claims{}, nil }))},
+			token:   token,
+			failing: []string{"invalid token rejected"},
+		},
+		{
+			name: "without PKCE",
+			opts: []spotifyserver.Option{
+				spotifyserver.WithTokenValidator(onlyToken(token)),
+				spotifyserver.WithWellKnownConfig([]byte(`{"authorization_endpoint":"https://accounts.spotify.com/authorize","token_endpoint":"https://accounts.spotify.com/api/token"}`)),
+			},
+			token:   token,
+			failing: []string{"PKCE S256"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// a logged in user, like -spotify-mock
+			store := tokenstore.NewMemory()
+			store.Put(context.Background(), spotifyserver.TokenKey, tokenstore.Token{Token: &oauth2.Token{AccessToken: "mock"}})
+			opts := append([]spotifyserver.Option{
+				spotifyserver.WithClientCredentials("mock", "mock"),
+				spotifyserver.WithWellKnownConfig(spotifymock.WellKnownConfig),
+				spotifyserver.WithAPIURL(api.URL),
+				spotifyserver.WithTokenStore(store),
+			}, tt.opts...)
+			srv, err := spotifyserver.New(opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ts := httptest.NewServer(srv.Handler())
+			defer ts.Close()
+
+			failing := make(map[string]bool)
+			for _, name := range tt.failing {
+				failing[name] = true
+			}
+			results := mcpclient.CheckAuthorization(context.Background(), ts.URL+"/mcp", tt.token)
+			if len(results) != 7 {
+				t.Errorf("got %d results, want 7: %+v", len(results), results)
+			}
+			for _, result := range results {
+				if result.OK == failing[result.Name] {
+					t.Errorf("%s: ok %v, want %v (%s)", result.Name, result.OK, !failing[result.Name], result.Detail)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package spotifyserver
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/wagnerjt/go-mcp/shared/pkg/healthcheck"
+)
+
+// CheckResult is one item of the startup self-test report.
+type CheckResult = healthcheck.Result
+
+// Check self-tests the constructed server without serving traffic: the tool
+// schemas and the Spotify upstream.
+func (s *Server) Check(ctx context.Context) []CheckResult {
+	s.mu.RLock()
+	results := []CheckResult{{
+		Name:   "config",
+		OK:     true,
+		Detail: fmt.Sprintf("client %s, redirect %s, scopes %v", s.clientID, s.redirectURI(s.externalURL), s.scopes),
+	}}
+	s.mu.RUnlock()
+
+	tools, err := s.listTools(ctx)
+	if err != nil {
+		results = append(results, CheckResult{Name: "tools", Detail: err.Error()})
+	}
+	for _, tool := range tools {
+		result := CheckResult{Name: "tool " + tool.Name, OK: true}
+		if err := healthcheck.ValidateToolSchema(tool); err != nil {
+			result.OK = false
+			result.Detail = err.Error()
+		}
+		results = append(results, result)
+	}
+
+	upstream := CheckResult{Name: "upstream " + SpotifyWellKnownURL, OK: true}
+	if s.wellKnownFixed {
+		upstream.Name = "upstream well-known config"
+		upstream.Detail = "set by WithWellKnownConfig, not fetched"
+	} else if err := s.fetchWellKnownConfig(ctx); err != nil {
+		upstream.OK = false
+		upstream.Detail = err.Error()
+	}
+	return append(results, upstream)
+}
+
+// listTools lists the tools the way a client sees them.
+func (s *Server) listTools(ctx context.Context) ([]mcp.Tool, error) {
+	return healthcheck.ListTools(ctx, s.mcpServer)
+}
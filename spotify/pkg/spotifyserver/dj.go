@@ -0,0 +1,273 @@
+package spotifyserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/wagnerjt/go-mcp/spotify/pkg/schema"
+)
+
+// Names of the DJ session tools.
+const (
+	StartDJSessionToolName = "start_dj_session"
+	StopDJSessionToolName  = "stop_dj_session"
+)
+
+// errDJStopped ends a DJ session stopped by stop_dj_session.
+var errDJStopped = errors.New("stopped by " + StopDJSessionToolName)
+
+// djSession is the running DJ session of an MCP session.
+type djSession struct {
+	cancel context.CancelCauseFunc
+}
+
+// queuedTrack is a track queued by a DJ session.
+type queuedTrack struct {
+	Name    string `json:"name"`
+	URI     string `json:"uri"`
+	Artists string `json:"artists"`
+}
+
+// recommendedTrack is a track of the Spotify recommendations.
+type recommendedTrack struct {
+	ID      string `json:"id"`
+	URI     string `json:"uri"`
+	Name    string `json:"name"`
+	Artists []struct {
+		Name string `json:"name"`
+	} `json:"artists"`
+}
+
+func (t recommendedTrack) queued() queuedTrack {
+	artists := make([]string, len(t.Artists))
+	for i, artist := range t.Artists {
+		artists[i] = artist.Name
+	}
+	return queuedTrack{Name: t.Name, URI: t.URI, Artists: strings.Join(artists, ", ")}
+}
+
+// djSeeds are the seeds of the recommendations, at most 5 in total.
+type djSeeds struct {
+	tracks, artists, genres []string
+}
+
+func (seeds djSeeds) query() url.Values {
+	query := url.Values{}
+	for name, values := range map[string][]string{"seed_tracks": seeds.tracks, "seed_artists": seeds.artists, "seed_genres": seeds.genres} {
+		if len(values) > 0 {
+			query.Set(name, strings.Join(values, ","))
+		}
+	}
+	return query
+}
+
+func (s *Server) djTools() []server.ServerTool {
+	seedInput := func(description string) *schema.Schema {
+		return schema.Array(schema.String(schema.Length(1, -1)), schema.Length(0, 5), schema.Description(description))
+	}
+	return []server.ServerTool{
+		schema.NewTool(StartDJSessionToolName, schema.Object(schema.Properties{
+			"seed_tracks":  seedInput("IDs, URIs or open.spotify.com URLs of the tracks to base the recommendations on"),
+			"seed_artists": seedInput("IDs, URIs or open.spotify.com URLs of the artists to base the recommendations on"),
+			"seed_genres":  seedInput("Genres to base the recommendations on, i.e. indie-pop"),
+			"interval_seconds": schema.Integer(schema.Min(5), schema.Max(3600), schema.Default(180),
+				schema.Description("Seconds between queuing two tracks, about the length of a track keeps the queue short")),
+			"max_tracks": schema.Integer(schema.Min(0), schema.Default(0),
+				schema.Description("Tracks to queue before the session ends, 0 queues until it is cancelled")),
+			"device_id": schema.String(schema.Description("Device to queue on, the active one by default")),
+			"market":    marketInput,
+		}), s.handleStartDJSessionTool,
+			mcp.WithDescription("Keeps queuing tracks recommended from the seeds, 1 to 5 tracks, artists and genres in total, on the player of the user until the call is cancelled, "+StopDJSessionToolName+" is called or max_tracks were queued. Each queued track is reported as a progress notification"),
+			mcp.WithDestructiveHintAnnotation(false),
+			mcp.WithOpenWorldHintAnnotation(true),
+		),
+		schema.NewTool(StopDJSessionToolName, schema.Object(schema.Properties{}), s.handleStopDJSessionTool,
+			mcp.WithDescription("Stops the DJ session running in this MCP session, the call of "+StartDJSessionToolName+" then returns the queued tracks"),
+			mcp.WithDestructiveHintAnnotation(false),
+			mcp.WithIdempotentHintAnnotation(true),
+		),
+	}
+}
+
+func mcpSessionID(ctx context.Context) string {
+	if session := server.ClientSessionFromContext(ctx); session != nil {
+		return session.SessionID()
+	}
+	return ""
+}
+
+func (s *Server) handleStartDJSessionTool(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	ids := func(kind, argument string) []string {
+		values := request.GetStringSlice(argument, nil)
+		for i, value := range values {
+			values[i] = spotifyID(kind, value)
+		}
+		return values
+	}
+	seeds := djSeeds{
+		tracks:  ids("track", "seed_tracks"),
+		artists: ids("artist", "seed_artists"),
+		genres:  request.GetStringSlice("seed_genres", nil),
+	}
+	if count := len(seeds.tracks) + len(seeds.artists) + len(seeds.genres); count < 1 || count > 5 {
+		return nil, fmt.Errorf("invalid arguments: want 1 to 5 seeds in total, got %d", count)
+	}
+	interval := time.Duration(request.GetInt("interval_seconds", 180)) * time.Second
+	maxTracks := request.GetInt("max_tracks", 0)
+
+	// a new session replaces the running one of the MCP session
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	session := &djSession{cancel: cancel}
+	id := mcpSessionID(ctx)
+	s.djMu.Lock()
+	if running, ok := s.djSessions[id]; ok {
+		running.cancel(errDJStopped)
+	}
+	if s.djSessions == nil {
+		s.djSessions = make(map[string]*djSession)
+	}
+	s.djSessions[id] = session
+	s.djMu.Unlock()
+	defer func() {
+		s.djMu.Lock()
+		if s.djSessions[id] == session {
+			delete(s.djSessions, id)
+		}
+		s.djMu.Unlock()
+	}()
+
+	queueQuery := url.Values{}
+	if device := request.GetString("device_id", ""); device != "" {
+		queueQuery.Set("device_id", device)
+	}
+	var (
+		queued  []queuedTrack
+		seen    = make(map[string]bool)
+		pending []recommendedTrack
+		stopped string
+	)
+	for stopped == "" {
+		if len(pending) == 0 {
+			var err error
+			if pending, err = s.recommend(ctx, seeds, request.GetString("market", ""), seen); err != nil {
+				if ctx.Err() != nil {
+					break
+				}
+				return nil, err
+			}
+			if len(pending) == 0 && len(queued) > 0 {
+				// the seeds are exhausted, continue from the last queued tracks
+				seeds = djSeeds{}
+				for _, track := range queued[max(0, len(queued)-5):] {
+					seeds.tracks = append(seeds.tracks, spotifyID("track", track.URI))
+				}
+				if pending, err = s.recommend(ctx, seeds, request.GetString("market", ""), seen); err != nil && ctx.Err() == nil {
+					return nil, err
+				}
+			}
+			if len(pending) == 0 {
+				stopped = "no further recommendations"
+				break
+			}
+		}
+
+		track := pending[0]
+		pending = pending[1:]
+		seen[track.ID] = true
+		queueQuery.Set("uri", track.URI)
+		if err := s.api(ctx, http.MethodPost, "/me/player/queue?"+queueQuery.Encode(), nil); err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			return nil, err
+		}
+		queued = append(queued, track.queued())
+		s.notifyDJProgress(ctx, request, len(queued), maxTracks, track.queued())
+
+		if maxTracks > 0 && len(queued) >= maxTracks {
+			stopped = "max_tracks queued"
+			break
+		}
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+		case <-timer.C:
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if stopped == "" {
+		stopped = "cancelled"
+		if errors.Is(context.Cause(ctx), errDJStopped) {
+			stopped = errDJStopped.Error()
+		}
+	}
+	return jsonResult(struct {
+		Queued  []queuedTrack `json:"queued"`
+		Stopped string        `json:"stopped"`
+	}{queued, stopped})
+}
+
+// recommend returns the recommendations for seeds, without the tracks seen
+// before.
+func (s *Server) recommend(ctx context.Context, seeds djSeeds, market string, seen map[string]bool) ([]recommendedTrack, error) {
+	query := seeds.query()
+	query.Set("limit", "20")
+	if market != "" {
+		query.Set("market", market)
+	}
+	var recommendations struct {
+		Tracks []recommendedTrack `json:"tracks"`
+	}
+	if err := s.api(ctx, http.MethodGet, "/recommendations?"+query.Encode(), &recommendations); err != nil {
+		return nil, err
+	}
+	var fresh []recommendedTrack
+	for _, track := range recommendations.Tracks {
+		if !seen[track.ID] {
+			fresh = append(fresh, track)
+		}
+	}
+	return fresh, nil
+}
+
+// notifyDJProgress reports a queued track to the client, when the call asked
+// for progress notifications.
+func (s *Server) notifyDJProgress(ctx context.Context, request mcp.CallToolRequest, count, maxTracks int, track queuedTrack) {
+	if request.Params.Meta == nil || request.Params.Meta.ProgressToken == nil {
+		return
+	}
+	params := map[string]any{
+		"progressToken": request.Params.Meta.ProgressToken,
+		"progress":      count,
+		"message":       fmt.Sprintf("Queued %s by %s", track.Name, track.Artists),
+	}
+	if maxTracks > 0 {
+		params["total"] = maxTracks
+	}
+	if err := s.mcpServer.SendNotificationToClient(ctx, "notifications/progress", params); err != nil {
+		log.Printf("Failed to report the queued track %s: %v", track.URI, err)
+	}
+}
+
+func (s *Server) handleStopDJSessionTool(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.djMu.Lock()
+	session, ok := s.djSessions[mcpSessionID(ctx)]
+	s.djMu.Unlock()
+	if !ok {
+		return mcp.NewToolResultText("No DJ session is running"), nil
+	}
+	session.cancel(errDJStopped)
+	return mcp.NewToolResultText("Stopped the DJ session"), nil
+}
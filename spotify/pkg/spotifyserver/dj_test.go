@@ -0,0 +1,272 @@
+package spotifyserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// djTestSession is an MCP session receiving the progress notifications.
+type djTestSession struct {
+	id            string
+	notifications chan mcp.JSONRPCNotification
+}
+
+func (s *djTestSession) Initialize()       {}
+func (s *djTestSession) Initialized() bool { return true }
+func (s *djTestSession) SessionID() string { return s.id }
+func (s *djTestSession) NotificationChannel() chan<- mcp.JSONRPCNotification {
+	return s.notifications
+}
+
+func djContext(s *Server, id string) (context.Context, *djTestSession) {
+	session := &djTestSession{id: id, notifications: make(chan mcp.JSONRPCNotification, 10)}
+	return s.mcpServer.WithContext(context.Background(), session), session
+}
+
+type djResult struct {
+	Queued  []queuedTrack `json:"queued"`
+	Stopped string        `json:"stopped"`
+}
+
+func parseDJResult(t *testing.T, text string) djResult {
+	t.Helper()
+	var result djResult
+	if err := json.Unmarshal([]byte(text), &result); err != nil {
+		t.Fatalf("invalid result %q: %v", text, err)
+	}
+	return result
+}
+
+func (r djResult) uris() []string {
+	uris := make([]string, len(r.Queued))
+	for i, track := range r.Queued {
+		uris[i] = track.URI
+	}
+	return uris
+}
+
+// recommendations answers the recommendations for the seed tracks with
+// the tracks of the same key, by URI.
+func recommendations(api *spotifyAPI, tracks map[string][]string) {
+	api.mux.HandleFunc("GET /recommendations", func(w http.ResponseWriter, r *http.Request) {
+		var response struct {
+			Tracks []map[string]any `json:"tracks"`
+		}
+		response.Tracks = []map[string]any{}
+		for _, uri := range tracks[r.URL.Query().Get("seed_tracks")] {
+			id := strings.TrimPrefix(uri, "spotify:track:")
+			response.Tracks = append(response.Tracks, map[string]any{
+				"id": id, "uri": uri, "name": "Track " + id,
+				"artists": []map[string]any{{"name": "A"}, {"name": "B"}},
+			})
+		}
+		json.NewEncoder(w).Encode(response)
+	})
+}
+
+// startDJ calls the handler of start_dj_session directly, so the interval
+// can be below the minimum of its schema.
+func startDJ(t *testing.T, ctx context.Context, s *Server, arguments map[string]any, progressToken mcp.ProgressToken) djResult {
+	t.Helper()
+	request := mcp.CallToolRequest{}
+	request.Params.Name = StartDJSessionToolName
+	request.Params.Arguments = arguments
+	if progressToken != nil {
+		request.Params.Meta = &mcp.Meta{ProgressToken: progressToken}
+	}
+	result, err := s.handleStartDJSessionTool(ctx, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parseDJResult(t, result.Content[0].(mcp.TextContent).Text)
+}
+
+func TestDJSession(t *testing.T) {
+	api := newSpotifyAPI()
+	recommendations(api, map[string][]string{"seed": {"spotify:track:1", "spotify:track:2", "spotify:track:3"}})
+	api.handle("POST /me/player/queue", ``)
+	s := newAPITestServer(t, api)
+	ctx, session := djContext(s, "session")
+
+	result := startDJ(t, ctx, s, map[string]any{
+		"seed_tracks":      []any{"https://open.spotify.com/track/seed"},
+		"seed_genres":      []any{"indie-pop"},
+		"interval_seconds": 0,
+		"max_tracks":       2,
+		"device_id":        "phone",
+		"market":           "US",
+	}, "progress")
+	if result.Stopped != "max_tracks queued" || !slices.Equal(result.uris(), []string{"spotify:track:1", "spotify:track:2"}) {
+		t.Errorf("got %+v, want tracks 1 and 2 queued", result)
+	}
+	if result.Queued[0] != (queuedTrack{Name: "Track 1", URI: "spotify:track:1", Artists: "A, B"}) {
+		t.Errorf("queued %+v", result.Queued[0])
+	}
+	want := []string{
+		"GET /recommendations?limit=20&market=US&seed_genres=indie-pop&seed_tracks=seed",
+		"POST /me/player/queue?device_id=phone&uri=spotify%3Atrack%3A1",
+		"POST /me/player/queue?device_id=phone&uri=spotify%3Atrack%3A2",
+	}
+	if requests := api.takeRequests(); !slices.Equal(requests, want) {
+		t.Errorf("requests %q, want %q", requests, want)
+	}
+
+	for i := 1; i <= 2; i++ {
+		notification := <-session.notifications
+		params := notification.Params.AdditionalFields
+		if notification.Method != "notifications/progress" || params["progressToken"] != "progress" ||
+			params["progress"] != i || params["total"] != 2 || params["message"] != fmt.Sprintf("Queued Track %d by A, B", i) {
+			t.Errorf("notification %d: %s %v", i, notification.Method, params)
+		}
+	}
+
+	// without a progress token nothing is reported
+	startDJ(t, ctx, s, map[string]any{"seed_tracks": []any{"seed"}, "interval_seconds": 0, "max_tracks": 1}, nil)
+	if len(session.notifications) != 0 {
+		t.Errorf("%d notifications without a progress token", len(session.notifications))
+	}
+}
+
+// TestDJSessionExhaustsSeeds checks that a session continues from the
+// queued tracks once the seeds have no further recommendations, and ends
+// when those have none either.
+func TestDJSessionExhaustsSeeds(t *testing.T) {
+	api := newSpotifyAPI()
+	recommendations(api, map[string][]string{
+		"seed": {"spotify:track:1", "spotify:track:2"},
+		"1,2":  {"spotify:track:2", "spotify:track:3"},
+	})
+	api.handle("POST /me/player/queue", ``)
+	s := newAPITestServer(t, api)
+	ctx, _ := djContext(s, "session")
+
+	result := startDJ(t, ctx, s, map[string]any{"seed_tracks": []any{"spotify:track:seed"}, "interval_seconds": 0}, nil)
+	if result.Stopped != "no further recommendations" || !slices.Equal(result.uris(), []string{"spotify:track:1", "spotify:track:2", "spotify:track:3"}) {
+		t.Errorf("got %+v, want tracks 1 to 3 queued", result)
+	}
+	var seeds []string
+	for _, request := range api.takeRequests() {
+		if strings.HasPrefix(request, "GET /recommendations") {
+			seeds = append(seeds, request[strings.Index(request, "seed_tracks="):])
+		}
+	}
+	if want := []string{"seed_tracks=seed", "seed_tracks=seed", "seed_tracks=1%2C2", "seed_tracks=1%2C2", "seed_tracks=1%2C2%2C3"}; !slices.Equal(seeds, want) {
+		t.Errorf("recommendations for %q, want %q", seeds, want)
+	}
+}
+
+// TestStopDJSession checks that a session runs until stop_dj_session is
+// called in its MCP session, a new session replaces it or its call is
+// cancelled.
+func TestStopDJSession(t *testing.T) {
+	api := newSpotifyAPI()
+	recommendations(api, map[string][]string{"seed": {"spotify:track:1", "spotify:track:2"}})
+	api.handle("POST /me/player/queue", ``)
+	s := newAPITestServer(t, api)
+
+	// start returns once the session reported its first queued track
+	start := func(ctx context.Context, session *djTestSession) <-chan string {
+		done := make(chan string, 1)
+		go func() {
+			message := `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"` + StartDJSessionToolName +
+				`","arguments":{"seed_tracks":["seed"]},"_meta":{"progressToken":"dj"}}}`
+			response, _ := s.mcpServer.HandleMessage(ctx, json.RawMessage(message)).(mcp.JSONRPCResponse)
+			result, _ := response.Result.(mcp.CallToolResult)
+			if len(result.Content) == 1 {
+				done <- result.Content[0].(mcp.TextContent).Text
+			}
+			close(done)
+		}()
+		select {
+		case <-session.notifications:
+		case <-time.After(5 * time.Second):
+			t.Fatal("no track queued")
+		}
+		return done
+	}
+	wait := func(done <-chan string) djResult {
+		t.Helper()
+		select {
+		case text, ok := <-done:
+			if !ok {
+				t.Fatal("the DJ session failed")
+			}
+			return parseDJResult(t, text)
+		case <-time.After(5 * time.Second):
+			t.Fatal("the DJ session did not stop")
+		}
+		return djResult{}
+	}
+
+	ctx, session := djContext(s, "session")
+	first := start(ctx, session)
+	other, _ := djContext(s, "other")
+	if text, _ := callTool(t, other, s, StopDJSessionToolName, nil); text != "No DJ session is running" {
+		t.Errorf("stop in another MCP session returned %q", text)
+	}
+	if text, _ := callTool(t, ctx, s, StopDJSessionToolName, nil); text != "Stopped the DJ session" {
+		t.Errorf("stop returned %q", text)
+	}
+	if result := wait(first); result.Stopped != errDJStopped.Error() || len(result.Queued) != 1 {
+		t.Errorf("stopped session returned %+v", result)
+	}
+
+	first = start(ctx, session)
+	cancelled, cancel := context.WithCancel(ctx)
+	second := start(cancelled, session)
+	if result := wait(first); result.Stopped != errDJStopped.Error() {
+		t.Errorf("replaced session returned %+v", result)
+	}
+	cancel()
+	if result := wait(second); result.Stopped != "cancelled" || len(result.Queued) != 1 {
+		t.Errorf("cancelled session returned %+v", result)
+	}
+	if text, _ := callTool(t, ctx, s, StopDJSessionToolName, nil); text != "No DJ session is running" {
+		t.Errorf("stop after the sessions ended returned %q", text)
+	}
+}
+
+func TestDJSessionErrors(t *testing.T) {
+	api := newSpotifyAPI()
+	recommendations(api, map[string][]string{"seed": {"spotify:track:1"}})
+	api.mux.HandleFunc("POST /me/player/queue", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": {"status": 404, "message": "Player command failed: No active device found"}}`))
+	})
+	s := newAPITestServer(t, api)
+	ctx, _ := djContext(s, "session")
+
+	for _, test := range []struct {
+		name      string
+		arguments map[string]any
+		wantErr   string
+		wantCalls int
+	}{
+		{"no seeds", map[string]any{}, "want 1 to 5 seeds in total, got 0", 0},
+		{"too many seeds", map[string]any{"seed_tracks": []string{"a", "b", "c"}, "seed_artists": []string{"d", "e", "f"}}, "got 6", 0},
+		{"interval too short", map[string]any{"seed_tracks": []string{"seed"}, "interval_seconds": 1}, "interval_seconds", 0},
+		{"no active device", map[string]any{"seed_tracks": []string{"seed"}}, "No active device found", 2},
+	} {
+		text, errMessage := callTool(t, ctx, s, StartDJSessionToolName, test.arguments)
+		if !strings.Contains(errMessage, test.wantErr) {
+			t.Errorf("%s: got %q, %q, want an error containing %q", test.name, text, errMessage, test.wantErr)
+		}
+		if requests := api.takeRequests(); len(requests) != test.wantCalls {
+			t.Errorf("%s: requests %q, want %d", test.name, requests, test.wantCalls)
+		}
+	}
+
+	s.djMu.Lock()
+	defer s.djMu.Unlock()
+	if len(s.djSessions) != 0 {
+		t.Errorf("failed sessions are still running: %v", s.djSessions)
+	}
+}
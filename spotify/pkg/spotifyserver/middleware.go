@@ -0,0 +1,184 @@
+package spotifyserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/wagnerjt/go-mcp/shared/pkg/jwtauth"
+	"github.com/wagnerjt/go-mcp/shared/pkg/oauthflow"
+	"github.com/wagnerjt/go-mcp/shared/pkg/upstream"
+	"golang.org/x/oauth2"
+)
+
+type authKey struct{}
+
+func withAuthKey(ctx context.Context, auth string) context.Context {
+	return context.WithValue(ctx, authKey{}, auth)
+}
+
+func authFromRequest(ctx context.Context, r *http.Request) context.Context {
+	return withAuthKey(ctx, r.Header.Get(AuthorizationHeader))
+}
+
+// WithTokenValidator validates the bearer tokens of the MCP endpoint with
+// validator, i.e. a jwtauth.Validator of an authorization server issuing
+// JWT access tokens. Without one the bearer tokens are Spotify access
+// tokens, which are opaque and verified with Spotify instead.
+func WithTokenValidator(validator jwtauth.TokenValidator) Option {
+	return func(s *Server) {
+		s.tokenValidator = validator
+	}
+}
+
+func textResponse(rw http.ResponseWriter, status int, body string) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	if body != "" {
+		rw.Write([]byte(body))
+	}
+}
+
+// challenge points the client of r to the protected resource metadata.
+func challenge(r *http.Request) oauthflow.Challenge {
+	return oauthflow.Challenge{
+		Realm:            "spotify-go-server",
+		ResourceMetadata: baseURLFromContext(r.Context()) + "/.well-known/oauth-protected-resource",
+		AuthorizationURI: SpotifyAuthEndpoint,
+		Error:            "unauthorized",
+	}
+}
+
+// TokenCacheTTL is how long a bearer token found to belong to the logged in
+// user is trusted before Spotify is asked again.
+const TokenCacheTTL = 5 * time.Minute
+
+// verifiedToken is a bearer token Spotify reported to belong to user.
+type verifiedToken struct {
+	user    string
+	expires time.Time
+}
+
+// spotifyUser returns the ID of the Spotify user of token, along with the
+// status code of the failed call.
+func (s *Server) spotifyUser(ctx context.Context, token *oauth2.Token) (string, int, error) {
+	resp, err := s.apiRequest(ctx, http.MethodGet, "/me", token)
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return "", resp.StatusCode, fmt.Errorf("spotify GET /me: status code %d", resp.StatusCode)
+	}
+	var user struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return "", resp.StatusCode, fmt.Errorf("spotify GET /me: %w", upstream.Error(ctx, err))
+	}
+	if user.ID == "" {
+		return "", resp.StatusCode, fmt.Errorf("spotify GET /me: no user ID")
+	}
+	return user.ID, resp.StatusCode, nil
+}
+
+// validateSpotifyToken validates the bearer tokens without
+// WithTokenValidator: only the Spotify access tokens of the user of the last
+// login are valid, since the tools act with the token of that login.
+// Spotify tells the user of a token on GET /me, which is cached for
+// TokenCacheTTL by the hash of the token.
+func (s *Server) validateSpotifyToken(ctx context.Context, bearer string) (*jwtauth.Claims, error) {
+	key := sha256.Sum256([]byte(bearer))
+	now := s.clock()
+
+	s.mu.RLock()
+	login := s.token
+	loginUser := s.loginUser
+	cached, ok := s.verified[key]
+	s.mu.RUnlock()
+	if login == nil {
+		return nil, fmt.Errorf("%w: not logged in to Spotify", jwtauth.ErrInvalidToken)
+	}
+	if ok && cached.user == loginUser && now.Before(cached.expires) {
+		return &jwtauth.Claims{Subject: cached.user}, nil
+	}
+
+	if loginUser == "" {
+		var me struct {
+			ID string `json:"id"`
+		}
+		if err := s.api(ctx, http.MethodGet, "/me", &me); err != nil {
+			return nil, fmt.Errorf("failed to look up the logged in user: %w", err)
+		}
+		loginUser = me.ID
+	}
+	user, status, err := s.spotifyUser(ctx, &oauth2.Token{AccessToken: bearer})
+	if err != nil && (status == http.StatusUnauthorized || status == http.StatusForbidden) {
+		return nil, fmt.Errorf("%w: %v", jwtauth.ErrInvalidToken, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify the token with Spotify: %w", err)
+	}
+	if user != loginUser {
+		return nil, fmt.Errorf("%w: token of Spotify user %q, not of the logged in user", jwtauth.ErrInvalidToken, user)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// a login or refresh in the meantime leaves the cache alone
+	if s.token != login {
+		return &jwtauth.Claims{Subject: user}, nil
+	}
+	s.loginUser = loginUser
+	for key, cached := range s.verified {
+		if !now.Before(cached.expires) {
+			delete(s.verified, key)
+		}
+	}
+	if s.verified == nil {
+		s.verified = make(map[[sha256.Size]byte]verifiedToken)
+	}
+	s.verified[key] = verifiedToken{user: user, expires: now.Add(TokenCacheTTL)}
+	return &jwtauth.Claims{Subject: user}, nil
+}
+
+// bearerMiddleware challenges requests without a bearer token, and rejects
+// the ones whose token fails the validator of WithTokenValidator, or is not
+// a Spotify access token of the logged in user without a validator.
+func (s *Server) bearerMiddleware(next http.Handler) http.Handler {
+	validator := s.tokenValidator
+	if validator == nil {
+		validator = jwtauth.TokenValidatorFunc(s.validateSpotifyToken)
+	}
+	return oauthflow.RequireBearer(challenge)(jwtauth.Middleware(validator, rejectInvalidToken)(next))
+}
+
+// rejectInvalidToken answers a request whose token failed validation with
+// the invalid_token error of RFC 6750, or 503 when the keys of the
+// authorization server could not be fetched, or Spotify not be asked.
+func rejectInvalidToken(w http.ResponseWriter, r *http.Request, err error) {
+	log.Printf("Rejected bearer token: %v", err)
+	if !errors.Is(err, jwtauth.ErrInvalidToken) {
+		textResponse(w, http.StatusServiceUnavailable, `{"error":"temporarily_unavailable"}`)
+		return
+	}
+	c := challenge(r)
+	c.AuthorizationURI, c.Error = "", "invalid_token"
+	c.Reject(w)
+}
+
+func handleAuthSmokeTest(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get(AuthorizationHeader)
+	if _, session := oauthflow.SessionFromContext(r.Context()); auth == "" && !session {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"status":"AUTHENTICATED"}`))
+}
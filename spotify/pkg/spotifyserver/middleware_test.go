@@ -0,0 +1,84 @@
+package spotifyserver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wagnerjt/go-mcp/shared/pkg/jwtauth"
+	"github.com/wagnerjt/go-mcp/spotify/pkg/tokenstore"
+	"golang.org/x/oauth2"
+)
+
+// TestValidateSpotifyToken checks that without a validator only the Spotify
+// tokens of the logged in user are accepted, and that Spotify is asked once
+// per token until TokenCacheTTL.
+func TestValidateSpotifyToken(t *testing.T) {
+	users := map[string]string{"Bearer login": "alice", "Bearer other-device": "alice", "Bearer mallory": "mallory"}
+	calls := 0
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.Header.Get("Authorization") == "Bearer unavailable" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		user, ok := users[r.Header.Get("Authorization")]
+		if r.URL.Path != "/me" || !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"id":"` + user + `"}`))
+	}))
+	defer api.Close()
+
+	now := time.Unix(1700000000, 0)
+	store := tokenstore.NewMemory()
+	store.Put(context.Background(), TokenKey, tokenstore.Token{Token: &oauth2.Token{AccessToken: "login"}})
+	s, err := New(WithClientCredentials("id", "secret"), WithTokenStore(store), WithAPIURL(api.URL),
+		WithClock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		token   string
+		invalid bool
+		err     bool
+	}{
+		{token: "login"},
+		{token: "other-device"},
+		{token: "mallory", invalid: true},
+		{token: "forged", invalid: true},
+		{token: "unavailable", err: true},
+	} {
+		claims, err := s.validateSpotifyToken(context.Background(), test.token)
+		switch {
+		case test.invalid && !errors.Is(err, jwtauth.ErrInvalidToken):
+			t.Errorf("%s: got %v, want an invalid token", test.token, err)
+		case test.err && (err == nil || errors.Is(err, jwtauth.ErrInvalidToken)):
+			t.Errorf("%s: got %v, want a failed verification", test.token, err)
+		case !test.invalid && !test.err && (err != nil || claims.Subject != "alice"):
+			t.Errorf("%s: got %+v, %v, want alice", test.token, claims, err)
+		}
+	}
+
+	calls = 0
+	if _, err := s.validateSpotifyToken(context.Background(), "other-device"); err != nil || calls != 0 {
+		t.Errorf("cached token: %v after %d calls, want no call", err, calls)
+	}
+	now = now.Add(TokenCacheTTL)
+	if _, err := s.validateSpotifyToken(context.Background(), "other-device"); err != nil || calls != 1 {
+		t.Errorf("expired token: %v after %d calls, want 1 call", err, calls)
+	}
+
+	if _, err := s.Logout(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.validateSpotifyToken(context.Background(), "login"); err == nil || !strings.Contains(err.Error(), "not logged in") {
+		t.Errorf("after logout: got %v, want not logged in", err)
+	}
+}
@@ -0,0 +1,61 @@
+package spotifyserver
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/wagnerjt/go-mcp/shared/pkg/oauthflow"
+)
+
+// OAuthProtectedResource is the metadata served on
+// /.well-known/oauth-protected-resource, shared with the GitHub server.
+type OAuthProtectedResource = oauthflow.ProtectedResource
+
+func (s *Server) returnWellKnownAuthServer(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	// body := OAuthProtectedResource{
+	// 	Resource:               "https://accounts.spotify.com",
+	// 	AuthorizationServers:   []string{SpotifyAuthEndpoint},
+	// 	BearerMethodsSupported: []string{"header"},
+	// 	ScopesSupported:        []string{"user-read-private", "user-read-email"},
+	// }
+
+	proxy_body := OAuthProtectedResource{
+		Resource:               s.baseURL(r) + "/",
+		AuthorizationServers:   []string{SpotifyAuthEndpoint},
+		BearerMethodsSupported: []string{"header"},
+		ScopesSupported:        []string{"user-read-private", "user-read-email"},
+	}
+
+	// ignore error for simplicity
+	bodyJSON, _ := json.Marshal(proxy_body)
+	w.Write(bodyJSON)
+}
+
+func (s *Server) returnWellKnownProxy(w http.ResponseWriter, r *http.Request) {
+	// Spotify does not have a well-known endpoint for OAuth authorization resources, proxy it for now
+	s.mu.RLock()
+	config := s.wellKnownConfig
+	s.mu.RUnlock()
+	if config == nil {
+		http.Error(w, "Authorization server metadata not loaded yet", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(config)
+}
+
+// Handler to start the PKCE OAuth flow
+func (s *Server) handleSpotifyLogin(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	scopes := s.scopes
+	s.mu.RUnlock()
+	authURL, _, err := s.auth.Start(s.redirectURI(s.baseURL(r)), scopes, false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
@@ -0,0 +1,258 @@
+package spotifyserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/wagnerjt/go-mcp/shared/pkg/compensation"
+	"github.com/wagnerjt/go-mcp/spotify/pkg/schema"
+)
+
+// Names of the podcast tools.
+const (
+	SearchShowsToolName      = "search_shows"
+	ListShowEpisodesToolName = "list_show_episodes"
+	GetEpisodeToolName       = "get_episode"
+	ListSavedShowsToolName   = "list_saved_shows"
+	SaveShowsToolName        = "save_shows"
+	RemoveSavedShowsToolName = "remove_saved_shows"
+)
+
+// podcastScopes are the scopes the podcast tools require, unless
+// WithToolScopes declared others.
+var podcastScopes = map[string][]string{
+	ListSavedShowsToolName: {"user-library-read"},
+	// the library is read to only undo the shows a call changed
+	SaveShowsToolName:        {"user-library-read", "user-library-modify"},
+	RemoveSavedShowsToolName: {"user-library-read", "user-library-modify"},
+	// the resume point of an episode is only returned with this scope
+	GetEpisodeToolName: {"user-read-playback-position"},
+}
+
+// episodeFields are the fields kept of an episode by get_episode, which
+// unlike the lists includes the description and the resume point.
+var episodeFields = []string{
+	"name", "uri", "description", "release_date", "duration_ms", "explicit", "language",
+	"show.name", "show.uri", "show.publisher", "resume_point",
+}
+
+// savedShowFields are the fields kept of the saved shows.
+var savedShowFields = []string{"added_at", "show.name", "show.uri", "show.publisher", "show.total_episodes"}
+
+// spotifyID returns the ID of an item of kind given by its ID, its URI as
+// returned by the tools, i.e. spotify:show:38bS44xjbVVZ3No3ByF1dJ, or its
+// open.spotify.com URL.
+func spotifyID(kind, value string) string {
+	value = strings.TrimSpace(value)
+	if id, ok := strings.CutPrefix(value, "spotify:"+kind+":"); ok {
+		return id
+	}
+	if link, err := url.Parse(value); err == nil && link.Host == "open.spotify.com" {
+		if id, ok := strings.CutPrefix(link.Path, "/"+kind+"/"); ok {
+			return id
+		}
+	}
+	return value
+}
+
+func pagingInput(limit int) schema.Properties {
+	return schema.Properties{
+		"limit": schema.Integer(schema.Min(1), schema.Max(50), schema.Default(limit),
+			schema.Description("Items per page")),
+		"offset": schema.Integer(schema.Min(0), schema.Default(0),
+			schema.Description("Index of the first item, the offset of the next page is returned as nextOffset")),
+		"fields": fieldsInput,
+	}
+}
+
+// pagedResult is a page of a list tool.
+type pagedResult struct {
+	Items      []any `json:"items"`
+	Total      int   `json:"total"`
+	Offset     int   `json:"offset"`
+	NextOffset *int  `json:"nextOffset,omitempty"`
+}
+
+func newPagedResult(page itemPage) pagedResult {
+	result := pagedResult{Items: page.Items, Total: page.Total, Offset: page.Offset}
+	if next := page.Offset + len(page.Items); len(page.Items) > 0 && next < page.Total {
+		result.NextOffset = &next
+	}
+	return result
+}
+
+func jsonResult(value any) (*mcp.CallToolResult, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return mcp.NewToolResultText(string(data)), nil
+}
+
+func (s *Server) podcastTools() []server.ServerTool {
+	showInput := schema.String(schema.Length(1, -1),
+		schema.Description("ID, URI or open.spotify.com URL of the show"))
+	showsInput := schema.Array(schema.String(schema.Length(1, -1)), schema.Length(1, 50),
+		schema.Description("IDs, URIs or open.spotify.com URLs of the shows"))
+
+	episodesInput := pagingInput(20)
+	episodesInput["show"] = showInput
+	episodesInput["market"] = marketInput
+	savedInput := pagingInput(20)
+
+	return []server.ServerTool{
+		schema.NewTool(SearchShowsToolName, schema.Object(schema.Properties{
+			"query":  searchInput.Properties["query"],
+			"market": marketInput,
+			"limit":  searchInput.Properties["limit"],
+			"offset": searchInput.Properties["offset"],
+			"fields": fieldsInput,
+		}, schema.Required("query")), s.handleSearchShowsTool,
+			mcp.WithDescription("Searches the Spotify catalog for podcast shows"),
+			mcp.WithReadOnlyHintAnnotation(true),
+			mcp.WithDestructiveHintAnnotation(false),
+			mcp.WithOpenWorldHintAnnotation(true),
+		),
+		schema.NewTool(ListShowEpisodesToolName, schema.Object(episodesInput, schema.Required("show")), s.handleListShowEpisodesTool,
+			mcp.WithDescription("Lists the episodes of a podcast show, newest first"),
+			mcp.WithReadOnlyHintAnnotation(true),
+			mcp.WithDestructiveHintAnnotation(false),
+			mcp.WithOpenWorldHintAnnotation(true),
+		),
+		schema.NewTool(GetEpisodeToolName, schema.Object(schema.Properties{
+			"episode": schema.String(schema.Length(1, -1),
+				schema.Description("ID, URI or open.spotify.com URL of the episode")),
+			"market": marketInput,
+			"fields": fieldsInput,
+		}, schema.Required("episode")), s.handleGetEpisodeTool,
+			mcp.WithDescription("Gets the details of a podcast episode, with its description, show and resume point"),
+			mcp.WithReadOnlyHintAnnotation(true),
+			mcp.WithDestructiveHintAnnotation(false),
+			mcp.WithOpenWorldHintAnnotation(true),
+		),
+		schema.NewTool(ListSavedShowsToolName, schema.Object(savedInput), s.handleListSavedShowsTool,
+			mcp.WithDescription("Lists the podcast shows saved in the library of the user, most recently saved first"),
+			mcp.WithReadOnlyHintAnnotation(true),
+			mcp.WithDestructiveHintAnnotation(false),
+			mcp.WithOpenWorldHintAnnotation(true),
+		),
+		schema.NewTool(SaveShowsToolName, schema.Object(schema.Properties{
+			"shows": showsInput,
+		}, schema.Required("shows")), s.handleSaveShowsTool(http.MethodPut, "Saved"),
+			mcp.WithDescription("Saves podcast shows to the library of the user. Undoing it with "+compensation.ToolName+" removes the shows it saved"),
+			mcp.WithDestructiveHintAnnotation(false),
+			mcp.WithIdempotentHintAnnotation(true),
+			mcp.WithOpenWorldHintAnnotation(true),
+		),
+		schema.NewTool(RemoveSavedShowsToolName, schema.Object(schema.Properties{
+			"shows": showsInput,
+		}, schema.Required("shows")), s.handleSaveShowsTool(http.MethodDelete, "Removed"),
+			mcp.WithDescription("Removes podcast shows from the library of the user. Undoing it with "+compensation.ToolName+" saves the shows it removed again"),
+			mcp.WithDestructiveHintAnnotation(true),
+			mcp.WithIdempotentHintAnnotation(true),
+			mcp.WithOpenWorldHintAnnotation(true),
+		),
+	}
+}
+
+func (s *Server) handleSearchShowsTool(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	arguments := request.GetArguments()
+	arguments["types"] = []any{"show"}
+	request.Params.Arguments = arguments
+	return s.handleSearchTool(ctx, request)
+}
+
+func pagingQuery(request mcp.CallToolRequest) url.Values {
+	query := url.Values{
+		"limit":  {fmt.Sprint(request.GetInt("limit", 20))},
+		"offset": {fmt.Sprint(request.GetInt("offset", 0))},
+	}
+	if market := request.GetString("market", ""); market != "" {
+		query.Set("market", market)
+	}
+	return query
+}
+
+func (s *Server) handleListShowEpisodesTool(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	show := spotifyID("show", request.GetString("show", ""))
+	var page itemPage
+	path := "/shows/" + url.PathEscape(show) + "/episodes?" + pagingQuery(request).Encode()
+	if err := s.api(ctx, http.MethodGet, path, &page); err != nil {
+		return nil, err
+	}
+	trimItems(page.Items, request.GetStringSlice("fields", nil), searchFields["episode"])
+	return jsonResult(newPagedResult(page))
+}
+
+func (s *Server) handleGetEpisodeTool(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	episode := spotifyID("episode", request.GetString("episode", ""))
+	query := url.Values{}
+	if market := request.GetString("market", ""); market != "" {
+		query.Set("market", market)
+	}
+	path := "/episodes/" + url.PathEscape(episode)
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	var details any
+	if err := s.api(ctx, http.MethodGet, path, &details); err != nil {
+		return nil, err
+	}
+	items := []any{details}
+	trimItems(items, request.GetStringSlice("fields", nil), episodeFields)
+	return jsonResult(items[0])
+}
+
+func (s *Server) handleListSavedShowsTool(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var page itemPage
+	if err := s.api(ctx, http.MethodGet, "/me/shows?"+pagingQuery(request).Encode(), &page); err != nil {
+		return nil, err
+	}
+	trimItems(page.Items, request.GetStringSlice("fields", nil), savedShowFields)
+	return jsonResult(newPagedResult(page))
+}
+
+// handleSaveShowsTool saves the shows with PUT, or removes them with DELETE,
+// and registers the compensation doing the opposite for the shows it changed.
+func (s *Server) handleSaveShowsTool(method, done string) server.ToolHandlerFunc {
+	undoMethod := http.MethodDelete
+	if method == http.MethodDelete {
+		undoMethod = http.MethodPut
+	}
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		shows := request.GetStringSlice("shows", nil)
+		ids := make([]string, len(shows))
+		for i, show := range shows {
+			ids[i] = spotifyID("show", show)
+		}
+		query := url.Values{"ids": {strings.Join(ids, ",")}}
+		var saved []bool
+		if err := s.api(ctx, http.MethodGet, "/me/shows/contains?"+query.Encode(), &saved); err != nil {
+			return nil, err
+		}
+		if err := s.api(ctx, method, "/me/shows?"+query.Encode(), nil); err != nil {
+			return nil, err
+		}
+
+		// the shows already saved, or already removed, are left alone on undo
+		var changed []string
+		for i, id := range ids {
+			if i < len(saved) && saved[i] == (method == http.MethodDelete) {
+				changed = append(changed, id)
+			}
+		}
+		if len(changed) > 0 {
+			undoQuery := url.Values{"ids": {strings.Join(changed, ",")}}
+			s.undo.Push(ctx, fmt.Sprintf("%s %d shows", strings.ToLower(done), len(changed)), func(ctx context.Context) error {
+				return s.api(ctx, undoMethod, "/me/shows?"+undoQuery.Encode(), nil)
+			})
+		}
+		return mcp.NewToolResultText(fmt.Sprintf("%s %d shows", done, len(ids))), nil
+	}
+}
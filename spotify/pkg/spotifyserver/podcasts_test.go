@@ -0,0 +1,189 @@
+package spotifyserver
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/wagnerjt/go-mcp/shared/pkg/compensation"
+)
+
+func TestSpotifyID(t *testing.T) {
+	for _, test := range []struct {
+		kind, value, want string
+	}{
+		{"show", "38bS44xjbVVZ3No3ByF1dJ", "38bS44xjbVVZ3No3ByF1dJ"},
+		{"show", " spotify:show:38bS44xjbVVZ3No3ByF1dJ ", "38bS44xjbVVZ3No3ByF1dJ"},
+		{"show", "https://open.spotify.com/show/38bS44xjbVVZ3No3ByF1dJ?si=abc", "38bS44xjbVVZ3No3ByF1dJ"},
+		{"episode", "spotify:episode:512ojhOuo1ktJprKbVcKyQ", "512ojhOuo1ktJprKbVcKyQ"},
+		// URIs and URLs of other kinds are left alone
+		{"show", "spotify:episode:512ojhOuo1ktJprKbVcKyQ", "spotify:episode:512ojhOuo1ktJprKbVcKyQ"},
+		{"show", "https://open.spotify.com/episode/512ojhOuo1ktJprKbVcKyQ", "https://open.spotify.com/episode/512ojhOuo1ktJprKbVcKyQ"},
+		{"show", "https://example.com/show/38bS44xjbVVZ3No3ByF1dJ", "https://example.com/show/38bS44xjbVVZ3No3ByF1dJ"},
+	} {
+		if got := spotifyID(test.kind, test.value); got != test.want {
+			t.Errorf("spotifyID(%q, %q) = %q, want %q", test.kind, test.value, got, test.want)
+		}
+	}
+}
+
+func TestPodcastTools(t *testing.T) {
+	api := newSpotifyAPI()
+	api.handle("GET /search", `{"shows": {"items": [
+		{"name": "Darknet Diaries", "uri": "spotify:show:d", "publisher": "Jack Rhysider", "total_episodes": 150, "images": []}
+	], "total": 1, "offset": 0}}`)
+	api.handle("GET /shows/d/episodes", `{"items": [
+		{"name": "Episode 150", "uri": "spotify:episode:e150", "release_date": "2024-10-01", "duration_ms": 3600000, "description": "long"},
+		{"name": "Episode 149", "uri": "spotify:episode:e149", "release_date": "2024-09-01", "duration_ms": 3500000, "description": "long"}
+	], "total": 150, "offset": 4}`)
+	api.handle("GET /episodes/e150", `{"name": "Episode 150", "uri": "spotify:episode:e150", "description": "About", "images": [],
+		"show": {"name": "Darknet Diaries", "uri": "spotify:show:d", "publisher": "Jack Rhysider", "images": []},
+		"resume_point": {"fully_played": false, "resume_position_ms": 1000}}`)
+	api.handle("GET /me/shows", `{"items": [
+		{"added_at": "2024-01-01T00:00:00Z", "show": {"name": "Darknet Diaries", "uri": "spotify:show:d", "publisher": "Jack Rhysider", "total_episodes": 150, "images": []}}
+	], "total": 1, "offset": 0}`)
+	s := newAPITestServer(t, api)
+
+	for _, test := range []struct {
+		tool        string
+		arguments   map[string]any
+		want        string
+		wantRequest string
+	}{
+		{
+			tool:        SearchShowsToolName,
+			arguments:   map[string]any{"query": "darknet", "market": "US"},
+			want:        `{"shows": {"items": [{"name": "Darknet Diaries", "uri": "spotify:show:d", "publisher": "Jack Rhysider", "total_episodes": 150}], "total": 1, "offset": 0}}`,
+			wantRequest: "GET /search?limit=10&market=US&offset=0&q=darknet&type=show",
+		},
+		{
+			tool:      ListShowEpisodesToolName,
+			arguments: map[string]any{"show": "https://open.spotify.com/show/d", "limit": 2, "offset": 4},
+			want: `{"items": [
+				{"name": "Episode 150", "uri": "spotify:episode:e150", "release_date": "2024-10-01", "duration_ms": 3600000},
+				{"name": "Episode 149", "uri": "spotify:episode:e149", "release_date": "2024-09-01", "duration_ms": 3500000}
+			], "total": 150, "offset": 4, "nextOffset": 6}`,
+			wantRequest: "GET /shows/d/episodes?limit=2&offset=4",
+		},
+		{
+			tool:      GetEpisodeToolName,
+			arguments: map[string]any{"episode": "spotify:episode:e150", "market": "from_token"},
+			want: `{"name": "Episode 150", "uri": "spotify:episode:e150", "description": "About",
+				"show": {"name": "Darknet Diaries", "uri": "spotify:show:d", "publisher": "Jack Rhysider"},
+				"resume_point": {"fully_played": false, "resume_position_ms": 1000}}`,
+			wantRequest: "GET /episodes/e150?market=from_token",
+		},
+		{
+			tool:        GetEpisodeToolName,
+			arguments:   map[string]any{"episode": "e150", "fields": []string{"resume_point.resume_position_ms"}},
+			want:        `{"resume_point": {"resume_position_ms": 1000}}`,
+			wantRequest: "GET /episodes/e150",
+		},
+		{
+			tool:      ListSavedShowsToolName,
+			arguments: map[string]any{},
+			want: `{"items": [{"added_at": "2024-01-01T00:00:00Z",
+				"show": {"name": "Darknet Diaries", "uri": "spotify:show:d", "publisher": "Jack Rhysider", "total_episodes": 150}}], "total": 1, "offset": 0}`,
+			wantRequest: "GET /me/shows?limit=20&offset=0",
+		},
+	} {
+		text, errMessage := callTool(t, context.Background(), s, test.tool, test.arguments)
+		if errMessage != "" {
+			t.Errorf("%s: %s", test.tool, errMessage)
+			continue
+		}
+		assertJSON(t, text, test.want)
+		if requests := api.takeRequests(); !slices.Equal(requests, []string{test.wantRequest}) {
+			t.Errorf("%s: requests %q, want %q", test.tool, requests, test.wantRequest)
+		}
+	}
+}
+
+// TestSaveShowsUndo checks that saving and removing shows register the
+// compensation for the shows they changed only.
+func TestSaveShowsUndo(t *testing.T) {
+	api := newSpotifyAPI()
+	// a is saved, b is not
+	api.handle("GET /me/shows/contains", `[true, false]`)
+	api.handle("PUT /me/shows", ``)
+	api.handle("DELETE /me/shows", ``)
+	s := newAPITestServer(t, api)
+	ctx := context.Background()
+
+	for _, test := range []struct {
+		tool         string
+		want         string
+		wantRequest  string
+		wantUndo     string
+		wantUndoText string
+	}{
+		{SaveShowsToolName, "Saved 2 shows", "PUT /me/shows?ids=a%2Cb", "DELETE /me/shows?ids=b", "Undone: saved 1 shows"},
+		{RemoveSavedShowsToolName, "Removed 2 shows", "DELETE /me/shows?ids=a%2Cb", "PUT /me/shows?ids=a", "Undone: removed 1 shows"},
+	} {
+		text, errMessage := callTool(t, ctx, s, test.tool, map[string]any{"shows": []string{"spotify:show:a", "https://open.spotify.com/show/b"}})
+		if text != test.want {
+			t.Fatalf("%s: got %q, %q, want %q", test.tool, text, errMessage, test.want)
+		}
+		want := []string{"GET /me/shows/contains?ids=a%2Cb", test.wantRequest}
+		if requests := api.takeRequests(); !slices.Equal(requests, want) {
+			t.Errorf("%s: requests %q, want %q", test.tool, requests, want)
+		}
+		if text, _ := callTool(t, ctx, s, compensation.ToolName, nil); text != test.wantUndoText {
+			t.Errorf("%s: undo returned %q, want %q", test.tool, text, test.wantUndoText)
+		}
+		if requests := api.takeRequests(); !slices.Equal(requests, []string{test.wantUndo}) {
+			t.Errorf("%s: undo requests %q, want %q", test.tool, requests, test.wantUndo)
+		}
+	}
+
+	// nothing changed, nothing to undo
+	api = newSpotifyAPI()
+	api.handle("GET /me/shows/contains", `[true]`)
+	api.handle("PUT /me/shows", ``)
+	s = newAPITestServer(t, api)
+	callTool(t, ctx, s, SaveShowsToolName, map[string]any{"shows": []string{"a"}})
+	if text, _ := callTool(t, ctx, s, compensation.ToolName, nil); text != "Nothing to undo in this session" {
+		t.Errorf("undo of saving a saved show returned %q", text)
+	}
+}
+
+func TestPodcastToolErrors(t *testing.T) {
+	api := newSpotifyAPI()
+	api.mux.HandleFunc("GET /shows/missing/episodes", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": {"status": 404, "message": "Non existing id"}}`))
+	})
+	api.mux.HandleFunc("GET /me/shows/contains", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"error": {"status": 403, "message": "Insufficient client scope"}}`))
+	})
+	s := newAPITestServer(t, api)
+
+	for _, test := range []struct {
+		tool      string
+		arguments map[string]any
+		wantErr   string
+		wantCalls int
+	}{
+		{ListShowEpisodesToolName, map[string]any{}, "show", 0},
+		{ListShowEpisodesToolName, map[string]any{"show": "d", "limit": 51}, "limit", 0},
+		{GetEpisodeToolName, map[string]any{"episode": ""}, "episode", 0},
+		{SaveShowsToolName, map[string]any{"shows": []string{}}, "shows", 0},
+		{ListShowEpisodesToolName, map[string]any{"show": "missing"}, "status code 404: Non existing id", 1},
+		// without the library scope nothing is saved
+		{SaveShowsToolName, map[string]any{"shows": []string{"a"}}, "status code 403: Insufficient client scope", 1},
+	} {
+		text, errMessage := callTool(t, context.Background(), s, test.tool, test.arguments)
+		if !strings.Contains(errMessage, test.wantErr) {
+			t.Errorf("%s %v: got %q, %q, want an error containing %q", test.tool, test.arguments, text, errMessage, test.wantErr)
+		}
+		if requests := api.takeRequests(); len(requests) != test.wantCalls {
+			t.Errorf("%s %v: requests %q, want %d", test.tool, test.arguments, requests, test.wantCalls)
+		}
+	}
+	if text, _ := callTool(t, context.Background(), s, compensation.ToolName, nil); text != "Nothing to undo in this session" {
+		t.Errorf("undo after a failed save returned %q", text)
+	}
+}
@@ -0,0 +1,107 @@
+package spotifyserver
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+const (
+	// CallbackPath is the default path of the OAuth redirect URI.
+	CallbackPath = "/auth/callback"
+
+	ForwardedProtoHeader = "X-Forwarded-Proto"
+	ForwardedHostHeader  = "X-Forwarded-Host"
+)
+
+type baseURLKey struct{}
+
+// WithExternalURL sets the public base URL of the server, i.e.
+// https://spotify-mcp.example.com behind a TLS-terminating proxy. The
+// redirect URI and the advertised metadata URLs are built from it, otherwise
+// from the URL each request was made to.
+func WithExternalURL(externalURL string) Option {
+	return func(s *Server) {
+		s.externalURL = strings.TrimSuffix(externalURL, "/")
+	}
+}
+
+// WithCallbackPath serves the OAuth redirect URI on path instead of
+// CallbackPath.
+func WithCallbackPath(path string) Option {
+	return func(s *Server) {
+		s.callbackPath = path
+	}
+}
+
+// WithForwardedHeaders trusts the X-Forwarded-Proto and X-Forwarded-Host
+// headers of the proxy in front of the server when building URLs from
+// requests. Only enable it when every request goes through that proxy.
+func WithForwardedHeaders(trusted bool) Option {
+	return func(s *Server) {
+		s.trustForwarded = trusted
+	}
+}
+
+// baseURL is the external URL, or the scheme and host r was made to.
+func (s *Server) baseURL(r *http.Request) string {
+	if s.externalURL != "" {
+		return s.externalURL
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	host := r.Host
+	if s.trustForwarded {
+		if proto := lastForwardedValue(r.Header.Values(ForwardedProtoHeader)); proto == "http" || proto == "https" {
+			scheme = proto
+		}
+		if forwardedHost := lastForwardedValue(r.Header.Values(ForwardedHostHeader)); validForwardedHost(forwardedHost) {
+			host = forwardedHost
+		}
+	}
+	return scheme + "://" + host
+}
+
+// lastForwardedValue is the value set by the trusted proxy next to the
+// server. Proxies append to the headers, so the values before it were sent
+// by the client or other hops and may be spoofed.
+func lastForwardedValue(headers []string) string {
+	if len(headers) == 0 {
+		return ""
+	}
+	header := headers[len(headers)-1]
+	if i := strings.LastIndexByte(header, ','); i >= 0 {
+		header = header[i+1:]
+	}
+	return strings.TrimSpace(header)
+}
+
+// validForwardedHost reports whether host is a bare host and port, which
+// cannot change the path or the user info of the URLs built from it.
+func validForwardedHost(host string) bool {
+	return host != "" && !strings.ContainsAny(host, "/\\@?# ")
+}
+
+// redirectURI is the configured redirect URL, or the callback path on base.
+func (s *Server) redirectURI(base string) string {
+	if s.redirectURL != "" {
+		return s.redirectURL
+	}
+	return base + s.callbackPath
+}
+
+// baseURLMiddleware makes the base URL of the request available to the
+// handlers and the tools.
+func (s *Server) baseURLMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), baseURLKey{}, s.baseURL(r))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func baseURLFromContext(ctx context.Context) string {
+	base, _ := ctx.Value(baseURLKey{}).(string)
+	return base
+}
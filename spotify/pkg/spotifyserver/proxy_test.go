@@ -0,0 +1,30 @@
+package spotifyserver
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+// TestBaseURLForwarded checks that only the forwarded values of the proxy
+// next to the server are used, and only when they cannot alter the URL.
+func TestBaseURLForwarded(t *testing.T) {
+	s := &Server{trustForwarded: true}
+	for _, test := range []struct {
+		proto, host []string
+		want        string
+	}{
+		{nil, nil, "http://internal:8080"},
+		{[]string{"https"}, []string{"mcp.example.com"}, "https://mcp.example.com"},
+		{[]string{"http, https"}, []string{"evil.example, mcp.example.com"}, "https://mcp.example.com"},
+		{[]string{"http"}, []string{"evil.example", "mcp.example.com"}, "http://mcp.example.com"},
+		{[]string{"javascript"}, []string{"evil.example/path"}, "http://internal:8080"},
+		{nil, []string{"user@evil.example"}, "http://internal:8080"},
+	} {
+		r := httptest.NewRequest("GET", "http://internal:8080/auth/spotify/login", nil)
+		r.Header[ForwardedProtoHeader] = test.proto
+		r.Header[ForwardedHostHeader] = test.host
+		if got := s.baseURL(r); got != test.want {
+			t.Errorf("baseURL with %q and %q = %q, want %q", test.proto, test.host, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package spotifyserver
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// CredentialsLoader returns the Spotify client ID and secret, it is called
+// again on Reload so rotated secrets are picked up.
+type CredentialsLoader func() (clientID, clientSecret string, err error)
+
+// LookupConfig resolves a config value from the environment variable key,
+// then from the file named by key_FILE, then from the file key in dir, which
+// is how Kubernetes projected secrets are usually mounted.
+func LookupConfig(key, dir string) (string, bool) {
+	if value, ok := os.LookupEnv(key); ok {
+		return value, true
+	}
+	if path, ok := os.LookupEnv(key + "_FILE"); ok {
+		if data, err := os.ReadFile(path); err == nil {
+			return strings.TrimSpace(string(data)), true
+		}
+	}
+	if dir != "" {
+		if data, err := os.ReadFile(filepath.Join(dir, key)); err == nil {
+			return strings.TrimSpace(string(data)), true
+		}
+	}
+	return "", false
+}
+
+// EnvCredentials loads SPOTIFY_CLIENT_ID and SPOTIFY_CLIENT_SECRET through
+// LookupConfig.
+func EnvCredentials(dir string) CredentialsLoader {
+	return func() (string, string, error) {
+		clientID, ok := LookupConfig("SPOTIFY_CLIENT_ID", dir)
+		if !ok {
+			return "", "", fmt.Errorf("SPOTIFY_CLIENT_ID not set")
+		}
+		clientSecret, ok := LookupConfig("SPOTIFY_CLIENT_SECRET", dir)
+		if !ok {
+			return "", "", fmt.Errorf("SPOTIFY_CLIENT_SECRET not set")
+		}
+		return clientID, clientSecret, nil
+	}
+}
+
+// WithCredentialsLoader loads the client credentials through loader.
+func WithCredentialsLoader(loader CredentialsLoader) Option {
+	return func(s *Server) {
+		s.credentialsLoader = loader
+	}
+}
+
+func (s *Server) loadCredentials() error {
+	if s.credentialsLoader == nil {
+		return nil
+	}
+	clientID, clientSecret, err := s.credentialsLoader()
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.clientID = clientID
+	s.clientSecret = clientSecret
+	s.mu.Unlock()
+	return nil
+}
+
+// wellKnownTimeout bounds a fetch of the Spotify well-known config.
+const wellKnownTimeout = 10 * time.Second
+
+func (s *Server) fetchWellKnownConfig(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, wellKnownTimeout)
+	defer cancel()
+	config, err := GetResponseBodyBytes(ctx, SpotifyWellKnownURL)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.wellKnownConfig = config
+	s.mu.Unlock()
+	return nil
+}
+
+// Reload re-reads the client credentials and the proxied Spotify well-known
+// config, unless it was set by WithWellKnownConfig, keeping the current
+// values when anything fails.
+func (s *Server) Reload() error {
+	if err := s.loadCredentials(); err != nil {
+		return fmt.Errorf("failed to reload credentials: %w", err)
+	}
+	// SIGHUP has no caller to wait on, the fetch is bounded by its timeout
+	if !s.wellKnownFixed {
+		if err := s.fetchWellKnownConfig(context.Background()); err != nil {
+			return fmt.Errorf("failed to reload well-known config: %w", err)
+		}
+	}
+	log.Printf("Configuration reloaded")
+	return nil
+}
+
+// Ready reports whether the Spotify upstream was verified.
+func (s *Server) Ready() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.wellKnownConfig != nil
+}
+
+// AwaitReadiness fetches the Spotify well-known config until it succeeds,
+// the server reports not ready on /readyz until then.
+func (s *Server) AwaitReadiness(ctx context.Context) {
+	backoff := time.Second
+	for !s.Ready() {
+		if err := s.fetchWellKnownConfig(ctx); err != nil {
+			log.Printf("Spotify upstream not ready: %v", err)
+		} else {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if !s.Ready() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"status":"NOT_READY"}`))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"status":"READY"}`))
+}
@@ -0,0 +1,183 @@
+package spotifyserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/wagnerjt/go-mcp/shared/pkg/oauthflow"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// ScopesToolName is the tool reporting the granted and required scopes.
+	ScopesToolName = "spotify_scopes"
+	// ConsentPath re-runs the authorization for the missing scopes only.
+	ConsentPath = "/auth/spotify/consent"
+)
+
+// WithToolScopes declares the Spotify scopes the tool named name requires,
+// which are compared with the ones granted to the stored token.
+func WithToolScopes(name string, scopes ...string) Option {
+	return func(s *Server) {
+		if s.toolScopes == nil {
+			s.toolScopes = make(map[string][]string)
+		}
+		s.toolScopes[name] = scopes
+	}
+}
+
+// addToolScopes declares the scopes of a built-in tool, unless
+// WithToolScopes declared others.
+func (s *Server) addToolScopes(name string, scopes ...string) {
+	if _, ok := s.toolScopes[name]; ok {
+		return
+	}
+	if s.toolScopes == nil {
+		s.toolScopes = make(map[string][]string)
+	}
+	s.toolScopes[name] = scopes
+}
+
+// ScopeReport compares the scopes of the stored token with the ones the
+// enabled tools require.
+type ScopeReport struct {
+	Authenticated bool         `json:"authenticated"`
+	Granted       []string     `json:"granted"`
+	Tools         []ToolScopes `json:"tools,omitempty"`
+	Missing       []string     `json:"missing"`
+	ConsentURL    string       `json:"consentUrl,omitempty"`
+}
+
+// ToolScopes are the scopes required by one tool.
+type ToolScopes struct {
+	Name     string   `json:"name"`
+	Required []string `json:"required"`
+	Missing  []string `json:"missing,omitempty"`
+}
+
+// Scopes reports the scopes of the stored token against the ones required
+// by the enabled tools.
+func (s *Server) Scopes(ctx context.Context) (ScopeReport, error) {
+	tools, err := s.listTools(ctx)
+	if err != nil {
+		return ScopeReport{}, err
+	}
+
+	s.mu.RLock()
+	report := ScopeReport{
+		Authenticated: s.token != nil,
+		Granted:       slices.Clone(s.grantedScopes),
+	}
+	required := make(map[string][]string, len(s.toolScopes))
+	for name, scopes := range s.toolScopes {
+		required[name] = scopes
+	}
+	s.mu.RUnlock()
+
+	missing := make(map[string]bool)
+	for _, tool := range tools {
+		scopes, ok := required[tool.Name]
+		if !ok || len(scopes) == 0 {
+			continue
+		}
+		entry := ToolScopes{Name: tool.Name, Required: scopes}
+		for _, scope := range scopes {
+			if !slices.Contains(report.Granted, scope) {
+				entry.Missing = append(entry.Missing, scope)
+				missing[scope] = true
+			}
+		}
+		report.Tools = append(report.Tools, entry)
+	}
+	sort.Slice(report.Tools, func(i, j int) bool { return report.Tools[i].Name < report.Tools[j].Name })
+
+	report.Missing = make([]string, 0, len(missing))
+	for scope := range missing {
+		report.Missing = append(report.Missing, scope)
+	}
+	sort.Strings(report.Missing)
+	if len(report.Missing) > 0 || !report.Authenticated {
+		report.ConsentURL = baseURLFromContext(ctx) + ConsentPath
+	}
+	return report, nil
+}
+
+// storeToken stores the token of a finished login and returns its scopes,
+// as reported by the token endpoint or else the requested ones.
+func (s *Server) storeToken(token *oauth2.Token, login oauthflow.Login) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	granted := login.Scopes
+	if login.Incremental {
+		granted = unionScopes(s.grantedScopes, login.Scopes)
+	}
+	// the reported scope is authoritative, providers that do not honor
+	// include_granted_scopes only report the newly requested ones
+	if scope, ok := token.Extra("scope").(string); ok && scope != "" {
+		granted = strings.Fields(scope)
+	}
+	s.token = token
+	s.grantedScopes = granted
+	s.loginUser = ""
+	s.verified = nil
+	s.refreshFailure = nil
+	if err := s.saveToken(); err != nil {
+		log.Printf("Failed to persist the token: %v", err)
+	}
+	return granted, nil
+}
+
+func unionScopes(a, b []string) []string {
+	union := slices.Clone(a)
+	for _, scope := range b {
+		if !slices.Contains(union, scope) {
+			union = append(union, scope)
+		}
+	}
+	return union
+}
+
+func (s *Server) handleScopesTool(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	report, err := s.Scopes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(report)
+	if err != nil {
+		return nil, err
+	}
+	return mcp.NewToolResultText(string(data)), nil
+}
+
+// Handler to re-run the PKCE OAuth flow for the scopes the stored token is
+// missing, or the full login when there is no token yet
+func (s *Server) handleSpotifyConsent(w http.ResponseWriter, r *http.Request) {
+	report, err := s.Scopes(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to compare scopes: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if report.Authenticated && len(report.Missing) == 0 {
+		textResponse(w, http.StatusOK, `{"status":"no missing scopes"}`)
+		return
+	}
+
+	scopes := report.Missing
+	if !report.Authenticated {
+		scopes = unionScopes(s.scopes, report.Missing)
+	}
+	authURL, _, err := s.auth.Start(s.redirectURI(s.baseURL(r)), scopes, report.Authenticated)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
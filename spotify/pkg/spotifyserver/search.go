@@ -0,0 +1,264 @@
+package spotifyserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/wagnerjt/go-mcp/shared/pkg/upstream"
+	"github.com/wagnerjt/go-mcp/spotify/pkg/schema"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// SpotifyAPIURL is the base URL of the Spotify Web API.
+	SpotifyAPIURL = "https://api.spotify.com/v1"
+	// SearchToolName is the name of the search tool.
+	SearchToolName = "search_spotify"
+)
+
+// searchTypes are the item types of the Spotify search, in the order of the
+// results.
+var searchTypes = []string{"track", "album", "artist", "playlist", "show", "episode"}
+
+// searchFields are the fields kept of the items of each type when the call
+// names none, enough to tell the items apart and to pass their URIs on.
+var searchFields = map[string][]string{
+	"track":    {"name", "uri", "artists.name", "album.name", "duration_ms", "explicit"},
+	"album":    {"name", "uri", "artists.name", "release_date", "total_tracks"},
+	"artist":   {"name", "uri", "genres", "followers.total"},
+	"playlist": {"name", "uri", "description", "owner.display_name", "tracks.total"},
+	"show":     {"name", "uri", "publisher", "total_episodes"},
+	"episode":  {"name", "uri", "release_date", "duration_ms"},
+}
+
+// WithHTTPClient sets the client of the Spotify API and token endpoint
+// calls, i.e. one recording them with the vcr package.
+func WithHTTPClient(client *http.Client) Option {
+	return func(s *Server) {
+		s.httpClient = client
+	}
+}
+
+// WithAPIURL overrides the Spotify Web API URL, i.e. for a test server.
+func WithAPIURL(apiURL string) Option {
+	return func(s *Server) {
+		s.apiURL = strings.TrimSuffix(apiURL, "/")
+	}
+}
+
+// marketInput and fieldsInput are the arguments shared by the tools reading
+// the catalog.
+var (
+	marketInput = schema.String(
+		schema.Description("ISO 3166-1 alpha-2 country code of the catalog, or from_token for the country of the logged in user"),
+		schema.Examples("US", "from_token"))
+	fieldsInput = schema.Array(schema.String(schema.Length(1, -1)),
+		schema.Description("Fields to keep of each item, dotted paths that reach into nested objects and arrays, i.e. artists.name; * keeps the items whole. Defaults to a concise set per type"),
+		schema.Examples([]string{"name", "uri", "album.release_date"}))
+)
+
+var searchInput = schema.Object(schema.Properties{
+	"query": schema.String(schema.Length(1, -1),
+		schema.Description("Search query, with the field filters of Spotify, i.e. artist:radiohead year:1997")),
+	"types": schema.Array(schema.String(schema.Enum(searchTypes...)), schema.Length(1, -1),
+		schema.Description("Item types to search"), schema.Default([]string{"track"})),
+	"market": marketInput,
+	"limit": schema.Integer(schema.Min(1), schema.Max(50), schema.Default(10),
+		schema.Description("Items per type")),
+	"offset": schema.Integer(schema.Min(0), schema.Max(1000), schema.Default(0),
+		schema.Description("Index of the first item per type")),
+	"fields": fieldsInput,
+}, schema.Required("query"))
+
+// SearchResultSchema is the schema of the results of the search tool: a
+// page per searched type, under the plural of the type, i.e. tracks. The
+// items are trimmed to the requested fields. A page has a nextOffset while
+// there are more items, to pass as the offset of the next call. mcp-go
+// cannot declare the output schema of a tool, so its description states the
+// shape.
+var SearchResultSchema = schema.Object(searchResultPages(), schema.Description("Search results by type"))
+
+func searchResultPages() schema.Properties {
+	pages := make(schema.Properties, len(searchTypes))
+	for _, kind := range searchTypes {
+		pages[kind+"s"] = schema.Object(schema.Properties{
+			"items": schema.Array(schema.Object(nil, schema.Open()),
+				schema.Description("Items trimmed to the requested fields")),
+			"total":  schema.Integer(schema.Min(0), schema.Description("Items matching the query")),
+			"offset": schema.Integer(schema.Min(0), schema.Description("Index of the first item")),
+			"nextOffset": schema.Integer(schema.Min(1),
+				schema.Description("Offset of the next page, absent on the last page")),
+		}, schema.Required("items", "total", "offset"))
+	}
+	return pages
+}
+
+func (s *Server) searchTool() server.ServerTool {
+	return schema.NewTool(SearchToolName, searchInput, s.handleSearchTool,
+		mcp.WithDescription("Searches the Spotify catalog for tracks, albums, artists, playlists, shows and episodes, returning only the requested fields of each item. "+
+			"Returns a page per type under its plural, i.e. {\"tracks\": {\"items\": [...], \"total\": 120, \"offset\": 0, \"nextOffset\": 10}}; "+
+			"call again with offset set to nextOffset for the next page, nextOffset is absent on the last page"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithOpenWorldHintAnnotation(true),
+	)
+}
+
+// itemPage is a page of items of the Spotify API.
+type itemPage struct {
+	Items  []any `json:"items"`
+	Total  int   `json:"total"`
+	Offset int   `json:"offset"`
+}
+
+func (s *Server) handleSearchTool(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	types := request.GetStringSlice("types", nil)
+	query := url.Values{
+		"q":      {request.GetString("query", "")},
+		"type":   {strings.Join(types, ",")},
+		"limit":  {fmt.Sprint(request.GetInt("limit", 10))},
+		"offset": {fmt.Sprint(request.GetInt("offset", 0))},
+	}
+	if market := request.GetString("market", ""); market != "" {
+		query.Set("market", market)
+	}
+
+	var response map[string]itemPage
+	if err := s.api(ctx, http.MethodGet, "/search?"+query.Encode(), &response); err != nil {
+		return nil, err
+	}
+
+	fields := request.GetStringSlice("fields", nil)
+	results := make(map[string]pagedResult, len(types))
+	for _, kind := range types {
+		page, ok := response[kind+"s"]
+		if !ok {
+			continue
+		}
+		result := newPagedResult(page)
+		// Spotify lists items that are no longer available as null
+		result.Items = slices.DeleteFunc(result.Items, func(item any) bool { return item == nil })
+		trimItems(result.Items, fields, searchFields[kind])
+		results[kind+"s"] = result
+	}
+	return jsonResult(results)
+}
+
+// trimItems trims items to fields, or to defaults when the call named none,
+// unless the fields include *.
+func trimItems(items []any, fields, defaults []string) {
+	if len(fields) == 0 {
+		fields = defaults
+	}
+	if slices.Contains(fields, "*") {
+		return
+	}
+	paths := splitPaths(fields)
+	for i, item := range items {
+		items[i] = trimFields(item, paths)
+	}
+}
+
+func splitPaths(paths []string) [][]string {
+	split := make([][]string, len(paths))
+	for i, path := range paths {
+		split[i] = strings.Split(path, ".")
+	}
+	return split
+}
+
+// trimFields keeps the fields of value named by paths. A path ending at a
+// field keeps it whole, a longer one keeps the named fields of the nested
+// object, or of each object of a nested array.
+func trimFields(value any, paths [][]string) any {
+	switch value := value.(type) {
+	case []any:
+		trimmed := make([]any, len(value))
+		for i, item := range value {
+			trimmed[i] = trimFields(item, paths)
+		}
+		return trimmed
+	case map[string]any:
+		nested := make(map[string][][]string)
+		whole := make(map[string]bool)
+		for _, path := range paths {
+			if len(path) == 1 {
+				whole[path[0]] = true
+			} else {
+				nested[path[0]] = append(nested[path[0]], path[1:])
+			}
+		}
+		trimmed := make(map[string]any)
+		for name, field := range value {
+			switch {
+			case whole[name]:
+				trimmed[name] = field
+			case nested[name] != nil:
+				trimmed[name] = trimFields(field, nested[name])
+			}
+		}
+		return trimmed
+	}
+	return value
+}
+
+// api calls the Spotify Web API with the stored token, refreshed when
+// expired, and decodes the response into out, unless out is nil. A token
+// Spotify rejects before its expiry is refreshed once and the call retried.
+func (s *Server) api(ctx context.Context, method, path string, out any) error {
+	token, err := s.TokenSource(ctx).Token()
+	if err != nil {
+		return err
+	}
+	resp, err := s.apiRequest(ctx, method, path, token)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusUnauthorized && token.RefreshToken != "" {
+		resp.Body.Close()
+		if token, err = s.refreshToken(ctx, token); err != nil {
+			return err
+		}
+		if resp, err = s.apiRequest(ctx, method, path, token); err != nil {
+			return err
+		}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&apiErr)
+		return fmt.Errorf("spotify %s %s: status code %d: %s", method, path, resp.StatusCode, apiErr.Error.Message)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("spotify %s %s: %w", method, path, upstream.Error(ctx, err))
+	}
+	return nil
+}
+
+func (s *Server) apiRequest(ctx context.Context, method, path string, token *oauth2.Token) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.apiURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	token.SetAuthHeader(req)
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("spotify %s %s: %w", method, path, upstream.Error(ctx, err))
+	}
+	return resp, nil
+}
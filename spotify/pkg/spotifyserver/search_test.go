@@ -0,0 +1,249 @@
+package spotifyserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/wagnerjt/go-mcp/spotify/pkg/tokenstore"
+	"golang.org/x/oauth2"
+)
+
+// spotifyAPI is a fake Spotify Web API answering with the handlers of its
+// routes, "METHOD /path" as for http.ServeMux, and recording the requests.
+type spotifyAPI struct {
+	mux *http.ServeMux
+
+	mu       sync.Mutex
+	requests []string
+}
+
+func newSpotifyAPI() *spotifyAPI {
+	return &spotifyAPI{mux: http.NewServeMux()}
+}
+
+func (a *spotifyAPI) handle(pattern, response string) {
+	a.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(response))
+	})
+}
+
+func (a *spotifyAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	a.mu.Lock()
+	a.requests = append(a.requests, r.Method+" "+r.URL.RequestURI())
+	a.mu.Unlock()
+	a.mux.ServeHTTP(w, r)
+}
+
+// takeRequests returns the requests since its last call.
+func (a *spotifyAPI) takeRequests() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	requests := a.requests
+	a.requests = nil
+	return requests
+}
+
+// newAPITestServer returns a server logged in with the token "token" that
+// calls api instead of Spotify.
+func newAPITestServer(t *testing.T, api http.Handler, opts ...Option) *Server {
+	t.Helper()
+	ts := httptest.NewServer(api)
+	t.Cleanup(ts.Close)
+	store := tokenstore.NewMemory()
+	store.Put(context.Background(), TokenKey, tokenstore.Token{Token: &oauth2.Token{AccessToken: "token"}})
+	s, err := New(append([]Option{
+		WithClientCredentials("id", "secret"),
+		WithTokenStore(store),
+		WithAPIURL(ts.URL),
+	}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// callTool calls tool through the MCP server with ctx, returning the text of
+// its result or the message of its error.
+func callTool(t *testing.T, ctx context.Context, s *Server, tool string, arguments map[string]any) (text, errMessage string) {
+	t.Helper()
+	message, err := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "tools/call",
+		"params":  map[string]any{"name": tool, "arguments": arguments},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	switch response := s.mcpServer.HandleMessage(ctx, message).(type) {
+	case mcp.JSONRPCError:
+		return "", response.Error.Message
+	case mcp.JSONRPCResponse:
+		result := response.Result.(mcp.CallToolResult)
+		if len(result.Content) != 1 {
+			t.Fatalf("%s returned %d contents", tool, len(result.Content))
+		}
+		text := result.Content[0].(mcp.TextContent).Text
+		if result.IsError {
+			return "", text
+		}
+		return text, ""
+	default:
+		t.Fatalf("%s returned %#v", tool, response)
+	}
+	return "", ""
+}
+
+func TestSearchTool(t *testing.T) {
+	api := newSpotifyAPI()
+	api.handle("GET /search", `{
+		"tracks": {"items": [
+			{"name": "Airbag", "uri": "spotify:track:1", "duration_ms": 284000, "explicit": false, "popularity": 60,
+			 "artists": [{"name": "Radiohead", "uri": "spotify:artist:r"}], "album": {"name": "OK Computer", "images": []}},
+			null
+		], "total": 25, "offset": 0},
+		"artists": {"items": [
+			{"name": "Radiohead", "uri": "spotify:artist:r", "genres": ["rock"], "followers": {"total": 9, "href": null}, "images": []}
+		], "total": 1, "offset": 0}
+	}`)
+	s := newAPITestServer(t, api)
+
+	text, errMessage := callTool(t, context.Background(), s, SearchToolName, map[string]any{
+		"query":  "artist:radiohead year:1997",
+		"types":  []string{"track", "artist"},
+		"market": "from_token",
+		"limit":  2,
+	})
+	if errMessage != "" {
+		t.Fatal(errMessage)
+	}
+	want := `{
+		"tracks": {"items": [
+			{"name": "Airbag", "uri": "spotify:track:1", "duration_ms": 284000, "explicit": false,
+			 "artists": [{"name": "Radiohead"}], "album": {"name": "OK Computer"}}
+		], "total": 25, "offset": 0, "nextOffset": 2},
+		"artists": {"items": [
+			{"name": "Radiohead", "uri": "spotify:artist:r", "genres": ["rock"], "followers": {"total": 9}}
+		], "total": 1, "offset": 0}
+	}`
+	assertJSON(t, text, want)
+	if requests := api.takeRequests(); len(requests) != 1 ||
+		requests[0] != "GET /search?limit=2&market=from_token&offset=0&q=artist%3Aradiohead+year%3A1997&type=track%2Cartist" {
+		t.Errorf("requests %q", requests)
+	}
+
+	// the defaults search tracks
+	if _, errMessage := callTool(t, context.Background(), s, SearchToolName, map[string]any{"query": "airbag"}); errMessage != "" {
+		t.Fatal(errMessage)
+	}
+	if requests := api.takeRequests(); len(requests) != 1 || requests[0] != "GET /search?limit=10&offset=0&q=airbag&type=track" {
+		t.Errorf("requests %q", requests)
+	}
+}
+
+func TestSearchToolFields(t *testing.T) {
+	api := newSpotifyAPI()
+	api.handle("GET /search", `{"tracks": {"items": [
+		{"name": "Airbag", "uri": "spotify:track:1", "popularity": 60,
+		 "artists": [{"name": "Radiohead", "id": "r"}, {"name": "Guest", "id": "g"}], "album": {"name": "OK Computer", "release_date": "1997"}}
+	], "total": 1, "offset": 0}}`)
+	s := newAPITestServer(t, api)
+
+	for _, test := range []struct {
+		fields []string
+		want   string
+	}{
+		{[]string{"name", "artists.id", "album.release_date"},
+			`{"tracks": {"items": [{"name": "Airbag", "artists": [{"id": "r"}, {"id": "g"}], "album": {"release_date": "1997"}}], "total": 1, "offset": 0}}`},
+		{[]string{"popularity", "missing.field"},
+			`{"tracks": {"items": [{"popularity": 60}], "total": 1, "offset": 0}}`},
+		{[]string{"*"},
+			`{"tracks": {"items": [{"name": "Airbag", "uri": "spotify:track:1", "popularity": 60,
+			 "artists": [{"name": "Radiohead", "id": "r"}, {"name": "Guest", "id": "g"}], "album": {"name": "OK Computer", "release_date": "1997"}}], "total": 1, "offset": 0}}`},
+	} {
+		text, errMessage := callTool(t, context.Background(), s, SearchToolName, map[string]any{"query": "airbag", "fields": test.fields})
+		if errMessage != "" {
+			t.Fatalf("%v: %s", test.fields, errMessage)
+		}
+		assertJSON(t, text, test.want)
+	}
+}
+
+func TestSearchToolErrors(t *testing.T) {
+	api := newSpotifyAPI()
+	api.mux.HandleFunc("GET /search", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("q") {
+		case "rate limited":
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"error": {"status": 429, "message": "API rate limit exceeded"}}`))
+		case "garbled":
+			w.Write([]byte(`{"tracks": [`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": {"status": 400, "message": "Invalid market code"}}`))
+		}
+	})
+	s := newAPITestServer(t, api)
+
+	for _, test := range []struct {
+		name      string
+		arguments map[string]any
+		wantErr   string
+		// wantCall is false for the arguments rejected before calling Spotify
+		wantCall bool
+	}{
+		{"missing query", map[string]any{}, "query", false},
+		{"empty query", map[string]any{"query": ""}, "query", false},
+		{"unknown type", map[string]any{"query": "a", "types": []string{"song"}}, "types", false},
+		{"no types", map[string]any{"query": "a", "types": []string{}}, "types", false},
+		{"limit too high", map[string]any{"query": "a", "limit": 51}, "limit", false},
+		{"offset too high", map[string]any{"query": "a", "offset": 1001}, "offset", false},
+		{"invalid market", map[string]any{"query": "a", "market": "XX"}, "Invalid market code", true},
+		{"rate limited", map[string]any{"query": "rate limited"}, "status code 429: API rate limit exceeded", true},
+		{"invalid response", map[string]any{"query": "garbled"}, "spotify GET /search", true},
+	} {
+		text, errMessage := callTool(t, context.Background(), s, SearchToolName, test.arguments)
+		if !strings.Contains(errMessage, test.wantErr) {
+			t.Errorf("%s: got %q, %q, want an error containing %q", test.name, text, errMessage, test.wantErr)
+		}
+		if called := len(api.takeRequests()) > 0; called != test.wantCall {
+			t.Errorf("%s: called Spotify %v, want %v", test.name, called, test.wantCall)
+		}
+	}
+
+	// a token Spotify rejects without a refresh token to renew it
+	store := tokenstore.NewMemory()
+	store.Put(context.Background(), TokenKey, tokenstore.Token{Token: &oauth2.Token{AccessToken: "revoked"}})
+	s = newAPITestServer(t, api, WithTokenStore(store))
+	if _, errMessage := callTool(t, context.Background(), s, SearchToolName, map[string]any{"query": "a"}); !strings.Contains(errMessage, "status code 401") {
+		t.Errorf("revoked token: got %q, want status code 401", errMessage)
+	}
+}
+
+// assertJSON compares the JSON got with want, ignoring the formatting.
+func assertJSON(t *testing.T, got, want string) {
+	t.Helper()
+	var gotValue, wantValue any
+	if err := json.Unmarshal([]byte(got), &gotValue); err != nil {
+		t.Fatalf("invalid JSON %s: %v", got, err)
+	}
+	if err := json.Unmarshal([]byte(want), &wantValue); err != nil {
+		t.Fatalf("invalid expected JSON %s: %v", want, err)
+	}
+	gotJSON, _ := json.Marshal(gotValue)
+	wantJSON, _ := json.Marshal(wantValue)
+	if string(gotJSON) != string(wantJSON) {
+		t.Errorf("got %s, want %s", gotJSON, wantJSON)
+	}
+}
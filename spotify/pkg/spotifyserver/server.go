@@ -0,0 +1,298 @@
+// Package spotifyserver provides the Spotify OAuth protected MCP server so it
+// can be embedded in other Go programs.
+package spotifyserver
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/wagnerjt/go-mcp/shared/pkg/buildinfo"
+	"github.com/wagnerjt/go-mcp/shared/pkg/compensation"
+	"github.com/wagnerjt/go-mcp/shared/pkg/jwtauth"
+	"github.com/wagnerjt/go-mcp/shared/pkg/oauthflow"
+	"github.com/wagnerjt/go-mcp/shared/pkg/upstream"
+	"github.com/wagnerjt/go-mcp/spotify/pkg/tokenstore"
+	"golang.org/x/oauth2"
+)
+
+const (
+	AuthorizationHeader string = "Authorization"
+	QueryState          string = "state"
+	QueryCode           string = "code"
+	// RedirectURL is the redirect URI to register with Spotify for the
+	// default local setup
+	RedirectURL string = "http://127.0.0.1:8080" + CallbackPath
+	// Spotify endpoints from .well-known (hardcoded for now)
+	SpotifyAuthEndpoint  = "https://accounts.spotify.com/authorize"
+	SpotifyTokenEndpoint = "https://accounts.spotify.com/api/token"
+	SpotifyWellKnownURL  = "https://accounts.spotify.com/.well-known/openid-configuration"
+)
+
+// Server is the Spotify MCP server along with its OAuth endpoints.
+type Server struct {
+	mu                sync.RWMutex
+	credentialsLoader CredentialsLoader
+
+	clientID        string
+	clientSecret    string
+	redirectURL     string
+	externalURL     string
+	callbackPath    string
+	trustForwarded  bool
+	scopes          []string
+	wellKnownConfig []byte
+	// wellKnownFixed is set by WithWellKnownConfig, the config is then
+	// never fetched from Spotify
+	wellKnownFixed bool
+	// runs the PKCE flow, its state and code_verifier are kept in memory
+	auth *oauthflow.Flow
+	// the token of the last login and the scopes it was granted
+	token         *oauth2.Token
+	grantedScopes []string
+	// the rejection of the last refresh, until a refresh or login succeeds
+	refreshFailure *refreshFailure
+	toolScopes     map[string][]string
+	// the Spotify user of the last login, looked up on the first bearer
+	// token verified with Spotify, and the bearer tokens found to be theirs
+	loginUser string
+	verified  map[[sha256.Size]byte]verifiedToken
+
+	tokenFile          string
+	tokenStore         tokenstore.Store
+	revocationEndpoint string
+	sessionCookies     bool
+	sessionKey         string
+	sessions           *oauthflow.Sessions
+	refreshLeeway      time.Duration
+	refreshAttempts    int
+	refreshBackoff     time.Duration
+	clock              func() time.Time
+	rand               io.Reader
+
+	apiURL         string
+	httpClient     *http.Client
+	djMu           sync.Mutex
+	djSessions     map[string]*djSession
+	undo           *compensation.Stacks
+	extraTools     []server.ServerTool
+	tokenValidator jwtauth.TokenValidator
+	authMiddleware func(http.Handler) http.Handler
+	mcpServer      *server.MCPServer
+}
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithClientCredentials sets the Spotify app client ID and secret.
+func WithClientCredentials(clientID, clientSecret string) Option {
+	return func(s *Server) {
+		s.clientID = clientID
+		s.clientSecret = clientSecret
+	}
+}
+
+// WithRedirectURL overrides the OAuth redirect URI registered with Spotify,
+// which is otherwise the callback path on the base URL of the login request.
+func WithRedirectURL(redirectURL string) Option {
+	return func(s *Server) {
+		s.redirectURL = redirectURL
+	}
+}
+
+// WithScopes overrides the scopes requested during login.
+func WithScopes(scopes ...string) Option {
+	return func(s *Server) {
+		s.scopes = scopes
+	}
+}
+
+// WithWellKnownConfig uses config as the proxied authorization server
+// metadata instead of fetching it from Spotify.
+func WithWellKnownConfig(config []byte) Option {
+	return func(s *Server) {
+		s.wellKnownConfig = config
+		s.wellKnownFixed = true
+	}
+}
+
+// WithTools registers additional tools next to the built-in ones.
+func WithTools(tools ...server.ServerTool) Option {
+	return func(s *Server) {
+		s.extraTools = append(s.extraTools, tools...)
+	}
+}
+
+// WithAuth replaces the middleware protecting the MCP endpoint.
+func WithAuth(middleware func(http.Handler) http.Handler) Option {
+	return func(s *Server) {
+		s.authMiddleware = middleware
+	}
+}
+
+// WithSessionCookies issues a signed, HttpOnly session cookie after the OAuth
+// callback, which the MCP endpoint accepts in place of a bearer token for
+// browser clients that cannot set headers. The cookies are signed with key,
+// a random one per process when empty.
+func WithSessionCookies(enabled bool, key string) Option {
+	return func(s *Server) {
+		s.sessionCookies = enabled
+		s.sessionKey = key
+	}
+}
+
+// WithClock replaces time.Now in the login flow, the session cookies and
+// the token expiry checks, so tests can exercise the TTLs without sleeping.
+func WithClock(clock func() time.Time) Option {
+	return func(s *Server) {
+		s.clock = clock
+	}
+}
+
+// WithRand replaces crypto/rand as the source of the login states, PKCE
+// verifiers and session IDs, so tests can be deterministic. Never use a
+// predictable source in production.
+func WithRand(rand io.Reader) Option {
+	return func(s *Server) {
+		s.rand = rand
+	}
+}
+
+// New creates the Spotify MCP server.
+func New(opts ...Option) (*Server, error) {
+	s := &Server{
+		callbackPath: CallbackPath,
+		scopes:       []string{"user-read-private", "user-read-email"},
+
+		refreshLeeway:   DefaultRefreshLeeway,
+		refreshAttempts: DefaultRefreshAttempts,
+		refreshBackoff:  DefaultRefreshBackoff,
+
+		apiURL:     SpotifyAPIURL,
+		httpClient: http.DefaultClient,
+		clock:      time.Now,
+		rand:       rand.Reader,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.authMiddleware == nil {
+		s.authMiddleware = s.bearerMiddleware
+	}
+	if err := s.loadCredentials(); err != nil {
+		return nil, err
+	}
+	if s.clientID == "" || s.clientSecret == "" {
+		return nil, fmt.Errorf("spotify client credentials are required")
+	}
+	if err := s.loadToken(); err != nil {
+		return nil, err
+	}
+	// spotify's well-known configuration is fetched by AwaitReadiness for proxying
+
+	s.auth = oauthflow.New(s.oauthConfig, oauthflow.TokenStoreFunc(s.storeToken))
+	s.auth.Clock, s.auth.Rand = s.clock, s.rand
+	s.auth.HTTPClient = s.httpClient
+	if s.sessionCookies {
+		sessions, err := oauthflow.NewSessions([]byte(s.sessionKey))
+		if err != nil {
+			return nil, err
+		}
+		s.sessions = sessions
+		s.sessions.Secure = strings.HasPrefix(s.externalURL, "https://")
+		s.sessions.Clock, s.sessions.Rand = s.clock, s.rand
+		s.auth.Sessions = s.sessions
+	}
+	s.undo = compensation.New()
+	s.mcpServer = s.newMCPServer()
+	return s, nil
+}
+
+// MCPServer returns the underlying MCP server.
+func (s *Server) MCPServer() *server.MCPServer {
+	return s.mcpServer
+}
+
+func (s *Server) oauthConfig() *oauth2.Config {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return &oauth2.Config{
+		ClientID:     s.clientID,
+		ClientSecret: s.clientSecret,
+		RedirectURL:  s.redirectURL,
+		Scopes:       s.scopes,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  SpotifyAuthEndpoint,
+			TokenURL: SpotifyTokenEndpoint,
+		},
+	}
+}
+
+// Handler returns the http.Handler serving the MCP, OAuth and health endpoints.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	// Simple health endpoint
+	mux.HandleFunc("/health", handleHealth)
+	mux.HandleFunc("/readyz", s.handleReadyz)
+	mux.HandleFunc("/version", buildinfo.Handler)
+
+	// Adding MCP spec endpoints
+	mux.HandleFunc("/.well-known/oauth-protected-resource", s.returnWellKnownAuthServer)
+	mux.HandleFunc("/.well-known/oauth-authorization-server", s.returnWellKnownProxy)
+	// Provide a valid OAuthConfig to the callback handler
+	mux.Handle(s.callbackPath, s.auth)
+	// Add the login endpoint
+	mux.HandleFunc(LoginPath, s.handleSpotifyLogin)
+	mux.HandleFunc(ConsentPath, s.handleSpotifyConsent)
+
+	// Add the mcp server endpoint with the auth middleware
+	auth := s.authMiddleware
+	if s.sessions != nil {
+		auth = s.sessions.Middleware(auth)
+	}
+	httpServer := server.NewStreamableHTTPServer(s.mcpServer, server.WithHTTPContextFunc(authFromRequest))
+	mux.Handle("/mcp", auth(http.HandlerFunc(httpServer.ServeHTTP)))
+	mux.Handle("/auth/smoke", auth(http.HandlerFunc(handleAuthSmokeTest)))
+	// logging out drops the token of every client, so it takes the same auth
+	mux.Handle(LogoutPath, auth(http.HandlerFunc(s.handleLogout)))
+
+	return s.baseURLMiddleware(mux)
+}
+
+// GetResponseBodyBytes fetches url, aborting when ctx is done.
+func GetResponseBodyBytes(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", url, upstream.Error(ctx, err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: status code %d", url, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body from %s: %w", url, upstream.Error(ctx, err))
+	}
+
+	return body, nil
+}
+
+// HTTP endpoints
+func handleHealth(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"status":"UP"}`))
+}
@@ -0,0 +1,414 @@
+package spotifyserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/wagnerjt/go-mcp/shared/pkg/upstream"
+	"github.com/wagnerjt/go-mcp/spotify/pkg/tokenstore"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// LoginPath starts the login.
+	LoginPath = "/auth/spotify/login"
+	// LogoutPath revokes the stored token and forgets it.
+	LogoutPath = "/auth/logout"
+	// SpotifyAppsURL is where users remove the access of an app, Spotify
+	// has no revocation endpoint.
+	SpotifyAppsURL = "https://www.spotify.com/account/apps/"
+)
+
+// WithTokenFile persists the stored token and its scopes to path, which is
+// loaded by New and rewritten atomically whenever the token changes, i.e.
+// when a refresh rotated the refresh token.
+func WithTokenFile(path string) Option {
+	return func(s *Server) {
+		s.tokenFile = path
+	}
+}
+
+// WithRevocationEndpoint sets the RFC 7009 revocation endpoint, otherwise
+// the revocation_endpoint of the well-known config is used when present.
+func WithRevocationEndpoint(endpoint string) Option {
+	return func(s *Server) {
+		s.revocationEndpoint = endpoint
+	}
+}
+
+// Defaults of WithTokenRefresh.
+const (
+	// DefaultRefreshLeeway refreshes tokens shortly before they expire, like
+	// the oauth2 package, so they don't expire in flight.
+	DefaultRefreshLeeway   = 10 * time.Second
+	DefaultRefreshAttempts = 3
+	DefaultRefreshBackoff  = time.Second
+)
+
+// WithTokenRefresh configures the refreshes of the stored token: tool calls
+// refresh it leeway before it expires, and a refresh failing transiently,
+// i.e. on a network error or a 5xx or 429 of the token endpoint, is made up
+// to attempts times, waiting backoff and then twice as long each time. A
+// rejected refresh token is never retried.
+func WithTokenRefresh(leeway time.Duration, attempts int, backoff time.Duration) Option {
+	return func(s *Server) {
+		s.refreshLeeway = leeway
+		s.refreshAttempts = max(attempts, 1)
+		s.refreshBackoff = backoff
+	}
+}
+
+// TokenKey is the key of the stored token in the store of WithTokenStore,
+// the server keeps the token of the last login only.
+const TokenKey = "spotify"
+
+// WithTokenStore persists the stored token and its scopes in store, i.e. a
+// tokenstore.File encrypted at rest, in place of the plain file of
+// WithTokenFile. The token is loaded by New, and its refreshes go through
+// the store, so the tool handlers sharing the store see the rotated tokens.
+func WithTokenStore(store tokenstore.Store) Option {
+	return func(s *Server) {
+		s.tokenStore = store
+	}
+}
+
+func (s *Server) loadToken() error {
+	if s.tokenStore != nil {
+		stored, err := s.tokenStore.Get(context.Background(), TokenKey)
+		if errors.Is(err, tokenstore.ErrNotFound) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		s.token, s.grantedScopes = stored.Token, stored.Scopes
+		return nil
+	}
+	if s.tokenFile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(s.tokenFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to read token file: %w", err)
+	}
+	var stored tokenstore.Token
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return fmt.Errorf("failed to parse token file %s: %w", s.tokenFile, err)
+	}
+	s.token = stored.Token
+	s.grantedScopes = stored.Scopes
+	return nil
+}
+
+// saveToken writes the token to the store, or the token file through a
+// rename so a crash never leaves a torn file behind, the refresh token in it
+// may be the only valid one. It is called with s.mu held.
+func (s *Server) saveToken() error {
+	if s.tokenStore != nil {
+		if s.token == nil {
+			return s.tokenStore.Delete(context.Background(), TokenKey)
+		}
+		return s.tokenStore.Put(context.Background(), TokenKey, tokenstore.Token{Token: s.token, Scopes: s.grantedScopes})
+	}
+	if s.tokenFile == "" {
+		return nil
+	}
+	if s.token == nil {
+		if err := os.Remove(s.tokenFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.Marshal(tokenstore.Token{Token: s.token, Scopes: s.grantedScopes})
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.tokenFile), ".token-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.tokenFile)
+}
+
+// TokenSource returns the stored token, refreshing it when it expired. A
+// refresh token rotated by the refresh is persisted before the new access
+// token is used.
+func (s *Server) TokenSource(ctx context.Context) oauth2.TokenSource {
+	return &rotatingTokenSource{ctx: ctx, server: s}
+}
+
+type rotatingTokenSource struct {
+	ctx    context.Context
+	server *Server
+}
+
+func (ts *rotatingTokenSource) Token() (*oauth2.Token, error) {
+	s := ts.server
+	s.mu.RLock()
+	current := s.token
+	s.mu.RUnlock()
+	if current == nil {
+		return nil, fmt.Errorf("not logged in to Spotify")
+	}
+	if !s.tokenExpired(current) {
+		return current, nil
+	}
+
+	return s.refreshToken(ts.ctx, current)
+}
+
+// refreshToken refreshes current, the stored token, and records the
+// outcome for TokenStatus.
+func (s *Server) refreshToken(ctx context.Context, current *oauth2.Token) (*oauth2.Token, error) {
+	var refreshed *oauth2.Token
+	var err error
+	if s.tokenStore != nil {
+		var stored tokenstore.Token
+		stored, err = s.tokenStore.Refresh(ctx, TokenKey, func(stored tokenstore.Token) (tokenstore.Token, error) {
+			if stored.Token == nil {
+				return stored, fmt.Errorf("not logged in to Spotify")
+			}
+			if stored.Token.AccessToken != current.AccessToken && !s.tokenExpired(stored.Token) {
+				// refreshed by another user of the store meanwhile
+				return stored, nil
+			}
+			token, err := s.exchangeRefreshToken(ctx, stored.Token)
+			stored.Token = token
+			return stored, err
+		})
+		refreshed = stored.Token
+	} else {
+		refreshed, err = s.exchangeRefreshToken(ctx, current)
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to refresh token: %w", err)
+		// a caller giving up or a transient failure says nothing about the
+		// grant, only a rejection needs a new login
+		if ctx.Err() == nil && refreshRejected(err) {
+			s.recordRefresh(err)
+		}
+		return nil, err
+	}
+	s.recordRefresh(nil)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != current {
+		// a concurrent refresh or login won
+		return s.token, nil
+	}
+	s.token = refreshed
+	if s.tokenStore == nil && refreshed.RefreshToken != current.RefreshToken {
+		if err := s.saveToken(); err != nil {
+			// the previous refresh token may already be invalid
+			log.Printf("Failed to persist the rotated refresh token: %v", err)
+		}
+	}
+	return refreshed, nil
+}
+
+// exchangeRefreshToken exchanges the refresh token of token for a new token,
+// retrying transient failures as configured by WithTokenRefresh.
+func (s *Server) exchangeRefreshToken(ctx context.Context, token *oauth2.Token) (*oauth2.Token, error) {
+	if token.RefreshToken == "" {
+		return nil, fmt.Errorf("no refresh token, log in again")
+	}
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, s.httpClient)
+	var refreshed *oauth2.Token
+	var err error
+	backoff := s.refreshBackoff
+	for attempt := 1; ; attempt++ {
+		// only the refresh token is passed, so that the refresh happens on
+		// the server's clock rather than the one of the oauth2 package
+		refreshed, err = s.oauthConfig().TokenSource(ctx, &oauth2.Token{RefreshToken: token.RefreshToken}).Token()
+		if err == nil || attempt >= s.refreshAttempts || !refreshRetryable(ctx, err) {
+			break
+		}
+		log.Printf("Spotify token refresh failed, retrying in %v: %v", backoff, err)
+		select {
+		case <-ctx.Done():
+			return nil, upstream.Error(ctx, ctx.Err())
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	if err != nil {
+		return nil, upstream.Error(ctx, err)
+	}
+	if refreshed.RefreshToken == "" {
+		// Spotify only returns a refresh token when it rotated it
+		refreshed.RefreshToken = token.RefreshToken
+	}
+	return refreshed, nil
+}
+
+// refreshRetryable reports whether a refresh failed transiently, rather than
+// because the token endpoint rejected the refresh token or the caller gave
+// up.
+func refreshRetryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var retrieveErr *oauth2.RetrieveError
+	if errors.As(err, &retrieveErr) {
+		return retryableStatus(retrieveErr.Response.StatusCode)
+	}
+	return true
+}
+
+// refreshRejected reports whether the token endpoint rejected the refresh
+// token, which only a new login fixes.
+func refreshRejected(err error) bool {
+	var retrieveErr *oauth2.RetrieveError
+	return errors.As(err, &retrieveErr) && !retryableStatus(retrieveErr.Response.StatusCode)
+}
+
+func retryableStatus(status int) bool {
+	return status >= 500 || status == http.StatusTooManyRequests
+}
+
+// tokenExpired reports whether token has to be refreshed on s.clock.
+func (s *Server) tokenExpired(token *oauth2.Token) bool {
+	if token.AccessToken == "" {
+		return true
+	}
+	return !token.Expiry.IsZero() && !s.clock().Add(s.refreshLeeway).Before(token.Expiry)
+}
+
+// revocationEndpointURL returns the configured or discovered revocation
+// endpoint, empty when the provider has none.
+func (s *Server) revocationEndpointURL() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.revocationEndpoint != "" {
+		return s.revocationEndpoint
+	}
+	var metadata struct {
+		RevocationEndpoint string `json:"revocation_endpoint"`
+	}
+	if s.wellKnownConfig != nil {
+		_ = json.Unmarshal(s.wellKnownConfig, &metadata)
+	}
+	return metadata.RevocationEndpoint
+}
+
+// Logout revokes the stored token at the revocation endpoint when there is
+// one and forgets it. Spotify has none, the access of the app is removed
+// by the user on SpotifyAppsURL instead, which is reported as revoked
+// false.
+func (s *Server) Logout(ctx context.Context) (revoked bool, err error) {
+	s.mu.Lock()
+	token := s.token
+	s.token = nil
+	s.grantedScopes = nil
+	s.refreshFailure = nil
+	s.loginUser = ""
+	s.verified = nil
+	saveErr := s.saveToken()
+	s.mu.Unlock()
+	if saveErr != nil {
+		return false, fmt.Errorf("failed to remove token file: %w", saveErr)
+	}
+	if token == nil {
+		return false, nil
+	}
+
+	endpoint := s.revocationEndpointURL()
+	if endpoint == "" {
+		return false, nil
+	}
+	// the token is forgotten already, revoke it even if the caller went away
+	ctx, cancel := upstream.CleanupContext(ctx)
+	defer cancel()
+	// revoking the refresh token revokes its access tokens too (RFC 7009 2.1)
+	if token.RefreshToken != "" {
+		if err := s.revoke(ctx, endpoint, token.RefreshToken, "refresh_token"); err != nil {
+			return false, err
+		}
+	}
+	if err := s.revoke(ctx, endpoint, token.AccessToken, "access_token"); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *Server) revoke(ctx context.Context, endpoint, token, hint string) error {
+	form := url.Values{"token": {token}, "token_type_hint": {hint}}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	s.mu.RLock()
+	req.SetBasicAuth(url.QueryEscape(s.clientID), url.QueryEscape(s.clientSecret))
+	s.mu.RUnlock()
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to revoke %s: %w", hint, upstream.Error(ctx, err))
+	}
+	defer resp.Body.Close()
+	// the token is invalid either way, an unsupported_token_type means the
+	// provider cannot revoke that type (RFC 7009 2.2.1)
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode == http.StatusBadRequest && strings.Contains(string(body), "unsupported_token_type") {
+		return nil
+	}
+	return fmt.Errorf("failed to revoke %s: status code %d: %s", hint, resp.StatusCode, body)
+}
+
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	revoked, err := s.Logout(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to log out: %v", err), http.StatusBadGateway)
+		return
+	}
+	if s.sessions != nil {
+		// the cookies were issued for the login that is gone
+		s.sessions.RevokeAll()
+		s.sessions.Clear(w)
+	}
+	if !revoked {
+		body, _ := json.Marshal(map[string]string{"status": "logged out", "revoke": SpotifyAppsURL})
+		textResponse(w, http.StatusOK, string(body))
+		return
+	}
+	textResponse(w, http.StatusOK, `{"status":"revoked"}`)
+}
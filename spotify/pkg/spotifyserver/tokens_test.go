@@ -0,0 +1,71 @@
+package spotifyserver
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wagnerjt/go-mcp/spotify/pkg/tokenstore"
+	"golang.org/x/oauth2"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// TestRefreshRecordsRejectionsOnly checks that only a refresh token rejected
+// by the token endpoint asks for a new login, not a cancelled caller or a
+// transient failure.
+func TestRefreshRecordsRejectionsOnly(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	for _, test := range []struct {
+		name     string
+		status   int
+		cancel   bool
+		rejected bool
+	}{
+		{"invalid grant", http.StatusBadRequest, false, true},
+		{"server error", http.StatusBadGateway, false, false},
+		{"rate limited", http.StatusTooManyRequests, false, false},
+		{"cancelled", http.StatusBadRequest, true, false},
+	} {
+		store := tokenstore.NewMemory()
+		store.Put(context.Background(), TokenKey, tokenstore.Token{Token: &oauth2.Token{
+			AccessToken: "expired", RefreshToken: "refresh", Expiry: now.Add(-time.Minute),
+		}})
+		ctx, cancel := context.WithCancel(context.Background())
+		client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			if test.cancel {
+				cancel()
+				return nil, context.Canceled
+			}
+			return &http.Response{
+				StatusCode: test.status,
+				Header:     http.Header{"Content-Type": {"application/json"}},
+				Body:       io.NopCloser(strings.NewReader(`{"error":"invalid_grant"}`)),
+				Request:    r,
+			}, nil
+		})}
+		s, err := New(WithClientCredentials("id", "secret"), WithTokenStore(store), WithHTTPClient(client),
+			WithTokenRefresh(0, 1, 0), WithClock(func() time.Time { return now }))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.TokenSource(ctx).Token(); err == nil {
+			t.Fatalf("%s: refresh succeeded", test.name)
+		}
+		cancel()
+		status := s.TokenStatus(context.Background())
+		if got := status.LastRefreshError != ""; got != test.rejected {
+			t.Errorf("%s: refresh failure recorded = %v, want %v", test.name, got, test.rejected)
+		}
+		if got := status.LoginURL != ""; got != test.rejected {
+			t.Errorf("%s: login URL = %q, want one %v", test.name, status.LoginURL, test.rejected)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package spotifyserver
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	// TokenStatusURI is the resource reporting the health of the stored
+	// token.
+	TokenStatusURI = "spotify://auth/token-status"
+	// TokenRefreshAhead is how long before its expiry WatchToken refreshes
+	// the stored token, so a failing refresh is noticed before tool calls
+	// need the token.
+	TokenRefreshAhead = 5 * time.Minute
+	// TokenWatchInterval is how often WatchToken checks the stored token,
+	// and the first backoff after a transient refresh failure.
+	TokenWatchInterval = time.Minute
+	// maxTokenWatchBackoff caps the backoff of WatchToken, so a token
+	// about to expire is still retried a few times.
+	maxTokenWatchBackoff = 4 * time.Minute
+)
+
+// TokenStatus reports the health of the stored token.
+type TokenStatus struct {
+	Authenticated bool       `json:"authenticated"`
+	ExpiresAt     *time.Time `json:"expiresAt,omitempty"`
+	// ExpiresIn is in seconds, negative once the access token expired.
+	ExpiresIn   int64    `json:"expiresIn,omitempty"`
+	Expired     bool     `json:"expired"`
+	Scopes      []string `json:"scopes"`
+	Refreshable bool     `json:"refreshable"`
+	// LastRefreshError is the error of the last refresh the token endpoint
+	// rejected, until a refresh or a login succeeds.
+	LastRefreshError    string     `json:"lastRefreshError,omitempty"`
+	LastRefreshFailedAt *time.Time `json:"lastRefreshFailedAt,omitempty"`
+	// LoginURL is set when the token is missing or cannot be refreshed.
+	LoginURL string `json:"loginUrl,omitempty"`
+}
+
+// refreshFailure is the failure of the last refresh of the stored token.
+type refreshFailure struct {
+	err string
+	at  time.Time
+}
+
+// TokenStatus reports the health of the stored token.
+func (s *Server) TokenStatus(ctx context.Context) TokenStatus {
+	s.mu.RLock()
+	token, scopes, failure := s.token, slices.Clone(s.grantedScopes), s.refreshFailure
+	s.mu.RUnlock()
+
+	status := TokenStatus{Authenticated: token != nil, Scopes: scopes}
+	if status.Scopes == nil {
+		status.Scopes = []string{}
+	}
+	if token != nil {
+		status.Refreshable = token.RefreshToken != ""
+		status.Expired = s.tokenExpired(token)
+		if !token.Expiry.IsZero() {
+			expiry := token.Expiry.UTC()
+			status.ExpiresAt = &expiry
+			status.ExpiresIn = int64(token.Expiry.Sub(s.clock()) / time.Second)
+		}
+	}
+	if failure != nil {
+		at := failure.at.UTC()
+		status.LastRefreshError, status.LastRefreshFailedAt = failure.err, &at
+	}
+	if token == nil || failure != nil || (status.Expired && !status.Refreshable) {
+		status.LoginURL = baseURLFromContext(ctx) + LoginPath
+	}
+	return status
+}
+
+func (s *Server) readTokenStatus(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	data, err := json.Marshal(s.TokenStatus(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return []mcp.ResourceContents{mcp.TextResourceContents{
+		URI:      TokenStatusURI,
+		MIMEType: "application/json",
+		Text:     string(data),
+	}}, nil
+}
+
+// recordRefresh records the outcome of a refresh of the stored token, where
+// err is a refresh token rejected by the token endpoint. The
+// first failure after a success is pushed to the clients as an error log
+// message, so they can prompt for a new login before tool calls fail.
+func (s *Server) recordRefresh(err error) {
+	s.mu.Lock()
+	if err == nil {
+		s.refreshFailure = nil
+		s.mu.Unlock()
+		return
+	}
+	first := s.refreshFailure == nil
+	s.refreshFailure = &refreshFailure{err: err.Error(), at: s.clock()}
+	externalURL := s.externalURL
+	s.mu.Unlock()
+
+	if !first || s.mcpServer == nil {
+		return
+	}
+	log.Printf("Spotify token refresh failed: %v", err)
+	s.mcpServer.SendNotificationToAllClients("notifications/message", map[string]any{
+		"level":  mcp.LoggingLevelError,
+		"logger": "spotify-auth",
+		"data": map[string]any{
+			"message":  "The Spotify token could not be refreshed, log in again: " + err.Error(),
+			"resource": TokenStatusURI,
+			"loginUrl": externalURL + LoginPath,
+		},
+	})
+}
+
+// WatchToken refreshes the stored token TokenRefreshAhead before it
+// expires until ctx is done, so a revoked grant is reported through
+// TokenStatusURI and a notification before a tool call needs the token.
+// Transient failures are retried with a backoff.
+func (s *Server) WatchToken(ctx context.Context) {
+	ticker := time.NewTicker(TokenWatchInterval)
+	defer ticker.Stop()
+	var retryAt time.Time
+	backoff := TokenWatchInterval
+	for {
+		s.mu.RLock()
+		token, rejected := s.token, s.refreshFailure != nil
+		s.mu.RUnlock()
+		// a rejected refresh token is not retried until the next login
+		now := s.clock()
+		if token != nil && !rejected && token.RefreshToken != "" && !token.Expiry.IsZero() &&
+			!now.Add(TokenRefreshAhead).Before(token.Expiry) && !now.Before(retryAt) {
+			if _, err := s.refreshToken(ctx, token); err != nil && ctx.Err() == nil && !refreshRejected(err) {
+				log.Printf("Proactive Spotify token refresh failed, retrying in %v: %v", backoff, err)
+				retryAt = now.Add(backoff)
+				backoff = min(2*backoff, maxTokenWatchBackoff)
+			} else {
+				retryAt, backoff = time.Time{}, TokenWatchInterval
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
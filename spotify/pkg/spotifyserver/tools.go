@@ -0,0 +1,61 @@
+package spotifyserver
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/wagnerjt/go-mcp/shared/pkg/buildinfo"
+)
+
+func handleEchoTool(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	arguments := request.GetArguments()
+	message, ok := arguments["message"].(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments: message is required")
+	}
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.NewTextContent(fmt.Sprintf("Echo: %s", message)),
+		},
+	}, nil
+}
+
+func (s *Server) newMCPServer() *server.MCPServer {
+	hooks := &server.Hooks{}
+
+	mcpServer := server.NewMCPServer("vscode-spotify/tools", buildinfo.Get().Version,
+		server.WithToolCapabilities(true),
+		server.WithResourceCapabilities(false, false),
+		server.WithLogging(),
+		server.WithHooks(hooks),
+	)
+
+	// Add a simple echo tool
+	mcpServer.AddTool(mcp.NewTool("echo",
+		mcp.WithDescription("Echoes back the input"),
+		mcp.WithString("message",
+			mcp.Description("Message to echo"),
+			mcp.Required(),
+		),
+	), handleEchoTool)
+	mcpServer.AddTool(mcp.NewTool(ScopesToolName,
+		mcp.WithDescription("Reports the Spotify scopes granted to the stored token against the ones the enabled tools require, with the consent URL requesting the missing ones"),
+	), s.handleScopesTool)
+	mcpServer.AddTools(s.searchTool())
+	mcpServer.AddTools(s.podcastTools()...)
+	for name, scopes := range podcastScopes {
+		s.addToolScopes(name, scopes...)
+	}
+	mcpServer.AddTools(s.djTools()...)
+	s.addToolScopes(StartDJSessionToolName, "user-modify-playback-state")
+	mcpServer.AddTools(s.undo.Tool())
+	mcpServer.AddTools(s.extraTools...)
+	mcpServer.AddResource(mcp.NewResource(TokenStatusURI, "Spotify token status",
+		mcp.WithResourceDescription("Expiry, scopes and refresh availability of the stored Spotify token, with the login URL when it needs a new login"),
+		mcp.WithMIMEType("application/json"),
+	), s.readTokenStatus)
+
+	return mcpServer
+}
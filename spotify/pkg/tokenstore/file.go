@@ -0,0 +1,173 @@
+package tokenstore
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// fileVersion is the version of the encrypted file format.
+const fileVersion = 1
+
+// additionalData binds the ciphertext to this use of the key.
+var additionalData = []byte("go-mcp tokenstore v1")
+
+// encryptedFile is the file of a File store, the tokens by key as JSON
+// sealed with AES-256-GCM.
+type encryptedFile struct {
+	Version    int    `json:"version"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// File is a Store keeping the tokens in a file encrypted at rest, which is
+// read on every Get, so processes sharing it see each other's tokens, and
+// rewritten atomically on every change. The refreshes are only serialized
+// within a process.
+type File struct {
+	path      string
+	aead      cipher.AEAD
+	refreshes keyLocks
+
+	// mu serializes the read-modify-write of the file
+	mu sync.Mutex
+}
+
+// NewFile creates a File store at path, encrypted with a key derived from
+// secret, which should be a random value of at least 32 bytes, i.e. from
+// openssl rand -base64 32. The file is created on the first Put, a file
+// that does not decrypt with the key fails every call.
+func NewFile(path string, secret []byte) (*File, error) {
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("the token store key is required")
+	}
+	key := sha256.Sum256(secret)
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &File{path: path, aead: aead}, nil
+}
+
+func (f *File) read() (map[string]Token, error) {
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return make(map[string]Token), nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read token store: %w", err)
+	}
+	var file encryptedFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse token store %s: %w", f.path, err)
+	}
+	if file.Version != fileVersion {
+		return nil, fmt.Errorf("token store %s has unsupported version %d", f.path, file.Version)
+	}
+	plaintext, err := f.aead.Open(nil, file.Nonce, file.Ciphertext, additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt token store %s, was the key changed?", f.path)
+	}
+	tokens := make(map[string]Token)
+	if err := json.Unmarshal(plaintext, &tokens); err != nil {
+		return nil, fmt.Errorf("failed to parse token store %s: %w", f.path, err)
+	}
+	return tokens, nil
+}
+
+// write replaces the file through a rename so a crash never leaves a torn
+// file behind, the refresh tokens in it may be the only valid ones.
+func (f *File) write(tokens map[string]Token) error {
+	plaintext, err := json.Marshal(tokens)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, f.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	data, err := json.Marshal(encryptedFile{
+		Version:    fileVersion,
+		Nonce:      nonce,
+		Ciphertext: f.aead.Seal(nil, nonce, plaintext, additionalData),
+	})
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), ".tokens-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
+
+// update applies change to the tokens of the file and writes them back.
+func (f *File) update(change func(tokens map[string]Token)) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	tokens, err := f.read()
+	if err != nil {
+		return err
+	}
+	change(tokens)
+	return f.write(tokens)
+}
+
+// Get implements Store.
+func (f *File) Get(ctx context.Context, key string) (Token, error) {
+	f.mu.Lock()
+	tokens, err := f.read()
+	f.mu.Unlock()
+	if err != nil {
+		return Token{}, err
+	}
+	token, ok := tokens[key]
+	if !ok {
+		return Token{}, ErrNotFound
+	}
+	return token, nil
+}
+
+// Put implements Store.
+func (f *File) Put(ctx context.Context, key string, token Token) error {
+	return f.update(func(tokens map[string]Token) { tokens[key] = token })
+}
+
+// Refresh implements Store.
+func (f *File) Refresh(ctx context.Context, key string, refresh func(Token) (Token, error)) (Token, error) {
+	defer f.refreshes.lock(key)()
+	return refreshWith(ctx, f, key, refresh)
+}
+
+// Delete implements Store.
+func (f *File) Delete(ctx context.Context, key string) error {
+	return f.update(func(tokens map[string]Token) { delete(tokens, key) })
+}
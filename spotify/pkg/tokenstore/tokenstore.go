@@ -0,0 +1,119 @@
+// Package tokenstore persists the OAuth tokens of the servers by key, i.e.
+// per user, in memory or in a file encrypted at rest, so they survive
+// restarts and can be shared with the tool handlers calling the provider.
+package tokenstore
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"golang.org/x/oauth2"
+)
+
+// ErrNotFound is returned for keys without a token.
+var ErrNotFound = errors.New("token not found")
+
+// Token is a stored token along with the scopes granted to it.
+type Token struct {
+	Token  *oauth2.Token `json:"token"`
+	Scopes []string      `json:"scopes"`
+}
+
+// Store stores tokens by key.
+type Store interface {
+	// Get returns the token of key, ErrNotFound when there is none.
+	Get(ctx context.Context, key string) (Token, error)
+	// Put stores token under key, replacing the previous one.
+	Put(ctx context.Context, key string, token Token) error
+	// Refresh replaces the token of key by the one refresh returns for it,
+	// with key locked, so concurrent refreshes do not race and a rotated
+	// refresh token is never lost. refresh is passed the zero Token when
+	// there is none.
+	Refresh(ctx context.Context, key string, refresh func(Token) (Token, error)) (Token, error)
+	// Delete forgets the token of key, it is no error when there is none.
+	Delete(ctx context.Context, key string) error
+}
+
+// keyLocks serializes the refreshes per key.
+type keyLocks struct {
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+func (k *keyLocks) lock(key string) func() {
+	k.mu.Lock()
+	if k.locks == nil {
+		k.locks = make(map[string]*sync.Mutex)
+	}
+	lock, ok := k.locks[key]
+	if !ok {
+		lock = &sync.Mutex{}
+		k.locks[key] = lock
+	}
+	k.mu.Unlock()
+	lock.Lock()
+	return lock.Unlock
+}
+
+// Memory is a Store keeping the tokens in memory, lost on restart.
+type Memory struct {
+	refreshes keyLocks
+
+	mu     sync.RWMutex
+	tokens map[string]Token
+}
+
+// NewMemory creates an empty Memory store.
+func NewMemory() *Memory {
+	return &Memory{tokens: make(map[string]Token)}
+}
+
+// Get implements Store.
+func (m *Memory) Get(ctx context.Context, key string) (Token, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	token, ok := m.tokens[key]
+	if !ok {
+		return Token{}, ErrNotFound
+	}
+	return token, nil
+}
+
+// Put implements Store.
+func (m *Memory) Put(ctx context.Context, key string, token Token) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokens[key] = token
+	return nil
+}
+
+// Refresh implements Store.
+func (m *Memory) Refresh(ctx context.Context, key string, refresh func(Token) (Token, error)) (Token, error) {
+	defer m.refreshes.lock(key)()
+	return refreshWith(ctx, m, key, refresh)
+}
+
+// Delete implements Store.
+func (m *Memory) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.tokens, key)
+	return nil
+}
+
+// refreshWith runs a refresh of key in store, whose key is locked.
+func refreshWith(ctx context.Context, store Store, key string, refresh func(Token) (Token, error)) (Token, error) {
+	current, err := store.Get(ctx, key)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return Token{}, err
+	}
+	refreshed, err := refresh(current)
+	if err != nil {
+		return Token{}, err
+	}
+	if err := store.Put(ctx, key, refreshed); err != nil {
+		return Token{}, err
+	}
+	return refreshed, nil
+}
@@ -0,0 +1,141 @@
+package tokenstore
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func newFile(t *testing.T) *File {
+	t.Helper()
+	store, err := NewFile(filepath.Join(t.TempDir(), "tokens.json"), []byte("test secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestStores(t *testing.T) {
+	stores := map[string]func(t *testing.T) Store{
+		"memory": func(t *testing.T) Store { return NewMemory() },
+		"file":   func(t *testing.T) Store { return newFile(t) },
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			store := newStore(t)
+			if _, err := store.Get(ctx, "alice"); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("Get() error = %v, want %v", err, ErrNotFound)
+			}
+
+			token := Token{
+				Token:  &oauth2.Token{AccessToken: "access", RefreshToken: "refresh", Expiry: time.Unix(1700000000, 0).UTC()},
+				Scopes: []string{"user-read-private"},
+			}
+			if err := store.Put(ctx, "alice", token); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Put(ctx, "bob", Token{Token: &oauth2.Token{AccessToken: "other"}}); err != nil {
+				t.Fatal(err)
+			}
+			got, err := store.Get(ctx, "alice")
+			if err != nil || got.Token.AccessToken != "access" || got.Token.RefreshToken != "refresh" || !got.Token.Expiry.Equal(token.Token.Expiry) || got.Scopes[0] != "user-read-private" {
+				t.Fatalf("Get() = %+v, %v, want the stored token", got, err)
+			}
+
+			refreshed, err := store.Refresh(ctx, "alice", func(current Token) (Token, error) {
+				current.Token = &oauth2.Token{AccessToken: "access 2", RefreshToken: current.Token.RefreshToken}
+				return current, nil
+			})
+			if err != nil || refreshed.Token.AccessToken != "access 2" {
+				t.Fatalf("Refresh() = %+v, %v, want the refreshed token", refreshed, err)
+			}
+			failed := errors.New("revoked")
+			if _, err := store.Refresh(ctx, "alice", func(Token) (Token, error) { return Token{}, failed }); !errors.Is(err, failed) {
+				t.Fatalf("Refresh() error = %v, want %v", err, failed)
+			}
+			if got, _ := store.Get(ctx, "alice"); got.Token.AccessToken != "access 2" {
+				t.Errorf("a failed refresh replaced the token by %+v", got)
+			}
+
+			if err := store.Delete(ctx, "alice"); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Delete(ctx, "alice"); err != nil {
+				t.Errorf("Delete() of a missing token = %v, want nil", err)
+			}
+			if _, err := store.Get(ctx, "alice"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Get() after Delete() error = %v, want %v", err, ErrNotFound)
+			}
+			if got, err := store.Get(ctx, "bob"); err != nil || got.Token.AccessToken != "other" {
+				t.Errorf("Get() of another key = %+v, %v", got, err)
+			}
+		})
+	}
+}
+
+func TestRefreshSerialized(t *testing.T) {
+	ctx := context.Background()
+	store := newFile(t)
+	store.Put(ctx, "alice", Token{Token: &oauth2.Token{RefreshToken: "0"}})
+
+	// each refresh rotates the refresh token, a racing refresh would build
+	// on a stale one and lose a rotation
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			store.Refresh(ctx, "alice", func(current Token) (Token, error) {
+				current.Token = &oauth2.Token{RefreshToken: current.Token.RefreshToken + "+"}
+				return current, nil
+			})
+		}()
+	}
+	wg.Wait()
+	if got, _ := store.Get(ctx, "alice"); got.Token.RefreshToken != "0++++++++++" {
+		t.Errorf("refresh token %q, want 10 rotations", got.Token.RefreshToken)
+	}
+}
+
+func TestFileEncrypted(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	store, _ := NewFile(path, []byte("test secret"))
+	if err := store.Put(ctx, "alice", Token{Token: &oauth2.Token{AccessToken: "secret-access-token"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "secret-access-token") || strings.Contains(string(data), "alice") {
+		t.Errorf("the token store is not encrypted: %s", data)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0o600 {
+		t.Errorf("the token store has mode %v, want 0600", info.Mode().Perm())
+	}
+
+	reopened, _ := NewFile(path, []byte("test secret"))
+	if got, err := reopened.Get(ctx, "alice"); err != nil || got.Token.AccessToken != "secret-access-token" {
+		t.Errorf("Get() after reopening = %+v, %v", got, err)
+	}
+	other, _ := NewFile(path, []byte("other secret"))
+	if _, err := other.Get(ctx, "alice"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() with another key = %v, want a decryption error", err)
+	}
+	if err := other.Put(ctx, "bob", Token{}); err == nil {
+		t.Errorf("Put() with another key overwrote the tokens of the first")
+	}
+	if _, err := NewFile(path, nil); err == nil {
+		t.Errorf("NewFile() accepted an empty key")
+	}
+}
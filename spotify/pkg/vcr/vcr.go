@@ -0,0 +1,353 @@
+// Package vcr records the HTTP calls of a client to a cassette file and
+// replays them, so tests of code calling upstream APIs are deterministic and
+// run without credentials or network. Secrets are scrubbed before anything
+// is written, so cassettes can be committed.
+//
+// A Transport replaces the transport of the http.Client of the code under
+// test, i.e. spotifyserver.WithHTTPClient:
+//
+//	recorder, err := vcr.New("testdata/search.json", vcr.ModeAuto)
+//	...
+//	defer recorder.Save()
+//	srv, err := spotifyserver.New(spotifyserver.WithHTTPClient(recorder.Client()), ...)
+package vcr
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Mode selects whether a Transport records or replays.
+type Mode int
+
+const (
+	// ModeAuto replays an existing cassette, and records one otherwise.
+	ModeAuto Mode = iota
+	// ModeReplay only replays, a request missing from the cassette fails.
+	ModeReplay
+	// ModeRecord calls the upstream and records every call, replacing the
+	// cassette on Save.
+	ModeRecord
+)
+
+// ModeEnv overrides the mode of the transports when set to "record" or
+// "replay", i.e. VCR_MODE=record go test ./... to record the cassettes
+// again.
+const ModeEnv = "VCR_MODE"
+
+// Redacted replaces the scrubbed secrets.
+const Redacted = "REDACTED"
+
+var (
+	// ErrNoInteraction is returned in replay when the cassette has no
+	// interaction for a request.
+	ErrNoInteraction = errors.New("vcr: no recorded interaction")
+
+	// SecretHeaders are the headers scrubbed by default.
+	SecretHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+	// SecretFields are the query parameters, form fields and top-level
+	// JSON fields scrubbed by default.
+	SecretFields = []string{
+		"access_token", "refresh_token", "id_token", "client_secret",
+		"code", "code_verifier", "password", "token",
+	}
+)
+
+// Request is a recorded request.
+type Request struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// Response is a recorded response.
+type Response struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// Interaction is a recorded call.
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Cassette is the content of a cassette file.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Transport is an http.RoundTripper recording to or replaying from a
+// cassette.
+type Transport struct {
+	// Base makes the recorded calls, http.DefaultTransport by default.
+	Base http.RoundTripper
+	// Headers and Fields are scrubbed from the requests and responses,
+	// SecretHeaders and SecretFields by default.
+	Headers []string
+	Fields  []string
+	// Secrets are scrubbed wherever they appear, i.e. a webhook URL or an
+	// API key in a path.
+	Secrets []string
+
+	path string
+	mode Mode
+
+	mu       sync.Mutex
+	cassette Cassette
+	replayed []bool
+}
+
+// New opens the cassette at path in mode, overridden by ModeEnv.
+func New(path string, mode Mode) (*Transport, error) {
+	switch os.Getenv(ModeEnv) {
+	case "record":
+		mode = ModeRecord
+	case "replay":
+		mode = ModeReplay
+	}
+	t := &Transport{Headers: SecretHeaders, Fields: SecretFields, path: path, mode: mode}
+	if mode == ModeRecord {
+		return t, nil
+	}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist) && mode == ModeAuto:
+		t.mode = ModeRecord
+		return t, nil
+	case err != nil:
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &t.cassette); err != nil {
+		return nil, fmt.Errorf("invalid cassette %s: %w", path, err)
+	}
+	t.mode = ModeReplay
+	t.replayed = make([]bool, len(t.cassette.Interactions))
+	return t, nil
+}
+
+// Recording reports whether t calls the upstream rather than replaying.
+func (t *Transport) Recording() bool {
+	return t.mode == ModeRecord
+}
+
+// Client returns an http.Client using t.
+func (t *Transport) Client() *http.Client {
+	return &http.Client{Transport: t}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+	recorded := t.scrubRequest(req, body)
+	if t.mode == ModeReplay {
+		return t.replay(req, recorded)
+	}
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := readBody(&resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	t.mu.Lock()
+	t.cassette.Interactions = append(t.cassette.Interactions, Interaction{
+		Request: recorded,
+		Response: Response{
+			Status: resp.StatusCode,
+			Header: t.scrubHeader(resp.Header),
+			Body:   t.scrubBody(resp.Header.Get("Content-Type"), respBody),
+		},
+	})
+	t.mu.Unlock()
+	return resp, nil
+}
+
+// replay answers req with the first interaction of the same method, URL and
+// body not replayed yet, or the last one replayed when all were, i.e. for a
+// polling loop.
+func (t *Transport) replay(req *http.Request, recorded Request) (*http.Response, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	found := -1
+	for i, interaction := range t.cassette.Interactions {
+		if interaction.Request.Method != recorded.Method || interaction.Request.URL != recorded.URL ||
+			interaction.Request.Body != recorded.Body {
+			continue
+		}
+		found = i
+		if !t.replayed[i] {
+			break
+		}
+	}
+	if found < 0 {
+		return nil, fmt.Errorf("%w for %s %s in %s", ErrNoInteraction, recorded.Method, recorded.URL, t.path)
+	}
+	t.replayed[found] = true
+
+	response := t.cassette.Interactions[found].Response
+	header := response.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", response.Status, http.StatusText(response.Status)),
+		StatusCode:    response.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(response.Body)),
+		ContentLength: int64(len(response.Body)),
+		Request:       req,
+	}, nil
+}
+
+// Save writes the recorded cassette, it does nothing in replay.
+func (t *Transport) Save() error {
+	if t.mode != ModeRecord {
+		return nil
+	}
+	t.mu.Lock()
+	data, err := json.MarshalIndent(t.cassette, "", "  ")
+	t.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(t.path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(t.path, append(data, '\n'), 0o644)
+}
+
+// readBody reads *body and replaces it by a reader of the same bytes.
+func readBody(body *io.ReadCloser) ([]byte, error) {
+	if *body == nil || *body == http.NoBody {
+		return nil, nil
+	}
+	data, err := io.ReadAll(*body)
+	(*body).Close()
+	if err != nil {
+		return nil, err
+	}
+	*body = io.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
+
+func (t *Transport) scrubRequest(req *http.Request, body []byte) Request {
+	u := *req.URL
+	query := u.Query()
+	for name := range query {
+		if t.secretField(name) {
+			query.Set(name, Redacted)
+		}
+	}
+	u.RawQuery = query.Encode()
+	return Request{
+		Method: req.Method,
+		URL:    t.scrubString(u.String()),
+		Header: t.scrubHeader(req.Header),
+		Body:   t.scrubBody(req.Header.Get("Content-Type"), body),
+	}
+}
+
+func (t *Transport) scrubHeader(header http.Header) http.Header {
+	if len(header) == 0 {
+		return nil
+	}
+	scrubbed := make(http.Header, len(header))
+	for name, values := range header {
+		for _, value := range values {
+			if t.secretHeader(name) {
+				value = Redacted
+			}
+			scrubbed.Add(name, t.scrubString(value))
+		}
+	}
+	return scrubbed
+}
+
+// scrubBody redacts the secret fields of form and JSON bodies, and the
+// secrets of any other body.
+func (t *Transport) scrubBody(contentType string, body []byte) string {
+	switch {
+	case len(body) == 0:
+		return ""
+	case strings.HasPrefix(contentType, "application/x-www-form-urlencoded"):
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			break
+		}
+		for name := range form {
+			if t.secretField(name) {
+				form.Set(name, Redacted)
+			}
+		}
+		return t.scrubString(form.Encode())
+	case strings.Contains(contentType, "json"):
+		var fields map[string]json.RawMessage
+		if json.Unmarshal(body, &fields) != nil {
+			break
+		}
+		redacted, _ := json.Marshal(Redacted)
+		for name := range fields {
+			if t.secretField(name) {
+				fields[name] = redacted
+			}
+		}
+		scrubbed, err := json.Marshal(fields)
+		if err != nil {
+			break
+		}
+		return t.scrubString(string(scrubbed))
+	}
+	return t.scrubString(string(body))
+}
+
+func (t *Transport) scrubString(s string) string {
+	for _, secret := range t.Secrets {
+		if secret != "" {
+			s = strings.ReplaceAll(s, secret, Redacted)
+		}
+	}
+	return s
+}
+
+func (t *Transport) secretHeader(name string) bool {
+	for _, secret := range t.Headers {
+		if strings.EqualFold(name, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Transport) secretField(name string) bool {
+	for _, secret := range t.Fields {
+		if strings.EqualFold(name, secret) {
+			return true
+		}
+	}
+	return false
+}
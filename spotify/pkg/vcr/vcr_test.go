@@ -0,0 +1,159 @@
+package vcr_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wagnerjt/go-mcp/spotify/pkg/spotifymock"
+	"github.com/wagnerjt/go-mcp/spotify/pkg/spotifyserver"
+	"github.com/wagnerjt/go-mcp/spotify/pkg/tokenstore"
+	"github.com/wagnerjt/go-mcp/spotify/pkg/vcr"
+	"golang.org/x/oauth2"
+)
+
+func newTransport(t *testing.T, path string, mode vcr.Mode) *vcr.Transport {
+	t.Helper()
+	transport, err := vcr.New(path, mode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return transport
+}
+
+func TestRecordReplay(t *testing.T) {
+	t.Setenv(vcr.ModeEnv, "")
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=secret-cookie")
+		w.Write([]byte(`{"access_token":"secret-access","token_type":"Bearer","grant":"` + r.PostForm.Get("grant_type") + `"}`))
+	}))
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	post := func(client *http.Client) (*http.Response, error) {
+		req, _ := http.NewRequest(http.MethodPost, upstream.URL+"/hooks/secret-hook?code=secret-code",
+			strings.NewReader(url.Values{"grant_type": {"refresh_token"}, "refresh_token": {"secret-refresh"}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Authorization", "Basic secret-basic")
+		return client.Do(req)
+	}
+
+	recorder := newTransport(t, path, vcr.ModeAuto)
+	recorder.Secrets = []string{"secret-hook"}
+	if !recorder.Recording() {
+		t.Fatal("a missing cassette is not recorded")
+	}
+	resp, err := post(recorder.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(recorded), "secret-access") {
+		t.Errorf("recording changed the response to %s", recorded)
+	}
+	if err := recorder.Save(); err != nil {
+		t.Fatal(err)
+	}
+	upstream.Close()
+
+	cassette, _ := os.ReadFile(path)
+	if strings.Contains(string(cassette), "secret") {
+		t.Errorf("the cassette has secrets: %s", cassette)
+	}
+
+	player := newTransport(t, path, vcr.ModeAuto)
+	player.Secrets = []string{"secret-hook"}
+	if player.Recording() {
+		t.Fatal("an existing cassette is not replayed")
+	}
+	for range 2 {
+		resp, err = post(player.Client())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body map[string]string
+		json.NewDecoder(resp.Body).Decode(&body)
+		if resp.StatusCode != http.StatusOK || body["grant"] != "refresh_token" || body["access_token"] != vcr.Redacted {
+			t.Errorf("replayed %d %v, want the scrubbed recording", resp.StatusCode, body)
+		}
+	}
+	if _, err := player.Client().Get(upstream.URL + "/other"); !errors.Is(err, vcr.ErrNoInteraction) {
+		t.Errorf("replay of an unrecorded request error = %v, want %v", err, vcr.ErrNoInteraction)
+	}
+}
+
+func TestReplayOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	os.WriteFile(path, []byte(`{"interactions":[
+		{"request":{"method":"GET","url":"http://api/status"},"response":{"status":200,"body":"pending"}},
+		{"request":{"method":"GET","url":"http://api/status"},"response":{"status":200,"body":"done"}}
+	]}`), 0o644)
+	player := newTransport(t, path, vcr.ModeReplay)
+
+	// the interactions are replayed in order, the last one repeatedly
+	for _, want := range []string{"pending", "done", "done"} {
+		resp, err := player.Client().Get("http://api/status")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body, _ := io.ReadAll(resp.Body); string(body) != want {
+			t.Errorf("replayed %q, want %q", body, want)
+		}
+	}
+	if _, err := vcr.New(filepath.Join(t.TempDir(), "missing.json"), vcr.ModeReplay); err == nil {
+		t.Error("New() replays a missing cassette")
+	}
+}
+
+// TestSpotifyServer records the calls of a Spotify tool and replays them
+// without the upstream.
+func TestSpotifyServer(t *testing.T) {
+	t.Setenv(vcr.ModeEnv, "")
+	mock, err := spotifymock.New(spotifymock.Fixtures)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := spotifymock.Listen(mock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "search.json")
+	search := func() string {
+		transport := newTransport(t, path, vcr.ModeAuto)
+		tokens := tokenstore.NewMemory()
+		tokens.Put(context.Background(), spotifyserver.TokenKey, tokenstore.Token{Token: &oauth2.Token{AccessToken: "secret-access"}})
+		srv, err := spotifyserver.New(
+			spotifyserver.WithClientCredentials("id", "secret"),
+			spotifyserver.WithAPIURL(listener.URL),
+			spotifyserver.WithTokenStore(tokens),
+			spotifyserver.WithHTTPClient(transport.Client()),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response := srv.MCPServer().HandleMessage(context.Background(),
+			[]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"search_spotify","arguments":{"query":"karma"}}}`))
+		if err := transport.Save(); err != nil {
+			t.Fatal(err)
+		}
+		data, _ := json.Marshal(response)
+		return string(data)
+	}
+
+	recorded := search()
+	listener.Close()
+	if replayed := search(); replayed != recorded || !strings.Contains(replayed, "Karma Police") {
+		t.Errorf("replayed %s, want the recorded %s", replayed, recorded)
+	}
+	if cassette, _ := os.ReadFile(path); strings.Contains(string(cassette), "secret-access") {
+		t.Errorf("the cassette has the access token: %s", cassette)
+	}
+}